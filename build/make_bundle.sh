@@ -27,6 +27,41 @@ RESOURCES="
     version: v1
 "
 
+SNAPSHOT_RESOURCES="
+  - kind: VolumeSnapshots
+    version: snapshot.storage.k8s.io/v1beta1
+"
+
+RESTORE_RESOURCES="
+  - kind: PersistentVolumeClaims
+    version: v1
+  - kind: Secrets
+    version: v1
+"
+
+CLUSTER_REPLICATION_RESOURCES="
+  - kind: IndexerClusters
+    version: enterprise.splunk.com/v1alpha2
+  - kind: Secrets
+    version: v1
+"
+
+HEC_TOKEN_RESOURCES="
+  - kind: Secrets
+    version: v1
+"
+
+UNIVERSAL_FORWARDER_RESOURCES="
+  - kind: DaemonSets
+    version: apps/v1
+  - kind: Deployments
+    version: apps/v1
+  - kind: Pods
+    version: v1
+  - kind: Secrets
+    version: v1
+"
+
 cat << EOF >$YAML_SCRIPT_FILE
 - command: update
   path: spec.install.spec.deployments[0].spec.template.spec.containers[0].image
@@ -39,35 +74,130 @@ cat << EOF >$YAML_SCRIPT_FILE
   value: $RESOURCES
 - command: update
   path: spec.customresourcedefinitions.owned[1].resources
-  value: $RESOURCES
+  value: $CLUSTER_REPLICATION_RESOURCES
 - command: update
   path: spec.customresourcedefinitions.owned[2].resources
   value: $RESOURCES
 - command: update
   path: spec.customresourcedefinitions.owned[3].resources
-  value: $RESOURCES
+  value: $HEC_TOKEN_RESOURCES
 - command: update
   path: spec.customresourcedefinitions.owned[4].resources
   value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[5].resources
+  value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[6].resources
+  value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[7].resources
+  value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[8].resources
+  value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[9].resources
+  value: $SNAPSHOT_RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[10].resources
+  value: $RESTORE_RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[11].resources
+  value: $RESOURCES
+- command: update
+  path: spec.customresourcedefinitions.owned[12].resources
+  value: $UNIVERSAL_FORWARDER_RESOURCES
 - command: update
   path: spec.customresourcedefinitions.owned[0].displayName
-  value: IndexerCluster
+  value: ClusterMaster
 - command: update
   path: spec.customresourcedefinitions.owned[1].displayName
-  value: LicenseMaster
+  value: ClusterReplication
 - command: update
   path: spec.customresourcedefinitions.owned[2].displayName
-  value: SearchHeadCluster
+  value: DeploymentServer
 - command: update
   path: spec.customresourcedefinitions.owned[3].displayName
-  value: Spark
+  value: HecToken
 - command: update
   path: spec.customresourcedefinitions.owned[4].displayName
+  value: IndexerCluster
+- command: update
+  path: spec.customresourcedefinitions.owned[5].displayName
+  value: LicenseMaster
+- command: update
+  path: spec.customresourcedefinitions.owned[6].displayName
+  value: MonitoringConsole
+- command: update
+  path: spec.customresourcedefinitions.owned[7].displayName
+  value: SearchHeadCluster
+- command: update
+  path: spec.customresourcedefinitions.owned[8].displayName
+  value: Spark
+- command: update
+  path: spec.customresourcedefinitions.owned[9].displayName
+  value: SplunkBackup
+- command: update
+  path: spec.customresourcedefinitions.owned[10].displayName
+  value: SplunkRestore
+- command: update
+  path: spec.customresourcedefinitions.owned[11].displayName
   value: Standalone
+- command: update
+  path: spec.customresourcedefinitions.owned[12].displayName
+  value: UniversalForwarder
 - command: update
   path: metadata.annotations.alm-examples
   value: |-
     [{
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "ClusterMaster",
+      "metadata": {
+        "name": "example-cm",
+        "finalizers": [ "enterprise.splunk.com/delete-pvc" ]
+      },
+      "spec": {}
+    },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "ClusterReplication",
+      "metadata": {
+        "name": "example"
+      },
+      "spec": {
+        "primaryRef": {
+          "name": "example"
+        },
+        "standbyRef": {
+          "name": "example-standby"
+        }
+      }
+    },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "DeploymentServer",
+      "metadata": {
+        "name": "example",
+        "finalizers": [ "enterprise.splunk.com/delete-pvc" ]
+      },
+      "spec": {}
+    },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "HecToken",
+      "metadata": {
+        "name": "example",
+        "finalizers": [ "enterprise.splunk.com/delete-hec-token" ]
+      },
+      "spec": {
+        "targetRef": {
+          "kind": "Standalone",
+          "name": "example"
+        }
+      }
+    },
+    {
       "apiVersion": "enterprise.splunk.com/v1alpha2",
       "kind": "IndexerCluster",
       "metadata": {
@@ -87,6 +217,15 @@ cat << EOF >$YAML_SCRIPT_FILE
       },
       "spec": {}
     },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "MonitoringConsole",
+      "metadata": {
+        "name": "example",
+        "finalizers": [ "enterprise.splunk.com/delete-pvc" ]
+      },
+      "spec": {}
+    },
     {
       "apiVersion": "enterprise.splunk.com/v1alpha2",
       "kind": "SearchHeadCluster",
@@ -108,6 +247,35 @@ cat << EOF >$YAML_SCRIPT_FILE
         "replicas": 1
       }
     },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "SplunkBackup",
+      "metadata": {
+        "name": "example"
+      },
+      "spec": {
+        "targetRef": {
+          "kind": "Standalone",
+          "name": "example"
+        }
+      }
+    },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "SplunkRestore",
+      "metadata": {
+        "name": "example"
+      },
+      "spec": {
+        "backupRef": {
+          "name": "example"
+        },
+        "targetRef": {
+          "kind": "Standalone",
+          "name": "example"
+        }
+      }
+    },
     {
       "apiVersion": "enterprise.splunk.com/v1alpha2",
       "kind": "Standalone",
@@ -116,6 +284,18 @@ cat << EOF >$YAML_SCRIPT_FILE
         "finalizers": [ "enterprise.splunk.com/delete-pvc" ]
       },
       "spec": {}
+    },
+    {
+      "apiVersion": "enterprise.splunk.com/v1alpha2",
+      "kind": "UniversalForwarder",
+      "metadata": {
+        "name": "example"
+      },
+      "spec": {
+        "indexerClusterRef": {
+          "name": "example"
+        }
+      }
     }]
 EOF
 
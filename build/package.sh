@@ -26,11 +26,27 @@ cat deploy/crds/enterprise.splunk.com_standalones_crd.yaml >> release-${VERSION}
 echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
 cat deploy/crds/enterprise.splunk.com_licensemasters_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
 echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_clustermasters_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_monitoringconsoles_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
 cat deploy/crds/enterprise.splunk.com_searchheadclusters_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
 echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
 cat deploy/crds/enterprise.splunk.com_indexerclusters_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
 echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
 cat deploy/crds/enterprise.splunk.com_sparks_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_splunkbackups_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_splunkrestores_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_hectokens_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_universalforwarders_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_deploymentservers_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
+echo "---" >> release-${VERSION}/splunk-operator-crds.yaml
+cat deploy/crds/enterprise.splunk.com_clusterreplications_crd.yaml >> release-${VERSION}/splunk-operator-crds.yaml
 
 echo Generating release-${VERSION}/splunk-operator-noadmin.yaml
 cat deploy/service_account.yaml deploy/role.yaml deploy/role_binding.yaml > release-${VERSION}/splunk-operator-noadmin.yaml
@@ -0,0 +1,255 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// getCustomResource returns a custom resource of a kind
+func (p *plugin) getCustomResource(kind *splunkKind, name string) (*unstructured.Unstructured, error) {
+	cr := &unstructured.Unstructured{}
+	cr.SetGroupVersionKind(enterprisev1.SchemeGroupVersion.WithKind(kind.kind))
+	err := p.client.Get(context.TODO(), types.NamespacedName{Namespace: p.namespace, Name: name}, cr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s \"%s\" in namespace \"%s\": %v", kind.kind, name, p.namespace, err)
+	}
+	return cr, nil
+}
+
+// getSelectors returns the pod selectors of all StatefulSets, Deployments and DaemonSets controlled by a custom resource
+func (p *plugin) getSelectors(cr *unstructured.Unstructured) ([]map[string]string, error) {
+	var selectors []map[string]string
+	isOwned := func(obj metav1.Object) bool {
+		owner := metav1.GetControllerOf(obj)
+		return owner != nil && owner.UID == cr.GetUID()
+	}
+
+	var statefulSets appsv1.StatefulSetList
+	if err := p.client.List(context.TODO(), &statefulSets, client.InNamespace(p.namespace)); err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		if isOwned(&statefulSets.Items[i]) && statefulSets.Items[i].Spec.Selector != nil {
+			selectors = append(selectors, statefulSets.Items[i].Spec.Selector.MatchLabels)
+		}
+	}
+
+	var deployments appsv1.DeploymentList
+	if err := p.client.List(context.TODO(), &deployments, client.InNamespace(p.namespace)); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		if isOwned(&deployments.Items[i]) && deployments.Items[i].Spec.Selector != nil {
+			selectors = append(selectors, deployments.Items[i].Spec.Selector.MatchLabels)
+		}
+	}
+
+	var daemonSets appsv1.DaemonSetList
+	if err := p.client.List(context.TODO(), &daemonSets, client.InNamespace(p.namespace)); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		if isOwned(&daemonSets.Items[i]) && daemonSets.Items[i].Spec.Selector != nil {
+			selectors = append(selectors, daemonSets.Items[i].Spec.Selector.MatchLabels)
+		}
+	}
+
+	return selectors, nil
+}
+
+// getPod returns the pod of a custom resource selected using the role and pod flags, and the role of the pod
+func (p *plugin) getPod(kind *splunkKind, name string) (*corev1.Pod, enterprise.InstanceType, error) {
+	role, err := kind.getRole(p.role)
+	if err != nil {
+		return nil, role, err
+	}
+	cr, err := p.getCustomResource(kind, name)
+	if err != nil {
+		return nil, role, err
+	}
+	selectors, err := p.getSelectors(cr)
+	if err != nil {
+		return nil, role, err
+	}
+
+	var pods []corev1.Pod
+	for _, selector := range selectors {
+		var list corev1.PodList
+		err = p.client.List(context.TODO(), &list, client.InNamespace(p.namespace), client.MatchingLabels(selector))
+		if err != nil {
+			return nil, role, err
+		}
+		pods = append(pods, list.Items...)
+	}
+	pod, err := selectPod(pods, role, p.pod)
+	if err != nil {
+		return nil, role, fmt.Errorf("%s \"%s\": %v", kind.kind, name, err)
+	}
+	return pod, role, nil
+}
+
+// getExecArgs returns the arguments used to run a command in the splunk container of a pod using kubectl
+func (p *plugin) getExecArgs(pod *corev1.Pod, command ...string) []string {
+	args := append([]string{}, p.kubectlFlags...)
+	args = append(args, "exec", "--namespace", pod.GetNamespace(), pod.GetName(), "--container", "splunk")
+	if isTerminal(os.Stdin) {
+		args = append(args, "--stdin", "--tty")
+	}
+	return append(append(args, "--"), command...)
+}
+
+// isTerminal returns true if a file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// getSplunkCommand returns a shell command that runs the splunk CLI as admin, using the password mounted in its pod
+// so that it is never passed on the command line of kubectl
+func getSplunkCommand(role enterprise.InstanceType, args []string) []string {
+	script := fmt.Sprintf(`exec %s/bin/splunk "$@" -auth "admin:$(cat /mnt/splunk-secrets/password)"`, enterprise.GetSplunkHome(role))
+	return append([]string{"sh", "-c", script, "splunk"}, args...)
+}
+
+// exec runs a splunk CLI command as admin in a pod of a custom resource
+func (p *plugin) exec(kind *splunkKind, name string, args []string) error {
+	pod, role, err := p.getPod(kind, name)
+	if err != nil {
+		return err
+	}
+	return p.runKubectl(p.getExecArgs(pod, getSplunkCommand(role, args)...)...)
+}
+
+// logs prints (or follows) splunkd.log of a pod of a custom resource
+func (p *plugin) logs(kind *splunkKind, name string) error {
+	pod, role, err := p.getPod(kind, name)
+	if err != nil {
+		return err
+	}
+	command := []string{"tail", "-n", strconv.Itoa(p.tail)}
+	if p.follow {
+		command = append(command, "-F")
+	}
+	command = append(command, enterprise.GetSplunkHome(role)+"/var/log/splunk/splunkd.log")
+	return p.runKubectl(p.getExecArgs(pod, command...)...)
+}
+
+// credentials prints the admin credentials of a custom resource, using the Secret mounted in its pods
+func (p *plugin) credentials(kind *splunkKind, name string) error {
+	pod, _, err := p.getPod(kind, name)
+	if err != nil {
+		return err
+	}
+	secretName := ""
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == "mnt-splunk-secrets" && v.Secret != nil {
+			secretName = v.Secret.SecretName
+		}
+	}
+	if secretName == "" {
+		return fmt.Errorf("pod \"%s\" does not mount Splunk secrets", pod.GetName())
+	}
+
+	var secret corev1.Secret
+	err = p.client.Get(context.TODO(), types.NamespacedName{Namespace: p.namespace, Name: secretName}, &secret)
+	if err != nil {
+		return fmt.Errorf("unable to get secret \"%s\": %v", secretName, err)
+	}
+	fmt.Fprintf(p.out, "username: admin\npassword: %s\n", enterprise.GetAppliedAdminPassword(&secret))
+	return nil
+}
+
+// annotate sets an annotation on a custom resource (or removes it, if value is nil), which the operator handles the next
+// time it is reconciled
+func (p *plugin) annotate(cr *unstructured.Unstructured, annotation string, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{annotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return p.client.Patch(context.TODO(), cr, client.ConstantPatch(types.MergePatchType, patch))
+}
+
+// restart requests a rolling restart of all pods of a custom resource, using its restarted-at annotation
+func (p *plugin) restart(kind *splunkKind, name string) error {
+	if len(kind.roles) == 0 {
+		return fmt.Errorf("%s does not manage Splunk Enterprise pods", kind.kind)
+	}
+	cr, err := p.getCustomResource(kind, name)
+	if err != nil {
+		return err
+	}
+	if err = p.annotate(cr, enterprise.RestartAnnotation, p.now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Requested a rolling restart of %s \"%s\"\n", kind.kind, name)
+	return nil
+}
+
+// pushBundle requests a configuration bundle push from a custom resource, using its bundle-push annotation
+func (p *plugin) pushBundle(kind *splunkKind, name string) error {
+	if !kind.bundles {
+		return fmt.Errorf("%s does not push configuration bundles", kind.kind)
+	}
+	cr, err := p.getCustomResource(kind, name)
+	if err != nil {
+		return err
+	}
+	if ref, _, _ := unstructured.NestedString(cr.Object, "spec", "clusterMasterRef", "name"); ref != "" {
+		return fmt.Errorf("%s \"%s\" uses ClusterMaster \"%s\"; push its bundle instead", kind.kind, name, ref)
+	}
+	if err = p.annotate(cr, enterprise.BundlePushAnnotation, p.now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Requested a configuration bundle push from %s \"%s\"\n", kind.kind, name)
+	return nil
+}
+
+// setPaused pauses or resumes reconciles of a custom resource, using its paused annotation
+func (p *plugin) setPaused(kind *splunkKind, name string, paused bool) error {
+	cr, err := p.getCustomResource(kind, name)
+	if err != nil {
+		return err
+	}
+	var value interface{} = "true"
+	action := "Paused"
+	if !paused {
+		value, action = nil, "Resumed"
+	}
+	if err = p.annotate(cr, enterprise.PausedAnnotation, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "%s reconciles of %s \"%s\"\n", action, kind.kind, name)
+	return nil
+}
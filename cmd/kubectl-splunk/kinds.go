@@ -0,0 +1,119 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// splunkKind describes a kind of custom resource managed by the Splunk Operator
+type splunkKind struct {
+	// kind of the custom resource
+	kind string
+
+	// names that may be used for the kind in commands, in addition to its lowercase kind
+	names []string
+
+	// roles of the Splunk Enterprise instances managed by custom resources of the kind; the first role is used by default
+	roles []enterprise.InstanceType
+
+	// true if custom resources of the kind push configuration bundles
+	bundles bool
+}
+
+// splunkKinds are all custom resource kinds managed by the Splunk Operator, in the order that they are listed
+var splunkKinds = []splunkKind{
+	{kind: "Standalone", names: []string{"standalones"}, roles: []enterprise.InstanceType{enterprise.SplunkStandalone}},
+	{kind: "LicenseMaster", names: []string{"licensemasters", "lm"}, roles: []enterprise.InstanceType{enterprise.SplunkLicenseMaster}},
+	{kind: "ClusterMaster", names: []string{"clustermasters", "cm"}, roles: []enterprise.InstanceType{enterprise.SplunkClusterMaster}, bundles: true},
+	{kind: "IndexerCluster", names: []string{"indexerclusters", "idc", "idxc"}, roles: []enterprise.InstanceType{enterprise.SplunkIndexer, enterprise.SplunkClusterMaster}, bundles: true},
+	{kind: "SearchHeadCluster", names: []string{"searchheadclusters", "shc"}, roles: []enterprise.InstanceType{enterprise.SplunkSearchHead, enterprise.SplunkDeployer}, bundles: true},
+	{kind: "MonitoringConsole", names: []string{"monitoringconsoles", "mc"}, roles: []enterprise.InstanceType{enterprise.SplunkMonitoringConsole}},
+	{kind: "DeploymentServer", names: []string{"deploymentservers", "ds"}, roles: []enterprise.InstanceType{enterprise.SplunkDeploymentServer}},
+	{kind: "UniversalForwarder", names: []string{"universalforwarders", "uf"}, roles: []enterprise.InstanceType{enterprise.SplunkUniversalForwarder}},
+	{kind: "Spark", names: []string{"sparks"}},
+	{kind: "HecToken", names: []string{"hectokens", "hec"}},
+	{kind: "SplunkBackup", names: []string{"splunkbackups", "sb"}},
+	{kind: "SplunkRestore", names: []string{"splunkrestores", "sr"}},
+	{kind: "ClusterReplication", names: []string{"clusterreplications", "crep"}},
+}
+
+// findSplunkKind returns the custom resource kind with a name, which may be its kind, plural or short name
+func findSplunkKind(name string) (*splunkKind, error) {
+	name = strings.ToLower(name)
+	for i := range splunkKinds {
+		kind := &splunkKinds[i]
+		if name == strings.ToLower(kind.kind) {
+			return kind, nil
+		}
+		for _, n := range kind.names {
+			if name == n {
+				return kind, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown kind \"%s\"", name)
+}
+
+// getRole returns the role of the pods to use for a custom resource of the kind, which is its first role unless
+// another one is requested
+func (kind *splunkKind) getRole(role string) (enterprise.InstanceType, error) {
+	if len(kind.roles) == 0 {
+		return "", fmt.Errorf("%s does not manage Splunk Enterprise pods", kind.kind)
+	}
+	if role == "" {
+		return kind.roles[0], nil
+	}
+	for _, r := range kind.roles {
+		if role == r.ToString() {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("%s does not have pods with role \"%s\"", kind.kind, role)
+}
+
+// selectPod returns the pod with a name or StatefulSet ordinal index from a list of pods with a role, or the first
+// of them (sorted by name) if pod is empty
+func selectPod(pods []corev1.Pod, role enterprise.InstanceType, pod string) (*corev1.Pod, error) {
+	var candidates []corev1.Pod
+	for _, p := range pods {
+		if p.GetLabels()["app.kubernetes.io/name"] == role.ToString() {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no pods found with role \"%s\"", role)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].GetName() < candidates[j].GetName() })
+	if pod == "" {
+		return &candidates[0], nil
+	}
+
+	_, err := strconv.Atoi(pod)
+	for i := range candidates {
+		name := candidates[i].GetName()
+		if name == pod || (err == nil && strings.HasSuffix(name, "-"+pod)) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no pod \"%s\" found with role \"%s\"", pod, role)
+}
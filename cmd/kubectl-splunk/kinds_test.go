@@ -0,0 +1,104 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestFindSplunkKind(t *testing.T) {
+	test := func(name string, want string) {
+		kind, err := findSplunkKind(name)
+		if want == "" {
+			if err == nil {
+				t.Errorf("findSplunkKind(%s) = %s; want error", name, kind.kind)
+			}
+			return
+		}
+		if err != nil || kind.kind != want {
+			t.Errorf("findSplunkKind(%s) = %v, %v; want %s", name, kind, err, want)
+		}
+	}
+
+	test("Standalone", "Standalone")
+	test("standalones", "Standalone")
+	test("idxc", "IndexerCluster")
+	test("IndexerCluster", "IndexerCluster")
+	test("shc", "SearchHeadCluster")
+	test("hec", "HecToken")
+	test("crep", "ClusterReplication")
+	test("pods", "")
+}
+
+func TestGetRole(t *testing.T) {
+	test := func(name, role string, want enterprise.InstanceType, wantErr bool) {
+		kind, _ := findSplunkKind(name)
+		got, err := kind.getRole(role)
+		if wantErr {
+			if err == nil {
+				t.Errorf("getRole(%s, %s) = %s; want error", name, role, got)
+			}
+			return
+		}
+		if err != nil || got != want {
+			t.Errorf("getRole(%s, %s) = %s, %v; want %s", name, role, got, err, want)
+		}
+	}
+
+	test("shc", "", enterprise.SplunkSearchHead, false)
+	test("shc", "deployer", enterprise.SplunkDeployer, false)
+	test("idxc", "cluster-master", enterprise.SplunkClusterMaster, false)
+	test("idxc", "deployer", "", true)
+	test("hec", "", "", true)
+}
+
+func TestSelectPod(t *testing.T) {
+	newPod := func(name string, role enterprise.InstanceType) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app.kubernetes.io/name": role.ToString()}}}
+	}
+	pods := []corev1.Pod{
+		newPod("splunk-stack1-search-head-1", enterprise.SplunkSearchHead),
+		newPod("splunk-stack1-deployer-0", enterprise.SplunkDeployer),
+		newPod("splunk-stack1-search-head-0", enterprise.SplunkSearchHead),
+		newPod("splunk-stack1-search-head-10", enterprise.SplunkSearchHead),
+	}
+
+	test := func(role enterprise.InstanceType, pod string, want string) {
+		got, err := selectPod(pods, role, pod)
+		if want == "" {
+			if err == nil {
+				t.Errorf("selectPod(%s, %s) = %s; want error", role, pod, got.GetName())
+			}
+			return
+		}
+		if err != nil || got.GetName() != want {
+			t.Errorf("selectPod(%s, %s) = %v, %v; want %s", role, pod, got, err, want)
+		}
+	}
+
+	test(enterprise.SplunkSearchHead, "", "splunk-stack1-search-head-0")
+	test(enterprise.SplunkSearchHead, "1", "splunk-stack1-search-head-1")
+	test(enterprise.SplunkSearchHead, "10", "splunk-stack1-search-head-10")
+	test(enterprise.SplunkSearchHead, "splunk-stack1-search-head-1", "splunk-stack1-search-head-1")
+	test(enterprise.SplunkSearchHead, "2", "")
+	test(enterprise.SplunkSearchHead, "splunk-stack1-deployer-0", "")
+	test(enterprise.SplunkDeployer, "", "splunk-stack1-deployer-0")
+	test(enterprise.SplunkIndexer, "", "")
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// resourceHealth summarizes the health of a custom resource, using its status
+type resourceHealth struct {
+	kind      string
+	namespace string
+	name      string
+	phase     string
+	ready     string
+	age       string
+	message   string
+}
+
+// getResourceHealth returns the health of a custom resource. The number of ready instances is only reported for kinds
+// that report replicas in their status, and the message of its Degraded condition is only reported while it has one.
+// Custom resources whose reconciles are paused report that instead, if they are not degraded. Otherwise, the message
+// explaining the current phase is reported, if there is one.
+func getResourceHealth(obj *unstructured.Unstructured, now time.Time) resourceHealth {
+	health := resourceHealth{
+		kind:      obj.GetKind(),
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+		ready:     "-",
+		age:       duration.HumanDuration(now.Sub(obj.GetCreationTimestamp().Time)),
+	}
+	health.phase, _, _ = unstructured.NestedString(obj.Object, "status", "phase")
+	if health.phase == "" {
+		health.phase = string(enterprisev1.PhasePending)
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(obj.Object, "status", "replicas"); ok {
+		readyReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		health.ready = fmt.Sprintf("%d/%d", readyReplicas, replicas)
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		if condition["type"] == "Degraded" {
+			health.message, _ = condition["message"].(string)
+		} else if condition["type"] == "Paused" && health.message == "" {
+			health.message = "Paused"
+		}
+	}
+	if health.message == "" {
+		health.message, _, _ = unstructured.NestedString(obj.Object, "status", "message")
+	}
+	return health
+}
+
+// list prints the health of all custom resources of a kind, or of all kinds if args is empty, followed by a rollup of
+// the number of custom resources in each phase
+func (p *plugin) list(args []string) error {
+	kinds := splunkKinds
+	if len(args) == 1 {
+		kind, err := findSplunkKind(args[0])
+		if err != nil {
+			return err
+		}
+		kinds = []splunkKind{*kind}
+	}
+
+	var opts []client.ListOption
+	if !p.allNamespaces {
+		opts = append(opts, client.InNamespace(p.namespace))
+	}
+	var results []resourceHealth
+	for _, kind := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(enterprisev1.SchemeGroupVersion.WithKind(kind.kind + "List"))
+		err := p.client.List(context.TODO(), list, opts...)
+		if err != nil {
+			return fmt.Errorf("unable to list %s: %v", kind.kind, err)
+		}
+		for i := range list.Items {
+			results = append(results, getResourceHealth(&list.Items[i], p.now()))
+		}
+	}
+
+	printResourceHealth(p.out, results, p.allNamespaces)
+	return nil
+}
+
+// printResourceHealth prints a table of the health of custom resources, followed by a rollup of the number of custom
+// resources in each phase
+func printResourceHealth(out io.Writer, results []resourceHealth, allNamespaces bool) {
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No Splunk custom resources found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	if allNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "KIND\tNAME\tPHASE\tREADY\tAGE\tMESSAGE")
+	phases := make(map[string]int)
+	for _, r := range results {
+		if allNamespaces {
+			fmt.Fprintf(w, "%s\t", r.namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.kind, r.name, r.phase, r.ready, r.age, r.message)
+		phases[r.phase]++
+	}
+	w.Flush()
+
+	var rollup []string
+	for phase, count := range phases {
+		rollup = append(rollup, fmt.Sprintf("%d %s", count, phase))
+	}
+	sort.Strings(rollup)
+	healthy := "healthy"
+	if phases[string(enterprisev1.PhaseError)] > 0 {
+		healthy = "degraded"
+	} else if phases[string(enterprisev1.PhaseReady)] != len(results) {
+		healthy = "progressing"
+	}
+	fmt.Fprintf(out, "\n%d custom resources (%s): %s\n", len(results), healthy, strings.Join(rollup, ", "))
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetResourceHealth(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	test := func(obj map[string]interface{}, want resourceHealth) {
+		got := getResourceHealth(&unstructured.Unstructured{Object: obj}, now)
+		if got != want {
+			t.Errorf("getResourceHealth(%v) = %v; want %v", obj, got, want)
+		}
+	}
+
+	metadata := map[string]interface{}{"name": "stack1", "namespace": "test", "creationTimestamp": "2020-06-01T10:00:00Z"}
+	test(map[string]interface{}{"kind": "Standalone", "metadata": metadata},
+		resourceHealth{kind: "Standalone", namespace: "test", name: "stack1", phase: "Pending", ready: "-", age: "2h"})
+	test(map[string]interface{}{"kind": "IndexerCluster", "metadata": metadata,
+		"status": map[string]interface{}{"phase": "Ready", "replicas": int64(3), "readyReplicas": int64(2)}},
+		resourceHealth{kind: "IndexerCluster", namespace: "test", name: "stack1", phase: "Ready", ready: "2/3", age: "2h"})
+	test(map[string]interface{}{"kind": "SearchHeadCluster", "metadata": metadata,
+		"status": map[string]interface{}{"phase": "Error", "conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "message": "not ready"},
+			map[string]interface{}{"type": "Degraded", "status": "True", "message": "captain not found"},
+		}}},
+		resourceHealth{kind: "SearchHeadCluster", namespace: "test", name: "stack1", phase: "Error", ready: "-", age: "2h", message: "captain not found"})
+	test(map[string]interface{}{"kind": "ClusterMaster", "metadata": metadata,
+		"status": map[string]interface{}{"phase": "Ready", "conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "True"},
+			map[string]interface{}{"type": "Paused", "status": "True", "message": "Reconciles are paused"},
+		}}},
+		resourceHealth{kind: "ClusterMaster", namespace: "test", name: "stack1", phase: "Ready", ready: "-", age: "2h", message: "Paused"})
+	test(map[string]interface{}{"kind": "LicenseMaster", "metadata": metadata,
+		"status": map[string]interface{}{"phase": "Pending", "reason": "PodUnschedulable", "message": "pod splunk-stack1-license-master-0 cannot be scheduled"}},
+		resourceHealth{kind: "LicenseMaster", namespace: "test", name: "stack1", phase: "Pending", ready: "-", age: "2h", message: "pod splunk-stack1-license-master-0 cannot be scheduled"})
+}
+
+func TestPrintResourceHealth(t *testing.T) {
+	test := func(results []resourceHealth, allNamespaces bool, want string) {
+		var out bytes.Buffer
+		printResourceHealth(&out, results, allNamespaces)
+		if got := out.String(); got != want {
+			t.Errorf("printResourceHealth() =\n%s\nwant\n%s", got, want)
+		}
+	}
+
+	test(nil, false, "No Splunk custom resources found.\n")
+
+	results := []resourceHealth{
+		{kind: "Standalone", namespace: "test", name: "s1", phase: "Ready", ready: "1/1", age: "2h"},
+		{kind: "IndexerCluster", namespace: "prod", name: "idxc", phase: "Updating", ready: "2/3", age: "5d"},
+	}
+	test(results, false, "KIND             NAME   PHASE      READY   AGE   MESSAGE\n"+
+		"Standalone       s1     Ready      1/1     2h    \n"+
+		"IndexerCluster   idxc   Updating   2/3     5d    \n"+
+		"\n2 custom resources (progressing): 1 Ready, 1 Updating\n")
+
+	results[1].phase = "Error"
+	results[1].message = "cluster master not found"
+	test(results, true, "NAMESPACE   KIND             NAME   PHASE   READY   AGE   MESSAGE\n"+
+		"test        Standalone       s1     Ready   1/1     2h    \n"+
+		"prod        IndexerCluster   idxc   Error   2/3     5d    cluster master not found\n"+
+		"\n2 custom resources (degraded): 1 Error, 1 Ready\n")
+
+	test(results[:1], false, "KIND         NAME   PHASE   READY   AGE   MESSAGE\n"+
+		"Standalone   s1     Ready   1/1     2h    \n"+
+		"\n1 custom resources (healthy): 1 Ready\n")
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes/scheme"
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/splunk/splunk-operator/pkg/apis"
+)
+
+const usage = `kubectl splunk works with the Splunk Enterprise custom resources managed by the Splunk Operator.
+
+Usage:
+  kubectl splunk list [KIND]                      List custom resources and a rollup of their health
+  kubectl splunk exec KIND NAME -- COMMAND...     Run a splunk CLI command as admin in a pod of a custom resource
+  kubectl splunk credentials KIND NAME            Print the admin credentials of a custom resource
+  kubectl splunk logs KIND NAME                   Print (or follow) splunkd.log of a pod of a custom resource
+  kubectl splunk restart KIND NAME                Request a rolling restart of all pods of a custom resource
+  kubectl splunk push-bundle KIND NAME            Request a configuration bundle push from a ClusterMaster,
+                                                  IndexerCluster or SearchHeadCluster
+  kubectl splunk pause KIND NAME                  Suspend all reconciles of a custom resource by the operator
+  kubectl splunk resume KIND NAME                 Resume reconciles of a paused custom resource
+
+KIND may be the name, plural or short name of any custom resource kind, such as "indexercluster" or "idxc".
+
+Flags:
+`
+
+// plugin is used to run the commands of the kubectl-splunk plugin
+type plugin struct {
+	// client used to read and update Kubernetes resources
+	client client.Client
+
+	// namespace of the custom resources
+	namespace string
+
+	// list custom resources in all namespaces
+	allNamespaces bool
+
+	// role of the pods to use for a custom resource that manages more than one kind of Splunk Enterprise instance
+	role string
+
+	// name or index of the pod to use, instead of the first one
+	pod string
+
+	// follow splunkd.log instead of exiting after printing it
+	follow bool
+
+	// number of lines of splunkd.log to print
+	tail int
+
+	// flags passed to kubectl when it is called to exec commands in pods
+	kubectlFlags []string
+
+	// runKubectl runs kubectl with arguments
+	runKubectl func(args ...string) error
+
+	// output of commands
+	out io.Writer
+
+	// now returns the current time
+	now func() time.Time
+}
+
+func main() {
+	p := &plugin{out: os.Stdout, now: time.Now}
+	var kubeconfig, context string
+
+	flags := pflag.NewFlagSet("kubectl-splunk", pflag.ContinueOnError)
+	flags.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use")
+	flags.StringVar(&context, "context", "", "Name of the kubeconfig context to use")
+	flags.StringVarP(&p.namespace, "namespace", "n", "", "Namespace of the custom resources (defaults to the namespace of the current context)")
+	flags.BoolVarP(&p.allNamespaces, "all-namespaces", "A", false, "List custom resources in all namespaces")
+	flags.StringVar(&p.role, "role", "", "Role of the pods to use, such as \"deployer\" for a SearchHeadCluster or \"cluster-master\" for an IndexerCluster")
+	flags.StringVar(&p.pod, "pod", "", "Name or index of the pod to use (defaults to the first one)")
+	flags.BoolVarP(&p.follow, "follow", "f", false, "Follow splunkd.log")
+	flags.IntVar(&p.tail, "tail", 100, "Number of lines of splunkd.log to print")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == pflag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	// commands are run with the same kubeconfig, context and default namespace as kubectl
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: context})
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		fail(err)
+	}
+	if p.namespace == "" {
+		if p.namespace, _, err = clientConfig.Namespace(); err != nil {
+			fail(err)
+		}
+	}
+	if err = apis.AddToScheme(scheme.Scheme); err != nil {
+		fail(err)
+	}
+	if p.client, err = client.New(cfg, client.Options{Scheme: scheme.Scheme}); err != nil {
+		fail(err)
+	}
+	if kubeconfig != "" {
+		p.kubectlFlags = append(p.kubectlFlags, "--kubeconfig", kubeconfig)
+	}
+	if context != "" {
+		p.kubectlFlags = append(p.kubectlFlags, "--context", context)
+	}
+	p.runKubectl = func(args ...string) error {
+		cmd := exec.Command("kubectl", args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+
+	if err = p.run(flags.Args(), flags.ArgsLenAtDash()); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// errors have already been printed by kubectl
+			os.Exit(exitErr.ExitCode())
+		}
+		fail(err)
+	}
+}
+
+// fail prints an error and exits
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
+
+// run runs the command given by args. Arguments after dash (or -1 if there are none) are passed to the command being
+// executed in a pod.
+func (p *plugin) run(args []string, dash int) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a command is required; see \"kubectl splunk --help\"")
+	}
+	command, args := args[0], args[1:]
+	var commandArgs []string
+	if dash >= 1 {
+		commandArgs = args[dash-1:]
+		args = args[:dash-1]
+	}
+
+	if command == "list" {
+		if len(args) > 1 {
+			return fmt.Errorf("list accepts at most one KIND")
+		}
+		return p.list(args)
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("%s requires KIND and NAME", command)
+	}
+	kind, err := findSplunkKind(args[0])
+	if err != nil {
+		return err
+	}
+	name := args[1]
+
+	switch command {
+	case "exec":
+		if len(commandArgs) == 0 {
+			return fmt.Errorf("exec requires a splunk CLI command after \"--\", such as \"kubectl splunk exec standalone s1 -- status\"")
+		}
+		return p.exec(kind, name, commandArgs)
+	case "credentials":
+		return p.credentials(kind, name)
+	case "logs":
+		return p.logs(kind, name)
+	case "restart":
+		return p.restart(kind, name)
+	case "push-bundle":
+		return p.pushBundle(kind, name)
+	case "pause":
+		return p.setPaused(kind, name, true)
+	case "resume":
+		return p.setPaused(kind, name, false)
+	}
+	return fmt.Errorf("unknown command \"%s\"; see \"kubectl splunk --help\"", command)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// getWatchNamespaces returns the list of namespaces in a comma-separated WATCH_NAMESPACE value. An empty value is used to
+// watch all namespaces, and results in an empty list.
+func getWatchNamespaces(watchNamespace string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(watchNamespace, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// multiNamespaceCache is used to watch more than one namespace. Namespaced objects are read using a cache for each of the
+// watched namespaces, and cluster-scoped objects (such as StorageClasses) are read using a cluster-wide cache.
+type multiNamespaceCache struct {
+	cache.Cache
+	clusterCache cache.Cache
+	scheme       *runtime.Scheme
+	mapper       meta.RESTMapper
+}
+
+// newMultiNamespaceCache returns a function used by the manager to create a cache for a list of namespaces
+func newMultiNamespaceCache(namespaces []string) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		namespacedCache, err := cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Namespace = ""
+		clusterCache, err := cache.New(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &multiNamespaceCache{
+			Cache:        namespacedCache,
+			clusterCache: clusterCache,
+			scheme:       opts.Scheme,
+			mapper:       opts.Mapper,
+		}, nil
+	}
+}
+
+// Start for multiNamespaceCache starts both the namespaced and cluster-wide caches, and blocks until stop is closed
+func (c *multiNamespaceCache) Start(stop <-chan struct{}) error {
+	errs := make(chan error, 1)
+	go func() {
+		if err := c.clusterCache.Start(stop); err != nil {
+			errs <- err
+		}
+	}()
+	if err := c.Cache.Start(stop); err != nil {
+		return err
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// WaitForCacheSync for multiNamespaceCache waits for both the namespaced and cluster-wide caches to sync
+func (c *multiNamespaceCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return c.Cache.WaitForCacheSync(stop) && c.clusterCache.WaitForCacheSync(stop)
+}
+
+// Get for multiNamespaceCache reads cluster-scoped objects from the cluster-wide cache, and all others from the namespaced caches
+func (c *multiNamespaceCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if key.Namespace == "" && c.isClusterScoped(obj) {
+		return c.clusterCache.Get(ctx, key, obj)
+	}
+	return c.Cache.Get(ctx, key, obj)
+}
+
+// isClusterScoped returns true if an object's kind is not namespaced
+func (c *multiNamespaceCache) isClusterScoped(obj runtime.Object) bool {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return false
+	}
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameRoot
+}
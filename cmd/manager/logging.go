@@ -0,0 +1,278 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// logFormatJSON writes each log entry as a JSON object
+	logFormatJSON = "json"
+
+	// logFormatConsole writes log entries in a human readable format, for development
+	logFormatConsole = "console"
+
+	// defaultLogConfigMapName is the name of the ConfigMap in the operator's namespace used to change logging at runtime
+	defaultLogConfigMapName = "splunk-operator-logging"
+
+	// time to wait before watching the logging ConfigMap again, after its watch was closed or failed
+	logConfigRetryInterval = 10 * time.Second
+)
+
+// logSettings are the level and format used for operator logs
+type logSettings struct {
+	level  zapcore.Level
+	format string
+}
+
+// parseLogSettings returns the log settings for a level and format, which default to "info" and "json" if empty. Levels may
+// be given by name ("debug", "info", "warn" or "error"), or as a positive integer to enable more verbose debug logs.
+func parseLogSettings(level, format string) (logSettings, error) {
+	settings := logSettings{level: zapcore.InfoLevel, format: logFormatJSON}
+	if level != "" {
+		if n, err := strconv.Atoi(level); err == nil {
+			if n < 0 {
+				return settings, fmt.Errorf("Invalid log level %q: must not be negative", level)
+			}
+			settings.level = zapcore.Level(-n)
+		} else if err := settings.level.UnmarshalText([]byte(level)); err != nil {
+			return settings, fmt.Errorf("Invalid log level %q: %v", level, err)
+		}
+	}
+	if format != "" {
+		if format != logFormatJSON && format != logFormatConsole {
+			return settings, fmt.Errorf("Invalid log format %q: must be either \"%s\" or \"%s\"", format, logFormatJSON, logFormatConsole)
+		}
+		settings.format = format
+	}
+	return settings, nil
+}
+
+// getLogSettingsEnv returns the log settings in the LOG_LEVEL and LOG_FORMAT environment variables
+func getLogSettingsEnv() (logSettings, error) {
+	return parseLogSettings(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
+
+// operatorLogging holds the level and format of operator logs, which can be changed at runtime without replacing the
+// logger that is propagated through the operator
+type operatorLogging struct {
+	level zap.AtomicLevel
+
+	mu       sync.RWMutex
+	format   string
+	core     zapcore.Core
+	defaults logSettings
+}
+
+// newOperatorLogging returns operatorLogging using default settings, which are also restored if the logging ConfigMap is
+// deleted
+func newOperatorLogging(defaults logSettings) *operatorLogging {
+	l := &operatorLogging{level: zap.NewAtomicLevel(), defaults: defaults}
+	l.apply(defaults)
+	return l
+}
+
+// Logger returns a logr.Logger that writes using the current level and format
+func (l *operatorLogging) Logger() logr.Logger {
+	return zapr.NewLogger(zap.New(&dynamicCore{logging: l}, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr))))
+}
+
+// apply changes the level and format of operator logs, and returns true if either has changed
+func (l *operatorLogging) apply(settings logSettings) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := l.level.Level() != settings.level || l.format != settings.format
+	l.level.SetLevel(settings.level)
+	if l.core == nil || l.format != settings.format {
+		var encoder zapcore.Encoder
+		if settings.format == logFormatConsole {
+			encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+		} else {
+			encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		}
+		// the core itself logs every entry, since levels are checked by the dynamicCore using the atomic level
+		l.core = zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), zap.LevelEnablerFunc(func(zapcore.Level) bool { return true }))
+		l.format = settings.format
+	}
+	return changed
+}
+
+// applyConfigMap changes the level and format of operator logs using the "level" and "format" values of a ConfigMap,
+// or restores the defaults if it is nil. Missing values are taken from the defaults, and invalid values are logged and
+// ignored.
+func (l *operatorLogging) applyConfigMap(configMap *corev1.ConfigMap) {
+	settings := l.defaults
+	if configMap != nil {
+		level, format := configMap.Data["level"], configMap.Data["format"]
+		var err error
+		settings, err = parseLogSettings(level, format)
+		if err != nil {
+			log.Error(err, "Ignoring invalid logging configuration", "configMap", configMap.GetName())
+			return
+		}
+		if level == "" {
+			settings.level = l.defaults.level
+		}
+		if format == "" {
+			settings.format = l.defaults.format
+		}
+	}
+	if l.apply(settings) {
+		log.Info("Updated logging configuration", "level", settings.level.String(), "format", settings.format)
+	}
+}
+
+// getCore returns the zapcore.Core used to encode and write log entries in the current format
+func (l *operatorLogging) getCore() zapcore.Core {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.core
+}
+
+// dynamicCore is a zapcore.Core that checks the current level of operatorLogging, and writes entries using its current
+// format. Fields added using With are kept, and added to each entry when it is written.
+type dynamicCore struct {
+	logging *operatorLogging
+	fields  []zapcore.Field
+}
+
+// Enabled returns true if entries at a level are logged
+func (c *dynamicCore) Enabled(level zapcore.Level) bool {
+	return c.logging.level.Enabled(level)
+}
+
+// With returns a copy of the core with additional fields
+func (c *dynamicCore) With(fields []zapcore.Field) zapcore.Core {
+	combined := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	combined = append(combined, c.fields...)
+	return &dynamicCore{logging: c.logging, fields: append(combined, fields...)}
+}
+
+// Check adds the core to a checked entry, if its level is enabled
+func (c *dynamicCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write encodes and writes an entry using the current format
+func (c *dynamicCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	core := c.logging.getCore()
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
+	}
+	return core.Write(entry, fields)
+}
+
+// Sync flushes buffered entries
+func (c *dynamicCore) Sync() error {
+	return c.logging.getCore().Sync()
+}
+
+// watchLoggingConfig changes the level and format of operator logs whenever a ConfigMap in the operator's namespace is
+// created, updated or deleted, and reloads it when the operator receives SIGHUP. The ConfigMap is named using the
+// LOG_CONFIGMAP environment variable (defaults to "splunk-operator-logging").
+func watchLoggingConfig(clientset kubernetes.Interface, namespace string, logging *operatorLogging) {
+	name := os.Getenv("LOG_CONFIGMAP")
+	if name == "" {
+		name = defaultLogConfigMapName
+	}
+	scopedLog := log.WithValues("configMap", name, "namespace", namespace)
+	configMaps := clientset.CoreV1().ConfigMaps(namespace)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			scopedLog.Info("Reloading logging configuration")
+			if _, err := loadLoggingConfigMap(configMaps, name, logging); err != nil {
+				scopedLog.Error(err, "Unable to reload logging configuration")
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			if err := watchLoggingConfigMap(configMaps, name, logging); err != nil {
+				scopedLog.Error(err, "Unable to watch logging configuration")
+			}
+			time.Sleep(logConfigRetryInterval)
+		}
+	}()
+}
+
+// loadLoggingConfigMap applies a logging ConfigMap, or restores the default logging configuration if it does not exist,
+// and returns the resource version to watch it from
+func loadLoggingConfigMap(configMaps typedcorev1.ConfigMapInterface, name string, logging *operatorLogging) (string, error) {
+	list, err := configMaps.List(metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String()})
+	if err != nil {
+		return "", err
+	}
+	if len(list.Items) == 0 {
+		logging.applyConfigMap(nil)
+	} else {
+		logging.applyConfigMap(&list.Items[0])
+	}
+	return list.ResourceVersion, nil
+}
+
+// watchLoggingConfigMap loads a logging ConfigMap, and applies changes to it until its watch is closed
+func watchLoggingConfigMap(configMaps typedcorev1.ConfigMapInterface, name string, logging *operatorLogging) error {
+	resourceVersion, err := loadLoggingConfigMap(configMaps, name, logging)
+	if err != nil {
+		return err
+	}
+	watcher, err := configMaps.Watch(metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+		ResourceVersion: resourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for event := range watcher.ResultChan() {
+		configMap, ok := event.Object.(*corev1.ConfigMap)
+		if !ok {
+			continue
+		}
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			logging.applyConfigMap(configMap)
+		case watch.Deleted:
+			logging.applyConfigMap(nil)
+		}
+	}
+	return nil
+}
@@ -21,15 +21,17 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
-	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	"github.com/operator-framework/operator-sdk/pkg/metrics"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
@@ -42,6 +44,8 @@ import (
 
 	"github.com/splunk/splunk-operator/pkg/apis"
 	"github.com/splunk/splunk-operator/pkg/controller"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/webhook"
 	"github.com/splunk/splunk-operator/version"
 )
 
@@ -50,7 +54,12 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	webhookPort               = 9443
 )
+
+// defaultLeaderElectionID is the name of the ConfigMap used as a lock when leader election is enabled
+const defaultLeaderElectionID = "splunk-operator-leader"
+
 var log = logf.Log.WithName("cmd")
 
 func printVersion() {
@@ -63,9 +72,10 @@ func printVersion() {
 }
 
 func main() {
-	// Add the zap logger flag set to the CLI. The flag set must
-	// be added before calling pflag.Parse().
-	pflag.CommandLine.AddFlagSet(zap.FlagSet())
+	// "splunk-operator validate" checks custom resources in local manifests, instead of running the operator
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
 
 	// Add flags registered by imported packages (e.g. glog and
 	// controller-runtime)
@@ -73,15 +83,16 @@ func main() {
 
 	pflag.Parse()
 
-	// Use a zap logr.Logger implementation. If none of the zap
-	// flags are configured (or if the zap flag set is not being
-	// used), this defaults to a production zap logger.
-	//
-	// The logger instantiated here can be changed to any logger
-	// implementing the logr.Logger interface. This logger will
-	// be propagated through the whole operator, generating
-	// uniform and structured logs.
-	logf.SetLogger(zap.Logger())
+	// Use a zap logr.Logger implementation, with the level and format given by the LOG_LEVEL and LOG_FORMAT environment
+	// variables (defaults to "info" and "json"). This logger is propagated through the whole operator, and its level and
+	// format can be changed at runtime using the logging ConfigMap.
+	settings, err := getLogSettingsEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logging := newOperatorLogging(settings)
+	logf.SetLogger(logging.Logger())
 
 	printVersion()
 
@@ -98,21 +109,67 @@ func main() {
 		os.Exit(1)
 	}
 
-	ctx := context.TODO()
-	// Become the leader before proceeding
-	err = leader.Become(ctx, "splunk-operator-lock")
+	// Change the level and format of logs at runtime using a ConfigMap in the operator's namespace
+	addLoggingConfigWatch(cfg, logging)
+
+	// Export spans for reconciles to an OpenTelemetry collector, if one has been configured
+	stop := signals.SetupSignalHandler()
+	waitForTracing := addTracing(stop)
+
+	// Enable OpenShift compatibility if SecurityContextConstraints exist, unless explicitly configured
+	detectOpenShift(cfg)
+
+	// Use the client QPS and burst configured for all controllers, if any
+	cfg, err = resources.GetControllerConfig(cfg, "")
 	if err != nil {
-		log.Error(err, "")
+		log.Error(err, "Invalid client configuration")
 		os.Exit(1)
 	}
 
-	log.Info("Creating new manager", "namespace", namespace)
+	ctx := context.TODO()
+	// Become the leader before proceeding, unless leader election is enabled to allow replicas to take over from each other
+	leaderElection := os.Getenv("ENABLE_LEADER_ELECTION") == "true"
+	if !leaderElection {
+		err = leader.Become(ctx, "splunk-operator-lock")
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
 
-	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{
+	// WATCH_NAMESPACE may be a single namespace, a comma-separated list of namespaces, or empty to watch all namespaces
+	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
-	})
+	}
+
+	// RESYNC_INTERVAL may be used to change how often all custom resources are reconciled, even if nothing has changed
+	if options.SyncPeriod, err = getDurationEnv("RESYNC_INTERVAL"); err != nil {
+		log.Error(err, "Invalid resync interval")
+		os.Exit(1)
+	}
+	if leaderElection {
+		if err := setLeaderElectionOptions(&options); err != nil {
+			log.Error(err, "Invalid leader election configuration")
+			os.Exit(1)
+		}
+		log.Info("Enabling leader election", "id", options.LeaderElectionID)
+	}
+	watchNamespaces := getWatchNamespaces(namespace)
+	if len(watchNamespaces) > 1 {
+		options.Namespace = ""
+		options.NewCache = newMultiNamespaceCache(watchNamespaces)
+		log.Info("Creating new manager", "namespaces", watchNamespaces)
+	} else if len(watchNamespaces) == 1 {
+		options.Namespace = watchNamespaces[0]
+		log.Info("Creating new manager", "namespace", options.Namespace)
+	} else {
+		options.Namespace = ""
+		log.Info("Creating new manager for all namespaces")
+	}
+
+	// Create a new Cmd to provide shared dependencies and start components
+	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -132,22 +189,106 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the mutating webhook used to default custom resources (requires serving certificates)
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err := webhook.AddToManager(mgr, webhookPort); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
-	addMetrics(ctx, cfg, namespace)
+	addMetrics(ctx, cfg, watchNamespaces)
 
 	log.Info("Starting the Manager.")
 
 	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stop); err != nil {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
+	waitForTracing()
+}
+
+// setLeaderElectionOptions configures leader election for the manager, using the optional LEADER_ELECTION_ID and
+// LEADER_ELECTION_NAMESPACE environment variables to select the lock, and LEADER_ELECTION_LEASE_DURATION,
+// LEADER_ELECTION_RENEW_DEADLINE and LEADER_ELECTION_RETRY_PERIOD to override the controller-runtime defaults
+func setLeaderElectionOptions(options *manager.Options) error {
+	options.LeaderElection = true
+	options.LeaderElectionID = defaultLeaderElectionID
+	if id := os.Getenv("LEADER_ELECTION_ID"); id != "" {
+		options.LeaderElectionID = id
+	}
+	options.LeaderElectionNamespace = os.Getenv("LEADER_ELECTION_NAMESPACE")
+
+	var err error
+	if options.LeaseDuration, err = getDurationEnv("LEADER_ELECTION_LEASE_DURATION"); err != nil {
+		return err
+	}
+	if options.RenewDeadline, err = getDurationEnv("LEADER_ELECTION_RENEW_DEADLINE"); err != nil {
+		return err
+	}
+	if options.RetryPeriod, err = getDurationEnv("LEADER_ELECTION_RETRY_PERIOD"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getDurationEnv returns the duration (for example, "15s") in an environment variable, or nil if it is not set
+func getDurationEnv(name string) (*time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", name, err)
+	}
+	return &duration, nil
+}
+
+// addLoggingConfigWatch starts watching the logging ConfigMap in the operator's namespace, which is skipped when running
+// locally, outside of a cluster
+func addLoggingConfigWatch(cfg *rest.Config, logging *operatorLogging) {
+	operatorNs, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		log.Info("Skipping logging configuration watch; could not get operator namespace", "error", err.Error())
+		return
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		log.Info("Could not create client to watch logging configuration", "error", err.Error())
+		return
+	}
+	watchLoggingConfig(clientset, operatorNs, logging)
+}
+
+// detectOpenShift enables OpenShift compatibility if the OPENSHIFT_COMPATIBILITY environment variable is not set and the
+// cluster supports SecurityContextConstraints
+func detectOpenShift(cfg *rest.Config) {
+	if _, ok := os.LookupEnv(resources.OpenShiftCompatibilityEnv); ok {
+		return
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		log.Info("Could not create discovery client to detect OpenShift", "error", err.Error())
+		return
+	}
+	exists, err := k8sutil.ResourceExists(dc, "security.openshift.io/v1", "SecurityContextConstraints")
+	if err != nil {
+		log.Info("Could not detect OpenShift", "error", err.Error())
+		return
+	}
+	if exists {
+		log.Info("Detected OpenShift; enabling OpenShift compatibility")
+		os.Setenv(resources.OpenShiftCompatibilityEnv, "true")
+	}
 }
 
 // addMetrics will create the Services and Service Monitors to allow the operator export the metrics by using
 // the Prometheus operator
-func addMetrics(ctx context.Context, cfg *rest.Config, namespace string) {
-	if err := serveCRMetrics(cfg); err != nil {
+func addMetrics(ctx context.Context, cfg *rest.Config, watchNamespaces []string) {
+	if err := serveCRMetrics(cfg, watchNamespaces); err != nil {
 		if errors.Is(err, k8sutil.ErrRunLocal) {
 			log.Info("Skipping CR metrics server creation; not running in a cluster.")
 			return
@@ -168,9 +309,15 @@ func addMetrics(ctx context.Context, cfg *rest.Config, namespace string) {
 	}
 
 	// CreateServiceMonitors will automatically create the prometheus-operator ServiceMonitor resources
-	// necessary to configure Prometheus to scrape metrics from this operator.
+	// necessary to configure Prometheus to scrape metrics from this operator. These are created in the
+	// operator's namespace along with the metrics Service, which may not be one of the watched namespaces.
+	operatorNs, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		log.Info("Could not get operator namespace", "error", err.Error())
+		return
+	}
 	services := []*v1.Service{service}
-	_, err = metrics.CreateServiceMonitors(cfg, namespace, services)
+	_, err = metrics.CreateServiceMonitors(cfg, operatorNs, services)
 	if err != nil {
 		log.Info("Could not create ServiceMonitor object", "error", err.Error())
 		// If this operator is deployed to a cluster without the prometheus-operator running, it will return
@@ -181,22 +328,25 @@ func addMetrics(ctx context.Context, cfg *rest.Config, namespace string) {
 	}
 }
 
-// serveCRMetrics gets the Operator/CustomResource GVKs and generates metrics based on those types.
-// It serves those metrics on "http://metricsHost:operatorMetricsPort".
-func serveCRMetrics(cfg *rest.Config) error {
+// serveCRMetrics gets the Operator/CustomResource GVKs and generates metrics based on those types for each of the
+// watched namespaces (or all namespaces, if none). It serves those metrics on "http://metricsHost:operatorMetricsPort".
+func serveCRMetrics(cfg *rest.Config, watchNamespaces []string) error {
 	// Below function returns filtered operator/CustomResource specific GVKs.
 	// For more control override the below GVK list with your own custom logic.
 	filteredGVK, err := k8sutil.GetGVKsFromAddToScheme(apis.AddToScheme)
 	if err != nil {
 		return err
 	}
-	// Get the namespace the operator is currently deployed in.
-	operatorNs, err := k8sutil.GetOperatorNamespace()
+	// Metrics are not generated when running locally, outside of a cluster.
+	_, err = k8sutil.GetOperatorNamespace()
 	if err != nil {
 		return err
 	}
-	// To generate metrics in other namespaces, add the values below.
-	ns := []string{operatorNs}
+	// An empty namespace is used to generate metrics for all namespaces.
+	ns := watchNamespaces
+	if len(ns) == 0 {
+		ns = []string{""}
+	}
 	// Generate and serve custom resource specific metrics.
 	err = kubemetrics.GenerateAndServeCRMetrics(cfg, ns, filteredGVK, metricsHost, operatorMetricsPort)
 	if err != nil {
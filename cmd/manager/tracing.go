@@ -0,0 +1,62 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
+	"github.com/splunk/splunk-operator/version"
+)
+
+// defaultTracingServiceName is the service name used to identify the operator in exported spans
+const defaultTracingServiceName = "splunk-operator"
+
+// getTracingEndpointEnv returns the URL that spans are exported to, using the OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// environment variable as is, or the OTEL_EXPORTER_OTLP_ENDPOINT environment variable as the base URL of an OTLP/HTTP
+// collector. It returns an empty string if neither is set, and tracing is disabled.
+func getTracingEndpointEnv() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// addTracing enables tracing of reconciles if an OTLP endpoint has been configured, and exports spans until stop is
+// closed. It returns a function that waits for the remaining spans to be exported once stop has been closed.
+func addTracing(stop <-chan struct{}) func() {
+	endpoint := getTracingEndpointEnv()
+	if endpoint == "" {
+		return func() {}
+	}
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = defaultTracingServiceName
+	}
+
+	log.Info("Enabling tracing", "endpoint", endpoint, "serviceName", serviceName)
+	exporter := tracing.NewOTLPExporter(endpoint, serviceName, version.Version)
+	tracing.SetExporter(exporter)
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(stop)
+		close(done)
+	}()
+	return func() { <-done }
+}
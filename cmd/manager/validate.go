@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+
+	"github.com/splunk/splunk-operator/pkg/webhook"
+)
+
+// validate runs the "validate" command, which checks Splunk custom resources in local manifests using the same
+// validation and defaulting as the mutating webhook, so that they can be checked before applying them. It returns
+// 0 if all of them are valid, 1 if any are not, or 2 if the manifests could not be read.
+func validate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var filenames []string
+	var output string
+	flags := pflag.NewFlagSet("validate", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringArrayVarP(&filenames, "filename", "f", nil, "Manifest containing custom resources to validate (\"-\" reads from standard input); may be repeated")
+	flags.StringVarP(&output, "output", "o", "", "Print the defaulted custom resources instead of a summary (\"yaml\" or \"json\")")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: splunk-operator validate -f FILENAME [-o yaml|json]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if len(filenames) == 0 || flags.NArg() != 0 || (output != "" && output != "yaml" && output != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, filename := range filenames {
+		var results []webhook.ValidationResult
+		var err error
+		if filename == "-" {
+			results, err = webhook.ValidateManifest(stdin)
+		} else {
+			var f *os.File
+			if f, err = os.Open(filename); err == nil {
+				results, err = webhook.ValidateManifest(f)
+				f.Close()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+			return 2
+		}
+
+		for _, result := range results {
+			name := fmt.Sprintf("%s/%s", result.Kind, result.Name)
+			if result.Err != nil {
+				fmt.Fprintf(stderr, "%s: %s is invalid: %v\n", filename, name, result.Err)
+				status = 1
+				continue
+			}
+			if output == "" {
+				fmt.Fprintf(stdout, "%s: %s is valid\n", filename, name)
+				continue
+			}
+			if err = printObject(stdout, result.Object, output); err != nil {
+				fmt.Fprintf(stderr, "%s: %s: %v\n", filename, name, err)
+				return 2
+			}
+		}
+	}
+	return status
+}
+
+// printObject prints a defaulted custom resource as a YAML document or JSON object
+func printObject(out io.Writer, obj map[string]interface{}, output string) error {
+	var data []byte
+	var err error
+	if output == "json" {
+		data, err = json.MarshalIndent(obj, "", "    ")
+	} else {
+		fmt.Fprintln(out, "---")
+		data, err = yaml.Marshal(obj)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, strings.TrimSuffix(string(data), "\n"))
+	return err
+}
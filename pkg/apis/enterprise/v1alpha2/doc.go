@@ -1,4 +0,0 @@
-// Package v1alpha2 contains API Schema definitions for the enterprise v1alpha2 API group
-// +k8s:deepcopy-gen=package,register
-// +groupName=enterprise.splunk.com
-package v1alpha2
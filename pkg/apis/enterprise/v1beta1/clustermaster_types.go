@@ -0,0 +1,154 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// ClusterMasterSpec defines the desired state of a Splunk Enterprise cluster master, which may be referenced by one or
+// more IndexerCluster resources using clusterMasterRef.
+type ClusterMasterSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// Names of the sites of a multisite indexer cluster (each must be one of "site1" through "site63"); the cluster master
+	// belongs to the first site. IndexerCluster resources that reference the cluster master may provide peers for any of them.
+	Sites []string `json:"sites"`
+
+	// Number of copies of each bucket kept by the indexer cluster (defaults to the image default)
+	// +kubebuilder:validation:Minimum=0
+	ReplicationFactor int32 `json:"replicationFactor"`
+
+	// Number of searchable copies of each bucket kept by the indexer cluster; must not be greater than the replication factor (defaults to the image default)
+	// +kubebuilder:validation:Minimum=0
+	SearchFactor int32 `json:"searchFactor"`
+
+	// Site replication factor used by the cluster master of a multisite indexer cluster
+	SiteReplicationFactor IndexerClusterSiteFactor `json:"siteReplicationFactor"`
+
+	// Site search factor used by the cluster master of a multisite indexer cluster
+	SiteSearchFactor IndexerClusterSiteFactor `json:"siteSearchFactor"`
+
+	// Indexer discovery configuration, used by forwarders outside of the Kubernetes cluster to find indexer cluster peers
+	IndexerDiscovery IndexerDiscoverySpec `json:"indexerDiscovery"`
+}
+
+// ClusterMasterStatus defines the observed state of a Splunk Enterprise cluster master.
+type ClusterMasterStatus struct {
+	// current phase of the cluster master
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the cluster master most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the cluster master
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the cluster master, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the cluster master, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
+	// Indicates if the cluster is initialized.
+	Initialized bool `json:"initialized_flag"`
+
+	// Indicates if the cluster is ready for indexing.
+	IndexingReady bool `json:"indexing_ready_flag"`
+
+	// Indicates whether the master is ready to begin servicing, based on whether it is initialized.
+	ServiceReady bool `json:"service_ready_flag"`
+
+	// Indicates if the cluster is in maintenance mode.
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// checksum of the SmartStore configuration most recently pushed to indexer cluster peers
+	SmartStoreChecksum string `json:"smartstoreChecksum"`
+
+	// app packages pushed to indexer cluster peers by the cluster master
+	Apps []AppStatus `json:"apps"`
+
+	// app packages found the last time the app repository was checked
+	AppRepo AppRepoStatus `json:"appRepo"`
+
+	// status of the configuration bundle pushed to indexer cluster peers by the cluster master
+	Bundle ClusterBundleStatus `json:"bundle"`
+
+	// external endpoint used by forwarders for indexer discovery, once available
+	IndexerDiscovery IndexerDiscoveryStatus `json:"indexerDiscovery"`
+
+	// container image most recently verified by health checks after it was rolled out
+	VerifiedImage string `json:"verifiedImage"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterMaster is the Schema for a Splunk Enterprise cluster master.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=clustermasters,scope=Namespaced,shortName=cm
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of cluster master"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of cluster master"
+type ClusterMaster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterMasterSpec   `json:"spec,omitempty"`
+	Status ClusterMasterStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *ClusterMaster) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *ClusterMaster) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *ClusterMaster) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterMasterList contains a list of ClusterMaster
+type ClusterMasterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterMaster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterMaster{}, &ClusterMasterList{})
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// ClusterReplicationSpec defines the desired state of a standby indexer cluster that is kept in sync with a primary
+// indexer cluster, so that it can take over if the primary is lost.
+type ClusterReplicationSpec struct {
+	// PrimaryRef refers to the IndexerCluster that is replicated; it defaults to the namespace of the ClusterReplication
+	PrimaryRef corev1.ObjectReference `json:"primaryRef"`
+
+	// StandbyRef refers to the IndexerCluster that is kept in sync with the primary; it defaults to the namespace of the
+	// ClusterReplication, in the Kubernetes cluster reached using standbyKubeconfigSecretRef (if set)
+	StandbyRef corev1.ObjectReference `json:"standbyRef"`
+
+	// Name of a Secret in the same namespace with a kubeconfig value used to reach another Kubernetes cluster where the
+	// standby runs; the standby runs in the same Kubernetes cluster as the primary if empty
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	StandbyKubeconfigSecretRef string `json:"standbyKubeconfigSecretRef"`
+
+	// Interval between syncs of the standby, once it is in sync with the primary (e.g. "10m"; default="5m")
+	SyncInterval string `json:"syncInterval"`
+
+	// Compare the SmartStore bucket manifests (receipt.json objects) in the remote storage volumes of the primary and
+	// standby during each sync, and wait for all of them to be replicated before failing over; this requires the
+	// volumes to use the s3 provider with a secretRef
+	SmartStoreManifests bool `json:"smartstoreManifests"`
+}
+
+// ClusterReplicationStatus defines the observed state of a standby indexer cluster.
+type ClusterReplicationStatus struct {
+	// current phase of the replication
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the replication most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the replication
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the replication, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the replication, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// indexer cluster that is currently active and replicated to the other one, either "primary" or "standby"
+	// (defaults to primary until a failover is completed)
+	Active string `json:"active"`
+
+	// time of the most recent sync of the inactive indexer cluster
+	LastSyncTime metav1.Time `json:"lastSyncTime"`
+
+	// true if the inactive indexer cluster has pushed the same conf files and defaults to its peers as the active one
+	BundleInSync bool `json:"bundleInSync"`
+
+	// true if the inactive indexer cluster has installed the same app packages as the active one
+	AppsInSync bool `json:"appsInSync"`
+
+	// number of SmartStore bucket manifests found in the remote storage volumes of the active indexer cluster
+	Manifests int32 `json:"manifests"`
+
+	// number of SmartStore bucket manifests that have not been replicated to the volumes of the inactive indexer cluster yet
+	PendingManifests int32 `json:"pendingManifests"`
+
+	// true while a failover requested using the failover-at annotation is in progress
+	FailoverInProgress bool `json:"failoverInProgress"`
+
+	// value of the failover-at annotation most recently handled by failing over
+	FailoverRequest string `json:"failoverRequest"`
+
+	// time of the most recent failover
+	LastFailoverTime metav1.Time `json:"lastFailoverTime"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterReplication is the Schema for standby indexer clusters kept in sync with a primary indexer cluster, for disaster recovery.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=clusterreplications,scope=Namespaced,shortName=crep
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of cluster replication"
+// +kubebuilder:printcolumn:name="Primary",type="string",JSONPath=".spec.primaryRef.name",description="Name of the primary indexer cluster"
+// +kubebuilder:printcolumn:name="Standby",type="string",JSONPath=".spec.standbyRef.name",description="Name of the standby indexer cluster"
+// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.active",description="Indexer cluster that is currently active"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of cluster replication"
+type ClusterReplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterReplicationSpec   `json:"spec,omitempty"`
+	Status ClusterReplicationStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *ClusterReplication) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *ClusterReplication) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *ClusterReplication) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterReplicationList contains a list of ClusterReplication
+type ClusterReplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterReplication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterReplication{}, &ClusterReplicationList{})
+}
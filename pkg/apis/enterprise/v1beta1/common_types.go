@@ -0,0 +1,841 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourcePhase is used to represent the current phase of a custom resource
+// +kubebuilder:validation:Enum=Pending;Ready;Updating;ScalingUp;ScalingDown;Terminating;Error
+type ResourcePhase string
+
+const (
+	// PhasePending means a custom resource has just been created and is not yet ready
+	PhasePending ResourcePhase = "Pending"
+
+	// PhaseReady means a custom resource is ready and up to date
+	PhaseReady ResourcePhase = "Ready"
+
+	// PhaseUpdating means a custom resource is in the process of updating to a new desired state (spec)
+	PhaseUpdating ResourcePhase = "Updating"
+
+	// PhaseScalingUp means a customer resource is in the process of scaling up
+	PhaseScalingUp ResourcePhase = "ScalingUp"
+
+	// PhaseScalingDown means a customer resource is in the process of scaling down
+	PhaseScalingDown ResourcePhase = "ScalingDown"
+
+	// PhaseTerminating means a customer resource is in the process of being removed
+	PhaseTerminating ResourcePhase = "Terminating"
+
+	// PhaseError means an error occured with custom resource management
+	PhaseError ResourcePhase = "Error"
+)
+
+// ConditionType is used to represent a type of condition reported in the status of a custom resource
+type ConditionType string
+
+const (
+	// ConditionReady means a custom resource is ready and up to date
+	ConditionReady ConditionType = "Ready"
+
+	// ConditionProgressing means a custom resource is being created, updated, scaled or removed
+	ConditionProgressing ConditionType = "Progressing"
+
+	// ConditionDegraded means an error occured with custom resource management
+	ConditionDegraded ConditionType = "Degraded"
+
+	// ConditionUpgradeInProgress means pods are being recycled to apply a new desired state (spec)
+	ConditionUpgradeInProgress ConditionType = "UpgradeInProgress"
+
+	// ConditionPaused means reconciles of a custom resource have been suspended using its paused annotation
+	ConditionPaused ConditionType = "Paused"
+)
+
+// Condition is used to report one aspect of the current state of a custom resource. It uses the same fields as the
+// standard metav1.Condition (added in Kubernetes 1.19), so that it can be understood by tools such as kstatus.
+type Condition struct {
+	// type of condition
+	Type ConditionType `json:"type"`
+
+	// status of the condition, one of True, False or Unknown
+	Status corev1.ConditionStatus `json:"status"`
+
+	// generation of the custom resource that the condition was set for
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// last time the condition transitioned from one status to another
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	// reason for the condition's last transition, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message with details about the transition
+	Message string `json:"message"`
+}
+
+// DryRunStatus reports the changes that the operator would make to reconcile a custom resource, while dry runs are
+// requested using its enterprise.splunk.com/dry-run annotation
+type DryRunStatus struct {
+	// generation of the custom resource that was dry run
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// last time the custom resource was dry run
+	LastDryRunTime metav1.Time `json:"lastDryRunTime"`
+
+	// changes that would be made to Kubernetes resources and Splunk Enterprise instances, in order
+	Changes []string `json:"changes"`
+
+	// error that stopped the dry run before all changes were found, if any
+	Error string `json:"error"`
+}
+
+// ReconcileErrorStatus reports the most recent error that prevented the operator from reconciling a custom resource, which
+// is cleared once a reconcile succeeds
+type ReconcileErrorStatus struct {
+	// error message
+	Message string `json:"message"`
+
+	// last time a reconcile failed
+	LastErrorTime metav1.Time `json:"lastErrorTime"`
+
+	// number of consecutive reconciles that have failed
+	Count int32 `json:"count"`
+}
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// CommonSpec defines the desired state of parameters that are common across all CRD types
+type CommonSpec struct {
+	// Image to use for Splunk pod containers (overrides RELATED_IMAGE_SPLUNK_ENTERPRISE environment variables)
+	Image string `json:"image"`
+
+	// Sets pull policy for all images (either “Always” or the default: “IfNotPresent”)
+	// +kubebuilder:validation:Enum=Always;IfNotPresent
+	ImagePullPolicy string `json:"imagePullPolicy"`
+
+	// List of Secrets in the same namespace used to pull images from private registries
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets"`
+
+	// Name of Scheduler to use for pod placement (defaults to “default-scheduler”)
+	SchedulerName string `json:"schedulerName"`
+
+	// Name of PriorityClass used to set the priority of pods, so that they may preempt less important workloads and are
+	// less likely to be evicted when nodes are under pressure
+	PriorityClassName string `json:"priorityClassName"`
+
+	// Name of an existing ServiceAccount in the same namespace used by pods, such as one annotated with an IAM role
+	// (eks.amazonaws.com/role-arn) used to access SmartStore remote storage volumes without access keys
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// Kubernetes PodSecurityContext used for pods (runAsUser and fsGroup default to 41812, which runs Splunk Enterprise
+	// and Spark in their images)
+	PodSecurityContext corev1.PodSecurityContext `json:"podSecurityContext"`
+
+	// Kubernetes SecurityContext used for containers created by the operator, such as to set readOnlyRootFilesystem,
+	// allowPrivilegeEscalation or capabilities
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext"`
+
+	// Seccomp profile used by pods, either “runtime/default”, “docker/default”, “unconfined” or “localhost/<path>”
+	SeccompProfile string `json:"seccompProfile"`
+
+	// Kubernetes Affinity rules that control how pods are assigned to particular nodes.
+	Affinity corev1.Affinity `json:"affinity"`
+
+	// Anti-affinity for pods of the same type, either “soft” (the default) to prefer scheduling them on different nodes,
+	// “hard” to require it, or “none”. This is added to any podAntiAffinity rules given in affinity.
+	// +kubebuilder:validation:Enum=soft;hard;none
+	PodAntiAffinity string `json:"podAntiAffinity"`
+
+	// Kubernetes TopologySpreadConstraints that control how pods are spread across failure domains such as zones and nodes
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints"`
+
+	// Spread pods of the same type evenly across zones, as a shorthand for a topology spread constraint using the
+	// topology.kubernetes.io/zone label
+	SpreadAcrossZones bool `json:"spreadAcrossZones"`
+
+	// resource requirements for the pod containers
+	Resources corev1.ResourceRequirements `json:"resources"`
+
+	// ServiceTemplate is a template used to create Kubernetes services. Labels and annotations from its metadata are added
+	// to the services created by the operator (annotations are not added to headless services)
+	ServiceTemplate corev1.Service `json:"serviceTemplate"`
+}
+
+// CommonSplunkSpec defines the desired state of parameters that are common across all Splunk Enterprise CRD types
+type CommonSplunkSpec struct {
+	CommonSpec `json:",inline"`
+
+	// Name of StorageClass to use for persistent volume claims, unless overridden by etcStorage or varStorage
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	StorageClassName string `json:"storageClassName"`
+
+	// Storage for /opt/splunk/etc volumes, either as the capacity to request for persistent volume claims (default=”10Gi”)
+	// or as an object with storageCapacity, storageClassName and ephemeral fields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	EtcStorage StorageSpec `json:"etcStorage"`
+
+	// Storage for /opt/splunk/var volumes, either as the capacity to request for persistent volume claims (default=”100Gi”)
+	// or as an object with storageCapacity, storageClassName and ephemeral fields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	VarStorage StorageSpec `json:"varStorage"`
+
+	// Cleanup policy for persistent volume claims when the resource is deleted, either “Retain” (the default) to keep them,
+	// or “Delete” to delete them once all instances have been decommissioned
+	// +kubebuilder:validation:Enum=Retain;Delete
+	PVCCleanupPolicy string `json:"pvcCleanupPolicy"`
+
+	// List of one or more Kubernetes volumes. These will be mounted in all pod containers as as /mnt/<name>
+	Volumes []corev1.Volume `json:"volumes"`
+
+	// List of additional environment variables set in all Splunk Enterprise containers, such as SPLUNK_* variables used by
+	// splunk-ansible or proxy settings (variables that are set by the operator cannot be overridden)
+	ExtraEnv []corev1.EnvVar `json:"extraEnv"`
+
+	// List of additional containers that run alongside splunkd in all Splunk Enterprise pods, such as log shippers,
+	// auditing agents or service mesh proxies
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	SidecarContainers []corev1.Container `json:"sidecarContainers"`
+
+	// List of Kubernetes volumes added to all Splunk Enterprise pods for use by sidecar containers (these are not
+	// mounted in Splunk Enterprise containers)
+	SidecarVolumes []corev1.Volume `json:"sidecarVolumes"`
+
+	// List of additional init containers that run before splunkd starts in all Splunk Enterprise pods, such as those used
+	// to seed configuration or fix volume permissions
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	InitContainers []corev1.Container `json:"initContainers"`
+
+	// Script from a ConfigMap that is run in an init container before splunkd starts, with the etc and var volumes mounted
+	InitScript InitScriptSpec `json:"initScript"`
+
+	// Inline map of default.yml overrides used to initialize the environment
+	Defaults string `json:"defaults"`
+
+	// Full path or URL for one or more default.yml files, separated by commas
+	DefaultsURL string `json:"defaultsUrl"`
+
+	// Name of a ConfigMap in the same namespace with a default.yml file of overrides used to initialize the environment;
+	// pods are restarted when its contents change
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	DefaultsConfigMapRef string `json:"defaultsConfigMapRef"`
+
+	// Full path or URL for a Splunk Enterprise license file
+	LicenseURL string `json:"licenseUrl"`
+
+	// LicenseMasterRef refers to a Splunk Enterprise license master managed by the operator within Kubernetes
+	LicenseMasterRef corev1.ObjectReference `json:"licenseMasterRef"`
+
+	// Management URL of a Splunk Enterprise license master running outside of the operator, used instead of licenseMasterRef
+	LicenseMasterURL string `json:"licenseMasterURL"`
+
+	// Name of a Secret in the same namespace with the pass4SymmKey of the license master referenced by licenseMasterURL
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	LicenseMasterSecretRef string `json:"licenseMasterSecretRef"`
+
+	// IndexerClusterRef refers to a Splunk Enterprise indexer cluster managed by the operator within Kubernetes
+	IndexerClusterRef corev1.ObjectReference `json:"indexerClusterRef"`
+
+	// ClusterMasterRef refers to a Splunk Enterprise cluster master managed by the operator within Kubernetes; indexer cluster
+	// peers join it instead of creating their own cluster master, and search heads use it instead of indexerClusterRef
+	ClusterMasterRef corev1.ObjectReference `json:"clusterMasterRef"`
+
+	// MonitoringConsoleRef refers to a Splunk Enterprise monitoring console managed by the operator within Kubernetes
+	MonitoringConsoleRef corev1.ObjectReference `json:"monitoringConsoleRef"`
+
+	// SmartStore configuration for remote storage of indexes (used by Standalone and IndexerCluster resources)
+	SmartStore SmartStoreSpec `json:"smartstore"`
+
+	// Repository of Splunk apps stored in S3 buckets (used by Standalone, SearchHeadCluster and IndexerCluster resources)
+	AppRepo AppRepoSpec `json:"appRepo"`
+
+	// Inline stanzas for Splunk .conf files (such as limits.conf or outputs.conf) installed in an app managed by the operator,
+	// on the instances that use them; changes are applied using configUpdateStrategy
+	ConfFiles []ConfFileSpec `json:"confFiles"`
+
+	// Strategy used to apply changes to confFiles, either “restart” (the default) to restart pods, or “reload” to reload
+	// configuration using the REST API where Splunk supports it (used by Standalone, LicenseMaster, MonitoringConsole and
+	// DeploymentServer resources)
+	// +kubebuilder:validation:Enum=restart;reload
+	ConfigUpdateStrategy string `json:"configUpdateStrategy"`
+
+	// Interval between automated rotations of the admin password, HEC token and pass4SymmKey (e.g. "720h");
+	// secrets are only rotated when changed manually if empty
+	SecretRotationInterval string `json:"secretRotationInterval"`
+
+	// Name of a Secret in the same namespace providing values used instead of randomly generated secrets (password,
+	// pass4SymmKey, idxc_secret, shc_secret and hec_token); changes to it are rolled out like manual rotations
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	SecretRef string `json:"secretRef"`
+
+	// External secrets manager providing values used instead of randomly generated secrets (cannot be used with secretRef)
+	SecretsProvider SecretsProviderSpec `json:"secretsProvider"`
+
+	// TLS certificates issued by cert-manager, used by splunkd and Splunk Web instead of self-signed defaults
+	TLS TLSSpec `json:"tls"`
+
+	// Ingress used to expose Splunk Web and HTTP Event Collector endpoints outside the cluster (only created if host is set)
+	Ingress IngressSpec `json:"ingress"`
+
+	// NetworkPolicy restricting traffic to the Splunk ports of instances (only created if enabled)
+	NetworkPolicy NetworkPolicySpec `json:"networkPolicy"`
+
+	// Service mesh that injects sidecars into pods, so that it can be configured to leave Splunk traffic alone
+	ServiceMesh ServiceMeshSpec `json:"serviceMesh"`
+
+	// Velero backup hooks used to quiesce Splunk Enterprise instances while their volumes are backed up
+	Velero VeleroSpec `json:"velero"`
+
+	// Types of the services used to expose Splunk Web, HTTP Event Collector and management ports (all use ClusterIP by
+	// default; not used by universal forwarders)
+	ServiceTypes ServiceTypesSpec `json:"serviceTypes"`
+
+	// Prometheus Operator resources used to scrape metrics from Splunk Enterprise instances
+	Monitoring MonitoringSpec `json:"monitoring"`
+
+	// Liveness probe used to restart Splunk Enterprise containers that are not running (default initialDelaySeconds=300, timeoutSeconds=30, periodSeconds=30)
+	LivenessProbe ProbeSpec `json:"livenessProbe"`
+
+	// Readiness probe used to determine when Splunk Enterprise containers have started (default initialDelaySeconds=10, timeoutSeconds=5, periodSeconds=5)
+	ReadinessProbe ProbeSpec `json:"readinessProbe"`
+
+	// Number of seconds that pods are given to stop gracefully, after a preStop hook takes indexer cluster peers offline or
+	// stops splunkd, before they are killed (default=900 for indexer cluster peers, 300 for other instances)
+	TerminationGracePeriodSeconds int64 `json:"terminationGracePeriodSeconds"`
+
+	// List of fields of the generated StatefulSets that are managed by others and left unchanged by the operator, such as
+	// spec.replicas when scaled by a HorizontalPodAutoscaler (supported fields are spec.replicas,
+	// spec.template.metadata.annotations, spec.template.metadata.labels, spec.template.spec.affinity and
+	// spec.template.spec.containers.resources; not used by universal forwarders)
+	UnmanagedFields []string `json:"unmanagedFields"`
+}
+
+// StorageSpec defines the storage used for a Splunk Enterprise volume. For compatibility with earlier versions, it may also
+// be given as a string with the storage capacity only, and it is encoded that way unless any other fields are set.
+type StorageSpec struct {
+	// Storage capacity to request for persistent volume claims
+	StorageCapacity string `json:"storageCapacity"`
+
+	// Name of StorageClass to use for persistent volume claims (overrides storageClassName of the resource)
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Use emptyDir volumes instead of persistent volume claims. Data is lost whenever a pod is restarted, so this should
+	// only be used for test and development deployments. It cannot be changed after a resource is created.
+	Ephemeral bool `json:"ephemeral"`
+}
+
+// storageSpecFields is used to encode and decode a StorageSpec as an object
+type storageSpecFields StorageSpec
+
+// UnmarshalJSON decodes a StorageSpec from either a string with the storage capacity, or an object
+func (s *StorageSpec) UnmarshalJSON(data []byte) error {
+	var capacity string
+	if err := json.Unmarshal(data, &capacity); err == nil {
+		*s = StorageSpec{StorageCapacity: capacity}
+		return nil
+	}
+	return json.Unmarshal(data, (*storageSpecFields)(s))
+}
+
+// MarshalJSON encodes a StorageSpec as a string with the storage capacity, or as an object if any other fields are set
+func (s StorageSpec) MarshalJSON() ([]byte, error) {
+	if s.StorageClassName == "" && !s.Ephemeral {
+		return json.Marshal(s.StorageCapacity)
+	}
+	return json.Marshal(storageSpecFields(s))
+}
+
+// TLSSpec defines the certificates requested from cert-manager for Splunk Enterprise instances
+type TLSSpec struct {
+	// Reference to a cert-manager Issuer or ClusterIssuer (via name and optionally kind, default="Issuer")
+	IssuerRef corev1.ObjectReference `json:"issuerRef"`
+
+	// Requested lifetime of certificates (e.g. "2160h"); cert-manager's default is used if empty
+	Duration string `json:"duration"`
+
+	// Additional DNS names to include in certificates, such as hostnames used by an ingress
+	DNSNames []string `json:"dnsNames"`
+}
+
+// IngressSpec defines a Kubernetes Ingress used to reach the Splunk Web and HTTP Event Collector endpoints of an instance
+type IngressSpec struct {
+	// Host name used to reach the endpoints; an ingress is only created if this is set
+	Host string `json:"host"`
+
+	// Name of a Kubernetes Secret with the TLS certificate used by the ingress for host; TLS is not terminated by the ingress if empty
+	TLSSecretName string `json:"tlsSecretName"`
+
+	// Class of ingress controller used to implement the ingress (set using the kubernetes.io/ingress.class annotation)
+	IngressClass string `json:"ingressClass"`
+
+	// Path used to reach Splunk Web on port 8000 (default="/")
+	WebPath string `json:"webPath"`
+
+	// Path used to reach the HTTP Event Collector on port 8088 (default="/services/collector"); only used by instances that receive HEC data
+	HECPath string `json:"hecPath"`
+
+	// Additional annotations added to the ingress, such as those used to configure the ingress controller
+	Annotations map[string]string `json:"annotations"`
+}
+
+// NetworkPolicySpec defines a Kubernetes NetworkPolicy that only allows other instances managed by the operator, the operator
+// itself, and any additional clients that are given, to reach the Splunk ports of an instance
+type NetworkPolicySpec struct {
+	// Create a NetworkPolicy for the instances (defaults to false)
+	Enabled bool `json:"enabled"`
+
+	// Additional CIDR blocks allowed to reach the instances, such as those of forwarders or load balancers outside the cluster
+	AllowedCIDRs []string `json:"allowedCidrs"`
+
+	// Names of additional namespaces whose pods are allowed to reach the instances, such as those of ingress controllers or
+	// Prometheus (these are matched using the kubernetes.io/metadata.name label of namespaces)
+	AllowedNamespaces []string `json:"allowedNamespaces"`
+}
+
+// ServiceMeshSpec defines the service mesh used to inject sidecar proxies into the pods of an instance
+type ServiceMeshSpec struct {
+	// Service mesh to inject sidecars for, either “istio” or “linkerd” (service mesh compatibility is disabled by default)
+	// +kubebuilder:validation:Enum=istio;linkerd
+	Type string `json:"type"`
+}
+
+// VeleroSpec defines the hooks that Velero runs in the pods of an instance while it backs up their volumes, so that
+// backups capture a consistent state of Splunk Enterprise
+type VeleroSpec struct {
+	// Hook used to quiesce pods during backups, either “stop” to stop splunkd, or “fsfreeze” to freeze the etc and var
+	// filesystems from a privileged sidecar (backup hooks are disabled by default)
+	// +kubebuilder:validation:Enum=stop;fsfreeze
+	Hook string `json:"hook"`
+
+	// Maximum time that Velero waits for each hook to complete, as a duration such as “2m” (default=“10m”)
+	Timeout string `json:"timeout"`
+
+	// Image used for the sidecar that runs fsfreeze hooks, which must provide /sbin/fsfreeze (default=“ubuntu:20.04”)
+	FsfreezeImage string `json:"fsfreezeImage"`
+}
+
+// ServiceTypesSpec defines the types of the Kubernetes Services used to expose groups of Splunk ports. A separate service is
+// created for each group that does not use ClusterIP, in addition to the regular service used for all ports.
+type ServiceTypesSpec struct {
+	// Type of the service for Splunk Web (port 8000), either “ClusterIP” (the default), “NodePort” or “LoadBalancer”
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Web corev1.ServiceType `json:"web"`
+
+	// Type of the service for the HTTP Event Collector (port 8088), either “ClusterIP” (the default), “NodePort” or “LoadBalancer”
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	HEC corev1.ServiceType `json:"hec"`
+
+	// Type of the service for the splunkd management port (8089), either “ClusterIP” (the default), “NodePort” or “LoadBalancer”
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Management corev1.ServiceType `json:"management"`
+
+	// Annotations added to the services, for example to configure cloud load balancers
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ServiceStatus is used to report the external endpoint of a Kubernetes Service that exposes a group of Splunk ports.
+type ServiceStatus struct {
+	// Name of the service
+	Name string `json:"name"`
+
+	// Type of the service
+	Type corev1.ServiceType `json:"type"`
+
+	// Port exposed by the service
+	Port int32 `json:"port"`
+
+	// Node port allocated to the service, for NodePort and LoadBalancer services
+	NodePort int32 `json:"nodePort"`
+
+	// Hostname or IP address assigned to the load balancer of a LoadBalancer service, once available
+	Address string `json:"address"`
+}
+
+// MonitoringSpec defines the Prometheus Operator ServiceMonitors created to scrape metrics from Splunk Enterprise instances
+type MonitoringSpec struct {
+	// Create a ServiceMonitor for each type of instance; this is ignored if the Prometheus Operator CRDs are not installed
+	ServiceMonitor bool `json:"serviceMonitor"`
+
+	// Name of the service port that is scraped (default="splunkd")
+	Port string `json:"port"`
+
+	// HTTP path that is scraped (default="/metrics")
+	Path string `json:"path"`
+
+	// Interval between scrapes (e.g. "30s"); the Prometheus default is used if empty
+	Interval string `json:"interval"`
+
+	// Additional labels added to ServiceMonitors, such as those matched by the serviceMonitorSelector of a Prometheus resource
+	Labels map[string]string `json:"labels"`
+}
+
+// ProbeSpec defines the parameters of a probe used to check Splunk Enterprise containers; defaults are used for any that are not set
+type ProbeSpec struct {
+	// Number of seconds after a container has started before the probe is first run
+	InitialDelaySeconds int32 `json:"initialDelaySeconds"`
+
+	// Number of seconds after which the probe times out
+	TimeoutSeconds int32 `json:"timeoutSeconds"`
+
+	// Number of seconds between runs of the probe
+	PeriodSeconds int32 `json:"periodSeconds"`
+
+	// Number of consecutive failures before the probe is considered to have failed (Kubernetes default=3)
+	FailureThreshold int32 `json:"failureThreshold"`
+
+	// Check the splunkd health endpoint (/services/server/health/splunkd), which fails if the health of splunkd is red,
+	// instead of only checking that splunkd is running (liveness) or that the container has finished starting (readiness)
+	UseHealthEndpoint bool `json:"useHealthEndpoint"`
+}
+
+// InitScriptSpec defines a script from a ConfigMap that is run in an init container before splunkd starts
+type InitScriptSpec struct {
+	// Name of a ConfigMap in the same namespace containing the script (the script is only run if this is set)
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	ConfigMapRef string `json:"configMapRef"`
+
+	// Key of the script in the ConfigMap (default="init.sh")
+	Key string `json:"key"`
+
+	// Container image used to run the script with /bin/sh (defaults to the Splunk Enterprise image)
+	Image string `json:"image"`
+}
+
+// SecretsProviderSpec defines an external secrets manager that provides secret values used by Splunk Enterprise instances
+type SecretsProviderSpec struct {
+	// Type of secrets manager, either "vault" (HashiCorp Vault key/value version 2) or "aws" (AWS Secrets Manager);
+	// no secrets manager is used if empty
+	Type string `json:"type"`
+
+	// Endpoint used to access the secrets manager (e.g. "https://vault.example.com:8200"); required for Vault, and
+	// defaults to the regional endpoint for AWS Secrets Manager
+	Endpoint string `json:"endpoint"`
+
+	// Region of the secret, used to sign AWS Secrets Manager requests (default="us-east-1")
+	Region string `json:"region"`
+
+	// Vault secret path including its mount path (e.g. "secret/splunk/example"), or the name or ARN of an AWS Secrets
+	// Manager secret; it may contain password, pass4SymmKey, idxc_secret, shc_secret and hec_token values
+	Path string `json:"path"`
+
+	// Vault role used to log in with the operator's Kubernetes service account, if a token is not provided
+	Role string `json:"role"`
+
+	// Name of a Kubernetes Secret with credentials used to access the secrets manager, either a vault_token value,
+	// or aws_access_key and aws_secret_key values
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	CredentialsSecretRef string `json:"credentialsSecretRef"`
+
+	// Interval between checks for a new version of the secret (default="5m")
+	SyncInterval string `json:"syncInterval"`
+}
+
+// ConfFileSpec defines the inline contents of a Splunk .conf file that the operator installs in an app it manages
+type ConfFileSpec struct {
+	// Name of the .conf file, such as limits.conf or outputs.conf
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_.-]+\.conf$`
+	Name string `json:"name"`
+
+	// Contents of the file, containing one or more stanzas
+	Content string `json:"content"`
+}
+
+// AppRepoSpec defines a repository of Splunk app packages (.tgz, .tar.gz or .spl files) stored in S3 compatible buckets
+// or Azure Blob Storage containers
+type AppRepoSpec struct {
+	// Storage provider: "s3" (default) for S3 compatible buckets, or "azure" for Azure Blob Storage containers
+	Provider string `json:"provider"`
+
+	// Endpoint used to access the buckets (default="https://s3.amazonaws.com", or "https://<storageAccount>.blob.core.windows.net" for azure)
+	Endpoint string `json:"endpoint"`
+
+	// Region of the buckets, used to sign requests (default="us-east-1"); only used by the s3 provider
+	Region string `json:"region"`
+
+	// Name of the Azure storage account containing the containers; only used by the azure provider
+	StorageAccount string `json:"storageAccount"`
+
+	// Name of a Kubernetes Secret used to access the buckets, with s3_access_key and s3_secret_key values for the s3 provider,
+	// or an azure_sas_token value for the azure provider; if empty, s3 buckets must allow anonymous read access, and azure
+	// containers are accessed using the managed identity of the operator
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	SecretRef string `json:"secretRef"`
+
+	// Name of a Kubernetes Secret with a ca.crt value containing the CA certificates (PEM) used to verify the TLS certificate
+	// of the endpoint, for S3 compatible object stores using a private CA (e.g. MinIO); only used by the s3 provider
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	CASecretRef string `json:"caSecretRef"`
+
+	// Style of URLs used to access the buckets: "path" (default, https://endpoint/bucket) or "virtual" (virtual-hosted style,
+	// https://bucket.endpoint); only used by the s3 provider
+	URLStyle string `json:"urlStyle"`
+
+	// List of bucket locations containing app packages
+	AppSources []AppSourceSpec `json:"appSources"`
+
+	// Interval between checks for new versions of app packages (e.g. "1h"; default="5m"; at most "24h"); app packages are
+	// also checked whenever the custom resource changes
+	PollInterval string `json:"pollInterval"`
+}
+
+// AppSourceSpec defines a bucket location containing Splunk app packages
+type AppSourceSpec struct {
+	// Name of the app source
+	Name string `json:"name"`
+
+	// Name of the bucket (or Azure Blob Storage container)
+	Bucket string `json:"bucket"`
+
+	// Only app packages with object names beginning with this prefix will be installed (e.g. security/)
+	Prefix string `json:"prefix"`
+
+	// Type of premium app contained in the app source, either "enterpriseSecurity" (Splunk Enterprise Security) or "itsi"
+	// (Splunk IT Service Intelligence), so that the steps required after installing it are run, and probe timings and
+	// default resources are adjusted for it; empty for other apps
+	AppType string `json:"appType"`
+}
+
+// AppStatus is used to track the version of a Splunk app package installed from an app repository
+type AppStatus struct {
+	// Name of the app source containing the app package
+	Source string `json:"source"`
+
+	// Object name of the app package within its bucket
+	Key string `json:"key"`
+
+	// Version of the app package (the object's entity tag)
+	Version string `json:"version"`
+}
+
+// AppRepoStatus is used to track the app packages found the last time an app repository was checked
+type AppRepoStatus struct {
+	// Time when the app sources were last listed
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+
+	// Generation of the custom resource when the app sources were last listed
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// App packages found in the app sources, which may not have been installed yet
+	Apps []AppStatus `json:"apps"`
+}
+
+// PremiumAppStatus is used to track the steps run after installing a premium app package, such as essinstall for Splunk
+// Enterprise Security
+type PremiumAppStatus struct {
+	// Type of the premium app
+	AppType string `json:"appType"`
+
+	// Name of the app source containing the app package
+	Source string `json:"source"`
+
+	// Object name of the app package within its bucket
+	Key string `json:"key"`
+
+	// Version of the app package
+	Version string `json:"version"`
+
+	// IDs of the search jobs running the post-install steps, for each instance they are run on
+	SearchIDs []string `json:"searchIds"`
+
+	// Phase of the post-install steps: "Pending" while they are running, "Ready" once they have completed, or "Error" if they failed
+	Phase ResourcePhase `json:"phase"`
+}
+
+// ScalingScheduleEntry defines a number of replicas that a cluster is scaled to at scheduled times
+type ScalingScheduleEntry struct {
+	// Times when the cluster is scaled, in cron format ("minute hour day-of-month month day-of-week"), for example
+	// "0 8 * * 1-5" to scale at 8:00 every weekday
+	Schedule string `json:"schedule"`
+
+	// Number of replicas the cluster is scaled to at the scheduled times
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// Name of the time zone used for the schedule, for example "America/New_York" (defaults to UTC)
+	TimeZone string `json:"timeZone"`
+}
+
+// ScalingScheduleStatus is used to report the number of replicas set by the most recent scheduled scaling
+type ScalingScheduleStatus struct {
+	// schedule of the entry that most recently scaled the cluster
+	Schedule string `json:"schedule"`
+
+	// number of replicas set by the most recent scheduled scaling
+	Replicas int32 `json:"replicas"`
+
+	// time when the cluster was most recently scaled by its schedule
+	LastScheduleTime metav1.Time `json:"lastScheduleTime"`
+
+	// time when the cluster will next be scaled by its schedule
+	NextScheduleTime metav1.Time `json:"nextScheduleTime"`
+}
+
+// LoadMetricsSpec defines the Splunk load metrics that are collected by the operator, so that they can be used to scale
+// clusters using a HorizontalPodAutoscaler
+type LoadMetricsSpec struct {
+	// Collect load metrics from each instance and export them as operator metrics (defaults to false)
+	Enabled bool `json:"enabled"`
+}
+
+// LoadMetricsStatus defines the most recent Splunk load metrics collected by the operator
+type LoadMetricsStatus struct {
+	// total number of searches running on all search heads
+	SearchConcurrency int32 `json:"searchConcurrency"`
+
+	// average percentage of the index queue that is filled on all indexers
+	IndexingQueueFillPercent int32 `json:"indexingQueueFillPercent"`
+
+	// number of instances that load metrics were collected from
+	Instances int32 `json:"instances"`
+}
+
+// SmartStoreSpec defines the remote storage volumes and indexes used by Splunk SmartStore.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/AboutSmartStore
+type SmartStoreSpec struct {
+	// List of remote storage volumes
+	VolList []SmartStoreVolumeSpec `json:"volumes"`
+
+	// List of indexes stored using remote storage volumes
+	IndexList []SmartStoreIndexSpec `json:"indexes"`
+
+	// Default settings used for all indexes
+	Defaults SmartStoreDefaultsSpec `json:"defaults"`
+
+	// Settings for the SmartStore cache manager
+	CacheManagerConf SmartStoreCacheManagerSpec `json:"cacheManager"`
+}
+
+// SmartStoreVolumeSpec defines a remote storage volume used by SmartStore
+type SmartStoreVolumeSpec struct {
+	// Name of the remote storage volume
+	Name string `json:"name"`
+
+	// Remote storage provider: "s3" (default) for Amazon S3 and compatible object stores, "gcs" for Google Cloud Storage,
+	// or "azure" for Azure Blob Storage
+	Provider string `json:"provider"`
+
+	// Remote storage endpoint (e.g. https://s3-us-west-2.amazonaws.com, or https://myaccount.blob.core.windows.net for azure);
+	// not used by the gcs provider
+	Endpoint string `json:"endpoint"`
+
+	// Remote storage path, including the bucket (or Azure container) name (e.g. my-bucket/smartstore)
+	Path string `json:"path"`
+
+	// Name of a Kubernetes Secret used to access the volume, with s3_access_key and s3_secret_key values for the s3 provider,
+	// a gcs_credentials value containing a service account key (JSON) for the gcs provider, or azure_access_key (storage
+	// account name) and azure_secret_key (storage account key) values for the azure provider; if empty, Splunk will use the
+	// IAM role, workload identity or managed identity of the node, or of the pod's serviceAccountName
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	SecretRef string `json:"secretRef"`
+
+	// Name of a Kubernetes Secret with a ca.crt value containing the CA certificates (PEM) used to verify the TLS certificate
+	// of the endpoint, for S3 compatible object stores using a private CA (e.g. MinIO); only used by the s3 provider
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	CASecretRef string `json:"caSecretRef"`
+
+	// Style of URLs used to access the bucket: "path" (default, https://endpoint/bucket) or "virtual" (virtual-hosted style,
+	// https://bucket.endpoint); only used by the s3 provider
+	URLStyle string `json:"urlStyle"`
+}
+
+// SmartStoreIndexSpec defines a Splunk index stored using SmartStore
+type SmartStoreIndexSpec struct {
+	// Name of the index
+	Name string `json:"name"`
+
+	// Path relative to the remote storage volume to use for the index (defaults to the index name)
+	RemotePath string `json:"remotePath"`
+
+	// Name of the remote storage volume used by the index (defaults to defaults.volumeName)
+	VolName string `json:"volumeName"`
+
+	// Maximum total size of the index's warm and cold buckets, in MB (0 means no limit)
+	MaxGlobalDataSizeMB uint `json:"maxGlobalDataSizeMB"`
+
+	// Maximum total size of the index's raw data, in MB (0 means no limit)
+	MaxGlobalRawDataSizeMB uint `json:"maxGlobalRawDataSizeMB"`
+}
+
+// SmartStoreDefaultsSpec defines default settings used for all SmartStore indexes
+type SmartStoreDefaultsSpec struct {
+	// Name of the remote storage volume used by default for all indexes
+	VolName string `json:"volumeName"`
+
+	// Default maximum total size of warm and cold buckets for each index, in MB (0 means no limit)
+	MaxGlobalDataSizeMB uint `json:"maxGlobalDataSizeMB"`
+
+	// Default maximum total size of raw data for each index, in MB (0 means no limit)
+	MaxGlobalRawDataSizeMB uint `json:"maxGlobalRawDataSizeMB"`
+}
+
+// SmartStoreCacheManagerSpec defines the settings of the SmartStore cache manager (server.conf [cachemanager] stanza)
+type SmartStoreCacheManagerSpec struct {
+	// Eviction policy used to remove buckets from the local cache (e.g. lru)
+	EvictionPolicy string `json:"evictionPolicy"`
+
+	// Maximum space used by the local cache, in MB
+	MaxCacheSizeMB uint `json:"maxCacheSize"`
+
+	// Additional free space to maintain on the local cache volume, in MB
+	EvictionPaddingSizeMB uint `json:"evictionPadding"`
+
+	// Maximum number of buckets that can be downloaded from remote storage in parallel
+	MaxConcurrentDownloads uint `json:"maxConcurrentDownloads"`
+
+	// Maximum number of buckets that can be uploaded to remote storage in parallel
+	MaxConcurrentUploads uint `json:"maxConcurrentUploads"`
+
+	// Time, in seconds, during which recently created buckets are protected from eviction
+	HotlistRecencySecs uint `json:"hotlistRecencySecs"`
+
+	// Time, in hours, during which bloom filters of recently created buckets are protected from eviction
+	HotlistBloomFilterRecencyHours uint `json:"hotlistBloomFilterRecencyHours"`
+}
+
+// MetaObject is used to represent common interfaces of custom resources
+type MetaObject interface {
+	GetIdentifier() string
+	GetNamespace() string
+	GetTypeMeta() metav1.TypeMeta
+	GetObjectMeta() metav1.Object
+	GetObjectKind() schema.ObjectKind
+	DeepCopyObject() runtime.Object
+}
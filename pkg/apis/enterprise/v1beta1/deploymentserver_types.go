@@ -0,0 +1,160 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// DeploymentServerSpec defines the desired state of a Splunk Enterprise deployment server, which distributes apps to
+// forwarders and other deployment clients.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Updating/Aboutdeploymentserver
+type DeploymentServerSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// Name of a ConfigMap containing a serverclass.conf file that defines the server classes of the deployment server
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	ServerClassConfigMapRef string `json:"serverClassConfigMapRef"`
+
+	// Deployment apps with configuration files provided by ConfigMaps; app packages may also be installed as deployment
+	// apps using appRepo
+	DeploymentApps []DeploymentAppSpec `json:"deploymentApps"`
+
+	// External service used by deployment clients outside of the Kubernetes cluster to reach the deployment server
+	ExternalService ExternalServiceSpec `json:"externalService"`
+}
+
+// DeploymentAppSpec defines an app whose configuration files are provided by a ConfigMap, and which is distributed by a
+// deployment server or search head cluster deployer
+type DeploymentAppSpec struct {
+	// Name of the app in etc/deployment-apps (for deployment servers) or etc/shcluster/apps (for deployers)
+	Name string `json:"name"`
+
+	// Name of a ConfigMap containing the files installed in the app's local directory (e.g. inputs.conf, outputs.conf)
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	ConfigMapRef string `json:"configMapRef"`
+}
+
+// ExternalServiceSpec defines an external Kubernetes Service used to reach the management port of a Splunk Enterprise
+// instance from outside of the Kubernetes cluster
+type ExternalServiceSpec struct {
+	// Create the external service
+	Enabled bool `json:"enabled"`
+
+	// Type of the external service, either “LoadBalancer” (the default) or “NodePort”
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	ServiceType corev1.ServiceType `json:"serviceType"`
+
+	// Annotations added to the external service, for example to configure cloud load balancers
+	Annotations map[string]string `json:"annotations"`
+}
+
+// DeploymentServerStatus defines the observed state of a Splunk Enterprise deployment server.
+type DeploymentServerStatus struct {
+	// current phase of the deployment server
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// address and port used as targetUri by deployment clients within the Kubernetes cluster
+	TargetURI string `json:"targetUri"`
+
+	// address and port used as targetUri by deployment clients outside of the Kubernetes cluster, once the external
+	// service has one
+	ExternalTargetURI string `json:"externalTargetUri"`
+
+	// app packages installed as deployment apps from the app repository
+	Apps []AppStatus `json:"apps"`
+
+	// app packages found the last time the app repository was checked
+	AppRepo AppRepoStatus `json:"appRepo"`
+
+	// generation of the deployment server most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the deployment server
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the deployment server, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the deployment server, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
+	// checksum of the conf files most recently reloaded on all instances, when configUpdateStrategy is reload
+	ConfFilesChecksum string `json:"confFilesChecksum"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DeploymentServer is the Schema for a Splunk Enterprise deployment server.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=deploymentservers,scope=Namespaced,shortName=ds
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of deployment server"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".status.targetUri",description="Target URI used by deployment clients"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of deployment server"
+type DeploymentServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DeploymentServerSpec   `json:"spec,omitempty"`
+	Status DeploymentServerStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *DeploymentServer) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *DeploymentServer) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *DeploymentServer) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DeploymentServerList contains a list of DeploymentServer
+type DeploymentServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DeploymentServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DeploymentServer{}, &DeploymentServerList{})
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// HecTokenSpec defines the desired state of an HTTP Event Collector (HEC) token configured on Splunk Enterprise instances.
+type HecTokenSpec struct {
+	// TargetRef refers to the Splunk Enterprise custom resource that receives events using the token, by kind (Standalone
+	// or IndexerCluster) and name; it must be in the same namespace as the token
+	TargetRef corev1.ObjectReference `json:"targetRef"`
+
+	// Name of the token in Splunk (defaults to the name of the HecToken)
+	TokenName string `json:"tokenName"`
+
+	// Default index for events sent using the token
+	Index string `json:"index"`
+
+	// Default sourcetype for events sent using the token
+	Sourcetype string `json:"sourcetype"`
+
+	// Indexes that events sent using the token are allowed to use (defaults to all indexes); this must include index, if set
+	Indexes []string `json:"indexes"`
+
+	// Interval between automated rotations of the token value (e.g. "720h"); the token is only rotated when its
+	// Secret is changed manually if empty
+	RotationInterval string `json:"rotationInterval"`
+}
+
+// HecTokenStatus defines the observed state of an HTTP Event Collector (HEC) token.
+type HecTokenStatus struct {
+	// current phase of the token
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the token most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the token
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the token, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the token, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// name of the Kubernetes Secret containing the token value and HEC URL, for use by applications
+	SecretName string `json:"secretName"`
+
+	// name of the token most recently configured in Splunk, which is removed if the token is renamed or deleted
+	TokenName string `json:"tokenName"`
+
+	// number of Splunk Enterprise instances on which the token is configured
+	Instances int32 `json:"instances"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HecToken is the Schema for HTTP Event Collector (HEC) tokens configured by the operator on Splunk Enterprise instances.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=hectokens,scope=Namespaced,shortName=hec
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of HEC token"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetRef.name",description="Name of the custom resource that receives events"
+// +kubebuilder:printcolumn:name="Secret",type="string",JSONPath=".status.secretName",description="Name of the Secret containing the token"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of HEC token"
+type HecToken struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HecTokenSpec   `json:"spec,omitempty"`
+	Status HecTokenStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *HecToken) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *HecToken) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *HecToken) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HecTokenList contains a list of HecToken
+type HecTokenList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HecToken `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HecToken{}, &HecTokenList{})
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// IndexerClusterSpec defines the desired state of a Splunk Enterprise indexer cluster
+type IndexerClusterSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// Number of search head pods; a search head cluster will be created if > 1
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// List of sites used to create a multisite indexer cluster; when defined, one StatefulSet of indexers
+	// will be created for each site, and Replicas will be set to the total number of peers across all sites
+	Sites []IndexerClusterSiteSpec `json:"sites"`
+
+	// Number of copies of each bucket kept by the indexer cluster; must not be greater than the number of peers (defaults to the image default)
+	// +kubebuilder:validation:Minimum=0
+	ReplicationFactor int32 `json:"replicationFactor"`
+
+	// Number of searchable copies of each bucket kept by the indexer cluster; must not be greater than the replication factor (defaults to the image default)
+	// +kubebuilder:validation:Minimum=0
+	SearchFactor int32 `json:"searchFactor"`
+
+	// Site replication factor used by the cluster master of a multisite indexer cluster
+	SiteReplicationFactor IndexerClusterSiteFactor `json:"siteReplicationFactor"`
+
+	// Site search factor used by the cluster master of a multisite indexer cluster
+	SiteSearchFactor IndexerClusterSiteFactor `json:"siteSearchFactor"`
+
+	// Maximum number of indexer peers (of each site, for a multisite indexer cluster) that may be unavailable
+	// during voluntary disruptions such as node drains; this should be less than the replication factor (defaults to 1)
+	MaxUnavailable int32 `json:"maxUnavailable"`
+
+	// Put the cluster master into maintenance mode while indexer peers are restarted for updates, to avoid
+	// unnecessary bucket fixup activity, and take it out of maintenance mode once all peers have been updated
+	MaintenanceMode bool `json:"maintenanceMode"`
+
+	// Sets how indexer cluster peers are restarted when a rolling restart is requested using the restarted-at annotation,
+	// for example to load configuration changes: "restart" (the default) recycles peer pods one at a time, and "searchable"
+	// requests a searchable rolling restart from the cluster master, which keeps searches running while peers restart
+	// +kubebuilder:validation:Enum=restart;searchable
+	RollingRestartMode string `json:"rollingRestartMode"`
+
+	// Disable the removal of excess bucket copies from the remaining indexer peers after peers are decommissioned to
+	// scale down, which otherwise reclaims the storage used by copies beyond the replication and search factors
+	DisableExcessBucketCleanup bool `json:"disableExcessBucketCleanup"`
+
+	// Indexer discovery configuration, used by forwarders outside of the Kubernetes cluster to find indexer cluster peers
+	IndexerDiscovery IndexerDiscoverySpec `json:"indexerDiscovery"`
+
+	// External services created for each indexer cluster peer, used by forwarders outside of the Kubernetes cluster to send
+	// data to specific peers
+	PodServices IndexerPodServicesSpec `json:"podServices"`
+
+	// List of scheduled times when the number of indexer peers is scaled, replacing replicas with the number of replicas
+	// of the entry scheduled most recently (not supported for multisite indexer clusters)
+	ScalingSchedule []ScalingScheduleEntry `json:"scalingSchedule"`
+
+	// Splunk load metrics collected from indexer peers, which can be used to scale them using a HorizontalPodAutoscaler
+	LoadMetrics LoadMetricsSpec `json:"loadMetrics"`
+
+	// Handling of indexer cluster peers that are stuck in automatic detention
+	Detention IndexerClusterDetentionSpec `json:"detention"`
+
+	// Cluster master running outside of the operator, such as on premises, that indexer cluster peers join instead of
+	// creating their own cluster master (cannot be used with clusterMasterRef)
+	ExternalClusterMaster ExternalClusterMasterSpec `json:"externalClusterMaster"`
+}
+
+// IndexerDiscoverySpec defines the configuration used to expose a cluster master and indexer cluster peers to forwarders
+// outside of the Kubernetes cluster using indexer discovery.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/indexerdiscovery
+type IndexerDiscoverySpec struct {
+	// Enable indexer discovery on the cluster master, and create external services used by forwarders to reach it
+	Enabled bool `json:"enabled"`
+
+	// Type of the external services, either “LoadBalancer” (the default) or “NodePort”
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	ServiceType corev1.ServiceType `json:"serviceType"`
+
+	// Annotations added to the external services, for example to configure cloud load balancers
+	Annotations map[string]string `json:"annotations"`
+
+	// Rate (from 1 to 10) that adjusts how often forwarders poll the cluster master for the list of peers (defaults to 10)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	PollingRate int32 `json:"pollingRate"`
+
+	// Weight the load balancing of data across peers by their total disk capacity
+	IndexerWeightByDiskCapacity bool `json:"indexerWeightByDiskCapacity"`
+}
+
+// IndexerDiscoveryStatus is used to report the external endpoints used by forwarders for indexer discovery.
+type IndexerDiscoveryStatus struct {
+	// management URI of the cluster master, used as master_uri by forwarders
+	MasterURI string `json:"masterUri"`
+
+	// addresses and ports used by forwarders to send data to indexer cluster peers
+	Endpoints []string `json:"endpoints"`
+}
+
+// IndexerPodServicesSpec defines the external Kubernetes Services created for each indexer cluster peer, which give every
+// peer a stable endpoint that forwarders outside of the Kubernetes cluster can send data to.
+type IndexerPodServicesSpec struct {
+	// Create an external service for each indexer cluster peer
+	Enabled bool `json:"enabled"`
+
+	// Type of the external services, either “LoadBalancer” (the default) or “NodePort”
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	ServiceType corev1.ServiceType `json:"serviceType"`
+
+	// Annotations added to the external services, for example to configure cloud load balancers
+	Annotations map[string]string `json:"annotations"`
+}
+
+// IndexerPodServiceStatus is used to report the external endpoint of an indexer cluster peer.
+type IndexerPodServiceStatus struct {
+	// Name of the indexer cluster peer
+	Name string `json:"name"`
+
+	// Name of the external service of the peer
+	Service string `json:"service"`
+
+	// address and port used by forwarders to send data to the peer, once available
+	Endpoint string `json:"endpoint"`
+}
+
+// IndexerClusterSiteSpec defines the desired state of a single site within a multisite indexer cluster
+type IndexerClusterSiteSpec struct {
+	// Name of the site (must be one of "site1" through "site63")
+	// +kubebuilder:validation:Pattern=^site([1-9]|[1-5][0-9]|6[0-3])$
+	Name string `json:"name"`
+
+	// Number of indexer peers for this site (defaults to 1)
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+}
+
+// IndexerClusterSiteFactor is used to represent a site replication or search factor for a multisite indexer cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Sitereplicationfactor
+type IndexerClusterSiteFactor struct {
+	// Minimum number of copies to keep on the site that originates the data
+	Origin int32 `json:"origin"`
+
+	// Total number of copies to keep across all sites
+	Total int32 `json:"total"`
+}
+
+// ClusterBundleStatus is used to track the configuration bundle that a cluster master pushes to indexer cluster peers.
+type ClusterBundleStatus struct {
+	// checksum of the default.yml in the defaults ConfigMap most recently pushed to indexer cluster peers
+	DefaultsChecksum string `json:"defaultsChecksum"`
+
+	// checksum of the conf files most recently pushed to indexer cluster peers
+	ConfFilesChecksum string `json:"confFilesChecksum"`
+
+	// checksum of the configuration bundle that is active on indexer cluster peers
+	ActiveChecksum string `json:"activeChecksum"`
+
+	// checksum of the latest configuration bundle in master-apps on the cluster master
+	LatestChecksum string `json:"latestChecksum"`
+
+	// true while the latest configuration bundle is being pushed to indexer cluster peers
+	PushInProgress bool `json:"pushInProgress"`
+
+	// value of the bundle-push annotation most recently handled by pushing the configuration bundle
+	PushRequest string `json:"pushRequest"`
+}
+
+// IndexerClusterDetentionSpec defines how the operator remediates indexer cluster peers that the cluster master has put into
+// automatic detention, for example because they are running out of disk space. Peers in manual detention are only reported,
+// since manual detention is requested deliberately.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Peerdetention
+type IndexerClusterDetentionSpec struct {
+	// Remediation attempted for peers that remain in automatic detention: "None" (the default) only reports them, "Restart"
+	// restarts Splunk on each detained peer, and "Rebalance" requests a data rebalance from the cluster master
+	// +kubebuilder:validation:Enum=None;Restart;Rebalance
+	Remediation string `json:"remediation"`
+
+	// Number of seconds that a peer must remain in automatic detention before remediation is attempted, and between
+	// attempts (defaults to 900)
+	// +kubebuilder:validation:Minimum=0
+	RemediationDelaySeconds int32 `json:"remediationDelaySeconds"`
+}
+
+// ExternalClusterMasterSpec defines a Splunk Enterprise cluster master that is not managed by the operator, which indexer
+// cluster peers join using its management URL
+type ExternalClusterMasterSpec struct {
+	// Management URL of the cluster master, such as "https://cm.example.com:8089" (the port must be 8089 if given)
+	URL string `json:"url"`
+
+	// Name of a Secret in the same namespace with the password of the cluster master's admin user ("password"), used to
+	// manage peers using its REST API, and the secret that peers use to join the indexer cluster ("idxc_secret")
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	SecretRef string `json:"secretRef"`
+}
+
+// IndexerClusterPeerDetentionStatus is used to track the detention of an indexer cluster peer
+type IndexerClusterPeerDetentionStatus struct {
+	// detention of the peer: "automatic", "manual", or empty if it is not in detention
+	Mode string `json:"mode"`
+
+	// time when the operator first found the peer in its current detention mode
+	StartTime metav1.Time `json:"startTime,omitempty"`
+
+	// time of the most recent remediation attempted while the peer has been in automatic detention
+	RemediationTime metav1.Time `json:"remediationTime,omitempty"`
+
+	// number of remediations attempted while the peer has been in automatic detention
+	Remediations int32 `json:"remediations"`
+}
+
+// IndexerClusterMemberStatus is used to track the status of each indexer cluster peer.
+type IndexerClusterMemberStatus struct {
+	// Unique identifier or GUID for the peer
+	ID string `json:"guid"`
+
+	// Name of the indexer cluster peer
+	Name string `json:"name"`
+
+	// Status of the indexer cluster peer
+	Status string `json:"status"`
+
+	// The ID of the configuration bundle currently being used by the master.
+	ActiveBundleID string `json:"active_bundle_id"`
+
+	// Count of the number of buckets on this peer, across all indexes.
+	BucketCount int64 `json:"bucket_count"`
+
+	// Flag indicating if this peer belongs to the current committed generation and is searchable.
+	Searchable bool `json:"is_searchable"`
+
+	// detention of the peer, as reported by the cluster master
+	Detention IndexerClusterPeerDetentionStatus `json:"detention"`
+}
+
+// IndexerClusterStatus defines the observed state of a Splunk Enterprise indexer cluster
+type IndexerClusterStatus struct {
+	// current phase of the indexer cluster
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the indexer cluster most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the indexer cluster
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the indexer cluster, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the indexer cluster, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
+	// current phase of the cluster master
+	ClusterMasterPhase ResourcePhase `json:"clusterMasterPhase"`
+
+	// desired number of indexer peers
+	Replicas int32 `json:"replicas"`
+
+	// current number of ready indexer peers
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// selector for pods, used by HorizontalPodAutoscaler
+	Selector string `json:"selector"`
+
+	// Indicates if the cluster is initialized.
+	Initialized bool `json:"initialized_flag"`
+
+	// Indicates if the cluster is ready for indexing.
+	IndexingReady bool `json:"indexing_ready_flag"`
+
+	// Indicates whether the master is ready to begin servicing, based on whether it is initialized.
+	ServiceReady bool `json:"service_ready_flag"`
+
+	// Indicates if the cluster is in maintenance mode.
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// true if the operator has put the cluster master into maintenance mode while indexer peers are updated
+	OperatorMaintenanceMode bool `json:"operatorMaintenanceMode"`
+
+	// true while the cluster master is restarting indexer cluster peers
+	RollingRestartInProgress bool `json:"rollingRestartInProgress"`
+
+	// value of the restarted-at annotation most recently handled by restarting indexer cluster peers
+	RollingRestartRequest string `json:"rollingRestartRequest"`
+
+	// true after indexer peers have been decommissioned to scale down, until excess bucket copies have been removed from
+	// the remaining peers
+	ExcessBucketCleanupPending bool `json:"excessBucketCleanupPending"`
+
+	// status of each indexer cluster peer
+	Peers []IndexerClusterMemberStatus `json:"peers"`
+
+	// status of each site in a multisite indexer cluster
+	Sites []IndexerClusterSiteStatus `json:"sites"`
+
+	// checksum of the SmartStore configuration most recently pushed to indexer cluster peers
+	SmartStoreChecksum string `json:"smartstoreChecksum"`
+
+	// app packages pushed to indexer cluster peers by the cluster master
+	Apps []AppStatus `json:"apps"`
+
+	// app packages found the last time the app repository was checked
+	AppRepo AppRepoStatus `json:"appRepo"`
+
+	// status of the configuration bundle pushed to indexer cluster peers by the cluster master
+	Bundle ClusterBundleStatus `json:"bundle"`
+
+	// external endpoints used by forwarders for indexer discovery, once available
+	IndexerDiscovery IndexerDiscoveryStatus `json:"indexerDiscovery"`
+
+	// external endpoints of each indexer cluster peer, if external services are enabled for them
+	PodServices []IndexerPodServiceStatus `json:"podServices"`
+
+	// container image most recently verified by health checks after it was rolled out
+	VerifiedImage string `json:"verifiedImage"`
+
+	// number of indexer peers set by the scaling schedule, if configured
+	ScalingSchedule ScalingScheduleStatus `json:"scalingSchedule"`
+
+	// load metrics most recently collected from indexer peers, if enabled
+	LoadMetrics LoadMetricsStatus `json:"loadMetrics"`
+}
+
+// IndexerClusterSiteStatus is used to track the status of each site in a multisite indexer cluster.
+type IndexerClusterSiteStatus struct {
+	// Name of the site
+	Name string `json:"name"`
+
+	// current phase of the indexer peers for this site
+	Phase ResourcePhase `json:"phase"`
+
+	// desired number of indexer peers for this site
+	Replicas int32 `json:"replicas"`
+
+	// current number of ready indexer peers for this site
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// status of each indexer cluster peer for this site
+	Peers []IndexerClusterMemberStatus `json:"peers"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IndexerCluster is the Schema for a Splunk Enterprise indexer cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:resource:path=indexerclusters,scope=Namespaced,shortName=idc;idxc
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of indexer cluster"
+// +kubebuilder:printcolumn:name="Master",type="string",JSONPath=".status.clusterMasterPhase",description="Status of cluster master"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.replicas",description="Desired number of indexer peers"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Current number of ready indexer peers"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of indexer cluster"
+type IndexerCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IndexerClusterSpec   `json:"spec,omitempty"`
+	Status IndexerClusterStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *IndexerCluster) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *IndexerCluster) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *IndexerCluster) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IndexerClusterList contains a list of IndexerCluster
+type IndexerClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IndexerCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IndexerCluster{}, &IndexerClusterList{})
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// LicenseMasterSpec defines the desired state of a Splunk Enterprise license master.
+type LicenseMasterSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// License pools to configure on the license master
+	LicensePools []LicensePoolSpec `json:"licensePools"`
+
+	// Reporting of the usage of all license stacks and pools on the license master
+	LicenseUsage LicenseUsageSpec `json:"licenseUsage"`
+}
+
+// LicensePoolSpec defines a license pool, which allocates indexing volume from a license stack to license slaves.
+type LicensePoolSpec struct {
+	// Name of the license pool
+	Name string `json:"name"`
+
+	// Description of the license pool
+	Description string `json:"description"`
+
+	// Identifier of the license stack that the pool draws from (defaults to "enterprise")
+	StackID string `json:"stackId"`
+
+	// Daily indexing volume allocated to the pool: either "MAX" to use all of the volume available
+	// in the stack (default), or a number of bytes with an optional MB or GB suffix (e.g. "500MB")
+	Quota string `json:"quota"`
+
+	// GUIDs of the license slaves assigned to the pool, or "*" for all slaves (default)
+	Slaves []string `json:"slaves"`
+}
+
+// LicensePoolStatus defines the observed usage of a license pool.
+type LicensePoolStatus struct {
+	// Name of the license pool
+	Name string `json:"name"`
+
+	// Identifier of the license stack that the pool draws from
+	StackID string `json:"stackId"`
+
+	// Daily indexing volume allowed for the pool, in bytes
+	Quota int64 `json:"quota"`
+
+	// Indexing volume used by the pool today, in bytes
+	UsedBytes int64 `json:"usedBytes"`
+}
+
+// LicenseUsageSpec defines the license usage reporting performed by the operator, so that quota exhaustion can be
+// alerted on using operator metrics
+type LicenseUsageSpec struct {
+	// Poll the license master for the usage of all license stacks and pools, and export it as operator metrics (defaults to false)
+	Enabled bool `json:"enabled"`
+}
+
+// LicenseStackStatus defines the observed usage of a license stack.
+type LicenseStackStatus struct {
+	// Identifier of the license stack
+	Name string `json:"name"`
+
+	// Type of licenses in the stack
+	Type string `json:"type"`
+
+	// Daily indexing volume allowed by all licenses in the stack, in bytes
+	Quota int64 `json:"quota"`
+
+	// Indexing volume used by all pools in the stack today, in bytes
+	UsedBytes int64 `json:"usedBytes"`
+}
+
+// LicenseUsageStatus defines the most recent license usage reported by the license master
+type LicenseUsageStatus struct {
+	// usage of each license stack
+	Stacks []LicenseStackStatus `json:"stacks"`
+
+	// usage of each license pool, including pools that are not configured by the operator
+	Pools []LicensePoolStatus `json:"pools"`
+
+	// time when license usage was most recently polled
+	LastUpdated metav1.Time `json:"lastUpdated"`
+}
+
+// LicenseMasterStatus defines the observed state of a Splunk Enterprise license master.
+type LicenseMasterStatus struct {
+	// current phase of the license master
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the license master most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the license master
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the license master, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the license master, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
+	// usage of the license pools configured on the license master
+	LicensePools []LicensePoolStatus `json:"licensePools"`
+
+	// usage of all license stacks and pools on the license master, when license usage reporting is enabled
+	LicenseUsage LicenseUsageStatus `json:"licenseUsage"`
+
+	// checksum of the conf files most recently reloaded on all instances, when configUpdateStrategy is reload
+	ConfFilesChecksum string `json:"confFilesChecksum"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LicenseMaster is the Schema for a Splunk Enterprise license master.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=licensemasters,scope=Namespaced,shortName=lm
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of license master"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of license master"
+type LicenseMaster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LicenseMasterSpec   `json:"spec,omitempty"`
+	Status LicenseMasterStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *LicenseMaster) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *LicenseMaster) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *LicenseMaster) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LicenseMasterList contains a list of LicenseMaster
+type LicenseMasterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LicenseMaster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LicenseMaster{}, &LicenseMasterList{})
+}
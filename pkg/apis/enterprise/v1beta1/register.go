@@ -1,9 +1,9 @@
 // NOTE: Boilerplate only.  Ignore this file.
 
-// Package v1alpha2 contains API Schema definitions for the enterprise v1alpha2 API group
+// Package v1beta1 contains API Schema definitions for the enterprise v1beta1 API group
 // +k8s:deepcopy-gen=package,register
 // +groupName=enterprise.splunk.com
-package v1alpha2
+package v1beta1
 
 import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -12,7 +12,11 @@ import (
 
 var (
 	// SchemeGroupVersion is group version used to register these objects
-	SchemeGroupVersion = schema.GroupVersion{Group: "enterprise.splunk.com", Version: "v1alpha2"}
+	SchemeGroupVersion = schema.GroupVersion{Group: "enterprise.splunk.com", Version: "v1beta1"}
+
+	// ServedVersions are the versions of the enterprise API group served by the CRDs. They all share the schema of these
+	// types, so custom resources are converted between them by only changing their apiVersion.
+	ServedVersions = []string{"v1beta1", "v1alpha2"}
 
 	// SchemeBuilder is used to add go types to the GroupVersionKind scheme
 	SchemeBuilder = &scheme.Builder{GroupVersion: SchemeGroupVersion}
@@ -0,0 +1,332 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// SearchHeadClusterSpec defines the desired state of a Splunk Enterprise search head cluster
+type SearchHeadClusterSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// Number of search head pods; a search head cluster will be created if > 1
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// SparkRef refers to a Spark cluster managed by the operator within Kubernetes
+	// When defined, Data Fabric Search (DFS) will be enabled and configured to use the Spark cluster.
+	SparkRef corev1.ObjectReference `json:"sparkRef"`
+
+	// URL of an externally managed Spark master ("spark://host:port") that Data Fabric Search (DFS) will use instead of
+	// a Spark cluster referenced by sparkRef (the port defaults to 7077)
+	SparkMasterURL string `json:"sparkMasterUrl"`
+
+	// Port of the Spark master web UI used with sparkMasterUrl (defaults to 8080)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	SparkMasterWebUIPort int32 `json:"sparkMasterWebUiPort"`
+
+	// Image to use for Spark pod containers (overrides RELATED_IMAGE_SPLUNK_SPARK environment variables)
+	SparkImage string `json:"sparkImage"`
+
+	// Maximum number of search heads that may be unavailable during voluntary disruptions such as node drains (defaults to 1)
+	MaxUnavailable int32 `json:"maxUnavailable"`
+
+	// Apps with configuration files provided by ConfigMaps, which are staged on the deployer and pushed to search head
+	// cluster members; app packages may also be pushed using appRepo
+	DeployerApps []DeploymentAppSpec `json:"deployerApps"`
+
+	// Strategy used to upgrade search head cluster members to a new image: "RollingUpdate" (default) recycles members one at a
+	// time, and "BlueGreen" creates a replacement search head cluster using the new image, and switches searches over to it
+	// once it is ready
+	UpgradeStrategy string `json:"upgradeStrategy"`
+
+	// List of scheduled times when the number of search heads is scaled, replacing replicas with the number of replicas
+	// of the entry scheduled most recently
+	ScalingSchedule []ScalingScheduleEntry `json:"scalingSchedule"`
+
+	// Splunk load metrics collected from search heads, which can be used to scale them using a HorizontalPodAutoscaler
+	LoadMetrics LoadMetricsSpec `json:"loadMetrics"`
+
+	// Backup of the KV store taken before search head cluster members are upgraded to a new image
+	KVStoreBackup SearchHeadClusterKVStoreBackupSpec `json:"kvStoreBackup"`
+}
+
+// SearchHeadClusterKVStoreBackupSpec defines the KV store backup taken before search head cluster members are upgraded to a
+// new image, so that KV store data can be recovered if an upgrade fails
+type SearchHeadClusterKVStoreBackupSpec struct {
+	// Back up the KV store before upgrading search head cluster members to a new image (defaults to false)
+	Enabled bool `json:"enabled"`
+
+	// Where backups are stored: "volume" (default) creates a KV store backup archive on the var volume of the first member,
+	// and "s3" uploads the documents of every KV store collection to an S3 compatible bucket
+	// +kubebuilder:validation:Enum=volume;s3
+	Target string `json:"target"`
+
+	// Bucket location used to store backups; only used by the s3 target
+	S3 KVStoreBackupS3Spec `json:"s3"`
+}
+
+// KVStoreBackupS3Spec defines an S3 compatible bucket location used to store KV store backups
+type KVStoreBackupS3Spec struct {
+	// Endpoint used to access the bucket (default="https://s3.amazonaws.com")
+	Endpoint string `json:"endpoint"`
+
+	// Region of the bucket, used to sign requests (default="us-east-1")
+	Region string `json:"region"`
+
+	// Name of the bucket
+	Bucket string `json:"bucket"`
+
+	// Prefix added to the object names of backups (e.g. kvstore/)
+	Prefix string `json:"prefix"`
+
+	// Name of a Kubernetes Secret with s3_access_key and s3_secret_key values used to access the bucket
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	SecretRef string `json:"secretRef"`
+}
+
+// SearchHeadClusterMemberStatus is used to track the status of each search head cluster member
+type SearchHeadClusterMemberStatus struct {
+	// Name of the search head cluster member
+	Name string `json:"name"`
+
+	// Indicates the status of the member.
+	Status string `json:"status"`
+
+	// Flag that indicates if this member can run scheduled searches.
+	Adhoc bool `json:"adhoc_searchhead"`
+
+	// Indicates if this member is registered with the searchhead cluster captain.
+	Registered bool `json:"is_registered"`
+
+	// Number of currently running historical searches.
+	ActiveHistoricalSearchCount int `json:"active_historical_search_count"`
+
+	// Number of currently running realtime searches.
+	ActiveRealtimeSearchCount int `json:"active_realtime_search_count"`
+
+	// Versions of the apps staged on the deployer that are installed on this member, by app name
+	Apps map[string]string `json:"apps"`
+
+	// State of the KV store on this member
+	KVStore SearchHeadClusterMemberKVStoreStatus `json:"kvStore"`
+}
+
+// SearchHeadClusterMemberKVStoreStatus defines the observed state of the KV store on a search head cluster member
+type SearchHeadClusterMemberKVStoreStatus struct {
+	// Status of the KV store, such as "starting", "ready" or "failed"
+	Status string `json:"status"`
+
+	// Replication status of the KV store, such as "KV store captain", "Non-captain KV store member" or "Initial sync"
+	ReplicationStatus string `json:"replicationStatus"`
+
+	// Time when the status or replication status of the KV store last changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// SearchHeadClusterBundlePushStatus is used to track pushes of the configuration bundle from the deployer to search
+// head cluster members
+type SearchHeadClusterBundlePushStatus struct {
+	// checksum of the deployer apps included in the most recent successful push
+	Checksum string `json:"checksum"`
+
+	// checksum of the conf files included in the most recent successful push
+	ConfFilesChecksum string `json:"confFilesChecksum"`
+
+	// time of the most recent successful push
+	LastSuccessTime metav1.Time `json:"lastSuccessTime"`
+
+	// value of the bundle-push annotation most recently handled by pushing the configuration bundle
+	PushRequest string `json:"pushRequest"`
+}
+
+// SearchHeadClusterKVStoreBackupStatus is used to track the KV store backup taken before the most recent upgrade of search
+// head cluster members
+type SearchHeadClusterKVStoreBackupStatus struct {
+	// image that search head cluster members are upgraded to once the backup has completed
+	Image string `json:"image"`
+
+	// name of the backup
+	Name string `json:"name"`
+
+	// location of the backup: the path of the archive on the var volume of a member, or the URL of the bucket prefix
+	// containing the documents of each KV store collection
+	Location string `json:"location"`
+
+	// time when the backup was started
+	StartTime metav1.Time `json:"startTime"`
+
+	// true once the backup has completed
+	Completed bool `json:"completed"`
+}
+
+// SearchHeadClusterBlueGreenStatus is used to track blue/green upgrades, which replace search head cluster members instead of
+// recycling them
+type SearchHeadClusterBlueGreenStatus struct {
+	// color of the search head cluster members currently serving searches ("blue" or "green")
+	ActiveColor string `json:"activeColor"`
+
+	// image used by the replacement search head cluster, or empty if no replacement is in progress
+	Image string `json:"image"`
+
+	// current phase of the replacement search head cluster, if a replacement is in progress
+	Phase ResourcePhase `json:"phase,omitempty"`
+
+	// time of the most recent switch of searches over to a replacement search head cluster
+	LastSwitchTime metav1.Time `json:"lastSwitchTime"`
+}
+
+// SearchHeadClusterStatus defines the observed state of a Splunk Enterprise search head cluster
+type SearchHeadClusterStatus struct {
+	// current phase of the search head cluster
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the search head cluster most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the search head cluster
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the search head cluster, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the search head cluster, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
+	// current phase of the deployer
+	DeployerPhase ResourcePhase `json:"deployerPhase"`
+
+	// desired number of search head cluster members
+	Replicas int32 `json:"replicas"`
+
+	// current number of ready search head cluster members
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// selector for pods, used by HorizontalPodAutoscaler
+	Selector string `json:"selector"`
+
+	// name or label of the search head captain
+	Captain string `json:"captain"`
+
+	// true if the search head cluster's captain is ready to service requests
+	CaptainReady bool `json:"captainReady"`
+
+	// true if the search head cluster has finished initialization
+	Initialized bool `json:"initialized"`
+
+	// true if the minimum number of search head cluster members have joined
+	MinPeersJoined bool `json:"minPeersJoined"`
+
+	// true if the search head cluster is in maintenance mode
+	MaintenanceMode bool `json:"maintenanceMode"`
+
+	// status of each search head cluster member
+	Members []SearchHeadClusterMemberStatus `json:"members"`
+
+	// app packages pushed to search head cluster members by the deployer
+	Apps []AppStatus `json:"apps"`
+
+	// app packages found the last time the app repository was checked
+	AppRepo AppRepoStatus `json:"appRepo"`
+
+	// status of the most recent successful push of deployer apps to search head cluster members
+	BundlePush SearchHeadClusterBundlePushStatus `json:"bundlePush"`
+
+	// post-install steps run on the deployer for premium app packages pushed to search head cluster members
+	PremiumApps []PremiumAppStatus `json:"premiumApps"`
+
+	// container image most recently verified by health checks after it was rolled out
+	VerifiedImage string `json:"verifiedImage"`
+
+	// status of blue/green upgrades, if the BlueGreen upgrade strategy is used
+	BlueGreen SearchHeadClusterBlueGreenStatus `json:"blueGreen"`
+
+	// number of search head cluster members set by the scaling schedule, if configured
+	ScalingSchedule ScalingScheduleStatus `json:"scalingSchedule"`
+
+	// load metrics most recently collected from search heads, if enabled
+	LoadMetrics LoadMetricsStatus `json:"loadMetrics"`
+
+	// KV store backup taken before the most recent upgrade, if enabled
+	KVStoreBackup SearchHeadClusterKVStoreBackupStatus `json:"kvStoreBackup"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SearchHeadCluster is the Schema for a Splunk Enterprise search head cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:resource:path=searchheadclusters,scope=Namespaced,shortName=shc
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of search head cluster"
+// +kubebuilder:printcolumn:name="Deployer",type="string",JSONPath=".status.deployerPhase",description="Status of the deployer"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.replicas",description="Desired number of search head cluster members"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Current number of ready search head cluster members"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of search head cluster"
+type SearchHeadCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SearchHeadClusterSpec   `json:"spec,omitempty"`
+	Status SearchHeadClusterStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *SearchHeadCluster) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *SearchHeadCluster) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *SearchHeadCluster) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SearchHeadClusterList contains a list of SearcHead
+type SearchHeadClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SearchHeadCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SearchHeadCluster{}, &SearchHeadClusterList{})
+}
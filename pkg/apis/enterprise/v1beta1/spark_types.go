@@ -12,9 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package v1alpha2
+package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,7 +32,33 @@ type SparkSpec struct {
 	CommonSpec `json:",inline"`
 
 	// Number of spark worker pods
+	// +kubebuilder:validation:Minimum=0
 	Replicas int32 `json:"replicas"`
+
+	// Minimum number of spark worker pods when autoscaling is enabled (defaults to 1)
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas int32 `json:"minReplicas"`
+
+	// Maximum number of spark worker pods; when set, the number of workers is scaled between minReplicas and
+	// maxReplicas based on the number of active DFS searches, instead of using replicas
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// Number of active DFS searches each spark worker pod should handle when autoscaling is enabled (defaults to 1)
+	// +kubebuilder:validation:Minimum=0
+	SearchesPerWorker int32 `json:"searchesPerWorker"`
+
+	// resource requirements for the spark master pod; any requests and limits not given are taken from resources
+	MasterResources corev1.ResourceRequirements `json:"masterResources"`
+
+	// resource requirements for the spark worker pods; any requests and limits not given are taken from resources
+	WorkerResources corev1.ResourceRequirements `json:"workerResources"`
+
+	// JVM options for the spark master pod, such as "-Xms1g -Xmx1g" (passed using SPARK_DAEMON_JAVA_OPTS)
+	MasterJavaOpts string `json:"masterJavaOpts"`
+
+	// JVM options for the spark worker pods, such as "-XX:+UseG1GC" (passed using SPARK_DAEMON_JAVA_OPTS)
+	WorkerJavaOpts string `json:"workerJavaOpts"`
 }
 
 // SparkStatus defines the observed state of a Spark cluster
@@ -39,6 +66,24 @@ type SparkStatus struct {
 	// current phase of the spark workers
 	Phase ResourcePhase `json:"phase"`
 
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the spark workers most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the spark workers
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the spark workers, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the spark workers, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
 	// current phase of the spark master
 	MasterPhase ResourcePhase `json:"masterPhase"`
 
@@ -50,6 +95,12 @@ type SparkStatus struct {
 
 	// selector for pods, used by HorizontalPodAutoscaler
 	Selector string `json:"selector"`
+
+	// number of active DFS searches on search heads that use this spark cluster, when autoscaling is enabled
+	ActiveSearches int32 `json:"activeSearches"`
+
+	// time when the number of spark workers was last changed by autoscaling
+	LastScaleTime metav1.Time `json:"lastScaleTime"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,158 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// SplunkBackupSpec defines the desired state of a backup of the persistent volumes used by a Splunk Enterprise custom resource.
+type SplunkBackupSpec struct {
+	// TargetRef refers to the Splunk Enterprise custom resource to back up, by kind (Standalone, LicenseMaster,
+	// MonitoringConsole, SearchHeadCluster or IndexerCluster) and name; it must be in the same namespace as the backup
+	TargetRef corev1.ObjectReference `json:"targetRef"`
+
+	// Name of the VolumeSnapshotClass used to take snapshots (defaults to the cluster's default VolumeSnapshotClass)
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName"`
+
+	// Interval between scheduled backups (e.g. "24h"); a single backup is taken on demand if empty
+	Schedule string `json:"schedule"`
+
+	// Number of backups to keep; older backups and their snapshots are deleted (defaults to 7)
+	Retain int32 `json:"retain"`
+}
+
+// BackupStatus is used to track the VolumeSnapshots taken for a single backup
+type BackupStatus struct {
+	// Name of the backup, which is also used as a prefix for the names of its VolumeSnapshots
+	Name string `json:"name"`
+
+	// Time when the backup was started
+	StartTime metav1.Time `json:"startTime"`
+
+	// True if splunkd was quiesced for the backup, and has not yet been resumed
+	Quiesced bool `json:"quiesced"`
+
+	// True if all of the backup's VolumeSnapshots are ready to be used to restore volumes
+	ReadyToUse bool `json:"readyToUse"`
+
+	// Error that caused the backup to fail, if any
+	Error string `json:"error"`
+
+	// Status of each VolumeSnapshot taken for the backup
+	Snapshots []VolumeSnapshotStatus `json:"snapshots"`
+}
+
+// VolumeSnapshotStatus is used to track a VolumeSnapshot of a persistent volume claim
+type VolumeSnapshotStatus struct {
+	// Name of the VolumeSnapshot
+	Name string `json:"name"`
+
+	// Name of the persistent volume claim used as the snapshot's source
+	PersistentVolumeClaimName string `json:"persistentVolumeClaimName"`
+
+	// Name of the VolumeSnapshotContent bound to the VolumeSnapshot
+	ContentName string `json:"contentName"`
+
+	// Handle used by the CSI driver to identify the snapshot on the storage system
+	SnapshotHandle string `json:"snapshotHandle"`
+
+	// True once the storage system has taken the point-in-time snapshot
+	Created bool `json:"created"`
+
+	// True once the snapshot is ready to be used to restore a volume
+	ReadyToUse bool `json:"readyToUse"`
+}
+
+// SplunkBackupStatus defines the observed state of a backup of a Splunk Enterprise custom resource.
+type SplunkBackupStatus struct {
+	// current phase of the backup
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the backup most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the backup
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the backup, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the backup, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// backups that have been taken or are in progress, from oldest to newest
+	Backups []BackupStatus `json:"backups"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkBackup is the Schema for backups of Splunk Enterprise persistent volumes, using CSI VolumeSnapshots.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkbackups,scope=Namespaced,shortName=sb
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of backup"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetRef.name",description="Name of the custom resource backed up"
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule",description="Interval between scheduled backups"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of backup"
+type SplunkBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkBackupSpec   `json:"spec,omitempty"`
+	Status SplunkBackupStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *SplunkBackup) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *SplunkBackup) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *SplunkBackup) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkBackupList contains a list of SplunkBackup
+type SplunkBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkBackup{}, &SplunkBackupList{})
+}
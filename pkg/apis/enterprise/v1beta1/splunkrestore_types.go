@@ -0,0 +1,136 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// SplunkRestoreSpec defines the desired state of a restore of a Splunk Enterprise custom resource from a backup.
+type SplunkRestoreSpec struct {
+	// BackupRef refers to the SplunkBackup to restore from, by name; it must be in the same namespace as the restore
+	BackupRef corev1.ObjectReference `json:"backupRef"`
+
+	// Name of the backup to restore, from the SplunkBackup's status (defaults to the latest backup that is ready to use)
+	BackupName string `json:"backupName"`
+
+	// TargetRef refers to the Splunk Enterprise custom resource to restore, by kind (Standalone or IndexerCluster)
+	// and name; its kind must match the custom resource that was backed up
+	TargetRef corev1.ObjectReference `json:"targetRef"`
+
+	// Name of StorageClass to use for restored persistent volume claims (defaults to the cluster's default StorageClass)
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*)?$`
+	StorageClassName string `json:"storageClassName"`
+}
+
+// RestoredVolumeStatus is used to track a persistent volume claim restored from a VolumeSnapshot
+type RestoredVolumeStatus struct {
+	// Name of the restored persistent volume claim
+	PersistentVolumeClaimName string `json:"persistentVolumeClaimName"`
+
+	// Name of the VolumeSnapshot used as the persistent volume claim's data source
+	VolumeSnapshotName string `json:"volumeSnapshotName"`
+}
+
+// SplunkRestoreStatus defines the observed state of a restore of a Splunk Enterprise custom resource.
+type SplunkRestoreStatus struct {
+	// current phase of the restore
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the restore most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the restore
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the restore, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the restore, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// name of the backup that was restored
+	BackupName string `json:"backupName"`
+
+	// true once the target's persistent volume claims and secrets have been restored
+	VolumesRestored bool `json:"volumesRestored"`
+
+	// persistent volume claims restored from the backup
+	Volumes []RestoredVolumeStatus `json:"volumes"`
+
+	// labels of stale indexer cluster peers that were removed from the cluster master after restoring
+	RemovedPeers []string `json:"removedPeers"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkRestore is the Schema for restores of Splunk Enterprise custom resources from a SplunkBackup.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkrestores,scope=Namespaced,shortName=sr
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of restore"
+// +kubebuilder:printcolumn:name="Backup",type="string",JSONPath=".status.backupName",description="Name of the backup restored"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetRef.name",description="Name of the custom resource restored"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of restore"
+type SplunkRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkRestoreSpec   `json:"spec,omitempty"`
+	Status SplunkRestoreStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *SplunkRestore) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *SplunkRestore) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *SplunkRestore) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkRestoreList contains a list of SplunkRestore
+type SplunkRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkRestore{}, &SplunkRestoreList{})
+}
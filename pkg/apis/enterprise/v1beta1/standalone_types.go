@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package v1alpha2
+package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
@@ -32,14 +32,47 @@ type StandaloneSpec struct {
 	CommonSplunkSpec `json:",inline"`
 
 	// Number of standalone pods
+	// +kubebuilder:validation:Minimum=0
 	Replicas int32 `json:"replicas"`
 
 	// SparkRef refers to a Spark cluster managed by the operator within Kubernetes
 	// When defined, Data Fabric Search (DFS) will be enabled and configured to use the Spark cluster.
 	SparkRef corev1.ObjectReference `json:"sparkRef"`
 
+	// URL of an externally managed Spark master ("spark://host:port") that Data Fabric Search (DFS) will use instead of
+	// a Spark cluster referenced by sparkRef (the port defaults to 7077)
+	SparkMasterURL string `json:"sparkMasterUrl"`
+
+	// Port of the Spark master web UI used with sparkMasterUrl (defaults to 8080)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	SparkMasterWebUIPort int32 `json:"sparkMasterWebUiPort"`
+
 	// Image to use for Spark pod containers (overrides RELATED_IMAGE_SPLUNK_SPARK environment variables)
 	SparkImage string `json:"sparkImage"`
+
+	// Migration of the standalone instances into an indexer cluster
+	Migration StandaloneMigrationSpec `json:"migration"`
+}
+
+// StandaloneMigrationSpec defines an orchestrated migration of standalone instances into an indexer cluster
+type StandaloneMigrationSpec struct {
+	// IndexerClusterRef refers to an indexer cluster managed by the operator that the standalone instances are converted
+	// into peers of, once it is ready. Data on their existing persistent volumes is kept.
+	IndexerClusterRef corev1.ObjectReference `json:"indexerClusterRef"`
+}
+
+// StandaloneMigrationStatus is used to track the migration of standalone instances into an indexer cluster
+type StandaloneMigrationStatus struct {
+	// indexer cluster ("namespace/name") that the standalone instances are migrated into
+	IndexerCluster string `json:"indexerCluster"`
+
+	// current phase of the migration: "Pending" while waiting for the indexer cluster to be ready, "Updating" while
+	// instances are converted into peers, and "Ready" once all of them are up on its cluster master
+	Phase ResourcePhase `json:"phase"`
+
+	// names of the standalone instances that are up as peers on the cluster master
+	Peers []string `json:"peers"`
 }
 
 // StandaloneStatus defines the observed state of a Splunk Enterprise standalone instances.
@@ -47,6 +80,27 @@ type StandaloneStatus struct {
 	// current phase of the standalone instances
 	Phase ResourcePhase `json:"phase"`
 
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// generation of the standalone instances most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the standalone instances
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the standalone instances, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the standalone instances, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+
+	// external endpoints of the services used to expose groups of Splunk ports, for groups that do not use ClusterIP
+	Services []ServiceStatus `json:"services"`
+
 	// number of desired standalone instances
 	Replicas int32 `json:"replicas"`
 
@@ -55,6 +109,24 @@ type StandaloneStatus struct {
 
 	// selector for pods, used by HorizontalPodAutoscaler
 	Selector string `json:"selector"`
+
+	// app packages installed from the app repository
+	Apps []AppStatus `json:"apps"`
+
+	// app packages found the last time the app repository was checked
+	AppRepo AppRepoStatus `json:"appRepo"`
+
+	// post-install steps run for premium app packages installed from the app repository
+	PremiumApps []PremiumAppStatus `json:"premiumApps"`
+
+	// container image most recently verified by health checks after it was rolled out
+	VerifiedImage string `json:"verifiedImage"`
+
+	// checksum of the conf files most recently reloaded on all instances, when configUpdateStrategy is reload
+	ConfFilesChecksum string `json:"confFilesChecksum"`
+
+	// progress of the migration into an indexer cluster, if one has been requested
+	Migration *StandaloneMigrationStatus `json:"migration,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// UniversalForwarderSpec defines the desired state of Splunk universal forwarders, which send data to the indexer
+// cluster referenced by indexerClusterRef.
+type UniversalForwarderSpec struct {
+	CommonSplunkSpec `json:",inline"`
+
+	// Kind of workload used to run forwarders, either “DaemonSet” to run one on each node (the default) or “Deployment”
+	// +kubebuilder:validation:Enum=DaemonSet;Deployment
+	WorkloadType string `json:"workloadType"`
+
+	// Number of forwarder pods, when workloadType is “Deployment” (defaults to 1; ignored for DaemonSets)
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+}
+
+// UniversalForwarderStatus defines the observed state of Splunk universal forwarders.
+type UniversalForwarderStatus struct {
+	// current phase of the universal forwarders
+	Phase ResourcePhase `json:"phase"`
+
+	// reason for the current phase, in CamelCase
+	Reason string `json:"reason"`
+
+	// human readable message explaining the current phase, such as what is not ready yet
+	Message string `json:"message"`
+
+	// number of forwarder pods that should be running
+	Replicas int32 `json:"replicas"`
+
+	// current number of ready forwarder pods
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// true if forwarders use indexer discovery to find the peers of the indexer cluster
+	IndexerDiscovery bool `json:"indexerDiscovery"`
+
+	// true if forwarders use TLS to send data to the peers of the indexer cluster
+	TLS bool `json:"tls"`
+
+	// generation of the universal forwarders most recently observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// standard conditions used to report the state of the universal forwarders
+	Conditions []Condition `json:"conditions"`
+
+	// changes that the operator would make to reconcile the universal forwarders, while dry runs are requested
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// most recent error that prevented the operator from reconciling the universal forwarders, cleared once a reconcile succeeds
+	LastError *ReconcileErrorStatus `json:"lastError,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UniversalForwarder is the Schema for Splunk universal forwarders.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=universalforwarders,scope=Namespaced,shortName=uf
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of universal forwarders"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.replicas",description="Number of desired forwarder pods"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Current number of ready forwarder pods"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of universal forwarders"
+type UniversalForwarder struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UniversalForwarderSpec   `json:"spec,omitempty"`
+	Status UniversalForwarderStatus `json:"status,omitempty"`
+}
+
+// GetIdentifier is a convenience function to return unique identifier for the Splunk enterprise deployment
+func (cr *UniversalForwarder) GetIdentifier() string {
+	return cr.ObjectMeta.Name
+}
+
+// GetNamespace is a convenience function to return namespace for a Splunk enterprise deployment
+func (cr *UniversalForwarder) GetNamespace() string {
+	return cr.ObjectMeta.Namespace
+}
+
+// GetTypeMeta is a convenience function to return a TypeMeta object
+func (cr *UniversalForwarder) GetTypeMeta() metav1.TypeMeta {
+	return cr.TypeMeta
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UniversalForwarderList contains a list of UniversalForwarder
+type UniversalForwarderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UniversalForwarder `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UniversalForwarder{}, &UniversalForwarderList{})
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploymentserver
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
+	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
+)
+
+var log = logf.Log.WithName("controller_deploymentserver")
+
+/**
+* USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
+* business logic.  Delete these comments after modifying this file.*
+ */
+
+// Add creates a new DeploymentServer Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	// Use a new go client to work-around issues with the operator sdk design.
+	// If WATCH_NAMESPACE is empty for monitoring cluster-wide custom Splunk resources,
+	// the default caching client will attempt to list all resources in all namespaces for
+	// any get requests, even if the request is namespace-scoped.
+	options := client.Options{}
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "deploymentserver")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, options)
+	if err != nil {
+		return err
+	}
+	reconciler := ReconcileDeploymentServer{
+		client: client,
+		scheme: mgr.GetScheme(),
+	}
+	return add(mgr, &reconciler)
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller, which may reconcile multiple custom resources at the same time if configured
+	maxConcurrentReconciles, err := resources.GetMaxConcurrentReconciles("deploymentserver")
+	if err != nil {
+		return err
+	}
+	c, err := controller.New("deploymentserver-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource DeploymentServer
+	err = c.Watch(&source.Kind{Type: &enterprisev1.DeploymentServer{}}, &handler.EnqueueRequestForObject{}, resources.SpecChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource StatefulSets and requeue the owner DeploymentServer
+	err = c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &enterprisev1.DeploymentServer{},
+	}, resources.WorkloadChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ConfigMaps and requeue any DeploymentServers that reference them using defaultsConfigMapRef,
+	// serverClassConfigMapRef or the configMapRef of a deployment app
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.DeploymentServerList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list DeploymentServers for ConfigMap", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if referencesConfigMap(&cr, obj.Meta.GetName()) {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Secrets and requeue any DeploymentServers that reference them using secretRef or licenseMasterSecretRef
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.DeploymentServerList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list DeploymentServers for Secret", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if cr.Spec.SecretRef == obj.Meta.GetName() || cr.Spec.LicenseMasterSecretRef == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// referencesConfigMap returns true if a DeploymentServer references the named ConfigMap
+func referencesConfigMap(cr *enterprisev1.DeploymentServer, name string) bool {
+	if cr.Spec.DefaultsConfigMapRef == name || cr.Spec.ServerClassConfigMapRef == name {
+		return true
+	}
+	for _, app := range cr.Spec.DeploymentApps {
+		if app.ConfigMapRef == name {
+			return true
+		}
+	}
+	return false
+}
+
+// blank assignment to verify that ReconcileDeploymentServer implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileDeploymentServer{}
+
+// ReconcileDeploymentServer reconciles a DeploymentServer object
+type ReconcileDeploymentServer struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile reads that state of the cluster for a DeploymentServer object and makes changes based on the state read
+// and what is in the DeploymentServer.Spec
+// TODO(user): Modify this Reconcile function to implement your Controller logic.  This example creates
+// a Pod as an example
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileDeploymentServer) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling DeploymentServer")
+	start := time.Now()
+
+	// Fetch the DeploymentServer instance
+	instance := &enterprisev1.DeploymentServer{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			metrics.DeleteResourcePhase("DeploymentServer", request.Namespace, request.Name)
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1beta1"
+	instance.TypeMeta.Kind = "DeploymentServer"
+
+	span := tracing.StartSpan(nil, "Reconcile DeploymentServer", tracing.SpanKindInternal, "k8s.namespace", request.Namespace, "k8s.name", request.Name)
+	result, err := splunkreconcile.ApplyDeploymentServer(splunkreconcile.NewTracingClient(r.client, span), instance)
+	span.End(err)
+	metrics.ObserveReconcile("deploymentserver", start, err)
+	metrics.SetResourcePhase(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), instance.Status.Phase)
+	if err != nil {
+		reqLogger.Error(err, "DeploymentServer reconciliation requeued", "RequeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+	if result.Requeue {
+		reqLogger.Info("DeploymentServer reconciliation requeued", "RequeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+
+	reqLogger.Info("DeploymentServer reconciliation complete")
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package garbagecollector
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
+	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+var log = logf.Log.WithName("controller_garbagecollector")
+
+// Add creates a new garbage collector for orphaned resources and adds it to the Manager, unless the ORPHAN_POLICY
+// environment variable is empty or "Ignore". The Manager will Start it once this replica of the operator is the leader.
+func Add(mgr manager.Manager) error {
+	policy := os.Getenv(resources.OrphanPolicyEnv)
+	if policy == "" {
+		policy = enterprise.OrphanPolicyIgnore
+	}
+	if err := enterprise.ValidateOrphanPolicy(policy); err != nil {
+		return err
+	}
+	if policy == enterprise.OrphanPolicyIgnore {
+		return nil
+	}
+	interval, err := resources.GetOrphanCheckInterval()
+	if err != nil {
+		return err
+	}
+
+	// orphaned resources are checked in each watched namespace, or all namespaces if none are configured
+	watchNamespace, err := k8sutil.GetWatchNamespace()
+	if err != nil {
+		return err
+	}
+	var namespaces []string
+	for _, ns := range strings.Split(watchNamespace, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	// Use a new go client, like the other controllers, so that objects are not cached for all namespaces
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "garbagecollector")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return err
+	}
+
+	log.Info("Registering garbage collector for orphaned resources", "policy", policy, "interval", interval)
+	return mgr.Add(&GarbageCollector{
+		client:     client,
+		policy:     policy,
+		interval:   interval,
+		namespaces: namespaces,
+	})
+}
+
+// GarbageCollector periodically reports or deletes resources created by the operator whose custom resource no longer exists
+type GarbageCollector struct {
+	client     client.Client
+	policy     string
+	interval   time.Duration
+	namespaces []string
+}
+
+// Start checks for orphaned resources every interval, until stop is closed
+func (gc *GarbageCollector) Start(stop <-chan struct{}) error {
+	wait.Until(gc.collect, gc.interval, stop)
+	return nil
+}
+
+// collect checks for orphaned resources in each namespace, and updates the orphaned resource metrics with those that remain
+func (gc *GarbageCollector) collect() {
+	start := time.Now()
+	counts := make(map[string]map[string]int)
+	var err error
+	for _, namespace := range gc.namespaces {
+		orphans, nsErr := splunkreconcile.CollectOrphanedResources(gc.client, namespace, gc.policy)
+		if nsErr != nil {
+			log.Error(nsErr, "Unable to collect orphaned resources", "namespace", namespace)
+			err = nsErr
+		}
+		for _, orphan := range orphans {
+			if orphan.Deleted {
+				continue
+			}
+			if counts[orphan.Namespace] == nil {
+				counts[orphan.Namespace] = make(map[string]int)
+			}
+			counts[orphan.Namespace][orphan.Kind]++
+		}
+	}
+	metrics.ObserveReconcile("garbagecollector", start, err)
+	metrics.SetOrphanedResources(counts)
+}
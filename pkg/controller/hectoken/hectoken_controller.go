@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hectoken
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
+	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
+)
+
+var log = logf.Log.WithName("controller_hectoken")
+
+/**
+* USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
+* business logic.  Delete these comments after modifying this file.*
+ */
+
+// Add creates a new HecToken Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	// Use a new go client to work-around issues with the operator sdk design.
+	// If WATCH_NAMESPACE is empty for monitoring cluster-wide custom Splunk resources,
+	// the default caching client will attempt to list all resources in all namespaces for
+	// any get requests, even if the request is namespace-scoped.
+	options := client.Options{}
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "hectoken")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, options)
+	if err != nil {
+		return err
+	}
+	reconciler := ReconcileHecToken{
+		client: client,
+		scheme: mgr.GetScheme(),
+	}
+	return add(mgr, &reconciler)
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller, which may reconcile multiple custom resources at the same time if configured
+	maxConcurrentReconciles, err := resources.GetMaxConcurrentReconciles("hectoken")
+	if err != nil {
+		return err
+	}
+	c, err := controller.New("hectoken-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource HecToken
+	err = c.Watch(&source.Kind{Type: &enterprisev1.HecToken{}}, &handler.EnqueueRequestForObject{}, resources.SpecChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Secrets and requeue the owner HecToken
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &enterprisev1.HecToken{},
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileHecToken implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileHecToken{}
+
+// ReconcileHecToken reconciles a HecToken object
+type ReconcileHecToken struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile reads that state of the cluster for a HecToken object and makes changes based on the state read
+// and what is in the HecToken.Spec
+// TODO(user): Modify this Reconcile function to implement your Controller logic.  This example creates
+// a Pod as an example
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileHecToken) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling HecToken")
+	start := time.Now()
+
+	// Fetch the HecToken instance
+	instance := &enterprisev1.HecToken{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			metrics.DeleteResourcePhase("HecToken", request.Namespace, request.Name)
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1beta1"
+	instance.TypeMeta.Kind = "HecToken"
+
+	span := tracing.StartSpan(nil, "Reconcile HecToken", tracing.SpanKindInternal, "k8s.namespace", request.Namespace, "k8s.name", request.Name)
+	result, err := splunkreconcile.ApplyHecToken(splunkreconcile.NewTracingClient(r.client, span), instance)
+	span.End(err)
+	metrics.ObserveReconcile("hectoken", start, err)
+	metrics.SetResourcePhase(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), instance.Status.Phase)
+	if err != nil {
+		reqLogger.Error(err, "HecToken reconciliation requeued", "RequeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+	if result.Requeue {
+		reqLogger.Info("HecToken reconciliation requeued", "RequeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+
+	reqLogger.Info("HecToken reconciliation complete")
+	return reconcile.Result{}, nil
+}
@@ -16,10 +16,13 @@ package indexercluster
 
 import (
 	"context"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -28,8 +31,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
 	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
 )
 
 var log = logf.Log.WithName("controller_indexer")
@@ -47,7 +53,11 @@ func Add(mgr manager.Manager) error {
 	// the default caching client will attempt to list all resources in all namespaces for
 	// any get requests, even if the request is namespace-scoped.
 	options := client.Options{}
-	client, err := client.New(mgr.GetConfig(), options)
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "indexercluster")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, options)
 	if err != nil {
 		return err
 	}
@@ -60,14 +70,18 @@ func Add(mgr manager.Manager) error {
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
-	// Create a new controller
-	c, err := controller.New("indexer-controller", mgr, controller.Options{Reconciler: r})
+	// Create a new controller, which may reconcile multiple custom resources at the same time if configured
+	maxConcurrentReconciles, err := resources.GetMaxConcurrentReconciles("indexercluster")
+	if err != nil {
+		return err
+	}
+	c, err := controller.New("indexer-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
 	if err != nil {
 		return err
 	}
 
 	// Watch for changes to primary resource IndexerCluster
-	err = c.Watch(&source.Kind{Type: &enterprisev1.IndexerCluster{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &enterprisev1.IndexerCluster{}}, &handler.EnqueueRequestForObject{}, resources.SpecChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -76,7 +90,86 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	err = c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &enterprisev1.IndexerCluster{},
-	})
+	}, resources.WorkloadChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ConfigMaps and requeue any IndexerClusters that reference them using defaultsConfigMapRef
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.IndexerClusterList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list IndexerClusters for ConfigMap", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if cr.Spec.DefaultsConfigMapRef == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Secrets and requeue any IndexerClusters that reference them using secretRef, or for an external
+	// cluster master or license master
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.IndexerClusterList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list IndexerClusters for Secret", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if cr.Spec.SecretRef == obj.Meta.GetName() || cr.Spec.ExternalClusterMaster.SecretRef == obj.Meta.GetName() ||
+					cr.Spec.LicenseMasterSecretRef == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ClusterMasters and requeue any IndexerClusters that reference them using clusterMasterRef
+	err = c.Watch(&source.Kind{Type: &enterprisev1.ClusterMaster{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.IndexerClusterList
+			err := mgr.GetClient().List(context.TODO(), &list)
+			if err != nil {
+				log.Error(err, "Unable to list IndexerClusters for ClusterMaster", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				ref := cr.Spec.ClusterMasterRef
+				namespace := ref.Namespace
+				if namespace == "" {
+					namespace = cr.GetNamespace()
+				}
+				if ref.Name == obj.Meta.GetName() && namespace == obj.Meta.GetNamespace() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.ResourceChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -105,6 +198,7 @@ type ReconcileIndexerCluster struct {
 func (r *ReconcileIndexerCluster) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling IndexerCluster")
+	start := time.Now()
 
 	// Fetch the IndexerCluster instance
 	instance := &enterprisev1.IndexerCluster{}
@@ -114,16 +208,23 @@ func (r *ReconcileIndexerCluster) Reconcile(request reconcile.Request) (reconcil
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			metrics.DeleteResourcePhase("IndexerCluster", request.Namespace, request.Name)
+			metrics.DeleteLoadMetrics("IndexerCluster", request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1alpha2"
+	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1beta1"
 	instance.TypeMeta.Kind = "IndexerCluster"
 
-	result, err := splunkreconcile.ApplyIndexerCluster(r.client, instance)
+	span := tracing.StartSpan(nil, "Reconcile IndexerCluster", tracing.SpanKindInternal, "k8s.namespace", request.Namespace, "k8s.name", request.Name)
+	result, err := splunkreconcile.ApplyIndexerCluster(splunkreconcile.NewTracingClient(r.client, span), instance)
+	span.End(err)
+	metrics.ObserveReconcile("indexercluster", start, err)
+	metrics.SetResourcePhase(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), instance.Status.Phase)
+	metrics.SetIndexingQueueFillRatio(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), &instance.Spec.LoadMetrics, &instance.Status.LoadMetrics)
 	if err != nil {
 		reqLogger.Error(err, "IndexerCluster reconciliation requeued", "RequeueAfter", result.RequeueAfter)
 		return result, nil
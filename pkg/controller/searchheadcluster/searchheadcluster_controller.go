@@ -16,10 +16,13 @@ package searchheadcluster
 
 import (
 	"context"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -28,8 +31,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
 	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
 )
 
 var log = logf.Log.WithName("controller_searchhead")
@@ -47,7 +53,11 @@ func Add(mgr manager.Manager) error {
 	// the default caching client will attempt to list all resources in all namespaces for
 	// any get requests, even if the request is namespace-scoped.
 	options := client.Options{}
-	client, err := client.New(mgr.GetConfig(), options)
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "searchheadcluster")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, options)
 	if err != nil {
 		return err
 	}
@@ -60,14 +70,18 @@ func Add(mgr manager.Manager) error {
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
-	// Create a new controller
-	c, err := controller.New("searchhead-controller", mgr, controller.Options{Reconciler: r})
+	// Create a new controller, which may reconcile multiple custom resources at the same time if configured
+	maxConcurrentReconciles, err := resources.GetMaxConcurrentReconciles("searchheadcluster")
+	if err != nil {
+		return err
+	}
+	c, err := controller.New("searchhead-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
 	if err != nil {
 		return err
 	}
 
 	// Watch for changes to primary resource SearchHeadCluster
-	err = c.Watch(&source.Kind{Type: &enterprisev1.SearchHeadCluster{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &enterprisev1.SearchHeadCluster{}}, &handler.EnqueueRequestForObject{}, resources.SpecChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -76,7 +90,56 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	err = c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &enterprisev1.SearchHeadCluster{},
-	})
+	}, resources.WorkloadChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ConfigMaps and requeue any SearchHeadClusters that reference them using defaultsConfigMapRef or
+	// the configMapRef of a deployer app
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.SearchHeadClusterList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list SearchHeadClusters for ConfigMap", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if referencesConfigMap(&cr, obj.Meta.GetName()) {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Secrets and requeue any SearchHeadClusters that reference them using secretRef or licenseMasterSecretRef
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			var list enterprisev1.SearchHeadClusterList
+			err := mgr.GetClient().List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace()))
+			if err != nil {
+				log.Error(err, "Unable to list SearchHeadClusters for Secret", "name", obj.Meta.GetName(), "namespace", obj.Meta.GetNamespace())
+				return nil
+			}
+			var requests []reconcile.Request
+			for _, cr := range list.Items {
+				if cr.Spec.SecretRef == obj.Meta.GetName() || cr.Spec.LicenseMasterSecretRef == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()},
+					})
+				}
+			}
+			return requests
+		}),
+	}, resources.DataChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -84,6 +147,19 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+// referencesConfigMap returns true if a SearchHeadCluster references the named ConfigMap
+func referencesConfigMap(cr *enterprisev1.SearchHeadCluster, name string) bool {
+	if cr.Spec.DefaultsConfigMapRef == name {
+		return true
+	}
+	for _, app := range cr.Spec.DeployerApps {
+		if app.ConfigMapRef == name {
+			return true
+		}
+	}
+	return false
+}
+
 // blank assignment to verify that ReconcileSearchHeadCluster implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileSearchHeadCluster{}
 
@@ -105,6 +181,7 @@ type ReconcileSearchHeadCluster struct {
 func (r *ReconcileSearchHeadCluster) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling SearchHeadCluster")
+	start := time.Now()
 
 	// Fetch the SearchHeadCluster instance
 	instance := &enterprisev1.SearchHeadCluster{}
@@ -114,16 +191,23 @@ func (r *ReconcileSearchHeadCluster) Reconcile(request reconcile.Request) (recon
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			metrics.DeleteResourcePhase("SearchHeadCluster", request.Namespace, request.Name)
+			metrics.DeleteLoadMetrics("SearchHeadCluster", request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1alpha2"
+	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1beta1"
 	instance.TypeMeta.Kind = "SearchHeadCluster"
 
-	result, err := splunkreconcile.ApplySearchHeadCluster(r.client, instance)
+	span := tracing.StartSpan(nil, "Reconcile SearchHeadCluster", tracing.SpanKindInternal, "k8s.namespace", request.Namespace, "k8s.name", request.Name)
+	result, err := splunkreconcile.ApplySearchHeadCluster(splunkreconcile.NewTracingClient(r.client, span), instance)
+	span.End(err)
+	metrics.ObserveReconcile("searchheadcluster", start, err)
+	metrics.SetResourcePhase(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), instance.Status.Phase)
+	metrics.SetSearchConcurrency(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), &instance.Spec.LoadMetrics, &instance.Status.LoadMetrics)
 	if err != nil {
 		reqLogger.Error(err, "SearchHeadCluster reconciliation requeued", "RequeueAfter", result.RequeueAfter)
 		return result, nil
@@ -16,6 +16,7 @@ package spark
 
 import (
 	"context"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -28,8 +29,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/metrics"
 	splunkreconcile "github.com/splunk/splunk-operator/pkg/splunk/reconcile"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
 )
 
 var log = logf.Log.WithName("controller_spark")
@@ -47,7 +51,11 @@ func Add(mgr manager.Manager) error {
 	// the default caching client will attempt to list all resources in all namespaces for
 	// any get requests, even if the request is namespace-scoped.
 	options := client.Options{}
-	client, err := client.New(mgr.GetConfig(), options)
+	cfg, err := resources.GetControllerConfig(mgr.GetConfig(), "spark")
+	if err != nil {
+		return err
+	}
+	client, err := client.New(cfg, options)
 	if err != nil {
 		return err
 	}
@@ -60,14 +68,18 @@ func Add(mgr manager.Manager) error {
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
-	// Create a new controller
-	c, err := controller.New("spark-controller", mgr, controller.Options{Reconciler: r})
+	// Create a new controller, which may reconcile multiple custom resources at the same time if configured
+	maxConcurrentReconciles, err := resources.GetMaxConcurrentReconciles("spark")
+	if err != nil {
+		return err
+	}
+	c, err := controller.New("spark-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
 	if err != nil {
 		return err
 	}
 
 	// Watch for changes to primary resource Spark
-	err = c.Watch(&source.Kind{Type: &enterprisev1.Spark{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &enterprisev1.Spark{}}, &handler.EnqueueRequestForObject{}, resources.SpecChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -76,7 +88,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &enterprisev1.Spark{},
-	})
+	}, resources.WorkloadChangedPredicate())
 	if err != nil {
 		return err
 	}
@@ -105,6 +117,7 @@ type ReconcileSpark struct {
 func (r *ReconcileSpark) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling Spark")
+	start := time.Now()
 
 	// Fetch the Spark instance
 	instance := &enterprisev1.Spark{}
@@ -114,16 +127,21 @@ func (r *ReconcileSpark) Reconcile(request reconcile.Request) (reconcile.Result,
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			metrics.DeleteResourcePhase("Spark", request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1alpha2"
+	instance.TypeMeta.APIVersion = "enterprise.splunk.com/v1beta1"
 	instance.TypeMeta.Kind = "Spark"
 
-	result, err := splunkreconcile.ApplySpark(r.client, instance)
+	span := tracing.StartSpan(nil, "Reconcile Spark", tracing.SpanKindInternal, "k8s.namespace", request.Namespace, "k8s.name", request.Name)
+	result, err := splunkreconcile.ApplySpark(splunkreconcile.NewTracingClient(r.client, span), instance)
+	span.End(err)
+	metrics.ObserveReconcile("spark", start, err)
+	metrics.SetResourcePhase(instance.TypeMeta.Kind, instance.GetNamespace(), instance.GetName(), instance.Status.Phase)
 	if err != nil {
 		reqLogger.Error(err, "Spark reconciliation requeued", "RequeueAfter", result.RequeueAfter)
 		return result, nil
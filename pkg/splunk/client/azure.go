@@ -0,0 +1,330 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// version of the Azure Storage REST API used for requests and shared access signatures
+	azureStorageVersion = "2020-02-10"
+
+	// time format used by the Azure Storage REST API
+	azureTimeFormat = "2006-01-02T15:04:05Z"
+
+	// resource used to request access tokens for Azure Storage
+	azureStorageResource = "https://storage.azure.com/"
+)
+
+// AzureIdentityEndpoint is the Azure Instance Metadata Service endpoint used to get access tokens for a managed identity
+var AzureIdentityEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// AzureBlobClient is a simple object used to list and download blobs from Azure Blob Storage. Requests are authorized
+// using a shared access signature (SAS) token when one is provided, or using the managed identity of the operator.
+type AzureBlobClient struct {
+	// https endpoint of the storage account (e.g. "https://myaccount.blob.core.windows.net")
+	Endpoint string
+
+	// name of the storage account, used to sign download URLs when using the managed identity
+	StorageAccount string
+
+	// SAS token used to authorize requests and downloads; the managed identity is used if empty
+	SASToken string
+
+	// HTTP client used to process requests
+	Client SplunkHTTPClient
+
+	// access token obtained for the managed identity
+	accessToken string
+
+	// user delegation key obtained for the managed identity, used to sign download URLs
+	delegationKey *azureUserDelegationKey
+}
+
+// NewAzureBlobClient returns a new AzureBlobClient object initialized with an endpoint, storage account and SAS token.
+func NewAzureBlobClient(endpoint, storageAccount, sasToken string) *AzureBlobClient {
+	return &AzureBlobClient{
+		Endpoint:       strings.TrimSuffix(endpoint, "/"),
+		StorageAccount: storageAccount,
+		SASToken:       strings.TrimPrefix(sasToken, "?"),
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// azureListBlobsResult is used to decode List Blobs responses.
+// See https://docs.microsoft.com/en-us/rest/api/storageservices/list-blobs
+type azureListBlobsResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ETag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// azureUserDelegationKey is used to decode Get User Delegation Key responses.
+// See https://docs.microsoft.com/en-us/rest/api/storageservices/get-user-delegation-key
+type azureUserDelegationKey struct {
+	SignedOid     string `xml:"SignedOid"`
+	SignedTid     string `xml:"SignedTid"`
+	SignedStart   string `xml:"SignedStart"`
+	SignedExpiry  string `xml:"SignedExpiry"`
+	SignedService string `xml:"SignedService"`
+	SignedVersion string `xml:"SignedVersion"`
+	Value         string `xml:"Value"`
+}
+
+// ListObjects returns all blobs in a container that have names beginning with prefix.
+func (c *AzureBlobClient) ListObjects(container, prefix string) ([]S3ObjectInfo, error) {
+	result := []S3ObjectInfo{}
+	marker := ""
+
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		request, err := c.newRequest("GET", getAzureBlobPath(container, ""), query, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		var page azureListBlobsResult
+		if err = xml.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Blobs {
+			result = append(result, S3ObjectInfo{
+				Key:          blob.Name,
+				ETag:         blob.Properties.ETag,
+				LastModified: blob.Properties.LastModified,
+				Size:         blob.Properties.ContentLength,
+			})
+		}
+
+		if page.NextMarker == "" {
+			break
+		}
+		marker = page.NextMarker
+	}
+
+	return result, nil
+}
+
+// GetDownloadURL returns a URL that can be used to download a blob without credentials until it expires. The SAS token
+// is used if one has been provided; otherwise, the URL is signed using a user delegation key for the managed identity.
+func (c *AzureBlobClient) GetDownloadURL(container, key string, expires time.Duration) (string, error) {
+	blobURL := c.Endpoint + getAzureBlobPath(container, key)
+	if c.SASToken != "" {
+		return fmt.Sprintf("%s?%s", blobURL, c.SASToken), nil
+	}
+
+	now := time.Now().UTC()
+	start := now.Format(azureTimeFormat)
+	expiry := now.Add(expires).Format(azureTimeFormat)
+	if c.delegationKey == nil || c.delegationKey.SignedExpiry < expiry {
+		delegationKey, err := c.getUserDelegationKey(start, expiry)
+		if err != nil {
+			return "", err
+		}
+		c.delegationKey = delegationKey
+	}
+
+	signature, err := c.getUserDelegationSignature(container, key, start, expiry)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("sv", azureStorageVersion)
+	query.Set("sr", "b")
+	query.Set("sp", "r")
+	query.Set("st", start)
+	query.Set("se", expiry)
+	query.Set("spr", "https")
+	query.Set("skoid", c.delegationKey.SignedOid)
+	query.Set("sktid", c.delegationKey.SignedTid)
+	query.Set("skt", c.delegationKey.SignedStart)
+	query.Set("ske", c.delegationKey.SignedExpiry)
+	query.Set("sks", c.delegationKey.SignedService)
+	query.Set("skv", c.delegationKey.SignedVersion)
+	query.Set("sig", signature)
+	return fmt.Sprintf("%s?%s", blobURL, query.Encode()), nil
+}
+
+// getUserDelegationKey requests a key used to sign download URLs for the managed identity, valid between start and expiry
+func (c *AzureBlobClient) getUserDelegationKey(start, expiry string) (*azureUserDelegationKey, error) {
+	query := url.Values{}
+	query.Set("restype", "service")
+	query.Set("comp", "userdelegationkey")
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?><KeyInfo><Start>%s</Start><Expiry>%s</Expiry></KeyInfo>`, start, expiry)
+
+	request, err := c.newRequest("POST", "/", query, []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var delegationKey azureUserDelegationKey
+	if err = xml.Unmarshal(data, &delegationKey); err != nil {
+		return nil, err
+	}
+	return &delegationKey, nil
+}
+
+// getUserDelegationSignature returns the signature of a user delegation SAS used to read a blob between start and expiry.
+// See https://docs.microsoft.com/en-us/rest/api/storageservices/create-user-delegation-sas
+func (c *AzureBlobClient) getUserDelegationSignature(container, key, start, expiry string) (string, error) {
+	secret, err := base64.StdEncoding.DecodeString(c.delegationKey.Value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid user delegation key: %v", err)
+	}
+
+	stringToSign := strings.Join([]string{
+		"r",
+		start,
+		expiry,
+		fmt.Sprintf("/blob/%s/%s/%s", c.StorageAccount, container, key),
+		c.delegationKey.SignedOid,
+		c.delegationKey.SignedTid,
+		c.delegationKey.SignedStart,
+		c.delegationKey.SignedExpiry,
+		c.delegationKey.SignedService,
+		c.delegationKey.SignedVersion,
+		"", // signed authorized user object id
+		"", // signed unauthorized user object id
+		"", // signed correlation id
+		"", // signed IP
+		"https",
+		azureStorageVersion,
+		"b",
+		"", // signed snapshot time
+		"", // rscc
+		"", // rscd
+		"", // rsce
+		"", // rscl
+		"", // rsct
+	}, "\n")
+
+	return base64.StdEncoding.EncodeToString(hmacSHA256(secret, stringToSign)), nil
+}
+
+// getAccessToken returns an access token for Azure Storage, obtained for the managed identity of the operator
+func (c *AzureBlobClient) getAccessToken() (string, error) {
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+
+	query := url.Values{}
+	query.Set("api-version", "2018-02-01")
+	query.Set("resource", azureStorageResource)
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s?%s", AzureIdentityEndpoint, query.Encode()), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata", "true")
+	data, err := c.do(request)
+	if err != nil {
+		return "", fmt.Errorf("Unable to get access token for managed identity: %v", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(data, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("Managed identity access token is empty")
+	}
+	c.accessToken = token.AccessToken
+	return c.accessToken, nil
+}
+
+// newRequest returns a request for the Blob service, authorized using either the SAS token or the managed identity
+func (c *AzureBlobClient) newRequest(method, path string, query url.Values, body []byte) (*http.Request, error) {
+	rawQuery := query.Encode()
+	if c.SASToken != "" {
+		rawQuery = fmt.Sprintf("%s&%s", rawQuery, c.SASToken)
+	}
+
+	request, err := http.NewRequest(method, fmt.Sprintf("%s%s?%s", c.Endpoint, path, rawQuery), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Ms-Version", azureStorageVersion)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/xml")
+	}
+
+	if c.SASToken == "" {
+		token, err := c.getAccessToken()
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request, nil
+}
+
+// do sends a request and returns the body of its response
+func (c *AzureBlobClient) do(request *http.Request) ([]byte, error) {
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+	}
+	return data, nil
+}
+
+// getAzureBlobPath returns the URI encoded path of a container, or of a blob within it
+func getAzureBlobPath(container, key string) string {
+	if key == "" {
+		return "/" + s3Escape(container)
+	}
+	return getS3CanonicalURI(container, key)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestAzureListObjects(t *testing.T) {
+	mockClient := &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(
+		spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://myaccount.blob.test/apps?comp=list&prefix=security%2F&restype=container&sv=2019-12-12&sp=rl&sig=abc%3D",
+			Status: 200,
+			Body:   `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="apps"><Prefix>security/</Prefix><Blobs><Blob><Name>security/app1.tgz</Name><Properties><Last-Modified>Fri, 01 May 2020 10:00:00 GMT</Last-Modified><Etag>0x8D7ED5C9A1B2C3D</Etag><Content-Length>2048</Content-Length></Properties></Blob></Blobs><NextMarker>marker1</NextMarker></EnumerationResults>`,
+		},
+		spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://myaccount.blob.test/apps?comp=list&marker=marker1&prefix=security%2F&restype=container&sv=2019-12-12&sp=rl&sig=abc%3D",
+			Status: 200,
+			Body:   `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="apps"><Prefix>security/</Prefix><Blobs><Blob><Name>security/app2.spl</Name><Properties><Etag>0x8D7ED5C9A1B2C3E</Etag><Content-Length>4096</Content-Length></Properties></Blob></Blobs><NextMarker /></EnumerationResults>`,
+		},
+	)
+	c := NewAzureBlobClient("https://myaccount.blob.test/", "myaccount", "?sv=2019-12-12&sp=rl&sig=abc%3D")
+	c.Client = mockClient
+
+	objects, err := c.ListObjects("apps", "security/")
+	if err != nil {
+		t.Errorf("ListObjects err = %v", err)
+	}
+	mockClient.CheckRequests(t, "TestAzureListObjects")
+	if len(objects) != 2 {
+		t.Fatalf("ListObjects returned %d objects; want %d", len(objects), 2)
+	}
+	if objects[0].Key != "security/app1.tgz" || objects[0].ETag != "0x8D7ED5C9A1B2C3D" || objects[0].Size != 2048 {
+		t.Errorf("ListObjects objects[0]=%v; want security/app1.tgz", objects[0])
+	}
+	if objects[1].Key != "security/app2.spl" || objects[1].ETag != "0x8D7ED5C9A1B2C3E" {
+		t.Errorf("ListObjects objects[1]=%v; want security/app2.spl", objects[1])
+	}
+	if got := mockClient.GotRequests[0].Header.Get("X-Ms-Version"); got != azureStorageVersion {
+		t.Errorf("ListObjects X-Ms-Version=\"%s\"; want \"%s\"", got, azureStorageVersion)
+	}
+
+	// test error code
+	mockClient = &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://myaccount.blob.test/apps?comp=list&restype=container&sv=2019-12-12&sp=rl&sig=abc%3D",
+		Status: 403,
+	})
+	c.Client = mockClient
+	_, err = c.ListObjects("apps", "")
+	if err == nil {
+		t.Errorf("ListObjects returned nil; want error")
+	}
+	mockClient.CheckRequests(t, "TestAzureListObjects")
+}
+
+func TestAzureGetDownloadURL(t *testing.T) {
+	// SAS token
+	c := NewAzureBlobClient("https://myaccount.blob.test", "myaccount", "sv=2019-12-12&sp=rl&sig=abc%3D")
+	got, err := c.GetDownloadURL("apps", "security/my app.tgz", time.Hour)
+	want := "https://myaccount.blob.test/apps/security/my%20app.tgz?sv=2019-12-12&sp=rl&sig=abc%3D"
+	if err != nil || got != want {
+		t.Errorf("GetDownloadURL()=\"%s\", %v; want \"%s\", nil", got, err, want)
+	}
+
+	// managed identity
+	savedEndpoint := AzureIdentityEndpoint
+	AzureIdentityEndpoint = "http://imds.test/metadata/identity/oauth2/token"
+	defer func() { AzureIdentityEndpoint = savedEndpoint }()
+	mockClient := &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(
+		spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "http://imds.test/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fstorage.azure.com%2F",
+			Status: 200,
+			Body:   `{"access_token":"t0k3n","expires_in":"3599","token_type":"Bearer"}`,
+		},
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://myaccount.blob.test/?comp=userdelegationkey&restype=service",
+			Status: 200,
+			Body:   `<?xml version="1.0" encoding="utf-8"?><UserDelegationKey><SignedOid>oid</SignedOid><SignedTid>tid</SignedTid><SignedStart>2020-05-01T00:00:00Z</SignedStart><SignedExpiry>2099-05-08T00:00:00Z</SignedExpiry><SignedService>b</SignedService><SignedVersion>2020-02-10</SignedVersion><Value>c2VjcmV0</Value></UserDelegationKey>`,
+		},
+	)
+	c = NewAzureBlobClient("https://myaccount.blob.test", "myaccount", "")
+	c.Client = mockClient
+	for _, key := range []string{"security/app1.tgz", "security/app2.spl"} {
+		got, err = c.GetDownloadURL("apps", key, time.Hour)
+		if err != nil {
+			t.Fatalf("GetDownloadURL() returned %v; want nil", err)
+		}
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Fatalf("GetDownloadURL() returned invalid URL \"%s\": %v", got, err)
+		}
+		if u.Path != "/apps/"+key {
+			t.Errorf("GetDownloadURL() path=\"%s\"; want \"%s\"", u.Path, "/apps/"+key)
+		}
+		query := u.Query()
+		if query.Get("skoid") != "oid" || query.Get("sktid") != "tid" || query.Get("sr") != "b" || query.Get("sp") != "r" {
+			t.Errorf("GetDownloadURL() query=%v; want user delegation SAS", query)
+		}
+		if sig, err := base64.StdEncoding.DecodeString(query.Get("sig")); err != nil || len(sig) != 32 {
+			t.Errorf("GetDownloadURL() sig=\"%s\"; want base64 encoded HMAC-SHA256", query.Get("sig"))
+		}
+	}
+	// the access token and user delegation key are reused for all downloads
+	mockClient.CheckRequests(t, "TestAzureGetDownloadURL")
+	if got := mockClient.GotRequests[1].Header.Get("Authorization"); got != "Bearer t0k3n" {
+		t.Errorf("GetDownloadURL() Authorization=\"%s\"; want \"Bearer t0k3n\"", got)
+	}
+	if got := mockClient.GotRequests[0].Header.Get("Metadata"); got != "true" {
+		t.Errorf("GetDownloadURL() Metadata=\"%s\"; want \"true\"", got)
+	}
+}
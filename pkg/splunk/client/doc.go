@@ -13,7 +13,11 @@
 // limitations under the License.
 
 /*
-Package client provides a simple client for the Splunk Enterprise REST API.
+Package client provides a simple client for the Splunk Enterprise REST API,
+and for listing and downloading objects from S3 compatible storage.
+Splunk REST API requests are retried with exponential backoff after transient
+errors, using timeouts and TLS settings that may be configured with
+SplunkClientOptions.
 This package has no depedencies outside of the standard go library.
 */
 package client
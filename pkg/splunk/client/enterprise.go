@@ -15,12 +15,17 @@
 package client
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -30,6 +35,51 @@ type SplunkHTTPClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// requestObserver is called after each Splunk REST API request, if not nil
+var requestObserver func(method, path string, code int, start time.Time)
+
+// SetRequestObserver sets a function that is called after each Splunk REST API request, with the response
+// status code (or 0 if no response was received) and the time when the request was sent. This is used to
+// record metrics without adding dependencies to this package.
+func SetRequestObserver(observer func(method, path string, code int, start time.Time)) {
+	requestObserver = observer
+}
+
+// observeRequest calls the request observer, if one has been set
+func observeRequest(request *http.Request, code int, start time.Time) {
+	if requestObserver != nil {
+		requestObserver(request.Method, request.URL.Path, code, start)
+	}
+}
+
+// SplunkClientOptions are used to configure the timeouts, TLS settings and retries used by a SplunkClient.
+type SplunkClientOptions struct {
+	// maximum time allowed for each request, including reading the response body
+	Timeout time.Duration
+
+	// TLS configuration used for https connections; certificates are not verified if nil
+	TLSConfig *tls.Config
+
+	// maximum number of times a request is retried after a transient error
+	MaxRetries int
+
+	// time to wait before the first retry, which is doubled for each subsequent retry
+	RetryBackoff time.Duration
+
+	// maximum time to wait between retries
+	MaxRetryBackoff time.Duration
+}
+
+// DefaultSplunkClientOptions returns the options used by NewSplunkClient.
+func DefaultSplunkClientOptions() SplunkClientOptions {
+	return SplunkClientOptions{
+		Timeout:         5 * time.Second,
+		MaxRetries:      2,
+		RetryBackoff:    500 * time.Millisecond,
+		MaxRetryBackoff: 5 * time.Second,
+	}
+}
+
 // SplunkClient is a simple object used to send HTTP REST API requests
 type SplunkClient struct {
 	// https endpoint for management interface (e.g. "https://server:8089")
@@ -43,33 +93,131 @@ type SplunkClient struct {
 
 	// HTTP client used to process requests
 	Client SplunkHTTPClient
+
+	// maximum number of times a request is retried after a transient error
+	MaxRetries int
+
+	// time to wait before the first retry, which is doubled for each subsequent retry
+	RetryBackoff time.Duration
+
+	// maximum time to wait between retries
+	MaxRetryBackoff time.Duration
+
+	// function used to wait between retries (uses time.Sleep if nil)
+	sleep func(time.Duration)
 }
 
 // NewSplunkClient returns a new SplunkClient object initialized with a username and password.
 func NewSplunkClient(managementURI, username, password string) *SplunkClient {
+	return NewSplunkClientWithOptions(managementURI, username, password, DefaultSplunkClientOptions())
+}
+
+// NewSplunkClientWithOptions returns a new SplunkClient object initialized with a username, password and options.
+func NewSplunkClientWithOptions(managementURI, username, password string, options SplunkClientOptions) *SplunkClient {
+	tlsConfig := options.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: true} // don't verify ssl certs
+	}
 	return &SplunkClient{
 		ManagementURI: managementURI,
 		Username:      username,
 		Password:      password,
 		Client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout: options.Timeout,
 			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // don't verify ssl certs
+				TLSClientConfig: tlsConfig,
 			},
 		},
+		MaxRetries:      options.MaxRetries,
+		RetryBackoff:    options.RetryBackoff,
+		MaxRetryBackoff: options.MaxRetryBackoff,
+	}
+}
+
+// ResponseError is returned when a Splunk REST API request receives an unexpected response code.
+type ResponseError struct {
+	// URL of the request
+	URL string
+
+	// response code received
+	StatusCode int
+
+	// response code that was expected
+	ExpectedStatus int
+}
+
+// Error returns a description of a ResponseError.
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("Response code=%d from %s; want %d", e.StatusCode, e.URL, e.ExpectedStatus)
+}
+
+// isRetryable returns true if a response or error indicates a transient failure that may succeed if retried.
+// Responses with a 503 code are not retried, since splunkd uses these to report errors that need to be handled
+// by the caller (such as a cluster that is not yet initialized).
+func isRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		// timeouts, connection failures, etc. (errors from an http.Client always implement net.Error)
+		_, ok := err.(net.Error)
+		return ok
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// send sends a Splunk REST API request, retrying with exponential backoff after transient errors.
+func (c *SplunkClient) send(request *http.Request) (*http.Response, error) {
+	request.SetBasicAuth(c.Username, c.Password)
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		response, err := c.Client.Do(request)
+		if err != nil {
+			observeRequest(request, 0, start)
+		} else {
+			observeRequest(request, response.StatusCode, start)
+		}
+
+		// requests with a body can only be retried if it can be recreated
+		if attempt >= c.MaxRetries || !isRetryable(response, err) || (request.Body != nil && request.GetBody == nil) {
+			return response, err
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+		if request.GetBody != nil {
+			request.Body, err = request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if c.sleep != nil {
+			c.sleep(backoff)
+		} else {
+			time.Sleep(backoff)
+		}
+		backoff *= 2
+		if c.MaxRetryBackoff > 0 && backoff > c.MaxRetryBackoff {
+			backoff = c.MaxRetryBackoff
+		}
 	}
 }
 
 // Do processes a Splunk REST API request and unmarshals response into obj, if not nil.
+// Requests are retried after transient errors, and a *ResponseError is returned if the response
+// code is not expectedStatus.
 func (c *SplunkClient) Do(request *http.Request, expectedStatus int, obj interface{}) error {
 	// send HTTP response and check status
-	request.SetBasicAuth(c.Username, c.Password)
-	response, err := c.Client.Do(request)
+	response, err := c.send(request)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	if response.StatusCode != expectedStatus {
-		return fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, expectedStatus)
+		return &ResponseError{URL: request.URL.String(), StatusCode: response.StatusCode, ExpectedStatus: expectedStatus}
 	}
 	if obj == nil {
 		return nil
@@ -289,6 +437,22 @@ func (c *SplunkClient) SetSearchHeadDetention(detain bool) error {
 	return c.Do(request, 200, nil)
 }
 
+// TransferSearchHeadCaptaincy transfers captaincy of a search head cluster to the member with the given management URI
+// (as reported by GetSearchHeadCaptainMembers). You can use this on any member of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Transfercaptaincy
+func (c *SplunkClient) TransferSearchHeadCaptaincy(mgmtURI string) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/member/consensus/default/transfer_captaincy", c.ManagementURI)
+	body := url.Values{
+		"mgmt_uri": {mgmtURI},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
 // RemoveSearchHeadClusterMember removes a search head cluster member.
 // You can use this on any member of a search head cluster.
 // See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Removeaclustermember
@@ -301,16 +465,16 @@ func (c *SplunkClient) RemoveSearchHeadClusterMember() error {
 	}
 
 	// send HTTP response and check status
-	request.SetBasicAuth(c.Username, c.Password)
-	response, err := c.Client.Do(request)
+	response, err := c.send(request)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	if response.StatusCode == 200 {
 		return nil
 	}
 	if response.StatusCode != 503 {
-		return fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+		return &ResponseError{URL: request.URL.String(), StatusCode: response.StatusCode, ExpectedStatus: 200}
 	}
 
 	// unmarshall 503 response
@@ -342,6 +506,62 @@ func (c *SplunkClient) RemoveSearchHeadClusterMember() error {
 	return fmt.Errorf("Received unrecognized 503 response from %s", request.URL)
 }
 
+// ApplySearchHeadClusterBundle pushes the configuration bundle in etc/shcluster to the search head cluster that target (the
+// management URI of any member) belongs to.
+// You can only use this on a search head cluster deployer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/PropagateSHCconfigurationchanges
+func (c *SplunkClient) ApplySearchHeadClusterBundle(target string) error {
+	endpoint := fmt.Sprintf("%s/services/apps/deploy", c.ManagementURI)
+	body := url.Values{
+		"target": {target},
+		"action": {"all"},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
+// LocalAppInfo represents the status of an app installed on a Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTapps#apps.2Flocal
+type LocalAppInfo struct {
+	// Name of the app
+	Name string `json:"-"`
+
+	// Version of the app, from its app.conf
+	Version string `json:"version"`
+
+	// Indicates if the app is disabled
+	Disabled bool `json:"disabled"`
+}
+
+// GetLocalApps queries for the apps that are installed on a Splunk Enterprise instance.
+// You can use this on any Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTapps#apps.2Flocal
+func (c *SplunkClient) GetLocalApps() (map[string]LocalAppInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string       `json:"name"`
+			Content LocalAppInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/apps/local"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make(map[string]LocalAppInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		apps[e.Name] = e.Content
+	}
+
+	return apps, nil
+}
+
 // ClusterBundleInfo represents the status of a configuration bundle.
 type ClusterBundleInfo struct {
 	// BundlePath is filesystem path to the file represending the bundle
@@ -406,6 +626,52 @@ func (c *SplunkClient) GetClusterMasterInfo() (*ClusterMasterInfo, error) {
 	return &apiResponse.Entry[0].Content, nil
 }
 
+// ClusterMasterHealthInfo represents the health of an indexer cluster, as reported by the cluster master.
+// Each value is "1" if the condition is met, or "0" if it is not.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmaster.2Fhealth
+type ClusterMasterHealthInfo struct {
+	// Indicates if all data in the cluster is searchable.
+	AllDataIsSearchable string `json:"all_data_is_searchable"`
+
+	// Indicates if all peers are up and running.
+	AllPeersAreUp string `json:"all_peers_are_up"`
+
+	// Indicates if there are no fixup tasks (such as bucket replication) in progress.
+	NoFixupTasksInProgress string `json:"no_fixup_tasks_in_progress"`
+
+	// Indicates if the replication factor is met for all buckets.
+	ReplicationFactorMet string `json:"replication_factor_met"`
+
+	// Indicates if the search factor is met for all buckets.
+	SearchFactorMet string `json:"search_factor_met"`
+
+	// Indicates if the site replication factor is met for all buckets (multisite clusters only).
+	SiteReplicationFactorMet string `json:"site_replication_factor_met"`
+
+	// Indicates if the site search factor is met for all buckets (multisite clusters only).
+	SiteSearchFactorMet string `json:"site_search_factor_met"`
+}
+
+// GetClusterMasterHealth queries the cluster master for the health of the indexer cluster.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmaster.2Fhealth
+func (c *SplunkClient) GetClusterMasterHealth() (*ClusterMasterHealthInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content ClusterMasterHealthInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/master/health"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("Invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
 // IndexerClusterPeerInfo represents the status of a indexer cluster peer.
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fslave.2Finfo
 type IndexerClusterPeerInfo struct {
@@ -608,3 +874,799 @@ func (c *SplunkClient) DecommissionIndexerClusterPeer(enforceCounts bool) error
 	}
 	return c.Do(request, 200, nil)
 }
+
+// SetIndexerClusterPeerDetention enables or disables manual detention of an indexer cluster peer, which stops it from
+// receiving new data from forwarders while it keeps serving searches.
+// You can use this on any peer in an indexer cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Peerdetention
+func (c *SplunkClient) SetIndexerClusterPeerDetention(detain bool) error {
+	mode := "off"
+	if detain {
+		mode = "on"
+	}
+	endpoint := fmt.Sprintf("%s/services/cluster/slave/control/control/set_manual_detention?manual_detention=%s", c.ManagementURI, mode)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// RollClusterHotBuckets rolls the hot buckets of an index on all peers of an indexer cluster, so that their data is
+// uploaded to remote storage by SmartStore.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmaster.2Fcontrol.2Fcontrol.2Froll-hot-buckets
+func (c *SplunkClient) RollClusterHotBuckets(index string) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/control/roll-hot-buckets?index=%s", c.ManagementURI, url.QueryEscape(index))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// ApplyClusterMasterBundle distributes the configuration bundle in master-apps to all indexer cluster peers.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Updatepeerconfigurations
+func (c *SplunkClient) ApplyClusterMasterBundle() error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/default/apply", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// ClusterMasterIndexInfo represents the status of an index across all peers of an indexer cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmaster.2Findexes
+type ClusterMasterIndexInfo struct {
+	// Name of the index
+	Name string `json:"-"`
+
+	// Size of the index in bytes, counting one copy of each bucket.
+	IndexSize int64 `json:"index_size"`
+
+	// Number of buckets in the index.
+	NumBuckets int64 `json:"num_buckets"`
+
+	// Number of bucket copies in the index beyond those required by the replication factor.
+	TotalExcessBucketCopies int64 `json:"total_excess_bucket_copies"`
+}
+
+// GetClusterMasterIndexes queries the cluster master for info about the indexes of an indexer cluster.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmaster.2Findexes
+func (c *SplunkClient) GetClusterMasterIndexes() (map[string]ClusterMasterIndexInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string                 `json:"name"`
+			Content ClusterMasterIndexInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/master/indexes"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]ClusterMasterIndexInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		indexes[e.Name] = e.Content
+	}
+
+	return indexes, nil
+}
+
+// RemoveExcessBuckets removes bucket copies beyond those required by the replication and search factors from all
+// indexer cluster peers, across all indexes.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Removeextrabucketcopies
+func (c *SplunkClient) RemoveExcessBuckets() error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/control/prune_index", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// SetClusterMasterMaintenanceMode enables or disables maintenance mode for an indexer cluster, which halts bucket fixup activity.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Usemaintenancemode
+func (c *SplunkClient) SetClusterMasterMaintenanceMode(enable bool) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/default/maintenance_mode?mode=%t", c.ManagementURI, enable)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// RebalanceClusterBuckets starts a data rebalance, which moves bucket copies between indexer cluster peers so that each
+// peer holds approximately the same number of copies.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Rebalancethecluster
+func (c *SplunkClient) RebalanceClusterBuckets() error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/control/rebalance_buckets?action=start", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// RestartSplunk restarts Splunk on an instance, without restarting its pod.
+// You can use this on any Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsystem#server.2Fcontrol.2Frestart
+func (c *SplunkClient) RestartSplunk() error {
+	endpoint := fmt.Sprintf("%s/services/server/control/restart", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// RollingRestartClusterPeers initiates a rolling restart of all indexer cluster peers. Searchable rolling restarts keep
+// data searchable and allow in-progress searches to complete while peers restart.
+// You can only use this on a cluster master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Userollingrestart
+func (c *SplunkClient) RollingRestartClusterPeers(searchable bool) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/master/control/control/restart?searchable=%t", c.ManagementURI, searchable)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// SearchPeerInfo represents the status of a search peer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fpeers
+type SearchPeerInfo struct {
+	// Name of the search peer, in the form host:port
+	Name string `json:"-"`
+
+	// Name used by the search peer's server as its identifier
+	PeerName string `json:"peerName"`
+
+	// Type of search peer (configured or discovered)
+	PeerType string `json:"peerType"`
+
+	// Status of the search peer (e.g. "Up")
+	Status string `json:"status"`
+
+	// Indicates if the search peer is disabled
+	Disabled bool `json:"disabled"`
+}
+
+// GetSearchPeers queries for the search peers that are configured for distributed search.
+// You can use this on any search head, including a monitoring console.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fpeers
+func (c *SplunkClient) GetSearchPeers() (map[string]SearchPeerInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string         `json:"name"`
+			Content SearchPeerInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/search/distributed/peers"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]SearchPeerInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		peers[e.Name] = e.Content
+	}
+
+	return peers, nil
+}
+
+// AddSearchPeer adds a search peer for distributed search, where peer is in the form host:port.
+// You can use this on any search head, including a monitoring console.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Configuredistributedsearch
+func (c *SplunkClient) AddSearchPeer(peer, remoteUsername, remotePassword string) error {
+	endpoint := fmt.Sprintf("%s/services/search/distributed/peers", c.ManagementURI)
+	body := url.Values{
+		"name":           {peer},
+		"remoteUsername": {remoteUsername},
+		"remotePassword": {remotePassword},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 201, nil)
+}
+
+// RemoveSearchPeer removes a search peer for distributed search, where peer is in the form host:port.
+// You can use this on any search head, including a monitoring console.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fpeers.2F.7Bname.7D
+func (c *SplunkClient) RemoveSearchPeer(peer string) error {
+	endpoint := fmt.Sprintf("%s/services/search/distributed/peers/%s", c.ManagementURI, url.PathEscape(peer))
+	request, err := http.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// dfsSearchRegex is used to identify search jobs that run using Data Fabric Search (DFS)
+var dfsSearchRegex = regexp.MustCompile(`^\s*\|\s*dfsjob\b`)
+
+// GetActiveDFSSearchCount returns the number of Data Fabric Search (DFS) jobs that are currently running on a search head.
+// DFS jobs are identified as searches that start with the dfsjob command.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fjobs
+func (c *SplunkClient) GetActiveDFSSearchCount() (int, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string `json:"name"`
+			Content struct {
+				IsDone bool `json:"isDone"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/search/jobs"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range apiResponse.Entry {
+		if !e.Content.IsDone && dfsSearchRegex.MatchString(e.Name) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetRunningSearchCount returns the number of search jobs that are currently running on a search head.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fjobs
+func (c *SplunkClient) GetRunningSearchCount() (int, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content struct {
+				DispatchState string `json:"dispatchState"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/search/jobs"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range apiResponse.Entry {
+		if e.Content.DispatchState == "RUNNING" {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetIndexQueueFillRatio returns the fraction (between 0 and 1) of the index queue on an indexer that is currently filled.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#server.2Fintrospection.2Fqueues
+func (c *SplunkClient) GetIndexQueueFillRatio() (float64, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string `json:"name"`
+			Content struct {
+				CurrentSizeBytes int64 `json:"current_size_bytes"`
+				MaxSizeBytes     int64 `json:"max_size_bytes"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/server/introspection/queues"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range apiResponse.Entry {
+		if e.Name == "indexqueue" && e.Content.MaxSizeBytes > 0 {
+			return float64(e.Content.CurrentSizeBytes) / float64(e.Content.MaxSizeBytes), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Invalid response from %s%s", c.ManagementURI, path)
+}
+
+// KVStoreInfo represents the current state of the KV store on a Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#kvstore.2Fstatus
+type KVStoreInfo struct {
+	// Status of the KV store, such as "starting", "ready", "disabled" or "failed"
+	Status string `json:"status"`
+
+	// Replication status of the KV store within a search head cluster, such as "KV store captain",
+	// "Non-captain KV store member", "Initial sync", "Recovering" or "Down"
+	ReplicationStatus string `json:"replicationStatus"`
+
+	// Status of KV store backups and restores, either "Ready" or "Busy" while one is in progress
+	BackupRestoreStatus string `json:"backupRestoreStatus"`
+}
+
+// GetKVStoreInfo queries for the current state of the KV store on a Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#kvstore.2Fstatus
+func (c *SplunkClient) GetKVStoreInfo() (*KVStoreInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content struct {
+				Current KVStoreInfo `json:"current"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/kvstore/status"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("Invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content.Current, nil
+}
+
+// CreateKVStoreBackup starts a backup of all KV store collections to an archive named archiveName. The archive is created
+// in the kvstorebackup directory of the instance's var volume, once the backupRestoreStatus reported by GetKVStoreInfo
+// is "Ready" again.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Admin/BackupKVstore
+func (c *SplunkClient) CreateKVStoreBackup(archiveName string) error {
+	endpoint := fmt.Sprintf("%s/services/kvstore/backup/create", c.ManagementURI)
+	body := url.Values{"archiveName": {archiveName}}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
+// GetKVStoreStatus returns the status of the KV store on a Splunk Enterprise instance, such as "starting", "ready" or "failed".
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#kvstore.2Fstatus
+func (c *SplunkClient) GetKVStoreStatus() (string, error) {
+	info, err := c.GetKVStoreInfo()
+	if err != nil {
+		return "", err
+	}
+	return info.Status, nil
+}
+
+// KVStoreCollectionInfo represents a KV store collection defined by an app.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#storage.2Fcollections.2Fconfig
+type KVStoreCollectionInfo struct {
+	// Name of the collection
+	Name string `json:"name"`
+
+	// Name of the app that defines the collection
+	App string `json:"app"`
+}
+
+// GetKVStoreCollections queries for the KV store collections defined by all apps.
+// You can use this on any Splunk Enterprise instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#storage.2Fcollections.2Fconfig
+func (c *SplunkClient) GetKVStoreCollections() ([]KVStoreCollectionInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name string `json:"name"`
+			ACL  struct {
+				App string `json:"app"`
+			} `json:"acl"`
+		} `json:"entry"`
+	}{}
+	path := "/servicesNS/-/-/storage/collections/config"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := []KVStoreCollectionInfo{}
+	for _, e := range apiResponse.Entry {
+		collections = append(collections, KVStoreCollectionInfo{Name: e.Name, App: e.ACL.App})
+	}
+	return collections, nil
+}
+
+// CreateKVStoreCollection creates a new KV store collection in an app, which is shared with all users.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#storage.2Fcollections.2Fconfig
+func (c *SplunkClient) CreateKVStoreCollection(app, name string) error {
+	endpoint := fmt.Sprintf("%s/servicesNS/nobody/%s/storage/collections/config", c.ManagementURI, url.PathEscape(app))
+	body := url.Values{
+		"name": {name},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 201, nil)
+}
+
+// GetKVStoreCollectionData returns up to limit documents from a KV store collection, after skipping the first skip documents
+// when sorted by _key.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#storage.2Fcollections.2Fdata.2F.7Bcollection.7D
+func (c *SplunkClient) GetKVStoreCollectionData(app, collection string, skip, limit int) ([]json.RawMessage, error) {
+	query := url.Values{
+		"sort":        {"_key"},
+		"skip":        {strconv.Itoa(skip)},
+		"limit":       {strconv.Itoa(limit)},
+		"output_mode": {"json"},
+	}
+	endpoint := fmt.Sprintf("%s/servicesNS/nobody/%s/storage/collections/data/%s?%s", c.ManagementURI, url.PathEscape(app), url.PathEscape(collection), query.Encode())
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	documents := []json.RawMessage{}
+	err = c.Do(request, 200, &documents)
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// SaveKVStoreCollectionData inserts documents into a KV store collection, replacing any existing documents with the same _key.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#storage.2Fcollections.2Fdata.2F.7Bcollection.7D.2Fbatch_save
+func (c *SplunkClient) SaveKVStoreCollectionData(app, collection string, documents []json.RawMessage) error {
+	endpoint := fmt.Sprintf("%s/servicesNS/nobody/%s/storage/collections/data/%s/batch_save", c.ManagementURI, url.PathEscape(app), url.PathEscape(collection))
+	body, err := json.Marshal(documents)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return c.Do(request, 200, nil)
+}
+
+// DispatchSearch starts a search job that runs in the background, and returns its search ID.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fjobs
+func (c *SplunkClient) DispatchSearch(search string) (string, error) {
+	endpoint := fmt.Sprintf("%s/services/search/jobs", c.ManagementURI)
+	body := url.Values{
+		"search":      {search},
+		"output_mode": {"json"},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	apiResponse := struct {
+		SearchID string `json:"sid"`
+	}{}
+	err = c.Do(request, 201, &apiResponse)
+	if err != nil {
+		return "", err
+	}
+	if apiResponse.SearchID == "" {
+		return "", fmt.Errorf("Search job ID not found in response from %s", endpoint)
+	}
+	return apiResponse.SearchID, nil
+}
+
+// GetSearchJobState returns the dispatch state of a search job: "QUEUED", "PARSING", "RUNNING", "FINALIZING", "DONE"
+// or "FAILED" (among others).
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fjobs.2F.7Bsearch_id.7D
+func (c *SplunkClient) GetSearchJobState(searchID string) (string, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content struct {
+				DispatchState string `json:"dispatchState"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := fmt.Sprintf("/services/search/jobs/%s", url.PathEscape(searchID))
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return "", err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return "", fmt.Errorf("Invalid response from %s%s", c.ManagementURI, path)
+	}
+	return apiResponse.Entry[0].Content.DispatchState, nil
+}
+
+// LicenseStackInfo represents the status of a license stack, which combines the licenses of a given type.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fstacks
+type LicenseStackInfo struct {
+	// Identifier of the license stack (e.g. "enterprise")
+	Name string `json:"-"`
+
+	// Label of the license stack (e.g. "Splunk Enterprise")
+	Label string `json:"label"`
+
+	// Daily indexing volume allowed by all licenses in the stack, in bytes
+	Quota int64 `json:"quota"`
+
+	// Type of licenses in the stack
+	Type string `json:"type"`
+}
+
+// GetLicenseStacks queries for the license stacks available on a license master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fstacks
+func (c *SplunkClient) GetLicenseStacks() (map[string]LicenseStackInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string           `json:"name"`
+			Content LicenseStackInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/stacks"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	stacks := make(map[string]LicenseStackInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		stacks[e.Name] = e.Content
+	}
+
+	return stacks, nil
+}
+
+// LicensePoolInfo represents the status of a license pool.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+type LicensePoolInfo struct {
+	// Name of the license pool
+	Name string `json:"-"`
+
+	// Description of the license pool
+	Description string `json:"description"`
+
+	// Identifier of the license stack that the pool draws from
+	StackID string `json:"stack_id"`
+
+	// Daily indexing volume allowed for the pool, in bytes
+	EffectiveQuota int64 `json:"effective_quota"`
+
+	// Indexing volume used by the pool today, in bytes
+	UsedBytes int64 `json:"used_bytes"`
+
+	// GUIDs of the license slaves assigned to the pool, or "*" for all slaves
+	Slaves []string `json:"slaves"`
+}
+
+// GetLicensePools queries for the license pools configured on a license master.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+func (c *SplunkClient) GetLicensePools() (map[string]LicensePoolInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string          `json:"name"`
+			Content LicensePoolInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/pools"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make(map[string]LicensePoolInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		pools[e.Name] = e.Content
+	}
+
+	return pools, nil
+}
+
+// CreateLicensePool creates a new license pool on a license master, where quota is either "MAX" or a
+// number of bytes with an optional MB or GB suffix, and slaves are GUIDs of license slaves (or "*").
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+func (c *SplunkClient) CreateLicensePool(name, stackID, quota string, slaves []string, description string) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/pools", c.ManagementURI)
+	body := url.Values{
+		"name":        {name},
+		"stack_id":    {stackID},
+		"quota":       {quota},
+		"slaves":      slaves,
+		"description": {description},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 201, nil)
+}
+
+// UpdateLicensePool updates the quota, slaves and description of an existing license pool on a license master.
+// The slaves assigned to the pool are replaced with those provided.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools.2F.7Bname.7D
+func (c *SplunkClient) UpdateLicensePool(name, quota string, slaves []string, description string) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/pools/%s", c.ManagementURI, url.PathEscape(name))
+	body := url.Values{
+		"quota":         {quota},
+		"slaves":        slaves,
+		"append_slaves": {"false"},
+		"description":   {description},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
+// UpdateAdminPassword changes the password of the admin user, which must be the user authenticated by this client.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTaccess#authentication.2Fusers.2F.7Bname.7D
+func (c *SplunkClient) UpdateAdminPassword(newPassword string) error {
+	endpoint := fmt.Sprintf("%s/services/authentication/users/admin", c.ManagementURI)
+	body := url.Values{
+		"password":    {newPassword},
+		"oldpassword": {c.Password},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
+// CheckCredentials returns nil if the username and password used by this client are valid.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTaccess#authentication.2Fcurrent-context
+func (c *SplunkClient) CheckCredentials() error {
+	return c.Get("/services/authentication/current-context", nil)
+}
+
+// HTTPInputInfo represents the configuration of an HTTP Event Collector (HEC) token.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTinput#data.2Finputs.2Fhttp
+type HTTPInputInfo struct {
+	// Name of the HEC token
+	Name string `json:"-"`
+
+	// Value of the token used by clients to send events
+	Token string `json:"token"`
+
+	// Default index for events sent using the token
+	Index string `json:"index"`
+
+	// Default sourcetype for events sent using the token
+	Sourcetype string `json:"sourcetype"`
+
+	// Indexes that events sent using the token are allowed to use (all indexes if empty)
+	Indexes []string `json:"indexes"`
+
+	// Indicates if the token is disabled
+	Disabled bool `json:"disabled"`
+}
+
+// httpInputPrefix is added by splunkd to the names of HEC tokens returned by the REST API
+const httpInputPrefix = "http://"
+
+// GetHTTPInputs queries for the HTTP Event Collector (HEC) tokens configured on a Splunk instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTinput#data.2Finputs.2Fhttp
+func (c *SplunkClient) GetHTTPInputs() (map[string]HTTPInputInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string        `json:"name"`
+			Content HTTPInputInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/data/inputs/http"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[string]HTTPInputInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = strings.TrimPrefix(e.Name, httpInputPrefix)
+		inputs[e.Content.Name] = e.Content
+	}
+
+	return inputs, nil
+}
+
+// CreateHTTPInput creates a new HTTP Event Collector (HEC) token on a Splunk instance, using the given token value.
+// index and sourcetype are optional, and events may be sent to any index if indexes is empty.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTinput#data.2Finputs.2Fhttp
+func (c *SplunkClient) CreateHTTPInput(name, token, index, sourcetype string, indexes []string) error {
+	endpoint := fmt.Sprintf("%s/services/data/inputs/http", c.ManagementURI)
+	body := url.Values{
+		"name":  {name},
+		"token": {token},
+	}
+	if index != "" {
+		body.Set("index", index)
+	}
+	if sourcetype != "" {
+		body.Set("sourcetype", sourcetype)
+	}
+	if len(indexes) > 0 {
+		body.Set("indexes", strings.Join(indexes, ","))
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 201, nil)
+}
+
+// UpdateHTTPInput updates the default index, sourcetype and allowed indexes of an existing HTTP Event Collector (HEC)
+// token on a Splunk instance. Empty values are used to clear any that were previously set. The token value cannot be changed.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTinput#data.2Finputs.2Fhttp.2F.7Bname.7D
+func (c *SplunkClient) UpdateHTTPInput(name, index, sourcetype string, indexes []string) error {
+	endpoint := fmt.Sprintf("%s/services/data/inputs/http/%s", c.ManagementURI, url.PathEscape(name))
+	body := url.Values{
+		"index":      {index},
+		"sourcetype": {sourcetype},
+		"indexes":    {strings.Join(indexes, ",")},
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request, 200, nil)
+}
+
+// DeleteHTTPInput deletes an HTTP Event Collector (HEC) token from a Splunk instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTinput#data.2Finputs.2Fhttp.2F.7Bname.7D
+func (c *SplunkClient) DeleteHTTPInput(name string) error {
+	endpoint := fmt.Sprintf("%s/services/data/inputs/http/%s", c.ManagementURI, url.PathEscape(name))
+	request, err := http.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// GetConfValue returns the value of a setting in a stanza of a .conf file, as seen within the context of an app. An empty
+// string is returned if the stanza does not contain the setting.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTconf#configs.2Fconf-.7Bfile.7D.2F.7Bstanza.7D
+func (c *SplunkClient) GetConfValue(app, conf, stanza, key string) (string, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content map[string]interface{} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := fmt.Sprintf("/servicesNS/nobody/%s/configs/conf-%s/%s", url.PathEscape(app), url.PathEscape(conf), url.PathEscape(stanza))
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return "", err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return "", fmt.Errorf("Invalid response from %s%s", c.ManagementURI, path)
+	}
+	value, _ := apiResponse.Entry[0].Content[key].(string)
+	return value, nil
+}
+
+// ReloadConf reloads a .conf file from disk, so that changes made to it since splunkd started are returned by the REST API.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTconf#configs.2Fconf-.7Bfile.7D
+func (c *SplunkClient) ReloadConf(conf string) error {
+	endpoint := fmt.Sprintf("%s/services/configs/conf-%s/_reload", c.ManagementURI, url.PathEscape(conf))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
+
+// RefreshConfiguration reloads configuration from disk for all of the REST API endpoints that support it, which applies
+// most configuration changes without restarting splunkd.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Admin/Configurationfilechangesthatrequirerestart
+func (c *SplunkClient) RefreshConfiguration() error {
+	endpoint := fmt.Sprintf("%s/services/debug/refresh", c.ManagementURI)
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(request, 200, nil)
+}
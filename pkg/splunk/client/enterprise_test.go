@@ -15,8 +15,15 @@
 package client
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
 )
@@ -158,6 +165,14 @@ func TestSetSearchHeadDetention(t *testing.T) {
 	splunkClientTester(t, "TestSetSearchHeadDetention", 200, "", wantRequest, test)
 }
 
+func TestTransferSearchHeadCaptaincy(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/transfer_captaincy", nil)
+	test := func(c SplunkClient) error {
+		return c.TransferSearchHeadCaptaincy("https://splunk-s1-search-head-1.splunk-s1-search-head-headless.splunk.svc.cluster.local:8089")
+	}
+	splunkClientTester(t, "TestTransferSearchHeadCaptaincy", 200, "", wantRequest, test)
+}
+
 func TestRemoveSearchHeadClusterMember(t *testing.T) {
 	// test for 200 response first (sent on first removal request)
 	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/remove_server?output_mode=json", nil)
@@ -200,6 +215,47 @@ func TestRemoveSearchHeadClusterMember(t *testing.T) {
 	splunkClientTester(t, "TestRemoveSearchHeadClusterMember", 404, "", wantRequest, test)
 }
 
+func TestApplySearchHeadClusterBundle(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/apps/deploy", nil)
+	test := func(c SplunkClient) error {
+		return c.ApplySearchHeadClusterBundle("https://splunk-s2-search-head-0.splunk-s2-search-head-headless.splunk.svc.cluster.local:8089")
+	}
+	splunkClientTester(t, "TestApplySearchHeadClusterBundle", 200, "", wantRequest, test)
+}
+
+func TestGetLocalApps(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/apps/local?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		apps, err := c.GetLocalApps()
+		if err != nil {
+			return err
+		}
+		if len(apps) != 2 {
+			t.Errorf("len(apps)=%d; want %d", len(apps), 2)
+		}
+		app, ok := apps["sh_base"]
+		if !ok {
+			t.Errorf("wanted app not found: %s", "sh_base")
+		}
+		if app.Name != "sh_base" || app.Version != "1.2.0" {
+			t.Errorf("app sh_base want Name=sh_base, Version=1.2.0: got %s, %s", app.Name, app.Version)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"search","content":{"disabled":false,"version":"8.0.3"}},{"name":"sh_base","content":{"disabled":false,"version":"1.2.0"}}]}`
+	splunkClientTester(t, "TestGetLocalApps", 200, body, wantRequest, test)
+
+	// test error response
+	test = func(c SplunkClient) error {
+		_, err := c.GetLocalApps()
+		if err == nil {
+			t.Errorf("GetLocalApps returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetLocalApps", 503, "", wantRequest, test)
+}
+
 func TestGetClusterMasterInfo(t *testing.T) {
 	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/master/info?count=0&output_mode=json", nil)
 	wantInfo := ClusterMasterInfo{
@@ -249,6 +305,45 @@ func TestGetClusterMasterInfo(t *testing.T) {
 	splunkClientTester(t, "TestGetClusterMasterInfo", 500, "", wantRequest, test)
 }
 
+func TestGetClusterMasterHealth(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/master/health?count=0&output_mode=json", nil)
+	wantHealth := ClusterMasterHealthInfo{
+		AllDataIsSearchable:      "1",
+		AllPeersAreUp:            "1",
+		NoFixupTasksInProgress:   "1",
+		ReplicationFactorMet:     "1",
+		SearchFactorMet:          "1",
+		SiteReplicationFactorMet: "1",
+		SiteSearchFactorMet:      "1",
+	}
+	test := func(c SplunkClient) error {
+		gotHealth, err := c.GetClusterMasterHealth()
+		if err != nil {
+			return err
+		}
+		if *gotHealth != wantHealth {
+			t.Errorf("health=%v; want %v", *gotHealth, wantHealth)
+		}
+		return nil
+	}
+	body := `{"links":{},"origin":"https://localhost:8089/services/cluster/master/health","updated":"2020-05-12T17:36:01+00:00","generator":{"build":"a7f645ddaf91","version":"8.0.2"},"entry":[{"name":"master","id":"https://localhost:8089/services/cluster/master/health/master","updated":"1970-01-01T00:00:00+00:00","links":{"alternate":"/services/cluster/master/health/master","list":"/services/cluster/master/health/master"},"author":"system","acl":{"app":"","can_list":true,"can_write":true,"modifiable":false,"owner":"system","perms":{"read":["admin","splunk-system-role"],"write":["admin","splunk-system-role"]},"removable":false,"sharing":"system"},"content":{"all_data_is_searchable":"1","all_peers_are_up":"1","cm_version_is_compatible":"1","eai:acl":null,"multisite":"0","no_fixup_tasks_in_progress":"1","pre_flight_check":"1","replication_factor_met":"1","search_factor_met":"1","site_replication_factor_met":"1","site_search_factor_met":"1","splunk_version_peer_count":"{ 8.0.2: 3 }"}}],"paging":{"total":1,"perPage":30,"offset":0},"messages":[]}`
+	splunkClientTester(t, "TestGetClusterMasterHealth", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterMasterHealth()
+		if err == nil {
+			t.Errorf("GetClusterMasterHealth returned nil; want error")
+		}
+		return nil
+	}
+	body = `{"links":{},"origin":"https://localhost:8089/services/cluster/master/health","updated":"2020-05-12T17:36:01+00:00","generator":{"build":"a7f645ddaf91","version":"8.0.2"},"entry":[]}`
+	splunkClientTester(t, "TestGetClusterMasterHealth", 200, body, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetClusterMasterHealth", 500, "", wantRequest, test)
+}
+
 func TestGetIndexerClusterPeerInfo(t *testing.T) {
 	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/slave/info?count=0&output_mode=json", nil)
 	wantMemberStatus := "Up"
@@ -343,3 +438,667 @@ func TestDecommissionIndexerClusterPeer(t *testing.T) {
 	}
 	splunkClientTester(t, "TestDecommissionIndexerClusterPeer", 200, "", wantRequest, test)
 }
+
+func TestSetIndexerClusterPeerDetention(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/slave/control/control/set_manual_detention?manual_detention=on", nil)
+	test := func(c SplunkClient) error {
+		return c.SetIndexerClusterPeerDetention(true)
+	}
+	splunkClientTester(t, "TestSetIndexerClusterPeerDetention", 200, "", wantRequest, test)
+
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/cluster/slave/control/control/set_manual_detention?manual_detention=off", nil)
+	test = func(c SplunkClient) error {
+		return c.SetIndexerClusterPeerDetention(false)
+	}
+	splunkClientTester(t, "TestSetIndexerClusterPeerDetention", 200, "", wantRequest, test)
+}
+
+func TestRollClusterHotBuckets(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/control/roll-hot-buckets?index=main", nil)
+	test := func(c SplunkClient) error {
+		return c.RollClusterHotBuckets("main")
+	}
+	splunkClientTester(t, "TestRollClusterHotBuckets", 200, "", wantRequest, test)
+}
+
+func TestApplyClusterMasterBundle(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/default/apply", nil)
+	test := func(c SplunkClient) error {
+		return c.ApplyClusterMasterBundle()
+	}
+	splunkClientTester(t, "TestApplyClusterMasterBundle", 200, "", wantRequest, test)
+}
+
+func TestGetClusterMasterIndexes(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/master/indexes?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		indexes, err := c.GetClusterMasterIndexes()
+		if err != nil {
+			return err
+		}
+		if len(indexes) != 2 {
+			t.Errorf("len(indexes)=%d; want %d", len(indexes), 2)
+		}
+		index, ok := indexes["main"]
+		if !ok {
+			t.Errorf("wanted index not found: %s", "main")
+		}
+		if index.Name != "main" || index.IndexSize != 52428800 || index.NumBuckets != 10 || index.TotalExcessBucketCopies != 4 {
+			t.Errorf("index main = %v; want {main 52428800 10 4}", index)
+		}
+		return nil
+	}
+	body := `{"links":{},"origin":"https://localhost:8089/services/cluster/master/indexes","updated":"2020-06-01T10:00:00+00:00","generator":{"build":"a7f645ddaf91","version":"8.0.2"},"entry":[{"name":"_internal","content":{"buckets_with_excess_copies":0,"index_size":1048576,"num_buckets":5,"total_excess_bucket_copies":0}},{"name":"main","content":{"buckets_with_excess_copies":4,"index_size":52428800,"num_buckets":10,"total_excess_bucket_copies":4}}],"paging":{"total":2,"perPage":30,"offset":0},"messages":[]}`
+	splunkClientTester(t, "TestGetClusterMasterIndexes", 200, body, wantRequest, test)
+
+	// test error response
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterMasterIndexes()
+		if err == nil {
+			t.Errorf("GetClusterMasterIndexes returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetClusterMasterIndexes", 503, "", wantRequest, test)
+}
+
+func TestRemoveExcessBuckets(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/control/prune_index", nil)
+	test := func(c SplunkClient) error {
+		return c.RemoveExcessBuckets()
+	}
+	splunkClientTester(t, "TestRemoveExcessBuckets", 200, "", wantRequest, test)
+}
+
+func TestSetClusterMasterMaintenanceMode(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/default/maintenance_mode?mode=true", nil)
+	test := func(c SplunkClient) error {
+		return c.SetClusterMasterMaintenanceMode(true)
+	}
+	splunkClientTester(t, "TestSetClusterMasterMaintenanceMode", 200, "", wantRequest, test)
+
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/default/maintenance_mode?mode=false", nil)
+	test = func(c SplunkClient) error {
+		return c.SetClusterMasterMaintenanceMode(false)
+	}
+	splunkClientTester(t, "TestSetClusterMasterMaintenanceMode", 200, "", wantRequest, test)
+}
+
+func TestRebalanceClusterBuckets(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/control/rebalance_buckets?action=start", nil)
+	test := func(c SplunkClient) error {
+		return c.RebalanceClusterBuckets()
+	}
+	splunkClientTester(t, "TestRebalanceClusterBuckets", 200, "", wantRequest, test)
+}
+
+func TestRestartSplunk(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/server/control/restart", nil)
+	test := func(c SplunkClient) error {
+		return c.RestartSplunk()
+	}
+	splunkClientTester(t, "TestRestartSplunk", 200, "", wantRequest, test)
+}
+
+func TestRollingRestartClusterPeers(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/control/restart?searchable=true", nil)
+	test := func(c SplunkClient) error {
+		return c.RollingRestartClusterPeers(true)
+	}
+	splunkClientTester(t, "TestRollingRestartClusterPeers", 200, "", wantRequest, test)
+
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/cluster/master/control/control/restart?searchable=false", nil)
+	test = func(c SplunkClient) error {
+		return c.RollingRestartClusterPeers(false)
+	}
+	splunkClientTester(t, "TestRollingRestartClusterPeers", 200, "", wantRequest, test)
+}
+
+func TestGetSearchPeers(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/distributed/peers?count=0&output_mode=json", nil)
+	wantPeer := "splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local:8089"
+	test := func(c SplunkClient) error {
+		peers, err := c.GetSearchPeers()
+		if err != nil {
+			return err
+		}
+		if len(peers) != 1 {
+			t.Errorf("len(peers)=%d; want %d", len(peers), 1)
+		}
+		p, ok := peers[wantPeer]
+		if !ok {
+			t.Errorf("wanted peer not found: %s", wantPeer)
+		}
+		if p.Name != wantPeer {
+			t.Errorf("peer %s want Name=%s: got %s", wantPeer, wantPeer, p.Name)
+		}
+		if p.Status != "Up" {
+			t.Errorf("peer %s want Status=%s: got %s", wantPeer, "Up", p.Status)
+		}
+		return nil
+	}
+	body := `{"links":{"create":"/services/search/distributed/peers/_new"},"origin":"https://localhost:8089/services/search/distributed/peers","updated":"2020-05-04T17:41:09+00:00","generator":{"build":"a6754d8441bf","version":"8.0.3"},"entry":[{"name":"splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local:8089","id":"https://localhost:8089/services/search/distributed/peers/splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local%3A8089","updated":"1970-01-01T00:00:00+00:00","links":{"alternate":"/services/search/distributed/peers/splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local%3A8089"},"author":"system","content":{"disabled":false,"peerName":"splunk-s1-standalone-0","peerType":"configured","status":"Up"}}],"paging":{"total":1,"perPage":30,"offset":0},"messages":[]}`
+	splunkClientTester(t, "TestGetSearchPeers", 200, body, wantRequest, test)
+
+	// test error response
+	test = func(c SplunkClient) error {
+		_, err := c.GetSearchPeers()
+		if err == nil {
+			t.Errorf("GetSearchPeers returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetSearchPeers", 503, "", wantRequest, test)
+}
+
+func TestAddSearchPeer(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/search/distributed/peers", nil)
+	test := func(c SplunkClient) error {
+		return c.AddSearchPeer("splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local:8089", "admin", "p@ssw0rd")
+	}
+	splunkClientTester(t, "TestAddSearchPeer", 201, "", wantRequest, test)
+}
+
+func TestRemoveSearchPeer(t *testing.T) {
+	wantRequest, _ := http.NewRequest("DELETE", "https://localhost:8089/services/search/distributed/peers/splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local:8089", nil)
+	test := func(c SplunkClient) error {
+		return c.RemoveSearchPeer("splunk-s1-standalone-0.splunk-s1-standalone-headless.splunk.svc.cluster.local:8089")
+	}
+	splunkClientTester(t, "TestRemoveSearchPeer", 200, "", wantRequest, test)
+}
+
+func TestGetActiveDFSSearchCount(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/jobs?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		count, err := c.GetActiveDFSSearchCount()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("count=%d; want %d", count, 1)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"| dfsjob [| search index=main | stats count by host]","content":{"isDone":false,"dispatchState":"RUNNING"}},{"name":"| dfsjob [| search index=main]","content":{"isDone":true,"dispatchState":"DONE"}},{"name":"search index=_internal","content":{"isDone":false,"dispatchState":"RUNNING"}}]}`
+	splunkClientTester(t, "TestGetActiveDFSSearchCount", 200, body, wantRequest, test)
+}
+
+func TestGetRunningSearchCount(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/jobs?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		count, err := c.GetRunningSearchCount()
+		if err != nil {
+			return err
+		}
+		if count != 2 {
+			t.Errorf("count=%d; want %d", count, 2)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"search index=main","content":{"isDone":false,"dispatchState":"RUNNING"}},{"name":"search index=_internal","content":{"isDone":true,"dispatchState":"DONE"}},{"name":"| tstats count","content":{"isDone":false,"dispatchState":"RUNNING"}},{"name":"search index=summary","content":{"isDone":false,"dispatchState":"QUEUED"}}]}`
+	splunkClientTester(t, "TestGetRunningSearchCount", 200, body, wantRequest, test)
+}
+
+func TestGetIndexQueueFillRatio(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/server/introspection/queues?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		ratio, err := c.GetIndexQueueFillRatio()
+		if err != nil {
+			return err
+		}
+		if ratio != 0.25 {
+			t.Errorf("ratio=%f; want %f", ratio, 0.25)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"parsingqueue","content":{"current_size_bytes":0,"max_size_bytes":6291456}},{"name":"indexqueue","content":{"current_size_bytes":131072,"max_size_bytes":524288}}]}`
+	splunkClientTester(t, "TestGetIndexQueueFillRatio", 200, body, wantRequest, test)
+
+	// test body without an index queue
+	test = func(c SplunkClient) error {
+		_, err := c.GetIndexQueueFillRatio()
+		if err == nil {
+			t.Errorf("GetIndexQueueFillRatio returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetIndexQueueFillRatio", 200, `{"entry":[{"name":"parsingqueue","content":{"current_size_bytes":0,"max_size_bytes":6291456}}]}`, wantRequest, test)
+}
+
+func TestGetKVStoreStatus(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/kvstore/status?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		status, err := c.GetKVStoreStatus()
+		if err != nil {
+			return err
+		}
+		if status != "ready" {
+			t.Errorf("status=%s; want %s", status, "ready")
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"status","content":{"current":{"backupRestoreStatus":"Ready","replicationStatus":"KV store captain","status":"ready"}}}]}`
+	splunkClientTester(t, "TestGetKVStoreStatus", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetKVStoreStatus()
+		if err == nil {
+			t.Errorf("GetKVStoreStatus returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetKVStoreStatus", 200, `{"entry":[]}`, wantRequest, test)
+}
+
+func TestGetKVStoreInfo(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/kvstore/status?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		info, err := c.GetKVStoreInfo()
+		if err != nil {
+			return err
+		}
+		want := KVStoreInfo{Status: "ready", ReplicationStatus: "Non-captain KV store member", BackupRestoreStatus: "Ready"}
+		if *info != want {
+			t.Errorf("info=%v; want %v", *info, want)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"status","content":{"current":{"backupRestoreStatus":"Ready","replicationStatus":"Non-captain KV store member","status":"ready"}}}]}`
+	splunkClientTester(t, "TestGetKVStoreInfo", 200, body, wantRequest, test)
+}
+
+func TestCreateKVStoreBackup(t *testing.T) {
+	body := strings.NewReader("archiveName=splunk-stack1-kvstore-20200601100000")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/kvstore/backup/create", body)
+	test := func(c SplunkClient) error {
+		return c.CreateKVStoreBackup("splunk-stack1-kvstore-20200601100000")
+	}
+	splunkClientTester(t, "TestCreateKVStoreBackup", 200, "", wantRequest, test)
+}
+
+func TestGetKVStoreCollections(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/servicesNS/-/-/storage/collections/config?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		collections, err := c.GetKVStoreCollections()
+		if err != nil {
+			return err
+		}
+		want := []KVStoreCollectionInfo{{Name: "lookups", App: "search"}, {Name: "notable", App: "SA-ThreatIntelligence"}}
+		if !reflect.DeepEqual(collections, want) {
+			t.Errorf("collections=%v; want %v", collections, want)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"lookups","acl":{"app":"search","owner":"nobody","sharing":"app"},"content":{"disabled":false}},{"name":"notable","acl":{"app":"SA-ThreatIntelligence","owner":"nobody","sharing":"global"},"content":{"disabled":false}}]}`
+	splunkClientTester(t, "TestGetKVStoreCollections", 200, body, wantRequest, test)
+}
+
+func TestCreateKVStoreCollection(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/servicesNS/nobody/search/storage/collections/config", nil)
+	test := func(c SplunkClient) error {
+		return c.CreateKVStoreCollection("search", "lookups")
+	}
+	splunkClientTester(t, "TestCreateKVStoreCollection", 201, "", wantRequest, test)
+}
+
+func TestGetKVStoreCollectionData(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/servicesNS/nobody/search/storage/collections/data/lookups?limit=1000&output_mode=json&skip=2000&sort=_key", nil)
+	test := func(c SplunkClient) error {
+		documents, err := c.GetKVStoreCollectionData("search", "lookups", 2000, 1000)
+		if err != nil {
+			return err
+		}
+		if len(documents) != 2 || string(documents[1]) != `{"_key":"b","host":"idx2"}` {
+			t.Errorf("documents=%s; want 2 documents", documents)
+		}
+		return nil
+	}
+	body := `[{"_key":"a","host":"idx1"},{"_key":"b","host":"idx2"}]`
+	splunkClientTester(t, "TestGetKVStoreCollectionData", 200, body, wantRequest, test)
+}
+
+func TestSaveKVStoreCollectionData(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/servicesNS/nobody/search/storage/collections/data/lookups/batch_save", nil)
+	test := func(c SplunkClient) error {
+		return c.SaveKVStoreCollectionData("search", "lookups", []json.RawMessage{json.RawMessage(`{"_key":"a","host":"idx1"}`)})
+	}
+	splunkClientTester(t, "TestSaveKVStoreCollectionData", 200, `["a"]`, wantRequest, test)
+}
+
+func TestDispatchSearch(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/search/jobs", nil)
+	test := func(c SplunkClient) error {
+		sid, err := c.DispatchSearch("| essinstall --ssl_enablement ignore")
+		if err != nil {
+			return err
+		}
+		if sid != "1600000000.42" {
+			t.Errorf("sid=%s; want %s", sid, "1600000000.42")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestDispatchSearch", 201, `{"sid":"1600000000.42"}`, wantRequest, test)
+
+	// test missing search ID
+	test = func(c SplunkClient) error {
+		_, err := c.DispatchSearch("| essinstall --ssl_enablement ignore")
+		if err == nil {
+			t.Errorf("DispatchSearch returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestDispatchSearch", 201, `{}`, wantRequest, test)
+}
+
+func TestGetSearchJobState(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/jobs/1600000000.42?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		state, err := c.GetSearchJobState("1600000000.42")
+		if err != nil {
+			return err
+		}
+		if state != "DONE" {
+			t.Errorf("state=%s; want %s", state, "DONE")
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"| essinstall --ssl_enablement ignore","content":{"dispatchState":"DONE","isDone":true}}]}`
+	splunkClientTester(t, "TestGetSearchJobState", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetSearchJobState("1600000000.42")
+		if err == nil {
+			t.Errorf("GetSearchJobState returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetSearchJobState", 200, `{"entry":[]}`, wantRequest, test)
+}
+
+func TestGetLicenseStacks(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/stacks?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		stacks, err := c.GetLicenseStacks()
+		if err != nil {
+			return err
+		}
+		s, ok := stacks["enterprise"]
+		if len(stacks) != 1 || !ok {
+			t.Errorf("stacks=%v; want enterprise", stacks)
+		}
+		if s.Name != "enterprise" || s.Quota != 10737418240 {
+			t.Errorf("stack want Name=enterprise Quota=10737418240: got %s %d", s.Name, s.Quota)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"enterprise","content":{"cle_active":1,"label":"Splunk Enterprise","quota":10737418240,"type":"enterprise"}}]}`
+	splunkClientTester(t, "TestGetLicenseStacks", 200, body, wantRequest, test)
+}
+
+func TestGetLicensePools(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/pools?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		pools, err := c.GetLicensePools()
+		if err != nil {
+			return err
+		}
+		p, ok := pools["auto_generated_pool_enterprise"]
+		if len(pools) != 1 || !ok {
+			t.Errorf("pools=%v; want auto_generated_pool_enterprise", pools)
+		}
+		if p.Name != "auto_generated_pool_enterprise" || p.StackID != "enterprise" || p.EffectiveQuota != 10737418240 || p.UsedBytes != 4096 {
+			t.Errorf("pool want Name=auto_generated_pool_enterprise StackID=enterprise EffectiveQuota=10737418240 UsedBytes=4096: got %v", p)
+		}
+		if len(p.Slaves) != 1 || p.Slaves[0] != "*" {
+			t.Errorf("pool want Slaves=[*]: got %v", p.Slaves)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"auto_generated_pool_enterprise","content":{"description":"auto_generated_pool_enterprise","effective_quota":10737418240,"is_unlimited":false,"quota":"MAX","slaves":["*"],"slaves_usage_bytes":{},"stack_id":"enterprise","used_bytes":4096}}]}`
+	splunkClientTester(t, "TestGetLicensePools", 200, body, wantRequest, test)
+
+	// test error response
+	test = func(c SplunkClient) error {
+		_, err := c.GetLicensePools()
+		if err == nil {
+			t.Errorf("GetLicensePools returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetLicensePools", 503, "", wantRequest, test)
+}
+
+func TestCreateLicensePool(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/licenser/pools", nil)
+	test := func(c SplunkClient) error {
+		return c.CreateLicensePool("pool1", "enterprise", "500MB", []string{"*"}, "test pool")
+	}
+	splunkClientTester(t, "TestCreateLicensePool", 201, "", wantRequest, test)
+}
+
+func TestUpdateLicensePool(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/licenser/pools/pool1", nil)
+	test := func(c SplunkClient) error {
+		return c.UpdateLicensePool("pool1", "MAX", []string{"guid1", "guid2"}, "")
+	}
+	splunkClientTester(t, "TestUpdateLicensePool", 200, "", wantRequest, test)
+}
+
+func TestUpdateAdminPassword(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/authentication/users/admin", nil)
+	test := func(c SplunkClient) error {
+		return c.UpdateAdminPassword("n3wp@ssw0rd")
+	}
+	splunkClientTester(t, "TestUpdateAdminPassword", 200, "", wantRequest, test)
+}
+
+func TestCheckCredentials(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/authentication/current-context?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		return c.CheckCredentials()
+	}
+	splunkClientTester(t, "TestCheckCredentials", 200, "", wantRequest, test)
+}
+
+func TestGetHTTPInputs(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/data/inputs/http?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		inputs, err := c.GetHTTPInputs()
+		if err != nil {
+			return err
+		}
+		input, ok := inputs["app1"]
+		if len(inputs) != 1 || !ok {
+			t.Errorf("inputs=%v; want app1", inputs)
+		}
+		if input.Name != "app1" || input.Token != "01234567-89AB-CDEF-0123-456789ABCDEF" || input.Index != "main" || input.Sourcetype != "app1:json" {
+			t.Errorf("input want Name=app1 Token=01234567-89AB-CDEF-0123-456789ABCDEF Index=main Sourcetype=app1:json: got %v", input)
+		}
+		if len(input.Indexes) != 2 || input.Indexes[0] != "main" || input.Indexes[1] != "app1" {
+			t.Errorf("input want Indexes=[main app1]: got %v", input.Indexes)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"http://app1","content":{"disabled":false,"index":"main","indexes":["main","app1"],"sourcetype":"app1:json","token":"01234567-89AB-CDEF-0123-456789ABCDEF","useACK":false}}]}`
+	splunkClientTester(t, "TestGetHTTPInputs", 200, body, wantRequest, test)
+
+	// test error response
+	test = func(c SplunkClient) error {
+		_, err := c.GetHTTPInputs()
+		if err == nil {
+			t.Errorf("GetHTTPInputs returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetHTTPInputs", 503, "", wantRequest, test)
+}
+
+func TestCreateHTTPInput(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/data/inputs/http", nil)
+	test := func(c SplunkClient) error {
+		return c.CreateHTTPInput("app1", "01234567-89AB-CDEF-0123-456789ABCDEF", "main", "app1:json", []string{"main", "app1"})
+	}
+	splunkClientTester(t, "TestCreateHTTPInput", 201, "", wantRequest, test)
+}
+
+func TestUpdateHTTPInput(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/data/inputs/http/app1", nil)
+	test := func(c SplunkClient) error {
+		return c.UpdateHTTPInput("app1", "app1", "", nil)
+	}
+	splunkClientTester(t, "TestUpdateHTTPInput", 200, "", wantRequest, test)
+}
+
+func TestDeleteHTTPInput(t *testing.T) {
+	wantRequest, _ := http.NewRequest("DELETE", "https://localhost:8089/services/data/inputs/http/app1", nil)
+	test := func(c SplunkClient) error {
+		return c.DeleteHTTPInput("app1")
+	}
+	splunkClientTester(t, "TestDeleteHTTPInput", 200, "", wantRequest, test)
+}
+
+// flakyHTTPClient fails with the given error or response code before returning 200 responses
+type flakyHTTPClient struct {
+	failures int
+	err      error
+	status   int
+	bodies   []string
+}
+
+func (c *flakyHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		c.bodies = append(c.bodies, string(data))
+	} else {
+		c.bodies = append(c.bodies, "")
+	}
+	status := 200
+	if len(c.bodies) <= c.failures {
+		if c.err != nil {
+			return nil, c.err
+		}
+		status = c.status
+	}
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestSplunkClientRetries(t *testing.T) {
+	connErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	test := func(flaky *flakyHTTPClient, wantRequests int, wantSleeps []time.Duration, wantErr bool) {
+		c := NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd")
+		c.Client = flaky
+		sleeps := []time.Duration{}
+		c.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+		err := c.UpdateAdminPassword("n3wp@ssw0rd")
+		if wantErr && err == nil {
+			t.Errorf("UpdateAdminPassword() with %d failures returned nil; want error", flaky.failures)
+		} else if !wantErr && err != nil {
+			t.Errorf("UpdateAdminPassword() with %d failures returned %v; want nil", flaky.failures, err)
+		}
+		if len(flaky.bodies) != wantRequests {
+			t.Errorf("UpdateAdminPassword() sent %d requests; want %d", len(flaky.bodies), wantRequests)
+		}
+		for n := range flaky.bodies {
+			if flaky.bodies[n] != flaky.bodies[0] || flaky.bodies[n] == "" {
+				t.Errorf("UpdateAdminPassword() request %d body = \"%s\"; want \"%s\"", n, flaky.bodies[n], flaky.bodies[0])
+			}
+		}
+		if len(sleeps) != len(wantSleeps) {
+			t.Errorf("UpdateAdminPassword() backoff = %v; want %v", sleeps, wantSleeps)
+			return
+		}
+		for n := range sleeps {
+			if sleeps[n] != wantSleeps[n] {
+				t.Errorf("UpdateAdminPassword() backoff = %v; want %v", sleeps, wantSleeps)
+			}
+		}
+	}
+
+	// transient errors are retried with exponential backoff
+	test(&flakyHTTPClient{}, 1, []time.Duration{}, false)
+	test(&flakyHTTPClient{failures: 2, err: connErr}, 3, []time.Duration{500 * time.Millisecond, time.Second}, false)
+	test(&flakyHTTPClient{failures: 1, status: 502}, 2, []time.Duration{500 * time.Millisecond}, false)
+	test(&flakyHTTPClient{failures: 3, err: connErr}, 3, []time.Duration{500 * time.Millisecond, time.Second}, true)
+
+	// other errors are not retried
+	test(&flakyHTTPClient{failures: 1, status: 503}, 1, []time.Duration{}, true)
+	test(&flakyHTTPClient{failures: 1, err: errors.New("test error")}, 1, []time.Duration{}, true)
+}
+
+func TestSplunkClientResponseError(t *testing.T) {
+	c := NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd")
+	c.Client = &flakyHTTPClient{failures: 1, status: 401}
+	err := c.CheckCredentials()
+	rspErr, ok := err.(*ResponseError)
+	if !ok {
+		t.Fatalf("CheckCredentials() returned %v; want *ResponseError", err)
+	}
+	if rspErr.StatusCode != 401 || rspErr.ExpectedStatus != 200 {
+		t.Errorf("CheckCredentials() StatusCode=%d ExpectedStatus=%d; want 401 200", rspErr.StatusCode, rspErr.ExpectedStatus)
+	}
+	want := "Response code=401 from https://localhost:8089/services/authentication/current-context?count=0&output_mode=json; want 200"
+	if err.Error() != want {
+		t.Errorf("CheckCredentials() err = %s; want %s", err.Error(), want)
+	}
+}
+
+func TestNewSplunkClientWithOptions(t *testing.T) {
+	options := DefaultSplunkClientOptions()
+	options.Timeout = 30 * time.Second
+	options.MaxRetries = 5
+	c := NewSplunkClientWithOptions("https://localhost:8089", "admin", "p@ssw0rd", options)
+	httpClient, ok := c.Client.(*http.Client)
+	if !ok || httpClient.Timeout != 30*time.Second {
+		t.Errorf("NewSplunkClientWithOptions() Client = %v; want timeout %s", c.Client, options.Timeout)
+	}
+	if c.MaxRetries != 5 || c.RetryBackoff != options.RetryBackoff || c.MaxRetryBackoff != options.MaxRetryBackoff {
+		t.Errorf("NewSplunkClientWithOptions() retries=%d backoff=%s max=%s; want %d %s %s", c.MaxRetries, c.RetryBackoff, c.MaxRetryBackoff, 5, options.RetryBackoff, options.MaxRetryBackoff)
+	}
+	if !httpClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("NewSplunkClientWithOptions() verifies certificates; want InsecureSkipVerify by default")
+	}
+}
+
+func TestGetConfValue(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/servicesNS/nobody/splunk-operator-conf-files/configs/conf-splunk_operator/conf_files?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		value, err := c.GetConfValue("splunk-operator-conf-files", "splunk_operator", "conf_files", "checksum")
+		if err != nil {
+			return err
+		}
+		if value != "abc123" {
+			t.Errorf("value=%s; want %s", value, "abc123")
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"conf_files","content":{"checksum":"abc123","disabled":false,"eai:acl":null}}]}`
+	splunkClientTester(t, "TestGetConfValue", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetConfValue("splunk-operator-conf-files", "splunk_operator", "conf_files", "checksum")
+		if err == nil {
+			t.Errorf("GetConfValue returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetConfValue", 200, `{"entry":[]}`, wantRequest, test)
+}
+
+func TestReloadConf(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/configs/conf-limits/_reload", nil)
+	test := func(c SplunkClient) error {
+		return c.ReloadConf("limits")
+	}
+	splunkClientTester(t, "TestReloadConf", 200, "", wantRequest, test)
+}
+
+func TestRefreshConfiguration(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/debug/refresh", nil)
+	test := func(c SplunkClient) error {
+		return c.RefreshConfiguration()
+	}
+	splunkClientTester(t, "TestRefreshConfiguration", 200, "", wantRequest, test)
+}
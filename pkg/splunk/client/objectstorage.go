@@ -0,0 +1,27 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "time"
+
+// ObjectStorageClient is used to list objects stored in an app repository, and to get URLs that Splunk Enterprise
+// instances can use to download them without credentials.
+type ObjectStorageClient interface {
+	// ListObjects returns all objects in a bucket (or container) that have names beginning with prefix
+	ListObjects(bucket, prefix string) ([]S3ObjectInfo, error)
+
+	// GetDownloadURL returns a URL that can be used to download an object without credentials until it expires
+	GetDownloadURL(bucket, key string, expires time.Duration) (string, error)
+}
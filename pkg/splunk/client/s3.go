@@ -0,0 +1,362 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// algorithm used to sign S3 requests
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+
+	// payload hash used for presigned URLs
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// SHA256 hash of an empty request body
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// time format used by AWS Signature Version 4
+	s3TimeFormat = "20060102T150405Z"
+
+	// date format used by AWS Signature Version 4 credential scopes
+	s3DateFormat = "20060102"
+)
+
+// S3Client is a simple object used to list, download and upload objects in S3 compatible storage.
+// Requests are signed using AWS Signature Version 4 when an access key is provided.
+type S3Client struct {
+	// http or https endpoint for the storage service (e.g. "https://s3-us-west-2.amazonaws.com")
+	Endpoint string
+
+	// region used to sign requests (e.g. "us-west-2")
+	Region string
+
+	// access key used to sign requests; requests are sent anonymously if empty
+	AccessKey string
+
+	// secret key used to sign requests
+	SecretKey string
+
+	// use virtual-hosted style URLs (https://bucket.endpoint/key) instead of path-style URLs (https://endpoint/bucket/key)
+	VirtualHostedStyle bool
+
+	// HTTP client used to process requests
+	Client SplunkHTTPClient
+}
+
+// NewS3Client returns a new S3Client object initialized with an endpoint, region and access keys.
+func NewS3Client(endpoint, region, accessKey, secretKey string) *S3Client {
+	return &S3Client{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// SetCABundle configures the HTTP client to verify the TLS certificate of the endpoint using PEM encoded CA certificates,
+// instead of the system's certificate pool. This is used for S3 compatible object stores with certificates issued by a
+// private CA. Nothing is changed for HTTP clients other than http.Client.
+func (c *S3Client) SetCABundle(caBundle []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return fmt.Errorf("No valid certificates found in CA bundle")
+	}
+	if httpClient, ok := c.Client.(*http.Client); ok {
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	return nil
+}
+
+// S3ObjectInfo represents an object stored in an S3 bucket.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_Object.html
+type S3ObjectInfo struct {
+	// Name of the object within its bucket
+	Key string `xml:"Key"`
+
+	// Entity tag of the object, which changes whenever its contents change
+	ETag string `xml:"ETag"`
+
+	// Time when the object was last modified
+	LastModified string `xml:"LastModified"`
+
+	// Size of the object in bytes
+	Size int64 `xml:"Size"`
+}
+
+// s3ListBucketResult is used to decode ListObjectsV2 responses.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html
+type s3ListBucketResult struct {
+	Contents              []S3ObjectInfo `xml:"Contents"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken"`
+}
+
+// ListObjects returns all objects in a bucket that have names beginning with prefix.
+func (c *S3Client) ListObjects(bucket, prefix string) ([]S3ObjectInfo, error) {
+	result := []S3ObjectInfo{}
+	continuationToken := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		baseURL, canonicalURI := c.getObjectURL(bucket, "")
+		endpoint := fmt.Sprintf("%s%s?%s", baseURL, canonicalURI, getS3CanonicalQuery(query))
+		request, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.signRequest(request, time.Now().UTC())
+
+		response, err := c.Client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		// the body is closed before requesting the next page, rather than deferred until all pages have been read
+		data, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != 200 {
+			return nil, fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+		}
+
+		var page s3ListBucketResult
+		if err = xml.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		result = append(result, page.Contents...)
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = page.NextContinuationToken
+	}
+
+	return result, nil
+}
+
+// GetPresignedURL returns a URL that can be used to download an object without credentials until it expires.
+// If no access key has been provided, it returns the object's URL without any signature.
+func (c *S3Client) GetPresignedURL(bucket, key string, expires time.Duration) string {
+	baseURL, canonicalURI := c.getObjectURL(bucket, key)
+	if c.AccessKey == "" {
+		return fmt.Sprintf("%s%s", baseURL, canonicalURI)
+	}
+
+	now := time.Now().UTC()
+	host := getS3Host(baseURL)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3SigningAlgorithm)
+	query.Set("X-Amz-Credential", fmt.Sprintf("%s/%s", c.AccessKey, c.getCredentialScope(now)))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int64(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalQuery := getS3CanonicalQuery(query)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalURI,
+		canonicalQuery,
+		fmt.Sprintf("host:%s\n", host),
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	signature := c.getSignature(now, canonicalRequest)
+	return fmt.Sprintf("%s%s?%s&X-Amz-Signature=%s", baseURL, canonicalURI, canonicalQuery, signature)
+}
+
+// GetDownloadURL returns a presigned URL that can be used to download an object without credentials until it expires.
+func (c *S3Client) GetDownloadURL(bucket, key string, expires time.Duration) (string, error) {
+	return c.GetPresignedURL(bucket, key, expires), nil
+}
+
+// PutObject uploads data to an object in a bucket, replacing the object if it already exists.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObject.html
+func (c *S3Client) PutObject(bucket, key string, data []byte) error {
+	baseURL, canonicalURI := c.getObjectURL(bucket, key)
+	request, err := http.NewRequest("PUT", baseURL+canonicalURI, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256.Sum256(data)
+	c.signRequestWithPayload(request, time.Now().UTC(), hex.EncodeToString(payloadHash[:]))
+
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+	}
+	return nil
+}
+
+// signRequest adds AWS Signature Version 4 authorization headers to a request with an empty body.
+// It does nothing if no access key has been provided.
+func (c *S3Client) signRequest(request *http.Request, now time.Time) {
+	c.signRequestWithPayload(request, now, s3EmptyPayloadHash)
+}
+
+// signRequestWithPayload adds AWS Signature Version 4 authorization headers to a request with a body that has the given
+// SHA256 hash. It does nothing if no access key has been provided.
+func (c *S3Client) signRequestWithPayload(request *http.Request, now time.Time, payloadHash string) {
+	if c.AccessKey == "" {
+		return
+	}
+
+	amzDate := now.Format(s3TimeFormat)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", request.URL.Host, payloadHash, amzDate)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	signature := c.getSignature(now, canonicalRequest)
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, c.AccessKey, c.getCredentialScope(now), signedHeaders, signature))
+}
+
+// getObjectURL returns the base URL and URI encoded path used to access an object (or a bucket, if key is empty),
+// using either path-style or virtual-hosted style addressing
+func (c *S3Client) getObjectURL(bucket, key string) (string, string) {
+	if c.VirtualHostedStyle {
+		if u, err := url.Parse(c.Endpoint); err == nil {
+			u.Host = fmt.Sprintf("%s.%s", bucket, u.Host)
+			return u.String(), "/" + getS3EscapedKey(key)
+		}
+	}
+	return c.Endpoint, getS3CanonicalURI(bucket, key)
+}
+
+// getCredentialScope returns the credential scope used to sign requests at a given time
+func (c *S3Client) getCredentialScope(now time.Time) string {
+	return getAWSCredentialScope(now, c.Region, "s3")
+}
+
+// getSignature returns the AWS Signature Version 4 signature for a canonical request
+func (c *S3Client) getSignature(now time.Time, canonicalRequest string) string {
+	return getAWSSignature(now, c.Region, "s3", c.SecretKey, canonicalRequest)
+}
+
+// getAWSCredentialScope returns the credential scope used to sign requests for an AWS service at a given time
+func getAWSCredentialScope(now time.Time, region, service string) string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(s3DateFormat), region, service)
+}
+
+// getAWSSignature returns the AWS Signature Version 4 signature for a canonical request sent to an AWS service
+func getAWSSignature(now time.Time, region, service, secretKey, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		now.Format(s3TimeFormat),
+		getAWSCredentialScope(now, region, service),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// getS3Host returns the host (and port, if any) of an endpoint
+func getS3Host(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return u.Host
+}
+
+// getS3CanonicalURI returns the URI encoded path of an object using path-style addressing
+func getS3CanonicalURI(bucket, key string) string {
+	return fmt.Sprintf("/%s/%s", s3Escape(bucket), getS3EscapedKey(key))
+}
+
+// getS3EscapedKey returns the URI encoded name of an object, without encoding the "/" separators
+func getS3EscapedKey(key string) string {
+	segments := strings.Split(key, "/")
+	for idx := range segments {
+		segments[idx] = s3Escape(segments[idx])
+	}
+	return strings.Join(segments, "/")
+}
+
+// getS3CanonicalQuery returns a URI encoded query string with parameters sorted by name
+func getS3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]string, len(keys))
+	for idx, k := range keys {
+		params[idx] = fmt.Sprintf("%s=%s", s3Escape(k), s3Escape(query.Get(k)))
+	}
+	return strings.Join(params, "&")
+}
+
+// s3Escape URI encodes a string as required by AWS Signature Version 4
+func s3Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
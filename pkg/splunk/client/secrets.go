@@ -0,0 +1,21 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+// SecretsProvider is used to retrieve secret values stored in an external secrets manager.
+type SecretsProvider interface {
+	// GetSecret returns the key/value pairs stored in a secret, along with an identifier of its current version
+	GetSecret(path string) (map[string]string, string, error)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SecretsManagerClient is a simple object used to read secrets from AWS Secrets Manager.
+// Requests are signed using AWS Signature Version 4.
+// See https://docs.aws.amazon.com/secretsmanager/latest/apireference/API_GetSecretValue.html
+type SecretsManagerClient struct {
+	// https endpoint for the service (defaults to "https://secretsmanager.<region>.amazonaws.com")
+	Endpoint string
+
+	// region used to sign requests (e.g. "us-west-2")
+	Region string
+
+	// access key used to sign requests
+	AccessKey string
+
+	// secret key used to sign requests
+	SecretKey string
+
+	// HTTP client used to process requests
+	Client SplunkHTTPClient
+}
+
+// NewSecretsManagerClient returns a new SecretsManagerClient object initialized with an endpoint, region and access keys.
+func NewSecretsManagerClient(endpoint, region, accessKey, secretKey string) *SecretsManagerClient {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	return &SecretsManagerClient{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// secretsManagerResponse is used to decode GetSecretValue responses
+type secretsManagerResponse struct {
+	SecretString string `json:"SecretString"`
+	VersionID    string `json:"VersionId"`
+}
+
+// GetSecret for SecretsManagerClient returns the current version of a secret, given its name or ARN. The secret string
+// must be a JSON object of key/value pairs.
+func (c *SecretsManagerClient) GetSecret(path string) (map[string]string, string, error) {
+	if c.AccessKey == "" {
+		return nil, "", fmt.Errorf("AWS access key is required")
+	}
+
+	body, _ := json.Marshal(map[string]string{"SecretId": path})
+	request, err := http.NewRequest("POST", c.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	c.signRequest(request, body, time.Now().UTC())
+
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		return nil, "", fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+	}
+
+	var result secretsManagerResponse
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, "", err
+	}
+	values := make(map[string]string)
+	if err = json.Unmarshal([]byte(result.SecretString), &values); err != nil {
+		return nil, "", fmt.Errorf("Secret %s is not a JSON object of key/value pairs: %v", path, err)
+	}
+	return values, result.VersionID, nil
+}
+
+// signRequest adds AWS Signature Version 4 authorization headers to a request with the given body.
+func (c *SecretsManagerClient) signRequest(request *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(s3TimeFormat)
+	request.Header.Set("X-Amz-Date", amzDate)
+
+	payloadHash := sha256.Sum256(body)
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-date:%s\nx-amz-target:%s\n",
+		request.Header.Get("Content-Type"), request.URL.Host, amzDate, request.Header.Get("X-Amz-Target"))
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		"/",
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	signature := getAWSSignature(now, c.Region, "secretsmanager", c.SecretKey, canonicalRequest)
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, c.AccessKey, getAWSCredentialScope(now, c.Region, "secretsmanager"), signedHeaders, signature))
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultServiceAccountTokenFile is the file containing the Kubernetes service account token used to log in to Vault
+var VaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultClient is a simple object used to read secrets from the version 2 key/value secrets engine of HashiCorp Vault.
+// See https://www.vaultproject.io/api-docs/secret/kv/kv-v2
+type VaultClient struct {
+	// http or https address of the Vault server (e.g. "https://vault.example.com:8200")
+	Endpoint string
+
+	// token used to authenticate requests; if empty, a token is obtained by logging in using Role
+	Token string
+
+	// role used to log in using the Kubernetes auth method and the operator's service account token
+	Role string
+
+	// HTTP client used to process requests
+	Client SplunkHTTPClient
+}
+
+// NewVaultClient returns a new VaultClient object initialized with an endpoint, and either a token or a Kubernetes auth role.
+func NewVaultClient(endpoint, token, role string) *VaultClient {
+	return &VaultClient{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Token:    token,
+		Role:     role,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// vaultResponse is used to decode responses from the Vault API
+type vaultResponse struct {
+	Data struct {
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+// do sends a request to the Vault API and decodes its response
+func (c *VaultClient) do(request *http.Request, obj *vaultResponse) error {
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		return fmt.Errorf("Response code=%d from %s; want %d", response.StatusCode, request.URL, 200)
+	}
+	return json.Unmarshal(data, obj)
+}
+
+// login obtains a token using the Kubernetes auth method, if one has not been provided
+func (c *VaultClient) login() error {
+	if c.Token != "" {
+		return nil
+	}
+	if c.Role == "" {
+		return fmt.Errorf("Vault token or role is required")
+	}
+	jwt, err := ioutil.ReadFile(VaultServiceAccountTokenFile)
+	if err != nil {
+		return fmt.Errorf("Unable to read service account token: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"role": c.Role, "jwt": string(jwt)})
+	request, err := http.NewRequest("POST", c.Endpoint+"/v1/auth/kubernetes/login", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	var result vaultResponse
+	if err = c.do(request, &result); err != nil {
+		return err
+	}
+	if result.Auth.ClientToken == "" {
+		return fmt.Errorf("Vault login for role %s did not return a token", c.Role)
+	}
+	c.Token = result.Auth.ClientToken
+	return nil
+}
+
+// GetSecret for VaultClient returns the latest version of a secret, given its path including the mount path of the
+// secrets engine (e.g. "secret/splunk/example").
+func (c *VaultClient) GetSecret(path string) (map[string]string, string, error) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("Vault secret path must include a mount path; value=\"%s\"", path)
+	}
+	if err := c.login(); err != nil {
+		return nil, "", err
+	}
+
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s/data/%s", c.Endpoint, parts[0], parts[1]), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("X-Vault-Token", c.Token)
+	var result vaultResponse
+	if err = c.do(request, &result); err != nil {
+		return nil, "", err
+	}
+	return result.Data.Data, fmt.Sprintf("%d", result.Data.Metadata.Version), nil
+}
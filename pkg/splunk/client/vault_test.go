@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestVaultGetSecret(t *testing.T) {
+	mockClient := &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://vault.test:8200/v1/secret/data/splunk/example",
+		Status: 200,
+		Body:   `{"data":{"data":{"password":"s3cr3t","hec_token":"token1"},"metadata":{"version":3}}}`,
+	})
+	c := NewVaultClient("https://vault.test:8200/", "root", "")
+	c.Client = mockClient
+
+	values, version, err := c.GetSecret("secret/splunk/example")
+	if err != nil {
+		t.Errorf("GetSecret err = %v", err)
+	}
+	mockClient.CheckRequests(t, "TestVaultGetSecret")
+	want := map[string]string{"password": "s3cr3t", "hec_token": "token1"}
+	if !reflect.DeepEqual(values, want) || version != "3" {
+		t.Errorf("GetSecret() = %v, %s; want %v, 3", values, version, want)
+	}
+	if got := mockClient.GotRequests[0].Header.Get("X-Vault-Token"); got != "root" {
+		t.Errorf("GetSecret() X-Vault-Token=\"%s\"; want \"%s\"", got, "root")
+	}
+
+	// errors are returned for unexpected status codes and paths without a mount
+	mockClient = &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://vault.test:8200/v1/secret/data/splunk/example",
+		Status: 403,
+	})
+	c.Client = mockClient
+	if _, _, err = c.GetSecret("secret/splunk/example"); err == nil {
+		t.Errorf("GetSecret() returned nil; want error for status 403")
+	}
+	if _, _, err = c.GetSecret("example"); err == nil {
+		t.Errorf("GetSecret() returned nil; want error for path without mount")
+	}
+}
+
+func TestVaultLogin(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatalf("Unable to create service account token file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("jwt1")
+	tokenFile.Close()
+	savedTokenFile := VaultServiceAccountTokenFile
+	VaultServiceAccountTokenFile = tokenFile.Name()
+	defer func() { VaultServiceAccountTokenFile = savedTokenFile }()
+
+	mockClient := &spltest.MockHTTPClient{}
+	mockClient.AddHandlers(
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://vault.test:8200/v1/auth/kubernetes/login",
+			Status: 200,
+			Body:   `{"auth":{"client_token":"token2"}}`,
+		},
+		spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://vault.test:8200/v1/secret/data/splunk",
+			Status: 200,
+			Body:   `{"data":{"data":{"password":"s3cr3t"},"metadata":{"version":1}}}`,
+		},
+	)
+	c := NewVaultClient("https://vault.test:8200", "", "splunk-operator")
+	c.Client = mockClient
+	if _, _, err = c.GetSecret("secret/splunk"); err != nil {
+		t.Errorf("GetSecret err = %v", err)
+	}
+	mockClient.CheckRequests(t, "TestVaultLogin")
+	if got := mockClient.GotRequests[1].Header.Get("X-Vault-Token"); got != "token2" {
+		t.Errorf("GetSecret() X-Vault-Token=\"%s\"; want \"%s\"", got, "token2")
+	}
+
+	// a token or role is required
+	c = NewVaultClient("https://vault.test:8200", "", "")
+	if _, _, err = c.GetSecret("secret/splunk"); err == nil {
+		t.Errorf("GetSecret() returned nil; want error without token or role")
+	}
+}
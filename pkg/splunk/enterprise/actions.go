@@ -0,0 +1,124 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// RestartAnnotation is used to request a rolling restart of all pods managed by a custom resource. Like other annotations
+	// of custom resources, it is copied to pod templates, so setting it to a new value (such as the current time) recycles
+	// pods one at a time using the same process as any other update.
+	RestartAnnotation = "enterprise.splunk.com/restarted-at"
+
+	// BundlePushAnnotation is used to request that a ClusterMaster, or a SearchHeadCluster deployer, pushes its
+	// configuration bundle again. The bundle is pushed whenever its value changes.
+	BundlePushAnnotation = resources.BundlePushAnnotation
+
+	// DryRunAnnotation is used to request dry runs of reconciles for a custom resource. While it is "true", the changes
+	// that the operator would make are reported in status instead of being made.
+	DryRunAnnotation = resources.DryRunAnnotation
+
+	// PausedAnnotation is used to suspend reconciles of a custom resource. While it is "true", the operator leaves the
+	// custom resource and everything it owns unchanged, until it is removed.
+	PausedAnnotation = resources.PausedAnnotation
+
+	// DeletionProtectionAnnotation is used to block the deletion of a custom resource. While it is "true", deletion of
+	// the custom resource waits (without cleaning up anything) until it is removed.
+	DeletionProtectionAnnotation = resources.DeletionProtectionAnnotation
+
+	// DriftPolicyAnnotation is used to choose how the operator handles changes made outside of the operator to the
+	// StatefulSets, Services and ConfigMaps that a custom resource owns. They are reverted unless it is "warn", in which
+	// case they are only reported.
+	DriftPolicyAnnotation = resources.DriftPolicyAnnotation
+
+	// DriftPolicyWarn is the value of the drift-policy annotation used to report drift without reverting it
+	DriftPolicyWarn = "warn"
+
+	// AdoptAnnotation is used to migrate existing deployments of Splunk Enterprise. While it is "true", a custom resource
+	// takes ownership of existing StatefulSets (and their pods and PersistentVolumeClaims) that use the same names as
+	// the ones it would create, instead of failing to reconcile them.
+	AdoptAnnotation = resources.AdoptAnnotation
+
+	// FailoverAnnotation is used to request a controlled failover of a ClusterReplication, which makes the indexer cluster
+	// that is currently inactive take over from the active one. A failover is requested whenever its value changes.
+	FailoverAnnotation = "enterprise.splunk.com/failover-at"
+)
+
+// GetRestartRequest returns the value of the restarted-at annotation of a custom resource, or an empty string if it has none
+func GetRestartRequest(cr enterprisev1.MetaObject) string {
+	return cr.GetObjectMeta().GetAnnotations()[RestartAnnotation]
+}
+
+// IsRestartRequested returns true if a custom resource has a restarted-at annotation with a value other than the one most
+// recently handled
+func IsRestartRequested(cr enterprisev1.MetaObject, handled string) bool {
+	request := GetRestartRequest(cr)
+	return request != "" && request != handled
+}
+
+// GetBundlePushRequest returns the value of the bundle-push annotation of a custom resource, or an empty string if it has none
+func GetBundlePushRequest(cr enterprisev1.MetaObject) string {
+	return cr.GetObjectMeta().GetAnnotations()[BundlePushAnnotation]
+}
+
+// IsBundlePushRequested returns true if a custom resource has a bundle-push annotation with a value other than the one
+// most recently handled
+func IsBundlePushRequested(cr enterprisev1.MetaObject, handled string) bool {
+	request := GetBundlePushRequest(cr)
+	return request != "" && request != handled
+}
+
+// GetFailoverRequest returns the value of the failover-at annotation of a custom resource, or an empty string if it has none
+func GetFailoverRequest(cr enterprisev1.MetaObject) string {
+	return cr.GetObjectMeta().GetAnnotations()[FailoverAnnotation]
+}
+
+// IsFailoverRequested returns true if a custom resource has a failover-at annotation with a value other than the one most
+// recently handled
+func IsFailoverRequested(cr enterprisev1.MetaObject, handled string) bool {
+	request := GetFailoverRequest(cr)
+	return request != "" && request != handled
+}
+
+// IsDryRun returns true if dry runs have been requested for a custom resource, using its dry-run annotation
+func IsDryRun(cr enterprisev1.MetaObject) bool {
+	return cr.GetObjectMeta().GetAnnotations()[DryRunAnnotation] == "true"
+}
+
+// IsPaused returns true if reconciles of a custom resource have been suspended, using its paused annotation
+func IsPaused(cr enterprisev1.MetaObject) bool {
+	return cr.GetObjectMeta().GetAnnotations()[PausedAnnotation] == "true"
+}
+
+// IsDriftWarnOnly returns true if drift of the resources owned by a custom resource is only reported, using its
+// drift-policy annotation
+func IsDriftWarnOnly(meta metav1.Object) bool {
+	return meta.GetAnnotations()[DriftPolicyAnnotation] == DriftPolicyWarn
+}
+
+// IsAdoptionRequested returns true if a custom resource may take ownership of existing resources, using its adopt annotation
+func IsAdoptionRequested(meta metav1.Object) bool {
+	return meta.GetAnnotations()[AdoptAnnotation] == "true"
+}
+
+// IsDeletionProtected returns true if deletion of a custom resource has been blocked, using its deletion-protection annotation
+func IsDeletionProtected(cr enterprisev1.MetaObject) bool {
+	return cr.GetObjectMeta().GetAnnotations()[DeletionProtectionAnnotation] == "true"
+}
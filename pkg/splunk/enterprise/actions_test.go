@@ -0,0 +1,172 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestIsBundlePushRequested(t *testing.T) {
+	cr := enterprisev1.ClusterMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(handled string, want bool) {
+		if got := IsBundlePushRequested(&cr, handled); got != want {
+			t.Errorf("IsBundlePushRequested(%s) = %t; want %t", handled, got, want)
+		}
+	}
+
+	test("", false)
+	test("2020-06-01T10:00:00Z", false)
+	cr.ObjectMeta.Annotations = map[string]string{BundlePushAnnotation: "2020-06-01T10:00:00Z"}
+	test("", true)
+	test("2020-06-01T10:00:00Z", false)
+	test("2020-05-01T10:00:00Z", true)
+	if got := GetBundlePushRequest(&cr); got != "2020-06-01T10:00:00Z" {
+		t.Errorf("GetBundlePushRequest() = %s; want %s", got, "2020-06-01T10:00:00Z")
+	}
+}
+
+func TestIsRestartRequested(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(handled string, want bool) {
+		if got := IsRestartRequested(&cr, handled); got != want {
+			t.Errorf("IsRestartRequested(%s) = %t; want %t", handled, got, want)
+		}
+	}
+
+	test("", false)
+	cr.ObjectMeta.Annotations = map[string]string{RestartAnnotation: "2020-06-01T10:00:00Z"}
+	test("", true)
+	test("2020-06-01T10:00:00Z", false)
+	test("2020-05-01T10:00:00Z", true)
+	if got := GetRestartRequest(&cr); got != "2020-06-01T10:00:00Z" {
+		t.Errorf("GetRestartRequest() = %s; want %s", got, "2020-06-01T10:00:00Z")
+	}
+}
+
+func TestIsDryRun(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(value string, want bool) {
+		cr.ObjectMeta.Annotations = map[string]string{DryRunAnnotation: value}
+		if got := IsDryRun(&cr); got != want {
+			t.Errorf("IsDryRun(%s) = %t; want %t", value, got, want)
+		}
+	}
+
+	test("", false)
+	test("false", false)
+	test("yes", false)
+	test("true", true)
+}
+
+func TestIsPaused(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	test := func(value string, want bool) {
+		cr.ObjectMeta.Annotations = map[string]string{PausedAnnotation: value}
+		if got := IsPaused(&cr); got != want {
+			t.Errorf("IsPaused(%s) = %t; want %t", value, got, want)
+		}
+	}
+
+	test("", false)
+	test("false", false)
+	test("true", true)
+}
+
+func TestIsDeletionProtected(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(value string, want bool) {
+		cr.ObjectMeta.Annotations = map[string]string{DeletionProtectionAnnotation: value}
+		if got := IsDeletionProtected(&cr); got != want {
+			t.Errorf("IsDeletionProtected(%s) = %t; want %t", value, got, want)
+		}
+	}
+
+	test("", false)
+	test("false", false)
+	test("true", true)
+}
+
+func TestIsDriftWarnOnly(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(value string, want bool) {
+		cr.ObjectMeta.Annotations = map[string]string{DriftPolicyAnnotation: value}
+		if got := IsDriftWarnOnly(cr.GetObjectMeta()); got != want {
+			t.Errorf("IsDriftWarnOnly(%s) = %t; want %t", value, got, want)
+		}
+	}
+
+	test("", false)
+	test("correct", false)
+	test("warn", true)
+}
+
+func TestIsAdoptionRequested(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(value string, want bool) {
+		cr.ObjectMeta.Annotations = map[string]string{AdoptAnnotation: value}
+		if got := IsAdoptionRequested(cr.GetObjectMeta()); got != want {
+			t.Errorf("IsAdoptionRequested(%s) = %t; want %t", value, got, want)
+		}
+	}
+
+	test("", false)
+	test("false", false)
+	test("true", true)
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// AppsChecksumAnnotation is used to record a checksum of the app package versions in pod templates and app repository Secrets
+	AppsChecksumAnnotation = "enterprise.splunk.com/apps-checksum"
+
+	// AppsExpirationAnnotation is used to record when the download URLs contained in an app repository Secret expire
+	AppsExpirationAnnotation = "enterprise.splunk.com/apps-expiration"
+
+	// AppRepoProviderS3 is used for app repositories stored in S3 compatible buckets
+	AppRepoProviderS3 = "s3"
+
+	// AppRepoProviderAzure is used for app repositories stored in Azure Blob Storage containers
+	AppRepoProviderAzure = "azure"
+
+	// default endpoint used to access app repository buckets
+	defaultAppRepoEndpoint = "https://s3.amazonaws.com"
+
+	// template used for the default endpoint of app repository storage accounts in Azure Blob Storage
+	defaultAppRepoAzureEndpointTemplateStr = "https://%s.blob.core.windows.net"
+
+	// default region used to sign app repository requests
+	defaultAppRepoRegion = "us-east-1"
+
+	// default interval between checks for new versions of app packages
+	defaultAppRepoPollInterval = "5m"
+
+	// maximum interval between checks for new versions of app packages, so that download URLs are renewed before they expire
+	maxAppRepoPollInterval = 24 * time.Hour
+
+	// directory used to mount app repository configuration
+	appsMountPath = "/mnt/splunk-apps"
+
+	// default.yml file used to have splunk-ansible download and install app packages
+	appsDefaultsPath = appsMountPath + "/default.yml"
+
+	// AppTypeEnterpriseSecurity is used for app sources containing Splunk Enterprise Security
+	AppTypeEnterpriseSecurity = "enterpriseSecurity"
+
+	// AppTypeITSI is used for app sources containing Splunk IT Service Intelligence
+	AppTypeITSI = "itsi"
+
+	// default initial delay of liveness probes for instances with premium apps, which take much longer to start
+	premiumAppLivenessProbeInitialDelaySeconds = 1800
+
+	// default timeout of liveness probes for instances with premium apps
+	premiumAppLivenessProbeTimeoutSeconds = 60
+)
+
+// IsAppRepoConfigured returns true if one or more app sources have been configured for an app repository
+func IsAppRepoConfigured(spec *enterprisev1.AppRepoSpec) bool {
+	return len(spec.AppSources) > 0
+}
+
+// IsAppPackage returns true if an object name refers to a Splunk app package
+func IsAppPackage(key string) bool {
+	return strings.HasSuffix(key, ".tgz") || strings.HasSuffix(key, ".tar.gz") || strings.HasSuffix(key, ".spl")
+}
+
+// isAppInstaller returns true for instance types that download and install app packages. Standalone instances install
+// them locally, while deployers and cluster masters push them to search head cluster members and indexer cluster peers,
+// and deployment servers install them as deployment apps for their clients.
+func isAppInstaller(instanceType InstanceType) bool {
+	return instanceType == SplunkStandalone || instanceType == SplunkDeployer || instanceType == SplunkClusterMaster || instanceType == SplunkDeploymentServer
+}
+
+// validateAppRepoSpec checks validity and makes default updates to an AppRepoSpec, and returns error if something is wrong.
+func validateAppRepoSpec(spec *enterprisev1.AppRepoSpec) error {
+	if !IsAppRepoConfigured(spec) {
+		return nil
+	}
+
+	if spec.Provider == "" {
+		spec.Provider = AppRepoProviderS3
+	}
+	switch spec.Provider {
+	case AppRepoProviderS3:
+		if spec.Endpoint == "" {
+			spec.Endpoint = defaultAppRepoEndpoint
+		}
+		if spec.Region == "" {
+			spec.Region = defaultAppRepoRegion
+		}
+		if err := validateS3URLStyle(spec.URLStyle); err != nil {
+			return fmt.Errorf("App repository %v", err)
+		}
+	case AppRepoProviderAzure:
+		if spec.CASecretRef != "" || spec.URLStyle != "" {
+			return fmt.Errorf("App repository caSecretRef and urlStyle are only supported by the \"%s\" provider", AppRepoProviderS3)
+		}
+		if spec.StorageAccount == "" {
+			return fmt.Errorf("App repository storageAccount must not be empty for the \"%s\" provider", AppRepoProviderAzure)
+		}
+		if spec.Endpoint == "" {
+			spec.Endpoint = fmt.Sprintf(defaultAppRepoAzureEndpointTemplateStr, spec.StorageAccount)
+		}
+	default:
+		return fmt.Errorf("App repository provider must be either \"%s\" or \"%s\"; value=\"%s\"", AppRepoProviderS3, AppRepoProviderAzure, spec.Provider)
+	}
+
+	sources := make(map[string]bool)
+	for _, s := range spec.AppSources {
+		if s.Name == "" {
+			return fmt.Errorf("App source name must not be empty")
+		}
+		if sources[s.Name] {
+			return fmt.Errorf("App source names must be unique; value=\"%s\"", s.Name)
+		}
+		if s.Bucket == "" {
+			return fmt.Errorf("App source bucket must not be empty; source=\"%s\"", s.Name)
+		}
+		if s.AppType != "" && s.AppType != AppTypeEnterpriseSecurity && s.AppType != AppTypeITSI {
+			return fmt.Errorf("App source appType must be either \"%s\" or \"%s\"; source=\"%s\" value=\"%s\"", AppTypeEnterpriseSecurity, AppTypeITSI, s.Name, s.AppType)
+		}
+		sources[s.Name] = true
+	}
+
+	if spec.PollInterval == "" {
+		spec.PollInterval = defaultAppRepoPollInterval
+	}
+	d, err := time.ParseDuration(spec.PollInterval)
+	if err != nil {
+		return fmt.Errorf("App repository pollInterval is invalid; value=\"%s\": %v", spec.PollInterval, err)
+	}
+	if d < time.Minute || d > maxAppRepoPollInterval {
+		return fmt.Errorf("App repository pollInterval must be between %s and %s; value=\"%s\"", time.Minute, maxAppRepoPollInterval, spec.PollInterval)
+	}
+
+	return nil
+}
+
+// GetAppRepoPollInterval returns the interval between checks for new versions of app packages in an app repository
+func GetAppRepoPollInterval(spec *enterprisev1.AppRepoSpec) time.Duration {
+	d, err := time.ParseDuration(spec.PollInterval)
+	if err != nil {
+		d, _ = time.ParseDuration(defaultAppRepoPollInterval)
+	}
+	return d
+}
+
+// HasPremiumApps returns true if any app source of an app repository contains a premium app
+func HasPremiumApps(spec *enterprisev1.AppRepoSpec) bool {
+	for _, s := range spec.AppSources {
+		if s.AppType != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNoPremiumApps returns error if any app source of an app repository contains a premium app. Premium apps are only
+// supported by search tier resources, which run their post-install steps.
+func validateNoPremiumApps(spec *enterprisev1.AppRepoSpec) error {
+	for _, s := range spec.AppSources {
+		if s.AppType != "" {
+			return fmt.Errorf("App source appType is only supported by Standalone and SearchHeadCluster resources; source=\"%s\"", s.Name)
+		}
+	}
+	return nil
+}
+
+// GetAppSourceType returns the type of premium app contained in an app source, or an empty string for other apps
+func GetAppSourceType(spec *enterprisev1.AppRepoSpec, sourceName string) string {
+	for _, s := range spec.AppSources {
+		if s.Name == sourceName {
+			return s.AppType
+		}
+	}
+	return ""
+}
+
+// GetPremiumAppPostInstallSearch returns the search command that must be run after installing (or upgrading) a premium app
+// on an instance type, or an empty string if none is required. Splunk Enterprise Security is set up using essinstall, which
+// stages it for search head cluster members when run on a deployer. Since the operator manages TLS for Splunk Web, SSL
+// enablement is ignored. Splunk IT Service Intelligence does not require any post-install steps.
+// See https://docs.splunk.com/Documentation/ES/latest/Install/InstallEnterpriseSecurity
+func GetPremiumAppPostInstallSearch(appType string, instanceType InstanceType) string {
+	if appType != AppTypeEnterpriseSecurity {
+		return ""
+	}
+	search := "| essinstall --ssl_enablement ignore"
+	if instanceType == SplunkDeployer {
+		search += " --deployment_type shc_deployer"
+	}
+	return search
+}
+
+// getPremiumAppDefaultResources returns the default resource requests and limits used for instances with premium apps
+func getPremiumAppDefaultResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("16"),
+			corev1.ResourceMemory: resource.MustParse("32Gi"),
+		},
+	}
+}
+
+// applyPremiumAppProbeDefaults increases the default initial delay and timeout of a liveness probe for instances with premium
+// apps, unless they are set in its ProbeSpec
+func applyPremiumAppProbeDefaults(probe *corev1.Probe, spec *enterprisev1.ProbeSpec) {
+	if spec.InitialDelaySeconds == 0 {
+		probe.InitialDelaySeconds = premiumAppLivenessProbeInitialDelaySeconds
+	}
+	if spec.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = premiumAppLivenessProbeTimeoutSeconds
+	}
+}
+
+// GetAppsChecksum returns a checksum of the app package versions installed from an app repository.
+func GetAppsChecksum(apps []enterprisev1.AppStatus) string {
+	h := sha256.New()
+	for _, app := range apps {
+		fmt.Fprintf(h, "%s\n%s\n%s\n", app.Source, app.Key, app.Version)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SetAppsChecksum annotates a pod template with an app package versions checksum, so that pods are recycled when it changes.
+func SetAppsChecksum(podTemplateSpec *corev1.PodTemplateSpec, checksum string) {
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[AppsChecksumAnnotation] = checksum
+}
+
+// getAppsDefaults returns the contents of a default.yml file used to install app packages from a list of download URLs.
+func getAppsDefaults(urls []string) string {
+	var sb strings.Builder
+	sb.WriteString("splunk:\n  apps_location:\n")
+	for _, u := range urls {
+		fmt.Fprintf(&sb, "    - \"%s\"\n", u)
+	}
+	return sb.String()
+}
+
+// GetAppsSecret returns a Kubernetes Secret containing a default.yml file used to install app packages from a list of
+// download URLs. It is annotated with a checksum of the app package versions and the time when the URLs expire.
+func GetAppsSecret(cr enterprisev1.MetaObject, instanceType InstanceType, urls []string, checksum string, expiration time.Time) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkAppsName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+			Annotations: map[string]string{
+				AppsChecksumAnnotation:   checksum,
+				AppsExpirationAnnotation: expiration.UTC().Format(time.RFC3339),
+			},
+		},
+		Data: map[string][]byte{
+			"default.yml": []byte(getAppsDefaults(urls)),
+		},
+	}
+}
+
+// addAppsToPodTemplate mounts app repository configuration for standalone instances, deployers, cluster masters and deployment servers.
+func addAppsToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.AppRepoSpec, instanceType InstanceType) {
+	if !isAppInstaller(instanceType) || !IsAppRepoConfigured(spec) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	addSplunkVolumeToTemplate(podTemplateSpec, "apps", corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{
+			SecretName:  GetSplunkAppsName(cr.GetIdentifier(), instanceType),
+			DefaultMode: &secretVolDefaultMode,
+		},
+	})
+}
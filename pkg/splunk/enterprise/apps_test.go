@@ -0,0 +1,232 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateAppRepoSpec(t *testing.T) {
+	test := func(spec enterprisev1.AppRepoSpec, wantErr bool) {
+		err := validateAppRepoSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateAppRepoSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateAppRepoSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	source := enterprisev1.AppSourceSpec{Name: "security", Bucket: "apps", Prefix: "security/"}
+	test(enterprisev1.AppRepoSpec{}, false)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{source}}, false)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{source, source}}, true)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Bucket: "apps"}}}, true)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Name: "security"}}}, true)
+	test(enterprisev1.AppRepoSpec{Provider: "azure", StorageAccount: "myaccount", AppSources: []enterprisev1.AppSourceSpec{source}}, false)
+	test(enterprisev1.AppRepoSpec{Provider: "azure", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{Provider: "gcs", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{Endpoint: "https://minio.test:9000", CASecretRef: "minio-ca", URLStyle: "path", AppSources: []enterprisev1.AppSourceSpec{source}}, false)
+	test(enterprisev1.AppRepoSpec{URLStyle: "host", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{Provider: "azure", StorageAccount: "myaccount", CASecretRef: "minio-ca", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Name: "es", Bucket: "apps", AppType: "enterpriseSecurity"}}}, false)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Name: "itsi", Bucket: "apps", AppType: "itsi"}}}, false)
+	test(enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Name: "uba", Bucket: "apps", AppType: "uba"}}}, true)
+	test(enterprisev1.AppRepoSpec{PollInterval: "1h", AppSources: []enterprisev1.AppSourceSpec{source}}, false)
+	test(enterprisev1.AppRepoSpec{PollInterval: "hourly", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{PollInterval: "10s", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+	test(enterprisev1.AppRepoSpec{PollInterval: "168h", AppSources: []enterprisev1.AppSourceSpec{source}}, true)
+
+	// defaults are only set when app sources are configured
+	spec := enterprisev1.AppRepoSpec{}
+	validateAppRepoSpec(&spec)
+	if spec.Endpoint != "" || spec.Region != "" {
+		t.Errorf("validateAppRepoSpec() set defaults without app sources: %v", spec)
+	}
+	spec.AppSources = []enterprisev1.AppSourceSpec{source}
+	validateAppRepoSpec(&spec)
+	if spec.Endpoint != "https://s3.amazonaws.com" || spec.Region != "us-east-1" {
+		t.Errorf("validateAppRepoSpec() endpoint=%s region=%s; want https://s3.amazonaws.com us-east-1", spec.Endpoint, spec.Region)
+	}
+	if spec.Provider != AppRepoProviderS3 {
+		t.Errorf("validateAppRepoSpec() provider=%s; want %s", spec.Provider, AppRepoProviderS3)
+	}
+	if spec.PollInterval != "5m" || GetAppRepoPollInterval(&spec) != 5*time.Minute {
+		t.Errorf("validateAppRepoSpec() pollInterval=%s; want 5m", spec.PollInterval)
+	}
+
+	// azure endpoint defaults to the storage account
+	spec = enterprisev1.AppRepoSpec{Provider: "azure", StorageAccount: "myaccount", AppSources: []enterprisev1.AppSourceSpec{source}}
+	validateAppRepoSpec(&spec)
+	if spec.Endpoint != "https://myaccount.blob.core.windows.net" || spec.Region != "" {
+		t.Errorf("validateAppRepoSpec() endpoint=%s region=%s; want https://myaccount.blob.core.windows.net and no region", spec.Endpoint, spec.Region)
+	}
+}
+
+func TestPremiumApps(t *testing.T) {
+	spec := enterprisev1.AppRepoSpec{AppSources: []enterprisev1.AppSourceSpec{{Name: "security", Bucket: "apps"}}}
+	if HasPremiumApps(&spec) {
+		t.Errorf("HasPremiumApps() = true; want false")
+	}
+	if err := validateNoPremiumApps(&spec); err != nil {
+		t.Errorf("validateNoPremiumApps() returned %v; want nil", err)
+	}
+	spec.AppSources = append(spec.AppSources, enterprisev1.AppSourceSpec{Name: "es", Bucket: "apps", AppType: AppTypeEnterpriseSecurity})
+	if !HasPremiumApps(&spec) {
+		t.Errorf("HasPremiumApps() = false; want true")
+	}
+	if err := validateNoPremiumApps(&spec); err == nil {
+		t.Errorf("validateNoPremiumApps() returned nil; want error")
+	}
+	if got := GetAppSourceType(&spec, "es"); got != AppTypeEnterpriseSecurity {
+		t.Errorf("GetAppSourceType(es) = %s; want %s", got, AppTypeEnterpriseSecurity)
+	}
+	if got := GetAppSourceType(&spec, "security"); got != "" {
+		t.Errorf("GetAppSourceType(security) = %s; want empty", got)
+	}
+
+	test := func(appType string, instanceType InstanceType, want string) {
+		if got := GetPremiumAppPostInstallSearch(appType, instanceType); got != want {
+			t.Errorf("GetPremiumAppPostInstallSearch(%s, %s) = %s; want %s", appType, instanceType, got, want)
+		}
+	}
+	test(AppTypeEnterpriseSecurity, SplunkStandalone, "| essinstall --ssl_enablement ignore")
+	test(AppTypeEnterpriseSecurity, SplunkDeployer, "| essinstall --ssl_enablement ignore --deployment_type shc_deployer")
+	test(AppTypeITSI, SplunkStandalone, "")
+	test("", SplunkStandalone, "")
+
+	// premium apps increase default resources and liveness probe timings, unless they are set
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.AppRepo = spec
+	cr.Spec.LivenessProbe = enterprisev1.ProbeSpec{TimeoutSeconds: 45}
+	if err := ValidateStandaloneSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+	if got := cr.Spec.Resources.Limits[corev1.ResourceMemory]; got.String() != "32Gi" {
+		t.Errorf("ValidateStandaloneSpec() memory limit = %s; want 32Gi", got.String())
+	}
+	ss, err := GetStandaloneStatefulSet(&cr)
+	if err != nil {
+		t.Errorf("GetStandaloneStatefulSet() returned %v; want nil", err)
+	}
+	probe := ss.Spec.Template.Spec.Containers[0].LivenessProbe
+	if probe.InitialDelaySeconds != 1800 || probe.TimeoutSeconds != 45 {
+		t.Errorf("GetStandaloneStatefulSet() liveness probe = %v; want initialDelaySeconds=1800 timeoutSeconds=45", probe)
+	}
+
+	// premium apps are not supported by other resources
+	cm := enterprisev1.ClusterMaster{}
+	cm.Spec.AppRepo = spec
+	if err := ValidateClusterMasterSpec(&cm.Spec); err == nil {
+		t.Errorf("ValidateClusterMasterSpec() returned nil; want error for premium app source")
+	}
+}
+
+func TestIsAppPackage(t *testing.T) {
+	test := func(key string, want bool) {
+		if got := IsAppPackage(key); got != want {
+			t.Errorf("IsAppPackage(\"%s\") = %t; want %t", key, got, want)
+		}
+	}
+
+	test("security/app1.tgz", true)
+	test("security/app2.tar.gz", true)
+	test("app3.spl", true)
+	test("security/", false)
+	test("security/README.md", false)
+}
+
+func TestGetAppsSecret(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	apps := []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "abc123"}}
+	checksum := GetAppsChecksum(apps)
+	expiration := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+
+	secret := GetAppsSecret(&cr, SplunkDeployer, []string{"https://s3.test/apps/security/app1.tgz"}, checksum, expiration)
+	if secret.GetName() != "splunk-stack1-search-head-apps" || secret.GetNamespace() != "test" {
+		t.Errorf("GetAppsSecret() name = %s/%s; want %s/%s", secret.GetNamespace(), secret.GetName(), "test", "splunk-stack1-search-head-apps")
+	}
+	want := "splunk:\n  apps_location:\n    - \"https://s3.test/apps/security/app1.tgz\"\n"
+	if got := string(secret.Data["default.yml"]); got != want {
+		t.Errorf("GetAppsSecret() default.yml = %s;\nwant %s", got, want)
+	}
+	if got := secret.GetAnnotations()[AppsChecksumAnnotation]; got != checksum {
+		t.Errorf("GetAppsSecret() checksum = %s; want %s", got, checksum)
+	}
+	if got := secret.GetAnnotations()[AppsExpirationAnnotation]; got != "2020-05-08T10:00:00Z" {
+		t.Errorf("GetAppsSecret() expiration = %s; want %s", got, "2020-05-08T10:00:00Z")
+	}
+
+	// checksum should change when app versions change
+	apps[0].Version = "def456"
+	if GetAppsChecksum(apps) == checksum {
+		t.Errorf("GetAppsChecksum() did not change after app version was updated")
+	}
+}
+
+func TestAddAppsToPodTemplate(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.AppRepoSpec{
+		AppSources: []enterprisev1.AppSourceSpec{{Name: "security", Bucket: "apps"}},
+	}
+
+	test := func(instanceType InstanceType, want bool) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		addAppsToPodTemplate(&podTemplateSpec, &cr, &spec, instanceType)
+		mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+		if !want {
+			if len(mounts) != 0 || len(podTemplateSpec.Spec.Volumes) != 0 {
+				t.Errorf("addAppsToPodTemplate(%s) added volumes; want none", instanceType)
+			}
+			return
+		}
+		if len(mounts) != 1 || mounts[0].MountPath != "/mnt/splunk-apps" {
+			t.Errorf("addAppsToPodTemplate(%s) mounts = %v; want %s", instanceType, mounts, "/mnt/splunk-apps")
+		}
+		if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Secret.SecretName != GetSplunkAppsName("stack1", instanceType) {
+			t.Errorf("addAppsToPodTemplate(%s) volumes = %v; want secret %s", instanceType, podTemplateSpec.Spec.Volumes, GetSplunkAppsName("stack1", instanceType))
+		}
+	}
+
+	test(SplunkStandalone, true)
+	test(SplunkDeployer, true)
+	test(SplunkClusterMaster, true)
+	test(SplunkIndexer, false)
+	test(SplunkSearchHead, false)
+}
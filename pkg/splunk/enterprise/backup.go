@@ -0,0 +1,157 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// label used to identify the VolumeSnapshots taken for a SplunkBackup
+	backupLabelKey = "enterprise.splunk.com/backup"
+
+	// default number of backups to keep
+	defaultBackupRetain = 7
+
+	// minimum interval allowed between scheduled backups
+	minBackupSchedule = time.Hour
+)
+
+// VolumeSnapshotGroupVersionKind is the type of CSI VolumeSnapshot resources used to take backups
+var VolumeSnapshotGroupVersionKind = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1beta1",
+	Kind:    "VolumeSnapshot",
+}
+
+// VolumeSnapshotContentGroupVersionKind is the type of CSI VolumeSnapshotContent resources bound to VolumeSnapshots
+var VolumeSnapshotContentGroupVersionKind = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1beta1",
+	Kind:    "VolumeSnapshotContent",
+}
+
+// GetBackupInstanceTypes returns the instance types of the StatefulSets used by a kind of Splunk Enterprise custom resource
+// that can be backed up, or nil if the kind is not supported.
+func GetBackupInstanceTypes(kind string) []InstanceType {
+	switch kind {
+	case "Standalone":
+		return []InstanceType{SplunkStandalone}
+	case "LicenseMaster":
+		return []InstanceType{SplunkLicenseMaster}
+	case "MonitoringConsole":
+		return []InstanceType{SplunkMonitoringConsole}
+	case "SearchHeadCluster":
+		return []InstanceType{SplunkDeployer, SplunkSearchHead}
+	case "IndexerCluster":
+		return []InstanceType{SplunkClusterMaster, SplunkIndexer}
+	}
+	return nil
+}
+
+// ValidateSplunkBackupSpec checks validity and makes default updates to a SplunkBackupSpec, and returns error if something is wrong.
+func ValidateSplunkBackupSpec(spec *enterprisev1.SplunkBackupSpec) error {
+	if spec.TargetRef.Name == "" {
+		return fmt.Errorf("TargetRef name is required")
+	}
+	if GetBackupInstanceTypes(spec.TargetRef.Kind) == nil {
+		return fmt.Errorf("TargetRef kind must be Standalone, LicenseMaster, MonitoringConsole, SearchHeadCluster or IndexerCluster; value=\"%s\"", spec.TargetRef.Kind)
+	}
+
+	if spec.Schedule != "" {
+		d, err := time.ParseDuration(spec.Schedule)
+		if err != nil {
+			return fmt.Errorf("Schedule is invalid; value=\"%s\": %v", spec.Schedule, err)
+		}
+		if d < minBackupSchedule {
+			return fmt.Errorf("Schedule must be at least %s; value=\"%s\"", minBackupSchedule, spec.Schedule)
+		}
+	}
+
+	if spec.Retain < 0 {
+		return fmt.Errorf("Retain must not be negative; value=%d", spec.Retain)
+	}
+	if spec.Retain == 0 {
+		spec.Retain = defaultBackupRetain
+	}
+
+	return nil
+}
+
+// IsBackupDue returns true if a new backup should be started for a SplunkBackup resource. An on-demand backup is only
+// due if no backups have been taken, and a scheduled backup is due once its interval has passed since the last one started.
+func IsBackupDue(cr *enterprisev1.SplunkBackup, now time.Time) bool {
+	if len(cr.Status.Backups) == 0 {
+		return true
+	}
+	if cr.Spec.Schedule == "" {
+		return false
+	}
+	d, err := time.ParseDuration(cr.Spec.Schedule)
+	if err != nil {
+		return false
+	}
+	lastStarted := cr.Status.Backups[len(cr.Status.Backups)-1].StartTime.Time
+	return !now.Before(lastStarted.Add(d))
+}
+
+// GetVolumeSnapshot returns a CSI VolumeSnapshot used to take a snapshot of a persistent volume claim for a SplunkBackup resource.
+func GetVolumeSnapshot(cr *enterprisev1.SplunkBackup, name string, pvcName string) *unstructured.Unstructured {
+	// unstructured content must use the same types as decoded JSON
+	snapshotSpec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	if cr.Spec.VolumeSnapshotClassName != "" {
+		snapshotSpec["volumeSnapshotClassName"] = cr.Spec.VolumeSnapshotClassName
+	}
+
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{"spec": snapshotSpec}}
+	snapshot.SetGroupVersionKind(VolumeSnapshotGroupVersionKind)
+	snapshot.SetName(name)
+	snapshot.SetNamespace(cr.GetNamespace())
+	snapshot.SetLabels(map[string]string{backupLabelKey: cr.GetIdentifier()})
+	snapshot.SetOwnerReferences(append(snapshot.GetOwnerReferences(), resources.AsOwner(cr)))
+	return snapshot
+}
+
+// GetBackupSecrets returns a Kubernetes Secret used to keep a copy of the secrets used by the custom resource backed up by a
+// SplunkBackup resource. Restored instances need the same secrets, since their admin password and keys are stored in etc.
+func GetBackupSecrets(cr *enterprisev1.SplunkBackup, backupName string, secrets *corev1.Secret) *corev1.Secret {
+	data := make(map[string][]byte)
+	for k, v := range secrets.Data {
+		data[k] = v
+	}
+	backupSecrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkBackupSecretsName(backupName),
+			Namespace: cr.GetNamespace(),
+			Labels:    map[string]string{backupLabelKey: cr.GetIdentifier()},
+		},
+		Data: data,
+	}
+	backupSecrets.SetOwnerReferences(append(backupSecrets.GetOwnerReferences(), resources.AsOwner(cr)))
+	return backupSecrets
+}
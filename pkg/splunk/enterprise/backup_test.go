@@ -0,0 +1,129 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateSplunkBackupSpec(t *testing.T) {
+	test := func(spec enterprisev1.SplunkBackupSpec, wantErr bool) {
+		err := ValidateSplunkBackupSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateSplunkBackupSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateSplunkBackupSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	target := corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"}
+	test(enterprisev1.SplunkBackupSpec{TargetRef: target}, false)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: target, Schedule: "24h"}, false)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: target, Schedule: "1d"}, true)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: target, Schedule: "10m"}, true)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: target, Retain: -1}, true)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: corev1.ObjectReference{Kind: "IndexerCluster"}}, true)
+	test(enterprisev1.SplunkBackupSpec{TargetRef: corev1.ObjectReference{Kind: "Spark", Name: "stack1"}}, true)
+
+	spec := enterprisev1.SplunkBackupSpec{TargetRef: target}
+	ValidateSplunkBackupSpec(&spec)
+	if spec.Retain != defaultBackupRetain {
+		t.Errorf("ValidateSplunkBackupSpec() retain = %d; want %d", spec.Retain, defaultBackupRetain)
+	}
+}
+
+func TestIsBackupDue(t *testing.T) {
+	lastStarted := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	test := func(schedule string, backups []enterprisev1.BackupStatus, now time.Time, want bool) {
+		cr := enterprisev1.SplunkBackup{
+			Spec:   enterprisev1.SplunkBackupSpec{Schedule: schedule},
+			Status: enterprisev1.SplunkBackupStatus{Backups: backups},
+		}
+		if got := IsBackupDue(&cr, now); got != want {
+			t.Errorf("IsBackupDue(\"%s\", %v, %s) = %t; want %t", schedule, backups, now, got, want)
+		}
+	}
+
+	backups := []enterprisev1.BackupStatus{{Name: "stack1-20200508100000", StartTime: metav1.NewTime(lastStarted)}}
+	test("", nil, lastStarted, true)
+	test("24h", nil, lastStarted, true)
+	test("", backups, lastStarted.Add(48*time.Hour), false)
+	test("24h", backups, lastStarted.Add(time.Hour), false)
+	test("24h", backups, lastStarted.Add(24*time.Hour), true)
+}
+
+func TestGetVolumeSnapshot(t *testing.T) {
+	cr := enterprisev1.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(className string) {
+		cr.Spec.VolumeSnapshotClassName = className
+		snapshot := GetVolumeSnapshot(&cr, "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0", "pvc-etc-splunk-stack1-standalone-0")
+		if snapshot.GetName() != "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0" || snapshot.GetNamespace() != "test" {
+			t.Errorf("GetVolumeSnapshot() name = %s/%s; want %s/%s", snapshot.GetNamespace(), snapshot.GetName(), "test", "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0")
+		}
+		if snapshot.GroupVersionKind() != VolumeSnapshotGroupVersionKind {
+			t.Errorf("GetVolumeSnapshot() kind = %v; want %v", snapshot.GroupVersionKind(), VolumeSnapshotGroupVersionKind)
+		}
+		if got := snapshot.GetLabels()[backupLabelKey]; got != "backup1" {
+			t.Errorf("GetVolumeSnapshot() label = %s; want %s", got, "backup1")
+		}
+		if pvcName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName"); pvcName != "pvc-etc-splunk-stack1-standalone-0" {
+			t.Errorf("GetVolumeSnapshot() persistentVolumeClaimName = %s; want %s", pvcName, "pvc-etc-splunk-stack1-standalone-0")
+		}
+		got, found, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+		if got != className || found != (className != "") {
+			t.Errorf("GetVolumeSnapshot() volumeSnapshotClassName = %s (found=%t); want %s", got, found, className)
+		}
+	}
+
+	test("")
+	test("csi-snapclass")
+}
+
+func TestGetBackupSecrets(t *testing.T) {
+	cr := enterprisev1.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{Data: map[string][]byte{"password": []byte("p@ssw0rd")}}
+
+	got := GetBackupSecrets(&cr, "backup1-20200508100000", secrets)
+	if got.GetName() != "backup1-20200508100000-secrets" || got.GetNamespace() != "test" {
+		t.Errorf("GetBackupSecrets() name = %s/%s; want %s/%s", got.GetNamespace(), got.GetName(), "test", "backup1-20200508100000-secrets")
+	}
+	if string(got.Data["password"]) != "p@ssw0rd" || got.GetLabels()[backupLabelKey] != "backup1" || len(got.GetOwnerReferences()) != 1 {
+		t.Errorf("GetBackupSecrets() = %v; want copy of secrets owned by backup1", got)
+	}
+
+	// copy should not share data with the original secrets
+	got.Data["password"] = []byte("changed")
+	if string(secrets.Data["password"]) != "p@ssw0rd" {
+		t.Errorf("GetBackupSecrets() shares data with the original secrets")
+	}
+}
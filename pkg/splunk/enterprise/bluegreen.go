@@ -0,0 +1,146 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// upgrade strategies supported by search head clusters
+	rollingUpdateStrategy = "RollingUpdate"
+	blueGreenStrategy     = "BlueGreen"
+
+	// colors used for the search head cluster members serving searches, and for their replacement during blue/green upgrades
+	blueColor  = "blue"
+	greenColor = "green"
+)
+
+// IsBlueGreenUpgradeEnabled returns true if a search head cluster uses the BlueGreen upgrade strategy
+func IsBlueGreenUpgradeEnabled(spec *enterprisev1.SearchHeadClusterSpec) bool {
+	return spec.UpgradeStrategy == blueGreenStrategy
+}
+
+// validateUpgradeStrategy checks validity and makes default updates to the upgrade strategy of a SearchHeadClusterSpec, and returns error if something is wrong.
+func validateUpgradeStrategy(spec *enterprisev1.SearchHeadClusterSpec) error {
+	if spec.UpgradeStrategy == "" {
+		spec.UpgradeStrategy = rollingUpdateStrategy
+	}
+	if spec.UpgradeStrategy != rollingUpdateStrategy && spec.UpgradeStrategy != blueGreenStrategy {
+		return fmt.Errorf("UpgradeStrategy must be either \"%s\" or \"%s\"; value=\"%s\"", rollingUpdateStrategy, blueGreenStrategy, spec.UpgradeStrategy)
+	}
+	return nil
+}
+
+// GetSearchHeadClusterActiveColor returns the color of the search head cluster members currently serving searches
+func GetSearchHeadClusterActiveColor(cr *enterprisev1.SearchHeadCluster) string {
+	if cr.Status.BlueGreen.ActiveColor == greenColor {
+		return greenColor
+	}
+	return blueColor
+}
+
+// GetSearchHeadClusterStandbyColor returns the color used to replace the search head cluster members currently serving searches
+func GetSearchHeadClusterStandbyColor(cr *enterprisev1.SearchHeadCluster) string {
+	if GetSearchHeadClusterActiveColor(cr) == greenColor {
+		return blueColor
+	}
+	return greenColor
+}
+
+// GetSearchHeadClusterIdentifier returns the identifier used to name the statefulset, pods and headless service of the search
+// head cluster members of a color. Blue members use the identifier of the custom resource, so that existing members are kept
+// when the BlueGreen upgrade strategy is enabled.
+func GetSearchHeadClusterIdentifier(cr *enterprisev1.SearchHeadCluster, color string) string {
+	if color == greenColor {
+		return fmt.Sprintf(colorIdentifierTemplateStr, cr.GetIdentifier(), greenColor)
+	}
+	return cr.GetIdentifier()
+}
+
+// GetSearchHeadClusterLabels returns the labels used to select the search head cluster members of a color. Members of both
+// colors are part of the same search head cluster resources, so that they are cleaned up with it.
+func GetSearchHeadClusterLabels(cr *enterprisev1.SearchHeadCluster, color string) map[string]string {
+	labels := getSplunkLabels(GetSearchHeadClusterIdentifier(cr, color), SplunkSearchHead)
+	labels["app.kubernetes.io/part-of"] = getSplunkLabels(cr.GetIdentifier(), SplunkSearchHead)["app.kubernetes.io/part-of"]
+	return labels
+}
+
+// GetSearchHeadClusterDNSNames returns the DNS names used to reach the search heads and deployer of a search head cluster,
+// including replacement members used by blue/green upgrades.
+func GetSearchHeadClusterDNSNames(cr *enterprisev1.SearchHeadCluster) []string {
+	dnsNames := GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), SplunkSearchHead, SplunkDeployer)
+	if IsBlueGreenUpgradeEnabled(&cr.Spec) {
+		dnsNames = append(dnsNames, "*."+resources.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(SplunkSearchHead, GetSearchHeadClusterIdentifier(cr, greenColor), true)))
+	}
+	return dnsNames
+}
+
+// GetSearchHeadReplacementStatefulSet returns a Kubernetes StatefulSet object for the Splunk Enterprise search heads used to
+// replace the members currently serving searches during a blue/green upgrade.
+func GetSearchHeadReplacementStatefulSet(cr *enterprisev1.SearchHeadCluster) (*appsv1.StatefulSet, error) {
+	return getSearchHeadStatefulSet(cr, GetSearchHeadClusterStandbyColor(cr))
+}
+
+// GetSearchHeadClusterService returns a Kubernetes Service object for the search head cluster members of a color. Headless
+// services are named using the identifier of the color, while regular services keep the same name, so that clients are
+// switched over to replacement members when their selector changes.
+func GetSearchHeadClusterService(cr *enterprisev1.SearchHeadCluster, color string, isHeadless bool) *corev1.Service {
+	service := GetSplunkService(cr, cr.Spec.CommonSpec, SplunkSearchHead, isHeadless)
+	identifier := GetSearchHeadClusterIdentifier(cr, color)
+	if identifier == cr.GetIdentifier() {
+		return service
+	}
+
+	service.Spec.Selector = GetSearchHeadClusterLabels(cr, color)
+	if isHeadless {
+		service.ObjectMeta.Name = GetSplunkServiceName(SplunkSearchHead, identifier, true)
+		for k, v := range service.Spec.Selector {
+			service.ObjectMeta.Labels[k] = v
+		}
+	}
+	return service
+}
+
+// setSearchHeadClusterColor updates a StatefulSet for search head cluster members to use the names and labels of a color
+func setSearchHeadClusterColor(ss *appsv1.StatefulSet, cr *enterprisev1.SearchHeadCluster, color string) {
+	identifier := GetSearchHeadClusterIdentifier(cr, color)
+	if identifier == cr.GetIdentifier() {
+		return
+	}
+
+	labels := GetSearchHeadClusterLabels(cr, color)
+	ss.ObjectMeta.Name = GetSplunkStatefulsetName(SplunkSearchHead, identifier)
+	ss.Spec.ServiceName = GetSplunkServiceName(SplunkSearchHead, identifier, true)
+	ss.Spec.Selector.MatchLabels = resources.CopyLabels(labels)
+	for k, v := range labels {
+		ss.Spec.Template.ObjectMeta.Labels[k] = v
+	}
+	for idx := range ss.Spec.VolumeClaimTemplates {
+		for k, v := range labels {
+			ss.Spec.VolumeClaimTemplates[idx].ObjectMeta.Labels[k] = v
+		}
+	}
+
+	// anti-affinity and topology spread constraints apply to members of the same color
+	ss.Spec.Template.Spec.Affinity = resources.GetPodAffinity(&cr.Spec.CommonSpec, identifier, SplunkSearchHead.ToString())
+	ss.Spec.Template.Spec.TopologySpreadConstraints = resources.GetTopologySpreadConstraints(&cr.Spec.CommonSpec, identifier, SplunkSearchHead.ToString())
+}
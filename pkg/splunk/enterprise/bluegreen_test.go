@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateUpgradeStrategy(t *testing.T) {
+	test := func(strategy string, wantErr bool, want string) {
+		spec := enterprisev1.SearchHeadClusterSpec{UpgradeStrategy: strategy}
+		err := validateUpgradeStrategy(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateUpgradeStrategy(%s) returned nil; want error", strategy)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateUpgradeStrategy(%s) returned %v; want nil", strategy, err)
+		}
+		if !wantErr && spec.UpgradeStrategy != want {
+			t.Errorf("validateUpgradeStrategy(%s) upgradeStrategy = %s; want %s", strategy, spec.UpgradeStrategy, want)
+		}
+	}
+
+	test("", false, "RollingUpdate")
+	test("RollingUpdate", false, "RollingUpdate")
+	test("BlueGreen", false, "BlueGreen")
+	test("Recreate", true, "")
+}
+
+func TestGetSearchHeadClusterColors(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(activeColor string, wantActive, wantStandby, wantStandbyIdentifier string) {
+		cr.Status.BlueGreen.ActiveColor = activeColor
+		if got := GetSearchHeadClusterActiveColor(&cr); got != wantActive {
+			t.Errorf("GetSearchHeadClusterActiveColor(%s) = %s; want %s", activeColor, got, wantActive)
+		}
+		if got := GetSearchHeadClusterStandbyColor(&cr); got != wantStandby {
+			t.Errorf("GetSearchHeadClusterStandbyColor(%s) = %s; want %s", activeColor, got, wantStandby)
+		}
+		if got := GetSearchHeadClusterIdentifier(&cr, wantStandby); got != wantStandbyIdentifier {
+			t.Errorf("GetSearchHeadClusterIdentifier(%s) = %s; want %s", wantStandby, got, wantStandbyIdentifier)
+		}
+	}
+
+	test("", "blue", "green", "stack1-green")
+	test("blue", "blue", "green", "stack1-green")
+	test("green", "green", "blue", "stack1")
+}
+
+func TestGetSearchHeadReplacementStatefulSet(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SearchHeadClusterSpec{UpgradeStrategy: "BlueGreen"},
+	}
+	if err := ValidateSearchHeadClusterSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateSearchHeadClusterSpec() returned %v; want nil", err)
+	}
+
+	ss, err := GetSearchHeadReplacementStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetSearchHeadReplacementStatefulSet() returned %v; want nil", err)
+	}
+	if ss.GetName() != "splunk-stack1-green-search-head" || ss.Spec.ServiceName != "splunk-stack1-green-search-head-headless" {
+		t.Errorf("GetSearchHeadReplacementStatefulSet() name = %s, serviceName = %s; want %s, %s", ss.GetName(), ss.Spec.ServiceName, "splunk-stack1-green-search-head", "splunk-stack1-green-search-head-headless")
+	}
+	wantLabels := GetSearchHeadClusterLabels(&cr, "green")
+	if wantLabels["app.kubernetes.io/instance"] != "splunk-stack1-green-search-head" || wantLabels["app.kubernetes.io/part-of"] != "splunk-stack1-search-head" {
+		t.Errorf("GetSearchHeadClusterLabels(green) = %v; want instance splunk-stack1-green-search-head, part-of splunk-stack1-search-head", wantLabels)
+	}
+	if !reflect.DeepEqual(ss.Spec.Selector.MatchLabels, wantLabels) {
+		t.Errorf("GetSearchHeadReplacementStatefulSet() selector = %v; want %v", ss.Spec.Selector.MatchLabels, wantLabels)
+	}
+	if ss.Spec.Template.ObjectMeta.Labels["app.kubernetes.io/instance"] != "splunk-stack1-green-search-head" {
+		t.Errorf("GetSearchHeadReplacementStatefulSet() template labels = %v; want instance splunk-stack1-green-search-head", ss.Spec.Template.ObjectMeta.Labels)
+	}
+	wantCaptainURL := "splunk-stack1-green-search-head-0.splunk-stack1-green-search-head-headless.test.svc.cluster.local"
+	for _, env := range ss.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "SPLUNK_SEARCH_HEAD_CAPTAIN_URL" && env.Value != wantCaptainURL {
+			t.Errorf("GetSearchHeadReplacementStatefulSet() SPLUNK_SEARCH_HEAD_CAPTAIN_URL = %s; want %s", env.Value, wantCaptainURL)
+		}
+		if env.Name == "SPLUNK_DEPLOYER_URL" && env.Value != "splunk-stack1-deployer-service" {
+			t.Errorf("GetSearchHeadReplacementStatefulSet() SPLUNK_DEPLOYER_URL = %s; want %s", env.Value, "splunk-stack1-deployer-service")
+		}
+	}
+	if secretName := ss.Spec.Template.Spec.Volumes[0].Secret.SecretName; secretName != "splunk-stack1-search-head-secrets" {
+		t.Errorf("GetSearchHeadReplacementStatefulSet() secret = %s; want %s", secretName, "splunk-stack1-search-head-secrets")
+	}
+
+	// once green members are serving searches, blue members are used to replace them
+	cr.Status.BlueGreen.ActiveColor = "green"
+	ss, err = GetSearchHeadReplacementStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetSearchHeadReplacementStatefulSet() returned %v; want nil", err)
+	}
+	if ss.GetName() != "splunk-stack1-search-head" {
+		t.Errorf("GetSearchHeadReplacementStatefulSet() name = %s; want %s", ss.GetName(), "splunk-stack1-search-head")
+	}
+	ss, err = GetDeployerStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetDeployerStatefulSet() returned %v; want nil", err)
+	}
+	for _, env := range ss.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "SPLUNK_SEARCH_HEAD_CAPTAIN_URL" && env.Value != wantCaptainURL {
+			t.Errorf("GetDeployerStatefulSet() SPLUNK_SEARCH_HEAD_CAPTAIN_URL = %s; want %s", env.Value, wantCaptainURL)
+		}
+	}
+}
+
+func TestGetSearchHeadClusterService(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(color string, isHeadless bool, wantName, wantInstance string) {
+		service := GetSearchHeadClusterService(&cr, color, isHeadless)
+		if service.GetName() != wantName {
+			t.Errorf("GetSearchHeadClusterService(%s,%t) name = %s; want %s", color, isHeadless, service.GetName(), wantName)
+		}
+		if got := service.Spec.Selector["app.kubernetes.io/instance"]; got != wantInstance {
+			t.Errorf("GetSearchHeadClusterService(%s,%t) selector instance = %s; want %s", color, isHeadless, got, wantInstance)
+		}
+	}
+
+	test("blue", true, "splunk-stack1-search-head-headless", "splunk-stack1-search-head")
+	test("blue", false, "splunk-stack1-search-head-service", "splunk-stack1-search-head")
+	test("green", true, "splunk-stack1-green-search-head-headless", "splunk-stack1-green-search-head")
+	test("green", false, "splunk-stack1-search-head-service", "splunk-stack1-green-search-head")
+}
+
+func TestGetSearchHeadClusterDNSNames(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	if got := GetSearchHeadClusterDNSNames(&cr); len(got) != 6 {
+		t.Errorf("GetSearchHeadClusterDNSNames() = %v; want 6 names", got)
+	}
+	cr.Spec.UpgradeStrategy = "BlueGreen"
+	got := GetSearchHeadClusterDNSNames(&cr)
+	want := "*.splunk-stack1-green-search-head-headless.test.svc.cluster.local"
+	if len(got) != 7 || got[6] != want {
+		t.Errorf("GetSearchHeadClusterDNSNames() = %v; want %s included", got, want)
+	}
+}
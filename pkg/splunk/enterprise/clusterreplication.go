@@ -0,0 +1,226 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// ClusterReplicationPrimary is reported as the active indexer cluster of a ClusterReplication until it fails over
+	ClusterReplicationPrimary = "primary"
+
+	// ClusterReplicationStandby is reported as the active indexer cluster of a ClusterReplication once it has failed over
+	ClusterReplicationStandby = "standby"
+
+	// default interval between syncs of the inactive indexer cluster of a ClusterReplication
+	defaultClusterReplicationSyncInterval = "5m"
+
+	// name of the objects that SmartStore uploads to remote storage for each bucket, listing the files of the bucket
+	smartStoreManifestName = "receipt.json"
+)
+
+// ValidateClusterReplicationSpec checks validity and makes default updates to a ClusterReplicationSpec, and returns error if something is wrong.
+func ValidateClusterReplicationSpec(spec *enterprisev1.ClusterReplicationSpec) error {
+	if spec.PrimaryRef.Name == "" {
+		return fmt.Errorf("PrimaryRef name is required")
+	}
+	if spec.PrimaryRef.Kind != "" && spec.PrimaryRef.Kind != "IndexerCluster" {
+		return fmt.Errorf("PrimaryRef kind must be IndexerCluster; value=\"%s\"", spec.PrimaryRef.Kind)
+	}
+	if spec.StandbyRef.Name == "" {
+		return fmt.Errorf("StandbyRef name is required")
+	}
+	if spec.StandbyRef.Kind != "" && spec.StandbyRef.Kind != "IndexerCluster" {
+		return fmt.Errorf("StandbyRef kind must be IndexerCluster; value=\"%s\"", spec.StandbyRef.Kind)
+	}
+
+	if spec.SyncInterval == "" {
+		spec.SyncInterval = defaultClusterReplicationSyncInterval
+	}
+	d, err := time.ParseDuration(spec.SyncInterval)
+	if err != nil {
+		return fmt.Errorf("SyncInterval is invalid; value=\"%s\": %v", spec.SyncInterval, err)
+	}
+	if d < time.Minute {
+		return fmt.Errorf("SyncInterval must be at least %s; value=\"%s\"", time.Minute, spec.SyncInterval)
+	}
+	return nil
+}
+
+// GetClusterReplicationSyncInterval returns the interval between syncs of the inactive indexer cluster of a ClusterReplication
+func GetClusterReplicationSyncInterval(spec *enterprisev1.ClusterReplicationSpec) time.Duration {
+	d, err := time.ParseDuration(spec.SyncInterval)
+	if err != nil {
+		d, _ = time.ParseDuration(defaultClusterReplicationSyncInterval)
+	}
+	return d
+}
+
+// GetClusterReplicationRefs returns references to the primary and standby indexer clusters of a ClusterReplication, which
+// always include a namespace.
+func GetClusterReplicationRefs(cr *enterprisev1.ClusterReplication) (corev1.ObjectReference, corev1.ObjectReference) {
+	primary, standby := cr.Spec.PrimaryRef, cr.Spec.StandbyRef
+	if primary.Namespace == "" {
+		primary.Namespace = cr.GetNamespace()
+	}
+	if standby.Namespace == "" {
+		standby.Namespace = cr.GetNamespace()
+	}
+	return primary, standby
+}
+
+// IsClusterReplicationStandbyLocal returns true if the standby indexer cluster of a ClusterReplication runs in the same
+// Kubernetes cluster as the operator
+func IsClusterReplicationStandbyLocal(spec *enterprisev1.ClusterReplicationSpec) bool {
+	return spec.StandbyKubeconfigSecretRef == ""
+}
+
+// SyncReplicatedSpec copies the settings that determine the configuration bundle, app packages and SmartStore indexes of
+// an indexer cluster from src to dst, and returns true if dst was changed. Settings specific to each indexer cluster, such
+// as its size, storage and SmartStore volumes, are not copied.
+func SyncReplicatedSpec(src, dst *enterprisev1.IndexerClusterSpec) bool {
+	changed := false
+	if dst.Image != src.Image {
+		dst.Image = src.Image
+		changed = true
+	}
+	if dst.Defaults != src.Defaults || dst.DefaultsURL != src.DefaultsURL || dst.DefaultsConfigMapRef != src.DefaultsConfigMapRef {
+		dst.Defaults = src.Defaults
+		dst.DefaultsURL = src.DefaultsURL
+		dst.DefaultsConfigMapRef = src.DefaultsConfigMapRef
+		changed = true
+	}
+	if !reflect.DeepEqual(dst.ConfFiles, src.ConfFiles) {
+		dst.ConfFiles = src.ConfFiles
+		changed = true
+	}
+	if !reflect.DeepEqual(dst.AppRepo, src.AppRepo) {
+		dst.AppRepo = src.AppRepo
+		changed = true
+	}
+	if !reflect.DeepEqual(dst.SmartStore.IndexList, src.SmartStore.IndexList) {
+		dst.SmartStore.IndexList = src.SmartStore.IndexList
+		changed = true
+	}
+	if dst.SmartStore.Defaults != src.SmartStore.Defaults || dst.SmartStore.CacheManagerConf != src.SmartStore.CacheManagerConf {
+		dst.SmartStore.Defaults = src.SmartStore.Defaults
+		dst.SmartStore.CacheManagerConf = src.SmartStore.CacheManagerConf
+		changed = true
+	}
+	return changed
+}
+
+// IsClusterBundleInSync returns true if the cluster master of dst has pushed the same conf files and defaults to its
+// peers as the cluster master of src
+func IsClusterBundleInSync(src, dst *enterprisev1.ClusterBundleStatus) bool {
+	return src.ConfFilesChecksum == dst.ConfFilesChecksum && src.DefaultsChecksum == dst.DefaultsChecksum
+}
+
+// AreAppsInSync returns true if the same versions of app packages have been installed from an app repository by two
+// indexer clusters
+func AreAppsInSync(src, dst []enterprisev1.AppStatus) bool {
+	return GetAppsChecksum(src) == GetAppsChecksum(dst)
+}
+
+// SmartStoreManifestLocation is the location in remote storage of the bucket manifests of a SmartStore index.
+type SmartStoreManifestLocation struct {
+	// Name of the index
+	Index string
+
+	// Remote storage volume used by the index
+	Volume *enterprisev1.SmartStoreVolumeSpec
+
+	// Name of the bucket holding the index
+	Bucket string
+
+	// Prefix of the names of all objects of the index in the bucket, ending with "/"
+	Prefix string
+}
+
+// GetSmartStoreManifestLocations returns the locations in remote storage of the bucket manifests of each SmartStore
+// index of an indexer cluster, using its remote storage volumes.
+func GetSmartStoreManifestLocations(spec *enterprisev1.SmartStoreSpec) ([]SmartStoreManifestLocation, error) {
+	locations := []SmartStoreManifestLocation{}
+	for _, idx := range spec.IndexList {
+		volName := idx.VolName
+		if volName == "" {
+			volName = spec.Defaults.VolName
+		}
+		var volume *enterprisev1.SmartStoreVolumeSpec
+		for i := range spec.VolList {
+			if spec.VolList[i].Name == volName {
+				volume = &spec.VolList[i]
+				break
+			}
+		}
+		if volume == nil {
+			return nil, fmt.Errorf("SmartStore index volume is not defined; index=\"%s\", volume=\"%s\"", idx.Name, volName)
+		}
+		// remote paths may use the $_index_name variable, like indexes.conf
+		remotePath := strings.Replace(idx.RemotePath, "$_index_name", idx.Name, -1)
+		if remotePath == "" {
+			remotePath = idx.Name
+		}
+		path := strings.Trim(volume.Path, "/")
+		bucket, prefix := path, ""
+		if i := strings.Index(path, "/"); i >= 0 {
+			bucket, prefix = path[:i], path[i+1:]+"/"
+		}
+		locations = append(locations, SmartStoreManifestLocation{
+			Index:  idx.Name,
+			Volume: volume,
+			Bucket: bucket,
+			Prefix: prefix + strings.Trim(remotePath, "/") + "/",
+		})
+	}
+	return locations, nil
+}
+
+// IsSmartStoreManifest returns true if an object in remote storage is a SmartStore bucket manifest
+func IsSmartStoreManifest(key string) bool {
+	return key == smartStoreManifestName || strings.HasSuffix(key, "/"+smartStoreManifestName)
+}
+
+// GetS3EndpointRegion returns the AWS region of an Amazon S3 endpoint (e.g. https://s3-us-west-2.amazonaws.com or
+// https://s3.us-west-2.amazonaws.com), or us-east-1 for other endpoints, which is used by most S3 compatible object stores.
+func GetS3EndpointRegion(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return defaultAppRepoRegion
+	}
+	host := u.Hostname()
+	if !strings.HasSuffix(host, ".amazonaws.com") {
+		return defaultAppRepoRegion
+	}
+	labels := strings.Split(strings.TrimSuffix(host, ".amazonaws.com"), ".")
+	for i, label := range labels {
+		switch {
+		case strings.HasPrefix(label, "s3-") && label != "s3-external-1":
+			return strings.TrimPrefix(label, "s3-")
+		case label == "s3" && i+1 < len(labels):
+			return labels[i+1]
+		}
+	}
+	return defaultAppRepoRegion
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateClusterReplicationSpec(t *testing.T) {
+	test := func(spec enterprisev1.ClusterReplicationSpec, wantErr bool, wantInterval time.Duration) {
+		err := ValidateClusterReplicationSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateClusterReplicationSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateClusterReplicationSpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && GetClusterReplicationSyncInterval(&spec) != wantInterval {
+			t.Errorf("GetClusterReplicationSyncInterval(%v) = %s; want %s", spec, GetClusterReplicationSyncInterval(&spec), wantInterval)
+		}
+	}
+
+	primary := corev1.ObjectReference{Name: "idxc1"}
+	standby := corev1.ObjectReference{Name: "idxc2", Namespace: "dr"}
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary, StandbyRef: standby}, false, 5*time.Minute)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary, StandbyRef: standby, SyncInterval: "1h"}, false, time.Hour)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary, StandbyRef: standby, SyncInterval: "30s"}, true, 0)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary, StandbyRef: standby, SyncInterval: "often"}, true, 0)
+	test(enterprisev1.ClusterReplicationSpec{StandbyRef: standby}, true, 0)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary}, true, 0)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: corev1.ObjectReference{Name: "s1", Kind: "Standalone"}, StandbyRef: standby}, true, 0)
+	test(enterprisev1.ClusterReplicationSpec{PrimaryRef: primary, StandbyRef: corev1.ObjectReference{Name: "idxc2", Kind: "IndexerCluster"}}, false, 5*time.Minute)
+}
+
+func TestGetClusterReplicationRefs(t *testing.T) {
+	cr := enterprisev1.ClusterReplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "dr", Namespace: "test"},
+		Spec: enterprisev1.ClusterReplicationSpec{
+			PrimaryRef: corev1.ObjectReference{Name: "idxc1"},
+			StandbyRef: corev1.ObjectReference{Name: "idxc2", Namespace: "standby"},
+		},
+	}
+	primary, standby := GetClusterReplicationRefs(&cr)
+	if primary.Name != "idxc1" || primary.Namespace != "test" {
+		t.Errorf("GetClusterReplicationRefs() primary = %v; want test/idxc1", primary)
+	}
+	if standby.Name != "idxc2" || standby.Namespace != "standby" {
+		t.Errorf("GetClusterReplicationRefs() standby = %v; want standby/idxc2", standby)
+	}
+	if cr.Spec.PrimaryRef.Namespace != "" {
+		t.Errorf("GetClusterReplicationRefs() updated spec primaryRef namespace = %s; want empty", cr.Spec.PrimaryRef.Namespace)
+	}
+}
+
+func TestSyncReplicatedSpec(t *testing.T) {
+	src := enterprisev1.IndexerClusterSpec{Replicas: 3}
+	src.Image = "splunk/splunk:8.1.0"
+	src.Defaults = "splunk:\n  hec_disabled: 1\n"
+	src.ConfFiles = []enterprisev1.ConfFileSpec{{Name: "props.conf", Content: "[syslog]\n"}}
+	src.SmartStore = enterprisev1.SmartStoreSpec{
+		VolList:   []enterprisev1.SmartStoreVolumeSpec{{Name: "primary", Path: "primary-bucket"}},
+		IndexList: []enterprisev1.SmartStoreIndexSpec{{Name: "main"}},
+		Defaults:  enterprisev1.SmartStoreDefaultsSpec{VolName: "s2"},
+	}
+	dst := enterprisev1.IndexerClusterSpec{Replicas: 5}
+	dst.Image = "splunk/splunk:8.0.5"
+	dst.SmartStore.VolList = []enterprisev1.SmartStoreVolumeSpec{{Name: "standby", Path: "standby-bucket"}}
+
+	if !SyncReplicatedSpec(&src, &dst) {
+		t.Errorf("SyncReplicatedSpec() = false; want true")
+	}
+	if dst.Image != src.Image || dst.Defaults != src.Defaults || len(dst.ConfFiles) != 1 || len(dst.SmartStore.IndexList) != 1 || dst.SmartStore.Defaults.VolName != "s2" {
+		t.Errorf("SyncReplicatedSpec() dst = %v; want replicated settings of %v", dst, src)
+	}
+	if dst.Replicas != 5 || dst.SmartStore.VolList[0].Name != "standby" {
+		t.Errorf("SyncReplicatedSpec() updated replicas or volumes of dst: %v", dst)
+	}
+	if SyncReplicatedSpec(&src, &dst) {
+		t.Errorf("SyncReplicatedSpec() = true; want false once in sync")
+	}
+}
+
+func TestIsClusterBundleInSync(t *testing.T) {
+	src := enterprisev1.ClusterBundleStatus{ConfFilesChecksum: "abc", DefaultsChecksum: "def", ActiveChecksum: "123"}
+	dst := enterprisev1.ClusterBundleStatus{ConfFilesChecksum: "abc", DefaultsChecksum: "def", ActiveChecksum: "456"}
+	if !IsClusterBundleInSync(&src, &dst) {
+		t.Errorf("IsClusterBundleInSync(%v, %v) = false; want true", src, dst)
+	}
+	dst.ConfFilesChecksum = "xyz"
+	if IsClusterBundleInSync(&src, &dst) {
+		t.Errorf("IsClusterBundleInSync(%v, %v) = true; want false", src, dst)
+	}
+}
+
+func TestAreAppsInSync(t *testing.T) {
+	src := []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "abc123"}}
+	if !AreAppsInSync(src, []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "abc123"}}) {
+		t.Errorf("AreAppsInSync() = false; want true")
+	}
+	if AreAppsInSync(src, []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "def456"}}) {
+		t.Errorf("AreAppsInSync() = true; want false for different versions")
+	}
+	if AreAppsInSync(src, nil) {
+		t.Errorf("AreAppsInSync() = true; want false for missing apps")
+	}
+}
+
+func TestGetSmartStoreManifestLocations(t *testing.T) {
+	spec := enterprisev1.SmartStoreSpec{
+		VolList: []enterprisev1.SmartStoreVolumeSpec{
+			{Name: "s1", Path: "bucket1/smartstore"},
+			{Name: "s2", Path: "bucket2"},
+		},
+		IndexList: []enterprisev1.SmartStoreIndexSpec{
+			{Name: "main"},
+			{Name: "web", VolName: "s2", RemotePath: "$_index_name/"},
+		},
+		Defaults: enterprisev1.SmartStoreDefaultsSpec{VolName: "s1"},
+	}
+	locations, err := GetSmartStoreManifestLocations(&spec)
+	if err != nil {
+		t.Fatalf("GetSmartStoreManifestLocations() returned %v; want nil", err)
+	}
+	if len(locations) != 2 {
+		t.Fatalf("GetSmartStoreManifestLocations() = %v; want 2 locations", locations)
+	}
+	if l := locations[0]; l.Index != "main" || l.Volume.Name != "s1" || l.Bucket != "bucket1" || l.Prefix != "smartstore/main/" {
+		t.Errorf("GetSmartStoreManifestLocations() main = %v; want s1 bucket1 smartstore/main/", l)
+	}
+	if l := locations[1]; l.Index != "web" || l.Volume.Name != "s2" || l.Bucket != "bucket2" || l.Prefix != "web/" {
+		t.Errorf("GetSmartStoreManifestLocations() web = %v; want s2 bucket2 web/", l)
+	}
+
+	spec.IndexList = append(spec.IndexList, enterprisev1.SmartStoreIndexSpec{Name: "bad", VolName: "s3"})
+	if _, err = GetSmartStoreManifestLocations(&spec); err == nil {
+		t.Errorf("GetSmartStoreManifestLocations() returned nil; want error for undefined volume")
+	}
+}
+
+func TestIsSmartStoreManifest(t *testing.T) {
+	test := func(key string, want bool) {
+		if got := IsSmartStoreManifest(key); got != want {
+			t.Errorf("IsSmartStoreManifest(%s) = %t; want %t", key, got, want)
+		}
+	}
+	test("main/db/ab/cd/1~ABCD/receipt.json", true)
+	test("receipt.json", true)
+	test("main/db/ab/cd/1~ABCD/guidSplunk-ABCD/rawdata/journal.zst", false)
+	test("main/db/ab/cd/1~ABCD/myreceipt.json", false)
+}
+
+func TestGetS3EndpointRegion(t *testing.T) {
+	test := func(endpoint, want string) {
+		if got := GetS3EndpointRegion(endpoint); got != want {
+			t.Errorf("GetS3EndpointRegion(%s) = %s; want %s", endpoint, got, want)
+		}
+	}
+	test("https://s3-us-west-2.amazonaws.com", "us-west-2")
+	test("https://s3.eu-central-1.amazonaws.com", "eu-central-1")
+	test("https://s3.amazonaws.com", "us-east-1")
+	test("https://s3-external-1.amazonaws.com", "us-east-1")
+	test("https://minio.minio.svc:9000", "us-east-1")
+	test("", "us-east-1")
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// isConditionTrue returns true if a custom resource in the given phase has a condition of the given type
+func isConditionTrue(conditionType enterprisev1.ConditionType, phase enterprisev1.ResourcePhase) bool {
+	switch conditionType {
+	case enterprisev1.ConditionReady:
+		return phase == enterprisev1.PhaseReady
+	case enterprisev1.ConditionProgressing:
+		return phase == enterprisev1.PhasePending || phase == enterprisev1.PhaseUpdating || phase == enterprisev1.PhaseScalingUp ||
+			phase == enterprisev1.PhaseScalingDown || phase == enterprisev1.PhaseTerminating
+	case enterprisev1.ConditionDegraded:
+		return phase == enterprisev1.PhaseError
+	case enterprisev1.ConditionUpgradeInProgress:
+		return phase == enterprisev1.PhaseUpdating
+	}
+	return false
+}
+
+// GetStatusConditions returns the standard conditions for a custom resource in the given phase, observed for the given
+// generation. The transition time of each condition is only changed if its status differs from the current conditions.
+// If err is not nil, it is used as the message of the Degraded condition.
+func GetStatusConditions(current []enterprisev1.Condition, phase enterprisev1.ResourcePhase, generation int64, err error, now time.Time) []enterprisev1.Condition {
+	conditionTypes := []enterprisev1.ConditionType{
+		enterprisev1.ConditionReady,
+		enterprisev1.ConditionProgressing,
+		enterprisev1.ConditionDegraded,
+		enterprisev1.ConditionUpgradeInProgress,
+	}
+	conditions := []enterprisev1.Condition{}
+	for _, conditionType := range conditionTypes {
+		condition := enterprisev1.Condition{
+			Type:               conditionType,
+			Status:             corev1.ConditionFalse,
+			ObservedGeneration: generation,
+			LastTransitionTime: metav1.NewTime(now),
+			Reason:             string(phase),
+		}
+		if isConditionTrue(conditionType, phase) {
+			condition.Status = corev1.ConditionTrue
+		}
+		if conditionType == enterprisev1.ConditionDegraded && err != nil {
+			condition.Message = err.Error()
+		}
+		for _, c := range current {
+			if c.Type == conditionType && c.Status == condition.Status {
+				condition.LastTransitionTime = c.LastTransitionTime
+			}
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// GetCondition returns the condition of the given type from a list of conditions, or nil if there is none
+func GetCondition(conditions []enterprisev1.Condition, conditionType enterprisev1.ConditionType) *enterprisev1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// GetPausedConditions returns the current conditions of a custom resource whose reconciles have been paused, along with
+// a Paused condition observed for the given generation. All other conditions are left unchanged, and the transition
+// time of the Paused condition is only changed if it was not already paused.
+func GetPausedConditions(current []enterprisev1.Condition, generation int64, now time.Time) []enterprisev1.Condition {
+	paused := enterprisev1.Condition{
+		Type:               enterprisev1.ConditionPaused,
+		Status:             corev1.ConditionTrue,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Paused",
+		Message:            "Reconciles are paused by the " + PausedAnnotation + " annotation",
+	}
+	conditions := []enterprisev1.Condition{}
+	for _, c := range current {
+		if c.Type != enterprisev1.ConditionPaused {
+			conditions = append(conditions, c)
+		} else if c.Status == paused.Status {
+			paused.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+	return append(conditions, paused)
+}
+
+// GetPhaseReason returns the reason, in CamelCase, and the human readable message reported along with the phase in the
+// status of a custom resource. The reason and message found while reconciling it are kept, unless it is ready or err is
+// not nil, in which case the error is reported instead. The phase is used as the reason if none was found.
+func GetPhaseReason(phase enterprisev1.ResourcePhase, reason, message string, err error) (string, string) {
+	switch {
+	case err != nil:
+		return "ReconcileError", err.Error()
+	case phase == enterprisev1.PhaseReady || reason == "":
+		return string(phase), ""
+	}
+	return reason, message
+}
+
+// GetLastError returns the most recent reconcile error reported in the status of a custom resource, given the last one
+// reported (if any) and the result of the current reconcile. It returns nil once a reconcile succeeds, and otherwise counts
+// the number of consecutive reconciles that have failed.
+func GetLastError(current *enterprisev1.ReconcileErrorStatus, err error, now time.Time) *enterprisev1.ReconcileErrorStatus {
+	if err == nil {
+		return nil
+	}
+	lastError := enterprisev1.ReconcileErrorStatus{Message: err.Error(), LastErrorTime: metav1.NewTime(now), Count: 1}
+	if current != nil {
+		lastError.Count = current.Count + 1
+	}
+	return &lastError
+}
+
+// isPodReady returns true if a pod is running and all of its containers are ready
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if !s.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// getContainerMessage returns a message reporting the state of a container that is waiting or has terminated
+func getContainerMessage(pod *corev1.Pod, container, state, reason, message string) string {
+	result := fmt.Sprintf("container %s of pod %s %s (%s)", container, pod.GetName(), state, reason)
+	if message != "" {
+		result += ": " + message
+	}
+	return result
+}
+
+// GetPodNotReadyReason returns a reason, in CamelCase, and a human readable message explaining why a pod is not ready,
+// such as a pod that cannot be scheduled or a container that cannot pull its image. Empty strings are returned if the pod
+// is ready.
+func GetPodNotReadyReason(pod *corev1.Pod) (string, string) {
+	if isPodReady(pod) {
+		return "", ""
+	}
+	if pod.GetDeletionTimestamp() != nil {
+		return "PodTerminating", fmt.Sprintf("pod %s is terminating", pod.GetName())
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			return "PodUnschedulable", fmt.Sprintf("pod %s cannot be scheduled: %s", pod.GetName(), c.Message)
+		}
+	}
+
+	// init containers must complete before the other containers are started
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if waiting := s.State.Waiting; waiting != nil {
+			reason := waiting.Reason
+			if reason == "" {
+				reason = "ContainerWaiting"
+			}
+			return reason, getContainerMessage(pod, s.Name, "is waiting", reason, waiting.Message)
+		}
+		if terminated := s.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			reason := terminated.Reason
+			if reason == "" {
+				reason = "ContainerTerminated"
+			}
+			return reason, getContainerMessage(pod, s.Name, "has terminated", reason, terminated.Message)
+		}
+	}
+
+	if phase := pod.Status.Phase; phase != corev1.PodRunning {
+		if phase == "" {
+			phase = corev1.PodPending
+		}
+		return "Pod" + string(phase), fmt.Sprintf("pod %s is %s", pod.GetName(), strings.ToLower(string(phase)))
+	}
+	return "PodNotReady", fmt.Sprintf("waiting for pod %s to become ready", pod.GetName())
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestGetStatusConditions(t *testing.T) {
+	test := func(current []enterprisev1.Condition, phase enterprisev1.ResourcePhase, err error, now time.Time, want map[enterprisev1.ConditionType]corev1.ConditionStatus) []enterprisev1.Condition {
+		conditions := GetStatusConditions(current, phase, 3, err, now)
+		if len(conditions) != len(want) {
+			t.Errorf("GetStatusConditions(%s) returned %d conditions; want %d", phase, len(conditions), len(want))
+		}
+		for _, c := range conditions {
+			if c.Status != want[c.Type] {
+				t.Errorf("GetStatusConditions(%s) %s = %s; want %s", phase, c.Type, c.Status, want[c.Type])
+			}
+			if c.Reason != string(phase) || c.ObservedGeneration != 3 {
+				t.Errorf("GetStatusConditions(%s) %s reason = %s, observedGeneration = %d; want %s, 3", phase, c.Type, c.Reason, c.ObservedGeneration, phase)
+			}
+		}
+		return conditions
+	}
+
+	start := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	conditions := test(nil, enterprisev1.PhasePending, nil, start, map[enterprisev1.ConditionType]corev1.ConditionStatus{
+		enterprisev1.ConditionReady:             corev1.ConditionFalse,
+		enterprisev1.ConditionProgressing:       corev1.ConditionTrue,
+		enterprisev1.ConditionDegraded:          corev1.ConditionFalse,
+		enterprisev1.ConditionUpgradeInProgress: corev1.ConditionFalse,
+	})
+	for _, c := range conditions {
+		if !c.LastTransitionTime.Time.Equal(start) {
+			t.Errorf("GetStatusConditions() %s lastTransitionTime = %s; want %s", c.Type, c.LastTransitionTime, start)
+		}
+	}
+
+	// transition time only changes for conditions with a different status
+	later := start.Add(time.Hour)
+	conditions = test(conditions, enterprisev1.PhaseReady, nil, later, map[enterprisev1.ConditionType]corev1.ConditionStatus{
+		enterprisev1.ConditionReady:             corev1.ConditionTrue,
+		enterprisev1.ConditionProgressing:       corev1.ConditionFalse,
+		enterprisev1.ConditionDegraded:          corev1.ConditionFalse,
+		enterprisev1.ConditionUpgradeInProgress: corev1.ConditionFalse,
+	})
+	for _, c := range conditions {
+		want := later
+		if c.Type == enterprisev1.ConditionDegraded || c.Type == enterprisev1.ConditionUpgradeInProgress {
+			want = start
+		}
+		if !c.LastTransitionTime.Time.Equal(want) {
+			t.Errorf("GetStatusConditions() %s lastTransitionTime = %s; want %s", c.Type, c.LastTransitionTime, want)
+		}
+	}
+
+	test(conditions, enterprisev1.PhaseUpdating, nil, later, map[enterprisev1.ConditionType]corev1.ConditionStatus{
+		enterprisev1.ConditionReady:             corev1.ConditionFalse,
+		enterprisev1.ConditionProgressing:       corev1.ConditionTrue,
+		enterprisev1.ConditionDegraded:          corev1.ConditionFalse,
+		enterprisev1.ConditionUpgradeInProgress: corev1.ConditionTrue,
+	})
+
+	// errors are reported by the Degraded condition
+	conditions = test(conditions, enterprisev1.PhaseError, fmt.Errorf("StatefulSet update failed"), later, map[enterprisev1.ConditionType]corev1.ConditionStatus{
+		enterprisev1.ConditionReady:             corev1.ConditionFalse,
+		enterprisev1.ConditionProgressing:       corev1.ConditionFalse,
+		enterprisev1.ConditionDegraded:          corev1.ConditionTrue,
+		enterprisev1.ConditionUpgradeInProgress: corev1.ConditionFalse,
+	})
+	if conditions[2].Type != enterprisev1.ConditionDegraded || conditions[2].Message != "StatefulSet update failed" {
+		t.Errorf("GetStatusConditions() degraded condition = %v; want message %s", conditions[2], "StatefulSet update failed")
+	}
+}
+
+func TestGetPausedConditions(t *testing.T) {
+	start := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	later := start.Add(time.Hour)
+	current := GetStatusConditions(nil, enterprisev1.PhaseReady, 2, nil, start)
+
+	// other conditions are left unchanged
+	conditions := GetPausedConditions(current, 3, later)
+	if len(conditions) != len(current)+1 {
+		t.Fatalf("GetPausedConditions() returned %d conditions; want %d", len(conditions), len(current)+1)
+	}
+	for i := range current {
+		if !reflect.DeepEqual(conditions[i], current[i]) {
+			t.Errorf("GetPausedConditions() changed %s condition to %v; want %v", current[i].Type, conditions[i], current[i])
+		}
+	}
+	paused := GetCondition(conditions, enterprisev1.ConditionPaused)
+	if paused == nil || paused.Status != corev1.ConditionTrue || paused.ObservedGeneration != 3 || !paused.LastTransitionTime.Time.Equal(later) {
+		t.Errorf("GetPausedConditions() paused condition = %v; want True for generation 3 at %s", paused, later)
+	}
+
+	// transition time only changes if the custom resource was not already paused
+	conditions = GetPausedConditions(conditions, 4, later.Add(time.Hour))
+	paused = GetCondition(conditions, enterprisev1.ConditionPaused)
+	if len(conditions) != len(current)+1 || paused.ObservedGeneration != 4 || !paused.LastTransitionTime.Time.Equal(later) {
+		t.Errorf("GetPausedConditions() paused condition = %v; want generation 4 at %s", paused, later)
+	}
+
+	// paused conditions are removed once reconciles resume
+	conditions = GetStatusConditions(conditions, enterprisev1.PhaseReady, 4, nil, later)
+	if GetCondition(conditions, enterprisev1.ConditionPaused) != nil {
+		t.Errorf("GetStatusConditions() kept paused condition; want none")
+	}
+}
+
+func TestGetPhaseReason(t *testing.T) {
+	test := func(phase enterprisev1.ResourcePhase, reason, message string, err error, wantReason, wantMessage string) {
+		gotReason, gotMessage := GetPhaseReason(phase, reason, message, err)
+		if gotReason != wantReason || gotMessage != wantMessage {
+			t.Errorf("GetPhaseReason(%s, %s, %s, %v) = %s, %s; want %s, %s", phase, reason, message, err, gotReason, gotMessage, wantReason, wantMessage)
+		}
+	}
+
+	test(enterprisev1.PhaseReady, "", "", nil, "Ready", "")
+	test(enterprisev1.PhaseReady, "WaitingForPods", "waiting for pods", nil, "Ready", "")
+	test(enterprisev1.PhasePending, "", "", nil, "Pending", "")
+	test(enterprisev1.PhasePending, "ClusterMasterNotReady", "waiting for cluster master to be ready", nil, "ClusterMasterNotReady", "waiting for cluster master to be ready")
+	test(enterprisev1.PhaseError, "WaitingForPods", "waiting for pods", fmt.Errorf("StatefulSet update failed"), "ReconcileError", "StatefulSet update failed")
+}
+
+func TestGetLastError(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// nothing is reported while reconciles succeed
+	if got := GetLastError(nil, nil, now); got != nil {
+		t.Errorf("GetLastError(nil, nil) = %v; want nil", got)
+	}
+
+	// the first error is counted once
+	lastError := GetLastError(nil, fmt.Errorf("StatefulSet update failed"), now)
+	want := enterprisev1.ReconcileErrorStatus{Message: "StatefulSet update failed", LastErrorTime: metav1.NewTime(now), Count: 1}
+	if !reflect.DeepEqual(lastError, &want) {
+		t.Errorf("GetLastError(nil, err) = %v; want %v", lastError, want)
+	}
+
+	// consecutive errors are counted, keeping the latest message and time
+	later := now.Add(time.Minute)
+	lastError = GetLastError(lastError, fmt.Errorf("Secret not found"), later)
+	want = enterprisev1.ReconcileErrorStatus{Message: "Secret not found", LastErrorTime: metav1.NewTime(later), Count: 2}
+	if !reflect.DeepEqual(lastError, &want) {
+		t.Errorf("GetLastError(current, err) = %v; want %v", lastError, want)
+	}
+
+	// the error is cleared once a reconcile succeeds
+	if got := GetLastError(lastError, nil, later); got != nil {
+		t.Errorf("GetLastError(current, nil) = %v; want nil", got)
+	}
+}
+
+func TestGetPodNotReadyReason(t *testing.T) {
+	test := func(status corev1.PodStatus, wantReason, wantMessage string) {
+		pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone-2"}, Status: status}
+		gotReason, gotMessage := GetPodNotReadyReason(&pod)
+		if gotReason != wantReason || gotMessage != wantMessage {
+			t.Errorf("GetPodNotReadyReason(%v) = %s, %s; want %s, %s", status, gotReason, gotMessage, wantReason, wantMessage)
+		}
+	}
+
+	ready := []corev1.ContainerStatus{{Name: "splunk", Ready: true}}
+	test(corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: ready}, "", "")
+	test(corev1.PodStatus{}, "PodPending", "pod splunk-stack1-standalone-2 is pending")
+	test(corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Message: "0/3 nodes are available: 3 Insufficient cpu."},
+	}}, "PodUnschedulable", "pod splunk-stack1-standalone-2 cannot be scheduled: 0/3 nodes are available: 3 Insufficient cpu.")
+	test(corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "splunk", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image \"splunk/splunk:bad\""}}},
+	}}, "ImagePullBackOff", "container splunk of pod splunk-stack1-standalone-2 is waiting (ImagePullBackOff): Back-off pulling image \"splunk/splunk:bad\"")
+	test(corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+		{Name: "init", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}}},
+	}}, "Error", "container init of pod splunk-stack1-standalone-2 has terminated (Error)")
+	test(corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{Name: "splunk"}}},
+		"PodNotReady", "waiting for pod splunk-stack1-standalone-2 to become ready")
+	test(corev1.PodStatus{Phase: corev1.PodFailed}, "PodFailed", "pod splunk-stack1-standalone-2 is failed")
+}
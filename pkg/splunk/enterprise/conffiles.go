@@ -0,0 +1,221 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// pod template annotation used to restart pods when conf files change
+	confFilesChecksumAnnotation = "enterprise.splunk.com/conf-files-checksum"
+
+	// ConfFilesAppName is the name of the app used to install conf files
+	ConfFilesAppName = "splunk-operator-conf-files"
+
+	// ConfFilesMarkerConf, ConfFilesMarkerStanza and ConfFilesMarkerKey identify a setting added to conf files that are
+	// reloaded, containing a checksum of the others that is used to determine when they have been updated on an instance
+	ConfFilesMarkerConf   = "splunk_operator"
+	ConfFilesMarkerStanza = "conf_files"
+	ConfFilesMarkerKey    = "checksum"
+)
+
+// confFilesRequiringRestart are the conf files that splunkd only loads at startup, so pods are restarted when they change
+// even if the others are reloaded
+var confFilesRequiringRestart = map[string]bool{
+	"server.conf": true,
+	"web.conf":    true,
+}
+
+// IsConfFilesConfigured returns true if one or more conf files have been configured
+func IsConfFilesConfigured(confFiles []enterprisev1.ConfFileSpec) bool {
+	return len(confFiles) > 0
+}
+
+// validateConfFiles checks validity of the conf files configured for a Splunk Enterprise resource, and returns error if
+// something is wrong.
+func validateConfFiles(confFiles []enterprisev1.ConfFileSpec) error {
+	names := make(map[string]bool)
+	for _, f := range confFiles {
+		if !strings.HasSuffix(f.Name, ".conf") || strings.ContainsAny(f.Name, "/\\") || f.Name == ".conf" {
+			return fmt.Errorf("ConfFiles name must be the name of a .conf file; value=\"%s\"", f.Name)
+		}
+		if f.Name == ConfFilesMarkerConf+".conf" {
+			return fmt.Errorf("ConfFiles name is reserved for use by the operator; value=\"%s\"", f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("ConfFiles names must be unique; value=\"%s\"", f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// getConfFilesAppPath returns the directory used to install conf files for an instance type, or an empty string if conf
+// files are not installed on it. Cluster masters and deployers install them in the bundles that they push to indexer
+// cluster peers and search head cluster members, while other instances install them locally.
+func getConfFilesAppPath(instanceType InstanceType) string {
+	switch instanceType {
+	case SplunkClusterMaster:
+		return GetSplunkHome(instanceType) + "/etc/master-apps/" + ConfFilesAppName + "/local"
+	case SplunkDeployer:
+		return GetSplunkHome(instanceType) + "/etc/shcluster/apps/" + ConfFilesAppName + "/local"
+	case SplunkIndexer, SplunkSearchHead:
+		return ""
+	}
+	return GetSplunkHome(instanceType) + "/etc/apps/" + ConfFilesAppName + "/local"
+}
+
+// IsConfFilesInstaller returns true for instance types that install conf files, either locally or in the bundles that
+// they push to cluster members
+func IsConfFilesInstaller(instanceType InstanceType) bool {
+	return getConfFilesAppPath(instanceType) != ""
+}
+
+// IsConfFilesReloadEnabled returns true if changes to conf files are reloaded using the REST API instead of restarting pods,
+// which is supported for instance types that install conf files locally and are managed by statefulsets
+func IsConfFilesReloadEnabled(strategy string, instanceType InstanceType) bool {
+	switch instanceType {
+	case SplunkStandalone, SplunkLicenseMaster, SplunkMonitoringConsole, SplunkDeploymentServer:
+		return strategy == ConfigUpdateStrategyReload
+	}
+	return false
+}
+
+// GetConfFilesSecret returns a Kubernetes Secret containing the conf files configured for a Splunk Enterprise resource. If
+// changes to them are reloaded, it also contains a marker file with a checksum of the others.
+func GetConfFilesSecret(cr enterprisev1.MetaObject, confFiles []enterprisev1.ConfFileSpec, instanceType InstanceType, strategy string) *corev1.Secret {
+	data := make(map[string][]byte)
+	for _, f := range confFiles {
+		data[f.Name] = []byte(f.Content)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkConfFilesName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}
+
+	if IsConfFilesReloadEnabled(strategy, instanceType) {
+		data[ConfFilesMarkerConf+".conf"] = []byte(fmt.Sprintf("[%s]\n%s = %s\n", ConfFilesMarkerStanza, ConfFilesMarkerKey, GetConfFilesChecksum(secret)))
+	}
+
+	return secret
+}
+
+// getConfFilesDataChecksum returns a checksum of the conf files contained in a Kubernetes Secret for which include returns true.
+func getConfFilesDataChecksum(secret *corev1.Secret, include func(name string) bool) string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		if include(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(secret.Data[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// GetConfFilesChecksum returns a checksum of the conf files contained in a Kubernetes Secret.
+func GetConfFilesChecksum(secret *corev1.Secret) string {
+	return getConfFilesDataChecksum(secret, func(string) bool { return true })
+}
+
+// GetConfFilesReloadMarker returns the checksum contained in the marker file of a Kubernetes Secret containing conf files
+// that are reloaded, or an empty string if secret is nil or they are not reloaded.
+func GetConfFilesReloadMarker(secret *corev1.Secret) string {
+	if secret == nil {
+		return ""
+	}
+	if _, ok := secret.Data[ConfFilesMarkerConf+".conf"]; !ok {
+		return ""
+	}
+	return getConfFilesDataChecksum(secret, func(name string) bool { return name != ConfFilesMarkerConf+".conf" })
+}
+
+// GetReloadableConfFiles returns the sorted names (without the .conf extension) of the conf files contained in a Kubernetes
+// Secret that can be reloaded without restarting splunkd.
+func GetReloadableConfFiles(secret *corev1.Secret) []string {
+	var names []string
+	for k := range secret.Data {
+		if k != ConfFilesMarkerConf+".conf" && !confFilesRequiringRestart[k] {
+			names = append(names, strings.TrimSuffix(k, ".conf"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetConfFilesChecksum annotates a pod template with a checksum of conf files, so that pods are recycled when they change.
+// If they are reloaded, only changes to the conf files that splunkd loads at startup are included. Nothing is done if secret
+// is nil.
+func SetConfFilesChecksum(podTemplateSpec *corev1.PodTemplateSpec, secret *corev1.Secret) {
+	if secret == nil {
+		return
+	}
+	checksum := GetConfFilesChecksum(secret)
+	if GetConfFilesReloadMarker(secret) != "" {
+		checksum = getConfFilesDataChecksum(secret, func(name string) bool { return confFilesRequiringRestart[name] })
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[confFilesChecksumAnnotation] = checksum
+}
+
+// addConfFilesToPodTemplate mounts the conf files configured for a Splunk Enterprise resource as an app, for instance
+// types that install them.
+func addConfFilesToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, confFiles []enterprisev1.ConfFileSpec, instanceType InstanceType) {
+	appPath := getConfFilesAppPath(instanceType)
+	if appPath == "" || !IsConfFilesConfigured(confFiles) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-conf-files",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  GetSplunkConfFilesName(cr.GetIdentifier(), instanceType),
+				DefaultMode: &secretVolDefaultMode,
+			},
+		},
+	})
+
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      "mnt-splunk-conf-files",
+			MountPath: appPath,
+		})
+	}
+}
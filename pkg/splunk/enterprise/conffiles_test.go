@@ -0,0 +1,187 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateConfFiles(t *testing.T) {
+	test := func(confFiles []enterprisev1.ConfFileSpec, wantErr bool) {
+		err := validateConfFiles(confFiles)
+		if wantErr && err == nil {
+			t.Errorf("validateConfFiles(%v) returned nil; want error", confFiles)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateConfFiles(%v) returned %v; want nil", confFiles, err)
+		}
+	}
+
+	test(nil, false)
+	test([]enterprisev1.ConfFileSpec{{Name: "limits.conf"}, {Name: "outputs.conf"}}, false)
+	test([]enterprisev1.ConfFileSpec{{Name: "limits.conf"}, {Name: "limits.conf"}}, true)
+	test([]enterprisev1.ConfFileSpec{{Name: "limits.txt"}}, true)
+	test([]enterprisev1.ConfFileSpec{{Name: "../system/local/limits.conf"}}, true)
+	test([]enterprisev1.ConfFileSpec{{Name: ".conf"}}, true)
+	test([]enterprisev1.ConfFileSpec{{Name: "splunk_operator.conf"}}, true)
+}
+
+func TestGetConfFilesAppPath(t *testing.T) {
+	test := func(instanceType InstanceType, want string) {
+		if got := getConfFilesAppPath(instanceType); got != want {
+			t.Errorf("getConfFilesAppPath(%s) = %s; want %s", instanceType, got, want)
+		}
+	}
+
+	test(SplunkStandalone, "/opt/splunk/etc/apps/splunk-operator-conf-files/local")
+	test(SplunkClusterMaster, "/opt/splunk/etc/master-apps/splunk-operator-conf-files/local")
+	test(SplunkDeployer, "/opt/splunk/etc/shcluster/apps/splunk-operator-conf-files/local")
+	test(SplunkUniversalForwarder, "/opt/splunkforwarder/etc/apps/splunk-operator-conf-files/local")
+	test(SplunkIndexer, "")
+	test(SplunkSearchHead, "")
+}
+
+func TestGetConfFilesSecret(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	confFiles := []enterprisev1.ConfFileSpec{{Name: "limits.conf", Content: "[search]\nmax_searches_per_cpu = 2\n"}}
+
+	secret := GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyRestart)
+	if secret.GetName() != "splunk-stack1-standalone-conf-files" {
+		t.Errorf("GetConfFilesSecret() name = %s; want %s", secret.GetName(), "splunk-stack1-standalone-conf-files")
+	}
+	if got := string(secret.Data["limits.conf"]); got != confFiles[0].Content {
+		t.Errorf("GetConfFilesSecret() limits.conf = %s; want %s", got, confFiles[0].Content)
+	}
+
+	// checksum changes with the contents of the files
+	checksum := GetConfFilesChecksum(secret)
+	confFiles[0].Content = "[search]\nmax_searches_per_cpu = 4\n"
+	if GetConfFilesChecksum(GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyRestart)) == checksum {
+		t.Errorf("GetConfFilesChecksum() did not change with contents")
+	}
+	if _, ok := secret.Data["splunk_operator.conf"]; ok {
+		t.Errorf("GetConfFilesSecret() included marker file; want none when restarting pods")
+	}
+}
+
+func TestConfFilesReload(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	confFiles := []enterprisev1.ConfFileSpec{
+		{Name: "limits.conf", Content: "[search]\nmax_searches_per_cpu = 2\n"},
+		{Name: "server.conf", Content: "[general]\nparallelIngestionPipelines = 2\n"},
+	}
+
+	test := func(strategy string, instanceType InstanceType, want bool) {
+		if got := IsConfFilesReloadEnabled(strategy, instanceType); got != want {
+			t.Errorf("IsConfFilesReloadEnabled(%s, %s) = %t; want %t", strategy, instanceType, got, want)
+		}
+	}
+	test(ConfigUpdateStrategyReload, SplunkStandalone, true)
+	test(ConfigUpdateStrategyReload, SplunkDeploymentServer, true)
+	test(ConfigUpdateStrategyRestart, SplunkStandalone, false)
+	test(ConfigUpdateStrategyReload, SplunkClusterMaster, false)
+	test(ConfigUpdateStrategyReload, SplunkUniversalForwarder, false)
+
+	// marker contains a checksum of the other files
+	restart := GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyRestart)
+	reload := GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyReload)
+	if GetConfFilesReloadMarker(restart) != "" || GetConfFilesReloadMarker(nil) != "" {
+		t.Errorf("GetConfFilesReloadMarker() returned a checksum; want empty when restarting pods")
+	}
+	want := fmt.Sprintf("[conf_files]\nchecksum = %s\n", GetConfFilesChecksum(restart))
+	if got := string(reload.Data["splunk_operator.conf"]); got != want {
+		t.Errorf("GetConfFilesSecret() splunk_operator.conf = %s; want %s", got, want)
+	}
+	if got := GetConfFilesReloadMarker(reload); got != GetConfFilesChecksum(restart) {
+		t.Errorf("GetConfFilesReloadMarker() = %s; want %s", got, GetConfFilesChecksum(restart))
+	}
+	if got := GetReloadableConfFiles(reload); !reflect.DeepEqual(got, []string{"limits"}) {
+		t.Errorf("GetReloadableConfFiles() = %v; want %v", got, []string{"limits"})
+	}
+
+	// only changes to conf files that require a restart recycle pods when the others are reloaded
+	annotation := func(secret *corev1.Secret) string {
+		podTemplateSpec := corev1.PodTemplateSpec{}
+		SetConfFilesChecksum(&podTemplateSpec, secret)
+		return podTemplateSpec.ObjectMeta.Annotations["enterprise.splunk.com/conf-files-checksum"]
+	}
+	checksum := annotation(reload)
+	confFiles[0].Content = "[search]\nmax_searches_per_cpu = 4\n"
+	if annotation(GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyReload)) != checksum {
+		t.Errorf("SetConfFilesChecksum() changed after reloadable conf file changed")
+	}
+	if annotation(GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyRestart)) == annotation(restart) {
+		t.Errorf("SetConfFilesChecksum() did not change after conf file changed")
+	}
+	confFiles[1].Content = "[general]\nparallelIngestionPipelines = 4\n"
+	if annotation(GetConfFilesSecret(&cr, confFiles, SplunkStandalone, ConfigUpdateStrategyReload)) == checksum {
+		t.Errorf("SetConfFilesChecksum() did not change after server.conf changed")
+	}
+	if annotation(nil) != "" {
+		t.Errorf("SetConfFilesChecksum(nil) added annotation")
+	}
+}
+
+func TestAddConfFilesToPodTemplate(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	confFiles := []enterprisev1.ConfFileSpec{{Name: "indexes.conf", Content: "[main]\nfrozenTimePeriodInSecs = 86400\n"}}
+
+	test := func(confFiles []enterprisev1.ConfFileSpec, instanceType InstanceType, wantMount string) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		addConfFilesToPodTemplate(&podTemplateSpec, &cr, confFiles, instanceType)
+		mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+		if wantMount == "" {
+			if len(mounts) != 0 || len(podTemplateSpec.Spec.Volumes) != 0 {
+				t.Errorf("addConfFilesToPodTemplate(%s) added volumes; want none", instanceType)
+			}
+			return
+		}
+		if len(mounts) != 1 || mounts[0].MountPath != wantMount {
+			t.Errorf("addConfFilesToPodTemplate(%s) mounts = %v; want %s", instanceType, mounts, wantMount)
+		}
+		if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Secret.SecretName != "splunk-stack1-indexer-conf-files" {
+			t.Errorf("addConfFilesToPodTemplate(%s) volumes = %v; want secret %s", instanceType, podTemplateSpec.Spec.Volumes, "splunk-stack1-indexer-conf-files")
+		}
+	}
+
+	test(nil, SplunkClusterMaster, "")
+	test(confFiles, SplunkClusterMaster, "/opt/splunk/etc/master-apps/splunk-operator-conf-files/local")
+	test(confFiles, SplunkIndexer, "")
+}
@@ -16,40 +16,60 @@ package enterprise
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 	"github.com/splunk/splunk-operator/pkg/splunk/spark"
 )
 
+// siteNameRegex is used to validate the names of sites in a multisite indexer cluster
+var siteNameRegex = regexp.MustCompile(`^site([1-9]|[1-5][0-9]|6[0-3])$`)
+
+// splunkHealthCommand is a shell command used by probes to check the splunkd health endpoint, which fails if the health of splunkd is red
+const splunkHealthCommand = `curl -ksf -u "admin:$(cat /mnt/splunk-secrets/password)" "https://localhost:8089/services/server/health/splunkd?output_mode=json" | grep -qv '"health":"red"'`
+
 // getSplunkLabels returns a map of labels to use for Splunk Enterprise components.
 func getSplunkLabels(identifier string, instanceType InstanceType) map[string]string {
 	return resources.GetLabels(instanceType.ToKind(), instanceType.ToString(), identifier)
 }
 
-// getSplunkVolumeClaims returns a standard collection of Kubernetes volume claims.
+// getSplunkLabelsForCR returns the labels used for instances of a given InstanceType owned by a custom resource. The cluster
+// master of a ClusterMaster is its own component, so that it is never selected along with an IndexerCluster of the same name.
+func getSplunkLabelsForCR(cr enterprisev1.MetaObject, instanceType InstanceType) map[string]string {
+	if _, ok := cr.(*enterprisev1.ClusterMaster); ok {
+		return resources.GetLabels(SplunkClusterMaster.ToString(), instanceType.ToString(), cr.GetIdentifier())
+	}
+	return getSplunkLabels(cr.GetIdentifier(), instanceType)
+}
+
+// getSplunkVolumeClaims returns a standard collection of Kubernetes volume claims, excluding any volumes that use ephemeral storage.
 func getSplunkVolumeClaims(cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, labels map[string]string) ([]corev1.PersistentVolumeClaim, error) {
 	var etcStorage, varStorage resource.Quantity
 	var err error
 
-	etcStorage, err = resources.ParseResourceQuantity(spec.EtcStorage, "10Gi")
+	etcStorage, err = resources.ParseResourceQuantity(spec.EtcStorage.StorageCapacity, defaultEtcStorage)
 	if err != nil {
 		return []corev1.PersistentVolumeClaim{}, fmt.Errorf("%s: %s", "etcStorage", err)
 	}
 
-	varStorage, err = resources.ParseResourceQuantity(spec.VarStorage, "100Gi")
+	varStorage, err = resources.ParseResourceQuantity(spec.VarStorage.StorageCapacity, defaultVarStorage)
 	if err != nil {
 		return []corev1.PersistentVolumeClaim{}, fmt.Errorf("%s: %s", "varStorage", err)
 	}
 
-	volumeClaims := []corev1.PersistentVolumeClaim{
-		{
+	volumeClaims := []corev1.PersistentVolumeClaim{}
+	if !spec.EtcStorage.Ephemeral {
+		volumeClaims = append(volumeClaims, corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "etc",
 				Namespace: cr.GetNamespace(),
@@ -62,9 +82,12 @@ func getSplunkVolumeClaims(cr enterprisev1.MetaObject, spec *enterprisev1.Common
 						corev1.ResourceStorage: etcStorage,
 					},
 				},
+				StorageClassName: getVolumeStorageClassName(spec, &spec.EtcStorage),
 			},
-		},
-		{
+		})
+	}
+	if !spec.VarStorage.Ephemeral {
+		volumeClaims = append(volumeClaims, corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "var",
 				Namespace: cr.GetNamespace(),
@@ -77,17 +100,23 @@ func getSplunkVolumeClaims(cr enterprisev1.MetaObject, spec *enterprisev1.Common
 						corev1.ResourceStorage: varStorage,
 					},
 				},
+				StorageClassName: getVolumeStorageClassName(spec, &spec.VarStorage),
 			},
-		},
+		})
 	}
 
+	return volumeClaims, nil
+}
+
+// getVolumeStorageClassName returns the name of the StorageClass to use for a volume, or nil to use the cluster's default.
+func getVolumeStorageClassName(spec *enterprisev1.CommonSplunkSpec, storage *enterprisev1.StorageSpec) *string {
+	if storage.StorageClassName != "" {
+		return &storage.StorageClassName
+	}
 	if spec.StorageClassName != "" {
-		for idx := range volumeClaims {
-			volumeClaims[idx].Spec.StorageClassName = &spec.StorageClassName
-		}
+		return &spec.StorageClassName
 	}
-
-	return volumeClaims, nil
+	return nil
 }
 
 // GetStandaloneStatefulSet returns a Kubernetes StatefulSet object for Splunk Enterprise standalone instances.
@@ -100,18 +129,26 @@ func GetStandaloneStatefulSet(cr *enterprisev1.Standalone) (*appsv1.StatefulSet,
 	}
 
 	// add spark and java mounts to search head containers
-	if cr.Spec.SparkRef.Name != "" {
-		addDFCToPodTemplate(&ss.Spec.Template, cr.Spec.SparkRef, cr.Spec.SparkImage, cr.Spec.ImagePullPolicy, cr.Spec.Replicas > 1)
+	if sparkMasterEnv := getSparkMasterEnv(cr.Spec.SparkRef, cr.Spec.SparkMasterURL, cr.Spec.SparkMasterWebUIPort); sparkMasterEnv != nil {
+		addDFCToPodTemplate(&ss.Spec.Template, sparkMasterEnv, cr.Spec.SparkImage, cr.Spec.ImagePullPolicy, cr.Spec.Replicas > 1)
 	}
 
+	// add sidecar containers last, so that they are not modified by splunk configuration
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+
 	return ss, nil
 }
 
 // GetSearchHeadStatefulSet returns a Kubernetes StatefulSet object for Splunk Enterprise search heads.
 func GetSearchHeadStatefulSet(cr *enterprisev1.SearchHeadCluster) (*appsv1.StatefulSet, error) {
+	return getSearchHeadStatefulSet(cr, GetSearchHeadClusterActiveColor(cr))
+}
+
+// getSearchHeadStatefulSet returns a Kubernetes StatefulSet object for the Splunk Enterprise search heads of a color.
+func getSearchHeadStatefulSet(cr *enterprisev1.SearchHeadCluster, color string) (*appsv1.StatefulSet, error) {
 
 	// get search head env variables with deployer
-	env := getSearchHeadExtraEnv(cr, cr.Spec.Replicas)
+	env := getSearchHeadExtraEnv(cr.GetNamespace(), GetSearchHeadClusterIdentifier(cr, color), cr.Spec.Replicas)
 	env = append(env, corev1.EnvVar{
 		Name:  "SPLUNK_DEPLOYER_URL",
 		Value: GetSplunkServiceName(SplunkDeployer, cr.GetIdentifier(), false),
@@ -122,33 +159,156 @@ func GetSearchHeadStatefulSet(cr *enterprisev1.SearchHeadCluster) (*appsv1.State
 	if err != nil {
 		return nil, err
 	}
+	setSearchHeadClusterColor(ss, cr, color)
 
 	// add spark and java mounts to search head containers
-	if cr.Spec.SparkRef.Name != "" {
-		addDFCToPodTemplate(&ss.Spec.Template, cr.Spec.SparkRef, cr.Spec.SparkImage, cr.Spec.ImagePullPolicy, cr.Spec.Replicas > 1)
+	if sparkMasterEnv := getSparkMasterEnv(cr.Spec.SparkRef, cr.Spec.SparkMasterURL, cr.Spec.SparkMasterWebUIPort); sparkMasterEnv != nil {
+		addDFCToPodTemplate(&ss.Spec.Template, sparkMasterEnv, cr.Spec.SparkImage, cr.Spec.ImagePullPolicy, cr.Spec.Replicas > 1)
 	}
 
+	// add sidecar containers last, so that they are not modified by splunk configuration
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+
 	return ss, nil
 }
 
 // GetIndexerStatefulSet returns a Kubernetes StatefulSet object for Splunk Enterprise indexers.
 func GetIndexerStatefulSet(cr *enterprisev1.IndexerCluster) (*appsv1.StatefulSet, error) {
-	return getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, cr.Spec.Replicas, getIndexerExtraEnv(cr, cr.Spec.Replicas))
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, cr.Spec.Replicas, getIndexerExtraEnv(cr, cr.Spec.Replicas))
+	if err != nil {
+		return nil, err
+	}
+	addIndexerDiscoveryToPodTemplate(&ss.Spec.Template, cr, &cr.Spec.IndexerDiscovery, SplunkIndexer)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	setPeerRestartAnnotation(&ss.Spec.Template, cr)
+	return ss, nil
+}
+
+// GetIndexerSiteStatefulSet returns a Kubernetes StatefulSet object for the Splunk Enterprise indexers of one site within a multisite indexer cluster.
+func GetIndexerSiteStatefulSet(cr *enterprisev1.IndexerCluster, site enterprisev1.IndexerClusterSiteSpec) (*appsv1.StatefulSet, error) {
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, site.Replicas, getMultisiteExtraEnv(cr, site.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	// each site has its own statefulset and headless service, with pods selected using an additional site label
+	siteIdentifier := GetSplunkSiteIdentifier(cr.GetIdentifier(), site.Name)
+	ss.ObjectMeta.Name = GetSplunkStatefulsetName(SplunkIndexer, siteIdentifier)
+	ss.Spec.ServiceName = GetSplunkServiceName(SplunkIndexer, siteIdentifier, true)
+	ss.Spec.Selector.MatchLabels[siteLabelKey] = site.Name
+	ss.Spec.Template.ObjectMeta.Labels[siteLabelKey] = site.Name
+
+	addIndexerDiscoveryToPodTemplate(&ss.Spec.Template, cr, &cr.Spec.IndexerDiscovery, SplunkIndexer)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	setPeerRestartAnnotation(&ss.Spec.Template, cr)
+	return ss, nil
+}
+
+// GetIndexerSiteService returns a headless Kubernetes Service object for the Splunk Enterprise indexers of one site within a multisite indexer cluster.
+func GetIndexerSiteService(cr *enterprisev1.IndexerCluster, site string) *corev1.Service {
+	service := GetSplunkService(cr, cr.Spec.CommonSpec, SplunkIndexer, true)
+	service.ObjectMeta.Name = GetSplunkServiceName(SplunkIndexer, GetSplunkSiteIdentifier(cr.GetIdentifier(), site), true)
+	service.Spec.Selector[siteLabelKey] = site
+	service.ObjectMeta.Labels[siteLabelKey] = site
+	return service
+}
+
+// GetSplunkPodDisruptionBudget returns a Kubernetes PodDisruptionBudget object for the pods of a StatefulSet, which allows
+// no more than maxUnavailable of them to be evicted at the same time.
+func GetSplunkPodDisruptionBudget(statefulSet *appsv1.StatefulSet, maxUnavailable int32) *policyv1beta1.PodDisruptionBudget {
+	labels := make(map[string]string)
+	for k, v := range statefulSet.Spec.Selector.MatchLabels {
+		labels[k] = v
+	}
+	selectLabels := make(map[string]string)
+	for k, v := range labels {
+		selectLabels[k] = v
+	}
+	maxUnavailableValue := intstr.FromInt(int(maxUnavailable))
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      statefulSet.GetName(),
+			Namespace: statefulSet.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectLabels,
+			},
+			MaxUnavailable: &maxUnavailableValue,
+		},
+	}
+	pdb.SetOwnerReferences(append(pdb.GetOwnerReferences(), statefulSet.GetOwnerReferences()...))
+
+	return pdb
+}
+
+// GetIndexerClusterMasterStatefulSet returns a Kubernetes StatefulSet object for the cluster master managed by a Splunk Enterprise indexer cluster.
+func GetIndexerClusterMasterStatefulSet(cr *enterprisev1.IndexerCluster) (*appsv1.StatefulSet, error) {
+	var extraEnv []corev1.EnvVar
+	if len(cr.Spec.Sites) > 0 {
+		// cluster master belongs to the first site of a multisite indexer cluster
+		extraEnv = getMultisiteExtraEnv(cr, cr.Spec.Sites[0].Name)
+	} else {
+		extraEnv = getIndexerExtraEnv(cr, cr.Spec.Replicas)
+	}
+	extraEnv = append(extraEnv, getClusterFactorsExtraEnv(cr.Spec.ReplicationFactor, cr.Spec.SearchFactor)...)
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkClusterMaster, 1, extraEnv)
+	if err != nil {
+		return nil, err
+	}
+	addIndexerDiscoveryToPodTemplate(&ss.Spec.Template, cr, &cr.Spec.IndexerDiscovery, SplunkClusterMaster)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
 }
 
-// GetClusterMasterStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise license master.
-func GetClusterMasterStatefulSet(cr *enterprisev1.IndexerCluster) (*appsv1.StatefulSet, error) {
-	return getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkClusterMaster, 1, getIndexerExtraEnv(cr, cr.Spec.Replicas))
+// GetClusterMasterStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise cluster master.
+func GetClusterMasterStatefulSet(cr *enterprisev1.ClusterMaster) (*appsv1.StatefulSet, error) {
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkClusterMaster, 1, getClusterMasterExtraEnv(cr))
+	if err != nil {
+		return nil, err
+	}
+	addIndexerDiscoveryToPodTemplate(&ss.Spec.Template, cr, &cr.Spec.IndexerDiscovery, SplunkClusterMaster)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
 }
 
 // GetDeployerStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise license master.
 func GetDeployerStatefulSet(cr *enterprisev1.SearchHeadCluster) (*appsv1.StatefulSet, error) {
-	return getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkDeployer, 1, getSearchHeadExtraEnv(cr, cr.Spec.Replicas))
+	// the deployer pushes apps to the search head cluster members currently serving searches
+	env := getSearchHeadExtraEnv(cr.GetNamespace(), GetSearchHeadClusterIdentifier(cr, GetSearchHeadClusterActiveColor(cr)), cr.Spec.Replicas)
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkDeployer, 1, env)
+	if err != nil {
+		return nil, err
+	}
+	addConfigMapAppsToPodTemplate(&ss.Spec.Template, cr.Spec.DeployerApps, deployerAppsPath)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
 }
 
 // GetLicenseMasterStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise license master.
 func GetLicenseMasterStatefulSet(cr *enterprisev1.LicenseMaster) (*appsv1.StatefulSet, error) {
-	return getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkLicenseMaster, 1, []corev1.EnvVar{})
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkLicenseMaster, 1, []corev1.EnvVar{})
+	if err != nil {
+		return nil, err
+	}
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
+}
+
+// GetMonitoringConsoleStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise monitoring console.
+func GetMonitoringConsoleStatefulSet(cr *enterprisev1.MonitoringConsole) (*appsv1.StatefulSet, error) {
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkMonitoringConsole, 1, []corev1.EnvVar{})
+	if err != nil {
+		return nil, err
+	}
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
 }
 
 // GetSplunkService returns a Kubernetes Service object for Splunk instances configured for a Splunk Enterprise resource.
@@ -162,6 +322,9 @@ func GetSplunkService(cr enterprisev1.MetaObject, spec enterprisev1.CommonSpec,
 		// Initialize to defaults
 		service.Spec.ClusterIP = corev1.ClusterIPNone
 		service.Spec.Type = corev1.ServiceTypeClusterIP
+
+		// use labels from template, but not annotations, which are usually specific to load balancers
+		service.ObjectMeta.Labels = resources.CopyLabels(spec.ServiceTemplate.ObjectMeta.Labels)
 	} else {
 		service = spec.ServiceTemplate.DeepCopy()
 	}
@@ -172,7 +335,7 @@ func GetSplunkService(cr enterprisev1.MetaObject, spec enterprisev1.CommonSpec,
 
 	service.ObjectMeta.Name = GetSplunkServiceName(instanceType, cr.GetIdentifier(), isHeadless)
 	service.ObjectMeta.Namespace = cr.GetNamespace()
-	service.Spec.Selector = getSplunkLabels(cr.GetIdentifier(), instanceType)
+	service.Spec.Selector = getSplunkLabelsForCR(cr, instanceType)
 	service.Spec.Ports = append(service.Spec.Ports, resources.SortServicePorts(getSplunkServicePorts(instanceType))...) // note that port order is important for tests
 
 	// ensure labels and annotations are not nil
@@ -247,10 +410,51 @@ func setServiceTemplateDefaults(spec *enterprisev1.CommonSplunkSpec) {
 	}
 }
 
+// validateStorageSpec checks validity of a StorageSpec, and returns error if something is wrong. This is checked here
+// rather than by the CRD schema, since storage may be given as either a string or an object.
+func validateStorageSpec(name string, storage *enterprisev1.StorageSpec) error {
+	if _, err := resources.ParseResourceQuantity(storage.StorageCapacity, ""); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	if storage.Ephemeral && storage.StorageClassName != "" {
+		return fmt.Errorf("%s: storageClassName cannot be used with ephemeral storage; storageClassName=\"%s\"", name, storage.StorageClassName)
+	}
+	return nil
+}
+
 // validateCommonSplunkSpec checks validity and makes default updates to a CommonSplunkSpec, and returns error if something is wrong.
 func validateCommonSplunkSpec(spec *enterprisev1.CommonSplunkSpec) error {
 	// if not specified via spec or env, image defaults to splunk/splunk
 	spec.CommonSpec.Image = GetSplunkImage(spec.CommonSpec.Image)
+	if err := ValidateImage(spec.CommonSpec.Image); err != nil {
+		return err
+	}
+
+	// if not specified via spec or env, storage class is left empty to use the cluster's default
+	spec.StorageClassName = GetStorageClassName(spec.StorageClassName)
+	if spec.EtcStorage.StorageCapacity == "" {
+		spec.EtcStorage.StorageCapacity = defaultEtcStorage
+	}
+	if spec.VarStorage.StorageCapacity == "" {
+		spec.VarStorage.StorageCapacity = defaultVarStorage
+	}
+	if err := validateStorageSpec("etcStorage", &spec.EtcStorage); err != nil {
+		return err
+	}
+	if err := validateStorageSpec("varStorage", &spec.VarStorage); err != nil {
+		return err
+	}
+	if spec.PVCCleanupPolicy == "" {
+		spec.PVCCleanupPolicy = PVCCleanupPolicyRetain
+	} else if spec.PVCCleanupPolicy != PVCCleanupPolicyRetain && spec.PVCCleanupPolicy != PVCCleanupPolicyDelete {
+		return fmt.Errorf("pvcCleanupPolicy must be either \"%s\" or \"%s\"; value=\"%s\"", PVCCleanupPolicyRetain, PVCCleanupPolicyDelete, spec.PVCCleanupPolicy)
+	}
+	if err := validateUnmanagedFields(spec.UnmanagedFields); err != nil {
+		return err
+	}
+	if err := validateExternalLicenseMaster(spec); err != nil {
+		return err
+	}
 
 	defaultResources := corev1.ResourceRequirements{
 		Requests: corev1.ResourceList{
@@ -266,13 +470,278 @@ func validateCommonSplunkSpec(spec *enterprisev1.CommonSplunkSpec) error {
 	setVolumeDefaults(spec)
 	setServiceTemplateDefaults(spec)
 
+	if err := validateSmartStoreSpec(&spec.SmartStore); err != nil {
+		return err
+	}
+
+	if err := validateAppRepoSpec(&spec.AppRepo); err != nil {
+		return err
+	}
+
+	if err := validateConfFiles(spec.ConfFiles); err != nil {
+		return err
+	}
+	if spec.ConfigUpdateStrategy == "" {
+		spec.ConfigUpdateStrategy = ConfigUpdateStrategyRestart
+	} else if spec.ConfigUpdateStrategy != ConfigUpdateStrategyRestart && spec.ConfigUpdateStrategy != ConfigUpdateStrategyReload {
+		return fmt.Errorf("configUpdateStrategy must be either \"%s\" or \"%s\"; value=\"%s\"", ConfigUpdateStrategyRestart, ConfigUpdateStrategyReload, spec.ConfigUpdateStrategy)
+	}
+
+	if err := validateSecretRotationInterval(spec.SecretRotationInterval); err != nil {
+		return err
+	}
+
+	if err := validateSecretsProviderSpec(&spec.SecretsProvider); err != nil {
+		return err
+	}
+
+	// values provided using secretRef or a secrets provider are managed outside of the operator, which must not replace them
+	if spec.SecretRef != "" && IsSecretsProviderConfigured(&spec.SecretsProvider) {
+		return fmt.Errorf("SecretsProvider cannot be used with SecretRef; value=\"%s\"", spec.SecretRef)
+	}
+	if (spec.SecretRef != "" || IsSecretsProviderConfigured(&spec.SecretsProvider)) && spec.SecretRotationInterval != "" {
+		return fmt.Errorf("SecretRotationInterval cannot be used with SecretRef or SecretsProvider; value=\"%s\"", spec.SecretRotationInterval)
+	}
+
+	if err := validateTLSSpec(&spec.TLS); err != nil {
+		return err
+	}
+
+	if err := validateExtraEnv(spec.ExtraEnv); err != nil {
+		return err
+	}
+
+	if err := validateSidecarContainers(spec); err != nil {
+		return err
+	}
+
+	if err := validateInitContainers(spec); err != nil {
+		return err
+	}
+
+	if err := validateIngressSpec(&spec.Ingress); err != nil {
+		return err
+	}
+	if err := validateNetworkPolicySpec(&spec.NetworkPolicy); err != nil {
+		return err
+	}
+	if err := validateServiceMeshSpec(&spec.ServiceMesh); err != nil {
+		return err
+	}
+	if err := validateVeleroSpec(spec); err != nil {
+		return err
+	}
+	if err := validateServiceTypesSpec(&spec.ServiceTypes); err != nil {
+		return err
+	}
+
+	if err := validateMonitoringSpec(&spec.Monitoring); err != nil {
+		return err
+	}
+
+	if err := validateProbeSpec("LivenessProbe", &spec.LivenessProbe); err != nil {
+		return err
+	}
+
+	if err := validateProbeSpec("ReadinessProbe", &spec.ReadinessProbe); err != nil {
+		return err
+	}
+
+	if spec.TerminationGracePeriodSeconds < 0 {
+		return fmt.Errorf("TerminationGracePeriodSeconds must not be negative; value=%d", spec.TerminationGracePeriodSeconds)
+	}
+
+	// premium apps require more resources than the defaults used for other instances
+	if HasPremiumApps(&spec.AppRepo) {
+		defaultResources = getPremiumAppDefaultResources()
+	}
+
 	return resources.ValidateCommonSpec(&spec.CommonSpec, defaultResources)
 }
 
+// validateSiteName checks validity of a site name that has not already been seen, and returns error if something is wrong.
+func validateSiteName(name string, siteNames map[string]bool) error {
+	if !siteNameRegex.MatchString(name) {
+		return fmt.Errorf("Site name must be one of site1 through site63; value=\"%s\"", name)
+	}
+	if siteNames[name] {
+		return fmt.Errorf("Site names must be unique; value=\"%s\"", name)
+	}
+	siteNames[name] = true
+	return nil
+}
+
+// validateSiteFactors checks validity of the site replication and search factors of a multisite indexer cluster, and returns error if something is wrong.
+func validateSiteFactors(replicationFactor, searchFactor enterprisev1.IndexerClusterSiteFactor) error {
+	for name, factor := range map[string]enterprisev1.IndexerClusterSiteFactor{
+		"siteReplicationFactor": replicationFactor,
+		"siteSearchFactor":      searchFactor,
+	} {
+		if factor.Total != 0 && factor.Total < factor.Origin {
+			return fmt.Errorf("%s total must be greater than or equal to origin; origin=%d, total=%d", name, factor.Origin, factor.Total)
+		}
+	}
+	return nil
+}
+
+// validateClusterFactors checks validity of the replication and search factors of an indexer cluster with a number of peers
+// (or 0 if it is not known), and returns error if something is wrong.
+func validateClusterFactors(replicationFactor, searchFactor, replicas int32) error {
+	if replicationFactor < 0 || searchFactor < 0 {
+		return fmt.Errorf("ReplicationFactor and SearchFactor must not be negative; replicationFactor=%d, searchFactor=%d", replicationFactor, searchFactor)
+	}
+	if replicationFactor != 0 && searchFactor > replicationFactor {
+		return fmt.Errorf("SearchFactor must be less than or equal to ReplicationFactor; replicationFactor=%d, searchFactor=%d", replicationFactor, searchFactor)
+	}
+	if replicas != 0 && replicationFactor > replicas {
+		return fmt.Errorf("ReplicationFactor must be less than or equal to the number of indexer cluster peers; replicationFactor=%d, replicas=%d", replicationFactor, replicas)
+	}
+	return nil
+}
+
+// validateIndexerClusterScalingSchedule checks validity of the scaling schedule of an IndexerClusterSpec, which must not scale
+// to fewer peers than the replication factor, and returns error if something is wrong.
+func validateIndexerClusterScalingSchedule(spec *enterprisev1.IndexerClusterSpec) error {
+	if len(spec.ScalingSchedule) == 0 {
+		return nil
+	}
+	if len(spec.Sites) > 0 {
+		return fmt.Errorf("ScalingSchedule is not supported for multisite indexer clusters")
+	}
+	minReplicas := spec.ReplicationFactor
+	if minReplicas < 1 {
+		minReplicas = 1
+	}
+	return validateScalingSchedule(spec.ScalingSchedule, minReplicas)
+}
+
+// validateIndexerClusterSites checks validity and makes default updates to the sites of a multisite IndexerClusterSpec, and returns error if something is wrong.
+func validateIndexerClusterSites(spec *enterprisev1.IndexerClusterSpec) error {
+	siteNames := make(map[string]bool)
+	spec.Replicas = 0
+	for idx := range spec.Sites {
+		site := &spec.Sites[idx]
+		if err := validateSiteName(site.Name, siteNames); err != nil {
+			return err
+		}
+		if site.Replicas == 0 {
+			site.Replicas = 1
+		}
+		spec.Replicas += site.Replicas
+	}
+
+	return validateSiteFactors(spec.SiteReplicationFactor, spec.SiteSearchFactor)
+}
+
+// validateClusterMasterRef checks that an IndexerClusterSpec which references a ClusterMaster, or joins an external cluster
+// master, does not configure anything that is managed by the cluster master, and returns error if something is wrong.
+func validateClusterMasterRef(spec *enterprisev1.IndexerClusterSpec) error {
+	var clusterMaster string
+	switch {
+	case spec.ClusterMasterRef.Name != "":
+		clusterMaster = "the ClusterMaster referenced by clusterMasterRef"
+	case IsExternalClusterMasterConfigured(&spec.ExternalClusterMaster):
+		clusterMaster = "the external cluster master"
+	default:
+		return nil
+	}
+	if IsSmartStoreConfigured(&spec.SmartStore) {
+		return fmt.Errorf("SmartStore must be configured on %s", clusterMaster)
+	}
+	if IsAppRepoConfigured(&spec.AppRepo) {
+		return fmt.Errorf("AppRepo must be configured on %s", clusterMaster)
+	}
+	if IsConfFilesConfigured(spec.ConfFiles) {
+		return fmt.Errorf("ConfFiles must be configured on %s", clusterMaster)
+	}
+	if spec.SiteReplicationFactor != (enterprisev1.IndexerClusterSiteFactor{}) || spec.SiteSearchFactor != (enterprisev1.IndexerClusterSiteFactor{}) {
+		return fmt.Errorf("Site replication and search factors must be configured on %s", clusterMaster)
+	}
+	if spec.ReplicationFactor != 0 || spec.SearchFactor != 0 {
+		return fmt.Errorf("Replication and search factors must be configured on %s", clusterMaster)
+	}
+	return nil
+}
+
+// SetIndexerClusterCreateDefaults makes default updates to the IndexerClusterSpec of an indexer cluster that is being
+// created. These defaults are not applied by ValidateIndexerClusterSpec, so that existing indexer clusters are unchanged.
+func SetIndexerClusterCreateDefaults(spec *enterprisev1.IndexerClusterSpec) {
+	if len(spec.Sites) == 0 && spec.Replicas == 0 {
+		spec.Replicas = defaultNewIndexerClusterReplicas
+	}
+}
+
+// SetIndexerClusterUpdateDefaults makes default updates to the IndexerClusterSpec of an existing indexer cluster that is
+// being updated, keeping the number of peers from its previous spec if none are specified, so that replacing it with a
+// manifest that omits replicas does not scale it down.
+func SetIndexerClusterUpdateDefaults(spec, oldSpec *enterprisev1.IndexerClusterSpec) {
+	if len(spec.Sites) == 0 && spec.Replicas == 0 {
+		spec.Replicas = oldSpec.Replicas
+	}
+}
+
 // ValidateIndexerClusterSpec checks validity and makes default updates to a IndexerClusterSpec, and returns error if something is wrong.
 func ValidateIndexerClusterSpec(spec *enterprisev1.IndexerClusterSpec) error {
-	if spec.Replicas == 0 {
-		spec.Replicas = 1
+	if len(spec.Sites) > 0 {
+		if err := validateIndexerClusterSites(spec); err != nil {
+			return err
+		}
+	} else if spec.Replicas == 0 {
+		spec.Replicas = defaultIndexerClusterReplicas
+	}
+	if err := validateMaxUnavailable(&spec.MaxUnavailable); err != nil {
+		return err
+	}
+	if err := validateExternalClusterMaster(spec); err != nil {
+		return err
+	}
+	if err := validateClusterMasterRef(spec); err != nil {
+		return err
+	}
+	if err := validateClusterFactors(spec.ReplicationFactor, spec.SearchFactor, spec.Replicas); err != nil {
+		return err
+	}
+	if err := validateIndexerClusterScalingSchedule(spec); err != nil {
+		return err
+	}
+	if err := validateNoPremiumApps(&spec.AppRepo); err != nil {
+		return err
+	}
+	if err := validateIndexerDiscoverySpec(&spec.IndexerDiscovery); err != nil {
+		return err
+	}
+	if err := validateIndexerPodServicesSpec(&spec.PodServices); err != nil {
+		return err
+	}
+	if err := validateRollingRestartMode(spec); err != nil {
+		return err
+	}
+	if err := validateDetentionSpec(&spec.Detention); err != nil {
+		return err
+	}
+	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
+}
+
+// ValidateClusterMasterSpec checks validity and makes default updates to a ClusterMasterSpec, and returns error if something is wrong.
+func ValidateClusterMasterSpec(spec *enterprisev1.ClusterMasterSpec) error {
+	siteNames := make(map[string]bool)
+	for _, site := range spec.Sites {
+		if err := validateSiteName(site, siteNames); err != nil {
+			return err
+		}
+	}
+	if err := validateSiteFactors(spec.SiteReplicationFactor, spec.SiteSearchFactor); err != nil {
+		return err
+	}
+	// peers are provided by IndexerCluster resources, so the replication factor cannot be checked against them here
+	if err := validateClusterFactors(spec.ReplicationFactor, spec.SearchFactor, 0); err != nil {
+		return err
+	}
+	if err := validateNoPremiumApps(&spec.AppRepo); err != nil {
+		return err
+	}
+	if err := validateIndexerDiscoverySpec(&spec.IndexerDiscovery); err != nil {
+		return err
 	}
 	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
 }
@@ -282,21 +751,68 @@ func ValidateSearchHeadClusterSpec(spec *enterprisev1.SearchHeadClusterSpec) err
 	if spec.Replicas < 3 {
 		spec.Replicas = 3
 	}
+	if err := validateMaxUnavailable(&spec.MaxUnavailable); err != nil {
+		return err
+	}
+	if err := validateDeploymentApps(spec.DeployerApps); err != nil {
+		return err
+	}
+	if err := validateUpgradeStrategy(spec); err != nil {
+		return err
+	}
+	if err := validateKVStoreBackupSpec(&spec.KVStoreBackup); err != nil {
+		return err
+	}
+	if err := validateScalingSchedule(spec.ScalingSchedule, 3); err != nil {
+		return err
+	}
+	if err := validateSparkMaster(spec.SparkRef, spec.SparkMasterURL, &spec.SparkMasterWebUIPort); err != nil {
+		return err
+	}
 	spec.SparkImage = spark.GetSparkImage(spec.SparkImage)
 	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
 }
 
+// validateMaxUnavailable checks validity and makes default updates to the maximum number of unavailable pods, and returns error if something is wrong.
+func validateMaxUnavailable(maxUnavailable *int32) error {
+	if *maxUnavailable < 0 {
+		return fmt.Errorf("MaxUnavailable must not be negative; value=%d", *maxUnavailable)
+	}
+	if *maxUnavailable == 0 {
+		*maxUnavailable = defaultMaxUnavailable
+	}
+	return nil
+}
+
 // ValidateStandaloneSpec checks validity and makes default updates to a StandaloneSpec, and returns error if something is wrong.
 func ValidateStandaloneSpec(spec *enterprisev1.StandaloneSpec) error {
 	if spec.Replicas == 0 {
 		spec.Replicas = 1
 	}
+	if err := validateSparkMaster(spec.SparkRef, spec.SparkMasterURL, &spec.SparkMasterWebUIPort); err != nil {
+		return err
+	}
 	spec.SparkImage = spark.GetSparkImage(spec.SparkImage)
+	if err := validateStandaloneMigration(spec); err != nil {
+		return err
+	}
 	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
 }
 
 // ValidateLicenseMasterSpec checks validity and makes default updates to a LicenseMasterSpec, and returns error if something is wrong.
 func ValidateLicenseMasterSpec(spec *enterprisev1.LicenseMasterSpec) error {
+	err := validateLicensePools(spec.LicensePools)
+	if err != nil {
+		return err
+	}
+	if IsExternalLicenseMasterConfigured(&spec.CommonSplunkSpec) {
+		return fmt.Errorf("licenseMasterURL cannot be used by a LicenseMaster")
+	}
+	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
+}
+
+// ValidateMonitoringConsoleSpec checks validity and makes default updates to a MonitoringConsoleSpec, and returns error if something is wrong.
+func ValidateMonitoringConsoleSpec(spec *enterprisev1.MonitoringConsoleSpec) error {
 	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
 }
 
@@ -333,23 +849,7 @@ func GetSplunkSecrets(cr enterprisev1.MetaObject, instanceType InstanceType, idx
 		"idxc_secret":  idxcSecret,
 		"shc_secret":   generateSplunkSecret(),
 	}
-	secretData["default.yml"] = []byte(fmt.Sprintf(`
-splunk:
-    hec_disabled: 0
-    hec_enableSSL: 0
-    hec_token: "%s"
-    password: "%s"
-    pass4SymmKey: "%s"
-    idxc:
-        secret: "%s"
-    shc:
-        secret: "%s"
-`,
-		secretData["hec_token"],
-		secretData["password"],
-		secretData["pass4SymmKey"],
-		secretData["idxc_secret"],
-		secretData["shc_secret"]))
+	secretData["default.yml"] = GetSplunkSecretsDefaults(secretData)
 
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -376,8 +876,21 @@ func generateHECToken() []byte {
 	return hecToken
 }
 
+// GetSplunkHome returns the directory that Splunk is installed in for an instance type.
+func GetSplunkHome(instanceType InstanceType) string {
+	if instanceType == SplunkUniversalForwarder {
+		return "/opt/splunkforwarder"
+	}
+	return "/opt/splunk"
+}
+
 // getSplunkPorts returns a map of ports to use for Splunk instances.
 func getSplunkPorts(instanceType InstanceType) map[string]int {
+	// universal forwarders do not run Splunk Web
+	if instanceType == SplunkUniversalForwarder {
+		return map[string]int{"splunkd": 8089}
+	}
+
 	result := map[string]int{
 		"splunkweb": 8000,
 		"splunkd":   8089,
@@ -443,6 +956,19 @@ func getSplunkVolumeMounts() []corev1.VolumeMount {
 	}
 }
 
+// getSplunkEphemeralVolumes returns emptyDir volumes for each of the standard volume mounts that use ephemeral storage.
+// These use the same names as the persistent volume claims they replace, so that volume mounts are the same.
+func getSplunkEphemeralVolumes(spec *enterprisev1.CommonSplunkSpec) []corev1.Volume {
+	var volumes []corev1.Volume
+	if spec.EtcStorage.Ephemeral {
+		volumes = append(volumes, corev1.Volume{Name: "pvc-etc", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	}
+	if spec.VarStorage.Ephemeral {
+		volumes = append(volumes, corev1.Volume{Name: "pvc-var", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	}
+	return volumes
+}
+
 // addSplunkVolumeToTemplate modifies the podTemplateSpec object to incorporates an additional VolumeSource.
 func addSplunkVolumeToTemplate(podTemplateSpec *corev1.PodTemplateSpec, name string, volumeSource corev1.VolumeSource) {
 
@@ -461,13 +987,14 @@ func addSplunkVolumeToTemplate(podTemplateSpec *corev1.PodTemplateSpec, name str
 }
 
 // addDFCToPodTemplate modifies the podTemplateSpec object to incorporate support for DFS.
-func addDFCToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, sparkRef corev1.ObjectReference, sparkImage string, imagePullPolicy string, slotsEnabled bool) {
+func addDFCToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, sparkMasterEnv []corev1.EnvVar, sparkImage string, imagePullPolicy string, slotsEnabled bool) {
 	// create an init container in the pod, which is just used to populate the jdk and spark mount directories
 	containerSpec := corev1.Container{
 		Image:           sparkImage,
 		ImagePullPolicy: corev1.PullPolicy(imagePullPolicy),
 		Name:            "init",
 		Command:         []string{"bash", "-c", "cp -r /opt/jdk /mnt && cp -r /opt/spark /mnt"},
+		SecurityContext: podTemplateSpec.Spec.Containers[0].SecurityContext.DeepCopy(),
 		VolumeMounts: []corev1.VolumeMount{
 			{Name: "mnt-splunk-jdk", MountPath: "/mnt/jdk"},
 			{Name: "mnt-splunk-spark", MountPath: "/mnt/spark"},
@@ -492,37 +1019,83 @@ func addDFCToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, sparkRef corev
 	addSplunkVolumeToTemplate(podTemplateSpec, "jdk", emptyVolumeSource)
 	addSplunkVolumeToTemplate(podTemplateSpec, "spark", emptyVolumeSource)
 
-	// prepare spark master host URL
-	sparkMasterHost := spark.GetSparkServiceName(spark.SparkMaster, sparkRef.Name, false)
-	if sparkRef.Namespace != "" {
-		sparkMasterHost = resources.GetServiceFQDN(sparkRef.Namespace, sparkMasterHost)
-	}
-
 	// append DFS env variables to splunk enterprise containers
-	dfsEnvVar := []corev1.EnvVar{
-		{Name: "SPLUNK_ENABLE_DFS", Value: "true"},
-		{Name: "SPARK_MASTER_HOST", Value: sparkMasterHost},
-		{Name: "SPARK_MASTER_WEBUI_PORT", Value: "8009"},
+	dfsEnvVar := []corev1.EnvVar{{Name: "SPLUNK_ENABLE_DFS", Value: "true"}}
+	dfsEnvVar = append(dfsEnvVar, sparkMasterEnv...)
+	dfsEnvVar = append(dfsEnvVar, []corev1.EnvVar{
 		{Name: "SPARK_HOME", Value: "/mnt/splunk-spark"},
 		{Name: "JAVA_HOME", Value: "/mnt/splunk-jdk"},
-		{Name: "SPLUNK_DFW_NUM_SLOTS_ENABLED", Value: "true"},
-	}
-	if !slotsEnabled {
-		dfsEnvVar[5].Value = "false"
-	}
+		{Name: "SPLUNK_DFW_NUM_SLOTS_ENABLED", Value: fmt.Sprintf("%t", slotsEnabled)},
+	}...)
 	for idx := range podTemplateSpec.Spec.Containers {
 		podTemplateSpec.Spec.Containers[idx].Env = append(podTemplateSpec.Spec.Containers[idx].Env, dfsEnvVar...)
 	}
 }
 
+// validateSparkMaster checks validity and makes default updates to the spark master used for Data Fabric Search (DFS),
+// and returns error if something is wrong.
+func validateSparkMaster(sparkRef corev1.ObjectReference, sparkMasterURL string, sparkMasterWebUIPort *int32) error {
+	if sparkMasterURL == "" {
+		return nil
+	}
+	if sparkRef.Name != "" {
+		return fmt.Errorf("SparkMasterURL cannot be used with SparkRef; sparkMasterUrl=\"%s\", sparkRef=\"%s\"", sparkMasterURL, sparkRef.Name)
+	}
+	u, err := url.Parse(sparkMasterURL)
+	if err != nil || u.Scheme != "spark" || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("SparkMasterURL must be of the form \"spark://host:port\"; value=\"%s\"", sparkMasterURL)
+	}
+	if *sparkMasterWebUIPort == 0 {
+		*sparkMasterWebUIPort = defaultSparkMasterWebUIPort
+	}
+	return nil
+}
+
+// getSparkMasterEnv returns the env variables used by Data Fabric Search (DFS) to reach a spark master, which is either
+// managed by the operator and referred to by sparkRef, or externally managed and given by sparkMasterURL. It returns nil
+// if neither of them are given.
+func getSparkMasterEnv(sparkRef corev1.ObjectReference, sparkMasterURL string, sparkMasterWebUIPort int32) []corev1.EnvVar {
+	if sparkRef.Name != "" {
+		sparkMasterHost := spark.GetSparkServiceName(spark.SparkMaster, sparkRef.Name, false)
+		if sparkRef.Namespace != "" {
+			sparkMasterHost = resources.GetServiceFQDN(sparkRef.Namespace, sparkMasterHost)
+		}
+		return []corev1.EnvVar{
+			{Name: "SPARK_MASTER_HOST", Value: sparkMasterHost},
+			{Name: "SPARK_MASTER_WEBUI_PORT", Value: "8009"},
+		}
+	}
+
+	if sparkMasterURL == "" {
+		return nil
+	}
+	u, err := url.Parse(sparkMasterURL)
+	if err != nil {
+		return nil
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultSparkMasterPort
+	}
+	if sparkMasterWebUIPort == 0 {
+		sparkMasterWebUIPort = defaultSparkMasterWebUIPort
+	}
+	return []corev1.EnvVar{
+		{Name: "SPARK_MASTER_HOST", Value: u.Hostname()},
+		{Name: "SPARK_MASTER_PORT", Value: port},
+		{Name: "SPARK_MASTER_WEBUI_PORT", Value: fmt.Sprintf("%d", sparkMasterWebUIPort)},
+	}
+}
+
 // getSplunkStatefulSet returns a Kubernetes StatefulSet object for Splunk instances configured for a Splunk Enterprise resource.
 func getSplunkStatefulSet(cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType InstanceType, replicas int32, extraEnv []corev1.EnvVar) (*appsv1.StatefulSet, error) {
 
 	// prepare misc values
 	ports := resources.SortContainerPorts(getSplunkContainerPorts(instanceType)) // note that port order is important for tests
 	annotations := resources.GetIstioAnnotations(ports)
-	selectLabels := getSplunkLabels(cr.GetIdentifier(), instanceType)
-	affinity := resources.AppendPodAntiAffinity(&spec.Affinity, cr.GetIdentifier(), instanceType.ToString())
+	selectLabels := getSplunkLabelsForCR(cr, instanceType)
+	affinity := resources.GetPodAffinity(&spec.CommonSpec, cr.GetIdentifier(), instanceType.ToString())
+	topologySpreadConstraints := resources.GetTopologySpreadConstraints(&spec.CommonSpec, cr.GetIdentifier(), instanceType.ToString())
 
 	// start with same labels as selector; note that this object gets modified by resources.AppendParentMeta()
 	labels := make(map[string]string)
@@ -565,8 +1138,12 @@ func getSplunkStatefulSet(cr enterprisev1.MetaObject, spec *enterprisev1.CommonS
 					Annotations: annotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:      affinity,
-					SchedulerName: spec.SchedulerName,
+					Affinity:                  affinity,
+					TopologySpreadConstraints: topologySpreadConstraints,
+					SchedulerName:             spec.SchedulerName,
+					PriorityClassName:         spec.PriorityClassName,
+					ServiceAccountName:        spec.ServiceAccountName,
+					ImagePullSecrets:          spec.ImagePullSecrets,
 					Containers: []corev1.Container{
 						{
 							Image:           spec.Image,
@@ -576,6 +1153,7 @@ func getSplunkStatefulSet(cr enterprisev1.MetaObject, spec *enterprisev1.CommonS
 							VolumeMounts:    getSplunkVolumeMounts(),
 						},
 					},
+					Volumes: getSplunkEphemeralVolumes(spec),
 				},
 			},
 			VolumeClaimTemplates: volumeClaims,
@@ -588,6 +1166,9 @@ func getSplunkStatefulSet(cr enterprisev1.MetaObject, spec *enterprisev1.CommonS
 	// update statefulset's pod template with common splunk pod config
 	updateSplunkPodTemplateWithConfig(&statefulSet.Spec.Template, cr, spec, instanceType, extraEnv)
 
+	// leave fields that are managed by others unchanged
+	setUnmanagedFields(statefulSet, spec.UnmanagedFields)
+
 	// make Splunk Enterprise object the owner
 	statefulSet.SetOwnerReferences(append(statefulSet.GetOwnerReferences(), resources.AsOwner(cr)))
 
@@ -635,6 +1216,18 @@ func updateSplunkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec,
 		},
 	})
 
+	// add smartstore configuration, if configured
+	addSmartStoreToPodTemplate(podTemplateSpec, cr, &spec.SmartStore, instanceType)
+
+	// add app repository configuration, if configured
+	addAppsToPodTemplate(podTemplateSpec, cr, &spec.AppRepo, instanceType)
+
+	// add conf files, if configured
+	addConfFilesToPodTemplate(podTemplateSpec, cr, spec.ConfFiles, instanceType)
+
+	// add certificates and tls configuration, if configured
+	addTLSToPodTemplate(podTemplateSpec, cr, &spec.TLS, instanceType)
+
 	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
 	configMapVolDefaultMode := int32(corev1.ConfigMapVolumeSourceDefaultMode)
 
@@ -650,57 +1243,60 @@ func updateSplunkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec,
 		})
 	}
 
+	// add defaults from a referenced ConfigMap, if configured
+	addDefaultsConfigMapToPodTemplate(podTemplateSpec, spec)
+
+	// add user-defined init containers and init script, if configured
+	addInitContainersToPodTemplate(podTemplateSpec, spec)
+
 	// update security context
-	runAsUser := int64(41812)
-	fsGroup := int64(41812)
-	podTemplateSpec.Spec.SecurityContext = &corev1.PodSecurityContext{
-		RunAsUser: &runAsUser,
-		FSGroup:   &fsGroup,
-	}
+	podTemplateSpec.Spec.SecurityContext = resources.GetPodSecurityContext(&spec.CommonSpec)
+	resources.SetSeccompProfile(podTemplateSpec, spec.SeccompProfile)
+	resources.SetOpenShiftServiceAccount(podTemplateSpec)
 
-	// use script provided by enterprise container to check if pod is alive
-	livenessProbe := &corev1.Probe{
-		Handler: corev1.Handler{
-			Exec: &corev1.ExecAction{
-				Command: []string{
-					"/sbin/checkstate.sh",
-				},
-			},
-		},
-		InitialDelaySeconds: 300,
-		TimeoutSeconds:      30,
-		PeriodSeconds:       30,
+	// add sidecar annotations for a service mesh, if configured
+	addServiceMeshToPodTemplate(podTemplateSpec, cr, &spec.ServiceMesh)
+
+	// add writable volumes for paths outside of etc and var, if the root filesystem is read-only
+	if isReadOnlyRootFilesystem(spec.ContainerSecurityContext) {
+		addWritableVolumesToPodTemplate(podTemplateSpec)
 	}
 
-	// pod is ready if container artifact file is created with contents of "started".
-	// this indicates that all the the ansible plays executed at startup have completed.
-	readinessProbe := &corev1.Probe{
-		Handler: corev1.Handler{
-			Exec: &corev1.ExecAction{
-				Command: []string{
-					"/bin/grep",
-					"started",
-					"/opt/container_artifact/splunk-container.state",
-				},
-			},
-		},
-		InitialDelaySeconds: 10,
-		TimeoutSeconds:      5,
-		PeriodSeconds:       5,
+	// add backup hook annotations for Velero, if configured
+	addVeleroToPodTemplate(podTemplateSpec, cr, spec, instanceType)
+
+	livenessProbe := getSplunkLivenessProbe(&spec.LivenessProbe)
+	readinessProbe := getSplunkReadinessProbe(&spec.ReadinessProbe)
+	if HasPremiumApps(&spec.AppRepo) {
+		applyPremiumAppProbeDefaults(livenessProbe, &spec.LivenessProbe)
 	}
 
+	// stop splunkd gracefully before pods are killed, so that in-flight data is flushed
+	terminationGracePeriodSeconds := getTerminationGracePeriodSeconds(spec.TerminationGracePeriodSeconds, instanceType)
+	podTemplateSpec.Spec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+	lifecycle := getSplunkLifecycle(instanceType)
+
 	// prepare defaults variable
 	splunkDefaults := "/mnt/splunk-secrets/default.yml"
+	if IsAppRepoConfigured(&spec.AppRepo) && isAppInstaller(instanceType) {
+		splunkDefaults = fmt.Sprintf("%s,%s", splunkDefaults, appsDefaultsPath)
+	}
+	if IsTLSConfigured(&spec.TLS) {
+		splunkDefaults = fmt.Sprintf("%s,%s", splunkDefaults, tlsDefaultsPath)
+	}
 	if spec.DefaultsURL != "" {
 		splunkDefaults = fmt.Sprintf("%s,%s", splunkDefaults, spec.DefaultsURL)
 	}
+	if IsDefaultsConfigMapConfigured(spec) {
+		splunkDefaults = fmt.Sprintf("%s,%s", splunkDefaults, defaultsConfigMapPath)
+	}
 	if spec.Defaults != "" {
 		splunkDefaults = fmt.Sprintf("%s,%s", splunkDefaults, "/mnt/splunk-defaults/default.yml")
 	}
 
 	// prepare container env variables
 	env := []corev1.EnvVar{
-		{Name: "SPLUNK_HOME", Value: "/opt/splunk"},
+		{Name: "SPLUNK_HOME", Value: GetSplunkHome(instanceType)},
 		{Name: "SPLUNK_START_ARGS", Value: "--accept-license"},
 		{Name: "SPLUNK_DEFAULTS_URL", Value: splunkDefaults},
 		{Name: "SPLUNK_HOME_OWNERSHIP_ENFORCEMENT", Value: "false"},
@@ -723,18 +1319,15 @@ func updateSplunkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec,
 			Name:  "SPLUNK_LICENSE_MASTER_URL",
 			Value: licenseMasterURL,
 		})
+	} else if instanceType != SplunkLicenseMaster && IsExternalLicenseMasterConfigured(spec) {
+		env = append(env, corev1.EnvVar{
+			Name:  "SPLUNK_LICENSE_MASTER_URL",
+			Value: getExternalManagementHost(spec.LicenseMasterURL),
+		})
 	}
 
 	// append URL for cluster master, if configured
-	var clusterMasterURL string
-	if instanceType == SplunkIndexer {
-		clusterMasterURL = GetSplunkServiceName(SplunkClusterMaster, cr.GetIdentifier(), false)
-	} else if instanceType != SplunkClusterMaster && spec.IndexerClusterRef.Name != "" {
-		clusterMasterURL = GetSplunkServiceName(SplunkClusterMaster, spec.IndexerClusterRef.Name, false)
-		if spec.IndexerClusterRef.Namespace != "" {
-			clusterMasterURL = resources.GetServiceFQDN(spec.IndexerClusterRef.Namespace, clusterMasterURL)
-		}
-	}
+	clusterMasterURL := getClusterMasterURL(cr, spec, instanceType)
 	if clusterMasterURL != "" {
 		extraEnv = append(extraEnv, corev1.EnvVar{
 			Name:  "SPLUNK_CLUSTER_MASTER_URL",
@@ -745,24 +1338,224 @@ func updateSplunkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec,
 	// append any extra variables
 	env = append(env, extraEnv...)
 
+	// append user-defined variables, unless they are already set by the operator
+	env = appendUserEnv(env, spec.ExtraEnv)
+
 	// update each container in pod
 	for idx := range podTemplateSpec.Spec.Containers {
 		podTemplateSpec.Spec.Containers[idx].Resources = spec.Resources
 		podTemplateSpec.Spec.Containers[idx].LivenessProbe = livenessProbe
 		podTemplateSpec.Spec.Containers[idx].ReadinessProbe = readinessProbe
+		podTemplateSpec.Spec.Containers[idx].Lifecycle = lifecycle
 		podTemplateSpec.Spec.Containers[idx].Env = env
+		podTemplateSpec.Spec.Containers[idx].SecurityContext = spec.ContainerSecurityContext.DeepCopy()
+	}
+}
+
+// isReadOnlyRootFilesystem returns true if a container security context makes the root filesystem read-only
+func isReadOnlyRootFilesystem(securityContext *corev1.SecurityContext) bool {
+	return securityContext != nil && securityContext.ReadOnlyRootFilesystem != nil && *securityContext.ReadOnlyRootFilesystem
+}
+
+// addWritableVolumesToPodTemplate adds empty volumes to all splunk containers for paths outside of etc and var that
+// are written to by Splunk Enterprise images, so that they can be used with a read-only root filesystem
+func addWritableVolumesToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec) {
+	writablePaths := []struct{ name, path string }{
+		{"mnt-splunk-tmp", "/tmp"},
+		{"mnt-splunk-artifacts", "/opt/container_artifact"},
+		{"mnt-splunk-home", "/home/splunk"},
+	}
+	for _, w := range writablePaths {
+		podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+			Name:         w.name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		for idx := range podTemplateSpec.Spec.Containers {
+			podTemplateSpec.Spec.Containers[idx].VolumeMounts = append(podTemplateSpec.Spec.Containers[idx].VolumeMounts, corev1.VolumeMount{
+				Name:      w.name,
+				MountPath: w.path,
+			})
+		}
+	}
+}
+
+// getClusterMasterURL returns the service used to reach the cluster master of an instance, or an empty string if it does not
+// use one. A ClusterMaster referenced using clusterMasterRef takes precedence over the cluster master of an indexer cluster,
+// and indexer cluster peers that join an external cluster master use its host name.
+// Universal forwarders only use indexerClusterRef to configure their outputs, so they never use a cluster master.
+func getClusterMasterURL(cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType InstanceType) string {
+	var ref corev1.ObjectReference
+	switch {
+	case instanceType == SplunkClusterMaster || instanceType == SplunkUniversalForwarder:
+		return ""
+	case spec.ClusterMasterRef.Name != "":
+		ref = spec.ClusterMasterRef
+	case instanceType == SplunkIndexer:
+		if idxc, ok := cr.(*enterprisev1.IndexerCluster); ok && IsExternalClusterMasterConfigured(&idxc.Spec.ExternalClusterMaster) {
+			return getExternalClusterMasterHost(&idxc.Spec.ExternalClusterMaster)
+		}
+		ref.Name = cr.GetIdentifier()
+	case spec.IndexerClusterRef.Name != "":
+		ref = spec.IndexerClusterRef
+	default:
+		return ""
+	}
+
+	clusterMasterURL := GetSplunkServiceName(SplunkClusterMaster, ref.Name, false)
+	if ref.Namespace != "" {
+		clusterMasterURL = resources.GetServiceFQDN(ref.Namespace, clusterMasterURL)
+	}
+	return clusterMasterURL
+}
+
+// appendUserEnv returns a list of environment variables with user-defined variables appended, skipping any that are
+// already set so that the operator's own configuration always takes precedence
+func appendUserEnv(env []corev1.EnvVar, userEnv []corev1.EnvVar) []corev1.EnvVar {
+	names := make(map[string]bool)
+	for _, v := range env {
+		names[v.Name] = true
+	}
+	for _, v := range userEnv {
+		if !names[v.Name] {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// validateExtraEnv checks validity and makes default updates to user-defined environment variables, and returns error if something is wrong.
+func validateExtraEnv(extraEnv []corev1.EnvVar) error {
+	names := make(map[string]bool)
+	for _, v := range extraEnv {
+		if v.Name == "" {
+			return fmt.Errorf("ExtraEnv variables must have a name")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("ExtraEnv variable names must be unique; name=\"%s\"", v.Name)
+		}
+		names[v.Name] = true
+	}
+	setEnvDefaults(extraEnv)
+	return nil
+}
+
+// setEnvDefaults explicitly sets default values for environment variables, so we can compare for changes correctly with current statefulset.
+func setEnvDefaults(env []corev1.EnvVar) {
+	for idx := range env {
+		if env[idx].ValueFrom != nil && env[idx].ValueFrom.FieldRef != nil && env[idx].ValueFrom.FieldRef.APIVersion == "" {
+			env[idx].ValueFrom.FieldRef.APIVersion = "v1"
+		}
+	}
+}
+
+// getSplunkLivenessProbe returns a Kubernetes Probe used to check if splunkd is alive
+func getSplunkLivenessProbe(spec *enterprisev1.ProbeSpec) *corev1.Probe {
+	// use script provided by enterprise container to check if pod is alive
+	command := []string{"/sbin/checkstate.sh"}
+	if spec.UseHealthEndpoint {
+		command = []string{"/bin/sh", "-c", splunkHealthCommand}
+	}
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: command,
+			},
+		},
+		InitialDelaySeconds: 300,
+		TimeoutSeconds:      30,
+		PeriodSeconds:       30,
 	}
+	applyProbeSpec(probe, spec)
+	return probe
 }
 
-// getSearchHeadExtraEnv returns extra environment variables used by search head clusters
-func getSearchHeadExtraEnv(cr enterprisev1.MetaObject, replicas int32) []corev1.EnvVar {
+// getSplunkReadinessProbe returns a Kubernetes Probe used to check if a Splunk container has finished starting
+func getSplunkReadinessProbe(spec *enterprisev1.ProbeSpec) *corev1.Probe {
+	// pod is ready if container artifact file is created with contents of "started".
+	// this indicates that all the the ansible plays executed at startup have completed.
+	command := []string{
+		"/bin/grep",
+		"started",
+		"/opt/container_artifact/splunk-container.state",
+	}
+	if spec.UseHealthEndpoint {
+		command = []string{"/bin/sh", "-c", "/bin/grep -q started /opt/container_artifact/splunk-container.state && " + splunkHealthCommand}
+	}
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: command,
+			},
+		},
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      5,
+		PeriodSeconds:       5,
+	}
+	applyProbeSpec(probe, spec)
+	return probe
+}
+
+// applyProbeSpec overrides the default parameters of a Kubernetes Probe with any that are set in a ProbeSpec
+func applyProbeSpec(probe *corev1.Probe, spec *enterprisev1.ProbeSpec) {
+	if spec.InitialDelaySeconds != 0 {
+		probe.InitialDelaySeconds = spec.InitialDelaySeconds
+	}
+	if spec.TimeoutSeconds != 0 {
+		probe.TimeoutSeconds = spec.TimeoutSeconds
+	}
+	if spec.PeriodSeconds != 0 {
+		probe.PeriodSeconds = spec.PeriodSeconds
+	}
+	if spec.FailureThreshold != 0 {
+		probe.FailureThreshold = spec.FailureThreshold
+	}
+}
+
+// getTerminationGracePeriodSeconds returns the number of seconds that pods are given to stop gracefully
+func getTerminationGracePeriodSeconds(specSeconds int64, instanceType InstanceType) int64 {
+	if specSeconds != 0 {
+		return specSeconds
+	}
+	if instanceType == SplunkIndexer {
+		return defaultIndexerTerminationGracePeriodSeconds
+	}
+	return defaultTerminationGracePeriodSeconds
+}
+
+// getSplunkLifecycle returns a Kubernetes Lifecycle with a preStop hook that takes indexer cluster peers offline, or stops
+// splunkd for other instances, so that in-flight data is flushed before the container is killed
+func getSplunkLifecycle(instanceType InstanceType) *corev1.Lifecycle {
+	command := GetSplunkHome(instanceType) + "/bin/splunk stop"
+	if instanceType == SplunkIndexer {
+		command = `/opt/splunk/bin/splunk offline -auth "admin:$(cat /mnt/splunk-secrets/password)"`
+	}
+	return &corev1.Lifecycle{
+		PreStop: &corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", command},
+			},
+		},
+	}
+}
+
+// validateProbeSpec checks validity of a ProbeSpec, and returns error if something is wrong.
+func validateProbeSpec(name string, spec *enterprisev1.ProbeSpec) error {
+	if spec.InitialDelaySeconds < 0 || spec.TimeoutSeconds < 0 || spec.PeriodSeconds < 0 || spec.FailureThreshold < 0 {
+		return fmt.Errorf("%s parameters must not be negative; value=%+v", name, *spec)
+	}
+	return nil
+}
+
+// getSearchHeadExtraEnv returns extra environment variables used by search head clusters, where identifier is used to name
+// the search head cluster members
+func getSearchHeadExtraEnv(namespace string, identifier string, replicas int32) []corev1.EnvVar {
 	return []corev1.EnvVar{
 		{
 			Name:  "SPLUNK_SEARCH_HEAD_URL",
-			Value: GetSplunkStatefulsetUrls(cr.GetNamespace(), SplunkSearchHead, cr.GetIdentifier(), replicas, false),
+			Value: GetSplunkStatefulsetUrls(namespace, SplunkSearchHead, identifier, replicas, false),
 		}, {
 			Name:  "SPLUNK_SEARCH_HEAD_CAPTAIN_URL",
-			Value: GetSplunkStatefulsetURL(cr.GetNamespace(), SplunkSearchHead, cr.GetIdentifier(), 0, false),
+			Value: GetSplunkStatefulsetURL(namespace, SplunkSearchHead, identifier, 0, false),
 		},
 	}
 }
@@ -776,3 +1569,72 @@ func getIndexerExtraEnv(cr enterprisev1.MetaObject, replicas int32) []corev1.Env
 		},
 	}
 }
+
+// getMultisiteExtraEnv returns extra environment variables used by members of a multisite indexer cluster
+func getMultisiteExtraEnv(cr *enterprisev1.IndexerCluster, site string) []corev1.EnvVar {
+	siteNames := make([]string, len(cr.Spec.Sites))
+	indexerUrls := make([]string, len(cr.Spec.Sites))
+	for idx, s := range cr.Spec.Sites {
+		siteNames[idx] = s.Name
+		indexerUrls[idx] = GetSplunkStatefulsetUrls(cr.GetNamespace(), SplunkIndexer, GetSplunkSiteIdentifier(cr.GetIdentifier(), s.Name), s.Replicas, false)
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "SPLUNK_INDEXER_URL", Value: strings.Join(indexerUrls, ",")},
+		{Name: "SPLUNK_SITE", Value: site},
+		{Name: "SPLUNK_ALL_SITES", Value: strings.Join(siteNames, ",")},
+		{Name: "SPLUNK_MULTISITE_MASTER", Value: getClusterMasterURL(cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer)},
+	}
+
+	return append(env, getSiteFactorsExtraEnv(cr.Spec.SiteReplicationFactor, cr.Spec.SiteSearchFactor)...)
+}
+
+// getClusterMasterExtraEnv returns extra environment variables used by a ClusterMaster, which belongs to the first site of a multisite indexer cluster
+func getClusterMasterExtraEnv(cr *enterprisev1.ClusterMaster) []corev1.EnvVar {
+	env := getClusterFactorsExtraEnv(cr.Spec.ReplicationFactor, cr.Spec.SearchFactor)
+	if len(cr.Spec.Sites) == 0 {
+		return env
+	}
+
+	env = append(env, []corev1.EnvVar{
+		{Name: "SPLUNK_SITE", Value: cr.Spec.Sites[0]},
+		{Name: "SPLUNK_ALL_SITES", Value: strings.Join(cr.Spec.Sites, ",")},
+		{Name: "SPLUNK_MULTISITE_MASTER", Value: GetSplunkServiceName(SplunkClusterMaster, cr.GetIdentifier(), false)},
+	}...)
+
+	return append(env, getSiteFactorsExtraEnv(cr.Spec.SiteReplicationFactor, cr.Spec.SiteSearchFactor)...)
+}
+
+// getClusterFactorsExtraEnv returns extra environment variables for the replication and search factors of an indexer cluster
+func getClusterFactorsExtraEnv(replicationFactor, searchFactor int32) []corev1.EnvVar {
+	// replication and search factors are only used by the cluster master; use image defaults if not set
+	env := []corev1.EnvVar{}
+	if replicationFactor > 0 {
+		env = append(env, corev1.EnvVar{Name: "SPLUNK_IDXC_REPLICATION_FACTOR", Value: fmt.Sprintf("%d", replicationFactor)})
+	}
+	if searchFactor > 0 {
+		env = append(env, corev1.EnvVar{Name: "SPLUNK_IDXC_SEARCH_FACTOR", Value: fmt.Sprintf("%d", searchFactor)})
+	}
+	return env
+}
+
+// getSiteFactorsExtraEnv returns extra environment variables for the site replication and search factors of a multisite indexer cluster
+func getSiteFactorsExtraEnv(replicationFactor, searchFactor enterprisev1.IndexerClusterSiteFactor) []corev1.EnvVar {
+	// site replication and search factors are only used by the cluster master; use splunk-ansible defaults if not set
+	factors := []struct {
+		name  string
+		value int32
+	}{
+		{"SPLUNK_MULTISITE_REPLICATION_FACTOR_ORIGIN", replicationFactor.Origin},
+		{"SPLUNK_MULTISITE_REPLICATION_FACTOR_TOTAL", replicationFactor.Total},
+		{"SPLUNK_MULTISITE_SEARCH_FACTOR_ORIGIN", searchFactor.Origin},
+		{"SPLUNK_MULTISITE_SEARCH_FACTOR_TOTAL", searchFactor.Total},
+	}
+	var env []corev1.EnvVar
+	for _, f := range factors {
+		if f.value > 0 {
+			env = append(env, corev1.EnvVar{Name: f.name, Value: fmt.Sprintf("%d", f.value)})
+		}
+	}
+	return env
+}
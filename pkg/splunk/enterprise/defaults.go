@@ -0,0 +1,87 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// pod template annotation used to restart pods when the contents of a referenced defaults ConfigMap change
+	defaultsChecksumAnnotation = "enterprise.splunk.com/defaults-checksum"
+
+	// key of the default.yml file contained in a referenced defaults ConfigMap
+	defaultsConfigMapKey = "default.yml"
+
+	// directory used to mount a referenced defaults ConfigMap
+	defaultsConfigMapMountPath = "/mnt/splunk-defaults-configmap"
+
+	// default.yml file from a referenced defaults ConfigMap, used by splunk-ansible
+	defaultsConfigMapPath = defaultsConfigMapMountPath + "/" + defaultsConfigMapKey
+)
+
+// IsDefaultsConfigMapConfigured returns true if a ConfigMap containing default.yml overrides has been referenced
+func IsDefaultsConfigMapConfigured(spec *enterprisev1.CommonSplunkSpec) bool {
+	return spec.DefaultsConfigMapRef != ""
+}
+
+// ValidateDefaultsConfigMap checks that a referenced ConfigMap contains a default.yml file, and returns error if it does not.
+func ValidateDefaultsConfigMap(configMap *corev1.ConfigMap) error {
+	if _, ok := configMap.Data[defaultsConfigMapKey]; !ok {
+		return fmt.Errorf("Defaults ConfigMap must contain %s; name=\"%s\"", defaultsConfigMapKey, configMap.GetName())
+	}
+	return nil
+}
+
+// GetDefaultsChecksum returns a checksum of the default.yml contained in a Kubernetes ConfigMap.
+func GetDefaultsChecksum(configMap *corev1.ConfigMap) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(configMap.Data[defaultsConfigMapKey])))
+}
+
+// SetDefaultsChecksum annotates a pod template with a checksum of the default.yml in a referenced ConfigMap, so that pods
+// are recycled when it changes. It does nothing if configMap is nil (no ConfigMap is referenced).
+func SetDefaultsChecksum(podTemplateSpec *corev1.PodTemplateSpec, configMap *corev1.ConfigMap) {
+	if configMap == nil {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[defaultsChecksumAnnotation] = GetDefaultsChecksum(configMap)
+}
+
+// addDefaultsConfigMapToPodTemplate mounts a referenced defaults ConfigMap for all Splunk Enterprise instances, if configured.
+func addDefaultsConfigMapToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.CommonSplunkSpec) {
+	if !IsDefaultsConfigMapConfigured(spec) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	configMapVolDefaultMode := int32(corev1.ConfigMapVolumeSourceDefaultMode)
+
+	addSplunkVolumeToTemplate(podTemplateSpec, "defaults-configmap", corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: spec.DefaultsConfigMapRef,
+			},
+			DefaultMode: &configMapVolDefaultMode,
+		},
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateDefaultsConfigMap(t *testing.T) {
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-defaults", Namespace: "test"},
+		Data:       map[string]string{"default.yml": "splunk:\n    hec_disabled: 1\n"},
+	}
+	if err := ValidateDefaultsConfigMap(&configMap); err != nil {
+		t.Errorf("ValidateDefaultsConfigMap() returned %v; want nil", err)
+	}
+	configMap.Data = map[string]string{"defaults.yaml": "splunk: {}"}
+	if err := ValidateDefaultsConfigMap(&configMap); err == nil {
+		t.Errorf("ValidateDefaultsConfigMap() returned nil; want error without default.yml")
+	}
+}
+
+func TestSetDefaultsChecksum(t *testing.T) {
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-defaults", Namespace: "test"},
+		Data:       map[string]string{"default.yml": "splunk:\n    hec_disabled: 1\n"},
+	}
+	podTemplateSpec := corev1.PodTemplateSpec{}
+
+	// nothing to do without a ConfigMap
+	SetDefaultsChecksum(&podTemplateSpec, nil)
+	if len(podTemplateSpec.Annotations) != 0 {
+		t.Errorf("SetDefaultsChecksum(nil) annotations = %v; want none", podTemplateSpec.Annotations)
+	}
+
+	SetDefaultsChecksum(&podTemplateSpec, &configMap)
+	checksum := podTemplateSpec.Annotations["enterprise.splunk.com/defaults-checksum"]
+	if checksum != GetDefaultsChecksum(&configMap) {
+		t.Errorf("SetDefaultsChecksum() checksum = %s; want %s", checksum, GetDefaultsChecksum(&configMap))
+	}
+
+	// other keys are ignored, but changes to default.yml are not
+	configMap.Data["README.md"] = "managed in git"
+	if GetDefaultsChecksum(&configMap) != checksum {
+		t.Errorf("GetDefaultsChecksum() changed after adding a key other than default.yml")
+	}
+	configMap.Data["default.yml"] = "splunk:\n    hec_disabled: 0\n"
+	if GetDefaultsChecksum(&configMap) == checksum {
+		t.Errorf("GetDefaultsChecksum() did not change after default.yml was updated")
+	}
+}
+
+func TestAddDefaultsConfigMapToPodTemplate(t *testing.T) {
+	podTemplateSpec := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "splunk"}},
+		},
+	}
+
+	// nothing to do without a ConfigMap reference
+	addDefaultsConfigMapToPodTemplate(&podTemplateSpec, &enterprisev1.CommonSplunkSpec{})
+	if len(podTemplateSpec.Spec.Volumes) != 0 {
+		t.Errorf("addDefaultsConfigMapToPodTemplate() added volumes without a reference: %v", podTemplateSpec.Spec.Volumes)
+	}
+
+	addDefaultsConfigMapToPodTemplate(&podTemplateSpec, &enterprisev1.CommonSplunkSpec{DefaultsConfigMapRef: "splunk-defaults"})
+	volumes := podTemplateSpec.Spec.Volumes
+	if len(volumes) != 1 || volumes[0].Name != "mnt-splunk-defaults-configmap" || volumes[0].ConfigMap == nil || volumes[0].ConfigMap.Name != "splunk-defaults" {
+		t.Errorf("addDefaultsConfigMapToPodTemplate() volumes = %v; want ConfigMap splunk-defaults", volumes)
+	}
+	mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/mnt/splunk-defaults-configmap" {
+		t.Errorf("addDefaultsConfigMapToPodTemplate() mounts = %v; want /mnt/splunk-defaults-configmap", mounts)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// pod template annotation used to restart pods when server classes or deployment apps provided by ConfigMaps change
+	deploymentAppsChecksumAnnotation = "enterprise.splunk.com/deployment-apps-checksum"
+
+	// key of the serverclass.conf file contained in a referenced server class ConfigMap
+	serverClassConfigMapKey = "serverclass.conf"
+
+	// app directory used to install server classes on deployment servers
+	serverClassAppPath = "/opt/splunk/etc/apps/splunk-operator-serverclass/local"
+
+	// directory containing the apps distributed by deployment servers
+	deploymentAppsPath = "/opt/splunk/etc/deployment-apps"
+
+	// directory containing the apps pushed to search head cluster members by deployers
+	deployerAppsPath = "/opt/splunk/etc/shcluster/apps"
+)
+
+// deploymentAppNameRegex is used to validate the names of deployment apps, which are used as directory names
+var deploymentAppNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateDeploymentApps checks that apps provided by ConfigMaps have unique, valid names and a configMapRef, and returns error if
+// something is wrong.
+func validateDeploymentApps(deploymentApps []enterprisev1.DeploymentAppSpec) error {
+	apps := make(map[string]bool)
+	for _, app := range deploymentApps {
+		if !deploymentAppNameRegex.MatchString(app.Name) {
+			return fmt.Errorf("Deployment app name must only contain letters, numbers, \"_\", \".\" and \"-\"; value=\"%s\"", app.Name)
+		}
+		if apps[app.Name] {
+			return fmt.Errorf("Deployment app names must be unique; value=\"%s\"", app.Name)
+		}
+		if app.ConfigMapRef == "" {
+			return fmt.Errorf("Deployment app configMapRef must not be empty; app=\"%s\"", app.Name)
+		}
+		apps[app.Name] = true
+	}
+	return nil
+}
+
+// ValidateDeploymentServerSpec checks validity and makes default updates to a DeploymentServerSpec, and returns error if something is wrong.
+func ValidateDeploymentServerSpec(spec *enterprisev1.DeploymentServerSpec) error {
+	if err := validateDeploymentApps(spec.DeploymentApps); err != nil {
+		return err
+	}
+
+	if err := validateNoPremiumApps(&spec.AppRepo); err != nil {
+		return err
+	}
+
+	if spec.ExternalService.Enabled {
+		if spec.ExternalService.ServiceType == "" {
+			spec.ExternalService.ServiceType = corev1.ServiceTypeLoadBalancer
+		}
+		if spec.ExternalService.ServiceType != corev1.ServiceTypeLoadBalancer && spec.ExternalService.ServiceType != corev1.ServiceTypeNodePort {
+			return fmt.Errorf("ExternalService serviceType must be either \"%s\" or \"%s\"; value=\"%s\"", corev1.ServiceTypeLoadBalancer, corev1.ServiceTypeNodePort, spec.ExternalService.ServiceType)
+		}
+	}
+
+	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
+}
+
+// ValidateServerClassConfigMap checks that a referenced ConfigMap contains a serverclass.conf file, and returns error if it does not.
+func ValidateServerClassConfigMap(configMap *corev1.ConfigMap) error {
+	if _, ok := configMap.Data[serverClassConfigMapKey]; !ok {
+		return fmt.Errorf("Server class ConfigMap must contain %s; name=\"%s\"", serverClassConfigMapKey, configMap.GetName())
+	}
+	return nil
+}
+
+// GetDeploymentServerStatefulSet returns a Kubernetes StatefulSet object for a Splunk Enterprise deployment server.
+func GetDeploymentServerStatefulSet(cr *enterprisev1.DeploymentServer) (*appsv1.StatefulSet, error) {
+	ss, err := getSplunkStatefulSet(cr, &cr.Spec.CommonSplunkSpec, SplunkDeploymentServer, 1, []corev1.EnvVar{})
+	if err != nil {
+		return nil, err
+	}
+	addDeploymentAppsToPodTemplate(&ss.Spec.Template, &cr.Spec)
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+	return ss, nil
+}
+
+// GetDeploymentServerExternalService returns an external Kubernetes Service used by deployment clients outside of the
+// Kubernetes cluster to reach the management port of a deployment server.
+func GetDeploymentServerExternalService(cr *enterprisev1.DeploymentServer) *corev1.Service {
+	port := getSplunkPorts(SplunkDeploymentServer)["splunkd"]
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetSplunkExternalServiceName(SplunkDeploymentServer, cr.GetIdentifier()),
+			Namespace:   cr.GetNamespace(),
+			Labels:      getSplunkLabels(cr.GetIdentifier(), SplunkDeploymentServer),
+			Annotations: make(map[string]string),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     cr.Spec.ExternalService.ServiceType,
+			Selector: getSplunkLabels(cr.GetIdentifier(), SplunkDeploymentServer),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "splunkd",
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	for k, v := range cr.Spec.ExternalService.Annotations {
+		service.ObjectMeta.Annotations[k] = v
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(service.ObjectMeta.GetObjectMeta(), cr.GetObjectMeta())
+
+	service.SetOwnerReferences(append(service.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return service
+}
+
+// GetDeploymentServerTargetURI returns the address and port of the service used by deployment clients within the
+// Kubernetes cluster to reach a deployment server.
+func GetDeploymentServerTargetURI(cr *enterprisev1.DeploymentServer) string {
+	return fmt.Sprintf("%s:%d",
+		resources.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(SplunkDeploymentServer, cr.GetIdentifier(), false)),
+		getSplunkPorts(SplunkDeploymentServer)["splunkd"])
+}
+
+// GetDeploymentAppsChecksum returns a checksum of the contents of the ConfigMaps providing server classes and deployment apps.
+func GetDeploymentAppsChecksum(configMaps []*corev1.ConfigMap) string {
+	h := sha256.New()
+	for _, configMap := range configMaps {
+		keys := make([]string, 0, len(configMap.Data)+len(configMap.BinaryData))
+		for k := range configMap.Data {
+			keys = append(keys, k)
+		}
+		for k := range configMap.BinaryData {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(h, "%s\n", configMap.GetName())
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s\n", k)
+			if v, ok := configMap.Data[k]; ok {
+				h.Write([]byte(v))
+			} else {
+				h.Write(configMap.BinaryData[k])
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SetDeploymentAppsChecksum annotates a pod template with a checksum of the ConfigMaps providing server classes and deployment
+// apps, so that pods are recycled when they change. It does nothing if no ConfigMaps are referenced.
+func SetDeploymentAppsChecksum(podTemplateSpec *corev1.PodTemplateSpec, configMaps []*corev1.ConfigMap) {
+	if len(configMaps) == 0 {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[deploymentAppsChecksumAnnotation] = GetDeploymentAppsChecksum(configMaps)
+}
+
+// addDeploymentAppsToPodTemplate mounts a referenced server class ConfigMap as an app, and the ConfigMaps of deployment apps in
+// the local directory of each app, for deployment servers.
+func addDeploymentAppsToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.DeploymentServerSpec) {
+	if spec.ServerClassConfigMapRef != "" {
+		addConfigMapVolumeToPodTemplate(podTemplateSpec, "mnt-splunk-serverclass", spec.ServerClassConfigMapRef, serverClassAppPath,
+			[]corev1.KeyToPath{{Key: serverClassConfigMapKey, Path: serverClassConfigMapKey}})
+	}
+	addConfigMapAppsToPodTemplate(podTemplateSpec, spec.DeploymentApps, deploymentAppsPath)
+}
+
+// addConfigMapAppsToPodTemplate mounts the ConfigMap of each app in its local directory, within appsPath.
+func addConfigMapAppsToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, apps []enterprisev1.DeploymentAppSpec, appsPath string) {
+	// app names are not valid volume names, so volumes are named using the position of each app
+	for i, app := range apps {
+		addConfigMapVolumeToPodTemplate(podTemplateSpec, fmt.Sprintf("mnt-splunk-deployment-app-%d", i), app.ConfigMapRef,
+			fmt.Sprintf("%s/%s/local", appsPath, app.Name), nil)
+	}
+}
+
+// addConfigMapVolumeToPodTemplate adds a volume for a ConfigMap to a pod template, and mounts it in all containers at mountPath.
+func addConfigMapVolumeToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, name, configMapName, mountPath string, items []corev1.KeyToPath) {
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	configMapVolDefaultMode := int32(corev1.ConfigMapVolumeSourceDefaultMode)
+
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+				Items:                items,
+				DefaultMode:          &configMapVolDefaultMode,
+			},
+		},
+	})
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: mountPath,
+		})
+	}
+}
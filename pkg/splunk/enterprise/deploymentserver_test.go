@@ -0,0 +1,142 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateDeploymentServerSpec(t *testing.T) {
+	test := func(spec enterprisev1.DeploymentServerSpec, wantErr bool, wantServiceType corev1.ServiceType) {
+		err := ValidateDeploymentServerSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateDeploymentServerSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateDeploymentServerSpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && spec.ExternalService.ServiceType != wantServiceType {
+			t.Errorf("ValidateDeploymentServerSpec() externalService.serviceType = %s; want %s", spec.ExternalService.ServiceType, wantServiceType)
+		}
+	}
+
+	test(enterprisev1.DeploymentServerSpec{}, false, "")
+	test(enterprisev1.DeploymentServerSpec{ExternalService: enterprisev1.ExternalServiceSpec{Enabled: true}}, false, corev1.ServiceTypeLoadBalancer)
+	test(enterprisev1.DeploymentServerSpec{ExternalService: enterprisev1.ExternalServiceSpec{Enabled: true, ServiceType: corev1.ServiceTypeNodePort}}, false, corev1.ServiceTypeNodePort)
+	test(enterprisev1.DeploymentServerSpec{ExternalService: enterprisev1.ExternalServiceSpec{Enabled: true, ServiceType: corev1.ServiceTypeClusterIP}}, true, "")
+
+	apps := []enterprisev1.DeploymentAppSpec{{Name: "uf_outputs", ConfigMapRef: "outputs"}, {Name: "uf-inputs.linux", ConfigMapRef: "inputs"}}
+	test(enterprisev1.DeploymentServerSpec{DeploymentApps: apps}, false, "")
+	test(enterprisev1.DeploymentServerSpec{DeploymentApps: append(apps, enterprisev1.DeploymentAppSpec{Name: "uf_outputs", ConfigMapRef: "other"})}, true, "")
+	test(enterprisev1.DeploymentServerSpec{DeploymentApps: []enterprisev1.DeploymentAppSpec{{Name: "../system", ConfigMapRef: "outputs"}}}, true, "")
+	test(enterprisev1.DeploymentServerSpec{DeploymentApps: []enterprisev1.DeploymentAppSpec{{ConfigMapRef: "outputs"}}}, true, "")
+	test(enterprisev1.DeploymentServerSpec{DeploymentApps: []enterprisev1.DeploymentAppSpec{{Name: "uf_outputs"}}}, true, "")
+}
+
+func TestGetDeploymentServerStatefulSet(t *testing.T) {
+	cr := enterprisev1.DeploymentServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ds1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.DeploymentServerSpec{
+			ServerClassConfigMapRef: "serverclasses",
+			DeploymentApps:          []enterprisev1.DeploymentAppSpec{{Name: "uf_outputs", ConfigMapRef: "outputs"}},
+		},
+	}
+	if err := ValidateDeploymentServerSpec(&cr.Spec); err != nil {
+		t.Fatalf("ValidateDeploymentServerSpec() returned %v; want nil", err)
+	}
+
+	ss, err := GetDeploymentServerStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetDeploymentServerStatefulSet() returned %v; want nil", err)
+	}
+	if ss.GetName() != "splunk-ds1-deployment-server" {
+		t.Errorf("GetDeploymentServerStatefulSet() name = %s; want %s", ss.GetName(), "splunk-ds1-deployment-server")
+	}
+	container := ss.Spec.Template.Spec.Containers[0]
+	mounts := map[string]string{}
+	for _, m := range container.VolumeMounts {
+		mounts[m.Name] = m.MountPath
+	}
+	for name, want := range map[string]string{
+		"mnt-splunk-serverclass":      "/opt/splunk/etc/apps/splunk-operator-serverclass/local",
+		"mnt-splunk-deployment-app-0": "/opt/splunk/etc/deployment-apps/uf_outputs/local",
+	} {
+		if mounts[name] != want {
+			t.Errorf("GetDeploymentServerStatefulSet() mount %s = %s; want %s", name, mounts[name], want)
+		}
+	}
+	for _, v := range container.Env {
+		if v.Name == "SPLUNK_ROLE" && v.Value != "splunk_deployment_server" {
+			t.Errorf("GetDeploymentServerStatefulSet() SPLUNK_ROLE = %s; want %s", v.Value, "splunk_deployment_server")
+		}
+	}
+
+	// pods are recycled when server classes or deployment apps change
+	configMaps := []*corev1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: "serverclasses"}, Data: map[string]string{"serverclass.conf": "[serverClass:linux]\n"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "outputs"}, Data: map[string]string{"outputs.conf": "[tcpout]\n"}},
+	}
+	SetDeploymentAppsChecksum(&ss.Spec.Template, configMaps)
+	checksum := ss.Spec.Template.Annotations["enterprise.splunk.com/deployment-apps-checksum"]
+	if checksum != GetDeploymentAppsChecksum(configMaps) {
+		t.Errorf("SetDeploymentAppsChecksum() annotations = %v; want deployment apps checksum", ss.Spec.Template.Annotations)
+	}
+	configMaps[1].Data["outputs.conf"] = "[tcpout:idxc]\n"
+	if GetDeploymentAppsChecksum(configMaps) == checksum {
+		t.Errorf("GetDeploymentAppsChecksum() did not change when a deployment app changed")
+	}
+}
+
+func TestGetDeploymentServerExternalService(t *testing.T) {
+	cr := enterprisev1.DeploymentServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ds1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.DeploymentServerSpec{
+			ExternalService: enterprisev1.ExternalServiceSpec{
+				Enabled:     true,
+				ServiceType: corev1.ServiceTypeLoadBalancer,
+				Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+			},
+		},
+	}
+
+	service := GetDeploymentServerExternalService(&cr)
+	if service.GetName() != "splunk-ds1-deployment-server-external" {
+		t.Errorf("GetDeploymentServerExternalService() name = %s; want %s", service.GetName(), "splunk-ds1-deployment-server-external")
+	}
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		t.Errorf("GetDeploymentServerExternalService() type = %s; want %s", service.Spec.Type, corev1.ServiceTypeLoadBalancer)
+	}
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != 8089 {
+		t.Errorf("GetDeploymentServerExternalService() ports = %v; want 8089", service.Spec.Ports)
+	}
+	if service.GetAnnotations()["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" {
+		t.Errorf("GetDeploymentServerExternalService() annotations = %v; want aws-load-balancer-type=nlb", service.GetAnnotations())
+	}
+
+	want := "splunk-ds1-deployment-server-service.test.svc.cluster.local:8089"
+	if got := GetDeploymentServerTargetURI(&cr); got != want {
+		t.Errorf("GetDeploymentServerTargetURI() = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// PeerDetentionAutomatic is reported for indexer cluster peers that the cluster master has put into automatic detention
+	PeerDetentionAutomatic = "automatic"
+
+	// PeerDetentionManual is reported for indexer cluster peers that have been put into manual detention
+	PeerDetentionManual = "manual"
+
+	// default number of seconds that a peer must remain in automatic detention before remediation is attempted
+	defaultDetentionRemediationDelay = 900
+)
+
+// IsDetentionRemediationEnabled returns true if remediation is attempted for indexer cluster peers in automatic detention
+func IsDetentionRemediationEnabled(spec *enterprisev1.IndexerClusterDetentionSpec) bool {
+	return spec.Remediation != "" && spec.Remediation != DetentionRemediationNone
+}
+
+// validateDetentionSpec checks validity and makes default updates to an IndexerClusterDetentionSpec, and returns error if something is wrong.
+func validateDetentionSpec(spec *enterprisev1.IndexerClusterDetentionSpec) error {
+	if spec.Remediation == "" {
+		spec.Remediation = DetentionRemediationNone
+	}
+	switch spec.Remediation {
+	case DetentionRemediationNone, DetentionRemediationRestart, DetentionRemediationRebalance:
+	default:
+		return fmt.Errorf("Detention remediation must be either \"%s\", \"%s\" or \"%s\"; value=\"%s\"", DetentionRemediationNone, DetentionRemediationRestart, DetentionRemediationRebalance, spec.Remediation)
+	}
+
+	if spec.RemediationDelaySeconds < 0 {
+		return fmt.Errorf("Detention remediationDelaySeconds must not be negative; value=%d", spec.RemediationDelaySeconds)
+	}
+	if spec.RemediationDelaySeconds == 0 {
+		spec.RemediationDelaySeconds = defaultDetentionRemediationDelay
+	}
+	return nil
+}
+
+// GetPeerDetentionMode returns the detention mode of an indexer cluster peer ("automatic" or "manual") using the status
+// reported for it by the cluster master, or an empty string if it is not in detention
+func GetPeerDetentionMode(peerStatus string) string {
+	switch {
+	case peerStatus == "AutomaticDetention":
+		return PeerDetentionAutomatic
+	case strings.HasPrefix(peerStatus, "ManualDetention"):
+		return PeerDetentionManual
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateDetentionSpec(t *testing.T) {
+	test := func(spec enterprisev1.IndexerClusterDetentionSpec, wantErr bool, want enterprisev1.IndexerClusterDetentionSpec) {
+		err := validateDetentionSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateDetentionSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateDetentionSpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && spec != want {
+			t.Errorf("validateDetentionSpec() = %v; want %v", spec, want)
+		}
+	}
+
+	test(enterprisev1.IndexerClusterDetentionSpec{}, false, enterprisev1.IndexerClusterDetentionSpec{Remediation: "None", RemediationDelaySeconds: 900})
+	test(enterprisev1.IndexerClusterDetentionSpec{Remediation: "Restart", RemediationDelaySeconds: 60}, false, enterprisev1.IndexerClusterDetentionSpec{Remediation: "Restart", RemediationDelaySeconds: 60})
+	test(enterprisev1.IndexerClusterDetentionSpec{Remediation: "Rebalance"}, false, enterprisev1.IndexerClusterDetentionSpec{Remediation: "Rebalance", RemediationDelaySeconds: 900})
+	test(enterprisev1.IndexerClusterDetentionSpec{Remediation: "Decommission"}, true, enterprisev1.IndexerClusterDetentionSpec{})
+	test(enterprisev1.IndexerClusterDetentionSpec{Remediation: "Restart", RemediationDelaySeconds: -1}, true, enterprisev1.IndexerClusterDetentionSpec{})
+}
+
+func TestGetPeerDetentionMode(t *testing.T) {
+	test := func(peerStatus, want string) {
+		if got := GetPeerDetentionMode(peerStatus); got != want {
+			t.Errorf("GetPeerDetentionMode(%s) = %s; want %s", peerStatus, got, want)
+		}
+	}
+
+	test("Up", "")
+	test("", "")
+	test("AutomaticDetention", PeerDetentionAutomatic)
+	test("ManualDetention", PeerDetentionManual)
+	test("ManualDetention-PortsEnabled", PeerDetentionManual)
+}
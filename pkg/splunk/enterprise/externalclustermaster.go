@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// IsExternalClusterMasterConfigured returns true if indexer cluster peers join a cluster master that is not managed by the operator
+func IsExternalClusterMasterConfigured(spec *enterprisev1.ExternalClusterMasterSpec) bool {
+	return spec.URL != ""
+}
+
+// HasOwnClusterMaster returns true if the operator creates a cluster master for an indexer cluster, instead of using a
+// ClusterMaster referenced by clusterMasterRef or an external cluster master
+func HasOwnClusterMaster(spec *enterprisev1.IndexerClusterSpec) bool {
+	return spec.ClusterMasterRef.Name == "" && !IsExternalClusterMasterConfigured(&spec.ExternalClusterMaster)
+}
+
+// validateExternalClusterMaster checks validity of the external cluster master of an IndexerClusterSpec, and returns error if something is wrong.
+func validateExternalClusterMaster(spec *enterprisev1.IndexerClusterSpec) error {
+	if !IsExternalClusterMasterConfigured(&spec.ExternalClusterMaster) {
+		return nil
+	}
+	if spec.ClusterMasterRef.Name != "" {
+		return fmt.Errorf("ExternalClusterMaster cannot be used with clusterMasterRef")
+	}
+	if err := validateExternalManagementURL("ExternalClusterMaster url", spec.ExternalClusterMaster.URL); err != nil {
+		return err
+	}
+	if spec.ExternalClusterMaster.SecretRef == "" {
+		return fmt.Errorf("ExternalClusterMaster secretRef is required")
+	}
+	return nil
+}
+
+// validateExternalManagementURL checks that the management URL of a Splunk Enterprise instance running outside of the operator
+// can be used by splunk-ansible, which only accepts a host name and always uses https on the default management port
+func validateExternalManagementURL(field, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("%s must be an https URL; value=\"%s\"", field, value)
+	}
+	if port := u.Port(); port != "" && port != strconv.Itoa(getSplunkPorts(SplunkClusterMaster)["splunkd"]) {
+		return fmt.Errorf("%s must use port %d; value=\"%s\"", field, getSplunkPorts(SplunkClusterMaster)["splunkd"], value)
+	}
+	return nil
+}
+
+// getExternalManagementHost returns the host name of a management URL validated by validateExternalManagementURL
+func getExternalManagementHost(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// getExternalClusterMasterHost returns the host name of an external cluster master, which splunk-ansible uses to join peers to it
+func getExternalClusterMasterHost(spec *enterprisev1.ExternalClusterMasterSpec) string {
+	return getExternalManagementHost(spec.URL)
+}
+
+// GetExternalClusterMasterURI returns the management URI used to reach an external cluster master using its REST API
+func GetExternalClusterMasterURI(spec *enterprisev1.ExternalClusterMasterSpec) string {
+	return fmt.Sprintf("https://%s:%d", getExternalClusterMasterHost(spec), getSplunkPorts(SplunkClusterMaster)["splunkd"])
+}
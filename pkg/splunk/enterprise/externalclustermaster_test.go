@@ -0,0 +1,111 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateExternalClusterMaster(t *testing.T) {
+	test := func(spec enterprisev1.IndexerClusterSpec, wantErr bool) {
+		err := ValidateIndexerClusterSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateIndexerClusterSpec(%v) returned nil; want error", spec.ExternalClusterMaster)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateIndexerClusterSpec(%v) returned %v; want nil", spec.ExternalClusterMaster, err)
+		}
+	}
+
+	external := func(url, secretRef string) enterprisev1.IndexerClusterSpec {
+		spec := enterprisev1.IndexerClusterSpec{}
+		spec.ExternalClusterMaster = enterprisev1.ExternalClusterMasterSpec{URL: url, SecretRef: secretRef}
+		return spec
+	}
+	test(external("", ""), false)
+	test(external("https://cm.example.com:8089", "cm-secret"), false)
+	test(external("https://cm.example.com", "cm-secret"), false)
+	test(external("https://cm.example.com:8089", ""), true)
+	test(external("http://cm.example.com:8089", "cm-secret"), true)
+	test(external("https://cm.example.com:9089", "cm-secret"), true)
+	test(external("cm.example.com", "cm-secret"), true)
+
+	// a ClusterMaster cannot also be referenced
+	spec := external("https://cm.example.com:8089", "cm-secret")
+	spec.ClusterMasterRef.Name = "cm"
+	test(spec, true)
+
+	// configuration managed by the cluster master is not allowed
+	spec = external("https://cm.example.com:8089", "cm-secret")
+	spec.ReplicationFactor = 2
+	test(spec, true)
+}
+
+func TestExternalClusterMaster(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	if !HasOwnClusterMaster(&cr.Spec) {
+		t.Errorf("HasOwnClusterMaster() = false; want true")
+	}
+
+	cr.Spec.ExternalClusterMaster = enterprisev1.ExternalClusterMasterSpec{URL: "https://cm.example.com", SecretRef: "cm-secret"}
+	if err := ValidateIndexerClusterSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateIndexerClusterSpec() returned %v; want nil", err)
+	}
+	if HasOwnClusterMaster(&cr.Spec) {
+		t.Errorf("HasOwnClusterMaster() = true; want false")
+	}
+	if got, want := GetExternalClusterMasterURI(&cr.Spec.ExternalClusterMaster), "https://cm.example.com:8089"; got != want {
+		t.Errorf("GetExternalClusterMasterURI() = %s; want %s", got, want)
+	}
+
+	// peers use the host name of the external cluster master
+	if got, want := getClusterMasterURL(&cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer), "cm.example.com"; got != want {
+		t.Errorf("getClusterMasterURL() = \"%s\"; want \"%s\"", got, want)
+	}
+	ss, err := GetIndexerStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetIndexerStatefulSet() returned error: %v", err)
+	}
+	found := false
+	for _, v := range ss.Spec.Template.Spec.Containers[0].Env {
+		if v.Name == "SPLUNK_CLUSTER_MASTER_URL" {
+			found = true
+			if v.Value != "cm.example.com" {
+				t.Errorf("GetIndexerStatefulSet() env SPLUNK_CLUSTER_MASTER_URL = \"%s\"; want \"%s\"", v.Value, "cm.example.com")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("GetIndexerStatefulSet() did not set SPLUNK_CLUSTER_MASTER_URL")
+	}
+
+	// Splunk Web is not exposed for peers, since it is served by the external cluster master
+	ingress := GetSplunkIngress(&cr, &enterprisev1.IngressSpec{Host: "splunk.example.com", WebPath: "/"}, SplunkIndexer)
+	for _, rule := range ingress.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.ServiceName == GetSplunkServiceName(SplunkClusterMaster, "stack1", false) {
+				t.Errorf("GetSplunkIngress() includes path %s for the cluster master; want none", path.Path)
+			}
+		}
+	}
+}
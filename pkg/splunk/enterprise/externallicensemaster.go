@@ -0,0 +1,43 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// IsExternalLicenseMasterConfigured returns true if instances use a license master that is not managed by the operator
+func IsExternalLicenseMasterConfigured(spec *enterprisev1.CommonSplunkSpec) bool {
+	return spec.LicenseMasterURL != ""
+}
+
+// validateExternalLicenseMaster checks validity of the external license master of a CommonSplunkSpec, and returns error if something is wrong.
+func validateExternalLicenseMaster(spec *enterprisev1.CommonSplunkSpec) error {
+	if !IsExternalLicenseMasterConfigured(spec) {
+		return nil
+	}
+	if spec.LicenseMasterRef.Name != "" {
+		return fmt.Errorf("licenseMasterURL cannot be used with licenseMasterRef")
+	}
+	if err := validateExternalManagementURL("licenseMasterURL", spec.LicenseMasterURL); err != nil {
+		return err
+	}
+	if spec.LicenseMasterSecretRef == "" {
+		return fmt.Errorf("licenseMasterSecretRef is required with licenseMasterURL")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateExternalLicenseMaster(t *testing.T) {
+	test := func(url, secretRef, licenseMasterRef string, wantErr bool) {
+		spec := enterprisev1.StandaloneSpec{}
+		spec.LicenseMasterURL = url
+		spec.LicenseMasterSecretRef = secretRef
+		spec.LicenseMasterRef.Name = licenseMasterRef
+		err := ValidateStandaloneSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateStandaloneSpec(%s, %s, %s) returned nil; want error", url, secretRef, licenseMasterRef)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateStandaloneSpec(%s, %s, %s) returned %v; want nil", url, secretRef, licenseMasterRef, err)
+		}
+	}
+
+	test("", "", "", false)
+	test("https://lm.example.com:8089", "lm-secret", "", false)
+	test("https://lm.example.com", "lm-secret", "", false)
+	test("https://lm.example.com:8089", "", "", true)
+	test("https://lm.example.com:8089", "lm-secret", "lm", true)
+	test("http://lm.example.com:8089", "lm-secret", "", true)
+	test("https://lm.example.com:9089", "lm-secret", "", true)
+	test("lm.example.com", "lm-secret", "", true)
+
+	// a license master cannot use another license master
+	spec := enterprisev1.LicenseMasterSpec{}
+	spec.LicenseMasterURL = "https://lm.example.com:8089"
+	spec.LicenseMasterSecretRef = "lm-secret"
+	if err := ValidateLicenseMasterSpec(&spec); err == nil {
+		t.Errorf("ValidateLicenseMasterSpec() returned nil; want error")
+	}
+}
+
+func TestExternalLicenseMasterEnv(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.LicenseMasterURL = "https://lm.example.com:8089"
+	cr.Spec.LicenseMasterSecretRef = "lm-secret"
+	if err := ValidateStandaloneSpec(&cr.Spec); err != nil {
+		t.Fatalf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+
+	// license slaves use the host name of the external license master
+	ss, err := GetStandaloneStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetStandaloneStatefulSet() returned error: %v", err)
+	}
+	found := false
+	for _, v := range ss.Spec.Template.Spec.Containers[0].Env {
+		if v.Name == "SPLUNK_LICENSE_MASTER_URL" {
+			found = true
+			if v.Value != "lm.example.com" {
+				t.Errorf("GetStandaloneStatefulSet() env SPLUNK_LICENSE_MASTER_URL = \"%s\"; want \"%s\"", v.Value, "lm.example.com")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("GetStandaloneStatefulSet() did not set SPLUNK_LICENSE_MASTER_URL")
+	}
+}
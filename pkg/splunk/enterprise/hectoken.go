@@ -0,0 +1,105 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// GetHecTokenInstanceType returns the instance type of the Splunk Enterprise instances that receive events for a kind of
+// custom resource that HEC tokens can be configured on, or an empty string if the kind is not supported.
+func GetHecTokenInstanceType(kind string) InstanceType {
+	switch kind {
+	case "Standalone":
+		return SplunkStandalone
+	case "IndexerCluster":
+		return SplunkIndexer
+	}
+	return ""
+}
+
+// ValidateHecTokenSpec checks validity and makes default updates to a HecTokenSpec, and returns error if something is wrong.
+func ValidateHecTokenSpec(spec *enterprisev1.HecTokenSpec) error {
+	if spec.TargetRef.Name == "" {
+		return fmt.Errorf("TargetRef name is required")
+	}
+	if GetHecTokenInstanceType(spec.TargetRef.Kind) == "" {
+		return fmt.Errorf("TargetRef kind must be Standalone or IndexerCluster; value=\"%s\"", spec.TargetRef.Kind)
+	}
+
+	if spec.Index != "" && len(spec.Indexes) > 0 {
+		found := false
+		for _, index := range spec.Indexes {
+			if index == spec.Index {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Indexes must include the default index; value=\"%s\"", spec.Index)
+		}
+	}
+
+	return validateSecretRotationInterval(spec.RotationInterval)
+}
+
+// GetHecTokenName returns the name of the token configured in Splunk for a HecToken resource.
+func GetHecTokenName(cr *enterprisev1.HecToken) string {
+	if cr.Spec.TokenName != "" {
+		return cr.Spec.TokenName
+	}
+	return cr.GetIdentifier()
+}
+
+// GetHecTokenURL returns the URL of the HTTP Event Collector used by applications to send events to the target of a HecToken resource.
+// HEC is not configured to use SSL for Splunk Enterprise instances managed by the operator.
+func GetHecTokenURL(cr *enterprisev1.HecToken) string {
+	instanceType := GetHecTokenInstanceType(cr.Spec.TargetRef.Kind)
+	fqdnName := resources.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(instanceType, cr.Spec.TargetRef.Name, false))
+	return fmt.Sprintf("http://%s:%d", fqdnName, getSplunkPorts(instanceType)["hec"])
+}
+
+// GetHecTokenSecret returns a Kubernetes Secret containing a randomly generated value for a HecToken resource, and
+// the URL that applications use to send events with it.
+func GetHecTokenSecret(cr *enterprisev1.HecToken) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkHecTokenSecretName(cr.GetIdentifier()),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: map[string][]byte{
+			"hec_token": generateHECToken(),
+			"hec_url":   []byte(GetHecTokenURL(cr)),
+		},
+	}
+	secret.SetOwnerReferences(append(secret.GetOwnerReferences(), resources.AsOwner(cr)))
+	return secret
+}
+
+// RotateHecToken generates a new value for the token in a HecToken resource's Secret, and records when it was rotated.
+func RotateHecToken(secret *corev1.Secret, now time.Time) {
+	secret.Data["hec_token"] = generateHECToken()
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[SecretsRotatedAnnotation] = now.UTC().Format(time.RFC3339)
+}
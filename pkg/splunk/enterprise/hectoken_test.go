@@ -0,0 +1,106 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateHecTokenSpec(t *testing.T) {
+	test := func(spec enterprisev1.HecTokenSpec, wantErr bool) {
+		err := ValidateHecTokenSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateHecTokenSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateHecTokenSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	target := corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"}
+	test(enterprisev1.HecTokenSpec{TargetRef: target}, false)
+	test(enterprisev1.HecTokenSpec{TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"}}, false)
+	test(enterprisev1.HecTokenSpec{TargetRef: corev1.ObjectReference{Kind: "IndexerCluster"}}, true)
+	test(enterprisev1.HecTokenSpec{TargetRef: corev1.ObjectReference{Kind: "SearchHeadCluster", Name: "stack1"}}, true)
+	test(enterprisev1.HecTokenSpec{TargetRef: target, Index: "app1"}, false)
+	test(enterprisev1.HecTokenSpec{TargetRef: target, Index: "app1", Indexes: []string{"main", "app1"}}, false)
+	test(enterprisev1.HecTokenSpec{TargetRef: target, Index: "app1", Indexes: []string{"main"}}, true)
+	test(enterprisev1.HecTokenSpec{TargetRef: target, RotationInterval: "720h"}, false)
+	test(enterprisev1.HecTokenSpec{TargetRef: target, RotationInterval: "10m"}, true)
+}
+
+func TestGetHecTokenName(t *testing.T) {
+	cr := enterprisev1.HecToken{ObjectMeta: metav1.ObjectMeta{Name: "app1", Namespace: "test"}}
+	if got := GetHecTokenName(&cr); got != "app1" {
+		t.Errorf("GetHecTokenName() = %s; want %s", got, "app1")
+	}
+	cr.Spec.TokenName = "app1-events"
+	if got := GetHecTokenName(&cr); got != "app1-events" {
+		t.Errorf("GetHecTokenName() = %s; want %s", got, "app1-events")
+	}
+}
+
+func TestGetHecTokenSecret(t *testing.T) {
+	cr := enterprisev1.HecToken{
+		ObjectMeta: metav1.ObjectMeta{Name: "app1", Namespace: "test"},
+		Spec:       enterprisev1.HecTokenSpec{TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"}},
+	}
+
+	test := func(wantURL string) {
+		secret := GetHecTokenSecret(&cr)
+		if secret.GetName() != "splunk-app1-hec-token" {
+			t.Errorf("GetHecTokenSecret() name = %s; want %s", secret.GetName(), "splunk-app1-hec-token")
+		}
+		if len(secret.Data["hec_token"]) != 36 {
+			t.Errorf("GetHecTokenSecret() hec_token = %s; want 36 characters", secret.Data["hec_token"])
+		}
+		if got := string(secret.Data["hec_url"]); got != wantURL {
+			t.Errorf("GetHecTokenSecret() hec_url = %s; want %s", got, wantURL)
+		}
+		if len(secret.GetOwnerReferences()) != 1 {
+			t.Errorf("GetHecTokenSecret() owner references = %v; want 1", secret.GetOwnerReferences())
+		}
+	}
+
+	test("http://splunk-stack1-indexer-service.test.svc.cluster.local:8088")
+	cr.Spec.TargetRef.Kind = "Standalone"
+	test("http://splunk-stack1-standalone-service.test.svc.cluster.local:8088")
+}
+
+func TestRotateHecToken(t *testing.T) {
+	cr := enterprisev1.HecToken{
+		ObjectMeta: metav1.ObjectMeta{Name: "app1", Namespace: "test"},
+		Spec:       enterprisev1.HecTokenSpec{TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"}},
+	}
+	secret := GetHecTokenSecret(&cr)
+	token := string(secret.Data["hec_token"])
+	now := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+
+	RotateHecToken(secret, now)
+	if string(secret.Data["hec_token"]) == token {
+		t.Errorf("RotateHecToken() did not change hec_token")
+	}
+	if got := secret.GetAnnotations()[SecretsRotatedAnnotation]; got != "2020-05-08T10:00:00Z" {
+		t.Errorf("RotateHecToken() %s = %s; want %s", SecretsRotatedAnnotation, got, "2020-05-08T10:00:00Z")
+	}
+	if IsSecretsRotationDue(secret, "720h", now.Add(time.Hour)) {
+		t.Errorf("IsSecretsRotationDue() = true after RotateHecToken(); want false")
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// pod template annotation used to restart pods when indexer discovery configuration changes
+	indexerDiscoveryChecksumAnnotation = "enterprise.splunk.com/indexer-discovery-checksum"
+
+	// app directory used to install indexer discovery configuration on cluster masters and indexer cluster peers
+	indexerDiscoveryAppPath = "/opt/splunk/etc/apps/splunk-operator-indexer-discovery/local"
+
+	// maximum polling rate supported by indexer discovery
+	maxIndexerDiscoveryPollingRate = 10
+)
+
+// IsIndexerDiscoveryEnabled returns true if indexer discovery has been enabled for external forwarders
+func IsIndexerDiscoveryEnabled(spec *enterprisev1.IndexerDiscoverySpec) bool {
+	return spec.Enabled
+}
+
+// validateIndexerDiscoverySpec checks validity and makes default updates to an IndexerDiscoverySpec, and returns error if something is wrong.
+func validateIndexerDiscoverySpec(spec *enterprisev1.IndexerDiscoverySpec) error {
+	if !IsIndexerDiscoveryEnabled(spec) {
+		return nil
+	}
+
+	if spec.ServiceType == "" {
+		spec.ServiceType = corev1.ServiceTypeLoadBalancer
+	}
+	if spec.ServiceType != corev1.ServiceTypeLoadBalancer && spec.ServiceType != corev1.ServiceTypeNodePort {
+		return fmt.Errorf("IndexerDiscovery serviceType must be either \"%s\" or \"%s\"; value=\"%s\"", corev1.ServiceTypeLoadBalancer, corev1.ServiceTypeNodePort, spec.ServiceType)
+	}
+
+	if spec.PollingRate < 0 || spec.PollingRate > maxIndexerDiscoveryPollingRate {
+		return fmt.Errorf("IndexerDiscovery pollingRate must be between 1 and %d; value=%d", maxIndexerDiscoveryPollingRate, spec.PollingRate)
+	}
+
+	return nil
+}
+
+// getIndexerDiscoveryPortName returns the name of the port that forwarders use to reach an instance type
+func getIndexerDiscoveryPortName(instanceType InstanceType) string {
+	if instanceType == SplunkIndexer {
+		return "s2s"
+	}
+	return "splunkd"
+}
+
+// GetIndexerDiscoveryService returns an external Kubernetes Service used by forwarders to reach the management port of
+// a cluster master, or the receiving port of indexer cluster peers.
+func GetIndexerDiscoveryService(cr enterprisev1.MetaObject, spec *enterprisev1.IndexerDiscoverySpec, instanceType InstanceType) *corev1.Service {
+	portName := getIndexerDiscoveryPortName(instanceType)
+	port := getSplunkPorts(instanceType)[portName]
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetSplunkIndexerDiscoveryServiceName(instanceType, cr.GetIdentifier()),
+			Namespace:   cr.GetNamespace(),
+			Labels:      getSplunkLabelsForCR(cr, instanceType),
+			Annotations: make(map[string]string),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     spec.ServiceType,
+			Selector: getSplunkLabelsForCR(cr, instanceType),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       portName,
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	for k, v := range spec.Annotations {
+		service.ObjectMeta.Annotations[k] = v
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(service.ObjectMeta.GetObjectMeta(), cr.GetObjectMeta())
+
+	service.SetOwnerReferences(append(service.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return service
+}
+
+// getIndexerDiscoveryAddress returns the external address assigned to a LoadBalancer service, or an empty string if
+// one has not been assigned yet. NodePort services can be reached using the address of any node, so this is always empty.
+func getIndexerDiscoveryAddress(service *corev1.Service) string {
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return ""
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+	}
+	return ""
+}
+
+// GetIndexerDiscoveryEndpoints returns the external endpoints ("address:port") of an indexer discovery service. Endpoints of
+// LoadBalancer services are only returned once an address has been assigned, and endpoints of NodePort services are returned
+// without an address (":port"), since they can be reached using the address of any node.
+func GetIndexerDiscoveryEndpoints(service *corev1.Service) []string {
+	var endpoints []string
+	address := getIndexerDiscoveryAddress(service)
+	for _, p := range service.Spec.Ports {
+		switch service.Spec.Type {
+		case corev1.ServiceTypeLoadBalancer:
+			if address != "" {
+				endpoints = append(endpoints, fmt.Sprintf("%s:%d", address, p.Port))
+			}
+		case corev1.ServiceTypeNodePort:
+			if p.NodePort != 0 {
+				endpoints = append(endpoints, fmt.Sprintf(":%d", p.NodePort))
+			}
+		}
+	}
+	return endpoints
+}
+
+// getIndexerDiscoveryServerConf returns the contents of a server.conf file used for indexer discovery. Cluster masters
+// enable indexer discovery, and indexer cluster peers register the external address of their service with the cluster
+// master, so that it is returned to forwarders instead of pod addresses.
+func getIndexerDiscoveryServerConf(spec *enterprisev1.IndexerDiscoverySpec, instanceType InstanceType, service *corev1.Service, pass4SymmKey []byte) string {
+	var sb strings.Builder
+
+	if instanceType == SplunkIndexer {
+		if address := getIndexerDiscoveryAddress(service); address != "" {
+			fmt.Fprintf(&sb, "[clustering]\nregister_forwarder_address = %s\n", address)
+		}
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "[indexer_discovery]\npass4SymmKey = %s\n", pass4SymmKey)
+	if spec.PollingRate != 0 {
+		fmt.Fprintf(&sb, "polling_rate = %d\n", spec.PollingRate)
+	}
+	if spec.IndexerWeightByDiskCapacity {
+		sb.WriteString("indexerWeightByDiskCapacity = true\n")
+	}
+	return sb.String()
+}
+
+// GetIndexerDiscoverySecret returns a Kubernetes Secret containing the server.conf file generated for indexer discovery.
+// Secrets for cluster masters also contain the pass4SymmKey that forwarders must use, which is kept from current
+// (if not nil) or randomly generated.
+func GetIndexerDiscoverySecret(cr enterprisev1.MetaObject, spec *enterprisev1.IndexerDiscoverySpec, instanceType InstanceType, service *corev1.Service, current *corev1.Secret) *corev1.Secret {
+	var pass4SymmKey []byte
+	if instanceType != SplunkIndexer {
+		if current != nil {
+			pass4SymmKey = current.Data["pass4SymmKey"]
+		}
+		if len(pass4SymmKey) == 0 {
+			pass4SymmKey = generateSplunkSecret()
+		}
+	}
+
+	data := map[string][]byte{
+		"server.conf": []byte(getIndexerDiscoveryServerConf(spec, instanceType, service, pass4SymmKey)),
+	}
+	if pass4SymmKey != nil {
+		data["pass4SymmKey"] = pass4SymmKey
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkIndexerDiscoveryName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}
+}
+
+// GetIndexerDiscoveryChecksum returns a checksum of the indexer discovery configuration contained in a Kubernetes Secret.
+func GetIndexerDiscoveryChecksum(secret *corev1.Secret) string {
+	return GetSmartStoreChecksum(secret)
+}
+
+// SetIndexerDiscoveryChecksum annotates a pod template with an indexer discovery configuration checksum, so that pods are
+// recycled when it changes. Nothing is done if secret is nil.
+func SetIndexerDiscoveryChecksum(podTemplateSpec *corev1.PodTemplateSpec, secret *corev1.Secret) {
+	if secret == nil {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[indexerDiscoveryChecksumAnnotation] = GetIndexerDiscoveryChecksum(secret)
+}
+
+// addIndexerDiscoveryToPodTemplate mounts generated indexer discovery configuration as an app for cluster masters and
+// indexer cluster peers, if indexer discovery is enabled.
+func addIndexerDiscoveryToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.IndexerDiscoverySpec, instanceType InstanceType) {
+	if !IsIndexerDiscoveryEnabled(spec) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	// only server.conf is mounted, so that the pass4SymmKey used by forwarders is not installed as a file in the app
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-indexer-discovery",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  GetSplunkIndexerDiscoveryName(cr.GetIdentifier(), instanceType),
+				Items:       []corev1.KeyToPath{{Key: "server.conf", Path: "server.conf"}},
+				DefaultMode: &secretVolDefaultMode,
+			},
+		},
+	})
+
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      "mnt-splunk-indexer-discovery",
+			MountPath: indexerDiscoveryAppPath,
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateIndexerDiscoverySpec(t *testing.T) {
+	test := func(spec enterprisev1.IndexerDiscoverySpec, wantErr bool, wantServiceType corev1.ServiceType) {
+		err := validateIndexerDiscoverySpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateIndexerDiscoverySpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateIndexerDiscoverySpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && spec.ServiceType != wantServiceType {
+			t.Errorf("validateIndexerDiscoverySpec() serviceType = %s; want %s", spec.ServiceType, wantServiceType)
+		}
+	}
+
+	test(enterprisev1.IndexerDiscoverySpec{}, false, "")
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true}, false, corev1.ServiceTypeLoadBalancer)
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true, ServiceType: corev1.ServiceTypeNodePort}, false, corev1.ServiceTypeNodePort)
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true, ServiceType: corev1.ServiceTypeClusterIP}, true, "")
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true, PollingRate: 5}, false, corev1.ServiceTypeLoadBalancer)
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true, PollingRate: 11}, true, "")
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true, PollingRate: -1}, true, "")
+}
+
+func TestGetIndexerDiscoveryService(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.IndexerDiscoverySpec{
+		Enabled:     true,
+		ServiceType: corev1.ServiceTypeLoadBalancer,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+	}
+
+	test := func(instanceType InstanceType, wantName string, wantPort int32) {
+		service := GetIndexerDiscoveryService(&cr, &spec, instanceType)
+		if service.GetName() != wantName {
+			t.Errorf("GetIndexerDiscoveryService(%s) name = %s; want %s", instanceType, service.GetName(), wantName)
+		}
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			t.Errorf("GetIndexerDiscoveryService(%s) type = %s; want %s", instanceType, service.Spec.Type, corev1.ServiceTypeLoadBalancer)
+		}
+		if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != wantPort {
+			t.Errorf("GetIndexerDiscoveryService(%s) ports = %v; want %d", instanceType, service.Spec.Ports, wantPort)
+		}
+		if !reflect.DeepEqual(service.Spec.Selector, getSplunkLabels("stack1", instanceType)) {
+			t.Errorf("GetIndexerDiscoveryService(%s) selector = %v; want %v", instanceType, service.Spec.Selector, getSplunkLabels("stack1", instanceType))
+		}
+		if service.GetAnnotations()["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" {
+			t.Errorf("GetIndexerDiscoveryService(%s) annotations = %v; want aws-load-balancer-type=nlb", instanceType, service.GetAnnotations())
+		}
+		if len(service.GetOwnerReferences()) != 1 {
+			t.Errorf("GetIndexerDiscoveryService(%s) owner references = %v; want 1", instanceType, service.GetOwnerReferences())
+		}
+	}
+
+	test(SplunkClusterMaster, "splunk-stack1-cluster-master-discovery", 8089)
+	test(SplunkIndexer, "splunk-stack1-indexer-discovery", 9997)
+}
+
+func TestGetIndexerDiscoveryEndpoints(t *testing.T) {
+	test := func(service corev1.Service, want []string) {
+		got := GetIndexerDiscoveryEndpoints(&service)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetIndexerDiscoveryEndpoints(%v) = %v; want %v", service, got, want)
+		}
+	}
+
+	ports := []corev1.ServicePort{{Name: "s2s", Port: 9997, NodePort: 31234}}
+	lb := corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports}}
+	test(lb, nil)
+	lb.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "idxc.elb.amazonaws.com"}}
+	test(lb, []string{"idxc.elb.amazonaws.com:9997"})
+	lb.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	test(lb, []string{"10.1.2.3:9997"})
+	test(corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: ports}}, []string{":31234"})
+	test(corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Name: "s2s", Port: 9997}}}}, nil)
+}
+
+func TestGetIndexerDiscoverySecret(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.IndexerDiscoverySpec{Enabled: true, ServiceType: corev1.ServiceTypeLoadBalancer, PollingRate: 5, IndexerWeightByDiskCapacity: true}
+	service := corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}}
+
+	// cluster master pass4SymmKey is generated, and kept once it exists
+	secret := GetIndexerDiscoverySecret(&cr, &spec, SplunkClusterMaster, &service, nil)
+	if secret.GetName() != "splunk-stack1-cluster-master-discovery-config" {
+		t.Errorf("GetIndexerDiscoverySecret() name = %s; want %s", secret.GetName(), "splunk-stack1-cluster-master-discovery-config")
+	}
+	if len(secret.Data["pass4SymmKey"]) == 0 {
+		t.Errorf("GetIndexerDiscoverySecret() did not generate a pass4SymmKey")
+	}
+	current := corev1.Secret{Data: map[string][]byte{"pass4SymmKey": []byte("s3cr3t")}}
+	secret = GetIndexerDiscoverySecret(&cr, &spec, SplunkClusterMaster, &service, &current)
+	want := "[indexer_discovery]\npass4SymmKey = s3cr3t\npolling_rate = 5\nindexerWeightByDiskCapacity = true\n"
+	if got := string(secret.Data["server.conf"]); got != want {
+		t.Errorf("GetIndexerDiscoverySecret() server.conf = %s; want %s", got, want)
+	}
+
+	// peers only register a forwarder address once the load balancer has one
+	secret = GetIndexerDiscoverySecret(&cr, &spec, SplunkIndexer, &service, nil)
+	if got := string(secret.Data["server.conf"]); got != "" {
+		t.Errorf("GetIndexerDiscoverySecret() server.conf = %s; want empty", got)
+	}
+	if _, ok := secret.Data["pass4SymmKey"]; ok {
+		t.Errorf("GetIndexerDiscoverySecret() included pass4SymmKey for indexers")
+	}
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "idxc.elb.amazonaws.com"}}
+	secret = GetIndexerDiscoverySecret(&cr, &spec, SplunkIndexer, &service, nil)
+	want = "[clustering]\nregister_forwarder_address = idxc.elb.amazonaws.com\n"
+	if got := string(secret.Data["server.conf"]); got != want {
+		t.Errorf("GetIndexerDiscoverySecret() server.conf = %s; want %s", got, want)
+	}
+}
+
+func TestAddIndexerDiscoveryToPodTemplate(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(spec enterprisev1.IndexerDiscoverySpec, instanceType InstanceType, wantMount bool) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		addIndexerDiscoveryToPodTemplate(&podTemplateSpec, &cr, &spec, instanceType)
+		mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+		if !wantMount {
+			if len(mounts) != 0 || len(podTemplateSpec.Spec.Volumes) != 0 {
+				t.Errorf("addIndexerDiscoveryToPodTemplate(%s) added volumes; want none", instanceType)
+			}
+			return
+		}
+		if len(mounts) != 1 || mounts[0].MountPath != indexerDiscoveryAppPath {
+			t.Errorf("addIndexerDiscoveryToPodTemplate(%s) mounts = %v; want %s", instanceType, mounts, indexerDiscoveryAppPath)
+		}
+		if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Secret.SecretName != GetSplunkIndexerDiscoveryName("stack1", instanceType) {
+			t.Errorf("addIndexerDiscoveryToPodTemplate(%s) volumes = %v; want secret %s", instanceType, podTemplateSpec.Spec.Volumes, GetSplunkIndexerDiscoveryName("stack1", instanceType))
+		}
+	}
+
+	test(enterprisev1.IndexerDiscoverySpec{}, SplunkClusterMaster, false)
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true}, SplunkClusterMaster, true)
+	test(enterprisev1.IndexerDiscoverySpec{Enabled: true}, SplunkIndexer, true)
+}
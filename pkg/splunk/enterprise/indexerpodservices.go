@@ -0,0 +1,120 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// label set by Kubernetes on each pod of a StatefulSet, used to select a single pod
+const statefulSetPodNameLabelKey = "statefulset.kubernetes.io/pod-name"
+
+// IsIndexerPodServicesEnabled returns true if external services have been enabled for each indexer cluster peer
+func IsIndexerPodServicesEnabled(spec *enterprisev1.IndexerPodServicesSpec) bool {
+	return spec.Enabled
+}
+
+// validateIndexerPodServicesSpec checks validity and makes default updates to an IndexerPodServicesSpec, and returns error if something is wrong.
+func validateIndexerPodServicesSpec(spec *enterprisev1.IndexerPodServicesSpec) error {
+	if !IsIndexerPodServicesEnabled(spec) {
+		return nil
+	}
+
+	if spec.ServiceType == "" {
+		spec.ServiceType = corev1.ServiceTypeLoadBalancer
+	}
+	if spec.ServiceType != corev1.ServiceTypeLoadBalancer && spec.ServiceType != corev1.ServiceTypeNodePort {
+		return fmt.Errorf("PodServices serviceType must be either \"%s\" or \"%s\"; value=\"%s\"", corev1.ServiceTypeLoadBalancer, corev1.ServiceTypeNodePort, spec.ServiceType)
+	}
+
+	return nil
+}
+
+// GetIndexerPodNames returns the names of the pods of all indexer cluster peers, including each site of a multisite indexer cluster.
+func GetIndexerPodNames(cr *enterprisev1.IndexerCluster) []string {
+	var names []string
+	if len(cr.Spec.Sites) == 0 {
+		for i := int32(0); i < cr.Spec.Replicas; i++ {
+			names = append(names, GetSplunkStatefulsetPodName(SplunkIndexer, cr.GetIdentifier(), i))
+		}
+		return names
+	}
+	for _, site := range cr.Spec.Sites {
+		siteIdentifier := GetSplunkSiteIdentifier(cr.GetIdentifier(), site.Name)
+		for i := int32(0); i < site.Replicas; i++ {
+			names = append(names, GetSplunkStatefulsetPodName(SplunkIndexer, siteIdentifier, i))
+		}
+	}
+	return names
+}
+
+// GetIndexerPodService returns an external Kubernetes Service used by forwarders to reach the receiving port of a single
+// indexer cluster peer, selected using the pod name label set by its StatefulSet.
+func GetIndexerPodService(cr enterprisev1.MetaObject, spec *enterprisev1.IndexerPodServicesSpec, podName string) *corev1.Service {
+	port := getSplunkPorts(SplunkIndexer)["s2s"]
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetSplunkPodServiceName(podName),
+			Namespace:   cr.GetNamespace(),
+			Labels:      getSplunkLabels(cr.GetIdentifier(), SplunkIndexer),
+			Annotations: make(map[string]string),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     spec.ServiceType,
+			Selector: map[string]string{statefulSetPodNameLabelKey: podName},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "s2s",
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	for k, v := range spec.Annotations {
+		service.ObjectMeta.Annotations[k] = v
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(service.ObjectMeta.GetObjectMeta(), cr.GetObjectMeta())
+
+	service.SetOwnerReferences(append(service.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return service
+}
+
+// GetIndexerPodServiceEndpoint returns the external endpoint ("address:port") of an indexer cluster peer's service, in the
+// same way as GetIndexerDiscoveryEndpoints, or an empty string if it is not available yet.
+func GetIndexerPodServiceEndpoint(service *corev1.Service) string {
+	endpoints := GetIndexerDiscoveryEndpoints(service)
+	if len(endpoints) == 0 {
+		return ""
+	}
+	return endpoints[0]
+}
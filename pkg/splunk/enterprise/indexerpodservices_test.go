@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateIndexerPodServicesSpec(t *testing.T) {
+	test := func(spec enterprisev1.IndexerPodServicesSpec, wantErr bool, wantServiceType corev1.ServiceType) {
+		err := validateIndexerPodServicesSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateIndexerPodServicesSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateIndexerPodServicesSpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && spec.ServiceType != wantServiceType {
+			t.Errorf("validateIndexerPodServicesSpec() serviceType = %s; want %s", spec.ServiceType, wantServiceType)
+		}
+	}
+
+	test(enterprisev1.IndexerPodServicesSpec{}, false, "")
+	test(enterprisev1.IndexerPodServicesSpec{Enabled: true}, false, corev1.ServiceTypeLoadBalancer)
+	test(enterprisev1.IndexerPodServicesSpec{Enabled: true, ServiceType: corev1.ServiceTypeNodePort}, false, corev1.ServiceTypeNodePort)
+	test(enterprisev1.IndexerPodServicesSpec{Enabled: true, ServiceType: corev1.ServiceTypeClusterIP}, true, "")
+}
+
+func TestGetIndexerPodNames(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.IndexerClusterSpec{Replicas: 2},
+	}
+
+	want := []string{"splunk-stack1-indexer-0", "splunk-stack1-indexer-1"}
+	if got := GetIndexerPodNames(&cr); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetIndexerPodNames() = %v; want %v", got, want)
+	}
+
+	cr.Spec.Sites = []enterprisev1.IndexerClusterSiteSpec{{Name: "site1", Replicas: 1}, {Name: "site2", Replicas: 2}}
+	want = []string{"splunk-stack1-site1-indexer-0", "splunk-stack1-site2-indexer-0", "splunk-stack1-site2-indexer-1"}
+	if got := GetIndexerPodNames(&cr); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetIndexerPodNames() = %v; want %v", got, want)
+	}
+}
+
+func TestGetIndexerPodService(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.IndexerPodServicesSpec{
+		Enabled:     true,
+		ServiceType: corev1.ServiceTypeNodePort,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+	}
+
+	service := GetIndexerPodService(&cr, &spec, "splunk-stack1-indexer-1")
+	if service.GetName() != "splunk-stack1-indexer-1-external" {
+		t.Errorf("GetIndexerPodService() name = %s; want %s", service.GetName(), "splunk-stack1-indexer-1-external")
+	}
+	if service.Spec.Type != corev1.ServiceTypeNodePort {
+		t.Errorf("GetIndexerPodService() type = %s; want %s", service.Spec.Type, corev1.ServiceTypeNodePort)
+	}
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != 9997 {
+		t.Errorf("GetIndexerPodService() ports = %v; want 9997", service.Spec.Ports)
+	}
+	wantSelector := map[string]string{"statefulset.kubernetes.io/pod-name": "splunk-stack1-indexer-1"}
+	if !reflect.DeepEqual(service.Spec.Selector, wantSelector) {
+		t.Errorf("GetIndexerPodService() selector = %v; want %v", service.Spec.Selector, wantSelector)
+	}
+	if service.GetAnnotations()["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" {
+		t.Errorf("GetIndexerPodService() annotations = %v; want aws-load-balancer-type=nlb", service.GetAnnotations())
+	}
+	if len(service.GetOwnerReferences()) != 1 {
+		t.Errorf("GetIndexerPodService() owner references = %v; want 1", service.GetOwnerReferences())
+	}
+
+	if got := GetIndexerPodServiceEndpoint(service); got != "" {
+		t.Errorf("GetIndexerPodServiceEndpoint() = %s; want empty", got)
+	}
+	service.Spec.Ports[0].NodePort = 31234
+	if got := GetIndexerPodServiceEndpoint(service); got != ":31234" {
+		t.Errorf("GetIndexerPodServiceEndpoint() = %s; want %s", got, ":31234")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// annotation used to select the ingress controller that implements an ingress
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+	// default path used to reach Splunk Web
+	defaultIngressWebPath = "/"
+
+	// default path used to reach the HTTP Event Collector
+	defaultIngressHECPath = "/services/collector"
+)
+
+// IsIngressConfigured returns true if a host has been configured for an ingress
+func IsIngressConfigured(spec *enterprisev1.IngressSpec) bool {
+	return spec.Host != ""
+}
+
+// validateIngressSpec checks validity and makes default updates to an IngressSpec, and returns error if something is wrong.
+func validateIngressSpec(spec *enterprisev1.IngressSpec) error {
+	if !IsIngressConfigured(spec) {
+		return nil
+	}
+
+	if spec.WebPath == "" {
+		spec.WebPath = defaultIngressWebPath
+	}
+	if !strings.HasPrefix(spec.WebPath, "/") {
+		return fmt.Errorf("Ingress webPath must begin with \"/\"; value=\"%s\"", spec.WebPath)
+	}
+
+	if spec.HECPath == "" {
+		spec.HECPath = defaultIngressHECPath
+	}
+	if !strings.HasPrefix(spec.HECPath, "/") {
+		return fmt.Errorf("Ingress hecPath must begin with \"/\"; value=\"%s\"", spec.HECPath)
+	}
+
+	return nil
+}
+
+// getIngressBackend returns an IngressBackend for a port of the service used to reach an instance type
+func getIngressBackend(identifier string, instanceType InstanceType, port int) networkingv1beta1.IngressBackend {
+	// standalone instances only have a headless service
+	isHeadless := instanceType == SplunkStandalone
+	return networkingv1beta1.IngressBackend{
+		ServiceName: GetSplunkServiceName(instanceType, identifier, isHeadless),
+		ServicePort: intstr.FromInt(port),
+	}
+}
+
+// GetSplunkIngress returns a Kubernetes Ingress for the Splunk Web and HTTP Event Collector endpoints of a SplunkEnterprise resource.
+// Splunk Web is reached using the cluster master for indexer clusters (and is not included for indexer clusters that reference a
+// ClusterMaster, which has its own ingress, or that join an external cluster master), and HEC is only included for instances that
+// receive HEC data.
+func GetSplunkIngress(cr enterprisev1.MetaObject, spec *enterprisev1.IngressSpec, instanceType InstanceType) *networkingv1beta1.Ingress {
+	webInstanceType := instanceType
+	if instanceType == SplunkIndexer {
+		webInstanceType = SplunkClusterMaster
+	}
+
+	paths := []networkingv1beta1.HTTPIngressPath{}
+	if idxc, ok := cr.(*enterprisev1.IndexerCluster); !ok || HasOwnClusterMaster(&idxc.Spec) {
+		paths = append(paths, networkingv1beta1.HTTPIngressPath{
+			Path:    spec.WebPath,
+			Backend: getIngressBackend(cr.GetIdentifier(), webInstanceType, getSplunkPorts(webInstanceType)["splunkweb"]),
+		})
+	}
+	if hecPort, ok := getSplunkPorts(instanceType)["hec"]; ok {
+		paths = append(paths, networkingv1beta1.HTTPIngressPath{
+			Path:    spec.HECPath,
+			Backend: getIngressBackend(cr.GetIdentifier(), instanceType, hecPort),
+		})
+	}
+
+	ingress := &networkingv1beta1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: "networking.k8s.io/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetSplunkIngressName(cr.GetIdentifier(), instanceType),
+			Namespace:   cr.GetNamespace(),
+			Labels:      getSplunkLabelsForCR(cr, instanceType),
+			Annotations: make(map[string]string),
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{Paths: paths},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{
+			{
+				Hosts:      []string{spec.Host},
+				SecretName: spec.TLSSecretName,
+			},
+		}
+	}
+
+	for k, v := range spec.Annotations {
+		ingress.ObjectMeta.Annotations[k] = v
+	}
+	if spec.IngressClass != "" {
+		ingress.ObjectMeta.Annotations[ingressClassAnnotation] = spec.IngressClass
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(ingress.ObjectMeta.GetObjectMeta(), cr.GetObjectMeta())
+
+	ingress.SetOwnerReferences(append(ingress.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return ingress
+}
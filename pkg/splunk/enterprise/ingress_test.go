@@ -0,0 +1,117 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateIngressSpec(t *testing.T) {
+	test := func(spec enterprisev1.IngressSpec, wantErr bool) {
+		err := validateIngressSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateIngressSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateIngressSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.IngressSpec{}, false)
+	test(enterprisev1.IngressSpec{Host: "splunk.example.com"}, false)
+	test(enterprisev1.IngressSpec{Host: "splunk.example.com", WebPath: "/web", HECPath: "/hec"}, false)
+	test(enterprisev1.IngressSpec{Host: "splunk.example.com", WebPath: "web"}, true)
+	test(enterprisev1.IngressSpec{Host: "splunk.example.com", HECPath: "services/collector"}, true)
+
+	// defaults are only set when a host is configured
+	spec := enterprisev1.IngressSpec{}
+	validateIngressSpec(&spec)
+	if spec.WebPath != "" || spec.HECPath != "" {
+		t.Errorf("validateIngressSpec() set defaults without a host: %v", spec)
+	}
+	spec.Host = "splunk.example.com"
+	validateIngressSpec(&spec)
+	if spec.WebPath != "/" || spec.HECPath != "/services/collector" {
+		t.Errorf("validateIngressSpec() webPath=%s hecPath=%s; want / /services/collector", spec.WebPath, spec.HECPath)
+	}
+}
+
+func TestGetSplunkIngress(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.IngressSpec{
+		Host:          "splunk.example.com",
+		TLSSecretName: "splunk-example-tls",
+		IngressClass:  "nginx",
+		WebPath:       "/",
+		HECPath:       "/services/collector",
+		Annotations:   map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS"},
+	}
+
+	test := func(cr enterprisev1.MetaObject, spec enterprisev1.IngressSpec, instanceType InstanceType, want string) {
+		f := func() (interface{}, error) {
+			return GetSplunkIngress(cr, &spec, instanceType), nil
+		}
+		configTester(t, "GetSplunkIngress", f, want)
+	}
+
+	test(&cr, spec, SplunkStandalone, `{"kind":"Ingress","apiVersion":"networking.k8s.io/v1beta1","metadata":{"name":"splunk-stack1-standalone-ingress","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"standalone","app.kubernetes.io/instance":"splunk-stack1-standalone","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"standalone","app.kubernetes.io/part-of":"splunk-stack1-standalone"},"annotations":{"kubernetes.io/ingress.class":"nginx","nginx.ingress.kubernetes.io/backend-protocol":"HTTPS"},"ownerReferences":[{"apiVersion":"","kind":"","name":"stack1","uid":"","controller":true}]},"spec":{"tls":[{"hosts":["splunk.example.com"],"secretName":"splunk-example-tls"}],"rules":[{"host":"splunk.example.com","http":{"paths":[{"path":"/","backend":{"serviceName":"splunk-stack1-standalone-headless","servicePort":8000}},{"path":"/services/collector","backend":{"serviceName":"splunk-stack1-standalone-headless","servicePort":8088}}]}}]},"status":{"loadBalancer":{}}}`)
+
+	// indexer clusters use the cluster master for Splunk Web; search heads do not receive HEC data
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec = enterprisev1.IngressSpec{Host: "splunk.example.com", WebPath: "/", HECPath: "/hec"}
+	ingress := GetSplunkIngress(&idxc, &spec, SplunkIndexer)
+	paths := ingress.Spec.Rules[0].HTTP.Paths
+	if len(paths) != 2 || paths[0].Backend.ServiceName != "splunk-stack1-cluster-master-service" || paths[1].Backend.ServiceName != "splunk-stack1-indexer-service" || paths[1].Path != "/hec" {
+		t.Errorf("GetSplunkIngress(SplunkIndexer) paths = %v; want web on cluster master and /hec on indexers", paths)
+	}
+	if ingress.GetName() != "splunk-stack1-indexer-ingress" || len(ingress.Spec.TLS) != 0 {
+		t.Errorf("GetSplunkIngress(SplunkIndexer) name = %s, tls = %v; want splunk-stack1-indexer-ingress without tls", ingress.GetName(), ingress.Spec.TLS)
+	}
+
+	shc := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	paths = GetSplunkIngress(&shc, &spec, SplunkSearchHead).Spec.Rules[0].HTTP.Paths
+	if len(paths) != 1 || paths[0].Backend.ServiceName != "splunk-stack1-search-head-service" || paths[0].Backend.ServicePort.IntValue() != 8000 {
+		t.Errorf("GetSplunkIngress(SplunkSearchHead) paths = %v; want web on search heads only", paths)
+	}
+
+	// ingress class annotation is only set if configured
+	if _, ok := GetSplunkIngress(&shc, &spec, SplunkSearchHead).GetAnnotations()[ingressClassAnnotation]; ok {
+		t.Errorf("GetSplunkIngress() set %s without an ingress class", ingressClassAnnotation)
+	}
+
+	// labels from parent are appended
+	shc.ObjectMeta.Labels = map[string]string{"team": "security"}
+	if got := GetSplunkIngress(&shc, &spec, SplunkSearchHead).GetLabels()["team"]; got != "security" {
+		t.Errorf("GetSplunkIngress() label team = %s; want security", got)
+	}
+}
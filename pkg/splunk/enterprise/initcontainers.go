@@ -0,0 +1,100 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// name of the init container used to run an init script
+	initScriptContainerName = "init-script"
+
+	// default key of the script in an init script ConfigMap
+	defaultInitScriptKey = "init.sh"
+
+	// directory used to mount an init script ConfigMap
+	initScriptMountPath = "/mnt/splunk-init-script"
+)
+
+// IsInitScriptConfigured returns true if a ConfigMap containing an init script has been referenced
+func IsInitScriptConfigured(spec *enterprisev1.InitScriptSpec) bool {
+	return spec.ConfigMapRef != ""
+}
+
+// validateInitContainers checks validity and makes default updates to init containers and the init script, and returns error if something is wrong.
+func validateInitContainers(spec *enterprisev1.CommonSplunkSpec) error {
+	if err := validateUserContainers("InitContainers", spec.InitContainers, "init", initScriptContainerName); err != nil {
+		return err
+	}
+
+	if IsInitScriptConfigured(&spec.InitScript) {
+		if spec.InitScript.Key == "" {
+			spec.InitScript.Key = defaultInitScriptKey
+		}
+		if spec.InitScript.Image == "" {
+			spec.InitScript.Image = spec.Image
+		}
+	}
+
+	return nil
+}
+
+// getInitScriptContainer returns an init container that runs a script from a ConfigMap, with the etc and var volumes mounted
+func getInitScriptContainer(spec *enterprisev1.CommonSplunkSpec) corev1.Container {
+	return corev1.Container{
+		Name:            initScriptContainerName,
+		Image:           spec.InitScript.Image,
+		ImagePullPolicy: corev1.PullPolicy(spec.ImagePullPolicy),
+		Command:         []string{"/bin/sh", initScriptMountPath + "/" + spec.InitScript.Key},
+		Resources:       spec.Resources,
+		SecurityContext: spec.ContainerSecurityContext.DeepCopy(),
+		VolumeMounts: append(getSplunkVolumeMounts(), corev1.VolumeMount{
+			Name:      "mnt-splunk-init-script",
+			MountPath: initScriptMountPath,
+			ReadOnly:  true,
+		}),
+	}
+}
+
+// addInitContainersToPodTemplate appends user-defined init containers to a pod template, followed by a container that runs
+// the init script (if configured). The init script's ConfigMap is only mounted in its own container.
+func addInitContainersToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.CommonSplunkSpec) {
+	for idx := range spec.InitContainers {
+		podTemplateSpec.Spec.InitContainers = append(podTemplateSpec.Spec.InitContainers, *spec.InitContainers[idx].DeepCopy())
+	}
+
+	if !IsInitScriptConfigured(&spec.InitScript) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	configMapVolDefaultMode := int32(corev1.ConfigMapVolumeSourceDefaultMode)
+
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-init-script",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: spec.InitScript.ConfigMapRef,
+				},
+				DefaultMode: &configMapVolDefaultMode,
+			},
+		},
+	})
+	podTemplateSpec.Spec.InitContainers = append(podTemplateSpec.Spec.InitContainers, getInitScriptContainer(spec))
+}
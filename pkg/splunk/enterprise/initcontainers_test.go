@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateInitContainers(t *testing.T) {
+	test := func(containers []corev1.Container, wantErr bool) {
+		spec := enterprisev1.CommonSplunkSpec{InitContainers: containers}
+		err := validateInitContainers(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateInitContainers(%v) returned nil; want error", containers)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateInitContainers(%v) returned %v; want nil", containers, err)
+		}
+	}
+
+	seed := corev1.Container{Name: "seed-config", Image: "busybox"}
+	test(nil, false)
+	test([]corev1.Container{seed}, false)
+	test([]corev1.Container{{Name: "seed-config"}}, true)
+	test([]corev1.Container{{Name: "init", Image: "busybox"}}, true)
+	test([]corev1.Container{{Name: "init-script", Image: "busybox"}}, true)
+	test([]corev1.Container{seed, seed}, true)
+
+	// init script defaults are only set when a ConfigMap is referenced
+	spec := enterprisev1.CommonSplunkSpec{}
+	spec.Image = "splunk/splunk:8.0"
+	validateInitContainers(&spec)
+	if spec.InitScript.Key != "" || spec.InitScript.Image != "" {
+		t.Errorf("validateInitContainers() set init script defaults without a ConfigMap: %v", spec.InitScript)
+	}
+	spec.InitScript.ConfigMapRef = "splunk-init"
+	validateInitContainers(&spec)
+	if spec.InitScript.Key != "init.sh" || spec.InitScript.Image != "splunk/splunk:8.0" {
+		t.Errorf("validateInitContainers() key=%s image=%s; want init.sh splunk/splunk:8.0", spec.InitScript.Key, spec.InitScript.Image)
+	}
+}
+
+func TestAddInitContainersToPodTemplate(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.SparkRef.Name = "spark"
+	cr.Spec.InitContainers = []corev1.Container{
+		{
+			Name:         "fix-permissions",
+			Image:        "busybox",
+			Command:      []string{"chown", "-R", "41812:41812", "/opt/splunk/var"},
+			VolumeMounts: []corev1.VolumeMount{{Name: "pvc-var", MountPath: "/opt/splunk/var"}},
+		},
+	}
+	cr.Spec.InitScript.ConfigMapRef = "splunk-init"
+	cr.Spec.InitScript.Key = "seed.sh"
+	if err := ValidateStandaloneSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+
+	ss, err := GetStandaloneStatefulSet(&cr)
+	if err != nil {
+		t.Errorf("GetStandaloneStatefulSet() returned %v; want nil", err)
+		return
+	}
+
+	// user init containers run first, followed by the init script; the spark init container is unchanged
+	initContainers := ss.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 3 || initContainers[0].Name != "fix-permissions" || initContainers[1].Name != "init-script" || initContainers[2].Name != "init" {
+		t.Errorf("GetStandaloneStatefulSet() init containers = %v; want fix-permissions, init-script and init", initContainers)
+		return
+	}
+	if !reflect.DeepEqual(initContainers[0], cr.Spec.InitContainers[0]) {
+		t.Errorf("GetStandaloneStatefulSet() init container = %v; want %v", initContainers[0], cr.Spec.InitContainers[0])
+	}
+
+	script := initContainers[1]
+	wantCommand := []string{"/bin/sh", "/mnt/splunk-init-script/seed.sh"}
+	if script.Image != cr.Spec.Image || !reflect.DeepEqual(script.Command, wantCommand) {
+		t.Errorf("GetStandaloneStatefulSet() init-script image=%s command=%v; want %s %v", script.Image, script.Command, cr.Spec.Image, wantCommand)
+	}
+	wantMounts := []corev1.VolumeMount{
+		{Name: "pvc-etc", MountPath: "/opt/splunk/etc"},
+		{Name: "pvc-var", MountPath: "/opt/splunk/var"},
+		{Name: "mnt-splunk-init-script", MountPath: "/mnt/splunk-init-script", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(script.VolumeMounts, wantMounts) {
+		t.Errorf("GetStandaloneStatefulSet() init-script volume mounts = %v; want %v", script.VolumeMounts, wantMounts)
+	}
+
+	// the init script is only mounted in its own container
+	found := false
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Name == "mnt-splunk-init-script" {
+			found = v.ConfigMap != nil && v.ConfigMap.Name == "splunk-init"
+		}
+	}
+	if !found {
+		t.Errorf("GetStandaloneStatefulSet() volumes = %v; want mnt-splunk-init-script using ConfigMap splunk-init", ss.Spec.Template.Spec.Volumes)
+	}
+	for _, m := range ss.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if m.Name == "mnt-splunk-init-script" {
+			t.Errorf("GetStandaloneStatefulSet() mounted init script in splunk container")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// directory of the var volume where Splunk creates KV store backup archives
+const kvStoreBackupPath = "/opt/splunk/var/lib/splunk/kvstorebackup"
+
+// IsKVStoreBackupEnabled returns true if the KV store is backed up before search head cluster members are upgraded
+func IsKVStoreBackupEnabled(spec *enterprisev1.SearchHeadClusterKVStoreBackupSpec) bool {
+	return spec.Enabled
+}
+
+// validateKVStoreBackupSpec checks validity and makes default updates to a SearchHeadClusterKVStoreBackupSpec, and returns error if something is wrong.
+func validateKVStoreBackupSpec(spec *enterprisev1.SearchHeadClusterKVStoreBackupSpec) error {
+	if !IsKVStoreBackupEnabled(spec) {
+		return nil
+	}
+
+	if spec.Target == "" {
+		spec.Target = KVStoreBackupTargetVolume
+	}
+	switch spec.Target {
+	case KVStoreBackupTargetVolume:
+		return nil
+	case KVStoreBackupTargetS3:
+	default:
+		return fmt.Errorf("KVStoreBackup target must be either \"%s\" or \"%s\"; value=\"%s\"", KVStoreBackupTargetVolume, KVStoreBackupTargetS3, spec.Target)
+	}
+
+	if spec.S3.Bucket == "" {
+		return fmt.Errorf("KVStoreBackup s3 bucket must not be empty for the \"%s\" target", KVStoreBackupTargetS3)
+	}
+	if spec.S3.Endpoint == "" {
+		spec.S3.Endpoint = defaultAppRepoEndpoint
+	}
+	if spec.S3.Region == "" {
+		spec.S3.Region = defaultAppRepoRegion
+	}
+	return nil
+}
+
+// GetKVStoreBackupLocation returns the location of a KV store backup: the path of its archive on the var volume of a pod
+// ("pod:path"), or the URL of the bucket prefix containing the documents of each KV store collection
+func GetKVStoreBackupLocation(spec *enterprisev1.SearchHeadClusterKVStoreBackupSpec, backupName, podName string) string {
+	if spec.Target == KVStoreBackupTargetS3 {
+		return fmt.Sprintf("s3://%s/%s", spec.S3.Bucket, GetKVStoreBackupObjectName(spec, backupName, "", ""))
+	}
+	return fmt.Sprintf("%s:%s/%s.tar.gz", podName, kvStoreBackupPath, backupName)
+}
+
+// GetKVStoreBackupObjectName returns the name of the object used to store the documents of a KV store collection in a
+// backup uploaded to an S3 compatible bucket, or the prefix of all objects in the backup if app is empty
+func GetKVStoreBackupObjectName(spec *enterprisev1.SearchHeadClusterKVStoreBackupSpec, backupName, app, collection string) string {
+	prefix := spec.S3.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if app == "" {
+		return fmt.Sprintf("%s%s/", prefix, backupName)
+	}
+	return fmt.Sprintf("%s%s/%s/%s.json", prefix, backupName, app, collection)
+}
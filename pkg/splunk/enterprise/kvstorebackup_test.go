@@ -0,0 +1,63 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateKVStoreBackupSpec(t *testing.T) {
+	test := func(spec enterprisev1.SearchHeadClusterKVStoreBackupSpec, wantErr bool, want enterprisev1.SearchHeadClusterKVStoreBackupSpec) {
+		err := validateKVStoreBackupSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateKVStoreBackupSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateKVStoreBackupSpec(%v) returned %v; want nil", spec, err)
+		}
+		if !wantErr && spec != want {
+			t.Errorf("validateKVStoreBackupSpec() = %v; want %v", spec, want)
+		}
+	}
+
+	none := enterprisev1.SearchHeadClusterKVStoreBackupSpec{}
+	test(none, false, none)
+	test(enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true}, false, enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: KVStoreBackupTargetVolume})
+	test(enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: "nfs"}, true, none)
+	test(enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: KVStoreBackupTargetS3}, true, none)
+	test(enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: KVStoreBackupTargetS3, S3: enterprisev1.KVStoreBackupS3Spec{Bucket: "backups"}}, false,
+		enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: KVStoreBackupTargetS3, S3: enterprisev1.KVStoreBackupS3Spec{Endpoint: "https://s3.amazonaws.com", Region: "us-east-1", Bucket: "backups"}})
+}
+
+func TestGetKVStoreBackupLocation(t *testing.T) {
+	spec := enterprisev1.SearchHeadClusterKVStoreBackupSpec{Enabled: true, Target: KVStoreBackupTargetVolume}
+	test := func(want string) {
+		got := GetKVStoreBackupLocation(&spec, "splunk-stack1-kvstore-20200512173601", "splunk-stack1-search-head-0")
+		if got != want {
+			t.Errorf("GetKVStoreBackupLocation(%v) = %s; want %s", spec, got, want)
+		}
+	}
+
+	test("splunk-stack1-search-head-0:/opt/splunk/var/lib/splunk/kvstorebackup/splunk-stack1-kvstore-20200512173601.tar.gz")
+	spec.Target = KVStoreBackupTargetS3
+	spec.S3.Bucket = "backups"
+	test("s3://backups/splunk-stack1-kvstore-20200512173601/")
+	spec.S3.Prefix = "kvstore/"
+	test("s3://backups/kvstore/splunk-stack1-kvstore-20200512173601/")
+	if got, want := GetKVStoreBackupObjectName(&spec, "splunk-stack1-kvstore-20200512173601", "search", "lookups"), "kvstore/splunk-stack1-kvstore-20200512173601/search/lookups.json"; got != want {
+		t.Errorf("GetKVStoreBackupObjectName() = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// default license stack used by license pools
+	defaultLicenseStackID = "enterprise"
+
+	// default quota used by license pools, which allows them to use all of the volume available in their stack
+	defaultLicensePoolQuota = "MAX"
+)
+
+// regular expression used to validate license pool quotas
+var licensePoolQuotaRegex = regexp.MustCompile(`^(MAX|[0-9]+(MB|GB)?)$`)
+
+// validateLicensePools checks validity and makes default updates to a list of LicensePoolSpecs, and returns error if something is wrong.
+func validateLicensePools(pools []enterprisev1.LicensePoolSpec) error {
+	names := make(map[string]bool)
+	for i := range pools {
+		pool := &pools[i]
+		if pool.Name == "" {
+			return fmt.Errorf("License pool name must not be empty")
+		}
+		if names[pool.Name] {
+			return fmt.Errorf("License pool names must be unique; value=\"%s\"", pool.Name)
+		}
+		names[pool.Name] = true
+
+		if pool.StackID == "" {
+			pool.StackID = defaultLicenseStackID
+		}
+		if pool.Quota == "" {
+			pool.Quota = defaultLicensePoolQuota
+		}
+		if !licensePoolQuotaRegex.MatchString(pool.Quota) {
+			return fmt.Errorf("License pool quota must be MAX or a number of bytes with an optional MB or GB suffix; pool=\"%s\" value=\"%s\"", pool.Name, pool.Quota)
+		}
+		if len(pool.Slaves) == 0 {
+			pool.Slaves = []string{"*"}
+		}
+	}
+	return nil
+}
+
+// GetLicensePoolQuotaBytes returns the daily indexing volume allowed by a license pool quota, in bytes,
+// where stackQuota is the volume available in the pool's license stack.
+func GetLicensePoolQuotaBytes(quota string, stackQuota int64) int64 {
+	match := licensePoolQuotaRegex.FindStringSubmatch(quota)
+	if match == nil || match[1] == defaultLicensePoolQuota {
+		return stackQuota
+	}
+	multiplier := int64(1)
+	switch match[2] {
+	case "MB":
+		multiplier = 1024 * 1024
+	case "GB":
+		multiplier = 1024 * 1024 * 1024
+	}
+	value, _ := strconv.ParseInt(quota[:len(quota)-len(match[2])], 10, 64)
+	return value * multiplier
+}
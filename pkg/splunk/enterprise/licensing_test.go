@@ -0,0 +1,60 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateLicensePools(t *testing.T) {
+	test := func(pools []enterprisev1.LicensePoolSpec, wantErr bool) {
+		err := validateLicensePools(pools)
+		if wantErr && err == nil {
+			t.Errorf("validateLicensePools(%v) returned nil; want error", pools)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateLicensePools(%v) returned %v; want nil", pools, err)
+		}
+	}
+
+	test([]enterprisev1.LicensePoolSpec{}, false)
+	test([]enterprisev1.LicensePoolSpec{{Name: "pool1", Quota: "500MB"}, {Name: "pool2", Quota: "2GB"}}, false)
+	test([]enterprisev1.LicensePoolSpec{{Name: "pool1", Quota: "1073741824"}}, false)
+	test([]enterprisev1.LicensePoolSpec{{Name: "pool1"}, {Name: "pool1"}}, true)
+	test([]enterprisev1.LicensePoolSpec{{Quota: "MAX"}}, true)
+	test([]enterprisev1.LicensePoolSpec{{Name: "pool1", Quota: "2TB"}}, true)
+	test([]enterprisev1.LicensePoolSpec{{Name: "pool1", Quota: "-1"}}, true)
+
+	// defaults are set for optional fields
+	pools := []enterprisev1.LicensePoolSpec{{Name: "pool1"}}
+	validateLicensePools(pools)
+	if pools[0].StackID != "enterprise" || pools[0].Quota != "MAX" || len(pools[0].Slaves) != 1 || pools[0].Slaves[0] != "*" {
+		t.Errorf("validateLicensePools() defaults = %v; want stack enterprise, quota MAX and slaves *", pools[0])
+	}
+}
+
+func TestGetLicensePoolQuotaBytes(t *testing.T) {
+	test := func(quota string, want int64) {
+		if got := GetLicensePoolQuotaBytes(quota, 10737418240); got != want {
+			t.Errorf("GetLicensePoolQuotaBytes(\"%s\") = %d; want %d", quota, got, want)
+		}
+	}
+
+	test("MAX", 10737418240)
+	test("1024", 1024)
+	test("500MB", 524288000)
+	test("2GB", 2147483648)
+}
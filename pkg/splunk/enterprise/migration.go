@@ -0,0 +1,113 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// IsStandaloneMigrationConfigured returns true if standalone instances are migrated into an indexer cluster
+func IsStandaloneMigrationConfigured(spec *enterprisev1.StandaloneSpec) bool {
+	return spec.Migration.IndexerClusterRef.Name != ""
+}
+
+// validateStandaloneMigration checks that a StandaloneSpec which is migrated into an indexer cluster does not use features
+// that indexer cluster peers cannot use, and returns error if something is wrong.
+func validateStandaloneMigration(spec *enterprisev1.StandaloneSpec) error {
+	if !IsStandaloneMigrationConfigured(spec) {
+		return nil
+	}
+	switch {
+	case spec.ClusterMasterRef.Name != "" || spec.IndexerClusterRef.Name != "":
+		return fmt.Errorf("migration cannot be combined with clusterMasterRef or indexerClusterRef")
+	case spec.SparkRef.Name != "" || spec.SparkMasterURL != "":
+		return fmt.Errorf("migration cannot be combined with sparkRef or sparkMasterUrl")
+	case IsSmartStoreConfigured(&spec.SmartStore):
+		return fmt.Errorf("migration cannot be combined with smartstore, which must be configured on the cluster master")
+	}
+	return nil
+}
+
+// ValidateStandaloneMigrationTarget checks that standalone instances can be converted into peers of an indexer cluster, and
+// returns error if they cannot.
+func ValidateStandaloneMigrationTarget(idxc *enterprisev1.IndexerCluster) error {
+	if IsExternalClusterMasterConfigured(&idxc.Spec.ExternalClusterMaster) {
+		return fmt.Errorf("Unable to migrate into IndexerCluster %s: it joins an external cluster master", idxc.GetName())
+	}
+	if len(idxc.Spec.Sites) > 0 {
+		return fmt.Errorf("Unable to migrate into IndexerCluster %s: multisite indexer clusters are not supported", idxc.GetName())
+	}
+	return nil
+}
+
+// GetIndexerClusterMasterRef returns a reference to the cluster master used by the peers of an indexer cluster, which always
+// includes a namespace, and the instance type of the custom resource that owns the Secret used to access it. This is either a
+// referenced ClusterMaster, or the cluster master that the indexer cluster manages itself.
+func GetIndexerClusterMasterRef(idxc *enterprisev1.IndexerCluster) (corev1.ObjectReference, InstanceType) {
+	if ref := idxc.Spec.ClusterMasterRef; ref.Name != "" {
+		if ref.Namespace == "" {
+			ref.Namespace = idxc.GetNamespace()
+		}
+		return ref, SplunkClusterMaster
+	}
+	return corev1.ObjectReference{Name: idxc.GetName(), Namespace: idxc.GetNamespace()}, SplunkIndexer
+}
+
+// GetStandaloneMigrationSpec returns the CommonSplunkSpec used for standalone instances once they are converted into peers of
+// an indexer cluster. It references the cluster master of the indexer cluster, so that they join it using its idxc.secret.
+func GetStandaloneMigrationSpec(spec *enterprisev1.CommonSplunkSpec, idxc *enterprisev1.IndexerCluster) enterprisev1.CommonSplunkSpec {
+	peerSpec := *spec
+	ref, instanceType := GetIndexerClusterMasterRef(idxc)
+	if instanceType == SplunkClusterMaster {
+		peerSpec.ClusterMasterRef = ref
+	} else {
+		peerSpec.IndexerClusterRef = ref
+	}
+	return peerSpec
+}
+
+// GetStandalonePeerStatefulSet returns a Kubernetes StatefulSet object for Splunk Enterprise standalone instances that are
+// converted into peers of an indexer cluster. It uses the same name, labels and persistent volume claims as the StatefulSet
+// of the standalone instances, so that pods are recycled into peers with their existing data.
+func GetStandalonePeerStatefulSet(cr *enterprisev1.Standalone, idxc *enterprisev1.IndexerCluster) (*appsv1.StatefulSet, error) {
+	spec := GetStandaloneMigrationSpec(&cr.Spec.CommonSplunkSpec, idxc)
+	ss, err := getSplunkStatefulSet(cr, &spec, SplunkStandalone, cr.Spec.Replicas, []corev1.EnvVar{})
+	if err != nil {
+		return nil, err
+	}
+	setSplunkRole(&ss.Spec.Template, SplunkIndexer.ToRole())
+
+	// add sidecar containers last, so that they are not modified by splunk configuration
+	addSidecarsToPodTemplate(&ss.Spec.Template, &cr.Spec.CommonSplunkSpec)
+
+	return ss, nil
+}
+
+// setSplunkRole changes the role that Splunk Enterprise containers of a pod template are provisioned as
+func setSplunkRole(podTemplateSpec *corev1.PodTemplateSpec, role string) {
+	for idx := range podTemplateSpec.Spec.Containers {
+		env := podTemplateSpec.Spec.Containers[idx].Env
+		for n := range env {
+			if env[n].Name == "SPLUNK_ROLE" {
+				env[n].Value = role
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateStandaloneMigration(t *testing.T) {
+	test := func(spec enterprisev1.StandaloneSpec, wantErr bool) {
+		err := ValidateStandaloneSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateStandaloneSpec(%v) returned nil; want error", spec.Migration)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateStandaloneSpec(%v) returned %v; want nil", spec.Migration, err)
+		}
+	}
+
+	migration := enterprisev1.StandaloneMigrationSpec{IndexerClusterRef: corev1.ObjectReference{Name: "idxc"}}
+	test(enterprisev1.StandaloneSpec{}, false)
+	test(enterprisev1.StandaloneSpec{Migration: migration}, false)
+
+	spec := enterprisev1.StandaloneSpec{Migration: migration}
+	spec.IndexerClusterRef.Name = "idxc"
+	test(spec, true)
+
+	spec = enterprisev1.StandaloneSpec{Migration: migration}
+	spec.ClusterMasterRef.Name = "cm"
+	test(spec, true)
+
+	test(enterprisev1.StandaloneSpec{Migration: migration, SparkMasterURL: "spark://spark-master:7077"}, true)
+
+	spec = enterprisev1.StandaloneSpec{Migration: migration}
+	spec.SmartStore.VolList = []enterprisev1.SmartStoreVolumeSpec{{Name: "s3", Path: "bucket/smartstore"}}
+	test(spec, true)
+}
+
+func TestValidateStandaloneMigrationTarget(t *testing.T) {
+	idxc := enterprisev1.IndexerCluster{ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"}}
+	if err := ValidateStandaloneMigrationTarget(&idxc); err != nil {
+		t.Errorf("ValidateStandaloneMigrationTarget() returned %v; want nil", err)
+	}
+
+	multisite := idxc.DeepCopy()
+	multisite.Spec.Sites = []enterprisev1.IndexerClusterSiteSpec{{Name: "site1", Replicas: 3}}
+	if err := ValidateStandaloneMigrationTarget(multisite); err == nil {
+		t.Errorf("ValidateStandaloneMigrationTarget(multisite) returned nil; want error")
+	}
+
+	external := idxc.DeepCopy()
+	external.Spec.ExternalClusterMaster.URL = "https://cm.example.com:8089"
+	if err := ValidateStandaloneMigrationTarget(external); err == nil {
+		t.Errorf("ValidateStandaloneMigrationTarget(external) returned nil; want error")
+	}
+}
+
+func TestGetStandaloneMigrationSpec(t *testing.T) {
+	idxc := enterprisev1.IndexerCluster{ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "central"}}
+	spec := enterprisev1.CommonSplunkSpec{}
+
+	// indexer clusters that manage their own cluster master are referenced directly
+	peerSpec := GetStandaloneMigrationSpec(&spec, &idxc)
+	if peerSpec.IndexerClusterRef.Name != "idxc" || peerSpec.IndexerClusterRef.Namespace != "central" || peerSpec.ClusterMasterRef.Name != "" {
+		t.Errorf("GetStandaloneMigrationSpec() refs = %v, %v; want indexerClusterRef central/idxc", peerSpec.IndexerClusterRef, peerSpec.ClusterMasterRef)
+	}
+
+	// otherwise the referenced cluster master is used, in the namespace of the indexer cluster by default
+	idxc.Spec.ClusterMasterRef.Name = "cm"
+	peerSpec = GetStandaloneMigrationSpec(&spec, &idxc)
+	if peerSpec.ClusterMasterRef.Name != "cm" || peerSpec.ClusterMasterRef.Namespace != "central" || peerSpec.IndexerClusterRef.Name != "" {
+		t.Errorf("GetStandaloneMigrationSpec() refs = %v, %v; want clusterMasterRef central/cm", peerSpec.IndexerClusterRef, peerSpec.ClusterMasterRef)
+	}
+	if spec.ClusterMasterRef.Name != "" {
+		t.Errorf("GetStandaloneMigrationSpec() changed the spec of the standalone instances")
+	}
+}
+
+func TestGetStandalonePeerStatefulSet(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.StandaloneSpec{Replicas: 2},
+	}
+	idxc := enterprisev1.IndexerCluster{ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"}}
+
+	ss, err := GetStandalonePeerStatefulSet(&cr, &idxc)
+	if err != nil {
+		t.Fatalf("GetStandalonePeerStatefulSet() returned %v; want nil", err)
+	}
+	if ss.GetName() != "splunk-stack1-standalone" {
+		t.Errorf("GetStandalonePeerStatefulSet() name = %s; want %s", ss.GetName(), "splunk-stack1-standalone")
+	}
+	want := map[string]string{
+		"SPLUNK_ROLE":               "splunk_indexer",
+		"SPLUNK_CLUSTER_MASTER_URL": "splunk-idxc-cluster-master-service.test.svc.cluster.local",
+	}
+	for _, v := range ss.Spec.Template.Spec.Containers[0].Env {
+		if value, ok := want[v.Name]; ok {
+			if v.Value != value {
+				t.Errorf("GetStandalonePeerStatefulSet() %s = %s; want %s", v.Name, v.Value, value)
+			}
+			delete(want, v.Name)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("GetStandalonePeerStatefulSet() env is missing %v", want)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// default name of the service port scraped by ServiceMonitors
+	defaultMonitoringPort = "splunkd"
+
+	// default HTTP path scraped by ServiceMonitors
+	defaultMonitoringPath = "/metrics"
+)
+
+// ServiceMonitorGroupVersionKind is the type of Prometheus Operator ServiceMonitor resources used to scrape metrics
+var ServiceMonitorGroupVersionKind = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// IsServiceMonitorEnabled returns true if ServiceMonitors should be created for Splunk Enterprise instances
+func IsServiceMonitorEnabled(spec *enterprisev1.MonitoringSpec) bool {
+	return spec.ServiceMonitor
+}
+
+// validateMonitoringSpec checks validity and makes default updates to a MonitoringSpec, and returns error if something is wrong.
+func validateMonitoringSpec(spec *enterprisev1.MonitoringSpec) error {
+	if !IsServiceMonitorEnabled(spec) {
+		return nil
+	}
+
+	if spec.Port == "" {
+		spec.Port = defaultMonitoringPort
+	}
+
+	if spec.Path == "" {
+		spec.Path = defaultMonitoringPath
+	}
+	if !strings.HasPrefix(spec.Path, "/") {
+		return fmt.Errorf("Monitoring path must begin with \"/\"; value=\"%s\"", spec.Path)
+	}
+
+	if spec.Interval != "" {
+		if _, err := time.ParseDuration(spec.Interval); err != nil {
+			return fmt.Errorf("Monitoring interval is invalid; value=\"%s\": %v", spec.Interval, err)
+		}
+	}
+
+	return nil
+}
+
+// GetSplunkServiceMonitor returns a Prometheus Operator ServiceMonitor used to scrape metrics from a type of Splunk instance.
+// Services of the same type share labels, so targets are limited to the one service used to reach the instances.
+func GetSplunkServiceMonitor(cr enterprisev1.MetaObject, spec *enterprisev1.MonitoringSpec, instanceType InstanceType) *unstructured.Unstructured {
+	// standalone instances only have a headless service
+	serviceName := GetSplunkServiceName(instanceType, cr.GetIdentifier(), instanceType == SplunkStandalone)
+
+	// unstructured content must use the same types as decoded JSON, so that it can be compared with existing ServiceMonitors
+	matchLabels := map[string]interface{}{}
+	for k, v := range getSplunkLabelsForCR(cr, instanceType) {
+		matchLabels[k] = v
+	}
+	endpoint := map[string]interface{}{
+		"port":   spec.Port,
+		"path":   spec.Path,
+		"scheme": "https",
+		"tlsConfig": map[string]interface{}{
+			"insecureSkipVerify": true,
+		},
+		"relabelings": []interface{}{
+			map[string]interface{}{
+				"sourceLabels": []interface{}{"__meta_kubernetes_service_name"},
+				"regex":        serviceName,
+				"action":       "keep",
+			},
+		},
+	}
+	if spec.Interval != "" {
+		endpoint["interval"] = spec.Interval
+	}
+
+	labels := getSplunkLabelsForCR(cr, instanceType)
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+
+	serviceMonitor := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": matchLabels,
+			},
+			"endpoints": []interface{}{endpoint},
+		},
+	}}
+	serviceMonitor.SetGroupVersionKind(ServiceMonitorGroupVersionKind)
+	serviceMonitor.SetName(GetSplunkServiceMonitorName(instanceType, cr.GetIdentifier()))
+	serviceMonitor.SetNamespace(cr.GetNamespace())
+	serviceMonitor.SetLabels(labels)
+	serviceMonitor.SetOwnerReferences(append(serviceMonitor.GetOwnerReferences(), resources.AsOwner(cr)))
+	return serviceMonitor
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateMonitoringSpec(t *testing.T) {
+	test := func(spec enterprisev1.MonitoringSpec, wantErr bool) {
+		err := validateMonitoringSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateMonitoringSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateMonitoringSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.MonitoringSpec{}, false)
+	test(enterprisev1.MonitoringSpec{ServiceMonitor: true}, false)
+	test(enterprisev1.MonitoringSpec{ServiceMonitor: true, Port: "metrics", Path: "/metrics", Interval: "30s"}, false)
+	test(enterprisev1.MonitoringSpec{ServiceMonitor: true, Path: "metrics"}, true)
+	test(enterprisev1.MonitoringSpec{ServiceMonitor: true, Interval: "1d"}, true)
+
+	// defaults are only set when ServiceMonitors are enabled
+	spec := enterprisev1.MonitoringSpec{}
+	validateMonitoringSpec(&spec)
+	if spec.Port != "" || spec.Path != "" {
+		t.Errorf("validateMonitoringSpec() set defaults without serviceMonitor: %v", spec)
+	}
+	spec.ServiceMonitor = true
+	validateMonitoringSpec(&spec)
+	if spec.Port != "splunkd" || spec.Path != "/metrics" {
+		t.Errorf("validateMonitoringSpec() port=%s path=%s; want splunkd /metrics", spec.Port, spec.Path)
+	}
+}
+
+func TestGetSplunkServiceMonitor(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.MonitoringSpec{
+		ServiceMonitor: true,
+		Port:           "splunkd",
+		Path:           "/metrics",
+		Interval:       "30s",
+		Labels:         map[string]string{"release": "prometheus"},
+	}
+
+	serviceMonitor := GetSplunkServiceMonitor(&cr, &spec, SplunkDeployer)
+	if serviceMonitor.GetName() != "splunk-stack1-deployer-monitor" || serviceMonitor.GetNamespace() != "test" {
+		t.Errorf("GetSplunkServiceMonitor() name = %s/%s; want %s/%s", serviceMonitor.GetNamespace(), serviceMonitor.GetName(), "test", "splunk-stack1-deployer-monitor")
+	}
+	if serviceMonitor.GetAPIVersion() != "monitoring.coreos.com/v1" || serviceMonitor.GetKind() != "ServiceMonitor" {
+		t.Errorf("GetSplunkServiceMonitor() type = %s %s; want monitoring.coreos.com/v1 ServiceMonitor", serviceMonitor.GetAPIVersion(), serviceMonitor.GetKind())
+	}
+	if serviceMonitor.GetLabels()["release"] != "prometheus" || serviceMonitor.GetLabels()["app.kubernetes.io/component"] != "search-head" {
+		t.Errorf("GetSplunkServiceMonitor() labels = %v; want release=prometheus and splunk labels", serviceMonitor.GetLabels())
+	}
+	if len(serviceMonitor.GetOwnerReferences()) != 1 || serviceMonitor.GetOwnerReferences()[0].Name != "stack1" {
+		t.Errorf("GetSplunkServiceMonitor() ownerReferences = %v; want stack1", serviceMonitor.GetOwnerReferences())
+	}
+
+	smSpec := serviceMonitor.Object["spec"].(map[string]interface{})
+	wantSelector := map[string]interface{}{"matchLabels": map[string]interface{}{}}
+	for k, v := range getSplunkLabels("stack1", SplunkDeployer) {
+		wantSelector["matchLabels"].(map[string]interface{})[k] = v
+	}
+	if !reflect.DeepEqual(smSpec["selector"], wantSelector) {
+		t.Errorf("GetSplunkServiceMonitor() selector = %v; want %v", smSpec["selector"], wantSelector)
+	}
+	endpoints := smSpec["endpoints"].([]interface{})
+	endpoint := endpoints[0].(map[string]interface{})
+	if len(endpoints) != 1 || endpoint["port"] != "splunkd" || endpoint["path"] != "/metrics" || endpoint["interval"] != "30s" || endpoint["scheme"] != "https" {
+		t.Errorf("GetSplunkServiceMonitor() endpoints = %v; want splunkd /metrics every 30s using https", endpoints)
+	}
+	relabeling := endpoint["relabelings"].([]interface{})[0].(map[string]interface{})
+	if relabeling["regex"] != "splunk-stack1-deployer-service" || relabeling["action"] != "keep" {
+		t.Errorf("GetSplunkServiceMonitor() relabelings = %v; want keep splunk-stack1-deployer-service", endpoint["relabelings"])
+	}
+
+	// standalone instances are scraped using their headless service, and interval is omitted if empty
+	standalone := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec.Interval = ""
+	endpoint = GetSplunkServiceMonitor(&standalone, &spec, SplunkStandalone).Object["spec"].(map[string]interface{})["endpoints"].([]interface{})[0].(map[string]interface{})
+	relabeling = endpoint["relabelings"].([]interface{})[0].(map[string]interface{})
+	if _, ok := endpoint["interval"]; ok || relabeling["regex"] != "splunk-stack1-standalone-headless" {
+		t.Errorf("GetSplunkServiceMonitor(SplunkStandalone) endpoint = %v; want no interval and splunk-stack1-standalone-headless", endpoint)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 )
@@ -32,18 +33,111 @@ const (
 	// identifier, instanceType, index (ex: 0, 1, 2, ...)
 	statefulSetPodTemplateStr = "splunk-%s-%s-%d"
 
-	// identifier, instanceType, "headless" or "service"
+	// identifier, instanceType, "headless", "service" or a port group (ex: web, hec, management)
 	serviceTemplateStr = "splunk-%s-%s-%s"
 
+	// identifier, site (ex: site1, site2, ...)
+	siteIdentifierTemplateStr = "%s-%s"
+
+	// label used to identify the site of indexer cluster peers in a multisite indexer cluster
+	siteLabelKey = "enterprise.splunk.com/site"
+
+	// identifier, color (ex: green)
+	colorIdentifierTemplateStr = "%s-%s"
+
 	// identifier
 	secretsTemplateStr = "splunk-%s-%s-secrets"
 
 	// identifier
 	defaultsTemplateStr = "splunk-%s-%s-defaults"
 
+	// identifier
+	smartStoreTemplateStr = "splunk-%s-%s-smartstore"
+
+	// identifier
+	appsTemplateStr = "splunk-%s-%s-apps"
+
+	// identifier
+	confFilesTemplateStr = "splunk-%s-%s-conf-files"
+
+	// identifier
+	certificateTemplateStr = "splunk-%s-%s-certificate"
+
+	// identifier
+	tlsTemplateStr = "splunk-%s-%s-tls"
+
+	// identifier
+	ingressTemplateStr = "splunk-%s-%s-ingress"
+
+	// identifier
+	networkPolicyTemplateStr = "splunk-%s-%s-network-policy"
+
+	// identifier, instanceType (ex: cluster-master, indexer)
+	indexerDiscoveryServiceTemplateStr = "splunk-%s-%s-discovery"
+
+	// identifier, instanceType (ex: cluster-master, indexer)
+	indexerDiscoveryTemplateStr = "splunk-%s-%s-discovery-config"
+
+	// identifier
+	outputsTemplateStr = "splunk-%s-%s-outputs"
+
+	// identifier, instanceType (ex: deployment-server)
+	externalServiceTemplateStr = "splunk-%s-%s-external"
+
+	// pod name (ex: splunk-stack1-indexer-0)
+	podServiceTemplateStr = "%s-external"
+
+	// identifier, instanceType (ex: standalone, indexers, etc...)
+	serviceMonitorTemplateStr = "splunk-%s-%s-monitor"
+
+	// identifier, start time (ex: 20200512173601)
+	backupTemplateStr = "%s-%s"
+
+	// backup name, persistent volume claim name
+	volumeSnapshotTemplateStr = "%s-%s"
+
+	// backup name
+	backupSecretsTemplateStr = "%s-secrets"
+
+	// identifier, start time (ex: 20200512173601)
+	kvStoreBackupTemplateStr = "splunk-%s-kvstore-%s"
+
+	// identifier
+	hecTokenSecretTemplateStr = "splunk-%s-hec-token"
+
 	// default docker image used for Splunk instances
 	defaultSplunkImage = "splunk/splunk"
 
+	// default docker image used for Splunk Universal Forwarders
+	defaultSplunkForwarderImage = "splunk/universalforwarder"
+
+	// default storage capacity requested for /opt/splunk/etc volume claims
+	defaultEtcStorage = "10Gi"
+
+	// default storage capacity requested for /opt/splunk/var volume claims
+	defaultVarStorage = "100Gi"
+
+	// default number of indexer cluster peers, used when replicas is not specified for an existing indexer cluster
+	defaultIndexerClusterReplicas = 1
+
+	// default number of indexer cluster peers, used when replicas is not specified for a new indexer cluster
+	defaultNewIndexerClusterReplicas = 3
+
+	// default number of pods of a StatefulSet that may be unavailable during voluntary disruptions
+	defaultMaxUnavailable = 1
+
+	// default number of seconds that pods are given to stop gracefully
+	defaultTerminationGracePeriodSeconds = 300
+
+	// default number of seconds that indexer cluster peers are given to go offline gracefully
+	defaultIndexerTerminationGracePeriodSeconds = 900
+
+	// default port used by externally managed spark masters
+	defaultSparkMasterPort = "7077"
+
+	// default port used by the web UI of externally managed spark masters
+	defaultSparkMasterWebUIPort = 8080
+
 	// bytes used to generate random hexidecimal strings (e.g. HEC tokens)
 	hexBytes = "ABCDEF01234567890"
 
@@ -51,6 +145,52 @@ const (
 	secretBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 )
 
+const (
+	// PVCCleanupPolicyRetain keeps the persistent volume claims of a resource after it is deleted
+	PVCCleanupPolicyRetain = "Retain"
+
+	// PVCCleanupPolicyDelete deletes the persistent volume claims of a resource when it is deleted
+	PVCCleanupPolicyDelete = "Delete"
+)
+
+const (
+	// ConfigUpdateStrategyRestart restarts pods to apply changes to configuration
+	ConfigUpdateStrategyRestart = "restart"
+
+	// ConfigUpdateStrategyReload reloads changes to configuration using the REST API where Splunk supports it, instead of
+	// restarting pods
+	ConfigUpdateStrategyReload = "reload"
+)
+
+const (
+	// RollingRestartModeRestart recycles indexer cluster peer pods one at a time when a rolling restart is requested
+	RollingRestartModeRestart = "restart"
+
+	// RollingRestartModeSearchable uses a searchable rolling restart of the cluster master to restart indexer cluster
+	// peers when a rolling restart is requested
+	RollingRestartModeSearchable = "searchable"
+)
+
+const (
+	// DetentionRemediationNone only reports indexer cluster peers that are in automatic detention
+	DetentionRemediationNone = "None"
+
+	// DetentionRemediationRestart restarts Splunk on indexer cluster peers that remain in automatic detention
+	DetentionRemediationRestart = "Restart"
+
+	// DetentionRemediationRebalance requests a data rebalance from the cluster master while indexer cluster peers remain in
+	// automatic detention
+	DetentionRemediationRebalance = "Rebalance"
+)
+
+const (
+	// KVStoreBackupTargetVolume creates KV store backup archives on the var volume of a search head cluster member
+	KVStoreBackupTargetVolume = "volume"
+
+	// KVStoreBackupTargetS3 uploads the documents of every KV store collection to an S3 compatible bucket
+	KVStoreBackupTargetS3 = "s3"
+)
+
 // GetSplunkDeploymentName uses a template to name a Kubernetes Deployment for Splunk instances.
 func GetSplunkDeploymentName(instanceType InstanceType, identifier string) string {
 	return fmt.Sprintf(deploymentTemplateStr, identifier, instanceType)
@@ -79,6 +219,16 @@ func GetSplunkServiceName(instanceType InstanceType, identifier string, isHeadle
 	return result
 }
 
+// GetSplunkPortGroupServiceName uses a template to name a Kubernetes Service used to expose a group of ports (ex: web, hec, management) of Splunk instances.
+func GetSplunkPortGroupServiceName(instanceType InstanceType, identifier string, group string) string {
+	return fmt.Sprintf(serviceTemplateStr, identifier, instanceType, group)
+}
+
+// GetSplunkSiteIdentifier uses a template to build the identifier used for resources of a specific site within a multisite indexer cluster.
+func GetSplunkSiteIdentifier(identifier string, site string) string {
+	return fmt.Sprintf(siteIdentifierTemplateStr, identifier, site)
+}
+
 // GetSplunkSecretsName uses a template to name a Kubernetes Secret for a SplunkEnterprise resource.
 func GetSplunkSecretsName(identifier string, instanceType InstanceType) string {
 	return fmt.Sprintf(secretsTemplateStr, identifier, instanceType.ToKind())
@@ -89,6 +239,96 @@ func GetSplunkDefaultsName(identifier string, instanceType InstanceType) string
 	return fmt.Sprintf(defaultsTemplateStr, identifier, instanceType.ToKind())
 }
 
+// GetSplunkSmartStoreName uses a template to name a Kubernetes Secret for the SmartStore configuration of a SplunkEnterprise resource.
+func GetSplunkSmartStoreName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(smartStoreTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkAppsName uses a template to name a Kubernetes Secret for the app repository configuration of a SplunkEnterprise resource.
+func GetSplunkAppsName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(appsTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkConfFilesName uses a template to name a Kubernetes Secret for the conf files of a SplunkEnterprise resource.
+func GetSplunkConfFilesName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(confFilesTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkCertificateName uses a template to name a cert-manager Certificate, and the Kubernetes Secret it is issued to, for a SplunkEnterprise resource.
+func GetSplunkCertificateName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(certificateTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkTLSName uses a template to name a Kubernetes Secret for the TLS configuration of a SplunkEnterprise resource.
+func GetSplunkTLSName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(tlsTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkIngressName uses a template to name a Kubernetes Ingress for a SplunkEnterprise resource.
+func GetSplunkIngressName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(ingressTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkNetworkPolicyName uses a template to name a Kubernetes NetworkPolicy for a SplunkEnterprise resource.
+func GetSplunkNetworkPolicyName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(networkPolicyTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkIndexerDiscoveryServiceName uses a template to name an external Kubernetes Service used by forwarders for indexer discovery.
+func GetSplunkIndexerDiscoveryServiceName(instanceType InstanceType, identifier string) string {
+	return fmt.Sprintf(indexerDiscoveryServiceTemplateStr, identifier, instanceType)
+}
+
+// GetSplunkIndexerDiscoveryName uses a template to name a Kubernetes Secret for the indexer discovery configuration of Splunk instances.
+func GetSplunkIndexerDiscoveryName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(indexerDiscoveryTemplateStr, identifier, instanceType)
+}
+
+// GetSplunkOutputsName uses a template to name a Kubernetes Secret for the generated outputs configuration of a UniversalForwarder resource.
+func GetSplunkOutputsName(identifier string, instanceType InstanceType) string {
+	return fmt.Sprintf(outputsTemplateStr, identifier, instanceType.ToKind())
+}
+
+// GetSplunkExternalServiceName uses a template to name an external Kubernetes Service used to reach Splunk instances from outside of the Kubernetes cluster.
+func GetSplunkExternalServiceName(instanceType InstanceType, identifier string) string {
+	return fmt.Sprintf(externalServiceTemplateStr, identifier, instanceType)
+}
+
+// GetSplunkPodServiceName uses a template to name an external Kubernetes Service used to reach a single pod from outside of the Kubernetes cluster.
+func GetSplunkPodServiceName(podName string) string {
+	return fmt.Sprintf(podServiceTemplateStr, podName)
+}
+
+// GetSplunkServiceMonitorName uses a template to name a Prometheus Operator ServiceMonitor for Splunk instances.
+func GetSplunkServiceMonitorName(instanceType InstanceType, identifier string) string {
+	return fmt.Sprintf(serviceMonitorTemplateStr, identifier, instanceType)
+}
+
+// GetSplunkBackupName uses a template to name a backup of a SplunkBackup resource, using the time it was started.
+func GetSplunkBackupName(identifier string, startTime time.Time) string {
+	return fmt.Sprintf(backupTemplateStr, identifier, startTime.UTC().Format("20060102150405"))
+}
+
+// GetKVStoreBackupName uses a template to name a KV store backup taken before upgrading a search head cluster, using the time it was started.
+func GetKVStoreBackupName(identifier string, startTime time.Time) string {
+	return fmt.Sprintf(kvStoreBackupTemplateStr, identifier, startTime.UTC().Format("20060102150405"))
+}
+
+// GetVolumeSnapshotName uses a template to name a VolumeSnapshot of a persistent volume claim taken for a backup.
+func GetVolumeSnapshotName(backupName string, pvcName string) string {
+	return fmt.Sprintf(volumeSnapshotTemplateStr, backupName, pvcName)
+}
+
+// GetSplunkBackupSecretsName uses a template to name a Kubernetes Secret used to keep a copy of the secrets used by a backup.
+func GetSplunkBackupSecretsName(backupName string) string {
+	return fmt.Sprintf(backupSecretsTemplateStr, backupName)
+}
+
+// GetSplunkHecTokenSecretName uses a template to name a Kubernetes Secret containing the value of a HecToken resource, for use by applications.
+func GetSplunkHecTokenSecretName(identifier string) string {
+	return fmt.Sprintf(hecTokenSecretTemplateStr, identifier)
+}
+
 // GetSplunkStatefulsetUrls returns a list of fully qualified domain names for all pods within a Splunk StatefulSet.
 func GetSplunkStatefulsetUrls(namespace string, instanceType InstanceType, identifier string, replicas int32, hostnameOnly bool) string {
 	urls := make([]string, replicas)
@@ -114,6 +354,14 @@ func GetSplunkStatefulsetURL(namespace string, instanceType InstanceType, identi
 		))
 }
 
+// GetStorageClassName returns the name of the StorageClass to use for Splunk volume claims.
+func GetStorageClassName(specStorageClassName string) string {
+	if specStorageClassName != "" {
+		return specStorageClassName
+	}
+	return os.Getenv("DEFAULT_STORAGE_CLASS_NAME")
+}
+
 // GetSplunkImage returns the docker image to use for Splunk instances.
 func GetSplunkImage(specImage string) string {
 	var name string
@@ -129,3 +377,19 @@ func GetSplunkImage(specImage string) string {
 
 	return name
 }
+
+// GetSplunkForwarderImage returns the docker image to use for Splunk Universal Forwarders.
+func GetSplunkForwarderImage(specImage string) string {
+	var name string
+
+	if specImage != "" {
+		name = specImage
+	} else {
+		name = os.Getenv("RELATED_IMAGE_SPLUNK_UNIVERSALFORWARDER")
+		if name == "" {
+			name = defaultSplunkForwarderImage
+		}
+	}
+
+	return name
+}
@@ -17,6 +17,7 @@ package enterprise
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetSplunkDeploymentName(t *testing.T) {
@@ -56,6 +57,14 @@ func TestGetSplunkServiceName(t *testing.T) {
 	test("splunk-t2-search-head-service", SplunkSearchHead, "t2", false)
 }
 
+func TestGetSplunkSiteIdentifier(t *testing.T) {
+	got := GetSplunkSiteIdentifier("t4", "site2")
+	want := "t4-site2"
+	if got != want {
+		t.Errorf("GetSplunkSiteIdentifier(\"%s\",\"%s\") = %s; want %s", "t4", "site2", got, want)
+	}
+}
+
 func TestGetSplunkSecretsName(t *testing.T) {
 	got := GetSplunkSecretsName("pw", SplunkIndexer)
 	want := "splunk-pw-indexer-secrets"
@@ -72,6 +81,160 @@ func TestGetSplunkDefaultsName(t *testing.T) {
 	}
 }
 
+func TestGetSplunkSmartStoreName(t *testing.T) {
+	got := GetSplunkSmartStoreName("t1", SplunkClusterMaster)
+	want := "splunk-t1-indexer-smartstore"
+	if got != want {
+		t.Errorf("GetSplunkSmartStoreName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkClusterMaster, got, want)
+	}
+}
+
+func TestGetSplunkAppsName(t *testing.T) {
+	got := GetSplunkAppsName("t1", SplunkDeployer)
+	want := "splunk-t1-search-head-apps"
+	if got != want {
+		t.Errorf("GetSplunkAppsName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkDeployer, got, want)
+	}
+}
+
+func TestGetSplunkConfFilesName(t *testing.T) {
+	got := GetSplunkConfFilesName("t1", SplunkClusterMaster)
+	want := "splunk-t1-indexer-conf-files"
+	if got != want {
+		t.Errorf("GetSplunkConfFilesName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkClusterMaster, got, want)
+	}
+}
+
+func TestGetSplunkCertificateName(t *testing.T) {
+	got := GetSplunkCertificateName("t1", SplunkClusterMaster)
+	want := "splunk-t1-indexer-certificate"
+	if got != want {
+		t.Errorf("GetSplunkCertificateName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkClusterMaster, got, want)
+	}
+}
+
+func TestGetSplunkTLSName(t *testing.T) {
+	got := GetSplunkTLSName("t1", SplunkStandalone)
+	want := "splunk-t1-standalone-tls"
+	if got != want {
+		t.Errorf("GetSplunkTLSName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkStandalone, got, want)
+	}
+}
+
+func TestGetSplunkIngressName(t *testing.T) {
+	got := GetSplunkIngressName("t1", SplunkSearchHead)
+	want := "splunk-t1-search-head-ingress"
+	if got != want {
+		t.Errorf("GetSplunkIngressName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkSearchHead, got, want)
+	}
+}
+
+func TestGetSplunkNetworkPolicyName(t *testing.T) {
+	got := GetSplunkNetworkPolicyName("t1", SplunkClusterMaster)
+	want := "splunk-t1-indexer-network-policy"
+	if got != want {
+		t.Errorf("GetSplunkNetworkPolicyName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkClusterMaster, got, want)
+	}
+}
+
+func TestGetSplunkIndexerDiscoveryServiceName(t *testing.T) {
+	got := GetSplunkIndexerDiscoveryServiceName(SplunkClusterMaster, "t1")
+	want := "splunk-t1-cluster-master-discovery"
+	if got != want {
+		t.Errorf("GetSplunkIndexerDiscoveryServiceName(\"%s\",\"%s\") = %s; want %s", SplunkClusterMaster, "t1", got, want)
+	}
+}
+
+func TestGetSplunkIndexerDiscoveryName(t *testing.T) {
+	got := GetSplunkIndexerDiscoveryName("t1", SplunkIndexer)
+	want := "splunk-t1-indexer-discovery-config"
+	if got != want {
+		t.Errorf("GetSplunkIndexerDiscoveryName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkIndexer, got, want)
+	}
+}
+
+func TestGetSplunkOutputsName(t *testing.T) {
+	got := GetSplunkOutputsName("t1", SplunkUniversalForwarder)
+	want := "splunk-t1-universal-forwarder-outputs"
+	if got != want {
+		t.Errorf("GetSplunkOutputsName(\"%s\",\"%s\") = %s; want %s", "t1", SplunkUniversalForwarder, got, want)
+	}
+}
+
+func TestGetSplunkExternalServiceName(t *testing.T) {
+	got := GetSplunkExternalServiceName(SplunkDeploymentServer, "t1")
+	want := "splunk-t1-deployment-server-external"
+	if got != want {
+		t.Errorf("GetSplunkExternalServiceName(\"%s\",\"%s\") = %s; want %s", SplunkDeploymentServer, "t1", got, want)
+	}
+}
+
+func TestGetSplunkPortGroupServiceName(t *testing.T) {
+	got := GetSplunkPortGroupServiceName(SplunkStandalone, "t1", "hec")
+	want := "splunk-t1-standalone-hec"
+	if got != want {
+		t.Errorf("GetSplunkPortGroupServiceName(\"%s\",\"%s\",\"%s\") = %s; want %s", SplunkStandalone, "t1", "hec", got, want)
+	}
+}
+
+func TestGetSplunkPodServiceName(t *testing.T) {
+	got := GetSplunkPodServiceName("splunk-t1-indexer-0")
+	want := "splunk-t1-indexer-0-external"
+	if got != want {
+		t.Errorf("GetSplunkPodServiceName(\"%s\") = %s; want %s", "splunk-t1-indexer-0", got, want)
+	}
+}
+
+func TestGetSplunkServiceMonitorName(t *testing.T) {
+	got := GetSplunkServiceMonitorName(SplunkDeployer, "t1")
+	want := "splunk-t1-deployer-monitor"
+	if got != want {
+		t.Errorf("GetSplunkServiceMonitorName(\"%s\",\"%s\") = %s; want %s", SplunkDeployer, "t1", got, want)
+	}
+}
+
+func TestGetSplunkBackupName(t *testing.T) {
+	startTime := time.Date(2020, 5, 12, 17, 36, 1, 0, time.UTC)
+	got := GetSplunkBackupName("daily", startTime)
+	want := "daily-20200512173601"
+	if got != want {
+		t.Errorf("GetSplunkBackupName(\"%s\",%s) = %s; want %s", "daily", startTime, got, want)
+	}
+}
+
+func TestGetKVStoreBackupName(t *testing.T) {
+	startTime := time.Date(2020, 5, 12, 17, 36, 1, 0, time.UTC)
+	got := GetKVStoreBackupName("stack1", startTime)
+	want := "splunk-stack1-kvstore-20200512173601"
+	if got != want {
+		t.Errorf("GetKVStoreBackupName(\"%s\",%s) = %s; want %s", "stack1", startTime, got, want)
+	}
+}
+
+func TestGetVolumeSnapshotName(t *testing.T) {
+	got := GetVolumeSnapshotName("daily-20200512173601", "pvc-etc-splunk-t1-standalone-0")
+	want := "daily-20200512173601-pvc-etc-splunk-t1-standalone-0"
+	if got != want {
+		t.Errorf("GetVolumeSnapshotName(\"%s\",\"%s\") = %s; want %s", "daily-20200512173601", "pvc-etc-splunk-t1-standalone-0", got, want)
+	}
+}
+
+func TestGetSplunkBackupSecretsName(t *testing.T) {
+	got := GetSplunkBackupSecretsName("daily-20200512173601")
+	want := "daily-20200512173601-secrets"
+	if got != want {
+		t.Errorf("GetSplunkBackupSecretsName(\"%s\") = %s; want %s", "daily-20200512173601", got, want)
+	}
+}
+
+func TestGetSplunkHecTokenSecretName(t *testing.T) {
+	got := GetSplunkHecTokenSecretName("app1")
+	want := "splunk-app1-hec-token"
+	if got != want {
+		t.Errorf("GetSplunkHecTokenSecretName(\"%s\") = %s; want %s", "app1", got, want)
+	}
+}
+
 func TestGetSplunkStatefulsetUrls(t *testing.T) {
 	test := func(want string, namespace string, instanceType InstanceType, identifier string, replicas int32, hostnameOnly bool) {
 		got := GetSplunkStatefulsetUrls(namespace, instanceType, identifier, replicas, hostnameOnly)
@@ -105,3 +268,43 @@ func TestGetSplunkImage(t *testing.T) {
 	specImage = "splunk/splunk-test"
 	test("splunk/splunk-test")
 }
+
+func TestGetSplunkForwarderImage(t *testing.T) {
+	var specImage string
+
+	test := func(want string) {
+		got := GetSplunkForwarderImage(specImage)
+		if got != want {
+			t.Errorf("GetSplunkForwarderImage() = %s; want %s", got, want)
+		}
+	}
+
+	test("splunk/universalforwarder")
+
+	os.Setenv("RELATED_IMAGE_SPLUNK_UNIVERSALFORWARDER", "splunk-test/universalforwarder")
+	defer os.Unsetenv("RELATED_IMAGE_SPLUNK_UNIVERSALFORWARDER")
+	test("splunk-test/universalforwarder")
+
+	specImage = "splunk/universalforwarder-test"
+	test("splunk/universalforwarder-test")
+}
+
+func TestGetStorageClassName(t *testing.T) {
+	var specStorageClassName string
+
+	test := func(want string) {
+		got := GetStorageClassName(specStorageClassName)
+		if got != want {
+			t.Errorf("GetStorageClassName() = %s; want %s", got, want)
+		}
+	}
+
+	test("")
+
+	os.Setenv("DEFAULT_STORAGE_CLASS_NAME", "fast")
+	defer os.Unsetenv("DEFAULT_STORAGE_CLASS_NAME")
+	test("fast")
+
+	specStorageClassName = "slow"
+	test("slow")
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// label used to select the pods of the operator (see deploy/operator.yaml)
+	operatorPodLabelKey   = "name"
+	operatorPodLabelValue = "splunk-operator"
+
+	// label used to select namespaces by name (set automatically by Kubernetes 1.21 and later)
+	namespaceNameLabelKey = "kubernetes.io/metadata.name"
+)
+
+// IsNetworkPolicyEnabled returns true if a NetworkPolicy has been enabled for the instances of a resource
+func IsNetworkPolicyEnabled(spec *enterprisev1.NetworkPolicySpec) bool {
+	return spec.Enabled
+}
+
+// validateNetworkPolicySpec checks validity of a NetworkPolicySpec, and returns error if something is wrong.
+func validateNetworkPolicySpec(spec *enterprisev1.NetworkPolicySpec) error {
+	if !IsNetworkPolicyEnabled(spec) {
+		return nil
+	}
+	for _, cidr := range spec.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("NetworkPolicy allowedCidrs must contain CIDR blocks such as \"10.0.0.0/16\"; value=\"%s\"", cidr)
+		}
+	}
+	for _, namespace := range spec.AllowedNamespaces {
+		if namespace == "" {
+			return fmt.Errorf("NetworkPolicy allowedNamespaces must not contain empty names")
+		}
+	}
+	return nil
+}
+
+// getNetworkPolicyPorts returns the Splunk ports of an instance type that are allowed by its NetworkPolicy
+func getNetworkPolicyPorts(instanceType InstanceType) []networkingv1.NetworkPolicyPort {
+	var values []int
+	for _, port := range getSplunkPorts(instanceType) {
+		values = append(values, port)
+	}
+	sort.Ints(values) // note that port order is important for tests
+
+	protocol := corev1.ProtocolTCP
+	ports := []networkingv1.NetworkPolicyPort{}
+	for _, value := range values {
+		port := intstr.FromInt(value)
+		ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+	}
+	return ports
+}
+
+// GetSplunkNetworkPolicy returns a Kubernetes NetworkPolicy for the instances of a SplunkEnterprise resource. Pods are selected
+// using the part-of label of the instance type, which includes all sites of indexer clusters, the cluster master of indexer
+// clusters, the deployer of search head clusters and replacement members used by blue/green upgrades. Their Splunk ports may
+// only be reached by other pods managed by the operator in the same namespace, the operator itself, and the CIDR blocks and
+// namespaces that are allowed by spec.
+func GetSplunkNetworkPolicy(cr enterprisev1.MetaObject, spec *enterprisev1.NetworkPolicySpec, instanceType InstanceType) *networkingv1.NetworkPolicy {
+	labels := getSplunkLabelsForCR(cr, instanceType)
+	selector := map[string]string{
+		"app.kubernetes.io/managed-by": labels["app.kubernetes.io/managed-by"],
+		"app.kubernetes.io/part-of":    labels["app.kubernetes.io/part-of"],
+	}
+
+	peers := []networkingv1.NetworkPolicyPeer{
+		{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app.kubernetes.io/managed-by": labels["app.kubernetes.io/managed-by"]},
+			},
+		},
+		{
+			NamespaceSelector: &metav1.LabelSelector{},
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{operatorPodLabelKey: operatorPodLabelValue},
+			},
+		},
+	}
+	for _, cidr := range spec.AllowedCIDRs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	for _, namespace := range spec.AllowedNamespaces {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabelKey: namespace},
+			},
+		})
+	}
+
+	networkPolicy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "NetworkPolicy",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkNetworkPolicyName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: getNetworkPolicyPorts(instanceType),
+					From:  peers,
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(networkPolicy.ObjectMeta.GetObjectMeta(), cr.GetObjectMeta())
+
+	networkPolicy.SetOwnerReferences(append(networkPolicy.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return networkPolicy
+}
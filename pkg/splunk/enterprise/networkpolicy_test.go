@@ -0,0 +1,103 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateNetworkPolicySpec(t *testing.T) {
+	test := func(spec enterprisev1.NetworkPolicySpec, wantErr bool) {
+		err := validateNetworkPolicySpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateNetworkPolicySpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateNetworkPolicySpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.NetworkPolicySpec{}, false)
+	test(enterprisev1.NetworkPolicySpec{AllowedCIDRs: []string{"invalid"}}, false)
+	test(enterprisev1.NetworkPolicySpec{Enabled: true}, false)
+	test(enterprisev1.NetworkPolicySpec{Enabled: true, AllowedCIDRs: []string{"10.0.0.0/16", "192.168.1.10/32"}, AllowedNamespaces: []string{"ingress-nginx"}}, false)
+	test(enterprisev1.NetworkPolicySpec{Enabled: true, AllowedCIDRs: []string{"10.0.0.1"}}, true)
+	test(enterprisev1.NetworkPolicySpec{Enabled: true, AllowedNamespaces: []string{""}}, true)
+}
+
+func TestGetSplunkNetworkPolicy(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.NetworkPolicySpec{
+		Enabled:           true,
+		AllowedCIDRs:      []string{"10.0.0.0/16"},
+		AllowedNamespaces: []string{"monitoring"},
+	}
+
+	networkPolicy := GetSplunkNetworkPolicy(&cr, &spec, SplunkIndexer)
+	if networkPolicy.GetName() != "splunk-stack1-indexer-network-policy" {
+		t.Errorf("GetSplunkNetworkPolicy() name = %s; want %s", networkPolicy.GetName(), "splunk-stack1-indexer-network-policy")
+	}
+	wantSelector := map[string]string{
+		"app.kubernetes.io/managed-by": "splunk-operator",
+		"app.kubernetes.io/part-of":    "splunk-stack1-indexer",
+	}
+	if !reflect.DeepEqual(networkPolicy.Spec.PodSelector.MatchLabels, wantSelector) {
+		t.Errorf("GetSplunkNetworkPolicy() podSelector = %v; want %v", networkPolicy.Spec.PodSelector.MatchLabels, wantSelector)
+	}
+	if len(networkPolicy.Spec.Ingress) != 1 {
+		t.Fatalf("GetSplunkNetworkPolicy() ingress = %v; want 1 rule", networkPolicy.Spec.Ingress)
+	}
+	rule := networkPolicy.Spec.Ingress[0]
+	var ports []int
+	for _, port := range rule.Ports {
+		ports = append(ports, port.Port.IntValue())
+	}
+	if want := []int{8000, 8088, 8089, 9997}; !reflect.DeepEqual(ports, want) {
+		t.Errorf("GetSplunkNetworkPolicy() ports = %v; want %v", ports, want)
+	}
+	if len(rule.From) != 4 {
+		t.Fatalf("GetSplunkNetworkPolicy() from = %v; want 4 peers", rule.From)
+	}
+	if rule.From[1].NamespaceSelector == nil || rule.From[1].PodSelector.MatchLabels["name"] != "splunk-operator" {
+		t.Errorf("GetSplunkNetworkPolicy() from[1] = %v; want operator pods in any namespace", rule.From[1])
+	}
+	if rule.From[2].IPBlock == nil || rule.From[2].IPBlock.CIDR != "10.0.0.0/16" {
+		t.Errorf("GetSplunkNetworkPolicy() from[2] = %v; want ipBlock 10.0.0.0/16", rule.From[2])
+	}
+	if rule.From[3].NamespaceSelector == nil || rule.From[3].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"] != "monitoring" {
+		t.Errorf("GetSplunkNetworkPolicy() from[3] = %v; want namespace monitoring", rule.From[3])
+	}
+	if len(networkPolicy.GetOwnerReferences()) != 1 {
+		t.Errorf("GetSplunkNetworkPolicy() owner references = %v; want 1", networkPolicy.GetOwnerReferences())
+	}
+
+	// universal forwarders are only reached using splunkd
+	networkPolicy = GetSplunkNetworkPolicy(&cr, &enterprisev1.NetworkPolicySpec{Enabled: true}, SplunkUniversalForwarder)
+	if len(networkPolicy.Spec.Ingress[0].Ports) != 1 || networkPolicy.Spec.Ingress[0].Ports[0].Port.IntValue() != 8089 {
+		t.Errorf("GetSplunkNetworkPolicy() ports = %v; want 8089", networkPolicy.Spec.Ingress[0].Ports)
+	}
+	if len(networkPolicy.Spec.Ingress[0].From) != 2 {
+		t.Errorf("GetSplunkNetworkPolicy() from = %v; want 2 peers", networkPolicy.Spec.Ingress[0].From)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// OrphanPolicyIgnore disables the detection of orphaned resources
+	OrphanPolicyIgnore = "Ignore"
+
+	// OrphanPolicyReport reports orphaned resources using events and metrics, without deleting them
+	OrphanPolicyReport = "Report"
+
+	// OrphanPolicyDelete deletes orphaned resources, except for persistent volume claims, which are only reported
+	OrphanPolicyDelete = "Delete"
+
+	// OrphanPolicyDeleteWithVolumes deletes all orphaned resources, including persistent volume claims
+	OrphanPolicyDeleteWithVolumes = "DeleteWithVolumes"
+)
+
+// componentKinds are the kinds of custom resources that create resources labeled with each component
+var componentKinds = map[string][]string{
+	SplunkStandalone.ToKind():         {"Standalone"},
+	SplunkIndexer.ToKind():            {"IndexerCluster"},
+	SplunkClusterMaster.ToString():    {"ClusterMaster"},
+	SplunkSearchHead.ToKind():         {"SearchHeadCluster"},
+	SplunkLicenseMaster.ToKind():      {"LicenseMaster"},
+	SplunkMonitoringConsole.ToKind():  {"MonitoringConsole"},
+	SplunkUniversalForwarder.ToKind(): {"UniversalForwarder"},
+	SplunkDeploymentServer.ToKind():   {"DeploymentServer"},
+	"spark":                           {"Spark"},
+}
+
+// ValidateOrphanPolicy returns an error if policy is not a supported policy for orphaned resources
+func ValidateOrphanPolicy(policy string) error {
+	switch policy {
+	case OrphanPolicyIgnore, OrphanPolicyReport, OrphanPolicyDelete, OrphanPolicyDeleteWithVolumes:
+		return nil
+	}
+	return fmt.Errorf("orphan policy must be one of \"%s\", \"%s\", \"%s\" or \"%s\"; value=\"%s\"",
+		OrphanPolicyIgnore, OrphanPolicyReport, OrphanPolicyDelete, OrphanPolicyDeleteWithVolumes, policy)
+}
+
+// GetLabeledOwners returns the kinds and possible names of the custom resources that may own a resource created by the
+// operator, using its part-of and component labels. Resources of a site or color are labeled using an identifier with
+// a suffix, so every prefix of the identifier ending before a "-" is a possible name, starting with the identifier
+// itself. Nothing is returned if the labels do not identify a custom resource.
+func GetLabeledOwners(labels map[string]string) ([]string, []string) {
+	component := labels["app.kubernetes.io/component"]
+	kinds := componentKinds[component]
+	partOf := labels["app.kubernetes.io/part-of"]
+	suffix := "-" + component
+	if len(kinds) == 0 || !strings.HasPrefix(partOf, "splunk-") || !strings.HasSuffix(partOf, suffix) {
+		return nil, nil
+	}
+	identifier := strings.TrimSuffix(strings.TrimPrefix(partOf, "splunk-"), suffix)
+	if identifier == "" {
+		return nil, nil
+	}
+
+	names := []string{identifier}
+	for n := strings.LastIndex(identifier, "-"); n > 0; n = strings.LastIndex(identifier[:n], "-") {
+		names = append(names, identifier[:n])
+	}
+	return kinds, names
+}
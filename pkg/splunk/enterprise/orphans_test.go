@@ -0,0 +1,60 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestValidateOrphanPolicy(t *testing.T) {
+	for _, policy := range []string{OrphanPolicyIgnore, OrphanPolicyReport, OrphanPolicyDelete, OrphanPolicyDeleteWithVolumes} {
+		if err := ValidateOrphanPolicy(policy); err != nil {
+			t.Errorf("ValidateOrphanPolicy(%s) returned %v; want nil", policy, err)
+		}
+	}
+	for _, policy := range []string{"", "delete", "Orphan"} {
+		if err := ValidateOrphanPolicy(policy); err == nil {
+			t.Errorf("ValidateOrphanPolicy(%s) returned nil; want error", policy)
+		}
+	}
+}
+
+func TestGetLabeledOwners(t *testing.T) {
+	test := func(labels map[string]string, wantKinds, wantNames []string) {
+		kinds, names := GetLabeledOwners(labels)
+		if !reflect.DeepEqual(kinds, wantKinds) || !reflect.DeepEqual(names, wantNames) {
+			t.Errorf("GetLabeledOwners(%v) = %v, %v; want %v, %v", labels, kinds, names, wantKinds, wantNames)
+		}
+	}
+
+	test(getSplunkLabels("stack1", SplunkStandalone), []string{"Standalone"}, []string{"stack1"})
+	test(getSplunkLabels("stack1", SplunkClusterMaster), []string{"IndexerCluster"}, []string{"stack1"})
+	test(getSplunkLabelsForCR(&enterprisev1.ClusterMaster{ObjectMeta: metav1.ObjectMeta{Name: "stack1"}}, SplunkClusterMaster), []string{"ClusterMaster"}, []string{"stack1"})
+	test(getSplunkLabels("stack1", SplunkDeployer), []string{"SearchHeadCluster"}, []string{"stack1"})
+	test(getSplunkLabels(GetSplunkSiteIdentifier("my-idxc", "site1"), SplunkIndexer), []string{"IndexerCluster"}, []string{"my-idxc-site1", "my-idxc", "my"})
+	test(resources.GetLabels("spark", "spark-master", "stack1"), []string{"Spark"}, []string{"stack1"})
+
+	// labels that do not identify a custom resource
+	test(map[string]string{"app.kubernetes.io/managed-by": "splunk-operator"}, nil, nil)
+	test(resources.GetLabels("unknown", "unknown", "stack1"), nil, nil)
+	test(map[string]string{"app.kubernetes.io/component": "standalone", "app.kubernetes.io/part-of": "splunk--standalone"}, nil, nil)
+	test(map[string]string{"app.kubernetes.io/component": "standalone", "app.kubernetes.io/part-of": "stack1"}, nil, nil)
+}
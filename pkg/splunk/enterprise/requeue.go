@@ -0,0 +1,42 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// RequeueIntervalAnnotation is used to override the interval between reconciles of a custom resource that is not ready yet
+const RequeueIntervalAnnotation = "enterprise.splunk.com/requeue-interval"
+
+// GetRequeueInterval returns the interval between reconciles of a custom resource that is not ready yet, using its
+// requeue-interval annotation if set, or the interval configured for the controller of its kind otherwise. If the
+// interval is invalid, an error is returned along with the default interval.
+func GetRequeueInterval(cr enterprisev1.MetaObject) (time.Duration, error) {
+	value, ok := cr.GetObjectMeta().GetAnnotations()[RequeueIntervalAnnotation]
+	if !ok {
+		return resources.GetRequeueInterval(strings.ToLower(cr.GetTypeMeta().Kind))
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return resources.DefaultRequeueInterval, fmt.Errorf("%s annotation must be a positive duration, such as \"30s\"; value=\"%s\"", RequeueIntervalAnnotation, value)
+	}
+	return interval, nil
+}
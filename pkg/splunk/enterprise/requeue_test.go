@@ -0,0 +1,63 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestGetRequeueInterval(t *testing.T) {
+	defer os.Unsetenv(resources.RequeueIntervalEnv + "_INDEXERCLUSTER")
+
+	cr := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(want time.Duration, wantErr bool) {
+		got, err := GetRequeueInterval(&cr)
+		if wantErr && err == nil {
+			t.Errorf("GetRequeueInterval() returned nil; want error")
+		} else if !wantErr && err != nil {
+			t.Errorf("GetRequeueInterval() returned %v; want nil", err)
+		}
+		if got != want {
+			t.Errorf("GetRequeueInterval() = %s; want %s", got, want)
+		}
+	}
+
+	// interval configured for the kind of custom resource is used unless it has an annotation
+	test(resources.DefaultRequeueInterval, false)
+	os.Setenv(resources.RequeueIntervalEnv+"_INDEXERCLUSTER", "1m")
+	test(time.Minute, false)
+	cr.ObjectMeta.Annotations = map[string]string{RequeueIntervalAnnotation: "15s"}
+	test(15*time.Second, false)
+	cr.ObjectMeta.Annotations[RequeueIntervalAnnotation] = "-15s"
+	test(resources.DefaultRequeueInterval, true)
+	cr.ObjectMeta.Annotations[RequeueIntervalAnnotation] = "15"
+	test(resources.DefaultRequeueInterval, true)
+}
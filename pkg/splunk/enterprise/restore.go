@@ -0,0 +1,112 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// RestoreAnnotation is used on a Standalone or IndexerCluster to name a SplunkRestore that its pods must wait for
+const RestoreAnnotation = "enterprise.splunk.com/restore"
+
+// regular expression used to parse the names of persistent volume claims created from the volume claim templates of a StatefulSet
+var volumeClaimNameRegex = regexp.MustCompile(`^(pvc-etc|pvc-var)-splunk-(.+)-(\d+)$`)
+
+// ValidateSplunkRestoreSpec checks validity of a SplunkRestoreSpec, and returns error if something is wrong.
+func ValidateSplunkRestoreSpec(spec *enterprisev1.SplunkRestoreSpec) error {
+	if spec.BackupRef.Name == "" {
+		return fmt.Errorf("BackupRef name is required")
+	}
+	if spec.TargetRef.Name == "" {
+		return fmt.Errorf("TargetRef name is required")
+	}
+	if spec.TargetRef.Kind != "Standalone" && spec.TargetRef.Kind != "IndexerCluster" {
+		return fmt.Errorf("TargetRef kind must be Standalone or IndexerCluster; value=\"%s\"", spec.TargetRef.Kind)
+	}
+	return nil
+}
+
+// GetRestoredVolumeClaimName returns the name and instance type of the persistent volume claim used by the target of a
+// SplunkRestore resource, for a persistent volume claim that was backed up from the source identifier. This retains the
+// site of indexer cluster peers in a multisite indexer cluster. It returns error if the name was not used by the source.
+func GetRestoredVolumeClaimName(cr *enterprisev1.SplunkRestore, sourceIdentifier string, pvcName string) (string, InstanceType, error) {
+	if match := volumeClaimNameRegex.FindStringSubmatch(pvcName); match != nil {
+		for _, instanceType := range GetBackupInstanceTypes(cr.Spec.TargetRef.Kind) {
+			identifier := strings.TrimSuffix(match[2], "-"+instanceType.ToString())
+			if identifier == match[2] || (identifier != sourceIdentifier && !strings.HasPrefix(identifier, sourceIdentifier+"-")) {
+				continue
+			}
+			identifier = cr.Spec.TargetRef.Name + strings.TrimPrefix(identifier, sourceIdentifier)
+			return fmt.Sprintf("%s-%s-%s", match[1], GetSplunkStatefulsetName(instanceType, identifier), match[3]), instanceType, nil
+		}
+	}
+	return "", "", fmt.Errorf("Persistent volume claim %s was not used by %s %s", pvcName, cr.Spec.TargetRef.Kind, sourceIdentifier)
+}
+
+// GetRestoredPersistentVolumeClaim returns a Kubernetes PersistentVolumeClaim that uses a VolumeSnapshot as its data source,
+// for an instance of the target of a SplunkRestore resource. It is not owned by the SplunkRestore, so that restored volumes
+// are not deleted with it; the StatefulSet of the target adopts it using the same name as its volume claim templates.
+func GetRestoredPersistentVolumeClaim(cr *enterprisev1.SplunkRestore, name string, instanceType InstanceType, snapshotName string, size resource.Quantity) *corev1.PersistentVolumeClaim {
+	apiGroup := VolumeSnapshotGroupVersionKind.Group
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.GetNamespace(),
+			Labels:    getSplunkLabels(cr.Spec.TargetRef.Name, instanceType),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteOnce"},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     VolumeSnapshotGroupVersionKind.Kind,
+				Name:     snapshotName,
+			},
+		},
+	}
+	if cr.Spec.StorageClassName != "" {
+		pvc.Spec.StorageClassName = &cr.Spec.StorageClassName
+	}
+	return pvc
+}
+
+// GetRestoredSecrets returns a Kubernetes Secret for the target of a SplunkRestore resource, using a copy of the secrets
+// kept for a backup. It is not owned by the SplunkRestore, so that the target can continue using it.
+func GetRestoredSecrets(cr *enterprisev1.SplunkRestore, backupSecrets *corev1.Secret) *corev1.Secret {
+	instanceType := GetBackupInstanceTypes(cr.Spec.TargetRef.Kind)[0]
+	data := make(map[string][]byte)
+	for k, v := range backupSecrets.Data {
+		data[k] = v
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkSecretsName(cr.Spec.TargetRef.Name, instanceType),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}
+}
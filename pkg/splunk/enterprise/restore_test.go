@@ -0,0 +1,130 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateSplunkRestoreSpec(t *testing.T) {
+	test := func(spec enterprisev1.SplunkRestoreSpec, wantErr bool) {
+		err := ValidateSplunkRestoreSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateSplunkRestoreSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateSplunkRestoreSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	backup := corev1.ObjectReference{Name: "backup1"}
+	test(enterprisev1.SplunkRestoreSpec{BackupRef: backup, TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"}}, false)
+	test(enterprisev1.SplunkRestoreSpec{BackupRef: backup, TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"}}, false)
+	test(enterprisev1.SplunkRestoreSpec{BackupRef: backup, TargetRef: corev1.ObjectReference{Kind: "SearchHeadCluster", Name: "stack1"}}, true)
+	test(enterprisev1.SplunkRestoreSpec{BackupRef: backup, TargetRef: corev1.ObjectReference{Kind: "Standalone"}}, true)
+	test(enterprisev1.SplunkRestoreSpec{TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"}}, true)
+}
+
+func TestGetRestoredVolumeClaimName(t *testing.T) {
+	cr := enterprisev1.SplunkRestore{
+		Spec: enterprisev1.SplunkRestoreSpec{
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack2"},
+		},
+	}
+
+	test := func(pvcName string, wantName string, wantType InstanceType) {
+		name, instanceType, err := GetRestoredVolumeClaimName(&cr, "stack1", pvcName)
+		if wantName == "" {
+			if err == nil {
+				t.Errorf("GetRestoredVolumeClaimName(\"%s\") returned nil; want error", pvcName)
+			}
+			return
+		}
+		if err != nil || name != wantName || instanceType != wantType {
+			t.Errorf("GetRestoredVolumeClaimName(\"%s\") = %s, %s, %v; want %s, %s, nil", pvcName, name, instanceType, err, wantName, wantType)
+		}
+	}
+
+	test("pvc-etc-splunk-stack1-cluster-master-0", "pvc-etc-splunk-stack2-cluster-master-0", SplunkClusterMaster)
+	test("pvc-var-splunk-stack1-indexer-2", "pvc-var-splunk-stack2-indexer-2", SplunkIndexer)
+	test("pvc-etc-splunk-stack1-site2-indexer-0", "pvc-etc-splunk-stack2-site2-indexer-0", SplunkIndexer)
+	test("pvc-etc-splunk-stack10-indexer-0", "", "")
+	test("pvc-etc-splunk-stack1-standalone-0", "", "")
+	test("pvc-other-splunk-stack1-indexer-0", "", "")
+}
+
+func TestGetRestoredPersistentVolumeClaim(t *testing.T) {
+	cr := enterprisev1.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SplunkRestoreSpec{
+			TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack2"},
+		},
+	}
+
+	test := func(storageClassName string) {
+		cr.Spec.StorageClassName = storageClassName
+		pvc := GetRestoredPersistentVolumeClaim(&cr, "pvc-etc-splunk-stack2-standalone-0", SplunkStandalone, "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0", resource.MustParse("10Gi"))
+		if pvc.GetName() != "pvc-etc-splunk-stack2-standalone-0" || pvc.GetNamespace() != "test" || len(pvc.GetOwnerReferences()) != 0 {
+			t.Errorf("GetRestoredPersistentVolumeClaim() = %v; want %s/%s without owner", pvc.ObjectMeta, "test", "pvc-etc-splunk-stack2-standalone-0")
+		}
+		if got := pvc.GetLabels()["app.kubernetes.io/part-of"]; got != "splunk-stack2-standalone" {
+			t.Errorf("GetRestoredPersistentVolumeClaim() part-of label = %s; want %s", got, "splunk-stack2-standalone")
+		}
+		dataSource := pvc.Spec.DataSource
+		if dataSource == nil || *dataSource.APIGroup != "snapshot.storage.k8s.io" || dataSource.Kind != "VolumeSnapshot" || dataSource.Name != "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0" {
+			t.Errorf("GetRestoredPersistentVolumeClaim() dataSource = %v; want VolumeSnapshot %s", dataSource, "backup1-20200508100000-pvc-etc-splunk-stack1-standalone-0")
+		}
+		if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != "10Gi" {
+			t.Errorf("GetRestoredPersistentVolumeClaim() storage = %s; want %s", size.String(), "10Gi")
+		}
+		if storageClassName == "" && pvc.Spec.StorageClassName != nil {
+			t.Errorf("GetRestoredPersistentVolumeClaim() storageClassName = %s; want nil", *pvc.Spec.StorageClassName)
+		} else if storageClassName != "" && (pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != storageClassName) {
+			t.Errorf("GetRestoredPersistentVolumeClaim() storageClassName = %v; want %s", pvc.Spec.StorageClassName, storageClassName)
+		}
+	}
+
+	test("")
+	test("gp2")
+}
+
+func TestGetRestoredSecrets(t *testing.T) {
+	cr := enterprisev1.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SplunkRestoreSpec{
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack2"},
+		},
+	}
+	backupSecrets := &corev1.Secret{Data: map[string][]byte{"password": []byte("p@ssw0rd")}}
+
+	secrets := GetRestoredSecrets(&cr, backupSecrets)
+	if secrets.GetName() != "splunk-stack2-indexer-secrets" || secrets.GetNamespace() != "test" {
+		t.Errorf("GetRestoredSecrets() name = %s/%s; want %s/%s", secrets.GetNamespace(), secrets.GetName(), "test", "splunk-stack2-indexer-secrets")
+	}
+	if string(secrets.Data["password"]) != "p@ssw0rd" {
+		t.Errorf("GetRestoredSecrets() password = %s; want %s", secrets.Data["password"], "p@ssw0rd")
+	}
+}
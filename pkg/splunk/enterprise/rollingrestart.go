@@ -0,0 +1,58 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// IsSearchableRollingRestart returns true if indexer cluster peers are restarted using a searchable rolling restart of
+// the cluster master, instead of recycling their pods, when a rolling restart is requested
+func IsSearchableRollingRestart(spec *enterprisev1.IndexerClusterSpec) bool {
+	return spec.RollingRestartMode == RollingRestartModeSearchable
+}
+
+// validateRollingRestartMode checks validity and makes default updates to the rolling restart mode of an IndexerClusterSpec,
+// and returns error if something is wrong.
+func validateRollingRestartMode(spec *enterprisev1.IndexerClusterSpec) error {
+	if spec.RollingRestartMode == "" {
+		spec.RollingRestartMode = RollingRestartModeRestart
+	}
+	if spec.RollingRestartMode != RollingRestartModeRestart && spec.RollingRestartMode != RollingRestartModeSearchable {
+		return fmt.Errorf("rollingRestartMode must be either \"%s\" or \"%s\"; value=\"%s\"", RollingRestartModeRestart, RollingRestartModeSearchable, spec.RollingRestartMode)
+	}
+	return nil
+}
+
+// setPeerRestartAnnotation keeps the restarted-at annotation of an indexer cluster peer pod template at the value most recently
+// handled, when searchable rolling restarts are used, so that peer pods are not recycled for restart requests that the
+// cluster master handles instead.
+func setPeerRestartAnnotation(podTemplateSpec *corev1.PodTemplateSpec, cr *enterprisev1.IndexerCluster) {
+	if !IsSearchableRollingRestart(&cr.Spec) {
+		return
+	}
+	if cr.Status.RollingRestartRequest == "" {
+		delete(podTemplateSpec.ObjectMeta.Annotations, RestartAnnotation)
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[RestartAnnotation] = cr.Status.RollingRestartRequest
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateRollingRestartMode(t *testing.T) {
+	test := func(mode string, wantErr bool, want string) {
+		spec := enterprisev1.IndexerClusterSpec{RollingRestartMode: mode}
+		err := validateRollingRestartMode(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateRollingRestartMode(%s) returned nil; want error", mode)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateRollingRestartMode(%s) returned %v; want nil", mode, err)
+		}
+		if !wantErr && spec.RollingRestartMode != want {
+			t.Errorf("validateRollingRestartMode(%s) rollingRestartMode = %s; want %s", mode, spec.RollingRestartMode, want)
+		}
+	}
+
+	test("", false, RollingRestartModeRestart)
+	test("restart", false, RollingRestartModeRestart)
+	test("searchable", false, RollingRestartModeSearchable)
+	test("shutdown", true, "")
+}
+
+func TestSetPeerRestartAnnotation(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Annotations: map[string]string{RestartAnnotation: "2020-06-01T10:00:00Z"},
+		},
+	}
+
+	test := func(mode, handled, want string) {
+		cr.Spec.RollingRestartMode = mode
+		cr.Status.RollingRestartRequest = handled
+		podTemplateSpec := corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{RestartAnnotation: GetRestartRequest(&cr)}},
+		}
+		setPeerRestartAnnotation(&podTemplateSpec, &cr)
+		if got := podTemplateSpec.ObjectMeta.Annotations[RestartAnnotation]; got != want {
+			t.Errorf("setPeerRestartAnnotation(%s, %s) = %s; want %s", mode, handled, got, want)
+		}
+	}
+
+	// peer pods are recycled for restart requests by default
+	test(RollingRestartModeRestart, "", "2020-06-01T10:00:00Z")
+
+	// the pod template keeps the request most recently handled when the cluster master restarts peers
+	test(RollingRestartModeSearchable, "2020-05-01T10:00:00Z", "2020-05-01T10:00:00Z")
+	test(RollingRestartModeSearchable, "", "")
+
+	// statefulsets of all sites keep the same request
+	ss, err := GetIndexerSiteStatefulSet(&cr, enterprisev1.IndexerClusterSiteSpec{Name: "site1", Replicas: 1})
+	if err != nil {
+		t.Fatalf("GetIndexerSiteStatefulSet() returned %v; want nil", err)
+	}
+	if _, ok := ss.Spec.Template.ObjectMeta.Annotations[RestartAnnotation]; ok {
+		t.Errorf("GetIndexerSiteStatefulSet() annotations = %v; want no %s", ss.Spec.Template.ObjectMeta.Annotations, RestartAnnotation)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// maximum number of days searched for the previous or next time matching a scaling schedule
+const maxScalingScheduleDays = 366
+
+// cronSchedule is a parsed schedule in cron format, where each field is a bit mask of the values it matches
+type cronSchedule struct {
+	minutes       uint64
+	hours         uint64
+	daysOfMonth   uint64
+	months        uint64
+	daysOfWeek    uint64
+	anyDayOfWeek  bool
+	anyDayOfMonth bool
+}
+
+// parseCronField parses a single field of a schedule in cron format (for example "*", "1-5", "*/15" or "0,30"), and returns
+// a bit mask of the values between min and max that it matches
+func parseCronField(field string, min, max int) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in \"%s\"", part)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in \"%s\"", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in \"%s\"", part)
+				}
+			} else if step > 1 {
+				// a single value with a step (for example "5/10") matches from the value to the maximum
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("\"%s\" is not within %d-%d", part, min, max)
+		}
+
+		for n := start; n <= end; n += step {
+			mask |= 1 << uint(n)
+		}
+	}
+	return mask, nil
+}
+
+// parseCronSchedule parses a schedule in cron format ("minute hour day-of-month month day-of-week"). Day of week 7 is
+// treated as Sunday, like 0.
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute %v", err)
+	}
+	if s.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour %v", err)
+	}
+	if s.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month %v", err)
+	}
+	if s.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month %v", err)
+	}
+	if s.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week %v", err)
+	}
+	if s.daysOfWeek&(1<<7) != 0 {
+		s.daysOfWeek |= 1
+	}
+	s.anyDayOfMonth = fields[2] == "*"
+	s.anyDayOfWeek = fields[4] == "*"
+	return &s, nil
+}
+
+// matchesDay returns true if a schedule matches the date of t. Like cron, when both the day of month and the day of week
+// are restricted, the date matches if either of them does.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if s.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dayOfMonth := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.anyDayOfMonth && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMonth:
+		return dayOfWeek
+	case s.anyDayOfWeek:
+		return dayOfMonth
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// prev returns the most recent time matching a schedule that is not after t, or false if there is none within
+// maxScalingScheduleDays
+func (s *cronSchedule) prev(t time.Time) (time.Time, bool) {
+	for day := 0; day <= maxScalingScheduleDays; day++ {
+		date := time.Date(t.Year(), t.Month(), t.Day()-day, 0, 0, 0, 0, t.Location())
+		if !s.matchesDay(date) {
+			continue
+		}
+		startHour := 23
+		if day == 0 {
+			startHour = t.Hour()
+		}
+		for hour := startHour; hour >= 0; hour-- {
+			if s.hours&(1<<uint(hour)) == 0 {
+				continue
+			}
+			startMinute := 59
+			if day == 0 && hour == t.Hour() {
+				startMinute = t.Minute()
+			}
+			for minute := startMinute; minute >= 0; minute-- {
+				if s.minutes&(1<<uint(minute)) != 0 {
+					return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, t.Location()), true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// next returns the earliest time matching a schedule that is after t, or false if there is none within
+// maxScalingScheduleDays
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	for day := 0; day <= maxScalingScheduleDays; day++ {
+		date := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, t.Location())
+		if !s.matchesDay(date) {
+			continue
+		}
+		startHour := 0
+		if day == 0 {
+			startHour = t.Hour()
+		}
+		for hour := startHour; hour <= 23; hour++ {
+			if s.hours&(1<<uint(hour)) == 0 {
+				continue
+			}
+			startMinute := 0
+			if day == 0 && hour == t.Hour() {
+				startMinute = t.Minute() + 1
+			}
+			for minute := startMinute; minute <= 59; minute++ {
+				if s.minutes&(1<<uint(minute)) != 0 {
+					return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, t.Location()), true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// getScalingScheduleLocation returns the time zone used by a scaling schedule entry
+func getScalingScheduleLocation(entry *enterprisev1.ScalingScheduleEntry) (*time.Location, error) {
+	if entry.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(entry.TimeZone)
+}
+
+// validateScalingSchedule checks validity of the entries of a scaling schedule, which must not scale to fewer than
+// minReplicas, and returns error if something is wrong.
+func validateScalingSchedule(schedule []enterprisev1.ScalingScheduleEntry, minReplicas int32) error {
+	for idx := range schedule {
+		entry := &schedule[idx]
+		if _, err := parseCronSchedule(entry.Schedule); err != nil {
+			return fmt.Errorf("ScalingSchedule schedule is invalid; value=\"%s\": %v", entry.Schedule, err)
+		}
+		if _, err := getScalingScheduleLocation(entry); err != nil {
+			return fmt.Errorf("ScalingSchedule timeZone is invalid; value=\"%s\": %v", entry.TimeZone, err)
+		}
+		if entry.Replicas < minReplicas {
+			return fmt.Errorf("ScalingSchedule replicas must be at least %d; schedule=\"%s\", replicas=%d", minReplicas, entry.Schedule, entry.Replicas)
+		}
+	}
+	return nil
+}
+
+// GetScalingScheduleEntry returns the entry of a scaling schedule with the most recent scheduled time that is not after now,
+// along with that time, and the earliest scheduled time of any entry after now. The entry returned is nil if no scheduled
+// times are found within a year before now, and the next time is zero if none are found within a year after now.
+func GetScalingScheduleEntry(schedule []enterprisev1.ScalingScheduleEntry, now time.Time) (*enterprisev1.ScalingScheduleEntry, time.Time, time.Time) {
+	var current *enterprisev1.ScalingScheduleEntry
+	var last, next time.Time
+	for idx := range schedule {
+		entry := &schedule[idx]
+		cron, err := parseCronSchedule(entry.Schedule)
+		if err != nil {
+			continue
+		}
+		location, err := getScalingScheduleLocation(entry)
+		if err != nil {
+			continue
+		}
+		t := now.In(location)
+		if prev, ok := cron.prev(t); ok && (current == nil || prev.After(last)) {
+			current, last = entry, prev
+		}
+		if n, ok := cron.next(t); ok && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return current, last, next
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	test := func(schedule string, wantErr bool) {
+		_, err := parseCronSchedule(schedule)
+		if wantErr && err == nil {
+			t.Errorf("parseCronSchedule(\"%s\") returned nil; want error", schedule)
+		} else if !wantErr && err != nil {
+			t.Errorf("parseCronSchedule(\"%s\") returned %v; want nil", schedule, err)
+		}
+	}
+
+	test("0 8 * * 1-5", false)
+	test("*/15 0,12 1 */2 7", false)
+	test("5/10 * * * *", false)
+	test("0 8 * *", true)
+	test("60 8 * * *", true)
+	test("0 8 0 * *", true)
+	test("0 8 * 1-13 *", true)
+	test("0 8 * * 5-1", true)
+	test("0 8 * * */0", true)
+	test("0 eight * * *", true)
+}
+
+func TestCronSchedulePrevNext(t *testing.T) {
+	now := time.Date(2020, time.June, 10, 12, 30, 15, 0, time.UTC) // a Wednesday
+	test := func(schedule string, wantPrev, wantNext time.Time) {
+		cron, err := parseCronSchedule(schedule)
+		if err != nil {
+			t.Fatalf("parseCronSchedule(\"%s\") returned %v; want nil", schedule, err)
+		}
+		if prev, _ := cron.prev(now); !prev.Equal(wantPrev) {
+			t.Errorf("prev(\"%s\") = %s; want %s", schedule, prev, wantPrev)
+		}
+		if next, _ := cron.next(now); !next.Equal(wantNext) {
+			t.Errorf("next(\"%s\") = %s; want %s", schedule, next, wantNext)
+		}
+	}
+
+	test("0 8 * * 1-5", time.Date(2020, time.June, 10, 8, 0, 0, 0, time.UTC), time.Date(2020, time.June, 11, 8, 0, 0, 0, time.UTC))
+	test("0 8 * * 6", time.Date(2020, time.June, 6, 8, 0, 0, 0, time.UTC), time.Date(2020, time.June, 13, 8, 0, 0, 0, time.UTC))
+	test("30 12 * * *", time.Date(2020, time.June, 10, 12, 30, 0, 0, time.UTC), time.Date(2020, time.June, 11, 12, 30, 0, 0, time.UTC))
+	test("*/20 * * * *", time.Date(2020, time.June, 10, 12, 20, 0, 0, time.UTC), time.Date(2020, time.June, 10, 12, 40, 0, 0, time.UTC))
+	test("0 0 1 * *", time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, time.July, 1, 0, 0, 0, 0, time.UTC))
+
+	// when both day of month and day of week are restricted, either of them matches
+	test("0 0 15 * 0", time.Date(2020, time.June, 7, 0, 0, 0, 0, time.UTC), time.Date(2020, time.June, 14, 0, 0, 0, 0, time.UTC))
+}
+
+func TestValidateScalingSchedule(t *testing.T) {
+	test := func(entry enterprisev1.ScalingScheduleEntry, wantErr bool) {
+		err := validateScalingSchedule([]enterprisev1.ScalingScheduleEntry{entry}, 3)
+		if wantErr && err == nil {
+			t.Errorf("validateScalingSchedule(%v) returned nil; want error", entry)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateScalingSchedule(%v) returned %v; want nil", entry, err)
+		}
+	}
+
+	test(enterprisev1.ScalingScheduleEntry{Schedule: "0 8 * * 1-5", Replicas: 5}, false)
+	test(enterprisev1.ScalingScheduleEntry{Schedule: "0 8 * * 1-5", Replicas: 5, TimeZone: "UTC"}, false)
+	test(enterprisev1.ScalingScheduleEntry{Schedule: "0 8 * * 1-5", Replicas: 2}, true)
+	test(enterprisev1.ScalingScheduleEntry{Schedule: "0 8 * * mon", Replicas: 5}, true)
+	test(enterprisev1.ScalingScheduleEntry{Schedule: "0 8 * * 1-5", Replicas: 5, TimeZone: "Not/AZone"}, true)
+
+	spec := enterprisev1.IndexerClusterSpec{
+		ReplicationFactor: 3,
+		ScalingSchedule:   []enterprisev1.ScalingScheduleEntry{{Schedule: "0 20 * * *", Replicas: 2}},
+	}
+	if err := validateIndexerClusterScalingSchedule(&spec); err == nil {
+		t.Errorf("validateIndexerClusterScalingSchedule() returned nil; want error for replicas < replicationFactor")
+	}
+	spec.ScalingSchedule[0].Replicas = 3
+	if err := validateIndexerClusterScalingSchedule(&spec); err != nil {
+		t.Errorf("validateIndexerClusterScalingSchedule() returned %v; want nil", err)
+	}
+	spec.Sites = []enterprisev1.IndexerClusterSiteSpec{{Name: "site1", Replicas: 3}}
+	if err := validateIndexerClusterScalingSchedule(&spec); err == nil {
+		t.Errorf("validateIndexerClusterScalingSchedule() returned nil; want error for multisite indexer cluster")
+	}
+}
+
+func TestGetScalingScheduleEntry(t *testing.T) {
+	schedule := []enterprisev1.ScalingScheduleEntry{
+		{Schedule: "0 8 * * 1-5", Replicas: 10},
+		{Schedule: "0 20 * * *", Replicas: 3},
+	}
+
+	test := func(now time.Time, wantReplicas int32, wantLast, wantNext time.Time) {
+		entry, last, next := GetScalingScheduleEntry(schedule, now)
+		if entry == nil || entry.Replicas != wantReplicas {
+			t.Errorf("GetScalingScheduleEntry(%s) entry = %v; want replicas %d", now, entry, wantReplicas)
+		}
+		if !last.Equal(wantLast) {
+			t.Errorf("GetScalingScheduleEntry(%s) last = %s; want %s", now, last, wantLast)
+		}
+		if !next.Equal(wantNext) {
+			t.Errorf("GetScalingScheduleEntry(%s) next = %s; want %s", now, next, wantNext)
+		}
+	}
+
+	// business hours on a Wednesday, overnight, and over a weekend
+	test(time.Date(2020, time.June, 10, 12, 0, 0, 0, time.UTC), 10, time.Date(2020, time.June, 10, 8, 0, 0, 0, time.UTC), time.Date(2020, time.June, 10, 20, 0, 0, 0, time.UTC))
+	test(time.Date(2020, time.June, 10, 23, 0, 0, 0, time.UTC), 3, time.Date(2020, time.June, 10, 20, 0, 0, 0, time.UTC), time.Date(2020, time.June, 11, 8, 0, 0, 0, time.UTC))
+	test(time.Date(2020, time.June, 13, 12, 0, 0, 0, time.UTC), 3, time.Date(2020, time.June, 12, 20, 0, 0, 0, time.UTC), time.Date(2020, time.June, 13, 20, 0, 0, 0, time.UTC))
+
+	// schedules use their time zone
+	schedule[0].TimeZone = "America/New_York"
+	test(time.Date(2020, time.June, 10, 11, 0, 0, 0, time.UTC), 3, time.Date(2020, time.June, 9, 20, 0, 0, 0, time.UTC), time.Date(2020, time.June, 10, 12, 0, 0, 0, time.UTC))
+
+	if entry, _, _ := GetScalingScheduleEntry(nil, time.Now()); entry != nil {
+		t.Errorf("GetScalingScheduleEntry(nil) entry = %v; want nil", entry)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// SecretsRotatedAnnotation is used to record when new secret values were last applied to Splunk Enterprise instances
+	SecretsRotatedAnnotation = "enterprise.splunk.com/secrets-rotated"
+
+	// pod template annotation used to restart pods when secret values change
+	secretsChecksumAnnotation = "enterprise.splunk.com/secrets-checksum"
+
+	// minimum interval allowed between automated secret rotations
+	minSecretRotationInterval = time.Hour
+
+	// SecretsProviderVault is the type of secrets provider used for HashiCorp Vault
+	SecretsProviderVault = "vault"
+
+	// SecretsProviderAWS is the type of secrets provider used for AWS Secrets Manager
+	SecretsProviderAWS = "aws"
+
+	// SecretsProviderVersionAnnotation is used to record the version of secret values most recently retrieved from a secrets provider
+	SecretsProviderVersionAnnotation = "enterprise.splunk.com/secrets-provider-version"
+
+	// SecretsProviderSyncedAnnotation is used to record when a secrets provider was last checked for a new version
+	SecretsProviderSyncedAnnotation = "enterprise.splunk.com/secrets-provider-synced"
+
+	// default interval between checks for a new version of secret values from a secrets provider
+	defaultSecretsProviderSyncInterval = "5m"
+
+	// minimum interval allowed between checks for a new version of secret values from a secrets provider
+	minSecretsProviderSyncInterval = time.Minute
+)
+
+// regular expression used to find the admin password in a default.yml generated by GetSplunkSecretsDefaults
+var defaultsPasswordRegex = regexp.MustCompile(`(?m)^    password: "(.*)"$`)
+
+// GetSplunkSecretsDefaults returns the contents of a default.yml file generated from the values in a Kubernetes Secret's data.
+func GetSplunkSecretsDefaults(secretData map[string][]byte) []byte {
+	return []byte(fmt.Sprintf(`
+splunk:
+    hec_disabled: 0
+    hec_enableSSL: 0
+    hec_token: "%s"
+    password: "%s"
+    pass4SymmKey: "%s"
+    idxc:
+        secret: "%s"
+    shc:
+        secret: "%s"
+`,
+		secretData["hec_token"],
+		secretData["password"],
+		secretData["pass4SymmKey"],
+		secretData["idxc_secret"],
+		secretData["shc_secret"]))
+}
+
+// IsSecretsRotationPending returns true if the values in a Kubernetes Secret have changed, and have not yet been applied to its default.yml.
+func IsSecretsRotationPending(secret *corev1.Secret) bool {
+	return !bytes.Equal(secret.Data["default.yml"], GetSplunkSecretsDefaults(secret.Data))
+}
+
+// GetAppliedAdminPassword returns the admin password most recently applied to Splunk Enterprise instances, from a Secret's default.yml.
+func GetAppliedAdminPassword(secret *corev1.Secret) string {
+	match := defaultsPasswordRegex.FindSubmatch(secret.Data["default.yml"])
+	if match == nil {
+		return string(secret.Data["password"])
+	}
+	return string(match[1])
+}
+
+// validateSecretRotationInterval checks validity of a secret rotation interval, and returns error if something is wrong.
+func validateSecretRotationInterval(interval string) error {
+	if interval == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("SecretRotationInterval is invalid; value=\"%s\": %v", interval, err)
+	}
+	if d < minSecretRotationInterval {
+		return fmt.Errorf("SecretRotationInterval must be at least %s; value=\"%s\"", minSecretRotationInterval, interval)
+	}
+	return nil
+}
+
+// IsSecretsRotationDue returns true if a Kubernetes Secret has not been rotated within the given interval.
+// It always returns false if interval is empty.
+func IsSecretsRotationDue(secret *corev1.Secret, interval string, now time.Time) bool {
+	if interval == "" {
+		return false
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return false
+	}
+	lastRotated := secret.GetCreationTimestamp().Time
+	if value, ok := secret.GetAnnotations()[SecretsRotatedAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			lastRotated = t
+		}
+	}
+	return !now.Before(lastRotated.Add(d))
+}
+
+// RotateSplunkSecrets generates new values for the admin password and HEC token in a Kubernetes Secret, and optionally the pass4SymmKey.
+// The new values are applied to Splunk Enterprise instances by updating the Secret's default.yml once they are ready.
+func RotateSplunkSecrets(secret *corev1.Secret, rotatePass4SymmKey bool) {
+	secret.Data["password"] = generateSplunkSecret()
+	secret.Data["hec_token"] = generateHECToken()
+	if rotatePass4SymmKey {
+		secret.Data["pass4SymmKey"] = generateSplunkSecret()
+	}
+}
+
+// GetSecretsChecksum returns a checksum of the default.yml contained in a Kubernetes Secret.
+func GetSecretsChecksum(secret *corev1.Secret) string {
+	return fmt.Sprintf("%x", sha256.Sum256(secret.Data["default.yml"]))
+}
+
+// SetSecretsChecksum annotates a pod template with a checksum of the secrets that have been rotated, so that pods are
+// recycled when they change. It does nothing if secrets have never been rotated, to avoid restarting existing pods.
+func SetSecretsChecksum(podTemplateSpec *corev1.PodTemplateSpec, secret *corev1.Secret) {
+	if _, ok := secret.GetAnnotations()[SecretsRotatedAnnotation]; !ok {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[secretsChecksumAnnotation] = GetSecretsChecksum(secret)
+}
+
+// IsSecretsProviderConfigured returns true if an external secrets manager provides secret values
+func IsSecretsProviderConfigured(spec *enterprisev1.SecretsProviderSpec) bool {
+	return spec.Type != ""
+}
+
+// validateSecretsProviderSpec checks validity and makes default updates to a SecretsProviderSpec, and returns error if something is wrong.
+func validateSecretsProviderSpec(spec *enterprisev1.SecretsProviderSpec) error {
+	if !IsSecretsProviderConfigured(spec) {
+		return nil
+	}
+
+	switch spec.Type {
+	case SecretsProviderVault:
+		if spec.Endpoint == "" {
+			return fmt.Errorf("SecretsProvider endpoint is required for Vault")
+		}
+	case SecretsProviderAWS:
+		if spec.Region == "" {
+			spec.Region = "us-east-1"
+		}
+	default:
+		return fmt.Errorf("SecretsProvider type must be either \"%s\" or \"%s\"; value=\"%s\"", SecretsProviderVault, SecretsProviderAWS, spec.Type)
+	}
+
+	if spec.Path == "" {
+		return fmt.Errorf("SecretsProvider path is required")
+	}
+
+	if spec.SyncInterval == "" {
+		spec.SyncInterval = defaultSecretsProviderSyncInterval
+	}
+	d, err := time.ParseDuration(spec.SyncInterval)
+	if err != nil {
+		return fmt.Errorf("SecretsProvider syncInterval is invalid; value=\"%s\": %v", spec.SyncInterval, err)
+	}
+	if d < minSecretsProviderSyncInterval {
+		return fmt.Errorf("SecretsProvider syncInterval must be at least %s; value=\"%s\"", minSecretsProviderSyncInterval, spec.SyncInterval)
+	}
+	return nil
+}
+
+// IsSecretsProviderSyncDue returns true if a secrets provider has not been checked for a new version of the values in a
+// Kubernetes Secret within its sync interval.
+func IsSecretsProviderSyncDue(secret *corev1.Secret, spec *enterprisev1.SecretsProviderSpec, now time.Time) bool {
+	d, err := time.ParseDuration(spec.SyncInterval)
+	if err != nil {
+		return true
+	}
+	lastSynced, err := time.Parse(time.RFC3339, secret.GetAnnotations()[SecretsProviderSyncedAnnotation])
+	if err != nil {
+		return true
+	}
+	return !now.Before(lastSynced.Add(d))
+}
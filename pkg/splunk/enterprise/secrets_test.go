@@ -0,0 +1,186 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestSecretsRotation(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secret := GetSplunkSecrets(&cr, SplunkStandalone, nil, nil)
+	oldPassword := string(secret.Data["password"])
+	if IsSecretsRotationPending(secret) {
+		t.Errorf("IsSecretsRotationPending() = true for new secret; want false")
+	}
+	if got := GetAppliedAdminPassword(secret); got != oldPassword {
+		t.Errorf("GetAppliedAdminPassword() = %s; want %s", got, oldPassword)
+	}
+
+	// new values are pending until default.yml is updated
+	oldPass4SymmKey := string(secret.Data["pass4SymmKey"])
+	RotateSplunkSecrets(secret, false)
+	if string(secret.Data["password"]) == oldPassword {
+		t.Errorf("RotateSplunkSecrets() did not change password")
+	}
+	if string(secret.Data["pass4SymmKey"]) != oldPass4SymmKey {
+		t.Errorf("RotateSplunkSecrets(false) changed pass4SymmKey")
+	}
+	if !IsSecretsRotationPending(secret) {
+		t.Errorf("IsSecretsRotationPending() = false after rotation; want true")
+	}
+	if got := GetAppliedAdminPassword(secret); got != oldPassword {
+		t.Errorf("GetAppliedAdminPassword() = %s after rotation; want %s", got, oldPassword)
+	}
+	RotateSplunkSecrets(secret, true)
+	if string(secret.Data["pass4SymmKey"]) == oldPass4SymmKey {
+		t.Errorf("RotateSplunkSecrets(true) did not change pass4SymmKey")
+	}
+
+	// manual edits are also detected
+	secret.Data["default.yml"] = GetSplunkSecretsDefaults(secret.Data)
+	secret.Data["idxc_secret"] = []byte("changed")
+	if !IsSecretsRotationPending(secret) {
+		t.Errorf("IsSecretsRotationPending() = false after manual edit; want true")
+	}
+
+	// applied password falls back to secret data without a default.yml
+	delete(secret.Data, "default.yml")
+	if got := GetAppliedAdminPassword(secret); got != string(secret.Data["password"]) {
+		t.Errorf("GetAppliedAdminPassword() = %s without default.yml; want %s", got, secret.Data["password"])
+	}
+}
+
+func TestValidateSecretRotationInterval(t *testing.T) {
+	test := func(interval string, wantErr bool) {
+		err := validateSecretRotationInterval(interval)
+		if wantErr && err == nil {
+			t.Errorf("validateSecretRotationInterval(\"%s\") returned nil; want error", interval)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateSecretRotationInterval(\"%s\") returned %v; want nil", interval, err)
+		}
+	}
+
+	test("", false)
+	test("720h", false)
+	test("1h", false)
+	test("30m", true)
+	test("monthly", true)
+}
+
+func TestIsSecretsRotationDue(t *testing.T) {
+	created := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(created),
+		},
+	}
+	test := func(interval string, now time.Time, want bool) {
+		if got := IsSecretsRotationDue(&secret, interval, now); got != want {
+			t.Errorf("IsSecretsRotationDue(\"%s\", %s) = %t; want %t", interval, now, got, want)
+		}
+	}
+
+	test("", created.Add(1000*time.Hour), false)
+	test("24h", created.Add(23*time.Hour), false)
+	test("24h", created.Add(24*time.Hour), true)
+
+	// last rotation is used instead of creation time
+	secret.Annotations = map[string]string{SecretsRotatedAnnotation: "2020-05-10T00:00:00Z"}
+	test("24h", created.Add(48*time.Hour), false)
+	test("24h", created.Add(240*time.Hour), true)
+}
+
+func TestSetSecretsChecksum(t *testing.T) {
+	secret := corev1.Secret{
+		Data: map[string][]byte{"default.yml": []byte("splunk:")},
+	}
+	podTemplateSpec := corev1.PodTemplateSpec{}
+
+	// pods are not restarted for secrets that have never been rotated
+	SetSecretsChecksum(&podTemplateSpec, &secret)
+	if len(podTemplateSpec.Annotations) != 0 {
+		t.Errorf("SetSecretsChecksum() annotations = %v; want none", podTemplateSpec.Annotations)
+	}
+
+	secret.Annotations = map[string]string{SecretsRotatedAnnotation: "2020-05-10T00:00:00Z"}
+	SetSecretsChecksum(&podTemplateSpec, &secret)
+	checksum := podTemplateSpec.Annotations["enterprise.splunk.com/secrets-checksum"]
+	if checksum != GetSecretsChecksum(&secret) {
+		t.Errorf("SetSecretsChecksum() checksum = %s; want %s", checksum, GetSecretsChecksum(&secret))
+	}
+	secret.Data["default.yml"] = []byte("splunk:\n")
+	if GetSecretsChecksum(&secret) == checksum {
+		t.Errorf("GetSecretsChecksum() did not change after default.yml was updated")
+	}
+}
+
+func TestValidateSecretsProviderSpec(t *testing.T) {
+	test := func(spec enterprisev1.SecretsProviderSpec, wantErr bool) enterprisev1.SecretsProviderSpec {
+		err := validateSecretsProviderSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateSecretsProviderSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateSecretsProviderSpec(%v) returned %v; want nil", spec, err)
+		}
+		return spec
+	}
+
+	test(enterprisev1.SecretsProviderSpec{}, false)
+	test(enterprisev1.SecretsProviderSpec{Type: "gcp", Path: "splunk"}, true)
+	test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderVault, Path: "secret/splunk"}, true)
+	test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderAWS}, true)
+	test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderAWS, Path: "splunk", SyncInterval: "30s"}, true)
+	test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderAWS, Path: "splunk", SyncInterval: "often"}, true)
+
+	spec := test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderAWS, Path: "splunk"}, false)
+	if spec.Region != "us-east-1" || spec.SyncInterval != "5m" {
+		t.Errorf("validateSecretsProviderSpec() region = %s, syncInterval = %s; want us-east-1, 5m", spec.Region, spec.SyncInterval)
+	}
+	spec = test(enterprisev1.SecretsProviderSpec{Type: SecretsProviderVault, Endpoint: "https://vault:8200", Path: "secret/splunk", SyncInterval: "1h"}, false)
+	if spec.Region != "" || spec.SyncInterval != "1h" {
+		t.Errorf("validateSecretsProviderSpec() region = %s, syncInterval = %s; want empty, 1h", spec.Region, spec.SyncInterval)
+	}
+}
+
+func TestIsSecretsProviderSyncDue(t *testing.T) {
+	synced := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	spec := enterprisev1.SecretsProviderSpec{Type: SecretsProviderAWS, Path: "splunk", SyncInterval: "5m"}
+	secret := corev1.Secret{}
+
+	// always due if never synced
+	if !IsSecretsProviderSyncDue(&secret, &spec, synced) {
+		t.Errorf("IsSecretsProviderSyncDue() = false without %s; want true", SecretsProviderSyncedAnnotation)
+	}
+
+	secret.Annotations = map[string]string{SecretsProviderSyncedAnnotation: synced.Format(time.RFC3339)}
+	if IsSecretsProviderSyncDue(&secret, &spec, synced.Add(time.Minute)) {
+		t.Errorf("IsSecretsProviderSyncDue() = true after 1m; want false")
+	}
+	if !IsSecretsProviderSyncDue(&secret, &spec, synced.Add(5*time.Minute)) {
+		t.Errorf("IsSecretsProviderSyncDue() = false after 5m; want true")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// ServiceMeshIstio is used to inject Istio sidecars into pods
+	ServiceMeshIstio = "istio"
+
+	// ServiceMeshLinkerd is used to inject Linkerd sidecars into pods
+	ServiceMeshLinkerd = "linkerd"
+)
+
+// ports used by Splunk Enterprise for management, KV store, replication, forwarding and DFS traffic, which are never
+// intercepted by sidecars because splunkd already secures them, and clustering relies on peers connecting to each other
+// directly using their pod addresses
+var serviceMeshExcludedPorts = []int{7777, 8089, 8191, 9000, 9887, 9997, 17000, 17500, 19000}
+
+// IsServiceMeshEnabled returns true if a service mesh has been configured to inject sidecars into the pods of a resource
+func IsServiceMeshEnabled(spec *enterprisev1.ServiceMeshSpec) bool {
+	return spec.Type != ""
+}
+
+// validateServiceMeshSpec checks validity of a ServiceMeshSpec, and returns error if something is wrong.
+func validateServiceMeshSpec(spec *enterprisev1.ServiceMeshSpec) error {
+	switch spec.Type {
+	case "", ServiceMeshIstio, ServiceMeshLinkerd:
+		return nil
+	}
+	return fmt.Errorf("ServiceMesh type must be either \"%s\" or \"%s\"; value=\"%s\"", ServiceMeshIstio, ServiceMeshLinkerd, spec.Type)
+}
+
+// getServiceMeshExcludedPorts returns a comma-separated list of the ports that sidecars must not intercept
+func getServiceMeshExcludedPorts() string {
+	ports := make([]string, len(serviceMeshExcludedPorts))
+	for idx, port := range serviceMeshExcludedPorts {
+		ports[idx] = fmt.Sprintf("%d", port)
+	}
+	return strings.Join(ports, ",")
+}
+
+// getServiceMeshAnnotations returns the pod annotations used to inject and configure the sidecars of a service mesh
+func getServiceMeshAnnotations(spec *enterprisev1.ServiceMeshSpec) map[string]string {
+	excludedPorts := getServiceMeshExcludedPorts()
+	switch spec.Type {
+	case ServiceMeshIstio:
+		return map[string]string{
+			"sidecar.istio.io/inject":                       "true",
+			"traffic.sidecar.istio.io/excludeInboundPorts":  excludedPorts,
+			"traffic.sidecar.istio.io/excludeOutboundPorts": excludedPorts,
+			"proxy.istio.io/config":                         `{"holdApplicationUntilProxyStarts":true}`,
+		}
+	case ServiceMeshLinkerd:
+		return map[string]string{
+			"linkerd.io/inject":                     "enabled",
+			"config.linkerd.io/skip-inbound-ports":  excludedPorts,
+			"config.linkerd.io/skip-outbound-ports": excludedPorts,
+			"config.linkerd.io/proxy-await":         "enabled",
+		}
+	}
+	return nil
+}
+
+// addServiceMeshToPodTemplate annotates a pod template so that a service mesh injects sidecars that leave Splunk
+// Enterprise ports alone, and only starts Splunk containers once their sidecar is ready, so that the ansible plays run
+// at startup (and the readiness probe waiting for them) do not fail while the sidecar is still starting. Annotations of
+// the custom resource take precedence, as they do for other pod annotations. Nothing is done if a service mesh is not
+// configured.
+func addServiceMeshToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.ServiceMeshSpec) {
+	if !IsServiceMeshEnabled(spec) {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	parentAnnotations := cr.GetAnnotations()
+	for k, v := range getServiceMeshAnnotations(spec) {
+		if _, ok := parentAnnotations[k]; !ok {
+			podTemplateSpec.ObjectMeta.Annotations[k] = v
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateServiceMeshSpec(t *testing.T) {
+	test := func(spec enterprisev1.ServiceMeshSpec, wantErr bool) {
+		err := validateServiceMeshSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateServiceMeshSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateServiceMeshSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.ServiceMeshSpec{}, false)
+	test(enterprisev1.ServiceMeshSpec{Type: ServiceMeshIstio}, false)
+	test(enterprisev1.ServiceMeshSpec{Type: ServiceMeshLinkerd}, false)
+	test(enterprisev1.ServiceMeshSpec{Type: "consul"}, true)
+}
+
+func TestAddServiceMeshToPodTemplate(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	test := func(spec enterprisev1.ServiceMeshSpec, want map[string]string) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{"traffic.sidecar.istio.io/includeInboundPorts": "8000,8088"},
+			},
+		}
+		addServiceMeshToPodTemplate(&podTemplateSpec, &cr, &spec)
+		if !reflect.DeepEqual(podTemplateSpec.ObjectMeta.Annotations, want) {
+			t.Errorf("addServiceMeshToPodTemplate(%v) annotations = %v; want %v", spec, podTemplateSpec.ObjectMeta.Annotations, want)
+		}
+	}
+
+	excludedPorts := "7777,8089,8191,9000,9887,9997,17000,17500,19000"
+	test(enterprisev1.ServiceMeshSpec{}, map[string]string{
+		"traffic.sidecar.istio.io/includeInboundPorts": "8000,8088",
+	})
+	test(enterprisev1.ServiceMeshSpec{Type: ServiceMeshIstio}, map[string]string{
+		"traffic.sidecar.istio.io/includeInboundPorts":  "8000,8088",
+		"sidecar.istio.io/inject":                       "true",
+		"traffic.sidecar.istio.io/excludeInboundPorts":  excludedPorts,
+		"traffic.sidecar.istio.io/excludeOutboundPorts": excludedPorts,
+		"proxy.istio.io/config":                         `{"holdApplicationUntilProxyStarts":true}`,
+	})
+	test(enterprisev1.ServiceMeshSpec{Type: ServiceMeshLinkerd}, map[string]string{
+		"traffic.sidecar.istio.io/includeInboundPorts": "8000,8088",
+		"linkerd.io/inject":                            "enabled",
+		"config.linkerd.io/skip-inbound-ports":         excludedPorts,
+		"config.linkerd.io/skip-outbound-ports":        excludedPorts,
+		"config.linkerd.io/proxy-await":                "enabled",
+	})
+
+	// annotations of the custom resource take precedence
+	cr.ObjectMeta.Annotations = map[string]string{"config.linkerd.io/proxy-await": "disabled"}
+	test(enterprisev1.ServiceMeshSpec{Type: ServiceMeshLinkerd}, map[string]string{
+		"traffic.sidecar.istio.io/includeInboundPorts": "8000,8088",
+		"linkerd.io/inject":                            "enabled",
+		"config.linkerd.io/skip-inbound-ports":         excludedPorts,
+		"config.linkerd.io/skip-outbound-ports":        excludedPorts,
+	})
+}
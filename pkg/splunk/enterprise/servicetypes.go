@@ -0,0 +1,112 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// servicePortGroup is a group of Splunk ports that may be exposed using a service of its own type
+type servicePortGroup struct {
+	// name used for the service of the group
+	name string
+
+	// name of the service port included in the group
+	portName string
+}
+
+// groups of Splunk ports that may be exposed using services of their own type
+var servicePortGroups = []servicePortGroup{
+	{name: "web", portName: "splunkweb"},
+	{name: "hec", portName: "hec"},
+	{name: "management", portName: "splunkd"},
+}
+
+// getServicePortGroupType returns the service type configured for a group of Splunk ports
+func getServicePortGroupType(spec *enterprisev1.ServiceTypesSpec, group string) corev1.ServiceType {
+	switch group {
+	case "web":
+		return spec.Web
+	case "hec":
+		return spec.HEC
+	case "management":
+		return spec.Management
+	}
+	return ""
+}
+
+// validateServiceTypesSpec checks validity of a ServiceTypesSpec, and returns error if something is wrong.
+func validateServiceTypesSpec(spec *enterprisev1.ServiceTypesSpec) error {
+	for _, group := range servicePortGroups {
+		switch serviceType := getServicePortGroupType(spec, group.name); serviceType {
+		case "", corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+		default:
+			return fmt.Errorf("ServiceTypes %s must be either \"%s\", \"%s\" or \"%s\"; value=\"%s\"", group.name, corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer, serviceType)
+		}
+	}
+	return nil
+}
+
+// GetSplunkPortGroupServices returns a Kubernetes Service for each group of Splunk ports exposed by a regular service that
+// is configured to use a type other than ClusterIP. Each service is a copy of the regular service, using the same selector,
+// that only exposes the ports of its group.
+func GetSplunkPortGroupServices(cr enterprisev1.MetaObject, spec *enterprisev1.ServiceTypesSpec, instanceType InstanceType, regular *corev1.Service) []*corev1.Service {
+	var services []*corev1.Service
+	for _, group := range servicePortGroups {
+		serviceType := getServicePortGroupType(spec, group.name)
+		if serviceType == "" || serviceType == corev1.ServiceTypeClusterIP {
+			continue
+		}
+
+		var ports []corev1.ServicePort
+		for _, p := range regular.Spec.Ports {
+			if p.Name == group.portName {
+				ports = append(ports, p)
+			}
+		}
+		if len(ports) == 0 {
+			continue
+		}
+
+		service := regular.DeepCopy()
+		service.ObjectMeta.Name = GetSplunkPortGroupServiceName(instanceType, cr.GetIdentifier(), group.name)
+		service.Spec.Type = serviceType
+		service.Spec.ClusterIP = ""
+		service.Spec.Ports = ports
+		for k, v := range spec.Annotations {
+			service.ObjectMeta.Annotations[k] = v
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// GetServiceStatus returns the external endpoint of a Kubernetes Service that exposes a group of Splunk ports
+func GetServiceStatus(service *corev1.Service) enterprisev1.ServiceStatus {
+	status := enterprisev1.ServiceStatus{
+		Name:    service.GetName(),
+		Type:    service.Spec.Type,
+		Address: getIndexerDiscoveryAddress(service),
+	}
+	if len(service.Spec.Ports) > 0 {
+		status.Port = service.Spec.Ports[0].Port
+		status.NodePort = service.Spec.Ports[0].NodePort
+	}
+	return status
+}
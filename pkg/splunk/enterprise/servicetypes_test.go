@@ -0,0 +1,120 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateServiceTypesSpec(t *testing.T) {
+	test := func(spec enterprisev1.ServiceTypesSpec, wantErr bool) {
+		err := validateServiceTypesSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateServiceTypesSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateServiceTypesSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.ServiceTypesSpec{}, false)
+	test(enterprisev1.ServiceTypesSpec{Web: corev1.ServiceTypeClusterIP, HEC: corev1.ServiceTypeNodePort, Management: corev1.ServiceTypeLoadBalancer}, false)
+	test(enterprisev1.ServiceTypesSpec{Web: corev1.ServiceTypeExternalName}, true)
+	test(enterprisev1.ServiceTypesSpec{HEC: "Headless"}, true)
+}
+
+func TestGetSplunkPortGroupServices(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.ServiceTypesSpec{
+		Web:         corev1.ServiceTypeNodePort,
+		HEC:         corev1.ServiceTypeLoadBalancer,
+		Management:  corev1.ServiceTypeClusterIP,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true"},
+	}
+
+	test := func(instanceType InstanceType, want map[string]corev1.ServiceType) {
+		regular := GetSplunkService(&cr, cr.Spec.CommonSpec, instanceType, false)
+		services := GetSplunkPortGroupServices(&cr, &spec, instanceType, regular)
+		if len(services) != len(want) {
+			t.Fatalf("GetSplunkPortGroupServices(%s) returned %d services; want %d", instanceType, len(services), len(want))
+		}
+		for _, service := range services {
+			wantType, ok := want[service.GetName()]
+			if !ok {
+				t.Errorf("GetSplunkPortGroupServices(%s) returned unexpected service %s", instanceType, service.GetName())
+				continue
+			}
+			if service.Spec.Type != wantType {
+				t.Errorf("GetSplunkPortGroupServices(%s) %s type = %s; want %s", instanceType, service.GetName(), service.Spec.Type, wantType)
+			}
+			if len(service.Spec.Ports) != 1 {
+				t.Errorf("GetSplunkPortGroupServices(%s) %s ports = %v; want 1", instanceType, service.GetName(), service.Spec.Ports)
+			}
+			if service.GetAnnotations()["service.beta.kubernetes.io/aws-load-balancer-internal"] != "true" {
+				t.Errorf("GetSplunkPortGroupServices(%s) %s annotations = %v; want aws-load-balancer-internal=true", instanceType, service.GetName(), service.GetAnnotations())
+			}
+			if len(service.GetOwnerReferences()) != 1 {
+				t.Errorf("GetSplunkPortGroupServices(%s) %s owner references = %v; want 1", instanceType, service.GetName(), service.GetOwnerReferences())
+			}
+		}
+	}
+
+	test(SplunkIndexer, map[string]corev1.ServiceType{
+		"splunk-stack1-indexer-web": corev1.ServiceTypeNodePort,
+		"splunk-stack1-indexer-hec": corev1.ServiceTypeLoadBalancer,
+	})
+
+	// cluster masters do not expose HEC
+	test(SplunkClusterMaster, map[string]corev1.ServiceType{
+		"splunk-stack1-cluster-master-web": corev1.ServiceTypeNodePort,
+	})
+
+	// nothing is returned when every group uses ClusterIP
+	spec = enterprisev1.ServiceTypesSpec{}
+	test(SplunkIndexer, map[string]corev1.ServiceType{})
+}
+
+func TestGetServiceStatus(t *testing.T) {
+	test := func(service corev1.Service, want enterprisev1.ServiceStatus) {
+		got := GetServiceStatus(&service)
+		if got != want {
+			t.Errorf("GetServiceStatus(%v) = %v; want %v", service, got, want)
+		}
+	}
+
+	ports := []corev1.ServicePort{{Name: "hec", Port: 8088, NodePort: 31088}}
+	lb := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-indexer-hec"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports},
+	}
+	test(lb, enterprisev1.ServiceStatus{Name: "splunk-stack1-indexer-hec", Type: corev1.ServiceTypeLoadBalancer, Port: 8088, NodePort: 31088})
+	lb.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "hec.elb.amazonaws.com"}}
+	test(lb, enterprisev1.ServiceStatus{Name: "splunk-stack1-indexer-hec", Type: corev1.ServiceTypeLoadBalancer, Port: 8088, NodePort: 31088, Address: "hec.elb.amazonaws.com"})
+
+	np := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-indexer-web"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Name: "splunkweb", Port: 8000, NodePort: 31000}}},
+	}
+	test(np, enterprisev1.ServiceStatus{Name: "splunk-stack1-indexer-web", Type: corev1.ServiceTypeNodePort, Port: 8000, NodePort: 31000})
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// validateUserContainers checks validity and makes default updates to a list of user-defined containers, and returns error if
+// something is wrong. Container names must be unique, and cannot be any of the reserved names used by the operator.
+func validateUserContainers(field string, containers []corev1.Container, reserved ...string) error {
+	names := make(map[string]bool)
+	for _, name := range reserved {
+		names[name] = true
+	}
+	for idx := range containers {
+		container := &containers[idx]
+		if container.Name == "" || container.Image == "" {
+			return fmt.Errorf("%s must have a name and image; name=\"%s\"", field, container.Name)
+		}
+		if names[container.Name] {
+			return fmt.Errorf("%s names must be unique, and cannot be %s; name=\"%s\"", field, strings.Join(reserved, " or "), container.Name)
+		}
+		names[container.Name] = true
+
+		// Explicitly set default values here so we can compare for changes correctly with current statefulset.
+		for p := range container.Ports {
+			if container.Ports[p].Protocol == "" {
+				container.Ports[p].Protocol = corev1.ProtocolTCP
+			}
+		}
+		setEnvDefaults(container.Env)
+	}
+	return nil
+}
+
+// validateSidecarContainers checks validity and makes default updates to sidecar containers and volumes, and returns error if something is wrong.
+func validateSidecarContainers(spec *enterprisev1.CommonSplunkSpec) error {
+	if err := validateUserContainers("SidecarContainers", spec.SidecarContainers, "splunk", "init", veleroFsfreezeContainer); err != nil {
+		return err
+	}
+
+	volumes := map[string]bool{"pvc-etc": true, "pvc-var": true}
+	for _, v := range spec.Volumes {
+		volumes[v.Name] = true
+	}
+	for _, v := range spec.SidecarVolumes {
+		if v.Name == "" || volumes[v.Name] || strings.HasPrefix(v.Name, "mnt-splunk-") {
+			return fmt.Errorf("SidecarVolumes names must be unique, and cannot match volumes used by Splunk Enterprise containers; name=\"%s\"", v.Name)
+		}
+		volumes[v.Name] = true
+	}
+
+	return nil
+}
+
+// addSidecarsToPodTemplate appends sidecar containers and their volumes to a pod template, if configured, including the sidecar
+// used by Velero fsfreeze hooks. This should be called after all other changes have been made to the pod template, since those
+// are applied to every container.
+func addSidecarsToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.CommonSplunkSpec) {
+	addVeleroSidecarToPodTemplate(podTemplateSpec, spec)
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, spec.SidecarVolumes...)
+	for idx := range spec.SidecarContainers {
+		podTemplateSpec.Spec.Containers = append(podTemplateSpec.Spec.Containers, *spec.SidecarContainers[idx].DeepCopy())
+	}
+}
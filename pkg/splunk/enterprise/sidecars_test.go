@@ -0,0 +1,138 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateSidecarContainers(t *testing.T) {
+	test := func(containers []corev1.Container, volumes []corev1.Volume, wantErr bool) {
+		spec := enterprisev1.CommonSplunkSpec{
+			Volumes:           []corev1.Volume{{Name: "defaults"}},
+			SidecarContainers: containers,
+			SidecarVolumes:    volumes,
+		}
+		err := validateSidecarContainers(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateSidecarContainers(%v, %v) returned nil; want error", containers, volumes)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateSidecarContainers(%v, %v) returned %v; want nil", containers, volumes, err)
+		}
+	}
+
+	shipper := corev1.Container{Name: "log-shipper", Image: "fluent/fluent-bit"}
+	test(nil, nil, false)
+	test([]corev1.Container{shipper}, []corev1.Volume{{Name: "shipper-config"}}, false)
+	test([]corev1.Container{{Name: "log-shipper"}}, nil, true)
+	test([]corev1.Container{{Image: "fluent/fluent-bit"}}, nil, true)
+	test([]corev1.Container{{Name: "splunk", Image: "fluent/fluent-bit"}}, nil, true)
+	test([]corev1.Container{shipper, shipper}, nil, true)
+	test(nil, []corev1.Volume{{Name: "defaults"}}, true)
+	test(nil, []corev1.Volume{{Name: "pvc-var"}}, true)
+	test(nil, []corev1.Volume{{Name: "mnt-splunk-secrets"}}, true)
+	test(nil, []corev1.Volume{{Name: "shipper-config"}, {Name: "shipper-config"}}, true)
+
+	// port protocols are set to their defaults
+	spec := enterprisev1.CommonSplunkSpec{
+		SidecarContainers: []corev1.Container{{Name: "log-shipper", Image: "fluent/fluent-bit", Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 2020}}}},
+	}
+	validateSidecarContainers(&spec)
+	if got := spec.SidecarContainers[0].Ports[0].Protocol; got != corev1.ProtocolTCP {
+		t.Errorf("validateSidecarContainers() port protocol = %s; want %s", got, corev1.ProtocolTCP)
+	}
+}
+
+func TestAddSidecarsToPodTemplate(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Replicas = 3
+	cr.Spec.SidecarContainers = []corev1.Container{
+		{
+			Name:         "log-shipper",
+			Image:        "fluent/fluent-bit",
+			VolumeMounts: []corev1.VolumeMount{{Name: "pvc-var", MountPath: "/opt/splunk/var", ReadOnly: true}},
+		},
+	}
+	cr.Spec.SidecarVolumes = []corev1.Volume{{Name: "shipper-config"}}
+	if err := ValidateIndexerClusterSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateIndexerClusterSpec() returned %v; want nil", err)
+	}
+
+	test := func(method string, podTemplateSpec *corev1.PodTemplateSpec) {
+		containers := podTemplateSpec.Spec.Containers
+		if len(containers) != 2 || containers[0].Name != "splunk" || containers[1].Name != "log-shipper" {
+			t.Errorf("%s containers = %v; want splunk and log-shipper", method, containers)
+			return
+		}
+
+		// sidecars are not modified by splunk configuration
+		sidecar := containers[1]
+		if len(sidecar.Env) != 0 || len(sidecar.VolumeMounts) != 1 || sidecar.LivenessProbe != nil || sidecar.Lifecycle != nil {
+			t.Errorf("%s sidecar = %v; want unmodified container", method, sidecar)
+		}
+		volumes := podTemplateSpec.Spec.Volumes
+		if volumes[len(volumes)-1].Name != "shipper-config" {
+			t.Errorf("%s volumes = %v; want shipper-config last", method, volumes)
+		}
+		for _, m := range containers[0].VolumeMounts {
+			if m.Name == "shipper-config" {
+				t.Errorf("%s mounted sidecar volume in splunk container", method)
+			}
+		}
+	}
+
+	ss, err := GetIndexerStatefulSet(&cr)
+	if err != nil {
+		t.Errorf("GetIndexerStatefulSet() returned %v; want nil", err)
+	} else {
+		test("GetIndexerStatefulSet()", &ss.Spec.Template)
+	}
+	ss, err = GetIndexerClusterMasterStatefulSet(&cr)
+	if err != nil {
+		t.Errorf("GetIndexerClusterMasterStatefulSet() returned %v; want nil", err)
+	} else {
+		test("GetIndexerClusterMasterStatefulSet()", &ss.Spec.Template)
+	}
+
+	// sidecars are not changed by spark configuration
+	standalone := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	standalone.Spec.SparkRef.Name = "spark"
+	standalone.Spec.CommonSplunkSpec.SidecarContainers = cr.Spec.SidecarContainers
+	standalone.Spec.CommonSplunkSpec.SidecarVolumes = cr.Spec.SidecarVolumes
+	if err := ValidateStandaloneSpec(&standalone.Spec); err != nil {
+		t.Errorf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+	ss, err = GetStandaloneStatefulSet(&standalone)
+	if err != nil {
+		t.Errorf("GetStandaloneStatefulSet() returned %v; want nil", err)
+	} else {
+		test("GetStandaloneStatefulSet()", &ss.Spec.Template)
+	}
+}
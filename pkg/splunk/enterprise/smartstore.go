@@ -0,0 +1,380 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// pod template annotation used to restart pods when SmartStore configuration changes
+	smartStoreChecksumAnnotation = "enterprise.splunk.com/smartstore-checksum"
+
+	// app directory used to install SmartStore configuration on standalone instances
+	smartStoreStandaloneAppPath = "/opt/splunk/etc/apps/splunk-operator/local"
+
+	// app directory used by cluster masters to push SmartStore configuration to indexer cluster peers
+	smartStoreClusterMasterAppPath = "/opt/splunk/etc/master-apps/splunk-operator/local"
+
+	// directory used to install GCS credential files and CA certificates, relative to /opt/splunk/etc/auth (where Splunk
+	// looks for credential files)
+	smartStoreCredentialsDir = "splunk-operator-smartstore"
+
+	// directory used to mount GCS credential files and CA certificates
+	smartStoreCredentialsMountPath = "/opt/splunk/etc/auth/" + smartStoreCredentialsDir
+
+	// SmartStoreProviderS3 is used for volumes stored using Amazon S3 or a compatible object store
+	SmartStoreProviderS3 = "s3"
+
+	// SmartStoreProviderGCS is used for volumes stored using Google Cloud Storage
+	SmartStoreProviderGCS = "gcs"
+
+	// SmartStoreProviderAzure is used for volumes stored using Azure Blob Storage
+	SmartStoreProviderAzure = "azure"
+
+	// S3URLStylePath is used to access S3 compatible buckets using path-style URLs (https://endpoint/bucket)
+	S3URLStylePath = "path"
+
+	// S3URLStyleVirtual is used to access S3 compatible buckets using virtual-hosted style URLs (https://bucket.endpoint)
+	S3URLStyleVirtual = "virtual"
+
+	// SmartStoreGCSCredentialsKey is the key of a service account key (JSON) in Kubernetes Secrets referenced by gcs volumes
+	SmartStoreGCSCredentialsKey = "gcs_credentials"
+)
+
+// IsSmartStoreConfigured returns true if remote storage volumes have been configured for SmartStore
+func IsSmartStoreConfigured(spec *enterprisev1.SmartStoreSpec) bool {
+	return len(spec.VolList) > 0
+}
+
+// validateSmartStoreSpec checks validity and makes default updates to a SmartStoreSpec, and returns error if something is wrong.
+func validateSmartStoreSpec(spec *enterprisev1.SmartStoreSpec) error {
+	volumes := make(map[string]bool)
+	for i := range spec.VolList {
+		v := &spec.VolList[i]
+		if v.Name == "" {
+			return fmt.Errorf("SmartStore volume name must not be empty")
+		}
+		if volumes[v.Name] {
+			return fmt.Errorf("SmartStore volume names must be unique; value=\"%s\"", v.Name)
+		}
+		if v.Path == "" {
+			return fmt.Errorf("SmartStore volume path must not be empty; volume=\"%s\"", v.Name)
+		}
+		if v.Provider == "" {
+			v.Provider = SmartStoreProviderS3
+		}
+		if v.Provider != SmartStoreProviderS3 && (v.CASecretRef != "" || v.URLStyle != "") {
+			return fmt.Errorf("SmartStore volume caSecretRef and urlStyle are only supported by the \"%s\" provider; volume=\"%s\"", SmartStoreProviderS3, v.Name)
+		}
+		switch v.Provider {
+		case SmartStoreProviderS3:
+			if err := validateS3URLStyle(v.URLStyle); err != nil {
+				return fmt.Errorf("SmartStore volume %v; volume=\"%s\"", err, v.Name)
+			}
+		case SmartStoreProviderGCS:
+			if v.Endpoint != "" {
+				return fmt.Errorf("SmartStore volume endpoint is not supported by the \"%s\" provider; volume=\"%s\"", SmartStoreProviderGCS, v.Name)
+			}
+		case SmartStoreProviderAzure:
+			if v.Endpoint == "" {
+				return fmt.Errorf("SmartStore volume endpoint must not be empty for the \"%s\" provider; volume=\"%s\"", SmartStoreProviderAzure, v.Name)
+			}
+		default:
+			return fmt.Errorf("SmartStore volume provider must be one of \"%s\", \"%s\" or \"%s\"; volume=\"%s\", value=\"%s\"", SmartStoreProviderS3, SmartStoreProviderGCS, SmartStoreProviderAzure, v.Name, v.Provider)
+		}
+		volumes[v.Name] = true
+	}
+
+	if spec.Defaults.VolName != "" && !volumes[spec.Defaults.VolName] {
+		return fmt.Errorf("SmartStore default volume is not defined; volume=\"%s\"", spec.Defaults.VolName)
+	}
+
+	for _, idx := range spec.IndexList {
+		if idx.Name == "" {
+			return fmt.Errorf("SmartStore index name must not be empty")
+		}
+		volName := idx.VolName
+		if volName == "" {
+			volName = spec.Defaults.VolName
+		}
+		if !volumes[volName] {
+			return fmt.Errorf("SmartStore index volume is not defined; index=\"%s\", volume=\"%s\"", idx.Name, volName)
+		}
+	}
+
+	return nil
+}
+
+// validateS3URLStyle returns an error if a URL style used to access S3 compatible buckets is not supported
+func validateS3URLStyle(style string) error {
+	if style != "" && style != S3URLStylePath && style != S3URLStyleVirtual {
+		return fmt.Errorf("urlStyle must be either \"%s\" or \"%s\"; value=\"%s\"", S3URLStylePath, S3URLStyleVirtual, style)
+	}
+	return nil
+}
+
+// getSmartStoreIndexesConf returns the contents of an indexes.conf file generated from a SmartStoreSpec.
+// volumeSecrets provides the Kubernetes Secrets referenced by each volume, indexed by volume name.
+func getSmartStoreIndexesConf(spec *enterprisev1.SmartStoreSpec, instanceType InstanceType, volumeSecrets map[string]*corev1.Secret) string {
+	var sb strings.Builder
+
+	for _, v := range spec.VolList {
+		secret := volumeSecrets[v.Name]
+		switch v.Provider {
+		case SmartStoreProviderGCS:
+			// credentials are installed as a file by addSmartStoreToPodTemplate, since Splunk does not accept them inline
+			fmt.Fprintf(&sb, "[volume:%s]\nstorageType = remote\npath = gs://%s\n", v.Name, v.Path)
+			if secret != nil {
+				fmt.Fprintf(&sb, "remote.gs.credential_file = %s\n", getSmartStoreCredentialFile(v.Name))
+			}
+		case SmartStoreProviderAzure:
+			// the container name is the first element of the path
+			fmt.Fprintf(&sb, "[volume:%s]\nstorageType = remote\npath = azure://%s\n", v.Name, v.Path)
+			fmt.Fprintf(&sb, "remote.azure.endpoint = %s\nremote.azure.container_name = %s\n", v.Endpoint, strings.SplitN(v.Path, "/", 2)[0])
+			if secret != nil {
+				fmt.Fprintf(&sb, "remote.azure.access_key = %s\nremote.azure.secret_key = %s\n", secret.Data["azure_access_key"], secret.Data["azure_secret_key"])
+			}
+		default:
+			fmt.Fprintf(&sb, "[volume:%s]\nstorageType = remote\npath = s3://%s\n", v.Name, v.Path)
+			if v.Endpoint != "" {
+				fmt.Fprintf(&sb, "remote.s3.endpoint = %s\n", v.Endpoint)
+			}
+			if v.URLStyle == S3URLStyleVirtual {
+				sb.WriteString("remote.s3.url_version = v2\n")
+			}
+			if v.CASecretRef != "" {
+				fmt.Fprintf(&sb, "remote.s3.sslVerifyServerCert = true\nremote.s3.sslRootCAPath = %s/%s\n", smartStoreCredentialsMountPath, getSmartStoreCAFile(v.Name))
+			}
+			if secret != nil {
+				fmt.Fprintf(&sb, "remote.s3.access_key = %s\nremote.s3.secret_key = %s\n", secret.Data["s3_access_key"], secret.Data["s3_secret_key"])
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if spec.Defaults.VolName != "" {
+		fmt.Fprintf(&sb, "[default]\nremotePath = volume:%s/$_index_name\n", spec.Defaults.VolName)
+		writeSmartStoreSizeLimits(&sb, spec.Defaults.MaxGlobalDataSizeMB, spec.Defaults.MaxGlobalRawDataSizeMB)
+		sb.WriteString("\n")
+	}
+
+	for _, idx := range spec.IndexList {
+		volName := idx.VolName
+		if volName == "" {
+			volName = spec.Defaults.VolName
+		}
+		remotePath := idx.RemotePath
+		if remotePath == "" {
+			remotePath = "$_index_name"
+		}
+		fmt.Fprintf(&sb, "[%s]\nhomePath = $SPLUNK_DB/%s/db\ncoldPath = $SPLUNK_DB/%s/colddb\nthawedPath = $SPLUNK_DB/%s/thaweddb\nremotePath = volume:%s/%s\n",
+			idx.Name, idx.Name, idx.Name, idx.Name, volName, remotePath)
+		if instanceType == SplunkIndexer {
+			sb.WriteString("repFactor = auto\n")
+		}
+		writeSmartStoreSizeLimits(&sb, idx.MaxGlobalDataSizeMB, idx.MaxGlobalRawDataSizeMB)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// getSmartStoreCredentialFile returns the path of the GCS credential file installed for a volume, relative to /opt/splunk/etc/auth
+func getSmartStoreCredentialFile(volName string) string {
+	return fmt.Sprintf("%s/%s.json", smartStoreCredentialsDir, volName)
+}
+
+// getSmartStoreCAFile returns the name of the file containing CA certificates installed for a volume
+func getSmartStoreCAFile(volName string) string {
+	return volName + "-ca.crt"
+}
+
+// writeSmartStoreSizeLimits appends index size limits to an indexes.conf stanza, if they are defined
+func writeSmartStoreSizeLimits(sb *strings.Builder, maxGlobalDataSizeMB, maxGlobalRawDataSizeMB uint) {
+	if maxGlobalDataSizeMB != 0 {
+		fmt.Fprintf(sb, "maxGlobalDataSizeMB = %d\n", maxGlobalDataSizeMB)
+	}
+	if maxGlobalRawDataSizeMB != 0 {
+		fmt.Fprintf(sb, "maxGlobalRawDataSizeMB = %d\n", maxGlobalRawDataSizeMB)
+	}
+}
+
+// getSmartStoreServerConf returns the contents of a server.conf file with cache manager settings generated from a SmartStoreSpec.
+// It returns an empty string if no cache manager settings have been defined.
+func getSmartStoreServerConf(spec *enterprisev1.SmartStoreSpec) string {
+	var sb strings.Builder
+	conf := spec.CacheManagerConf
+
+	if conf.EvictionPolicy != "" {
+		fmt.Fprintf(&sb, "eviction_policy = %s\n", conf.EvictionPolicy)
+	}
+	settings := []struct {
+		name  string
+		value uint
+	}{
+		{"max_cache_size", conf.MaxCacheSizeMB},
+		{"eviction_padding", conf.EvictionPaddingSizeMB},
+		{"max_concurrent_downloads", conf.MaxConcurrentDownloads},
+		{"max_concurrent_uploads", conf.MaxConcurrentUploads},
+		{"hotlist_recency_secs", conf.HotlistRecencySecs},
+		{"hotlist_bloom_filter_recency_hours", conf.HotlistBloomFilterRecencyHours},
+	}
+	for _, s := range settings {
+		if s.value != 0 {
+			fmt.Fprintf(&sb, "%s = %d\n", s.name, s.value)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "[cachemanager]\n" + sb.String()
+}
+
+// GetSmartStoreSecret returns a Kubernetes Secret containing the indexes.conf and server.conf files generated for SmartStore.
+func GetSmartStoreSecret(cr enterprisev1.MetaObject, spec *enterprisev1.SmartStoreSpec, instanceType InstanceType, volumeSecrets map[string]*corev1.Secret) *corev1.Secret {
+	data := map[string][]byte{
+		"indexes.conf": []byte(getSmartStoreIndexesConf(spec, instanceType, volumeSecrets)),
+	}
+	if serverConf := getSmartStoreServerConf(spec); serverConf != "" {
+		data["server.conf"] = []byte(serverConf)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkSmartStoreName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}
+}
+
+// GetSmartStoreChecksum returns a checksum of the SmartStore configuration files contained in a Kubernetes Secret.
+func GetSmartStoreChecksum(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(secret.Data[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SetSmartStoreChecksum annotates a pod template with a SmartStore configuration checksum, so that pods are recycled when it changes.
+func SetSmartStoreChecksum(podTemplateSpec *corev1.PodTemplateSpec, checksum string) {
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[smartStoreChecksumAnnotation] = checksum
+}
+
+// addSmartStoreToPodTemplate mounts generated SmartStore configuration as an app for standalone instances
+// and cluster masters (which push it to indexer cluster peers). GCS credential files and CA certificates are
+// mounted for all instances, since indexer cluster peers need them to access remote storage.
+func addSmartStoreToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.SmartStoreSpec, instanceType InstanceType) {
+	addSmartStoreCredentialsToPodTemplate(podTemplateSpec, spec)
+
+	var appPath string
+	switch instanceType {
+	case SplunkStandalone:
+		appPath = smartStoreStandaloneAppPath
+	case SplunkClusterMaster:
+		appPath = smartStoreClusterMasterAppPath
+	}
+	if appPath == "" || !IsSmartStoreConfigured(spec) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-smartstore",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  GetSplunkSmartStoreName(cr.GetIdentifier(), instanceType),
+				DefaultMode: &secretVolDefaultMode,
+			},
+		},
+	})
+
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      "mnt-splunk-smartstore",
+			MountPath: appPath,
+		})
+	}
+}
+
+// addSmartStoreCredentialsToPodTemplate mounts the service account keys referenced by gcs volumes as credential files
+// in /opt/splunk/etc/auth, along with the CA certificates referenced by s3 volumes, if any have been configured.
+func addSmartStoreCredentialsToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.SmartStoreSpec) {
+	var sources []corev1.VolumeProjection
+	addSource := func(secretName, key, path string) {
+		sources = append(sources, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Items:                []corev1.KeyToPath{{Key: key, Path: path}},
+			},
+		})
+	}
+	for _, v := range spec.VolList {
+		if v.Provider == SmartStoreProviderGCS && v.SecretRef != "" {
+			addSource(v.SecretRef, SmartStoreGCSCredentialsKey, v.Name+".json")
+		}
+		if v.CASecretRef != "" {
+			addSource(v.CASecretRef, "ca.crt", getSmartStoreCAFile(v.Name))
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	projectedVolDefaultMode := int32(corev1.ProjectedVolumeSourceDefaultMode)
+
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-smartstore-credentials",
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources:     sources,
+				DefaultMode: &projectedVolDefaultMode,
+			},
+		},
+	})
+
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      "mnt-splunk-smartstore-credentials",
+			MountPath: smartStoreCredentialsMountPath,
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateSmartStoreSpec(t *testing.T) {
+	test := func(spec enterprisev1.SmartStoreSpec, wantErr bool) {
+		err := validateSmartStoreSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateSmartStoreSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateSmartStoreSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	vol := enterprisev1.SmartStoreVolumeSpec{Name: "s3vol", Path: "bucket/smartstore"}
+	test(enterprisev1.SmartStoreSpec{}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{vol}}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{vol, vol}}, true)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "s3vol"}}}, true)
+	test(enterprisev1.SmartStoreSpec{
+		VolList:  []enterprisev1.SmartStoreVolumeSpec{vol},
+		Defaults: enterprisev1.SmartStoreDefaultsSpec{VolName: "missing"},
+	}, true)
+	test(enterprisev1.SmartStoreSpec{
+		VolList:   []enterprisev1.SmartStoreVolumeSpec{vol},
+		IndexList: []enterprisev1.SmartStoreIndexSpec{{Name: "main"}},
+	}, true)
+	test(enterprisev1.SmartStoreSpec{
+		VolList:   []enterprisev1.SmartStoreVolumeSpec{vol},
+		IndexList: []enterprisev1.SmartStoreIndexSpec{{Name: "main", VolName: "s3vol"}},
+	}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "gcsvol", Provider: "gcs", Path: "bucket/smartstore"}}}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "gcsvol", Provider: "gcs", Path: "bucket/smartstore", Endpoint: "https://storage.googleapis.com"}}}, true)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "azvol", Provider: "azure", Path: "container/smartstore", Endpoint: "https://myaccount.blob.core.windows.net"}}}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "azvol", Provider: "azure", Path: "container/smartstore"}}}, true)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "minio", Provider: "minio", Path: "bucket/smartstore"}}}, true)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "minio", Path: "bucket/smartstore", CASecretRef: "minio-ca", URLStyle: "virtual"}}}, false)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "minio", Path: "bucket/smartstore", URLStyle: "host"}}}, true)
+	test(enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "gcsvol", Provider: "gcs", Path: "bucket/smartstore", CASecretRef: "minio-ca"}}}, true)
+
+	// provider defaults to s3
+	spec := enterprisev1.SmartStoreSpec{VolList: []enterprisev1.SmartStoreVolumeSpec{vol}}
+	if err := validateSmartStoreSpec(&spec); err != nil || spec.VolList[0].Provider != SmartStoreProviderS3 {
+		t.Errorf("validateSmartStoreSpec() provider = %s, %v; want %s, nil", spec.VolList[0].Provider, err, SmartStoreProviderS3)
+	}
+}
+
+func TestGetSmartStoreSecret(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.SmartStoreSpec{
+		VolList: []enterprisev1.SmartStoreVolumeSpec{
+			{Name: "s3vol", Endpoint: "https://s3-us-west-2.amazonaws.com", Path: "bucket/smartstore", SecretRef: "s3-keys"},
+		},
+		IndexList: []enterprisev1.SmartStoreIndexSpec{
+			{Name: "main", MaxGlobalDataSizeMB: 1000},
+			{Name: "web", RemotePath: "weblogs"},
+		},
+		Defaults: enterprisev1.SmartStoreDefaultsSpec{VolName: "s3vol"},
+		CacheManagerConf: enterprisev1.SmartStoreCacheManagerSpec{
+			EvictionPolicy: "lru",
+			MaxCacheSizeMB: 100000,
+		},
+	}
+	volumeSecrets := map[string]*corev1.Secret{
+		"s3vol": {Data: map[string][]byte{"s3_access_key": []byte("AKIA"), "s3_secret_key": []byte("s3cr3t")}},
+	}
+
+	secret := GetSmartStoreSecret(&cr, &spec, SplunkIndexer, volumeSecrets)
+	if secret.GetName() != "splunk-stack1-indexer-smartstore" || secret.GetNamespace() != "test" {
+		t.Errorf("GetSmartStoreSecret() name = %s/%s; want %s/%s", secret.GetNamespace(), secret.GetName(), "test", "splunk-stack1-indexer-smartstore")
+	}
+
+	wantIndexes := `[volume:s3vol]
+storageType = remote
+path = s3://bucket/smartstore
+remote.s3.endpoint = https://s3-us-west-2.amazonaws.com
+remote.s3.access_key = AKIA
+remote.s3.secret_key = s3cr3t
+
+[default]
+remotePath = volume:s3vol/$_index_name
+
+[main]
+homePath = $SPLUNK_DB/main/db
+coldPath = $SPLUNK_DB/main/colddb
+thawedPath = $SPLUNK_DB/main/thaweddb
+remotePath = volume:s3vol/$_index_name
+repFactor = auto
+maxGlobalDataSizeMB = 1000
+
+[web]
+homePath = $SPLUNK_DB/web/db
+coldPath = $SPLUNK_DB/web/colddb
+thawedPath = $SPLUNK_DB/web/thaweddb
+remotePath = volume:s3vol/weblogs
+repFactor = auto
+
+`
+	if got := string(secret.Data["indexes.conf"]); got != wantIndexes {
+		t.Errorf("GetSmartStoreSecret() indexes.conf = %s;\nwant %s", got, wantIndexes)
+	}
+
+	wantServer := "[cachemanager]\neviction_policy = lru\nmax_cache_size = 100000\n"
+	if got := string(secret.Data["server.conf"]); got != wantServer {
+		t.Errorf("GetSmartStoreSecret() server.conf = %s;\nwant %s", got, wantServer)
+	}
+
+	// checksum should change when configuration changes
+	checksum := GetSmartStoreChecksum(secret)
+	spec.IndexList = spec.IndexList[:1]
+	if GetSmartStoreChecksum(GetSmartStoreSecret(&cr, &spec, SplunkIndexer, volumeSecrets)) == checksum {
+		t.Errorf("GetSmartStoreChecksum() did not change after SmartStore configuration was updated")
+	}
+
+	// standalone indexes do not use repFactor, and server.conf is omitted without cache manager settings
+	spec.CacheManagerConf = enterprisev1.SmartStoreCacheManagerSpec{}
+	secret = GetSmartStoreSecret(&cr, &spec, SplunkStandalone, nil)
+	if _, ok := secret.Data["server.conf"]; ok {
+		t.Errorf("GetSmartStoreSecret() server.conf should not be present without cache manager settings")
+	}
+	wantIndexes = `[volume:s3vol]
+storageType = remote
+path = s3://bucket/smartstore
+remote.s3.endpoint = https://s3-us-west-2.amazonaws.com
+
+[default]
+remotePath = volume:s3vol/$_index_name
+
+[main]
+homePath = $SPLUNK_DB/main/db
+coldPath = $SPLUNK_DB/main/colddb
+thawedPath = $SPLUNK_DB/main/thaweddb
+remotePath = volume:s3vol/$_index_name
+maxGlobalDataSizeMB = 1000
+
+`
+	if got := string(secret.Data["indexes.conf"]); got != wantIndexes {
+		t.Errorf("GetSmartStoreSecret() indexes.conf = %s;\nwant %s", got, wantIndexes)
+	}
+
+	// gcs volumes reference a credential file if they have a secret, and azure volumes include storage account keys
+	spec = enterprisev1.SmartStoreSpec{
+		VolList: []enterprisev1.SmartStoreVolumeSpec{
+			{Name: "minio", Endpoint: "https://minio.test:9000", Path: "bucket/smartstore", CASecretRef: "minio-ca", URLStyle: "virtual"},
+			{Name: "gcsvol", Provider: "gcs", Path: "bucket/smartstore", SecretRef: "gcs-credentials"},
+			{Name: "gcswi", Provider: "gcs", Path: "bucket/wi"},
+			{Name: "azvol", Provider: "azure", Endpoint: "https://myaccount.blob.core.windows.net", Path: "container/smartstore", SecretRef: "azure-keys"},
+			{Name: "azmi", Provider: "azure", Endpoint: "https://myaccount.blob.core.windows.net", Path: "container"},
+		},
+	}
+	volumeSecrets = map[string]*corev1.Secret{
+		"gcsvol": {Data: map[string][]byte{"gcs_credentials": []byte("{}")}},
+		"azvol":  {Data: map[string][]byte{"azure_access_key": []byte("myaccount"), "azure_secret_key": []byte("s3cr3t")}},
+	}
+	secret = GetSmartStoreSecret(&cr, &spec, SplunkIndexer, volumeSecrets)
+	wantIndexes = `[volume:minio]
+storageType = remote
+path = s3://bucket/smartstore
+remote.s3.endpoint = https://minio.test:9000
+remote.s3.url_version = v2
+remote.s3.sslVerifyServerCert = true
+remote.s3.sslRootCAPath = /opt/splunk/etc/auth/splunk-operator-smartstore/minio-ca.crt
+
+[volume:gcsvol]
+storageType = remote
+path = gs://bucket/smartstore
+remote.gs.credential_file = splunk-operator-smartstore/gcsvol.json
+
+[volume:gcswi]
+storageType = remote
+path = gs://bucket/wi
+
+[volume:azvol]
+storageType = remote
+path = azure://container/smartstore
+remote.azure.endpoint = https://myaccount.blob.core.windows.net
+remote.azure.container_name = container
+remote.azure.access_key = myaccount
+remote.azure.secret_key = s3cr3t
+
+[volume:azmi]
+storageType = remote
+path = azure://container
+remote.azure.endpoint = https://myaccount.blob.core.windows.net
+remote.azure.container_name = container
+
+`
+	if got := string(secret.Data["indexes.conf"]); got != wantIndexes {
+		t.Errorf("GetSmartStoreSecret() indexes.conf = %s;\nwant %s", got, wantIndexes)
+	}
+}
+
+func TestAddSmartStoreToPodTemplate(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.SmartStoreSpec{
+		VolList: []enterprisev1.SmartStoreVolumeSpec{{Name: "s3vol", Path: "bucket/smartstore"}},
+	}
+
+	test := func(instanceType InstanceType, wantMountPath string) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		addSmartStoreToPodTemplate(&podTemplateSpec, &cr, &spec, instanceType)
+		mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+		if wantMountPath == "" {
+			if len(mounts) != 0 || len(podTemplateSpec.Spec.Volumes) != 0 {
+				t.Errorf("addSmartStoreToPodTemplate(%s) added volumes; want none", instanceType)
+			}
+			return
+		}
+		if len(mounts) != 1 || mounts[0].MountPath != wantMountPath {
+			t.Errorf("addSmartStoreToPodTemplate(%s) mounts = %v; want %s", instanceType, mounts, wantMountPath)
+		}
+		if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Secret.SecretName != GetSplunkSmartStoreName("stack1", instanceType) {
+			t.Errorf("addSmartStoreToPodTemplate(%s) volumes = %v; want secret %s", instanceType, podTemplateSpec.Spec.Volumes, GetSplunkSmartStoreName("stack1", instanceType))
+		}
+	}
+
+	test(SplunkStandalone, "/opt/splunk/etc/apps/splunk-operator/local")
+	test(SplunkClusterMaster, "/opt/splunk/etc/master-apps/splunk-operator/local")
+	test(SplunkIndexer, "")
+	test(SplunkSearchHead, "")
+}
+
+func TestAddSmartStoreCredentialsToPodTemplate(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.SmartStoreSpec{
+		VolList: []enterprisev1.SmartStoreVolumeSpec{
+			{Name: "s3vol", Provider: "s3", Path: "bucket/smartstore", SecretRef: "s3-keys", CASecretRef: "minio-ca"},
+			{Name: "gcsvol", Provider: "gcs", Path: "bucket/smartstore", SecretRef: "gcs-credentials"},
+			{Name: "gcswi", Provider: "gcs", Path: "bucket/wi"},
+		},
+	}
+
+	// credentials are mounted on indexer cluster peers, which access remote storage
+	podTemplateSpec := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "splunk"}},
+		},
+	}
+	addSmartStoreToPodTemplate(&podTemplateSpec, &cr, &spec, SplunkIndexer)
+	mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/opt/splunk/etc/auth/splunk-operator-smartstore" {
+		t.Errorf("addSmartStoreToPodTemplate() mounts = %v; want %s", mounts, "/opt/splunk/etc/auth/splunk-operator-smartstore")
+	}
+	if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Projected == nil {
+		t.Fatalf("addSmartStoreToPodTemplate() volumes = %v; want projected volume", podTemplateSpec.Spec.Volumes)
+	}
+	want := []corev1.VolumeProjection{{
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "minio-ca"},
+			Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "s3vol-ca.crt"}},
+		},
+	}, {
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "gcs-credentials"},
+			Items:                []corev1.KeyToPath{{Key: "gcs_credentials", Path: "gcsvol.json"}},
+		},
+	}}
+	if got := podTemplateSpec.Spec.Volumes[0].Projected.Sources; !reflect.DeepEqual(got, want) {
+		t.Errorf("addSmartStoreToPodTemplate() sources = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// pod template annotation used to restart pods when certificates are renewed
+	tlsChecksumAnnotation = "enterprise.splunk.com/tls-checksum"
+
+	// default kind of cert-manager issuer used to request certificates
+	defaultTLSIssuerKind = "Issuer"
+
+	// directory used to mount certificates and TLS configuration
+	tlsMountPath = "/mnt/splunk-tls"
+
+	// default.yml file used to have splunk-ansible configure splunkd and Splunk Web to use certificates
+	tlsDefaultsPath = tlsMountPath + "/default.yml"
+
+	// port used by indexer cluster peers to receive data from forwarders using TLS
+	tlsReceivingPort = 9998
+)
+
+// CertificateGroupVersionKind is the type of cert-manager Certificate resources used to request certificates
+var CertificateGroupVersionKind = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1alpha2",
+	Kind:    "Certificate",
+}
+
+// IsTLSConfigured returns true if a cert-manager issuer has been configured to request certificates
+func IsTLSConfigured(spec *enterprisev1.TLSSpec) bool {
+	return spec.IssuerRef.Name != ""
+}
+
+// validateTLSSpec checks validity and makes default updates to a TLSSpec, and returns error if something is wrong.
+func validateTLSSpec(spec *enterprisev1.TLSSpec) error {
+	if !IsTLSConfigured(spec) {
+		return nil
+	}
+
+	if spec.IssuerRef.Kind == "" {
+		spec.IssuerRef.Kind = defaultTLSIssuerKind
+	}
+	if spec.IssuerRef.Kind != "Issuer" && spec.IssuerRef.Kind != "ClusterIssuer" {
+		return fmt.Errorf("TLS issuer kind must be Issuer or ClusterIssuer; value=\"%s\"", spec.IssuerRef.Kind)
+	}
+
+	if spec.Duration != "" {
+		if _, err := time.ParseDuration(spec.Duration); err != nil {
+			return fmt.Errorf("TLS certificate duration is invalid; value=\"%s\": %v", spec.Duration, err)
+		}
+	}
+
+	return nil
+}
+
+// GetSplunkDNSNames returns the DNS names used to reach the services and pods of each instance type for a SplunkEnterprise resource.
+func GetSplunkDNSNames(namespace string, identifier string, instanceTypes ...InstanceType) []string {
+	dnsNames := []string{}
+	for _, instanceType := range instanceTypes {
+		serviceName := GetSplunkServiceName(instanceType, identifier, false)
+		dnsNames = append(dnsNames,
+			serviceName,
+			resources.GetServiceFQDN(namespace, serviceName),
+			"*."+resources.GetServiceFQDN(namespace, GetSplunkServiceName(instanceType, identifier, true)))
+	}
+	return dnsNames
+}
+
+// GetSplunkCertificate returns a cert-manager Certificate used to request a certificate for the given DNS names.
+func GetSplunkCertificate(cr enterprisev1.MetaObject, spec *enterprisev1.TLSSpec, instanceType InstanceType, dnsNames []string) *unstructured.Unstructured {
+	name := GetSplunkCertificateName(cr.GetIdentifier(), instanceType)
+
+	// unstructured content must use the same types as decoded JSON, so that it can be compared with existing Certificates
+	names := []interface{}{}
+	for _, n := range append(dnsNames, spec.DNSNames...) {
+		names = append(names, n)
+	}
+	certSpec := map[string]interface{}{
+		"secretName": name,
+		"dnsNames":   names,
+		"issuerRef": map[string]interface{}{
+			"name":  spec.IssuerRef.Name,
+			"kind":  spec.IssuerRef.Kind,
+			"group": CertificateGroupVersionKind.Group,
+		},
+	}
+	if spec.Duration != "" {
+		certSpec["duration"] = spec.Duration
+	}
+
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{"spec": certSpec}}
+	certificate.SetGroupVersionKind(CertificateGroupVersionKind)
+	certificate.SetName(name)
+	certificate.SetNamespace(cr.GetNamespace())
+	return certificate
+}
+
+// getTLSDefaults returns the contents of a default.yml file used to configure splunkd and Splunk Web to use certificates.
+// Indexer cluster peers are also configured to receive data from forwarders using TLS.
+func getTLSDefaults(instanceType InstanceType, hasCA bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "splunk:\n  http_enableSSL: 1\n  http_enableSSL_cert: %s/tls.crt\n  http_enableSSL_privKey: %s/tls.key\n", tlsMountPath, tlsMountPath)
+	fmt.Fprintf(&sb, "  conf:\n    server:\n      directory: %s/etc/system/local\n      content:\n        sslConfig:\n          serverCert: %s/server.pem\n", GetSplunkHome(instanceType), tlsMountPath)
+	if hasCA {
+		fmt.Fprintf(&sb, "          sslRootCAPath: %s/ca.crt\n", tlsMountPath)
+	}
+	if instanceType == SplunkIndexer {
+		fmt.Fprintf(&sb, "    inputs:\n      directory: %s/etc/system/local\n      content:\n        splunktcp-ssl:%d:\n          disabled: 0\n        SSL:\n          serverCert: %s/server.pem\n", GetSplunkHome(instanceType), tlsReceivingPort, tlsMountPath)
+		if hasCA {
+			fmt.Fprintf(&sb, "          sslRootCAPath: %s/ca.crt\n", tlsMountPath)
+		}
+	}
+	return sb.String()
+}
+
+// GetTLSSecret returns a Kubernetes Secret containing certificates issued by cert-manager, in the files used by Splunk Enterprise.
+// It returns error if the certificate has not been issued yet.
+func GetTLSSecret(cr enterprisev1.MetaObject, instanceType InstanceType, certSecret *corev1.Secret) (*corev1.Secret, error) {
+	cert, key, ca := certSecret.Data["tls.crt"], certSecret.Data["tls.key"], certSecret.Data["ca.crt"]
+	if len(cert) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("Certificate has not been issued; secret=\"%s\"", certSecret.GetName())
+	}
+
+	// splunkd requires the server certificate and its private key in a single file
+	serverPem := append(append([]byte{}, cert...), '\n')
+	serverPem = append(serverPem, key...)
+
+	data := map[string][]byte{
+		"tls.crt":     cert,
+		"tls.key":     key,
+		"server.pem":  serverPem,
+		"default.yml": []byte(getTLSDefaults(instanceType, len(ca) > 0)),
+	}
+	if len(ca) > 0 {
+		data["ca.crt"] = ca
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkTLSName(cr.GetIdentifier(), instanceType),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}, nil
+}
+
+// GetTLSChecksum returns a checksum of the certificate files contained in a Kubernetes Secret.
+func GetTLSChecksum(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(secret.Data[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SetTLSChecksum annotates a pod template with a checksum of the certificates in a TLS Secret, so that pods are recycled
+// when certificates are renewed. It does nothing if secret is nil (TLS is not configured).
+func SetTLSChecksum(podTemplateSpec *corev1.PodTemplateSpec, secret *corev1.Secret) {
+	if secret == nil {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[tlsChecksumAnnotation] = GetTLSChecksum(secret)
+}
+
+// addTLSToPodTemplate mounts certificates and TLS configuration for all Splunk Enterprise instances, if configured.
+func addTLSToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.TLSSpec, instanceType InstanceType) {
+	if !IsTLSConfigured(spec) {
+		return
+	}
+
+	// Explicitly set the default value here so we can compare for changes correctly with current statefulset.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	addSplunkVolumeToTemplate(podTemplateSpec, "tls", corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{
+			SecretName:  GetSplunkTLSName(cr.GetIdentifier(), instanceType),
+			DefaultMode: &secretVolDefaultMode,
+		},
+	})
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateTLSSpec(t *testing.T) {
+	test := func(spec enterprisev1.TLSSpec, wantErr bool) {
+		err := validateTLSSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateTLSSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateTLSSpec(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.TLSSpec{}, false)
+	test(enterprisev1.TLSSpec{IssuerRef: corev1.ObjectReference{Name: "ca-issuer"}}, false)
+	test(enterprisev1.TLSSpec{IssuerRef: corev1.ObjectReference{Name: "letsencrypt", Kind: "ClusterIssuer"}, Duration: "2160h"}, false)
+	test(enterprisev1.TLSSpec{IssuerRef: corev1.ObjectReference{Name: "ca-issuer", Kind: "Secret"}}, true)
+	test(enterprisev1.TLSSpec{IssuerRef: corev1.ObjectReference{Name: "ca-issuer"}, Duration: "90d"}, true)
+
+	// defaults are only set when an issuer is configured
+	spec := enterprisev1.TLSSpec{}
+	validateTLSSpec(&spec)
+	if spec.IssuerRef.Kind != "" {
+		t.Errorf("validateTLSSpec() set issuer kind without an issuer: %v", spec)
+	}
+	spec.IssuerRef.Name = "ca-issuer"
+	validateTLSSpec(&spec)
+	if spec.IssuerRef.Kind != "Issuer" {
+		t.Errorf("validateTLSSpec() issuer kind = %s; want Issuer", spec.IssuerRef.Kind)
+	}
+}
+
+func TestGetSplunkCertificate(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.TLSSpec{
+		IssuerRef: corev1.ObjectReference{Name: "ca-issuer", Kind: "Issuer"},
+		Duration:  "2160h",
+		DNSNames:  []string{"splunk.example.com"},
+	}
+	dnsNames := GetSplunkDNSNames("test", "stack1", SplunkSearchHead, SplunkDeployer)
+	wantDNSNames := []string{
+		"splunk-stack1-search-head-service",
+		"splunk-stack1-search-head-service.test.svc.cluster.local",
+		"*.splunk-stack1-search-head-headless.test.svc.cluster.local",
+		"splunk-stack1-deployer-service",
+		"splunk-stack1-deployer-service.test.svc.cluster.local",
+		"*.splunk-stack1-deployer-headless.test.svc.cluster.local",
+	}
+	if !reflect.DeepEqual(dnsNames, wantDNSNames) {
+		t.Errorf("GetSplunkDNSNames() = %v; want %v", dnsNames, wantDNSNames)
+	}
+
+	certificate := GetSplunkCertificate(&cr, &spec, SplunkSearchHead, dnsNames)
+	if certificate.GetName() != "splunk-stack1-search-head-certificate" || certificate.GetNamespace() != "test" {
+		t.Errorf("GetSplunkCertificate() name = %s/%s; want %s/%s", certificate.GetNamespace(), certificate.GetName(), "test", "splunk-stack1-search-head-certificate")
+	}
+	if certificate.GetAPIVersion() != "cert-manager.io/v1alpha2" || certificate.GetKind() != "Certificate" {
+		t.Errorf("GetSplunkCertificate() type = %s %s; want cert-manager.io/v1alpha2 Certificate", certificate.GetAPIVersion(), certificate.GetKind())
+	}
+	certSpec := certificate.Object["spec"].(map[string]interface{})
+	if certSpec["secretName"] != "splunk-stack1-search-head-certificate" || certSpec["duration"] != "2160h" {
+		t.Errorf("GetSplunkCertificate() spec = %v; want secretName splunk-stack1-search-head-certificate and duration 2160h", certSpec)
+	}
+	names := certSpec["dnsNames"].([]interface{})
+	if len(names) != 7 || names[6] != "splunk.example.com" {
+		t.Errorf("GetSplunkCertificate() dnsNames = %v; want %d names ending with splunk.example.com", names, 7)
+	}
+	wantIssuerRef := map[string]interface{}{"name": "ca-issuer", "kind": "Issuer", "group": "cert-manager.io"}
+	if !reflect.DeepEqual(certSpec["issuerRef"], wantIssuerRef) {
+		t.Errorf("GetSplunkCertificate() issuerRef = %v; want %v", certSpec["issuerRef"], wantIssuerRef)
+	}
+}
+
+func TestGetTLSSecret(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	certSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-indexer-certificate",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{},
+	}
+
+	// certificate has not been issued yet
+	if _, err := GetTLSSecret(&cr, SplunkClusterMaster, &certSecret); err == nil {
+		t.Errorf("GetTLSSecret() returned nil; want error for certificate that has not been issued")
+	}
+
+	certSecret.Data["tls.crt"] = []byte("cert")
+	certSecret.Data["tls.key"] = []byte("key")
+	secret, err := GetTLSSecret(&cr, SplunkClusterMaster, &certSecret)
+	if err != nil {
+		t.Fatalf("GetTLSSecret() returned %v; want nil", err)
+	}
+	if secret.GetName() != "splunk-stack1-indexer-tls" || secret.GetNamespace() != "test" {
+		t.Errorf("GetTLSSecret() name = %s/%s; want %s/%s", secret.GetNamespace(), secret.GetName(), "test", "splunk-stack1-indexer-tls")
+	}
+	if got := string(secret.Data["server.pem"]); got != "cert\nkey" {
+		t.Errorf("GetTLSSecret() server.pem = %s; want %s", got, "cert\nkey")
+	}
+	want := `splunk:
+  http_enableSSL: 1
+  http_enableSSL_cert: /mnt/splunk-tls/tls.crt
+  http_enableSSL_privKey: /mnt/splunk-tls/tls.key
+  conf:
+    server:
+      directory: /opt/splunk/etc/system/local
+      content:
+        sslConfig:
+          serverCert: /mnt/splunk-tls/server.pem
+`
+	if got := string(secret.Data["default.yml"]); got != want {
+		t.Errorf("GetTLSSecret() default.yml = %s;\nwant %s", got, want)
+	}
+	if _, ok := secret.Data["ca.crt"]; ok {
+		t.Errorf("GetTLSSecret() included ca.crt for certificate without a CA")
+	}
+
+	// root CA is configured when provided by the issuer
+	certSecret.Data["ca.crt"] = []byte("ca")
+	secret, _ = GetTLSSecret(&cr, SplunkClusterMaster, &certSecret)
+	if !strings.HasSuffix(string(secret.Data["default.yml"]), "          sslRootCAPath: /mnt/splunk-tls/ca.crt\n") {
+		t.Errorf("GetTLSSecret() default.yml = %s; want sslRootCAPath", secret.Data["default.yml"])
+	}
+
+	// indexer cluster peers also receive data from forwarders using TLS
+	secret, _ = GetTLSSecret(&cr, SplunkIndexer, &certSecret)
+	wantInputs := `    inputs:
+      directory: /opt/splunk/etc/system/local
+      content:
+        splunktcp-ssl:9998:
+          disabled: 0
+        SSL:
+          serverCert: /mnt/splunk-tls/server.pem
+          sslRootCAPath: /mnt/splunk-tls/ca.crt
+`
+	if !strings.HasSuffix(string(secret.Data["default.yml"]), wantInputs) {
+		t.Errorf("GetTLSSecret() default.yml = %s; want %s", secret.Data["default.yml"], wantInputs)
+	}
+
+	// checksum should change when certificates are renewed
+	podTemplateSpec := corev1.PodTemplateSpec{}
+	SetTLSChecksum(&podTemplateSpec, nil)
+	if len(podTemplateSpec.Annotations) != 0 {
+		t.Errorf("SetTLSChecksum(nil) annotations = %v; want none", podTemplateSpec.Annotations)
+	}
+	SetTLSChecksum(&podTemplateSpec, secret)
+	checksum := podTemplateSpec.Annotations["enterprise.splunk.com/tls-checksum"]
+	if checksum != GetTLSChecksum(secret) {
+		t.Errorf("SetTLSChecksum() checksum = %s; want %s", checksum, GetTLSChecksum(secret))
+	}
+	certSecret.Data["tls.crt"] = []byte("renewed")
+	secret, _ = GetTLSSecret(&cr, SplunkClusterMaster, &certSecret)
+	if GetTLSChecksum(secret) == checksum {
+		t.Errorf("GetTLSChecksum() did not change after certificate was renewed")
+	}
+}
+
+func TestAddTLSToPodTemplate(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	podTemplateSpec := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "splunk"}},
+		},
+	}
+
+	// nothing to do without an issuer
+	addTLSToPodTemplate(&podTemplateSpec, &cr, &enterprisev1.TLSSpec{}, SplunkStandalone)
+	if len(podTemplateSpec.Spec.Volumes) != 0 {
+		t.Errorf("addTLSToPodTemplate() added volumes without an issuer: %v", podTemplateSpec.Spec.Volumes)
+	}
+
+	spec := enterprisev1.TLSSpec{IssuerRef: corev1.ObjectReference{Name: "ca-issuer"}}
+	addTLSToPodTemplate(&podTemplateSpec, &cr, &spec, SplunkStandalone)
+	mounts := podTemplateSpec.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/mnt/splunk-tls" {
+		t.Errorf("addTLSToPodTemplate() mounts = %v; want %s", mounts, "/mnt/splunk-tls")
+	}
+	if len(podTemplateSpec.Spec.Volumes) != 1 || podTemplateSpec.Spec.Volumes[0].Secret.SecretName != "splunk-stack1-standalone-tls" {
+		t.Errorf("addTLSToPodTemplate() volumes = %v; want secret %s", podTemplateSpec.Spec.Volumes, "splunk-stack1-standalone-tls")
+	}
+}
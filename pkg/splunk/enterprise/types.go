@@ -35,6 +35,15 @@ const (
 
 	// SplunkLicenseMaster controls one or more license slaves
 	SplunkLicenseMaster InstanceType = "license-master"
+
+	// SplunkMonitoringConsole is a search head used to monitor the health of other Splunk Enterprise instances, see https://docs.splunk.com/Documentation/Splunk/latest/DMC/DMCoverview
+	SplunkMonitoringConsole InstanceType = "monitoring-console"
+
+	// SplunkUniversalForwarder is a Splunk Universal Forwarder instance used to collect and forward data to indexers
+	SplunkUniversalForwarder InstanceType = "universal-forwarder"
+
+	// SplunkDeploymentServer distributes apps to forwarders and other deployment clients, see https://docs.splunk.com/Documentation/Splunk/latest/Updating/Aboutdeploymentserver
+	SplunkDeploymentServer InstanceType = "deployment-server"
 )
 
 // ToString returns a string for a given InstanceType
@@ -58,6 +67,12 @@ func (instanceType InstanceType) ToRole() string {
 		role = "splunk_deployer"
 	case SplunkLicenseMaster:
 		role = "splunk_license_master"
+	case SplunkMonitoringConsole:
+		role = "splunk_monitor"
+	case SplunkUniversalForwarder:
+		role = "splunk_universal_forwarder"
+	case SplunkDeploymentServer:
+		role = "splunk_deployment_server"
 	}
 	return role
 }
@@ -78,6 +93,12 @@ func (instanceType InstanceType) ToKind() string {
 		kind = "search-head"
 	case SplunkLicenseMaster:
 		kind = "license-master"
+	case SplunkMonitoringConsole:
+		kind = "monitoring-console"
+	case SplunkUniversalForwarder:
+		kind = "universal-forwarder"
+	case SplunkDeploymentServer:
+		kind = "deployment-server"
 	}
 	return kind
 }
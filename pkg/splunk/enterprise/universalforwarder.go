@@ -0,0 +1,336 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// UniversalForwarderDaemonSet is used to run one universal forwarder on each node
+	UniversalForwarderDaemonSet = "DaemonSet"
+
+	// UniversalForwarderDeployment is used to run a fixed number of universal forwarders
+	UniversalForwarderDeployment = "Deployment"
+
+	// pod template annotation used to restart pods when outputs configuration changes
+	outputsChecksumAnnotation = "enterprise.splunk.com/outputs-checksum"
+
+	// app directory used to install generated outputs configuration on universal forwarders
+	outputsAppPath = "/opt/splunkforwarder/etc/apps/splunk-operator-outputs/local"
+)
+
+// ValidateUniversalForwarderSpec checks validity and makes default updates to a UniversalForwarderSpec, and returns error if something is wrong.
+func ValidateUniversalForwarderSpec(spec *enterprisev1.UniversalForwarderSpec) error {
+	// if not specified via spec or env, image defaults to splunk/universalforwarder
+	spec.Image = GetSplunkForwarderImage(spec.Image)
+
+	switch spec.WorkloadType {
+	case "":
+		spec.WorkloadType = UniversalForwarderDaemonSet
+	case UniversalForwarderDaemonSet, UniversalForwarderDeployment:
+	default:
+		return fmt.Errorf("WorkloadType must be either \"%s\" or \"%s\"; value=\"%s\"", UniversalForwarderDaemonSet, UniversalForwarderDeployment, spec.WorkloadType)
+	}
+
+	if spec.Replicas < 0 {
+		return fmt.Errorf("Replicas must not be negative; value=%d", spec.Replicas)
+	}
+	if spec.WorkloadType == UniversalForwarderDeployment && spec.Replicas == 0 {
+		spec.Replicas = 1
+	}
+
+	if spec.IndexerClusterRef.Name == "" {
+		return fmt.Errorf("IndexerClusterRef is required for universal forwarders")
+	}
+
+	// universal forwarders do not store indexes or install apps, and do not run Splunk Web
+	if IsSmartStoreConfigured(&spec.SmartStore) {
+		return fmt.Errorf("SmartStore is not supported for universal forwarders")
+	}
+	if IsAppRepoConfigured(&spec.AppRepo) {
+		return fmt.Errorf("AppRepo is not supported for universal forwarders")
+	}
+	if IsIngressConfigured(&spec.Ingress) {
+		return fmt.Errorf("Ingress is not supported for universal forwarders")
+	}
+	if spec.SecretRotationInterval != "" {
+		return fmt.Errorf("SecretRotationInterval is not supported for universal forwarders; value=\"%s\"", spec.SecretRotationInterval)
+	}
+
+	return validateCommonSplunkSpec(&spec.CommonSplunkSpec)
+}
+
+// getForwarderMasterURI returns the management URI of the cluster master of an indexer cluster, which is used by forwarders
+// for indexer discovery. A ClusterMaster referenced using clusterMasterRef takes precedence over the indexer cluster's own master.
+func getForwarderMasterURI(idxc *enterprisev1.IndexerCluster) string {
+	name, namespace := idxc.GetIdentifier(), idxc.GetNamespace()
+	if ref := idxc.Spec.ClusterMasterRef; ref.Name != "" {
+		name = ref.Name
+		if ref.Namespace != "" {
+			namespace = ref.Namespace
+		}
+	}
+	service := resources.GetServiceFQDN(namespace, GetSplunkServiceName(SplunkClusterMaster, name, false))
+	return fmt.Sprintf("https://%s:%d", service, getSplunkPorts(SplunkClusterMaster)["splunkd"])
+}
+
+// getForwarderServers returns the receiving endpoints ("host:port") of all peers of an indexer cluster, including each site
+// of a multisite cluster. Peers receive data using TLS on a separate port, if enabled.
+func getForwarderServers(idxc *enterprisev1.IndexerCluster, useTLS bool) []string {
+	port := getSplunkPorts(SplunkIndexer)["s2s"]
+	if useTLS {
+		port = tlsReceivingPort
+	}
+
+	var hosts []string
+	if len(idxc.Spec.Sites) == 0 {
+		hosts = strings.Split(GetSplunkStatefulsetUrls(idxc.GetNamespace(), SplunkIndexer, idxc.GetIdentifier(), idxc.Spec.Replicas, false), ",")
+	}
+	for _, site := range idxc.Spec.Sites {
+		siteIdentifier := GetSplunkSiteIdentifier(idxc.GetIdentifier(), site.Name)
+		hosts = append(hosts, strings.Split(GetSplunkStatefulsetUrls(idxc.GetNamespace(), SplunkIndexer, siteIdentifier, site.Replicas, false), ",")...)
+	}
+
+	servers := make([]string, len(hosts))
+	for i, host := range hosts {
+		servers[i] = fmt.Sprintf("%s:%d", host, port)
+	}
+	return servers
+}
+
+// getForwarderOutputsConf returns the contents of an outputs.conf file used by universal forwarders to send data to the
+// peers of an indexer cluster. Forwarders use indexer discovery if a pass4SymmKey is provided, or a static list of peers.
+func getForwarderOutputsConf(idxc *enterprisev1.IndexerCluster, pass4SymmKey []byte, useTLS bool, hasCA bool) string {
+	var sb strings.Builder
+	group := idxc.GetIdentifier()
+
+	fmt.Fprintf(&sb, "[tcpout]\ndefaultGroup = %s\n\n[tcpout:%s]\n", group, group)
+	if len(pass4SymmKey) > 0 {
+		fmt.Fprintf(&sb, "indexerDiscovery = %s\n", group)
+	} else {
+		fmt.Fprintf(&sb, "server = %s\n", strings.Join(getForwarderServers(idxc, useTLS), ","))
+	}
+	if useTLS {
+		sb.WriteString("useSSL = true\n")
+		if hasCA {
+			fmt.Fprintf(&sb, "sslRootCAPath = %s/ca.crt\nsslVerifyServerCert = true\n", outputsAppPath)
+		}
+	}
+
+	if len(pass4SymmKey) > 0 {
+		fmt.Fprintf(&sb, "\n[indexer_discovery:%s]\nmaster_uri = %s\npass4SymmKey = %s\n", group, getForwarderMasterURI(idxc), pass4SymmKey)
+	}
+	return sb.String()
+}
+
+// GetUniversalForwarderOutputsSecret returns a Kubernetes Secret containing the outputs.conf file generated for universal
+// forwarders to send data to the peers of an indexer cluster. Indexer discovery is used if discovery (the indexer discovery
+// configuration of the cluster master) is not nil, and TLS is used if tls (the TLS configuration of the indexer cluster)
+// is not nil, along with the CA certificate that issued the certificates of the peers, if there is one.
+func GetUniversalForwarderOutputsSecret(cr *enterprisev1.UniversalForwarder, idxc *enterprisev1.IndexerCluster, discovery *corev1.Secret, tls *corev1.Secret) *corev1.Secret {
+	var pass4SymmKey, ca []byte
+	if discovery != nil {
+		pass4SymmKey = discovery.Data["pass4SymmKey"]
+	}
+	if tls != nil {
+		ca = tls.Data["ca.crt"]
+	}
+
+	data := map[string][]byte{
+		"outputs.conf": []byte(getForwarderOutputsConf(idxc, pass4SymmKey, tls != nil, len(ca) > 0)),
+	}
+	if len(ca) > 0 {
+		data["ca.crt"] = ca
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkOutputsName(cr.GetIdentifier(), SplunkUniversalForwarder),
+			Namespace: cr.GetNamespace(),
+		},
+		Data: data,
+	}
+}
+
+// GetOutputsChecksum returns a checksum of the outputs configuration contained in a Kubernetes Secret.
+func GetOutputsChecksum(secret *corev1.Secret) string {
+	return GetTLSChecksum(secret)
+}
+
+// SetOutputsChecksum annotates a pod template with an outputs configuration checksum, so that pods are recycled when it
+// changes. Nothing is done if secret is nil.
+func SetOutputsChecksum(podTemplateSpec *corev1.PodTemplateSpec, secret *corev1.Secret) {
+	if secret == nil {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[outputsChecksumAnnotation] = GetOutputsChecksum(secret)
+}
+
+// getUniversalForwarderPodTemplate returns a Kubernetes PodTemplateSpec for universal forwarders. Forwarders do not keep any
+// state that needs to survive restarts, so ephemeral volumes are always used for etc and var.
+func getUniversalForwarderPodTemplate(cr *enterprisev1.UniversalForwarder, labels map[string]string) corev1.PodTemplateSpec {
+	ports := resources.SortContainerPorts(getSplunkContainerPorts(SplunkUniversalForwarder)) // note that port order is important for tests
+	home := GetSplunkHome(SplunkUniversalForwarder)
+
+	// affinity and topology spread constraints are only used to place the pods of deployments; daemonsets run on every node
+	// allowed by the user's affinity
+	affinity := cr.Spec.Affinity.DeepCopy()
+	var topologySpreadConstraints []corev1.TopologySpreadConstraint
+	if cr.Spec.WorkloadType == UniversalForwarderDeployment {
+		affinity = resources.GetPodAffinity(&cr.Spec.CommonSpec, cr.GetIdentifier(), SplunkUniversalForwarder.ToString())
+		topologySpreadConstraints = resources.GetTopologySpreadConstraints(&cr.Spec.CommonSpec, cr.GetIdentifier(), SplunkUniversalForwarder.ToString())
+	}
+
+	podTemplateSpec := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      labels,
+			Annotations: resources.GetIstioAnnotations(ports),
+		},
+		Spec: corev1.PodSpec{
+			Affinity:                  affinity,
+			TopologySpreadConstraints: topologySpreadConstraints,
+			SchedulerName:             cr.Spec.SchedulerName,
+			PriorityClassName:         cr.Spec.PriorityClassName,
+			ServiceAccountName:        cr.Spec.ServiceAccountName,
+			ImagePullSecrets:          cr.Spec.ImagePullSecrets,
+			Containers: []corev1.Container{
+				{
+					Image:           cr.Spec.Image,
+					ImagePullPolicy: corev1.PullPolicy(cr.Spec.ImagePullPolicy),
+					Name:            "splunk",
+					Ports:           ports,
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "pvc-etc", MountPath: home + "/etc"},
+						{Name: "pvc-var", MountPath: home + "/var"},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "pvc-etc", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: "pvc-var", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+
+	// append labels and annotations from parent
+	resources.AppendParentMeta(podTemplateSpec.GetObjectMeta(), cr.GetObjectMeta())
+
+	// update pod template with common splunk pod config
+	updateSplunkPodTemplateWithConfig(&podTemplateSpec, cr, &cr.Spec.CommonSplunkSpec, SplunkUniversalForwarder, nil)
+
+	// Explicitly set the default value here so we can compare for changes correctly with current workload.
+	secretVolDefaultMode := int32(corev1.SecretVolumeSourceDefaultMode)
+
+	// install generated outputs configuration as an app
+	podTemplateSpec.Spec.Volumes = append(podTemplateSpec.Spec.Volumes, corev1.Volume{
+		Name: "mnt-splunk-outputs",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  GetSplunkOutputsName(cr.GetIdentifier(), SplunkUniversalForwarder),
+				DefaultMode: &secretVolDefaultMode,
+			},
+		},
+	})
+	for idx := range podTemplateSpec.Spec.Containers {
+		containerSpec := &podTemplateSpec.Spec.Containers[idx]
+		containerSpec.VolumeMounts = append(containerSpec.VolumeMounts, corev1.VolumeMount{
+			Name:      "mnt-splunk-outputs",
+			MountPath: outputsAppPath,
+		})
+	}
+
+	addSidecarsToPodTemplate(&podTemplateSpec, &cr.Spec.CommonSplunkSpec)
+	return podTemplateSpec
+}
+
+// GetUniversalForwarderDaemonSet returns a Kubernetes DaemonSet object used to run a universal forwarder on each node.
+func GetUniversalForwarderDaemonSet(cr *enterprisev1.UniversalForwarder) *appsv1.DaemonSet {
+	selectLabels := getSplunkLabels(cr.GetIdentifier(), SplunkUniversalForwarder)
+
+	// start with same labels as selector; note that this object gets modified by resources.AppendParentMeta()
+	labels := make(map[string]string)
+	for k, v := range selectLabels {
+		labels[k] = v
+	}
+
+	daemonSet := &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "DaemonSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkDeploymentName(SplunkUniversalForwarder, cr.GetIdentifier()),
+			Namespace: cr.GetNamespace(),
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectLabels,
+			},
+			Template: getUniversalForwarderPodTemplate(cr, labels),
+		},
+	}
+
+	// make Splunk Enterprise object the owner
+	daemonSet.SetOwnerReferences(append(daemonSet.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return daemonSet
+}
+
+// GetUniversalForwarderDeployment returns a Kubernetes Deployment object used to run a fixed number of universal forwarders.
+func GetUniversalForwarderDeployment(cr *enterprisev1.UniversalForwarder) *appsv1.Deployment {
+	selectLabels := getSplunkLabels(cr.GetIdentifier(), SplunkUniversalForwarder)
+
+	// start with same labels as selector; note that this object gets modified by resources.AppendParentMeta()
+	labels := make(map[string]string)
+	for k, v := range selectLabels {
+		labels[k] = v
+	}
+
+	replicas := cr.Spec.Replicas
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetSplunkDeploymentName(SplunkUniversalForwarder, cr.GetIdentifier()),
+			Namespace: cr.GetNamespace(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectLabels,
+			},
+			Replicas: &replicas,
+			Template: getUniversalForwarderPodTemplate(cr, labels),
+		},
+	}
+
+	// make Splunk Enterprise object the owner
+	deployment.SetOwnerReferences(append(deployment.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	return deployment
+}
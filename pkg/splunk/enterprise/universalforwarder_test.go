@@ -0,0 +1,206 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateUniversalForwarderSpec(t *testing.T) {
+	test := func(spec enterprisev1.UniversalForwarderSpec, wantErr bool, wantWorkloadType string, wantReplicas int32) {
+		err := ValidateUniversalForwarderSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateUniversalForwarderSpec(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateUniversalForwarderSpec(%v) returned %v; want nil", spec, err)
+		}
+		if wantErr {
+			return
+		}
+		if spec.WorkloadType != wantWorkloadType || spec.Replicas != wantReplicas {
+			t.Errorf("ValidateUniversalForwarderSpec() workloadType, replicas = %s, %d; want %s, %d", spec.WorkloadType, spec.Replicas, wantWorkloadType, wantReplicas)
+		}
+		if spec.Image != "splunk/universalforwarder" {
+			t.Errorf("ValidateUniversalForwarderSpec() image = %s; want %s", spec.Image, "splunk/universalforwarder")
+		}
+	}
+
+	spec := enterprisev1.UniversalForwarderSpec{}
+	test(spec, true, "", 0)
+	spec.IndexerClusterRef.Name = "idxc"
+	test(spec, false, UniversalForwarderDaemonSet, 0)
+	spec.WorkloadType = UniversalForwarderDeployment
+	test(spec, false, UniversalForwarderDeployment, 1)
+	spec.Replicas = 3
+	test(spec, false, UniversalForwarderDeployment, 3)
+	spec.WorkloadType = "StatefulSet"
+	test(spec, true, "", 0)
+	spec.WorkloadType = ""
+	spec.Replicas = -1
+	test(spec, true, "", 0)
+
+	// features that are only used by Splunk Enterprise are not allowed
+	spec = enterprisev1.UniversalForwarderSpec{}
+	spec.IndexerClusterRef.Name = "idxc"
+	spec.SmartStore.VolList = []enterprisev1.SmartStoreVolumeSpec{{Name: "s3"}}
+	test(spec, true, "", 0)
+	spec = enterprisev1.UniversalForwarderSpec{}
+	spec.IndexerClusterRef.Name = "idxc"
+	spec.Ingress.Host = "splunk.example.com"
+	test(spec, true, "", 0)
+	spec = enterprisev1.UniversalForwarderSpec{}
+	spec.IndexerClusterRef.Name = "idxc"
+	spec.SecretRotationInterval = "720h"
+	test(spec, true, "", 0)
+}
+
+func TestGetUniversalForwarderOutputsSecret(t *testing.T) {
+	cr := enterprisev1.UniversalForwarder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "uf1",
+			Namespace: "logging",
+		},
+	}
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "idxc",
+			Namespace: "splunk",
+		},
+	}
+	idxc.Spec.Replicas = 2
+
+	test := func(discovery, tls *corev1.Secret, want string) {
+		secret := GetUniversalForwarderOutputsSecret(&cr, &idxc, discovery, tls)
+		if secret.GetName() != "splunk-uf1-universal-forwarder-outputs" || secret.GetNamespace() != "logging" {
+			t.Errorf("GetUniversalForwarderOutputsSecret() name = %s/%s; want %s/%s", secret.GetNamespace(), secret.GetName(), "logging", "splunk-uf1-universal-forwarder-outputs")
+		}
+		if got := string(secret.Data["outputs.conf"]); got != want {
+			t.Errorf("GetUniversalForwarderOutputsSecret() outputs.conf = %s;\nwant %s", got, want)
+		}
+	}
+
+	// static list of peers
+	test(nil, nil, `[tcpout]
+defaultGroup = idxc
+
+[tcpout:idxc]
+server = splunk-idxc-indexer-0.splunk-idxc-indexer-headless.splunk.svc.cluster.local:9997,splunk-idxc-indexer-1.splunk-idxc-indexer-headless.splunk.svc.cluster.local:9997
+`)
+
+	// peers of each site, using TLS
+	idxc.Spec.Sites = []enterprisev1.IndexerClusterSiteSpec{{Name: "site1", Replicas: 1}, {Name: "site2", Replicas: 1}}
+	tls := corev1.Secret{Data: map[string][]byte{"ca.crt": []byte("ca")}}
+	test(nil, &tls, `[tcpout]
+defaultGroup = idxc
+
+[tcpout:idxc]
+server = splunk-idxc-site1-indexer-0.splunk-idxc-site1-indexer-headless.splunk.svc.cluster.local:9998,splunk-idxc-site2-indexer-0.splunk-idxc-site2-indexer-headless.splunk.svc.cluster.local:9998
+useSSL = true
+sslRootCAPath = /opt/splunkforwarder/etc/apps/splunk-operator-outputs/local/ca.crt
+sslVerifyServerCert = true
+`)
+	if secret := GetUniversalForwarderOutputsSecret(&cr, &idxc, nil, &tls); string(secret.Data["ca.crt"]) != "ca" {
+		t.Errorf("GetUniversalForwarderOutputsSecret() ca.crt = %s; want %s", secret.Data["ca.crt"], "ca")
+	}
+
+	// indexer discovery using a referenced ClusterMaster
+	idxc.Spec.ClusterMasterRef.Name = "cm"
+	discovery := corev1.Secret{Data: map[string][]byte{"pass4SymmKey": []byte("s3cr3t")}}
+	test(&discovery, nil, `[tcpout]
+defaultGroup = idxc
+
+[tcpout:idxc]
+indexerDiscovery = idxc
+
+[indexer_discovery:idxc]
+master_uri = https://splunk-cm-cluster-master-service.splunk.svc.cluster.local:8089
+pass4SymmKey = s3cr3t
+`)
+}
+
+func TestGetUniversalForwarderWorkloads(t *testing.T) {
+	cr := enterprisev1.UniversalForwarder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "uf1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.IndexerClusterRef.Name = "idxc"
+	if err := ValidateUniversalForwarderSpec(&cr.Spec); err != nil {
+		t.Fatalf("ValidateUniversalForwarderSpec() returned %v; want nil", err)
+	}
+
+	daemonSet := GetUniversalForwarderDaemonSet(&cr)
+	if daemonSet.GetName() != "splunk-uf1-universal-forwarder" {
+		t.Errorf("GetUniversalForwarderDaemonSet() name = %s; want %s", daemonSet.GetName(), "splunk-uf1-universal-forwarder")
+	}
+	podSpec := daemonSet.Spec.Template.Spec
+	if podSpec.Affinity == nil || podSpec.Affinity.PodAntiAffinity != nil {
+		t.Errorf("GetUniversalForwarderDaemonSet() affinity = %v; want no pod anti-affinity", podSpec.Affinity)
+	}
+	container := podSpec.Containers[0]
+	if len(container.Ports) != 1 || container.Ports[0].Name != "splunkd" {
+		t.Errorf("GetUniversalForwarderDaemonSet() ports = %v; want splunkd", container.Ports)
+	}
+	mounts := map[string]string{}
+	for _, m := range container.VolumeMounts {
+		mounts[m.Name] = m.MountPath
+	}
+	for name, want := range map[string]string{
+		"pvc-etc":            "/opt/splunkforwarder/etc",
+		"pvc-var":            "/opt/splunkforwarder/var",
+		"mnt-splunk-outputs": "/opt/splunkforwarder/etc/apps/splunk-operator-outputs/local",
+	} {
+		if mounts[name] != want {
+			t.Errorf("GetUniversalForwarderDaemonSet() mount %s = %s; want %s", name, mounts[name], want)
+		}
+	}
+	env := map[string]string{}
+	for _, v := range container.Env {
+		env[v.Name] = v.Value
+	}
+	if env["SPLUNK_HOME"] != "/opt/splunkforwarder" || env["SPLUNK_ROLE"] != "splunk_universal_forwarder" {
+		t.Errorf("GetUniversalForwarderDaemonSet() env = %v; want SPLUNK_HOME and SPLUNK_ROLE for universal forwarders", container.Env)
+	}
+	if _, ok := env["SPLUNK_CLUSTER_MASTER_URL"]; ok {
+		t.Errorf("GetUniversalForwarderDaemonSet() env included SPLUNK_CLUSTER_MASTER_URL")
+	}
+	if got := container.Lifecycle.PreStop.Exec.Command[2]; got != "/opt/splunkforwarder/bin/splunk stop" {
+		t.Errorf("GetUniversalForwarderDaemonSet() preStop = %s; want %s", got, "/opt/splunkforwarder/bin/splunk stop")
+	}
+
+	// deployments are spread across nodes
+	cr.Spec.WorkloadType = UniversalForwarderDeployment
+	cr.Spec.Replicas = 2
+	deployment := GetUniversalForwarderDeployment(&cr)
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("GetUniversalForwarderDeployment() replicas = %d; want %d", *deployment.Spec.Replicas, 2)
+	}
+	if deployment.Spec.Template.Spec.Affinity.PodAntiAffinity == nil {
+		t.Errorf("GetUniversalForwarderDeployment() affinity = %v; want pod anti-affinity", deployment.Spec.Template.Spec.Affinity)
+	}
+
+	// pods are recycled when outputs change
+	secret := corev1.Secret{Data: map[string][]byte{"outputs.conf": []byte("[tcpout]\n")}}
+	SetOutputsChecksum(&deployment.Spec.Template, &secret)
+	if deployment.Spec.Template.Annotations["enterprise.splunk.com/outputs-checksum"] != GetOutputsChecksum(&secret) {
+		t.Errorf("SetOutputsChecksum() annotations = %v; want outputs checksum", deployment.Spec.Template.Annotations)
+	}
+}
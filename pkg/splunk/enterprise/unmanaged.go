@@ -0,0 +1,89 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UnmanagedReplicas leaves the number of replicas of a StatefulSet to others, such as a HorizontalPodAutoscaler
+	UnmanagedReplicas = "spec.replicas"
+
+	// UnmanagedPodAnnotations keeps pod template annotations added by others
+	UnmanagedPodAnnotations = "spec.template.metadata.annotations"
+
+	// UnmanagedPodLabels keeps pod template labels added by others
+	UnmanagedPodLabels = "spec.template.metadata.labels"
+
+	// UnmanagedAffinity leaves the affinity of pods to others
+	UnmanagedAffinity = "spec.template.spec.affinity"
+
+	// UnmanagedResources leaves the resource requests and limits of containers to others, such as a VerticalPodAutoscaler
+	UnmanagedResources = "spec.template.spec.containers.resources"
+
+	// UnmanagedFieldsAnnotation is used to list the fields of a StatefulSet that are not managed by the operator
+	UnmanagedFieldsAnnotation = "enterprise.splunk.com/unmanaged-fields"
+)
+
+// supportedUnmanagedFields are the fields of StatefulSets that may be left unmanaged by the operator
+var supportedUnmanagedFields = []string{
+	UnmanagedReplicas,
+	UnmanagedPodAnnotations,
+	UnmanagedPodLabels,
+	UnmanagedAffinity,
+	UnmanagedResources,
+}
+
+// validateUnmanagedFields returns an error if a field that is not supported is listed in unmanagedFields
+func validateUnmanagedFields(fields []string) error {
+	for _, field := range fields {
+		supported := false
+		for _, s := range supportedUnmanagedFields {
+			if field == s {
+				supported = true
+			}
+		}
+		if !supported {
+			return fmt.Errorf("unmanagedFields may only include \"%s\"; value=\"%s\"", strings.Join(supportedUnmanagedFields, "\", \""), field)
+		}
+	}
+	return nil
+}
+
+// setUnmanagedFields annotates a StatefulSet with the fields that are not managed by the operator, if there are any
+func setUnmanagedFields(statefulSet *appsv1.StatefulSet, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	if statefulSet.ObjectMeta.Annotations == nil {
+		statefulSet.ObjectMeta.Annotations = make(map[string]string)
+	}
+	statefulSet.ObjectMeta.Annotations[UnmanagedFieldsAnnotation] = strings.Join(fields, ",")
+}
+
+// IsUnmanagedField returns true if an object has been annotated to leave a field unmanaged by the operator
+func IsUnmanagedField(meta metav1.Object, field string) bool {
+	for _, f := range strings.Split(meta.GetAnnotations()[UnmanagedFieldsAnnotation], ",") {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
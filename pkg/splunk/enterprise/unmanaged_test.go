@@ -0,0 +1,77 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateUnmanagedFields(t *testing.T) {
+	spec := enterprisev1.StandaloneSpec{}
+	spec.UnmanagedFields = []string{UnmanagedReplicas, UnmanagedPodAnnotations, UnmanagedPodLabels, UnmanagedAffinity, UnmanagedResources}
+	if err := ValidateStandaloneSpec(&spec); err != nil {
+		t.Errorf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+
+	spec.UnmanagedFields = []string{UnmanagedReplicas, "spec.template.spec.containers.image"}
+	if err := ValidateStandaloneSpec(&spec); err == nil {
+		t.Errorf("ValidateStandaloneSpec() returned nil; want error for unmanagedFields=%v", spec.UnmanagedFields)
+	}
+}
+
+func TestGetSplunkStatefulSetUnmanagedFields(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	if err := ValidateStandaloneSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateStandaloneSpec() returned %v; want nil", err)
+	}
+
+	// nothing is annotated unless fields are listed
+	ss, err := GetStandaloneStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetStandaloneStatefulSet() returned %v; want nil", err)
+	}
+	if _, ok := ss.GetAnnotations()[UnmanagedFieldsAnnotation]; ok {
+		t.Errorf("GetStandaloneStatefulSet() annotations = %v; want no %s", ss.GetAnnotations(), UnmanagedFieldsAnnotation)
+	}
+	if IsUnmanagedField(ss, UnmanagedReplicas) {
+		t.Errorf("IsUnmanagedField(%s) = true; want false", UnmanagedReplicas)
+	}
+
+	cr.Spec.UnmanagedFields = []string{UnmanagedReplicas, UnmanagedResources}
+	ss, err = GetStandaloneStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetStandaloneStatefulSet() returned %v; want nil", err)
+	}
+	if got, want := ss.GetAnnotations()[UnmanagedFieldsAnnotation], "spec.replicas,spec.template.spec.containers.resources"; got != want {
+		t.Errorf("GetStandaloneStatefulSet() %s = %s; want %s", UnmanagedFieldsAnnotation, got, want)
+	}
+	for _, field := range []string{UnmanagedReplicas, UnmanagedResources} {
+		if !IsUnmanagedField(ss, field) {
+			t.Errorf("IsUnmanagedField(%s) = false; want true", field)
+		}
+	}
+	if IsUnmanagedField(ss, UnmanagedAffinity) {
+		t.Errorf("IsUnmanagedField(%s) = true; want false", UnmanagedAffinity)
+	}
+}
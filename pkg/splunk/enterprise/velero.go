@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// VeleroHookStop is used to stop splunkd while Velero backs up the volumes of a pod
+	VeleroHookStop = "stop"
+
+	// VeleroHookFsfreeze is used to freeze the etc and var filesystems while Velero backs up the volumes of a pod
+	VeleroHookFsfreeze = "fsfreeze"
+
+	// default maximum time that Velero waits for each hook to complete
+	defaultVeleroHookTimeout = "10m"
+
+	// default image used for the sidecar that runs fsfreeze hooks
+	defaultVeleroFsfreezeImage = "ubuntu:20.04"
+
+	// name of the sidecar container that runs fsfreeze hooks
+	veleroFsfreezeContainer = "velero-fsfreeze"
+
+	// pod annotation used to exclude volumes from file system backups
+	veleroBackupVolumesExcludesAnnotation = "backup.velero.io/backup-volumes-excludes"
+)
+
+// IsVeleroEnabled returns true if Velero backup hooks have been configured for the pods of a resource
+func IsVeleroEnabled(spec *enterprisev1.VeleroSpec) bool {
+	return spec.Hook != ""
+}
+
+// validateVeleroSpec checks validity and makes default updates to the VeleroSpec of a CommonSplunkSpec, and returns error if
+// something is wrong. The fsfreeze hook requires at least one of the etc and var volumes to use persistent storage.
+func validateVeleroSpec(spec *enterprisev1.CommonSplunkSpec) error {
+	veleroSpec := &spec.Velero
+	switch veleroSpec.Hook {
+	case "":
+		return nil
+	case VeleroHookStop:
+	case VeleroHookFsfreeze:
+		if len(getVeleroFsfreezeMounts(spec)) == 0 {
+			return fmt.Errorf("Velero fsfreeze hook requires etcStorage or varStorage to be persistent")
+		}
+	default:
+		return fmt.Errorf("Velero hook must be either \"%s\" or \"%s\"; value=\"%s\"", VeleroHookStop, VeleroHookFsfreeze, veleroSpec.Hook)
+	}
+
+	if veleroSpec.Timeout == "" {
+		veleroSpec.Timeout = defaultVeleroHookTimeout
+	}
+	if _, err := time.ParseDuration(veleroSpec.Timeout); err != nil {
+		return fmt.Errorf("Velero timeout must be a duration such as \"%s\"; value=\"%s\"", defaultVeleroHookTimeout, veleroSpec.Timeout)
+	}
+
+	if veleroSpec.FsfreezeImage == "" {
+		veleroSpec.FsfreezeImage = defaultVeleroFsfreezeImage
+	}
+
+	return nil
+}
+
+// getVeleroFsfreezeMounts returns volume mounts for the etc and var volumes that use persistent storage. Ephemeral volumes
+// are not backed up, so they are never frozen.
+func getVeleroFsfreezeMounts(spec *enterprisev1.CommonSplunkSpec) []corev1.VolumeMount {
+	var mounts []corev1.VolumeMount
+	if !spec.EtcStorage.Ephemeral {
+		mounts = append(mounts, corev1.VolumeMount{Name: "pvc-etc", MountPath: "/opt/splunk/etc"})
+	}
+	if !spec.VarStorage.Ephemeral {
+		mounts = append(mounts, corev1.VolumeMount{Name: "pvc-var", MountPath: "/opt/splunk/var"})
+	}
+	return mounts
+}
+
+// getVeleroHookCommands returns the container and the commands that Velero runs in it before and after backing up the
+// volumes of a pod. Filesystems that were already frozen are thawed if any of them cannot be frozen, since Velero does
+// not run post hooks when a pre hook fails.
+func getVeleroHookCommands(spec *enterprisev1.CommonSplunkSpec, instanceType InstanceType) (string, []string, []string) {
+	if spec.Velero.Hook == VeleroHookFsfreeze {
+		var freeze, unfreeze []string
+		for _, m := range getVeleroFsfreezeMounts(spec) {
+			freeze = append(freeze, "/sbin/fsfreeze --freeze "+m.MountPath)
+			unfreeze = append(unfreeze, "/sbin/fsfreeze --unfreeze "+m.MountPath)
+		}
+		pre := fmt.Sprintf("%s || { %s; exit 1; }", strings.Join(freeze, " && "), strings.Join(unfreeze, "; "))
+		return veleroFsfreezeContainer, []string{"/bin/sh", "-c", pre}, []string{"/bin/sh", "-c", strings.Join(unfreeze, "; ")}
+	}
+
+	splunk := GetSplunkHome(instanceType) + "/bin/splunk"
+	return "splunk",
+		[]string{"/bin/sh", "-c", splunk + " stop"},
+		[]string{"/bin/sh", "-c", splunk + " start --accept-license --answer-yes --no-prompt"}
+}
+
+// getVeleroBackupVolumesExcludes returns a comma-separated list of the emptyDir volumes of a pod, which only hold
+// transient data that is recreated when pods are restarted
+func getVeleroBackupVolumesExcludes(volumes []corev1.Volume) string {
+	var names []string
+	for _, v := range volumes {
+		if v.EmptyDir != nil {
+			names = append(names, v.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// getVeleroAnnotations returns the pod annotations used to configure Velero backup hooks, and to exclude transient volumes
+// from file system backups
+func getVeleroAnnotations(spec *enterprisev1.CommonSplunkSpec, instanceType InstanceType, volumes []corev1.Volume) map[string]string {
+	container, pre, post := getVeleroHookCommands(spec, instanceType)
+	preCommand, _ := json.Marshal(pre)
+	postCommand, _ := json.Marshal(post)
+
+	annotations := map[string]string{
+		"pre.hook.backup.velero.io/container":  container,
+		"pre.hook.backup.velero.io/command":    string(preCommand),
+		"pre.hook.backup.velero.io/timeout":    spec.Velero.Timeout,
+		"post.hook.backup.velero.io/container": container,
+		"post.hook.backup.velero.io/command":   string(postCommand),
+		"post.hook.backup.velero.io/timeout":   spec.Velero.Timeout,
+	}
+	if excludes := getVeleroBackupVolumesExcludes(volumes); excludes != "" {
+		annotations[veleroBackupVolumesExcludesAnnotation] = excludes
+	}
+	return annotations
+}
+
+// addVeleroToPodTemplate annotates a pod template with the hooks that Velero runs to quiesce Splunk Enterprise while it
+// backs up the volumes of pods, and with the emptyDir volumes that it should not back up. This should be called after all
+// of the volumes used by Splunk Enterprise containers have been added. Annotations of the custom resource take precedence,
+// as they do for other pod annotations. Nothing is done if Velero backup hooks are not configured.
+func addVeleroToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType InstanceType) {
+	if !IsVeleroEnabled(&spec.Velero) {
+		return
+	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	parentAnnotations := cr.GetAnnotations()
+	for k, v := range getVeleroAnnotations(spec, instanceType, podTemplateSpec.Spec.Volumes) {
+		if _, ok := parentAnnotations[k]; !ok {
+			podTemplateSpec.ObjectMeta.Annotations[k] = v
+		}
+	}
+}
+
+// addVeleroSidecarToPodTemplate appends the privileged sidecar container used to run fsfreeze hooks to a pod template, if
+// configured. It mounts the persistent etc and var volumes, and just waits for hooks to be run in it.
+func addVeleroSidecarToPodTemplate(podTemplateSpec *corev1.PodTemplateSpec, spec *enterprisev1.CommonSplunkSpec) {
+	if spec.Velero.Hook != VeleroHookFsfreeze {
+		return
+	}
+	privileged := true
+	podTemplateSpec.Spec.Containers = append(podTemplateSpec.Spec.Containers, corev1.Container{
+		Name:            veleroFsfreezeContainer,
+		Image:           spec.Velero.FsfreezeImage,
+		ImagePullPolicy: corev1.PullPolicy(spec.ImagePullPolicy),
+		Command:         []string{"/bin/sh", "-c", "trap 'exit 0' TERM INT; sleep infinity & wait"},
+		SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+		VolumeMounts:    getVeleroFsfreezeMounts(spec),
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("0.01"),
+				corev1.ResourceMemory: resource.MustParse("16Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("0.1"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+		},
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestValidateVeleroSpec(t *testing.T) {
+	test := func(spec enterprisev1.CommonSplunkSpec, wantErr bool, wantTimeout string) {
+		err := validateVeleroSpec(&spec)
+		if wantErr && err == nil {
+			t.Errorf("validateVeleroSpec(%v) returned nil; want error", spec.Velero)
+		} else if !wantErr && err != nil {
+			t.Errorf("validateVeleroSpec(%v) returned %v; want nil", spec.Velero, err)
+		}
+		if !wantErr && spec.Velero.Timeout != wantTimeout {
+			t.Errorf("validateVeleroSpec() timeout = %s; want %s", spec.Velero.Timeout, wantTimeout)
+		}
+	}
+
+	test(enterprisev1.CommonSplunkSpec{}, false, "")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookStop}}, false, "10m")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze, Timeout: "30s"}}, false, "30s")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookStop, Timeout: "soon"}}, true, "")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: "snapshot"}}, true, "")
+
+	ephemeral := enterprisev1.StorageSpec{Ephemeral: true}
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze}, EtcStorage: ephemeral}, false, "10m")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze}, EtcStorage: ephemeral, VarStorage: ephemeral}, true, "")
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookStop}, EtcStorage: ephemeral, VarStorage: ephemeral}, false, "10m")
+}
+
+func TestAddVeleroToPodTemplate(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Annotations: map[string]string{"post.hook.backup.velero.io/timeout": "1h"},
+		},
+	}
+
+	test := func(spec enterprisev1.CommonSplunkSpec, want map[string]string) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Volumes:    append(getSplunkEphemeralVolumes(&spec), corev1.Volume{Name: "mnt-splunk-secrets", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{}}}),
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		if err := validateVeleroSpec(&spec); err != nil {
+			t.Fatalf("validateVeleroSpec(%v) returned %v; want nil", spec.Velero, err)
+		}
+		addVeleroToPodTemplate(&podTemplateSpec, &cr, &spec, SplunkStandalone)
+		if len(podTemplateSpec.ObjectMeta.Annotations) != len(want) {
+			t.Errorf("addVeleroToPodTemplate(%v) annotations = %v; want %v", spec.Velero, podTemplateSpec.ObjectMeta.Annotations, want)
+		}
+		for k, v := range want {
+			if got := podTemplateSpec.ObjectMeta.Annotations[k]; got != v {
+				t.Errorf("addVeleroToPodTemplate(%v) annotation %s = %s; want %s", spec.Velero, k, got, v)
+			}
+		}
+	}
+
+	test(enterprisev1.CommonSplunkSpec{}, map[string]string{})
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookStop}}, map[string]string{
+		"pre.hook.backup.velero.io/container":  "splunk",
+		"pre.hook.backup.velero.io/command":    `["/bin/sh","-c","/opt/splunk/bin/splunk stop"]`,
+		"pre.hook.backup.velero.io/timeout":    "10m",
+		"post.hook.backup.velero.io/container": "splunk",
+		"post.hook.backup.velero.io/command":   `["/bin/sh","-c","/opt/splunk/bin/splunk start --accept-license --answer-yes --no-prompt"]`,
+	})
+	test(enterprisev1.CommonSplunkSpec{
+		Velero:     enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze, Timeout: "2m"},
+		VarStorage: enterprisev1.StorageSpec{Ephemeral: true},
+	}, map[string]string{
+		"pre.hook.backup.velero.io/container":  veleroFsfreezeContainer,
+		"pre.hook.backup.velero.io/command":    `["/bin/sh","-c","/sbin/fsfreeze --freeze /opt/splunk/etc || { /sbin/fsfreeze --unfreeze /opt/splunk/etc; exit 1; }"]`,
+		"pre.hook.backup.velero.io/timeout":    "2m",
+		"post.hook.backup.velero.io/container": veleroFsfreezeContainer,
+		"post.hook.backup.velero.io/command":   `["/bin/sh","-c","/sbin/fsfreeze --unfreeze /opt/splunk/etc"]`,
+		veleroBackupVolumesExcludesAnnotation:  "pvc-var",
+	})
+}
+
+func TestAddVeleroSidecarToPodTemplate(t *testing.T) {
+	test := func(spec enterprisev1.CommonSplunkSpec, wantMounts []string) {
+		podTemplateSpec := corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "splunk"}},
+			},
+		}
+		if err := validateVeleroSpec(&spec); err != nil {
+			t.Fatalf("validateVeleroSpec(%v) returned %v; want nil", spec.Velero, err)
+		}
+		addVeleroSidecarToPodTemplate(&podTemplateSpec, &spec)
+		containers := podTemplateSpec.Spec.Containers
+		if wantMounts == nil {
+			if len(containers) != 1 {
+				t.Errorf("addVeleroSidecarToPodTemplate(%v) containers = %d; want 1", spec.Velero, len(containers))
+			}
+			return
+		}
+		if len(containers) != 2 || containers[1].Name != veleroFsfreezeContainer {
+			t.Fatalf("addVeleroSidecarToPodTemplate(%v) did not add %s container", spec.Velero, veleroFsfreezeContainer)
+		}
+		sidecar := containers[1]
+		if sidecar.Image != defaultVeleroFsfreezeImage {
+			t.Errorf("addVeleroSidecarToPodTemplate() image = %s; want %s", sidecar.Image, defaultVeleroFsfreezeImage)
+		}
+		if sidecar.SecurityContext == nil || sidecar.SecurityContext.Privileged == nil || !*sidecar.SecurityContext.Privileged {
+			t.Errorf("addVeleroSidecarToPodTemplate() container is not privileged")
+		}
+		if len(sidecar.VolumeMounts) != len(wantMounts) {
+			t.Fatalf("addVeleroSidecarToPodTemplate() mounts = %v; want %v", sidecar.VolumeMounts, wantMounts)
+		}
+		for idx, m := range sidecar.VolumeMounts {
+			if m.Name != wantMounts[idx] {
+				t.Errorf("addVeleroSidecarToPodTemplate() mount %d = %s; want %s", idx, m.Name, wantMounts[idx])
+			}
+		}
+	}
+
+	test(enterprisev1.CommonSplunkSpec{}, nil)
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookStop}}, nil)
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze}}, []string{"pvc-etc", "pvc-var"})
+	test(enterprisev1.CommonSplunkSpec{Velero: enterprisev1.VeleroSpec{Hook: VeleroHookFsfreeze}, EtcStorage: enterprisev1.StorageSpec{Ephemeral: true}}, []string{"pvc-var"})
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SplunkImageAnnotation is used to upgrade linked custom resources to a new Splunk Enterprise image, in order
+const SplunkImageAnnotation = "enterprise.splunk.com/splunk-image"
+
+// imageDigestRegex matches the digest used to pin an image (e.g. "splunk/splunk@sha256:...")
+var imageDigestRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// splunkVersionRegex matches the Splunk Enterprise version at the start of an image tag (e.g. "8.0.5" or "8.1.0-debian9")
+var splunkVersionRegex = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ValidateImage returns an error if an image is pinned using a digest that is not valid
+func ValidateImage(image string) error {
+	idx := strings.Index(image, "@")
+	if idx < 0 {
+		return nil
+	}
+	if idx == 0 || !imageDigestRegex.MatchString(image[idx+1:]) {
+		return fmt.Errorf("image must be pinned using a name and a digest of the form \"sha256:<64 hexadecimal digits>\"; value=\"%s\"", image)
+	}
+	return nil
+}
+
+// GetRequestedImage returns the image requested for a custom resource using its splunk-image annotation, or an empty
+// string if it does not have one
+func GetRequestedImage(obj metav1.Object) string {
+	return obj.GetAnnotations()[SplunkImageAnnotation]
+}
+
+// getSplunkImageVersion returns the major, minor and maintenance version of Splunk Enterprise used by an image, based on
+// its tag. Images pinned using a digest may also include a tag (e.g. "splunk/splunk:8.1.0@sha256:..."). It returns nil if
+// the version cannot be determined, such as for "latest" or images that only have a digest.
+func getSplunkImageVersion(image string) []int {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		image = image[:idx]
+	}
+	idx := strings.LastIndex(image, ":")
+	if idx < 0 || strings.Contains(image[idx:], "/") {
+		return nil
+	}
+	match := splunkVersionRegex.FindStringSubmatch(image[idx+1:])
+	if match == nil {
+		return nil
+	}
+	version := make([]int, 3)
+	for n := range version {
+		version[n], _ = strconv.Atoi(match[n+1])
+	}
+	return version
+}
+
+// compareSplunkVersions returns a negative number if version a is earlier than b, a positive number if it is later,
+// or zero if they are the same
+func compareSplunkVersions(a, b []int) int {
+	for n := range a {
+		if a[n] != b[n] {
+			return a[n] - b[n]
+		}
+	}
+	return 0
+}
+
+// ValidateVersionSkew returns an error if instances of instanceType running image are not compatible with the instances
+// of refType running refImage that they are linked to. Splunk Enterprise requires license masters and cluster masters to
+// run the same or a later version than the instances that use them, and search heads to run the same or a later version
+// than indexer cluster peers. Nothing is checked if either version cannot be determined from its image tag.
+func ValidateVersionSkew(instanceType InstanceType, image string, refType InstanceType, refImage string) error {
+	version, refVersion := getSplunkImageVersion(image), getSplunkImageVersion(refImage)
+	if version == nil || refVersion == nil {
+		return nil
+	}
+
+	switch refType {
+	case SplunkLicenseMaster, SplunkClusterMaster:
+		if compareSplunkVersions(refVersion, version) < 0 {
+			return fmt.Errorf("Unsupported version skew: %s image %s must run the same or a later version than %s image %s", refType, refImage, instanceType, image)
+		}
+	case SplunkIndexer:
+		if compareSplunkVersions(version, refVersion) < 0 {
+			return fmt.Errorf("Unsupported version skew: %s image %s must run the same or a later version than %s image %s", instanceType, image, refType, refImage)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetSplunkImageVersion(t *testing.T) {
+	test := func(image string, want []int) {
+		if got := getSplunkImageVersion(image); !reflect.DeepEqual(got, want) {
+			t.Errorf("getSplunkImageVersion(%s) = %v; want %v", image, got, want)
+		}
+	}
+
+	test("splunk/splunk:8.0.5", []int{8, 0, 5})
+	test("splunk/splunk:8.1", []int{8, 1, 0})
+	test("splunk/splunk:8.1.0-debian9", []int{8, 1, 0})
+	test("registry.example.com:5000/splunk/splunk:7.3.6", []int{7, 3, 6})
+	test("splunk/splunk:latest", nil)
+	test("splunk/splunk", nil)
+	test("registry.example.com:5000/splunk/splunk", nil)
+	test("splunk/splunk@sha256:0123456789abcdef", nil)
+	test("splunk/splunk:8.1.0@sha256:0123456789abcdef", []int{8, 1, 0})
+}
+
+func TestValidateImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0a", 32)
+	test := func(image string, wantErr bool) {
+		err := ValidateImage(image)
+		if wantErr && err == nil {
+			t.Errorf("ValidateImage(%s) returned nil; want error", image)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateImage(%s) returned %v; want nil", image, err)
+		}
+	}
+
+	test("splunk/splunk:8.1.0", false)
+	test("splunk/splunk@"+digest, false)
+	test("splunk/splunk:8.1.0@"+digest, false)
+	test("@"+digest, true)
+	test("splunk/splunk@sha256:0123", true)
+	test("splunk/splunk@md5:"+strings.Repeat("0a", 16), true)
+}
+
+func TestValidateVersionSkew(t *testing.T) {
+	test := func(instanceType InstanceType, image string, refType InstanceType, refImage string, wantErr bool) {
+		err := ValidateVersionSkew(instanceType, image, refType, refImage)
+		if wantErr && err == nil {
+			t.Errorf("ValidateVersionSkew(%s, %s, %s, %s) returned nil; want error", instanceType, image, refType, refImage)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateVersionSkew(%s, %s, %s, %s) returned %v; want nil", instanceType, image, refType, refImage, err)
+		}
+	}
+
+	// license masters and cluster masters must run the same or a later version
+	test(SplunkIndexer, "splunk/splunk:8.0.5", SplunkLicenseMaster, "splunk/splunk:8.0.5", false)
+	test(SplunkIndexer, "splunk/splunk:8.0.5", SplunkLicenseMaster, "splunk/splunk:8.1.0", false)
+	test(SplunkIndexer, "splunk/splunk:8.1.0", SplunkLicenseMaster, "splunk/splunk:8.0.5", true)
+	test(SplunkIndexer, "splunk/splunk:8.0.5", SplunkClusterMaster, "splunk/splunk:8.0.6", false)
+	test(SplunkSearchHead, "splunk/splunk:8.0.6", SplunkClusterMaster, "splunk/splunk:8.0.5", true)
+
+	// search heads must run the same or a later version than indexer cluster peers
+	test(SplunkSearchHead, "splunk/splunk:8.1.0", SplunkIndexer, "splunk/splunk:8.0.5", false)
+	test(SplunkSearchHead, "splunk/splunk:7.3.6", SplunkIndexer, "splunk/splunk:8.0.5", true)
+
+	// nothing is checked if a version cannot be determined
+	test(SplunkIndexer, "splunk/splunk:latest", SplunkLicenseMaster, "splunk/splunk:7.3.6", false)
+	test(SplunkSearchHead, "splunk/splunk:7.3.6", SplunkIndexer, "splunk/splunk:edge", false)
+}
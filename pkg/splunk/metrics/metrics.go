@@ -0,0 +1,290 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+)
+
+// prefix used for the names of all metrics exported by the operator
+const metricsNamespace = "splunk_operator"
+
+// phases that are reported by the resource phase gauge
+var phases = []enterprisev1.ResourcePhase{
+	enterprisev1.PhasePending,
+	enterprisev1.PhaseReady,
+	enterprisev1.PhaseUpdating,
+	enterprisev1.PhaseScalingUp,
+	enterprisev1.PhaseScalingDown,
+	enterprisev1.PhaseTerminating,
+	enterprisev1.PhaseError,
+}
+
+var (
+	// reconcileDuration tracks how long each reconcile takes, per controller
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Time taken to reconcile a custom resource, per controller.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"controller"})
+
+	// reconcileErrors counts reconciles that returned an error, per controller
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of reconciles that failed with an error, per controller.",
+	}, []string{"controller"})
+
+	// resourcePhase is set to 1 for the current phase of each custom resource, and 0 for all other phases
+	resourcePhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_phase",
+		Help:      "Current phase of each custom resource (1 for the current phase, 0 otherwise).",
+	}, []string{"kind", "namespace", "name", "phase"})
+
+	// splunkRequestDuration tracks the latency of Splunk REST API requests
+	splunkRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "splunkd_request_duration_seconds",
+		Help:      "Latency of splunkd REST API requests made by the operator.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path", "code"})
+
+	// searchConcurrency tracks the number of searches running on the search heads of each custom resource, if load metrics are enabled
+	searchConcurrency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "search_concurrency",
+		Help:      "Number of searches running on all search heads of a custom resource.",
+	}, []string{"kind", "namespace", "name"})
+
+	// indexingQueueFillRatio tracks how much of the index queue is filled on the indexers of each custom resource, if load metrics are enabled
+	indexingQueueFillRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "indexing_queue_fill_ratio",
+		Help:      "Average fraction (between 0 and 1) of the index queue that is filled on all indexers of a custom resource.",
+	}, []string{"kind", "namespace", "name"})
+
+	// licensePoolQuota tracks the daily indexing volume allowed for each license pool, if license usage reporting is enabled
+	licensePoolQuota = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_pool_quota_bytes",
+		Help:      "Daily indexing volume allowed for a license pool, in bytes.",
+	}, []string{"namespace", "name", "pool", "stack"})
+
+	// licensePoolUsed tracks the indexing volume used today by each license pool, if license usage reporting is enabled
+	licensePoolUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_pool_used_bytes",
+		Help:      "Indexing volume used today by a license pool, in bytes.",
+	}, []string{"namespace", "name", "pool", "stack"})
+
+	// licenseStackQuota tracks the daily indexing volume allowed for each license stack, if license usage reporting is enabled
+	licenseStackQuota = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_stack_quota_bytes",
+		Help:      "Daily indexing volume allowed by all licenses in a license stack, in bytes.",
+	}, []string{"namespace", "name", "stack"})
+
+	// licenseStackUsed tracks the indexing volume used today by each license stack, if license usage reporting is enabled
+	licenseStackUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_stack_used_bytes",
+		Help:      "Indexing volume used today by all pools in a license stack, in bytes.",
+	}, []string{"namespace", "name", "stack"})
+
+	// orphanedResources tracks the number of resources created by the operator whose custom resource no longer exists, if
+	// orphaned resources are reported
+	orphanedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "orphaned_resources",
+		Help:      "Number of resources created by the operator whose custom resource no longer exists, per kind.",
+	}, []string{"namespace", "kind"})
+)
+
+// licenseUsageSeries tracks the pool and stack labels most recently exported for each license master, so that gauges
+// can be removed for pools and stacks that no longer exist
+var (
+	licenseUsageMutex  sync.Mutex
+	licenseUsageSeries = make(map[string]map[string][]string)
+)
+
+// requestPathTemplates replace the variable segments of splunkd REST API paths, such as search IDs and the names of
+// apps, peers, license pools and HEC tokens, so that the number of path labels used for request metrics is bounded
+var requestPathTemplates = []struct {
+	pattern  *regexp.Regexp
+	template string
+}{
+	{regexp.MustCompile(`^/services/search/jobs/.+$`), "/services/search/jobs/{sid}"},
+	{regexp.MustCompile(`^/services/search/distributed/peers/.+$`), "/services/search/distributed/peers/{peer}"},
+	{regexp.MustCompile(`^/services/licenser/pools/.+$`), "/services/licenser/pools/{pool}"},
+	{regexp.MustCompile(`^/services/data/inputs/http/.+$`), "/services/data/inputs/http/{name}"},
+	{regexp.MustCompile(`^/services/configs/conf-[^/]+/_reload$`), "/services/configs/conf-{conf}/_reload"},
+	{regexp.MustCompile(`^/servicesNS/[^/]+/[^/]+/storage/collections/config$`), "/servicesNS/{user}/{app}/storage/collections/config"},
+	{regexp.MustCompile(`^/servicesNS/[^/]+/[^/]+/storage/collections/data/.+/batch_save$`), "/servicesNS/{user}/{app}/storage/collections/data/{collection}/batch_save"},
+	{regexp.MustCompile(`^/servicesNS/[^/]+/[^/]+/storage/collections/data/.+$`), "/servicesNS/{user}/{app}/storage/collections/data/{collection}"},
+	{regexp.MustCompile(`^/servicesNS/[^/]+/[^/]+/configs/conf-[^/]+/.+$`), "/servicesNS/{user}/{app}/configs/conf-{conf}/{stanza}"},
+}
+
+// getRequestPathLabel returns the endpoint template used to label metrics for a splunkd REST API request path
+func getRequestPathLabel(path string) string {
+	for _, t := range requestPathTemplates {
+		if t.pattern.MatchString(path) {
+			return t.template
+		}
+	}
+	return path
+}
+
+func init() {
+	// register with the controller-runtime registry, which is served by the manager's metrics endpoint
+	metrics.Registry.MustRegister(reconcileDuration, reconcileErrors, resourcePhase, splunkRequestDuration, searchConcurrency, indexingQueueFillRatio,
+		licensePoolQuota, licensePoolUsed, licenseStackQuota, licenseStackUsed, orphanedResources)
+	splclient.SetRequestObserver(ObserveSplunkRequest)
+}
+
+// ObserveReconcile records the duration of a reconcile started at the given time, and counts it as an error if err is not nil.
+func ObserveReconcile(controller string, start time.Time, err error) {
+	reconcileDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(controller).Inc()
+	}
+}
+
+// SetResourcePhase updates the phase gauge for a custom resource, so that only its current phase has a value of 1.
+func SetResourcePhase(kind, namespace, name string, phase enterprisev1.ResourcePhase) {
+	for _, p := range phases {
+		value := 0.0
+		if p == phase {
+			value = 1.0
+		}
+		resourcePhase.WithLabelValues(kind, namespace, name, string(p)).Set(value)
+	}
+}
+
+// DeleteResourcePhase removes the phase gauge for a custom resource that no longer exists.
+func DeleteResourcePhase(kind, namespace, name string) {
+	for _, p := range phases {
+		resourcePhase.DeleteLabelValues(kind, namespace, name, string(p))
+	}
+}
+
+// SetSearchConcurrency updates the search concurrency gauge for a custom resource using the load metrics in its status,
+// or removes it if load metrics are not enabled or have not been collected yet.
+func SetSearchConcurrency(kind, namespace, name string, spec *enterprisev1.LoadMetricsSpec, status *enterprisev1.LoadMetricsStatus) {
+	if !spec.Enabled || status.Instances == 0 {
+		searchConcurrency.DeleteLabelValues(kind, namespace, name)
+		return
+	}
+	searchConcurrency.WithLabelValues(kind, namespace, name).Set(float64(status.SearchConcurrency))
+}
+
+// SetIndexingQueueFillRatio updates the indexing queue fill ratio gauge for a custom resource using the load metrics in
+// its status, or removes it if load metrics are not enabled or have not been collected yet.
+func SetIndexingQueueFillRatio(kind, namespace, name string, spec *enterprisev1.LoadMetricsSpec, status *enterprisev1.LoadMetricsStatus) {
+	if !spec.Enabled || status.Instances == 0 {
+		indexingQueueFillRatio.DeleteLabelValues(kind, namespace, name)
+		return
+	}
+	indexingQueueFillRatio.WithLabelValues(kind, namespace, name).Set(float64(status.IndexingQueueFillPercent) / 100)
+}
+
+// DeleteLoadMetrics removes the load metric gauges for a custom resource that no longer exists.
+func DeleteLoadMetrics(kind, namespace, name string) {
+	searchConcurrency.DeleteLabelValues(kind, namespace, name)
+	indexingQueueFillRatio.DeleteLabelValues(kind, namespace, name)
+}
+
+// SetLicenseUsage updates the license pool and stack gauges for a license master using the license usage in its status,
+// or removes them if license usage reporting is not enabled or usage has not been collected yet.
+func SetLicenseUsage(namespace, name string, spec *enterprisev1.LicenseUsageSpec, status *enterprisev1.LicenseUsageStatus) {
+	series := make(map[string][]string)
+	if spec.Enabled && !status.LastUpdated.IsZero() {
+		for _, pool := range status.Pools {
+			labels := []string{namespace, name, pool.Name, pool.StackID}
+			licensePoolQuota.WithLabelValues(labels...).Set(float64(pool.Quota))
+			licensePoolUsed.WithLabelValues(labels...).Set(float64(pool.UsedBytes))
+			series["pool/"+pool.Name+"/"+pool.StackID] = labels
+		}
+		for _, stack := range status.Stacks {
+			labels := []string{namespace, name, stack.Name}
+			licenseStackQuota.WithLabelValues(labels...).Set(float64(stack.Quota))
+			licenseStackUsed.WithLabelValues(labels...).Set(float64(stack.UsedBytes))
+			series["stack/"+stack.Name] = labels
+		}
+	}
+
+	licenseUsageMutex.Lock()
+	defer licenseUsageMutex.Unlock()
+	key := namespace + "/" + name
+	deleteLicenseUsageSeries(licenseUsageSeries[key], series)
+	if len(series) == 0 {
+		delete(licenseUsageSeries, key)
+	} else {
+		licenseUsageSeries[key] = series
+	}
+}
+
+// DeleteLicenseUsage removes the license pool and stack gauges for a license master that no longer exists.
+func DeleteLicenseUsage(namespace, name string) {
+	licenseUsageMutex.Lock()
+	defer licenseUsageMutex.Unlock()
+	key := namespace + "/" + name
+	deleteLicenseUsageSeries(licenseUsageSeries[key], nil)
+	delete(licenseUsageSeries, key)
+}
+
+// deleteLicenseUsageSeries removes the license gauges for each series in previous that is not in current
+func deleteLicenseUsageSeries(previous, current map[string][]string) {
+	for k, labels := range previous {
+		if _, ok := current[k]; ok {
+			continue
+		}
+		if len(labels) == 4 {
+			licensePoolQuota.DeleteLabelValues(labels...)
+			licensePoolUsed.DeleteLabelValues(labels...)
+		} else {
+			licenseStackQuota.DeleteLabelValues(labels...)
+			licenseStackUsed.DeleteLabelValues(labels...)
+		}
+	}
+}
+
+// SetOrphanedResources replaces the orphaned resource gauges with the number of orphaned resources found for each
+// namespace and kind, so that gauges are removed for those that no longer have any.
+func SetOrphanedResources(counts map[string]map[string]int) {
+	orphanedResources.Reset()
+	for namespace, kinds := range counts {
+		for kind, count := range kinds {
+			orphanedResources.WithLabelValues(namespace, kind).Set(float64(count))
+		}
+	}
+}
+
+// ObserveSplunkRequest records the latency of a splunkd REST API request started at the given time.
+// A code of 0 is used for requests that failed without a response, and variable path segments are replaced by
+// placeholders.
+func ObserveSplunkRequest(method, path string, code int, start time.Time) {
+	splunkRequestDuration.WithLabelValues(method, getRequestPathLabel(path), strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestObserveReconcile(t *testing.T) {
+	before := testutil.ToFloat64(reconcileErrors.WithLabelValues("test"))
+	ObserveReconcile("test", time.Now(), nil)
+	if got := testutil.ToFloat64(reconcileErrors.WithLabelValues("test")); got != before {
+		t.Errorf("ObserveReconcile(nil) errors = %f; want %f", got, before)
+	}
+	ObserveReconcile("test", time.Now(), errors.New("test error"))
+	if got := testutil.ToFloat64(reconcileErrors.WithLabelValues("test")); got != before+1 {
+		t.Errorf("ObserveReconcile(error) errors = %f; want %f", got, before+1)
+	}
+}
+
+func TestSetResourcePhase(t *testing.T) {
+	test := func(phase enterprisev1.ResourcePhase) {
+		SetResourcePhase("Standalone", "test", "stack1", phase)
+		for _, p := range phases {
+			want := 0.0
+			if p == phase {
+				want = 1.0
+			}
+			if got := testutil.ToFloat64(resourcePhase.WithLabelValues("Standalone", "test", "stack1", string(p))); got != want {
+				t.Errorf("SetResourcePhase(%s) %s = %f; want %f", phase, p, got, want)
+			}
+		}
+	}
+
+	test(enterprisev1.PhasePending)
+	test(enterprisev1.PhaseReady)
+	test(enterprisev1.PhaseError)
+
+	DeleteResourcePhase("Standalone", "test", "stack1")
+	for _, p := range phases {
+		if resourcePhase.DeleteLabelValues("Standalone", "test", "stack1", string(p)) {
+			t.Errorf("DeleteResourcePhase() did not remove phase %s", p)
+		}
+	}
+}
+
+func TestSetLoadMetrics(t *testing.T) {
+	spec := enterprisev1.LoadMetricsSpec{Enabled: true}
+	status := enterprisev1.LoadMetricsStatus{SearchConcurrency: 12, IndexingQueueFillPercent: 40, Instances: 3}
+
+	SetSearchConcurrency("SearchHeadCluster", "test", "stack1", &spec, &status)
+	if got := testutil.ToFloat64(searchConcurrency.WithLabelValues("SearchHeadCluster", "test", "stack1")); got != 12 {
+		t.Errorf("SetSearchConcurrency() = %f; want %f", got, 12.0)
+	}
+	SetIndexingQueueFillRatio("IndexerCluster", "test", "stack1", &spec, &status)
+	if got := testutil.ToFloat64(indexingQueueFillRatio.WithLabelValues("IndexerCluster", "test", "stack1")); got != 0.4 {
+		t.Errorf("SetIndexingQueueFillRatio() = %f; want %f", got, 0.4)
+	}
+
+	// metrics are removed if they have not been collected, or are disabled
+	SetSearchConcurrency("SearchHeadCluster", "test", "stack1", &spec, &enterprisev1.LoadMetricsStatus{})
+	if searchConcurrency.DeleteLabelValues("SearchHeadCluster", "test", "stack1") {
+		t.Errorf("SetSearchConcurrency() did not remove metric that has not been collected")
+	}
+	spec.Enabled = false
+	SetIndexingQueueFillRatio("IndexerCluster", "test", "stack1", &spec, &status)
+	if indexingQueueFillRatio.DeleteLabelValues("IndexerCluster", "test", "stack1") {
+		t.Errorf("SetIndexingQueueFillRatio() did not remove disabled metric")
+	}
+
+	spec.Enabled = true
+	SetSearchConcurrency("SearchHeadCluster", "test", "stack1", &spec, &status)
+	SetIndexingQueueFillRatio("IndexerCluster", "test", "stack1", &spec, &status)
+	DeleteLoadMetrics("SearchHeadCluster", "test", "stack1")
+	DeleteLoadMetrics("IndexerCluster", "test", "stack1")
+	if searchConcurrency.DeleteLabelValues("SearchHeadCluster", "test", "stack1") || indexingQueueFillRatio.DeleteLabelValues("IndexerCluster", "test", "stack1") {
+		t.Errorf("DeleteLoadMetrics() did not remove metrics")
+	}
+}
+
+func TestSetLicenseUsage(t *testing.T) {
+	spec := enterprisev1.LicenseUsageSpec{Enabled: true}
+	status := enterprisev1.LicenseUsageStatus{
+		Stacks: []enterprisev1.LicenseStackStatus{{Name: "enterprise", Type: "enterprise", Quota: 1000, UsedBytes: 300}},
+		Pools: []enterprisev1.LicensePoolStatus{
+			{Name: "pool1", StackID: "enterprise", Quota: 500, UsedBytes: 100},
+			{Name: "pool2", StackID: "enterprise", Quota: 500, UsedBytes: 200},
+		},
+		LastUpdated: metav1.Now(),
+	}
+
+	SetLicenseUsage("test", "stack1", &spec, &status)
+	if got := testutil.ToFloat64(licensePoolQuota.WithLabelValues("test", "stack1", "pool1", "enterprise")); got != 500 {
+		t.Errorf("SetLicenseUsage() pool quota = %f; want %f", got, 500.0)
+	}
+	if got := testutil.ToFloat64(licensePoolUsed.WithLabelValues("test", "stack1", "pool2", "enterprise")); got != 200 {
+		t.Errorf("SetLicenseUsage() pool used = %f; want %f", got, 200.0)
+	}
+	if got := testutil.ToFloat64(licenseStackQuota.WithLabelValues("test", "stack1", "enterprise")); got != 1000 {
+		t.Errorf("SetLicenseUsage() stack quota = %f; want %f", got, 1000.0)
+	}
+	if got := testutil.ToFloat64(licenseStackUsed.WithLabelValues("test", "stack1", "enterprise")); got != 300 {
+		t.Errorf("SetLicenseUsage() stack used = %f; want %f", got, 300.0)
+	}
+
+	// metrics are removed for pools that no longer exist
+	status.Pools = status.Pools[:1]
+	SetLicenseUsage("test", "stack1", &spec, &status)
+	if licensePoolUsed.DeleteLabelValues("test", "stack1", "pool2", "enterprise") {
+		t.Errorf("SetLicenseUsage() did not remove metric for pool that no longer exists")
+	}
+
+	// metrics are removed if license usage reporting is disabled
+	spec.Enabled = false
+	SetLicenseUsage("test", "stack1", &spec, &status)
+	if licensePoolQuota.DeleteLabelValues("test", "stack1", "pool1", "enterprise") || licenseStackUsed.DeleteLabelValues("test", "stack1", "enterprise") {
+		t.Errorf("SetLicenseUsage() did not remove disabled metrics")
+	}
+
+	spec.Enabled = true
+	SetLicenseUsage("test", "stack1", &spec, &status)
+	DeleteLicenseUsage("test", "stack1")
+	if licensePoolQuota.DeleteLabelValues("test", "stack1", "pool1", "enterprise") || licensePoolUsed.DeleteLabelValues("test", "stack1", "pool1", "enterprise") ||
+		licenseStackQuota.DeleteLabelValues("test", "stack1", "enterprise") || licenseStackUsed.DeleteLabelValues("test", "stack1", "enterprise") {
+		t.Errorf("DeleteLicenseUsage() did not remove metrics")
+	}
+}
+
+func TestSetOrphanedResources(t *testing.T) {
+	SetOrphanedResources(map[string]map[string]int{"test": {"StatefulSet": 1, "PersistentVolumeClaim": 3}})
+	if got := testutil.ToFloat64(orphanedResources.WithLabelValues("test", "PersistentVolumeClaim")); got != 3 {
+		t.Errorf("SetOrphanedResources() PersistentVolumeClaim = %f; want %f", got, 3.0)
+	}
+
+	// metrics are removed for kinds that no longer have orphaned resources
+	SetOrphanedResources(map[string]map[string]int{"test": {"PersistentVolumeClaim": 2}})
+	if orphanedResources.DeleteLabelValues("test", "StatefulSet") {
+		t.Errorf("SetOrphanedResources() did not remove metric for kind without orphaned resources")
+	}
+	if got := testutil.ToFloat64(orphanedResources.WithLabelValues("test", "PersistentVolumeClaim")); got != 2 {
+		t.Errorf("SetOrphanedResources() PersistentVolumeClaim = %f; want %f", got, 2.0)
+	}
+}
+
+func TestGetRequestPathLabel(t *testing.T) {
+	test := func(path, want string) {
+		if got := getRequestPathLabel(path); got != want {
+			t.Errorf("getRequestPathLabel(%s) = %s; want %s", path, got, want)
+		}
+	}
+	test("/services/cluster/master/peers", "/services/cluster/master/peers")
+	test("/services/search/jobs", "/services/search/jobs")
+	test("/services/search/jobs/1602691200.123", "/services/search/jobs/{sid}")
+	test("/services/search/distributed/peers/splunk-stack1-indexer-0:8089", "/services/search/distributed/peers/{peer}")
+	test("/services/licenser/pools/pool1", "/services/licenser/pools/{pool}")
+	test("/services/data/inputs/http/http://app1", "/services/data/inputs/http/{name}")
+	test("/services/configs/conf-inputs/_reload", "/services/configs/conf-{conf}/_reload")
+	test("/servicesNS/-/-/storage/collections/config", "/servicesNS/{user}/{app}/storage/collections/config")
+	test("/servicesNS/nobody/search/storage/collections/data/kv1/batch_save", "/servicesNS/{user}/{app}/storage/collections/data/{collection}/batch_save")
+	test("/servicesNS/nobody/search/storage/collections/data/kv1", "/servicesNS/{user}/{app}/storage/collections/data/{collection}")
+	test("/servicesNS/nobody/search/configs/conf-props/default", "/servicesNS/{user}/{app}/configs/conf-{conf}/{stanza}")
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// isAdoptionRequested returns true if the custom resource that controls a Kubernetes resource may take ownership of
+// existing resources, using its adopt annotation
+func isAdoptionRequested(c ControllerClient, obj metav1.Object) bool {
+	cr := getControllingCustomResource(c, obj)
+	return cr != nil && enterprise.IsAdoptionRequested(cr)
+}
+
+// adoptStatefulSet is used when a StatefulSet that is not controlled by anything already exists with the name of one
+// that a custom resource would create (revised). If the custom resource requests adoption, it takes ownership of the
+// StatefulSet, and the PersistentVolumeClaims and pods that belong to it are labeled like the ones that it would create.
+// Fields of StatefulSets that cannot be updated are changed by removing the StatefulSet without its pods, in which case
+// true is returned and the new StatefulSet is created by a later reconcile. Nothing is done if current is already
+// controlled by something, or revised is not controlled by a custom resource.
+func adoptStatefulSet(c ControllerClient, current, revised *appsv1.StatefulSet) (bool, error) {
+	owner := metav1.GetControllerOf(revised)
+	if owner == nil || metav1.GetControllerOf(current) != nil {
+		return false, nil
+	}
+
+	scopedLog := log.WithName("adoptStatefulSet").WithValues(
+		"name", current.GetName(),
+		"namespace", current.GetNamespace())
+
+	if !isAdoptionRequested(c, revised) {
+		err := fmt.Errorf("StatefulSet %s already exists and is not owned by %s %s; set the %s annotation to \"true\" to adopt it",
+			current.GetName(), owner.Kind, owner.Name, enterprise.AdoptAnnotation)
+		recordOwnerEvent(revised, corev1.EventTypeWarning, "AdoptionRequired", "%v", err)
+		return false, err
+	}
+
+	// pods of the new StatefulSet must mount the same persistent volume claims, or they would start with empty volumes
+	for _, claim := range revised.Spec.VolumeClaimTemplates {
+		if !hasVolumeClaimTemplate(current, claim.GetName()) {
+			return false, fmt.Errorf("Unable to adopt StatefulSet %s: it has no %s volume claim template", current.GetName(), claim.GetName())
+		}
+	}
+
+	var replicas int32 = 1
+	if current.Spec.Replicas != nil {
+		replicas = *current.Spec.Replicas
+	}
+
+	// label persistent volume claims so that they are found by the pvcCleanupPolicy of the custom resource
+	for _, claim := range revised.Spec.VolumeClaimTemplates {
+		for n := int32(0); n < replicas; n++ {
+			name := fmt.Sprintf("%s-%s-%d", claim.GetName(), current.GetName(), n)
+			if err := addAdoptionLabels(c, current.GetNamespace(), name, &corev1.PersistentVolumeClaim{}, claim.GetLabels()); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if reflect.DeepEqual(current.Spec.Selector, revised.Spec.Selector) &&
+		current.Spec.ServiceName == revised.Spec.ServiceName &&
+		current.Spec.PodManagementPolicy == revised.Spec.PodManagementPolicy {
+		scopedLog.Info("Adopting StatefulSet")
+		current.SetOwnerReferences(append(current.GetOwnerReferences(), *owner))
+		current.Spec.UpdateStrategy = revised.Spec.UpdateStrategy
+		if err := UpdateResource(c, current); err != nil {
+			return false, err
+		}
+		recordOwnerEvent(revised, corev1.EventTypeNormal, "StatefulSetAdopted", "Adopted StatefulSet %s", current.GetName())
+		return false, nil
+	}
+
+	// label pods so that they are selected by the new StatefulSet
+	for n := int32(0); n < replicas; n++ {
+		name := fmt.Sprintf("%s-%d", current.GetName(), n)
+		if err := addAdoptionLabels(c, current.GetNamespace(), name, &corev1.Pod{}, revised.Spec.Selector.MatchLabels); err != nil {
+			return false, err
+		}
+	}
+
+	// orphaned pods are adopted by the new StatefulSet, and are only recycled if they need to be
+	scopedLog.Info("Replacing StatefulSet to adopt it")
+	err := c.Delete(context.TODO(), current, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil {
+		scopedLog.Error(err, "Unable to delete StatefulSet")
+		return false, err
+	}
+	recordOwnerEvent(revised, corev1.EventTypeNormal, "StatefulSetAdopted", "Adopted StatefulSet %s by replacing it", current.GetName())
+	return true, nil
+}
+
+// hasVolumeClaimTemplate returns true if a StatefulSet has a volume claim template with the given name
+func hasVolumeClaimTemplate(statefulSet *appsv1.StatefulSet, name string) bool {
+	for _, claim := range statefulSet.Spec.VolumeClaimTemplates {
+		if claim.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addAdoptionLabels adds labels to an existing Kubernetes resource that belongs to an adopted StatefulSet. Resources
+// that do not exist yet (or no longer exist) are skipped, since they are created by the new owner anyway.
+func addAdoptionLabels(c ControllerClient, namespace, name string, obj ResourceObject, labels map[string]string) error {
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)
+	if err != nil {
+		return nil
+	}
+
+	meta := obj.GetObjectMeta()
+	updated := meta.GetLabels()
+	if updated == nil {
+		updated = make(map[string]string)
+	}
+	changed := false
+	for k, v := range labels {
+		if updated[k] != v {
+			updated[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	meta.SetLabels(updated)
+	return UpdateResource(c, obj)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestApplyStatefulSetAdoption(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v1beta1",
+			Kind:       "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	var replicas int32 = 1
+	claimLabels := map[string]string{"app.kubernetes.io/part-of": "splunk-stack1-standalone"}
+	newStatefulSet := func(selector map[string]string, claims ...string) *appsv1.StatefulSet {
+		ss := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "splunk-stack1-standalone",
+				Namespace: "test",
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: selector},
+				UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+					Type: appsv1.RollingUpdateStatefulSetStrategyType,
+				},
+			},
+		}
+		for _, claim := range claims {
+			ss.Spec.VolumeClaimTemplates = append(ss.Spec.VolumeClaimTemplates, corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: claim, Labels: claimLabels},
+			})
+		}
+		return ss
+	}
+	selector := map[string]string{"app.kubernetes.io/instance": "splunk-stack1-standalone"}
+	desired := func() *appsv1.StatefulSet {
+		ss := newStatefulSet(selector, "pvc-etc", "pvc-var")
+		ss.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+		ss.SetOwnerReferences([]metav1.OwnerReference{resources.AsOwner(&cr)})
+		return ss
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-var-splunk-stack1-standalone-0", Namespace: "test"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-standalone-0",
+			Namespace: "test",
+			Labels:    map[string]string{"app": "splunk"},
+		},
+	}
+	owner := &unstructured.Unstructured{}
+	owner.SetName("stack1")
+	owner.SetNamespace("test")
+	owner.SetAnnotations(map[string]string{enterprise.AdoptAnnotation: "true"})
+	statefulSetCall := mockFuncCall{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"}
+	ownerCall := mockFuncCall{metaName: "*unstructured.Unstructured-test-stack1"}
+	etcCall := mockFuncCall{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack1-standalone-0"}
+	varCall := mockFuncCall{metaName: "*v1.PersistentVolumeClaim-test-pvc-var-splunk-stack1-standalone-0"}
+	podCall := mockFuncCall{metaName: "*v1.Pod-test-splunk-stack1-standalone-0"}
+
+	// existing StatefulSets are not changed unless adoption is requested
+	c := newMockClient()
+	c.state[statefulSetCall.metaName] = newStatefulSet(selector, "pvc-etc", "pvc-var")
+	phase, err := ApplyStatefulSet(c, desired())
+	if phase != enterprisev1.PhaseError || err == nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, error", phase, err, enterprisev1.PhaseError)
+	}
+	c.checkCalls(t, "TestApplyStatefulSetAdoption(not requested)", map[string][]mockFuncCall{"Get": {statefulSetCall, ownerCall}})
+	checkEvents(t, "TestApplyStatefulSetAdoption(not requested)", recorder,
+		"Warning AdoptionRequired StatefulSet splunk-stack1-standalone already exists and is not owned by Standalone stack1; set the enterprise.splunk.com/adopt annotation to \"true\" to adopt it")
+
+	// StatefulSets without the same volume claim templates cannot be adopted
+	c.state[getStateKey(owner)] = owner
+	c.state[statefulSetCall.metaName] = newStatefulSet(selector, "pvc-var")
+	c.resetCalls()
+	phase, err = ApplyStatefulSet(c, desired())
+	if phase != enterprisev1.PhaseError || err == nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, error", phase, err, enterprisev1.PhaseError)
+	}
+	c.checkCalls(t, "TestApplyStatefulSetAdoption(missing claim)", map[string][]mockFuncCall{"Get": {statefulSetCall, ownerCall}})
+	checkEvents(t, "TestApplyStatefulSetAdoption(missing claim)", recorder)
+
+	// StatefulSets with the same selector are updated to add an owner, and their claims are labeled
+	c.state[statefulSetCall.metaName] = newStatefulSet(selector, "pvc-etc", "pvc-var")
+	c.state[getStateKey(pvc)] = pvc.DeepCopy()
+	c.resetCalls()
+	phase, err = ApplyStatefulSet(c, desired())
+	if phase != enterprisev1.PhaseReady || err != nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseReady)
+	}
+	c.checkCalls(t, "TestApplyStatefulSetAdoption(update)", map[string][]mockFuncCall{
+		"Get":    {statefulSetCall, ownerCall, etcCall, varCall},
+		"Update": {varCall, statefulSetCall},
+	})
+	checkEvents(t, "TestApplyStatefulSetAdoption(update)", recorder, "Normal StatefulSetAdopted Adopted StatefulSet splunk-stack1-standalone")
+	adopted := c.state[statefulSetCall.metaName].(*appsv1.StatefulSet)
+	if metav1.GetControllerOf(adopted) == nil || adopted.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType {
+		t.Errorf("ApplyStatefulSet() adopted StatefulSet = %v; want owner and OnDelete update strategy", adopted)
+	}
+	if got := c.state[varCall.metaName].(*corev1.PersistentVolumeClaim).GetLabels(); got["app.kubernetes.io/part-of"] != "splunk-stack1-standalone" {
+		t.Errorf("ApplyStatefulSet() PVC labels = %v; want %v", got, claimLabels)
+	}
+
+	// StatefulSets with a different selector are replaced, after labeling their pods
+	c.state[statefulSetCall.metaName] = newStatefulSet(map[string]string{"app": "splunk"}, "pvc-etc", "pvc-var")
+	c.state[getStateKey(pod)] = pod.DeepCopy()
+	c.resetCalls()
+	phase, err = ApplyStatefulSet(c, desired())
+	if phase != enterprisev1.PhaseUpdating || err != nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseUpdating)
+	}
+	c.checkCalls(t, "TestApplyStatefulSetAdoption(replace)", map[string][]mockFuncCall{
+		"Get":    {statefulSetCall, ownerCall, etcCall, varCall, podCall},
+		"Update": {podCall},
+		"Delete": {statefulSetCall},
+	})
+	checkEvents(t, "TestApplyStatefulSetAdoption(replace)", recorder, "Normal StatefulSetAdopted Adopted StatefulSet splunk-stack1-standalone by replacing it")
+	if got := c.state[podCall.metaName].(*corev1.Pod).GetLabels(); got["app.kubernetes.io/instance"] != "splunk-stack1-standalone" || got["app"] != "splunk" {
+		t.Errorf("ApplyStatefulSet() pod labels = %v; want selector labels added", got)
+	}
+}
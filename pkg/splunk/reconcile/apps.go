@@ -0,0 +1,286 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+const (
+	// download URLs for app packages are valid for 7 days, the longest time allowed by S3
+	appsURLExpiration = 7 * 24 * time.Hour
+
+	// download URLs for app packages are regenerated when they expire in less than 1 day
+	appsURLRenewal = 24 * time.Hour
+)
+
+// AppRepoManager is used to install Splunk apps from an app repository stored in S3 buckets or Azure Blob Storage containers
+type AppRepoManager struct {
+	log            logr.Logger
+	cr             enterprisev1.MetaObject
+	spec           *enterprisev1.AppRepoSpec
+	status         *enterprisev1.AppRepoStatus
+	newS3Client    func(endpoint, region, accessKey, secretKey string) *splclient.S3Client
+	newAzureClient func(endpoint, storageAccount, sasToken string) *splclient.AzureBlobClient
+}
+
+// Apply lists the app packages available in each app source, and creates or updates a Kubernetes Secret with a default.yml
+// used to download and install them. It returns the app packages found, or nil if an app repository is not configured.
+// If a status is provided, app sources are only listed again once the poll interval has elapsed or the custom resource
+// has changed, and the app packages found the last time are returned until then.
+func (mgr *AppRepoManager) Apply(client ControllerClient, instanceType enterprise.InstanceType) ([]enterprisev1.AppStatus, error) {
+	if !enterprise.IsAppRepoConfigured(mgr.spec) {
+		if mgr.status != nil {
+			*mgr.status = enterprisev1.AppRepoStatus{}
+		}
+		return nil, nil
+	}
+	if mgr.status != nil && mgr.status.Apps != nil && mgr.status.ObservedGeneration == mgr.cr.GetObjectMeta().GetGeneration() &&
+		time.Since(mgr.status.LastCheckTime.Time) < enterprise.GetAppRepoPollInterval(mgr.spec) {
+		return mgr.status.Apps, nil
+	}
+
+	storageClient, err := mgr.getStorageClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// list app packages in each app source
+	apps := []enterprisev1.AppStatus{}
+	urls := []string{}
+	for _, source := range mgr.spec.AppSources {
+		objects, err := storageClient.ListObjects(source.Bucket, source.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list app packages for app source %s: %v", source.Name, err)
+		}
+		for _, obj := range objects {
+			if !enterprise.IsAppPackage(obj.Key) {
+				continue
+			}
+			url, err := storageClient.GetDownloadURL(source.Bucket, obj.Key, appsURLExpiration)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to get download URL for app package %s: %v", obj.Key, err)
+			}
+			apps = append(apps, enterprisev1.AppStatus{
+				Source:  source.Name,
+				Key:     obj.Key,
+				Version: strings.Trim(obj.ETag, "\""),
+			})
+			urls = append(urls, url)
+		}
+	}
+
+	checksum := enterprise.GetAppsChecksum(apps)
+	secret := enterprise.GetAppsSecret(mgr.cr, instanceType, urls, checksum, time.Now().Add(appsURLExpiration))
+	secret.SetOwnerReferences(append(secret.GetOwnerReferences(), resources.AsOwner(mgr.cr)))
+
+	scopedLog := mgr.log.WithValues("secret", secret.GetObjectMeta().GetName())
+
+	namespacedName := types.NamespacedName{Namespace: secret.GetNamespace(), Name: secret.GetName()}
+	var current corev1.Secret
+
+	err = client.Get(context.TODO(), namespacedName, &current)
+	if err == nil {
+		if isAppsSecretCurrent(&current, checksum) {
+			scopedLog.Info("No changes for app repository Secret")
+		} else {
+			scopedLog.Info("Updating existing app repository Secret", "checksum", checksum)
+			current.Annotations = secret.Annotations
+			current.Data = secret.Data
+			err = UpdateResource(client, &current)
+		}
+	} else {
+		err = CreateResource(client, secret)
+	}
+
+	if err == nil && mgr.status != nil {
+		mgr.status.LastCheckTime = metav1.Now()
+		mgr.status.ObservedGeneration = mgr.cr.GetObjectMeta().GetGeneration()
+		mgr.status.Apps = apps
+	}
+	return apps, err
+}
+
+// isAppsSecretCurrent returns true if an app repository Secret installs the same app package versions,
+// using download URLs that will not expire soon
+func isAppsSecretCurrent(secret *corev1.Secret, checksum string) bool {
+	if secret.GetAnnotations()[enterprise.AppsChecksumAnnotation] != checksum {
+		return false
+	}
+	expiration, err := time.Parse(time.RFC3339, secret.GetAnnotations()[enterprise.AppsExpirationAnnotation])
+	if err != nil {
+		return false
+	}
+	return time.Now().Add(appsURLRenewal).Before(expiration)
+}
+
+// getStorageClient for AppRepoManager returns an S3Client or AzureBlobClient for the app repository, using credentials
+// from its secretRef (if any)
+func (mgr *AppRepoManager) getStorageClient(client ControllerClient) (splclient.ObjectStorageClient, error) {
+	var secret corev1.Secret
+	if mgr.spec.SecretRef != "" {
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.spec.SecretRef}
+		err := client.Get(context.TODO(), namespacedName, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get secret for app repository: %v", err)
+		}
+	}
+
+	if mgr.spec.Provider == enterprise.AppRepoProviderAzure {
+		sasToken := string(secret.Data["azure_sas_token"])
+		if mgr.spec.SecretRef != "" && sasToken == "" {
+			return nil, fmt.Errorf("Secret for app repository is missing azure_sas_token")
+		}
+		return mgr.newAzureClient(mgr.spec.Endpoint, mgr.spec.StorageAccount, sasToken), nil
+	}
+
+	s3Client := mgr.newS3Client(mgr.spec.Endpoint, mgr.spec.Region, string(secret.Data["s3_access_key"]), string(secret.Data["s3_secret_key"]))
+	s3Client.VirtualHostedStyle = mgr.spec.URLStyle == enterprise.S3URLStyleVirtual
+	if mgr.spec.CASecretRef != "" {
+		var caSecret corev1.Secret
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.spec.CASecretRef}
+		err := client.Get(context.TODO(), namespacedName, &caSecret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get CA secret for app repository: %v", err)
+		}
+		if err = s3Client.SetCABundle(caSecret.Data["ca.crt"]); err != nil {
+			return nil, fmt.Errorf("Invalid CA secret for app repository: %v", err)
+		}
+	}
+	return s3Client, nil
+}
+
+// PremiumAppManager is used to run the steps required after installing premium apps from an app repository, such as
+// essinstall for Splunk Enterprise Security
+type PremiumAppManager struct {
+	log             logr.Logger
+	cr              enterprisev1.MetaObject
+	spec            *enterprisev1.AppRepoSpec
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply runs post-install steps on hosts for each premium app package that has been installed, and tracks their progress
+// in status. Steps are run once for each version of an app package. It returns true if any steps have completed since the
+// last time it was called.
+func (mgr *PremiumAppManager) Apply(hosts []string, instanceType enterprise.InstanceType, apps []enterprisev1.AppStatus, status *[]enterprisev1.PremiumAppStatus) (bool, error) {
+	var premiumApps []enterprisev1.PremiumAppStatus
+	var firstErr error
+	completed := false
+	for _, app := range apps {
+		appType := enterprise.GetAppSourceType(mgr.spec, app.Source)
+		if appType == "" {
+			continue
+		}
+
+		// keep the progress of steps for app package versions that were already seen
+		premiumApp := enterprisev1.PremiumAppStatus{AppType: appType, Source: app.Source, Key: app.Key, Version: app.Version}
+		for _, s := range *status {
+			if s.Source == app.Source && s.Key == app.Key && s.Version == app.Version {
+				premiumApp = s
+				break
+			}
+		}
+
+		// stop running steps after the first error, but keep tracking the progress of other app packages
+		if firstErr == nil {
+			done, err := mgr.runPostInstallSteps(hosts, instanceType, &premiumApp)
+			if err != nil {
+				firstErr = err
+			}
+			completed = completed || done
+		}
+		premiumApps = append(premiumApps, premiumApp)
+	}
+
+	*status = premiumApps
+	return completed, firstErr
+}
+
+// runPostInstallSteps for PremiumAppManager dispatches the post-install search for a premium app package on each host, or
+// checks the state of the search jobs that were already dispatched. It returns true if they have completed successfully.
+func (mgr *PremiumAppManager) runPostInstallSteps(hosts []string, instanceType enterprise.InstanceType, app *enterprisev1.PremiumAppStatus) (bool, error) {
+	scopedLog := mgr.log.WithValues("source", app.Source, "key", app.Key, "appType", app.AppType)
+
+	switch app.Phase {
+	case enterprisev1.PhaseReady, enterprisev1.PhaseError:
+		return false, nil
+
+	case enterprisev1.PhasePending:
+		for n, searchID := range app.SearchIDs {
+			if n >= len(hosts) {
+				break
+			}
+			state, err := mgr.getClient(hosts[n]).GetSearchJobState(searchID)
+			if err != nil {
+				return false, err
+			}
+			switch state {
+			case "DONE":
+				continue
+			case "FAILED":
+				scopedLog.Info("Premium app post-install steps failed", "host", hosts[n], "sid", searchID)
+				app.Phase = enterprisev1.PhaseError
+				recordEvent(mgr.cr, corev1.EventTypeWarning, "PremiumAppSetupFailed", "Post-install steps failed for premium app package %s on %s (sid=%s)", app.Key, hosts[n], searchID)
+				return false, nil
+			default:
+				scopedLog.Info("Waiting for premium app post-install steps to complete", "host", hosts[n], "sid", searchID, "state", state)
+				return false, nil
+			}
+		}
+		scopedLog.Info("Premium app post-install steps completed")
+		app.Phase = enterprisev1.PhaseReady
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "PremiumAppSetupCompleted", "Post-install steps completed for premium app package %s", app.Key)
+		return true, nil
+	}
+
+	search := enterprise.GetPremiumAppPostInstallSearch(app.AppType, instanceType)
+	if search == "" {
+		app.Phase = enterprisev1.PhaseReady
+		return false, nil
+	}
+
+	searchIDs := []string{}
+	for _, host := range hosts {
+		searchID, err := mgr.getClient(host).DispatchSearch(search)
+		if err != nil {
+			return false, err
+		}
+		scopedLog.Info("Dispatched premium app post-install steps", "host", host, "sid", searchID)
+		searchIDs = append(searchIDs, searchID)
+	}
+	app.SearchIDs = searchIDs
+	app.Phase = enterprisev1.PhasePending
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "PremiumAppSetupStarted", "Started post-install steps for premium app package %s", app.Key)
+	return false, nil
+}
+
+// getClient for PremiumAppManager returns a SplunkClient for the host
+func (mgr *PremiumAppManager) getClient(host string) *splclient.SplunkClient {
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
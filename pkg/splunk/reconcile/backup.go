@@ -0,0 +1,452 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplySplunkBackup reconciles the state of a backup of a Splunk Enterprise custom resource.
+func ApplySplunkBackup(client ControllerClient, cr *enterprisev1.SplunkBackup) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplySplunkBackup(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplySplunkBackup").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateSplunkBackupSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	if cr.Status.Backups == nil {
+		cr.Status.Backups = []enterprisev1.BackupStatus{}
+	}
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		err = client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			scopedLog.Error(err, "Status update failed")
+		}
+	}()
+
+	// take a new backup if one is due, or check the progress of a backup already in progress
+	mgr := BackupManager{log: scopedLog, cr: cr, newSplunkClient: getSplunkClientFactory(client)}
+	phase, err := mgr.Apply(client, time.Now())
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+
+	// once the latest backup is no longer in progress, wait until the next scheduled backup is due
+	if phase != enterprisev1.PhasePending {
+		if cr.Spec.Schedule == "" {
+			result.Requeue = false
+		} else {
+			interval, _ := time.ParseDuration(cr.Spec.Schedule)
+			lastStarted := cr.Status.Backups[len(cr.Status.Backups)-1].StartTime.Time
+			if next := time.Until(lastStarted.Add(interval)); next > result.RequeueAfter {
+				result.RequeueAfter = next
+			}
+		}
+	}
+	return result, nil
+}
+
+// BackupManager is used to take backups of the persistent volumes used by a Splunk Enterprise custom resource
+type BackupManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.SplunkBackup
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply starts a new backup if one is due, and updates the status of a backup that is in progress. splunkd is quiesced
+// before VolumeSnapshots are created, and resumed once the storage system has taken all of them (or the backup fails).
+// Backups beyond the number to retain, and their VolumeSnapshots, are deleted once a new backup is ready to use.
+func (mgr *BackupManager) Apply(c ControllerClient, now time.Time) (enterprisev1.ResourcePhase, error) {
+	backup := mgr.getBackupInProgress()
+	if backup == nil {
+		if !enterprise.IsBackupDue(mgr.cr, now) {
+			return mgr.getLatestBackupPhase(), nil
+		}
+		var err error
+		backup, err = mgr.startBackup(c, now)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
+	if backup.Error == "" {
+		err := mgr.updateSnapshotStatus(c, backup)
+		if err != nil {
+			mgr.log.Error(err, "Backup failed", "backup", backup.Name)
+			backup.Error = err.Error()
+		}
+	}
+
+	// resume splunkd once the storage system has taken all of the snapshots
+	if backup.Quiesced && (backup.Error != "" || isBackupCreated(backup)) {
+		err := mgr.quiesce(c, false)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		backup.Quiesced = false
+	}
+
+	if backup.Error != "" {
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "BackupFailed", fmt.Sprintf("backup %s failed: %s", backup.Name, backup.Error)
+		return enterprisev1.PhaseError, nil
+	}
+	if !isBackupReadyToUse(backup) {
+		mgr.log.Info("Waiting for VolumeSnapshots to become ready", "backup", backup.Name)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "WaitingForSnapshots", fmt.Sprintf("waiting for VolumeSnapshots of backup %s to be ready to use", backup.Name)
+		return enterprisev1.PhasePending, nil
+	}
+
+	mgr.log.Info("Backup is ready to use", "backup", backup.Name)
+	backup.ReadyToUse = true
+	return enterprisev1.PhaseReady, mgr.deleteExpiredBackups(c)
+}
+
+// getBackupInProgress for BackupManager returns the status of the latest backup if it is still in progress, or nil
+func (mgr *BackupManager) getBackupInProgress() *enterprisev1.BackupStatus {
+	if len(mgr.cr.Status.Backups) == 0 {
+		return nil
+	}
+	backup := &mgr.cr.Status.Backups[len(mgr.cr.Status.Backups)-1]
+	if backup.ReadyToUse || (backup.Error != "" && !backup.Quiesced) {
+		return nil
+	}
+	return backup
+}
+
+// getLatestBackupPhase for BackupManager returns the phase of the latest backup, when no backup is in progress
+func (mgr *BackupManager) getLatestBackupPhase() enterprisev1.ResourcePhase {
+	if len(mgr.cr.Status.Backups) == 0 || mgr.cr.Status.Backups[len(mgr.cr.Status.Backups)-1].Error != "" {
+		return enterprisev1.PhaseError
+	}
+	return enterprisev1.PhaseReady
+}
+
+// startBackup for BackupManager quiesces splunkd and creates a VolumeSnapshot of each persistent volume claim used by
+// the custom resource that is backed up. It returns the status of the new backup.
+func (mgr *BackupManager) startBackup(c ControllerClient, now time.Time) (*enterprisev1.BackupStatus, error) {
+	pvcNames, err := mgr.getVolumeClaimNames(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep a copy of the secrets used by the target, which are needed to restore instances from the backup
+	name := enterprise.GetSplunkBackupName(mgr.cr.GetIdentifier(), now)
+	err = mgr.backupSecrets(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// quiesce splunkd before taking snapshots, so that all volumes are captured in a consistent state
+	err = mgr.quiesce(c, true)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr.log.Info("Starting backup", "backup", name, "target", mgr.cr.Spec.TargetRef.Name)
+	mgr.cr.Status.Backups = append(mgr.cr.Status.Backups, enterprisev1.BackupStatus{
+		Name:      name,
+		StartTime: metav1.NewTime(now),
+		Quiesced:  true,
+		Snapshots: []enterprisev1.VolumeSnapshotStatus{},
+	})
+	backup := &mgr.cr.Status.Backups[len(mgr.cr.Status.Backups)-1]
+
+	for _, pvcName := range pvcNames {
+		snapshot := enterprise.GetVolumeSnapshot(mgr.cr, enterprise.GetVolumeSnapshotName(name, pvcName), pvcName)
+		backup.Snapshots = append(backup.Snapshots, enterprisev1.VolumeSnapshotStatus{
+			Name:                      snapshot.GetName(),
+			PersistentVolumeClaimName: pvcName,
+		})
+		err = c.Create(context.TODO(), snapshot)
+		if err != nil && !errors.IsAlreadyExists(err) {
+			backup.Error = fmt.Sprintf("Unable to create VolumeSnapshot %s: %v", snapshot.GetName(), err)
+			break
+		}
+	}
+
+	return backup, nil
+}
+
+// backupSecrets for BackupManager creates a copy of the secrets used by the custom resource that is backed up
+func (mgr *BackupManager) backupSecrets(c ControllerClient, backupName string) error {
+	instanceType := enterprise.GetBackupInstanceTypes(mgr.cr.Spec.TargetRef.Kind)[0]
+	secretsName := enterprise.GetSplunkSecretsName(mgr.cr.Spec.TargetRef.Name, instanceType)
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: secretsName}
+	var secrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+	err = c.Create(context.TODO(), enterprise.GetBackupSecrets(mgr.cr, backupName, &secrets))
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// getVolumeClaimNames for BackupManager returns the names of the persistent volume claims used by every pod of the custom resource that is backed up
+func (mgr *BackupManager) getVolumeClaimNames(c ControllerClient) ([]string, error) {
+	statefulSetNames, err := mgr.getStatefulSetNames(c)
+	if err != nil {
+		return nil, err
+	}
+
+	pvcNames := []string{}
+	for _, statefulSetName := range statefulSetNames {
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: statefulSetName}
+		var statefulSet appsv1.StatefulSet
+		err := c.Get(context.TODO(), namespacedName, &statefulSet)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find StatefulSet %s: %v", statefulSetName, err)
+		}
+		for n := int32(0); n < *statefulSet.Spec.Replicas; n++ {
+			for _, vol := range []string{"pvc-etc", "pvc-var"} {
+				if isEphemeralVolume(&statefulSet, vol) {
+					// ephemeral storage cannot be backed up
+					continue
+				}
+				pvcNames = append(pvcNames, fmt.Sprintf("%s-%s-%d", vol, statefulSetName, n))
+			}
+		}
+	}
+	return pvcNames, nil
+}
+
+// getStatefulSetNames for BackupManager returns the names of the StatefulSets used by the custom resource that is backed up
+func (mgr *BackupManager) getStatefulSetNames(c ControllerClient) ([]string, error) {
+	targetName := mgr.cr.Spec.TargetRef.Name
+
+	// the indexers of a multisite indexer cluster use a separate StatefulSet for each site
+	var sites []enterprisev1.IndexerClusterSiteSpec
+	if mgr.cr.Spec.TargetRef.Kind == "IndexerCluster" {
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: targetName}
+		var idxc enterprisev1.IndexerCluster
+		err := c.Get(context.TODO(), namespacedName, &idxc)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find IndexerCluster %s: %v", targetName, err)
+		}
+		if idxc.Spec.ClusterMasterRef.Name != "" {
+			return nil, fmt.Errorf("Backups are not supported for IndexerCluster %s, which uses clusterMasterRef", targetName)
+		}
+		if enterprise.IsExternalClusterMasterConfigured(&idxc.Spec.ExternalClusterMaster) {
+			return nil, fmt.Errorf("Backups are not supported for IndexerCluster %s, which uses externalClusterMaster", targetName)
+		}
+		sites = idxc.Spec.Sites
+	}
+
+	names := []string{}
+	for _, instanceType := range enterprise.GetBackupInstanceTypes(mgr.cr.Spec.TargetRef.Kind) {
+		if instanceType == enterprise.SplunkIndexer && len(sites) > 0 {
+			for _, site := range sites {
+				names = append(names, enterprise.GetSplunkStatefulsetName(instanceType, enterprise.GetSplunkSiteIdentifier(targetName, site.Name)))
+			}
+			continue
+		}
+		names = append(names, enterprise.GetSplunkStatefulsetName(instanceType, targetName))
+	}
+	return names, nil
+}
+
+// quiesce for BackupManager quiesces or resumes splunkd for the custom resource that is backed up. Indexer clusters are put
+// into maintenance mode to halt bucket replication, and search head cluster members are put into detention to stop new
+// searches and artifact replication. Other instances do not need to be quiesced.
+func (mgr *BackupManager) quiesce(c ControllerClient, enable bool) error {
+	targetName := mgr.cr.Spec.TargetRef.Name
+
+	switch mgr.cr.Spec.TargetRef.Kind {
+	case "IndexerCluster":
+		password, err := mgr.getAdminPassword(c, enterprise.SplunkIndexer)
+		if err != nil {
+			return err
+		}
+		fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, targetName, false))
+		mgr.log.Info("Setting indexer cluster maintenance mode", "target", targetName, "enabled", enable)
+		err = mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", password).SetClusterMasterMaintenanceMode(enable)
+		if err != nil {
+			return fmt.Errorf("Unable to set maintenance mode for indexer cluster %s: %v", targetName, err)
+		}
+
+	case "SearchHeadCluster":
+		password, err := mgr.getAdminPassword(c, enterprise.SplunkSearchHead)
+		if err != nil {
+			return err
+		}
+		statefulSetName := enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, targetName)
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: statefulSetName}
+		var statefulSet appsv1.StatefulSet
+		err = c.Get(context.TODO(), namespacedName, &statefulSet)
+		if err != nil {
+			return fmt.Errorf("Unable to find StatefulSet %s: %v", statefulSetName, err)
+		}
+		for n := int32(0); n < *statefulSet.Spec.Replicas; n++ {
+			fqdnName := enterprise.GetSplunkStatefulsetURL(mgr.cr.GetNamespace(), enterprise.SplunkSearchHead, targetName, n, false)
+			mgr.log.Info("Setting search head detention", "host", fqdnName, "enabled", enable)
+			err = mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", password).SetSearchHeadDetention(enable)
+			if err != nil {
+				return fmt.Errorf("Unable to set detention for %s: %v", fqdnName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getAdminPassword for BackupManager returns the admin password used by instances of the custom resource that is backed up
+func (mgr *BackupManager) getAdminPassword(c ControllerClient, instanceType enterprise.InstanceType) (string, error) {
+	secretsName := enterprise.GetSplunkSecretsName(mgr.cr.Spec.TargetRef.Name, instanceType)
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: secretsName}
+	var secrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+	return enterprise.GetAppliedAdminPassword(&secrets), nil
+}
+
+// updateSnapshotStatus for BackupManager updates the status of each VolumeSnapshot taken for a backup, and returns error if any have failed
+func (mgr *BackupManager) updateSnapshotStatus(c ControllerClient, backup *enterprisev1.BackupStatus) error {
+	for idx := range backup.Snapshots {
+		snapshotStatus := &backup.Snapshots[idx]
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: snapshotStatus.Name}
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(enterprise.VolumeSnapshotGroupVersionKind)
+		err := c.Get(context.TODO(), namespacedName, snapshot)
+		if err != nil {
+			return fmt.Errorf("Unable to get VolumeSnapshot %s: %v", snapshotStatus.Name, err)
+		}
+
+		snapshotStatus.ReadyToUse, _, _ = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		_, snapshotStatus.Created, _ = unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found && !snapshotStatus.ReadyToUse {
+			return fmt.Errorf("VolumeSnapshot %s failed: %s", snapshotStatus.Name, message)
+		}
+
+		snapshotStatus.ContentName, _, _ = unstructured.NestedString(snapshot.Object, "status", "boundVolumeSnapshotContentName")
+		if snapshotStatus.ContentName != "" && snapshotStatus.SnapshotHandle == "" {
+			snapshotStatus.SnapshotHandle = mgr.getSnapshotHandle(c, snapshotStatus.ContentName)
+		}
+	}
+	return nil
+}
+
+// getSnapshotHandle for BackupManager returns the CSI snapshot handle of a VolumeSnapshotContent, or an empty string if it is not
+// available. VolumeSnapshotContents are cluster-scoped, so this requires the operator's cluster role.
+func (mgr *BackupManager) getSnapshotHandle(c ControllerClient, contentName string) string {
+	content := &unstructured.Unstructured{}
+	content.SetGroupVersionKind(enterprise.VolumeSnapshotContentGroupVersionKind)
+	err := c.Get(context.TODO(), types.NamespacedName{Name: contentName}, content)
+	if err != nil {
+		mgr.log.Info("Unable to get VolumeSnapshotContent", "contentName", contentName, "error", err.Error())
+		return ""
+	}
+	handle, _, _ := unstructured.NestedString(content.Object, "status", "snapshotHandle")
+	return handle
+}
+
+// deleteExpiredBackups for BackupManager deletes the oldest backups, their VolumeSnapshots and secrets, until no more than the number to retain remain
+func (mgr *BackupManager) deleteExpiredBackups(c ControllerClient) error {
+	for int32(len(mgr.cr.Status.Backups)) > mgr.cr.Spec.Retain {
+		expired := mgr.cr.Status.Backups[0]
+		mgr.log.Info("Deleting expired backup", "backup", expired.Name)
+		for _, snapshotStatus := range expired.Snapshots {
+			snapshot := &unstructured.Unstructured{}
+			snapshot.SetGroupVersionKind(enterprise.VolumeSnapshotGroupVersionKind)
+			snapshot.SetName(snapshotStatus.Name)
+			snapshot.SetNamespace(mgr.cr.GetNamespace())
+			err := c.Delete(context.Background(), snapshot)
+			if err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("Unable to delete VolumeSnapshot %s: %v", snapshotStatus.Name, err)
+			}
+		}
+		secrets := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: enterprise.GetSplunkBackupSecretsName(expired.Name), Namespace: mgr.cr.GetNamespace()},
+		}
+		err := c.Delete(context.Background(), secrets)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Unable to delete secrets for backup %s: %v", expired.Name, err)
+		}
+		mgr.cr.Status.Backups = mgr.cr.Status.Backups[1:]
+	}
+	return nil
+}
+
+// isBackupCreated returns true if the storage system has taken all of the snapshots for a backup
+func isBackupCreated(backup *enterprisev1.BackupStatus) bool {
+	for _, snapshotStatus := range backup.Snapshots {
+		if !snapshotStatus.Created {
+			return false
+		}
+	}
+	return true
+}
+
+// isBackupReadyToUse returns true if all of the snapshots for a backup are ready to be used to restore volumes
+func isBackupReadyToUse(backup *enterprisev1.BackupStatus) bool {
+	for _, snapshotStatus := range backup.Snapshots {
+		if !snapshotStatus.ReadyToUse {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestBackupManagerApply(t *testing.T) {
+	cr := enterprisev1.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SplunkBackupSpec{
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"},
+			Schedule:  "24h",
+			Retain:    1,
+		},
+	}
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	replicas := int32(1)
+	initObjects := []runtime.Object{
+		&idxc,
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-cluster-master", Namespace: "test"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-indexer", Namespace: "test"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		},
+		enterprise.GetSplunkSecrets(&idxc, enterprise.SplunkIndexer, nil, nil),
+	}
+	c := newMockClient()
+	for _, obj := range initObjects {
+		c.state[getStateKey(obj)] = obj
+	}
+
+	var mockSplunkClient *spltest.MockHTTPClient
+	setMaintenanceMode := func(modes ...string) {
+		mockSplunkClient = &spltest.MockHTTPClient{}
+		for _, mode := range modes {
+			mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+				Method: "POST",
+				URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/maintenance_mode?mode=" + mode,
+				Status: 200,
+			})
+		}
+	}
+	mgr := &BackupManager{
+		log: log.WithName("TestBackupManagerApply"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	pvcNames := []string{
+		"pvc-etc-splunk-stack1-cluster-master-0",
+		"pvc-var-splunk-stack1-cluster-master-0",
+		"pvc-etc-splunk-stack1-indexer-0",
+		"pvc-var-splunk-stack1-indexer-0",
+	}
+	snapshotCalls := func(backupName string) []mockFuncCall {
+		calls := []mockFuncCall{}
+		for _, pvcName := range pvcNames {
+			calls = append(calls, mockFuncCall{metaName: "*unstructured.Unstructured-test-" + enterprise.GetVolumeSnapshotName(backupName, pvcName)})
+		}
+		return calls
+	}
+	setSnapshotStatus := func(backupName string, value interface{}, fields ...string) {
+		for _, call := range snapshotCalls(backupName) {
+			snapshot := c.state[call.metaName].(*unstructured.Unstructured)
+			unstructured.SetNestedField(snapshot.Object, value, append([]string{"status"}, fields...)...)
+		}
+	}
+	checkApply := func(method string, now time.Time, wantPhase enterprisev1.ResourcePhase) {
+		phase, err := mgr.Apply(c, now)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", method, err)
+		}
+		if phase != wantPhase {
+			t.Errorf("%s phase = %s; want %s", method, phase, wantPhase)
+		}
+		mockSplunkClient.CheckRequests(t, method)
+	}
+	idxcCall := mockFuncCall{metaName: "*v1beta1.IndexerCluster-test-stack1"}
+	cmCall := mockFuncCall{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"}
+	idxCall := mockFuncCall{metaName: "*v1.StatefulSet-test-splunk-stack1-indexer"}
+	secretsCall := mockFuncCall{metaName: "*v1.Secret-test-" + enterprise.GetSplunkSecretsName("stack1", enterprise.SplunkIndexer)}
+	backupSecretsCall := func(backupName string) mockFuncCall {
+		return mockFuncCall{metaName: "*v1.Secret-test-" + enterprise.GetSplunkBackupSecretsName(backupName)}
+	}
+
+	// new backup copies secrets, quiesces the indexer cluster and creates a snapshot of each volume
+	now := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	backupName := "backup1-20200508100000"
+	setMaintenanceMode("true")
+	checkApply("BackupManager.Apply(start)", now, enterprisev1.PhasePending)
+	c.checkCalls(t, "BackupManager.Apply(start)", map[string][]mockFuncCall{
+		"Get":    append([]mockFuncCall{idxcCall, cmCall, idxCall, secretsCall, secretsCall}, snapshotCalls(backupName)...),
+		"Create": append([]mockFuncCall{backupSecretsCall(backupName)}, snapshotCalls(backupName)...),
+	})
+	if len(cr.Status.Backups) != 1 || cr.Status.Backups[0].Name != backupName || !cr.Status.Backups[0].Quiesced || len(cr.Status.Backups[0].Snapshots) != 4 {
+		t.Errorf("BackupManager.Apply(start) backups = %v; want %s quiesced with 4 snapshots", cr.Status.Backups, backupName)
+	}
+	backupSecrets := c.state[backupSecretsCall(backupName).metaName].(*corev1.Secret)
+	if string(backupSecrets.Data["password"]) != string(initObjects[3].(*corev1.Secret).Data["password"]) {
+		t.Errorf("BackupManager.Apply(start) did not copy secrets for backup %s", backupName)
+	}
+
+	// indexer cluster is resumed once all snapshots have been taken
+	c.resetCalls()
+	setSnapshotStatus(backupName, "2020-05-08T10:00:01Z", "creationTime")
+	setMaintenanceMode("false")
+	checkApply("BackupManager.Apply(created)", now, enterprisev1.PhasePending)
+	c.checkCalls(t, "BackupManager.Apply(created)", map[string][]mockFuncCall{"Get": append(snapshotCalls(backupName), secretsCall)})
+	if cr.Status.Backups[0].Quiesced {
+		t.Errorf("BackupManager.Apply(created) did not resume indexer cluster")
+	}
+
+	// backup is ready once all snapshots are ready to use, and records their snapshot handles
+	c.resetCalls()
+	setSnapshotStatus(backupName, true, "readyToUse")
+	setSnapshotStatus(backupName, "snapcontent-1", "boundVolumeSnapshotContentName")
+	content := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"snapshotHandle": "snap-0123456789"}}}
+	content.SetName("snapcontent-1")
+	c.state["*unstructured.Unstructured--snapcontent-1"] = content
+	setMaintenanceMode()
+	checkApply("BackupManager.Apply(ready)", now, enterprisev1.PhaseReady)
+	backup := cr.Status.Backups[0]
+	if !backup.ReadyToUse || backup.Snapshots[0].ContentName != "snapcontent-1" || backup.Snapshots[0].SnapshotHandle != "snap-0123456789" {
+		t.Errorf("BackupManager.Apply(ready) backup = %v; want ready to use with snapshot handle snap-0123456789", backup)
+	}
+
+	// nothing to do until the next scheduled backup is due
+	c.resetCalls()
+	checkApply("BackupManager.Apply(not-due)", now.Add(time.Hour), enterprisev1.PhaseReady)
+	c.checkCalls(t, "BackupManager.Apply(not-due)", map[string][]mockFuncCall{})
+
+	// failed snapshots resume the indexer cluster and are recorded as errors
+	c.resetCalls()
+	now = now.Add(24 * time.Hour)
+	failedName := "backup1-20200509100000"
+	setMaintenanceMode("true")
+	checkApply("BackupManager.Apply(scheduled)", now, enterprisev1.PhasePending)
+	setSnapshotStatus(failedName, "Failed to create snapshot", "error", "message")
+	setMaintenanceMode("false")
+	checkApply("BackupManager.Apply(failed)", now, enterprisev1.PhaseError)
+	if len(cr.Status.Backups) != 2 || cr.Status.Backups[1].Error == "" || cr.Status.Backups[1].Quiesced {
+		t.Errorf("BackupManager.Apply(failed) backups = %v; want resumed backup with error", cr.Status.Backups)
+	}
+	c.resetCalls()
+	checkApply("BackupManager.Apply(failed-not-due)", now.Add(time.Hour), enterprisev1.PhaseError)
+	c.checkCalls(t, "BackupManager.Apply(failed-not-due)", map[string][]mockFuncCall{})
+
+	// expired backups and their secrets are deleted once a new backup is ready to use
+	now = now.Add(24 * time.Hour)
+	newName := "backup1-20200510100000"
+	setMaintenanceMode("true")
+	checkApply("BackupManager.Apply(retry)", now, enterprisev1.PhasePending)
+	setSnapshotStatus(newName, "2020-05-10T10:00:01Z", "creationTime")
+	setSnapshotStatus(newName, true, "readyToUse")
+	c.resetCalls()
+	setMaintenanceMode("false")
+	checkApply("BackupManager.Apply(expired)", now, enterprisev1.PhaseReady)
+	c.checkCalls(t, "BackupManager.Apply(expired)", map[string][]mockFuncCall{
+		"Get":    append(snapshotCalls(newName), secretsCall),
+		"Delete": append(append(snapshotCalls(backupName), backupSecretsCall(backupName)), append(snapshotCalls(failedName), backupSecretsCall(failedName))...),
+	})
+	if len(cr.Status.Backups) != 1 || cr.Status.Backups[0].Name != newName {
+		t.Errorf("BackupManager.Apply(expired) backups = %v; want %s only", cr.Status.Backups, newName)
+	}
+
+	// errors are returned if the target cannot be found
+	cr.Status.Backups = nil
+	mgr.cr.Spec.TargetRef.Name = "stack2"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if _, err := mgr.Apply(c, now); err == nil {
+		t.Errorf("BackupManager.Apply() returned nil; want error for missing IndexerCluster")
+	}
+	if len(cr.Status.Backups) != 0 {
+		t.Errorf("BackupManager.Apply() backups = %v; want none when backup cannot be started", cr.Status.Backups)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// maximum number of KV store documents copied to replacement search head cluster members at a time
+const kvStoreSyncBatchSize = 1000
+
+// ApplyReplacement for SearchHeadClusterPodManager handles blue/green upgrades of a search head cluster. When the image of the
+// members serving searches differs from the image of the replacement StatefulSet provided, replacement members are created
+// alongside them, and searches are switched over to the replacement members once they are healthy and their apps and KV store
+// have been synced. Members that were replaced are deleted during the next reconcile. It returns true until members that
+// were replaced have been deleted.
+func (mgr *SearchHeadClusterPodManager) ApplyReplacement(c ControllerClient, replacement *appsv1.StatefulSet) (bool, error) {
+	status := &mgr.cr.Status.BlueGreen
+	var current enterprisev1.CommonSplunkSpec
+	err := keepCurrentImage(c, mgr.cr.GetNamespace(), enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, mgr.getIdentifier()), &current)
+	if err != nil {
+		return false, err
+	}
+
+	image := getSplunkContainerImage(replacement)
+	if !enterprise.IsBlueGreenUpgradeEnabled(&mgr.cr.Spec) || current.Image == "" || current.Image == image {
+		// clean up members replaced by the most recent switch, or a replacement that is no longer needed
+		if status.Image != "" {
+			err = mgr.deleteStandbyMembers(c)
+			if err != nil {
+				return false, err
+			}
+			status.Image = ""
+			status.Phase = ""
+		}
+		return false, nil
+	}
+
+	// members serving searches keep their current image until they have been replaced
+	requestedImage := mgr.cr.Spec.Image
+	mgr.cr.Spec.Image = current.Image
+	status.Image = image
+
+	// replacement members are created after the deployer has been upgraded, so that they receive apps from it
+	if mgr.cr.Status.DeployerPhase != enterprisev1.PhaseReady {
+		status.Phase = enterprisev1.PhasePending
+		return true, nil
+	}
+
+	standby := SearchHeadClusterPodManager{log: mgr.log, cr: mgr.cr, secrets: mgr.secrets, newSplunkClient: mgr.newSplunkClient, color: enterprise.GetSearchHeadClusterStandbyColor(mgr.cr)}
+	err = ApplyService(c, enterprise.GetSearchHeadClusterService(mgr.cr, standby.color, true))
+	if err != nil {
+		return true, err
+	}
+	err = ApplyPodDisruptionBudget(c, enterprise.GetSplunkPodDisruptionBudget(replacement, mgr.cr.Spec.MaxUnavailable))
+	if err != nil {
+		return true, err
+	}
+	replacementManager := DefaultStatefulSetPodManager{}
+	status.Phase, err = replacementManager.Update(c, replacement, mgr.cr.Spec.Replicas)
+	if err != nil || status.Phase != enterprisev1.PhaseReady {
+		return true, err
+	}
+
+	err = standby.checkReplacementHealth()
+	if err != nil {
+		mgr.log.Info("Waiting for replacement search head cluster members to become healthy", "reason", err.Error())
+		status.Phase = enterprisev1.PhasePending
+		return true, nil
+	}
+
+	// sync apps from the deployer, and KV store collections from members serving searches
+	err = standby.pushDeployerBundle()
+	if err != nil {
+		return true, err
+	}
+	count, err := syncKVStore(mgr.getClient(0), standby.getClient(0))
+	if err != nil {
+		return true, err
+	}
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "KVStoreSynced", "Copied %d KV store documents to replacement search head cluster members", count)
+
+	// switch searches over to the replacement members
+	status.ActiveColor = standby.color
+	status.LastSwitchTime = metav1.Now()
+	mgr.cr.Spec.Image = requestedImage
+	err = ApplyService(c, enterprise.GetSearchHeadClusterService(mgr.cr, standby.color, false))
+	if err != nil {
+		return true, err
+	}
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "SearchHeadClusterSwitched", "Switched searches to %s search head cluster members running %s", standby.color, image)
+	return true, nil
+}
+
+// checkReplacementHealth for SearchHeadClusterPodManager returns an error if the captain is not ready, or if the KV store is
+// not ready on any of the members. Unlike checkHealth, it does not rely on status, which tracks the members serving searches.
+func (mgr *SearchHeadClusterPodManager) checkReplacementHealth() error {
+	captainInfo, err := mgr.getClient(0).GetSearchHeadCaptainInfo()
+	if err != nil {
+		return fmt.Errorf("unable to get captain info: %v", err)
+	}
+	if !captainInfo.ServiceReady || !captainInfo.Initialized {
+		return fmt.Errorf("search head cluster captain is not ready")
+	}
+	hosts := strings.Split(enterprise.GetSplunkStatefulsetUrls(mgr.cr.GetNamespace(), enterprise.SplunkSearchHead, mgr.getIdentifier(), mgr.cr.Spec.Replicas, false), ",")
+	return checkKVStoreHealth(hosts, mgr.secrets, mgr.newSplunkClient)
+}
+
+// deleteStandbyMembers for SearchHeadClusterPodManager deletes the StatefulSet, PodDisruptionBudget, headless Service and
+// PVCs of the search head cluster members that are not serving searches, if they exist
+func (mgr *SearchHeadClusterPodManager) deleteStandbyMembers(c ControllerClient) error {
+	color := enterprise.GetSearchHeadClusterStandbyColor(mgr.cr)
+	identifier := enterprise.GetSearchHeadClusterIdentifier(mgr.cr, color)
+	namespace := mgr.cr.GetNamespace()
+	statefulSetName := enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, identifier)
+	mgr.log.Info("Deleting search head cluster members that are not serving searches", "statefulSet", statefulSetName)
+
+	objects := []ResourceObject{
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: statefulSetName, Namespace: namespace}},
+		&policyv1beta1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: statefulSetName, Namespace: namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: enterprise.GetSplunkServiceName(enterprise.SplunkSearchHead, identifier, true), Namespace: namespace}},
+	}
+	for _, obj := range objects {
+		err := c.Delete(context.Background(), obj)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels(enterprise.GetSearchHeadClusterLabels(mgr.cr, color)),
+	}
+	pvclist := corev1.PersistentVolumeClaimList{}
+	err := c.List(context.Background(), &pvclist, listOpts...)
+	if err != nil {
+		return err
+	}
+	for _, pvc := range pvclist.Items {
+		mgr.log.Info("Deleting PVC", "name", pvc.ObjectMeta.Name)
+		err = c.Delete(context.Background(), &pvc)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSplunkContainerImage returns the image used by the splunk container of a StatefulSet
+func getSplunkContainerImage(statefulSet *appsv1.StatefulSet) string {
+	for _, container := range statefulSet.Spec.Template.Spec.Containers {
+		if container.Name == "splunk" {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// syncKVStore copies all KV store collections and their documents from a source search head to a target search head, and
+// returns the number of documents copied. Documents that already exist on the target are replaced.
+func syncKVStore(source, target *splclient.SplunkClient) (int, error) {
+	collections, err := source.GetKVStoreCollections()
+	if err != nil {
+		return 0, fmt.Errorf("unable to get KV store collections: %v", err)
+	}
+	targetCollections, err := target.GetKVStoreCollections()
+	if err != nil {
+		return 0, fmt.Errorf("unable to get KV store collections of replacement: %v", err)
+	}
+	existing := make(map[splclient.KVStoreCollectionInfo]bool)
+	for _, collection := range targetCollections {
+		existing[collection] = true
+	}
+
+	count := 0
+	for _, collection := range collections {
+		if !existing[collection] {
+			err = target.CreateKVStoreCollection(collection.App, collection.Name)
+			if err != nil {
+				return count, fmt.Errorf("unable to create KV store collection %s/%s: %v", collection.App, collection.Name, err)
+			}
+		}
+		for skip := 0; ; skip += kvStoreSyncBatchSize {
+			documents, err := source.GetKVStoreCollectionData(collection.App, collection.Name, skip, kvStoreSyncBatchSize)
+			if err != nil {
+				return count, fmt.Errorf("unable to get documents of KV store collection %s/%s: %v", collection.App, collection.Name, err)
+			}
+			if len(documents) == 0 {
+				break
+			}
+			err = target.SaveKVStoreCollectionData(collection.App, collection.Name, documents)
+			if err != nil {
+				return count, fmt.Errorf("unable to save documents of KV store collection %s/%s: %v", collection.App, collection.Name, err)
+			}
+			count += len(documents)
+			if len(documents) < kvStoreSyncBatchSize {
+				break
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestSearchHeadClusterApplyReplacement(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "SearchHeadCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Replicas = 1
+	cr.Spec.UpgradeStrategy = "BlueGreen"
+	cr.Spec.Image = "splunk/splunk:8.0.5"
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &SearchHeadClusterPodManager{
+		log:     log.WithName("TestSearchHeadClusterApplyReplacement"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	c := newMockClient()
+
+	test := func(testname string, wantReplacing bool, wantImage string, wantPhase enterprisev1.ResourcePhase) {
+		replacement, err := enterprise.GetSearchHeadReplacementStatefulSet(&cr)
+		if err != nil {
+			t.Fatalf("%s: GetSearchHeadReplacementStatefulSet() returned %v; want nil", testname, err)
+		}
+		replacing, err := mgr.ApplyReplacement(c, replacement)
+		if err != nil || replacing != wantReplacing {
+			t.Errorf("%s: ApplyReplacement() = %t, %v; want %t, nil", testname, replacing, err, wantReplacing)
+		}
+		if cr.Status.BlueGreen.Image != wantImage {
+			t.Errorf("%s: ApplyReplacement() status.blueGreen.image = %s; want %s", testname, cr.Status.BlueGreen.Image, wantImage)
+		}
+		if cr.Status.BlueGreen.Phase != wantPhase {
+			t.Errorf("%s: ApplyReplacement() status.blueGreen.phase = %s; want %s", testname, cr.Status.BlueGreen.Phase, wantPhase)
+		}
+		mockSplunkClient.CheckRequests(t, testname)
+	}
+
+	// nothing to replace before members have been created
+	test("TestSearchHeadClusterApplyReplacement(no-members)", false, "", "")
+
+	// nothing to replace until the image changes
+	current, err := enterprise.GetSearchHeadStatefulSet(&cr)
+	if err != nil {
+		t.Fatalf("GetSearchHeadStatefulSet() returned %v; want nil", err)
+	}
+	c.state[getStateKey(current)] = current
+	test("TestSearchHeadClusterApplyReplacement(no-change)", false, "", "")
+
+	// members serving searches keep their image while waiting for the deployer
+	cr.Spec.Image = "splunk/splunk:8.1.0"
+	cr.Status.DeployerPhase = enterprisev1.PhasePending
+	c.resetCalls()
+	test("TestSearchHeadClusterApplyReplacement(deployer)", true, "splunk/splunk:8.1.0", enterprisev1.PhasePending)
+	if cr.Spec.Image != "splunk/splunk:8.0.5" {
+		t.Errorf("ApplyReplacement() spec.image = %s; want %s", cr.Spec.Image, "splunk/splunk:8.0.5")
+	}
+	c.checkCalls(t, "TestSearchHeadClusterApplyReplacement(deployer)", map[string][]mockFuncCall{
+		"Get": {{metaName: "*v1.StatefulSet-test-splunk-stack1-search-head"}},
+	})
+
+	// replacement members are created once the deployer is ready
+	cr.Spec.Image = "splunk/splunk:8.1.0"
+	cr.Status.DeployerPhase = enterprisev1.PhaseReady
+	c.resetCalls()
+	test("TestSearchHeadClusterApplyReplacement(create)", true, "splunk/splunk:8.1.0", enterprisev1.PhasePending)
+	createCalls := []mockFuncCall{
+		{metaName: "*v1.Service-test-splunk-stack1-green-search-head-headless"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-green-search-head"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-green-search-head"},
+	}
+	c.checkCalls(t, "TestSearchHeadClusterApplyReplacement(create)", map[string][]mockFuncCall{
+		"Get":    append([]mockFuncCall{{metaName: "*v1.StatefulSet-test-splunk-stack1-search-head"}}, createCalls...),
+		"Create": createCalls,
+	})
+
+	// searches are switched over once replacement members are ready and healthy, and their KV store is synced
+	cr.Spec.Image = "splunk/splunk:8.1.0"
+	replacement := c.state["*v1.StatefulSet-test-splunk-stack1-green-search-head"].(*appsv1.StatefulSet)
+	replacement.Status.Replicas = 1
+	replacement.Status.ReadyReplicas = 1
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-green-search-head-0", Namespace: "test"},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+		},
+	}
+	c.state[getStateKey(pod)] = pod
+	standbyURI := "https://splunk-stack1-green-search-head-0.splunk-stack1-green-search-head-headless.test.svc.cluster.local:8089"
+	activeURI := "https://splunk-stack1-search-head-0.splunk-stack1-search-head-headless.test.svc.cluster.local:8089"
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    standbyURI + "/services/shcluster/captain/info?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"captain","content":{"initialized_flag":true,"label":"splunk-stack1-green-search-head-0","service_ready_flag":true}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    standbyURI + "/services/kvstore/status?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"content":{"current":{"status":"ready"}}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-deployer-service.test.svc.cluster.local:8089/services/apps/deploy",
+		Status: 200,
+	}, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    activeURI + "/servicesNS/-/-/storage/collections/config?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"lookups","acl":{"app":"search"}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    standbyURI + "/servicesNS/-/-/storage/collections/config?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    standbyURI + "/servicesNS/nobody/search/storage/collections/config",
+		Status: 201,
+	}, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    activeURI + "/servicesNS/nobody/search/storage/collections/data/lookups?limit=1000&output_mode=json&skip=0&sort=_key",
+		Status: 200,
+		Body:   `[{"_key":"1","host":"idx1"},{"_key":"2","host":"idx2"}]`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    standbyURI + "/servicesNS/nobody/search/storage/collections/data/lookups/batch_save",
+		Status: 200,
+	})
+	test("TestSearchHeadClusterApplyReplacement(switch)", true, "splunk/splunk:8.1.0", enterprisev1.PhaseReady)
+	if cr.Status.BlueGreen.ActiveColor != "green" || cr.Status.BlueGreen.LastSwitchTime.IsZero() {
+		t.Errorf("ApplyReplacement() status.blueGreen = %v; want activeColor green and lastSwitchTime", cr.Status.BlueGreen)
+	}
+	if cr.Spec.Image != "splunk/splunk:8.1.0" {
+		t.Errorf("ApplyReplacement() spec.image = %s; want %s", cr.Spec.Image, "splunk/splunk:8.1.0")
+	}
+	service := c.state["*v1.Service-test-splunk-stack1-search-head-service"].(*corev1.Service)
+	if got := service.Spec.Selector["app.kubernetes.io/instance"]; got != "splunk-stack1-green-search-head" {
+		t.Errorf("ApplyReplacement() service selector instance = %s; want %s", got, "splunk-stack1-green-search-head")
+	}
+
+	// replaced members are deleted during the next reconcile
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	c.listObj = &corev1.PersistentVolumeClaimList{
+		Items: []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "pvc-etc-splunk-stack1-search-head-0", Namespace: "test"}}},
+	}
+	c.resetCalls()
+	test("TestSearchHeadClusterApplyReplacement(cleanup)", false, "", "")
+	c.checkCalls(t, "TestSearchHeadClusterApplyReplacement(cleanup)", map[string][]mockFuncCall{
+		"Get": {{metaName: "*v1.StatefulSet-test-splunk-stack1-green-search-head"}},
+		"List": {{listOpts: []client.ListOption{
+			client.InNamespace("test"),
+			client.MatchingLabels(enterprise.GetSearchHeadClusterLabels(&cr, "blue")),
+		}}},
+		"Delete": {
+			{metaName: "*v1.StatefulSet-test-splunk-stack1-search-head"},
+			{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-search-head"},
+			{metaName: "*v1.Service-test-splunk-stack1-search-head-headless"},
+			{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack1-search-head-0"},
+		},
+	})
+}
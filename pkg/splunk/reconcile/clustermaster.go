@@ -0,0 +1,394 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyClusterMaster reconciles the state of a Splunk Enterprise cluster master that is shared by one or more indexer clusters.
+func ApplyClusterMaster(client ControllerClient, cr *enterprisev1.ClusterMaster) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyClusterMaster(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplyClusterMaster").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateClusterMasterSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		err = client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			scopedLog.Error(err, "Status update failed")
+		}
+	}()
+
+	// check if deletion has been requested
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
+			cr.Status.Phase = enterprisev1.PhaseTerminating
+		} else {
+			result.Requeue = false
+		}
+		return result, err
+	}
+
+	// reject names used by indexer clusters, which would share the same StatefulSet, Services and Secrets
+	err = validateClusterMasterName(client, cr)
+	if err != nil {
+		return result, err
+	}
+
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// upgrade to the image requested using the splunk-image annotation, once linked resources have been upgraded in order
+	upgradePending, err := ApplyUpgradeImage(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.GetSplunkStatefulsetName(enterprise.SplunkClusterMaster, cr.GetIdentifier()))
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update general config resources
+	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkClusterMaster, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkClusterMaster))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update smartstore configuration (this is pushed to peers by the cluster master)
+	smartstore, err := ApplySmartStoreConfig(client, cr, &cr.Spec.SmartStore, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured (these are pushed to peers by the cluster master)
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update app repository configuration (apps are pushed to peers by the cluster master)
+	appManager := AppRepoManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, status: &cr.Status.AppRepo, newS3Client: splclient.NewS3Client, newAzureClient: splclient.NewAzureBlobClient}
+	apps, err := appManager.Apply(client, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a regular service for the cluster master
+	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkClusterMaster, false))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update an ingress for Splunk Web, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkClusterMaster, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkClusterMaster, false))
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update an external service and configuration for indexer discovery, if enabled
+	discovery, err := ApplyIndexerDiscoveryConfig(client, cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkClusterMaster, &cr.Status.IndexerDiscovery)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update statefulset
+	statefulSet, err := enterprise.GetClusterMasterStatefulSet(cr)
+	if err != nil {
+		return result, err
+	}
+	if smartstore != nil {
+		// restart cluster master to ensure it always pushes the latest smartstore configuration
+		enterprise.SetSmartStoreChecksum(&statefulSet.Spec.Template, enterprise.GetSmartStoreChecksum(smartstore))
+	}
+	if apps != nil {
+		// restart cluster master to ensure it always pushes the latest app packages
+		enterprise.SetAppsChecksum(&statefulSet.Spec.Template, enterprise.GetAppsChecksum(apps))
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
+	enterprise.SetIndexerDiscoveryChecksum(&statefulSet.Spec.Template, discovery)
+	mgr := DefaultStatefulSetPodManager{}
+	phase, err := mgr.Update(client, statefulSet, 1)
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+
+	// update status and push smartstore configuration, app packages, defaults and conf files to indexer cluster peers once the
+	// cluster master is ready
+	if smartstore == nil {
+		cr.Status.SmartStoreChecksum = ""
+	}
+	if apps == nil {
+		cr.Status.Apps = nil
+	}
+	if defaults == nil {
+		cr.Status.Bundle.DefaultsChecksum = ""
+	}
+	if confFiles == nil {
+		cr.Status.Bundle.ConfFilesChecksum = ""
+	}
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		cmManager := ClusterMasterManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = cmManager.Apply(smartstore, apps, defaults, confFiles)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	hosts := []string{resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, cr.GetIdentifier(), false))}
+	if cr.Status.Phase == enterprisev1.PhaseReady || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, hosts)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// verify that the cluster master is healthy after an upgrade, before reporting it as ready; the health of indexer
+	// cluster peers is verified by each IndexerCluster that references it
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		err = verifyUpgradeHealth(cr, enterprise.GetSplunkImage(cr.Spec.Image), &cr.Status.VerifiedImage, func() error {
+			return checkKVStoreHealth(hosts, secrets, getSplunkClientFactory(client))
+		})
+		if err != nil {
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+	}
+
+	// register cluster master with the monitoring console, once it is ready
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		mcManager := MonitoringConsolePeerManager{log: scopedLog, cr: cr, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mcManager.RegisterPeers(client, hosts)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, the latest cluster bundle has been pushed to indexer cluster peers, and no
+	// upgrades are waiting for linked resources
+	if cr.Status.Phase == enterprisev1.PhaseReady && !cr.Status.Bundle.PushInProgress && !upgradePending {
+		result.Requeue = false
+	}
+	return result, nil
+}
+
+// validateClusterMasterName returns an error if an IndexerCluster with the same name as a ClusterMaster exists in its namespace
+func validateClusterMasterName(client ControllerClient, cr *enterprisev1.ClusterMaster) error {
+	var idxc enterprisev1.IndexerCluster
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetIdentifier()}, &idxc)
+	if err == nil {
+		return fmt.Errorf("ClusterMaster name must not be the same as an IndexerCluster in its namespace; value=\"%s\"", cr.GetIdentifier())
+	}
+	return nil
+}
+
+// getReferencedClusterMaster retrieves the ClusterMaster referenced by an indexer cluster, and the Secret used to access it
+func getReferencedClusterMaster(client ControllerClient, cr *enterprisev1.IndexerCluster) (*enterprisev1.ClusterMaster, *corev1.Secret, error) {
+	ref := cr.Spec.ClusterMasterRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+
+	var clusterMaster enterprisev1.ClusterMaster
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: ref.Name}, &clusterMaster)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to get ClusterMaster %s: %v", ref.Name, err)
+	}
+
+	var secrets corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: enterprise.GetSplunkSecretsName(ref.Name, enterprise.SplunkClusterMaster)}
+	err = client.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to get secrets for ClusterMaster %s: %v", ref.Name, err)
+	}
+
+	return &clusterMaster, &secrets, nil
+}
+
+// getExternalClusterMasterSecret retrieves the Secret used to access the external cluster master of an indexer cluster, which
+// must include the admin password of the cluster master and the idxc_secret used to join it
+func getExternalClusterMasterSecret(client ControllerClient, cr *enterprisev1.IndexerCluster) (*corev1.Secret, error) {
+	name := cr.Spec.ExternalClusterMaster.SecretRef
+	var secret corev1.Secret
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: cr.GetNamespace(), Name: name}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get Secret %s for external cluster master: %v", name, err)
+	}
+	for _, key := range []string{"password", "idxc_secret"} {
+		if len(secret.Data[key]) == 0 {
+			return nil, fmt.Errorf("Secret %s for external cluster master does not include %s", name, key)
+		}
+	}
+	return &secret, nil
+}
+
+// ClusterMasterManager is used to manage a Splunk Enterprise cluster master using its REST API
+type ClusterMasterManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.ClusterMaster
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply for ClusterMasterManager updates the status of a ready cluster master, and applies the cluster bundle to push
+// smartstore configuration, app packages, defaults and conf files to indexer cluster peers if they have changed, or if a
+// push was requested using the bundle-push annotation.
+func (mgr *ClusterMasterManager) Apply(smartstore *corev1.Secret, apps []enterprisev1.AppStatus, defaults *corev1.ConfigMap, confFiles *corev1.Secret) error {
+	c := mgr.getClient()
+	clusterInfo, err := c.GetClusterMasterInfo()
+	if err != nil {
+		return err
+	}
+	mgr.cr.Status.Initialized = clusterInfo.Initialized
+	mgr.cr.Status.IndexingReady = clusterInfo.IndexingReady
+	mgr.cr.Status.ServiceReady = clusterInfo.ServiceReady
+	mgr.cr.Status.MaintenanceMode = clusterInfo.MaintenanceMode
+	setClusterBundleStatus(&mgr.cr.Status.Bundle, clusterInfo)
+
+	pushSmartStore := smartstore != nil && mgr.cr.Status.SmartStoreChecksum != enterprise.GetSmartStoreChecksum(smartstore)
+	pushApps := apps != nil && (mgr.cr.Status.Apps == nil || enterprise.GetAppsChecksum(mgr.cr.Status.Apps) != enterprise.GetAppsChecksum(apps))
+	pushDefaults := defaults != nil && mgr.cr.Status.Bundle.DefaultsChecksum != enterprise.GetDefaultsChecksum(defaults)
+	pushConfFiles := confFiles != nil && mgr.cr.Status.Bundle.ConfFilesChecksum != enterprise.GetConfFilesChecksum(confFiles)
+	pushRequested := enterprise.IsBundlePushRequested(mgr.cr, mgr.cr.Status.Bundle.PushRequest)
+	if !pushSmartStore && !pushApps && !pushDefaults && !pushConfFiles && !pushRequested {
+		return nil
+	}
+
+	mgr.log.Info("Applying cluster bundle to push SmartStore configuration, app packages, defaults and conf files", "smartstore", pushSmartStore, "apps", pushApps, "defaults", pushDefaults, "confFiles", pushConfFiles, "requested", pushRequested)
+	err = c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	if smartstore != nil {
+		mgr.cr.Status.SmartStoreChecksum = enterprise.GetSmartStoreChecksum(smartstore)
+	}
+	if apps != nil {
+		mgr.cr.Status.Apps = apps
+	}
+	if defaults != nil {
+		mgr.cr.Status.Bundle.DefaultsChecksum = enterprise.GetDefaultsChecksum(defaults)
+	}
+	if confFiles != nil {
+		mgr.cr.Status.Bundle.ConfFilesChecksum = enterprise.GetConfFilesChecksum(confFiles)
+	}
+	mgr.cr.Status.Bundle.PushRequest = enterprise.GetBundlePushRequest(mgr.cr)
+	mgr.cr.Status.Bundle.PushInProgress = true
+	return nil
+}
+
+// setClusterBundleStatus updates the status of the configuration bundle pushed to indexer cluster peers by a cluster master
+func setClusterBundleStatus(status *enterprisev1.ClusterBundleStatus, clusterInfo *splclient.ClusterMasterInfo) {
+	status.ActiveChecksum = clusterInfo.ActiveBundle.Checksum
+	status.LatestChecksum = clusterInfo.LatestBundle.Checksum
+	status.PushInProgress = status.ActiveChecksum != status.LatestChecksum
+}
+
+// getClient for ClusterMasterManager returns a SplunkClient for the cluster master
+func (mgr *ClusterMasterManager) getClient() *splclient.SplunkClient {
+	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, mgr.cr.GetIdentifier(), false))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestApplyClusterMaster(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-indexer-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-cluster-master-service"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"},
+	}
+	getCalls := append([]mockFuncCall{{metaName: "*v1beta1.IndexerCluster-test-stack1"}}, funcCalls...)
+	createCalls := map[string][]mockFuncCall{"Get": getCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": getCalls, "Update": []mockFuncCall{funcCalls[2]}}
+	current := enterprisev1.ClusterMaster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterMaster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyClusterMaster(c, cr.(*enterprisev1.ClusterMaster))
+		return err
+	}
+	reconcileTester(t, "TestApplyClusterMaster", &current, revised, createCalls, updateCalls, reconcile)
+
+	// test deletion
+	currentTime := metav1.NewTime(time.Now())
+	revised.ObjectMeta.DeletionTimestamp = &currentTime
+	revised.ObjectMeta.Finalizers = []string{"enterprise.splunk.com/delete-pvc"}
+	deleteFunc := func(cr enterprisev1.MetaObject, c ControllerClient) (bool, error) {
+		_, err := ApplyClusterMaster(c, cr.(*enterprisev1.ClusterMaster))
+		return true, err
+	}
+	splunkDeletionTester(t, revised, deleteFunc)
+}
+
+func TestApplyClusterMasterWithIndexerClusterName(t *testing.T) {
+	cr := enterprisev1.ClusterMaster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterMaster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&idxc)] = &idxc
+
+	// nothing is created for a cluster master with the same name as an indexer cluster
+	_, err := ApplyClusterMaster(c, &cr)
+	if err == nil || !strings.Contains(err.Error(), "IndexerCluster") {
+		t.Errorf("ApplyClusterMaster() returned %v; want error for name used by IndexerCluster", err)
+	}
+	if cr.Status.Phase != enterprisev1.PhaseError {
+		t.Errorf("ApplyClusterMaster() phase = %s; want %s", cr.Status.Phase, enterprisev1.PhaseError)
+	}
+	c.checkCalls(t, "TestApplyClusterMasterWithIndexerClusterName", map[string][]mockFuncCall{"Get": {{metaName: "*v1beta1.IndexerCluster-test-stack1"}}})
+}
+
+func TestClusterMasterManager(t *testing.T) {
+	cr := enterprisev1.ClusterMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	infoHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/info?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"content":{"indexing_ready_flag":true,"initialized_flag":true,"maintenance_mode":false,"service_ready_flag":true}}]}`,
+	}
+	applyHandler := spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/apply",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &ClusterMasterManager{
+		log:     log.WithName("TestClusterMasterManager"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// status is updated without applying the bundle when there is nothing to push
+	mockSplunkClient.AddHandlers(infoHandler)
+	if err := mgr.Apply(nil, nil, nil, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if !cr.Status.Initialized || !cr.Status.IndexingReady || !cr.Status.ServiceReady || cr.Status.MaintenanceMode {
+		t.Errorf("ClusterMasterManager.Apply() status = %v; want initialized, indexing and service ready", cr.Status)
+	}
+
+	// bundle is applied when apps are first installed
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler, applyHandler)
+	apps := []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "abc123"}}
+	if err := mgr.Apply(nil, apps, nil, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if len(cr.Status.Apps) != 1 || cr.Status.Apps[0] != apps[0] {
+		t.Errorf("ClusterMasterManager.Apply() status apps = %v; want %v", cr.Status.Apps, apps)
+	}
+
+	// nothing to push when app versions are unchanged
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler)
+	if err := mgr.Apply(nil, apps, nil, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+
+	// bundle is applied when the defaults ConfigMap changes, and the push is tracked until peers have the latest bundle
+	defaults := &corev1.ConfigMap{
+		Data: map[string]string{"default.yml": "splunk:\n  conf: []\n"},
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler, applyHandler)
+	if err := mgr.Apply(nil, apps, defaults, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if cr.Status.Bundle.DefaultsChecksum != enterprise.GetDefaultsChecksum(defaults) || !cr.Status.Bundle.PushInProgress {
+		t.Errorf("ClusterMasterManager.Apply() status bundle = %v; want defaults checksum and push in progress", cr.Status.Bundle)
+	}
+
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    infoHandler.URL,
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"content":{"active_bundle":{"checksum":"ABC"},"latest_bundle":{"checksum":"ABC"},"initialized_flag":true}}]}`,
+	})
+	if err := mgr.Apply(nil, apps, defaults, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if cr.Status.Bundle.ActiveChecksum != "ABC" || cr.Status.Bundle.LatestChecksum != "ABC" || cr.Status.Bundle.PushInProgress {
+		t.Errorf("ClusterMasterManager.Apply() status bundle = %v; want push complete", cr.Status.Bundle)
+	}
+
+	// bundle is applied once when requested using the bundle-push annotation
+	cr.ObjectMeta.Annotations = map[string]string{enterprise.BundlePushAnnotation: "2020-06-01T10:00:00Z"}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler, applyHandler)
+	if err := mgr.Apply(nil, apps, defaults, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if cr.Status.Bundle.PushRequest != "2020-06-01T10:00:00Z" {
+		t.Errorf("ClusterMasterManager.Apply() status bundle pushRequest = %s; want %s", cr.Status.Bundle.PushRequest, "2020-06-01T10:00:00Z")
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler)
+	if err := mgr.Apply(nil, apps, defaults, nil); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+
+	// bundle is applied when conf files change
+	confFiles := &corev1.Secret{
+		Data: map[string][]byte{"limits.conf": []byte("[search]\nmax_searches_per_cpu = 2\n")},
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler, applyHandler)
+	if err := mgr.Apply(nil, apps, defaults, confFiles); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+	if cr.Status.Bundle.ConfFilesChecksum != enterprise.GetConfFilesChecksum(confFiles) {
+		t.Errorf("ClusterMasterManager.Apply() status bundle confFilesChecksum = %s; want %s", cr.Status.Bundle.ConfFilesChecksum, enterprise.GetConfFilesChecksum(confFiles))
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(infoHandler)
+	if err := mgr.Apply(nil, apps, defaults, confFiles); err != nil {
+		t.Errorf("ClusterMasterManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestClusterMasterManager")
+}
+
+func TestGetExternalClusterMasterSecret(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.ExternalClusterMaster = enterprisev1.ExternalClusterMasterSpec{URL: "https://cm.example.com", SecretRef: "cm-secret"}
+
+	c := newMockClient()
+	if _, err := getExternalClusterMasterSecret(c, &cr); err == nil {
+		t.Errorf("getExternalClusterMasterSecret() returned nil; want error for missing Secret")
+	}
+
+	// both the admin password and idxc_secret are required
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm-secret", Namespace: "test"},
+		Data:       map[string][]byte{"password": []byte("admin-pw")},
+	}
+	c.state[getStateKey(&secret)] = &secret
+	if _, err := getExternalClusterMasterSecret(c, &cr); err == nil {
+		t.Errorf("getExternalClusterMasterSecret() returned nil; want error for missing idxc_secret")
+	}
+
+	secret.Data["idxc_secret"] = []byte("idxc-pw")
+	got, err := getExternalClusterMasterSecret(c, &cr)
+	if err != nil {
+		t.Fatalf("getExternalClusterMasterSecret() returned %v; want nil", err)
+	}
+	if enterprise.GetAppliedAdminPassword(got) != "admin-pw" {
+		t.Errorf("getExternalClusterMasterSecret() password = %s; want admin-pw", enterprise.GetAppliedAdminPassword(got))
+	}
+}
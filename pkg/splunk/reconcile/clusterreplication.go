@@ -0,0 +1,470 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyClusterReplication reconciles the state of a standby indexer cluster that is kept in sync with a primary indexer cluster.
+func ApplyClusterReplication(client ControllerClient, cr *enterprisev1.ClusterReplication) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyClusterReplication(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplyClusterReplication").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateClusterReplicationSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		err = client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			scopedLog.Error(err, "Status update failed")
+		}
+	}()
+
+	mgr := ClusterReplicationManager{
+		log:             scopedLog,
+		cr:              cr,
+		newSplunkClient: getSplunkClientFactory(client),
+		newS3Client:     splclient.NewS3Client,
+		newRemoteClient: newRemoteControllerClient,
+	}
+	phase, err := mgr.Apply(client, time.Now())
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+
+	// once the standby is in sync, periodically sync it again
+	if phase == enterprisev1.PhaseReady {
+		result.RequeueAfter = enterprise.GetClusterReplicationSyncInterval(&cr.Spec)
+	}
+	return result, nil
+}
+
+// newRemoteControllerClient returns a ControllerClient used to reach another Kubernetes cluster using a kubeconfig
+func newRemoteControllerClient(kubeconfig []byte) (ControllerClient, error) {
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{})
+}
+
+// ClusterReplicationManager is used to keep the inactive indexer cluster of a ClusterReplication in sync with the active one,
+// and to fail over between them
+type ClusterReplicationManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.ClusterReplication
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+	newS3Client     func(endpoint, region, accessKey, secretKey string) *splclient.S3Client
+	newRemoteClient func(kubeconfig []byte) (ControllerClient, error)
+}
+
+// replicatedIndexerCluster is one of the indexer clusters of a ClusterReplication
+type replicatedIndexerCluster struct {
+	// either "primary" or "standby"
+	role string
+
+	// client used to reach the Kubernetes cluster that the indexer cluster runs in
+	client ControllerClient
+
+	// true if the indexer cluster runs in the same Kubernetes cluster as the operator, so that its instances can be reached
+	local bool
+
+	idxc *enterprisev1.IndexerCluster
+}
+
+// Apply syncs the replicated settings of the active indexer cluster to the inactive one, reports whether the inactive
+// indexer cluster has the same configuration bundle, app packages and (if enabled) SmartStore bucket manifests, and fails
+// over if requested. It returns the phase of the replication.
+func (mgr *ClusterReplicationManager) Apply(c ControllerClient, now time.Time) (enterprisev1.ResourcePhase, error) {
+	primaryRef, standbyRef := enterprise.GetClusterReplicationRefs(mgr.cr)
+	local := enterprise.IsClusterReplicationStandbyLocal(&mgr.cr.Spec)
+	if local && primaryRef.Name == standbyRef.Name && primaryRef.Namespace == standbyRef.Namespace {
+		return enterprisev1.PhaseError, fmt.Errorf("StandbyRef must refer to a different IndexerCluster than PrimaryRef")
+	}
+
+	standbyClient, err := mgr.getStandbyClient(c)
+	if err != nil {
+		return enterprisev1.PhaseError, err
+	}
+
+	// changes that would be made to the standby are reported with those made locally during dry runs
+	if dryRun, ok := c.(*dryRunClient); ok && !local {
+		remote := newDryRunClient(standbyClient)
+		standbyClient = remote
+		defer func() {
+			for _, change := range remote.getChanges() {
+				dryRun.addChange("Standby cluster: %s", change)
+			}
+		}()
+	}
+
+	primary, err := mgr.getIndexerCluster(c, enterprise.ClusterReplicationPrimary, primaryRef, true)
+	if err != nil {
+		return enterprisev1.PhasePending, nil
+	}
+	standby, err := mgr.getIndexerCluster(standbyClient, enterprise.ClusterReplicationStandby, standbyRef, local)
+	if err != nil {
+		return enterprisev1.PhasePending, nil
+	}
+
+	status := &mgr.cr.Status
+	if status.Active == "" {
+		status.Active = enterprise.ClusterReplicationPrimary
+	}
+	source, target := primary, standby
+	if status.Active == enterprise.ClusterReplicationStandby {
+		source, target = standby, primary
+	}
+
+	if enterprise.SyncReplicatedSpec(&source.idxc.Spec, &target.idxc.Spec) {
+		mgr.log.Info("Syncing inactive IndexerCluster", target.role, target.idxc.GetName())
+		if err = UpdateResource(target.client, target.idxc); err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
+	status.BundleInSync = enterprise.IsClusterBundleInSync(&source.idxc.Status.Bundle, &target.idxc.Status.Bundle)
+	status.AppsInSync = enterprise.AreAppsInSync(source.idxc.Status.Apps, target.idxc.Status.Apps)
+	status.Manifests, status.PendingManifests = 0, 0
+	if mgr.cr.Spec.SmartStoreManifests {
+		if err = mgr.compareManifests(source, target); err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+	status.LastSyncTime = metav1.NewTime(now)
+
+	phase, err := mgr.applyFailover(source, target, now)
+	if phase != "" || err != nil {
+		return phase, err
+	}
+
+	if status.BundleInSync && status.AppsInSync && status.PendingManifests == 0 {
+		return enterprisev1.PhaseReady, nil
+	}
+	mgr.log.Info("Waiting for inactive IndexerCluster to be in sync", "bundleInSync", status.BundleInSync, "appsInSync", status.AppsInSync, "pendingManifests", status.PendingManifests)
+	mgr.cr.Status.Reason, mgr.cr.Status.Message = "WaitingForSync", getReplicationSyncMessage(target, status)
+	return enterprisev1.PhasePending, nil
+}
+
+// getReplicationSyncMessage returns a message listing what the inactive indexer cluster is still missing
+func getReplicationSyncMessage(target *replicatedIndexerCluster, status *enterprisev1.ClusterReplicationStatus) string {
+	var pending []string
+	if !status.BundleInSync {
+		pending = append(pending, "configuration bundle")
+	}
+	if !status.AppsInSync {
+		pending = append(pending, "app packages")
+	}
+	if status.PendingManifests > 0 {
+		pending = append(pending, fmt.Sprintf("%d bucket manifests", status.PendingManifests))
+	}
+	return fmt.Sprintf("waiting for %s IndexerCluster %s to be in sync: %s", target.role, target.idxc.GetName(), strings.Join(pending, ", "))
+}
+
+// getStandbyClient for ClusterReplicationManager returns the ControllerClient used to reach the Kubernetes cluster that the
+// standby runs in, using the kubeconfig in standbyKubeconfigSecretRef (if any)
+func (mgr *ClusterReplicationManager) getStandbyClient(c ControllerClient) (ControllerClient, error) {
+	if enterprise.IsClusterReplicationStandbyLocal(&mgr.cr.Spec) {
+		return c, nil
+	}
+	var secret corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.cr.Spec.StandbyKubeconfigSecretRef}
+	err := c.Get(context.TODO(), namespacedName, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get kubeconfig secret for standby: %v", err)
+	}
+	kubeconfig, ok := secret.Data["kubeconfig"]
+	if !ok {
+		return nil, fmt.Errorf("Kubeconfig secret for standby is missing kubeconfig")
+	}
+	remote, err := mgr.newRemoteClient(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to reach Kubernetes cluster of standby: %v", err)
+	}
+	return remote, nil
+}
+
+// getIndexerCluster for ClusterReplicationManager returns one of the replicated indexer clusters, or error if it does not exist
+func (mgr *ClusterReplicationManager) getIndexerCluster(c ControllerClient, role string, ref corev1.ObjectReference, local bool) (*replicatedIndexerCluster, error) {
+	var idxc enterprisev1.IndexerCluster
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &idxc)
+	if err != nil {
+		mgr.log.Info("Waiting for replicated IndexerCluster to be created", role, ref.Name, "namespace", ref.Namespace)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "IndexerClusterNotFound", fmt.Sprintf("waiting for %s IndexerCluster %s to be created", role, ref.Name)
+		return nil, err
+	}
+	return &replicatedIndexerCluster{role: role, client: c, local: local, idxc: &idxc}, nil
+}
+
+// applyFailover for ClusterReplicationManager makes the inactive indexer cluster take over from the active one once a failover
+// has been requested using the failover-at annotation. The peers of the active indexer cluster are put into manual detention,
+// so that they stop receiving new data, and their hot buckets are rolled so that SmartStore uploads them to remote storage.
+// The failover completes once all bucket manifests have been replicated, and can be cancelled until then by removing the
+// annotation. It returns the phase of the replication while a failover is in progress, or an empty phase otherwise.
+func (mgr *ClusterReplicationManager) applyFailover(source, target *replicatedIndexerCluster, now time.Time) (enterprisev1.ResourcePhase, error) {
+	status := &mgr.cr.Status
+	if !enterprise.IsFailoverRequested(mgr.cr, status.FailoverRequest) {
+		if status.FailoverInProgress {
+			mgr.log.Info("Cancelling failover", target.role, target.idxc.GetName())
+			if err := mgr.setPeerDetention(source, false); err != nil {
+				return enterprisev1.PhaseError, err
+			}
+			status.FailoverInProgress = false
+			recordEvent(mgr.cr, corev1.EventTypeNormal, "FailoverCancelled", "Failover to %s IndexerCluster %s was cancelled", target.role, target.idxc.GetName())
+		}
+		return "", nil
+	}
+
+	if target.idxc.Status.Phase != enterprisev1.PhaseReady {
+		mgr.log.Info("Waiting for inactive IndexerCluster to become ready before failing over", target.role, target.idxc.GetName(), "phase", target.idxc.Status.Phase)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "TargetNotReady", getNotReadyMessage(target.role+" IndexerCluster "+target.idxc.GetName(), target.idxc.Status.Message)
+		return enterprisev1.PhasePending, nil
+	}
+
+	if !status.FailoverInProgress {
+		mgr.log.Info("Starting failover", source.role, source.idxc.GetName(), target.role, target.idxc.GetName())
+		if err := mgr.setPeerDetention(source, true); err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		if err := mgr.rollHotBuckets(source); err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		status.FailoverInProgress = true
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "FailoverStarted", "Failing over from %s IndexerCluster %s to %s IndexerCluster %s", source.role, source.idxc.GetName(), target.role, target.idxc.GetName())
+
+		// give SmartStore time to upload the buckets that were rolled before comparing manifests again
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "FailoverInProgress", fmt.Sprintf("failing over to %s IndexerCluster %s", target.role, target.idxc.GetName())
+		return enterprisev1.PhaseUpdating, nil
+	}
+
+	if status.PendingManifests > 0 {
+		mgr.log.Info("Waiting for bucket manifests to be replicated before failing over", "pendingManifests", status.PendingManifests)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "FailoverInProgress", fmt.Sprintf("waiting for %d bucket manifests to be replicated before failing over", status.PendingManifests)
+		return enterprisev1.PhaseUpdating, nil
+	}
+
+	// peers of the new active indexer cluster may still be detained by a previous failover
+	if err := mgr.setPeerDetention(target, false); err != nil {
+		return enterprisev1.PhaseError, err
+	}
+	status.Active = target.role
+	status.FailoverRequest = enterprise.GetFailoverRequest(mgr.cr)
+	status.FailoverInProgress = false
+	status.LastFailoverTime = metav1.NewTime(now)
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "FailoverCompleted", "%s IndexerCluster %s is now active", strings.Title(target.role), target.idxc.GetName())
+	return "", nil
+}
+
+// getAdminPassword for ClusterReplicationManager returns the admin password used by the instances of an indexer cluster
+// of the given instance type, using the secrets of the custom resource with the given name
+func (mgr *ClusterReplicationManager) getAdminPassword(cluster *replicatedIndexerCluster, namespace, name string, instanceType enterprise.InstanceType) (string, error) {
+	secretsName := enterprise.GetSplunkSecretsName(name, instanceType)
+	var secrets corev1.Secret
+	err := cluster.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: secretsName}, &secrets)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+	return enterprise.GetAppliedAdminPassword(&secrets), nil
+}
+
+// setPeerDetention for ClusterReplicationManager enables or disables manual detention of all peers of an indexer cluster.
+// Nothing is done for indexer clusters in another Kubernetes cluster, since their peers cannot be reached.
+func (mgr *ClusterReplicationManager) setPeerDetention(cluster *replicatedIndexerCluster, detain bool) error {
+	if !cluster.local {
+		mgr.log.Info("Skipping peer detention for IndexerCluster in another Kubernetes cluster", cluster.role, cluster.idxc.GetName())
+		return nil
+	}
+	password, err := mgr.getAdminPassword(cluster, cluster.idxc.GetNamespace(), cluster.idxc.GetName(), enterprise.SplunkIndexer)
+	if err != nil {
+		return err
+	}
+	for _, host := range getIndexerClusterPeerHosts(cluster.idxc) {
+		splunkClient := mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", password)
+		if err = splunkClient.SetIndexerClusterPeerDetention(detain); err != nil {
+			return fmt.Errorf("Unable to set manual detention for %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// rollHotBuckets for ClusterReplicationManager rolls the hot buckets of all SmartStore indexes of an indexer cluster, using
+// its cluster master. Nothing is done for indexer clusters in another Kubernetes cluster, or that join an external cluster master.
+func (mgr *ClusterReplicationManager) rollHotBuckets(cluster *replicatedIndexerCluster) error {
+	if !cluster.local || enterprise.IsExternalClusterMasterConfigured(&cluster.idxc.Spec.ExternalClusterMaster) {
+		mgr.log.Info("Skipping hot bucket roll for IndexerCluster with an unreachable cluster master", cluster.role, cluster.idxc.GetName())
+		return nil
+	}
+	if len(cluster.idxc.Spec.SmartStore.IndexList) == 0 {
+		return nil
+	}
+
+	ref, instanceType := enterprise.GetIndexerClusterMasterRef(cluster.idxc)
+	password, err := mgr.getAdminPassword(cluster, ref.Namespace, ref.Name, instanceType)
+	if err != nil {
+		return err
+	}
+	fqdnName := resources.GetServiceFQDN(ref.Namespace, enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, ref.Name, false))
+	splunkClient := mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", password)
+	for _, idx := range cluster.idxc.Spec.SmartStore.IndexList {
+		if err = splunkClient.RollClusterHotBuckets(idx.Name); err != nil {
+			return fmt.Errorf("Unable to roll hot buckets of index %s: %v", idx.Name, err)
+		}
+	}
+	return nil
+}
+
+// compareManifests for ClusterReplicationManager counts the SmartStore bucket manifests in the remote storage volumes of the
+// active indexer cluster, and those that are missing from the volumes used by the same indexes of the inactive one
+func (mgr *ClusterReplicationManager) compareManifests(source, target *replicatedIndexerCluster) error {
+	sourceLocations, err := enterprise.GetSmartStoreManifestLocations(&source.idxc.Spec.SmartStore)
+	if err != nil {
+		return err
+	}
+	targetLocations, err := enterprise.GetSmartStoreManifestLocations(&target.idxc.Spec.SmartStore)
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[string]enterprise.SmartStoreManifestLocation)
+	for _, location := range targetLocations {
+		targets[location.Index] = location
+	}
+
+	status := &mgr.cr.Status
+	for _, location := range sourceLocations {
+		sourceManifests, err := mgr.listManifests(source, location)
+		if err != nil {
+			return err
+		}
+		targetLocation, ok := targets[location.Index]
+		if !ok {
+			return fmt.Errorf("SmartStore index %s is not defined for %s IndexerCluster %s", location.Index, target.role, target.idxc.GetName())
+		}
+		targetManifests, err := mgr.listManifests(target, targetLocation)
+		if err != nil {
+			return err
+		}
+		for key := range sourceManifests {
+			status.Manifests++
+			if !targetManifests[key] {
+				status.PendingManifests++
+			}
+		}
+	}
+	return nil
+}
+
+// listManifests for ClusterReplicationManager returns the SmartStore bucket manifests of an index in remote storage, relative
+// to the location of the index
+func (mgr *ClusterReplicationManager) listManifests(cluster *replicatedIndexerCluster, location enterprise.SmartStoreManifestLocation) (map[string]bool, error) {
+	s3Client, err := mgr.getS3Client(cluster, location.Volume)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s3Client.ListObjects(location.Bucket, location.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list bucket manifests of index %s for %s IndexerCluster %s: %v", location.Index, cluster.role, cluster.idxc.GetName(), err)
+	}
+	manifests := make(map[string]bool)
+	for _, obj := range objects {
+		if enterprise.IsSmartStoreManifest(obj.Key) {
+			manifests[strings.TrimPrefix(obj.Key, location.Prefix)] = true
+		}
+	}
+	return manifests, nil
+}
+
+// getS3Client for ClusterReplicationManager returns an S3Client for a remote storage volume of an indexer cluster, using
+// the credentials from its secretRef
+func (mgr *ClusterReplicationManager) getS3Client(cluster *replicatedIndexerCluster, volume *enterprisev1.SmartStoreVolumeSpec) (*splclient.S3Client, error) {
+	if volume.Provider != "" && volume.Provider != enterprise.SmartStoreProviderS3 {
+		return nil, fmt.Errorf("SmartStore manifests can only be compared for volumes using the \"%s\" provider; volume=\"%s\"", enterprise.SmartStoreProviderS3, volume.Name)
+	}
+	if volume.Endpoint == "" || volume.SecretRef == "" {
+		return nil, fmt.Errorf("SmartStore manifests can only be compared for volumes with an endpoint and secretRef; volume=\"%s\"", volume.Name)
+	}
+
+	var secret corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: cluster.idxc.GetNamespace(), Name: volume.SecretRef}
+	err := cluster.client.Get(context.TODO(), namespacedName, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get secret for SmartStore volume %s of %s IndexerCluster %s: %v", volume.Name, cluster.role, cluster.idxc.GetName(), err)
+	}
+
+	s3Client := mgr.newS3Client(volume.Endpoint, enterprise.GetS3EndpointRegion(volume.Endpoint), string(secret.Data["s3_access_key"]), string(secret.Data["s3_secret_key"]))
+	s3Client.VirtualHostedStyle = volume.URLStyle == enterprise.S3URLStyleVirtual
+	if volume.CASecretRef != "" {
+		var caSecret corev1.Secret
+		namespacedName = types.NamespacedName{Namespace: cluster.idxc.GetNamespace(), Name: volume.CASecretRef}
+		err = cluster.client.Get(context.TODO(), namespacedName, &caSecret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get CA secret for SmartStore volume %s of %s IndexerCluster %s: %v", volume.Name, cluster.role, cluster.idxc.GetName(), err)
+		}
+		if err = s3Client.SetCABundle(caSecret.Data["ca.crt"]); err != nil {
+			return nil, fmt.Errorf("Invalid CA secret for SmartStore volume %s: %v", volume.Name, err)
+		}
+	}
+	return s3Client, nil
+}
@@ -18,11 +18,14 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 )
@@ -31,10 +34,15 @@ import (
 func ApplySplunkConfig(client ControllerClient, cr enterprisev1.MetaObject, spec enterprisev1.CommonSplunkSpec, instanceType enterprise.InstanceType) (*corev1.Secret, error) {
 	var err error
 
+	// create service account used to run pods on OpenShift, if enabled
+	if err = ApplyOpenShiftServiceAccount(client, cr.GetNamespace()); err != nil {
+		return nil, err
+	}
+
 	// if reference to indexer cluster, extract and re-use idxc.secret
 	// IndexerRef is not relevant for Indexer, and Indexer will use value from LicenseMaster to prevent cyclical dependency
 	var idxcSecret []byte
-	if instanceType.ToKind() != "indexer" && instanceType.ToKind() != "license-master" && spec.IndexerClusterRef.Name != "" {
+	if instanceType.ToKind() != "indexer" && instanceType.ToKind() != "license-master" && spec.IndexerClusterRef.Name != "" && spec.ClusterMasterRef.Name == "" {
 		idxcSecret, err = GetSplunkSecret(client, cr, spec.IndexerClusterRef, enterprise.SplunkIndexer, "idxc_secret")
 		if err != nil {
 			return nil, err
@@ -57,13 +65,70 @@ func ApplySplunkConfig(client ControllerClient, cr enterprisev1.MetaObject, spec
 		}
 	}
 
-	// create or retrieve splunk secrets
+	// if using an external license master, use the pass4SymmKey provided for it
+	if instanceType != enterprise.SplunkLicenseMaster && enterprise.IsExternalLicenseMasterConfigured(&spec) {
+		var externalSecret *corev1.Secret
+		if externalSecret, err = getExternalLicenseMasterSecret(client, cr, &spec); err != nil {
+			return nil, err
+		}
+		pass4SymmKey = externalSecret.Data["pass4SymmKey"]
+	}
+
+	// if reference to cluster master, extract and re-use idxc.secret (this takes precedence over other references)
+	if instanceType != enterprise.SplunkClusterMaster && spec.ClusterMasterRef.Name != "" {
+		idxcSecret, err = GetSplunkSecret(client, cr, spec.ClusterMasterRef, enterprise.SplunkClusterMaster, "idxc_secret")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// if joining an external cluster master, use the idxc.secret provided for it
+	if idxc, ok := cr.(*enterprisev1.IndexerCluster); ok && enterprise.IsExternalClusterMasterConfigured(&idxc.Spec.ExternalClusterMaster) {
+		var externalSecret *corev1.Secret
+		if externalSecret, err = getExternalClusterMasterSecret(client, idxc); err != nil {
+			return nil, err
+		}
+		idxcSecret = externalSecret.Data["idxc_secret"]
+	}
+
+	// retrieve secret values provided using secretRef, or from a secrets provider
+	refSecrets, err := GetReferencedSecrets(client, cr, &spec)
+	if err != nil {
+		return nil, err
+	}
+	if refSecrets == nil {
+		providerManager := SecretsProviderManager{
+			log:                log.WithName("ApplySplunkConfig").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace()),
+			cr:                 cr,
+			spec:               &spec.SecretsProvider,
+			instanceType:       instanceType,
+			newSecretsProvider: newSecretsProvider,
+		}
+		if refSecrets, err = providerManager.GetSecrets(client, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// create or retrieve splunk secrets (new secrets use any provided values from the start)
 	secrets := enterprise.GetSplunkSecrets(cr, instanceType, idxcSecret, pass4SymmKey)
 	secrets.SetOwnerReferences(append(secrets.GetOwnerReferences(), resources.AsOwner(cr)))
+	if syncReferencedSecrets(secrets, refSecrets) {
+		syncSharedSecrets(secrets, idxcSecret, pass4SymmKey)
+		secrets.Data["default.yml"] = enterprise.GetSplunkSecretsDefaults(secrets.Data)
+	}
 	if secrets, err = ApplySecret(client, secrets); err != nil {
 		return nil, err
 	}
 
+	// keep secrets provided using secretRef, and those shared with referenced resources, in sync (these are applied once
+	// all instances are ready); shared secrets take precedence, since they must match across all referenced resources
+	changed := syncReferencedSecrets(secrets, refSecrets)
+	if syncSharedSecrets(secrets, idxcSecret, pass4SymmKey) || changed {
+		if err = UpdateResource(client, secrets); err != nil {
+			return nil, err
+		}
+	}
+
 	// create splunk defaults (for inline config)
 	if spec.Defaults != "" {
 		defaultsMap := enterprise.GetSplunkDefaults(cr.GetIdentifier(), cr.GetNamespace(), instanceType, spec.Defaults)
@@ -76,6 +141,227 @@ func ApplySplunkConfig(client ControllerClient, cr enterprisev1.MetaObject, spec
 	return secrets, nil
 }
 
+// GetDefaultsConfigMap retrieves a ConfigMap containing default.yml overrides that is referenced by a Splunk Enterprise resource.
+// It returns the ConfigMap if one is referenced, or nil if it is not. An error is returned if the ConfigMap does not exist or
+// does not contain a default.yml file.
+func GetDefaultsConfigMap(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec) (*corev1.ConfigMap, error) {
+	if !enterprise.IsDefaultsConfigMapConfigured(spec) {
+		return nil, nil
+	}
+
+	var configMap corev1.ConfigMap
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: spec.DefaultsConfigMapRef}
+	err := client.Get(context.TODO(), namespacedName, &configMap)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get defaults ConfigMap %s: %v", spec.DefaultsConfigMapRef, err)
+	}
+
+	if err = enterprise.ValidateDefaultsConfigMap(&configMap); err != nil {
+		return nil, err
+	}
+	return &configMap, nil
+}
+
+// GetReferencedSecrets retrieves a Secret providing secret values that is referenced by a Splunk Enterprise resource using
+// secretRef. It returns the Secret if one is referenced, or nil if it is not. An error is returned if the Secret does not exist.
+func GetReferencedSecrets(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec) (*corev1.Secret, error) {
+	if spec.SecretRef == "" {
+		return nil, nil
+	}
+
+	var secret corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: spec.SecretRef}
+	err := client.Get(context.TODO(), namespacedName, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get secret %s: %v", spec.SecretRef, err)
+	}
+	return &secret, nil
+}
+
+// ApplySmartStoreConfig creates or updates a Kubernetes Secret containing generated SmartStore configuration for Splunk Enterprise
+// instances. It returns the Secret if SmartStore is configured, or nil if it is not.
+func ApplySmartStoreConfig(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.SmartStoreSpec, instanceType enterprise.InstanceType) (*corev1.Secret, error) {
+	if !enterprise.IsSmartStoreConfigured(spec) {
+		return nil, nil
+	}
+
+	// retrieve remote storage access keys for each volume
+	volumeSecrets := make(map[string]*corev1.Secret)
+	for _, v := range spec.VolList {
+		if v.SecretRef == "" {
+			continue
+		}
+		var secret corev1.Secret
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: v.SecretRef}
+		err := client.Get(context.TODO(), namespacedName, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get secret for SmartStore volume %s: %v", v.Name, err)
+		}
+		if v.Provider == enterprise.SmartStoreProviderGCS && len(secret.Data[enterprise.SmartStoreGCSCredentialsKey]) == 0 {
+			return nil, fmt.Errorf("Secret for SmartStore volume %s is missing %s", v.Name, enterprise.SmartStoreGCSCredentialsKey)
+		}
+		volumeSecrets[v.Name] = &secret
+	}
+
+	// CA certificates are mounted from their secrets by pods, so only check that they are available
+	for _, v := range spec.VolList {
+		if v.CASecretRef == "" {
+			continue
+		}
+		var secret corev1.Secret
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: v.CASecretRef}
+		err := client.Get(context.TODO(), namespacedName, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get CA secret for SmartStore volume %s: %v", v.Name, err)
+		}
+		if len(secret.Data["ca.crt"]) == 0 {
+			return nil, fmt.Errorf("CA secret for SmartStore volume %s is missing ca.crt", v.Name)
+		}
+	}
+
+	smartstore := enterprise.GetSmartStoreSecret(cr, spec, instanceType, volumeSecrets)
+	smartstore.SetOwnerReferences(append(smartstore.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	scopedLog := log.WithName("ApplySmartStoreConfig").WithValues(
+		"name", smartstore.GetObjectMeta().GetName(),
+		"namespace", smartstore.GetObjectMeta().GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: smartstore.GetNamespace(), Name: smartstore.GetName()}
+	var current corev1.Secret
+
+	err := client.Get(context.TODO(), namespacedName, &current)
+	if err == nil {
+		if !reflect.DeepEqual(smartstore.Data, current.Data) {
+			scopedLog.Info("Updating existing SmartStore Secret")
+			current.Data = smartstore.Data
+			err = UpdateResource(client, &current)
+		} else {
+			scopedLog.Info("No changes for SmartStore Secret")
+		}
+	} else {
+		err = CreateResource(client, smartstore)
+	}
+
+	return smartstore, err
+}
+
+// ApplyConfFilesConfig creates or updates a Kubernetes Secret containing the conf files configured for a Splunk Enterprise
+// resource. It returns the Secret if conf files are configured and installed by the instance type, or nil if they are not.
+func ApplyConfFilesConfig(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType enterprise.InstanceType) (*corev1.Secret, error) {
+	if !enterprise.IsConfFilesConfigured(spec.ConfFiles) || !enterprise.IsConfFilesInstaller(instanceType) {
+		return nil, nil
+	}
+
+	revised := enterprise.GetConfFilesSecret(cr, spec.ConfFiles, instanceType, spec.ConfigUpdateStrategy)
+	revised.SetOwnerReferences(append(revised.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	scopedLog := log.WithName("ApplyConfFilesConfig").WithValues(
+		"name", revised.GetObjectMeta().GetName(),
+		"namespace", revised.GetObjectMeta().GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current corev1.Secret
+
+	err := client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return revised, CreateResource(client, revised)
+	}
+
+	if !reflect.DeepEqual(revised.Data, current.Data) {
+		scopedLog.Info("Updating existing conf files Secret")
+		current.Data = revised.Data
+		err = UpdateResource(client, &current)
+	} else {
+		scopedLog.Info("No changes for conf files Secret")
+	}
+
+	return &current, err
+}
+
+// ApplyTLSConfig requests a certificate from cert-manager for the given DNS names, and creates or updates a Kubernetes Secret
+// containing the issued certificate and TLS configuration for Splunk Enterprise instances. It returns the Secret if TLS
+// is configured, or nil if it is not. An error is returned until cert-manager has issued the certificate.
+func ApplyTLSConfig(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.TLSSpec, instanceType enterprise.InstanceType, dnsNames []string) (*corev1.Secret, error) {
+	if !enterprise.IsTLSConfigured(spec) {
+		return nil, nil
+	}
+
+	// create or update certificate request
+	certificate := enterprise.GetSplunkCertificate(cr, spec, instanceType, dnsNames)
+	certificate.SetOwnerReferences(append(certificate.GetOwnerReferences(), resources.AsOwner(cr)))
+	if err := ApplyCertificate(client, certificate); err != nil {
+		return nil, err
+	}
+
+	// retrieve certificate issued by cert-manager
+	var certSecret corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: enterprise.GetSplunkCertificateName(cr.GetIdentifier(), instanceType)}
+	err := client.Get(context.TODO(), namespacedName, &certSecret)
+	if err != nil {
+		return nil, fmt.Errorf("Certificate has not been issued; secret=\"%s\": %v", namespacedName.Name, err)
+	}
+
+	tls, err := enterprise.GetTLSSecret(cr, instanceType, &certSecret)
+	if err != nil {
+		return nil, err
+	}
+	tls.SetOwnerReferences(append(tls.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	scopedLog := log.WithName("ApplyTLSConfig").WithValues(
+		"name", tls.GetObjectMeta().GetName(),
+		"namespace", tls.GetObjectMeta().GetNamespace())
+
+	namespacedName = types.NamespacedName{Namespace: tls.GetNamespace(), Name: tls.GetName()}
+	var current corev1.Secret
+
+	err = client.Get(context.TODO(), namespacedName, &current)
+	if err == nil {
+		if !reflect.DeepEqual(tls.Data, current.Data) {
+			scopedLog.Info("Updating existing TLS Secret")
+			current.Data = tls.Data
+			err = UpdateResource(client, &current)
+		} else {
+			scopedLog.Info("No changes for TLS Secret")
+		}
+	} else {
+		err = CreateResource(client, tls)
+	}
+
+	return tls, err
+}
+
+// ApplyCertificate creates or updates a cert-manager Certificate
+func ApplyCertificate(client ControllerClient, certificate *unstructured.Unstructured) error {
+	scopedLog := log.WithName("ApplyCertificate").WithValues(
+		"name", certificate.GetName(),
+		"namespace", certificate.GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: certificate.GetNamespace(), Name: certificate.GetName()}
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(certificate.GroupVersionKind())
+
+	err := client.Get(context.TODO(), namespacedName, current)
+	if err == nil {
+		if !reflect.DeepEqual(certificate.Object["spec"], current.Object["spec"]) {
+			scopedLog.Info("Updating existing Certificate")
+			current.Object["spec"] = certificate.Object["spec"]
+			err = client.Update(context.TODO(), current)
+		} else {
+			scopedLog.Info("No changes for Certificate")
+		}
+	} else {
+		err = client.Create(context.TODO(), certificate)
+		if err == nil {
+			scopedLog.Info("Created Certificate")
+		}
+	}
+
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("Unable to apply certificate %s: %v", certificate.GetName(), err)
+	}
+	return nil
+}
+
 // ApplyConfigMap creates or updates a Kubernetes ConfigMap
 func ApplyConfigMap(client ControllerClient, configMap *corev1.ConfigMap) error {
 	scopedLog := log.WithName("ApplyConfigMap").WithValues(
@@ -84,17 +370,25 @@ func ApplyConfigMap(client ControllerClient, configMap *corev1.ConfigMap) error
 
 	namespacedName := types.NamespacedName{Namespace: configMap.GetNamespace(), Name: configMap.GetName()}
 	var current corev1.ConfigMap
+	checksum := getDesiredChecksum(configMap.Data)
 
 	err := client.Get(context.TODO(), namespacedName, &current)
 	if err == nil {
 		if !reflect.DeepEqual(configMap.Data, current.Data) {
-			scopedLog.Info("Updating existing ConfigMap")
+			original := current.DeepCopy()
 			current.Data = configMap.Data
+			// leave changes made outside of the operator unchanged if its owner only reports drift
+			if !checkDrift(client, original, &current, checksum) {
+				return nil
+			}
+			scopedLog.Info("Updating existing ConfigMap")
+			setDesiredChecksum(&current, checksum)
 			err = UpdateResource(client, &current)
 		} else {
 			scopedLog.Info("No changes for ConfigMap")
 		}
 	} else {
+		setDesiredChecksum(configMap, checksum)
 		err = CreateResource(client, configMap)
 	}
 
@@ -123,7 +417,7 @@ func ApplySecret(client ControllerClient, secret *corev1.Secret) (*corev1.Secret
 	return result, err
 }
 
-// GetSplunkSecret is used to retrieve a secret from another custom resource.
+// GetSplunkSecret is used to retrieve a secret from another custom resource, which may be in another namespace if ref includes one.
 func GetSplunkSecret(client ControllerClient, cr enterprisev1.MetaObject, ref corev1.ObjectReference, instanceType enterprise.InstanceType, secretName string) ([]byte, error) {
 	namespace := ref.Namespace
 	if namespace == "" {
@@ -140,12 +434,12 @@ func GetSplunkSecret(client ControllerClient, cr enterprisev1.MetaObject, ref co
 	var secret corev1.Secret
 	err := client.Get(context.TODO(), namespacedName, &secret)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to get secret: %v", err)
+		return nil, fmt.Errorf("Unable to get secret %s in namespace %s: %v", namespacedName.Name, namespacedName.Namespace, err)
 	}
 
 	result := secret.Data[secretName]
 	if len(result) == 0 {
-		return nil, fmt.Errorf("Secret is empty")
+		return nil, fmt.Errorf("Secret %s in namespace %s does not include %s", namespacedName.Name, namespacedName.Namespace, secretName)
 	}
 
 	scopedLog.Info("Re-using secret")
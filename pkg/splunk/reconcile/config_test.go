@@ -17,7 +17,7 @@ package reconcile
 import (
 	"testing"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -65,6 +65,7 @@ func TestApplySplunkConfig(t *testing.T) {
 		{metaName: "*v1.Secret-test-splunk-stack1-search-head-secrets"},
 		{metaName: "*v1.ConfigMap-test-splunk-stack1-search-head-defaults"},
 	}
+	updateCalls["Update"] = []mockFuncCall{{metaName: "*v1.Secret-test-splunk-stack1-search-head-secrets"}}
 	reconcileTester(t, "TestApplySplunkConfig", &searchHeadCR, searchHeadRevised, createCalls, updateCalls, reconcile, &secret)
 
 	// test indexer with license master
@@ -101,8 +102,409 @@ func TestApplySplunkConfig(t *testing.T) {
 		{metaName: "*v1.Secret-test-splunk-stack1-indexer-secrets"},
 	}
 	createCalls = map[string][]mockFuncCall{"Get": {funcCalls[2]}, "Create": {funcCalls[2]}}
-	updateCalls = map[string][]mockFuncCall{"Get": funcCalls}
+	updateCalls = map[string][]mockFuncCall{"Get": funcCalls, "Update": {funcCalls[2]}}
 	reconcileTester(t, "TestApplySplunkConfig", &indexerCR, indexerRevised, createCalls, updateCalls, reconcile, &secret)
+
+	// test standalone with secrets provided using secretRef
+	standaloneCR := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	standaloneCR.Spec.SecretRef = "splunk-provided-secrets"
+	refSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-provided-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{"password": []byte("pass1")},
+	}
+	funcCalls = []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-provided-secrets"},
+		{metaName: "*v1.Secret-test-splunk-stack1-standalone-secrets"},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&refSecret)] = &refSecret
+	secrets, err := ApplySplunkConfig(c, &standaloneCR, standaloneCR.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	if err != nil {
+		t.Fatalf("ApplySplunkConfig() returned %v; want nil", err)
+	}
+	if string(secrets.Data["password"]) != "pass1" || enterprise.IsSecretsRotationPending(secrets) {
+		t.Errorf("ApplySplunkConfig() did not create secrets using provided password")
+	}
+	c.checkCalls(t, "TestApplySplunkConfig(secretRef-create)", map[string][]mockFuncCall{"Get": funcCalls, "Create": {funcCalls[1]}})
+
+	// changes to provided values are rolled out like manual rotations
+	refSecret.Data["password"] = []byte("pass2")
+	c.resetCalls()
+	secrets, err = ApplySplunkConfig(c, &standaloneCR, standaloneCR.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	if err != nil {
+		t.Fatalf("ApplySplunkConfig() returned %v; want nil", err)
+	}
+	if string(secrets.Data["password"]) != "pass2" || !enterprise.IsSecretsRotationPending(secrets) {
+		t.Errorf("ApplySplunkConfig() did not update secrets using provided password")
+	}
+	c.checkCalls(t, "TestApplySplunkConfig(secretRef-update)", map[string][]mockFuncCall{"Get": funcCalls, "Update": {funcCalls[1]}})
+
+	// missing referenced Secret is an error
+	c = newMockClient()
+	if _, err = ApplySplunkConfig(c, &standaloneCR, standaloneCR.Spec.CommonSplunkSpec, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySplunkConfig() returned nil; want error for missing secretRef")
+	}
+}
+
+func TestApplySplunkConfigWithExternalClusterMaster(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.ExternalClusterMaster = enterprisev1.ExternalClusterMasterSpec{URL: "https://cm.example.com", SecretRef: "cm-secret"}
+
+	// an error is returned until the Secret for the external cluster master exists
+	c := newMockClient()
+	if _, err := ApplySplunkConfig(c, &cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkIndexer); err == nil {
+		t.Errorf("ApplySplunkConfig() returned nil; want error for missing Secret")
+	}
+
+	// peers use the idxc_secret provided for the external cluster master
+	external := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm-secret", Namespace: "test"},
+		Data:       map[string][]byte{"password": []byte("admin-pw"), "idxc_secret": []byte("idxc-pw")},
+	}
+	c.state[getStateKey(&external)] = &external
+	secrets, err := ApplySplunkConfig(c, &cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkIndexer)
+	if err != nil {
+		t.Fatalf("ApplySplunkConfig() returned %v; want nil", err)
+	}
+	if got := string(secrets.Data["idxc_secret"]); got != "idxc-pw" {
+		t.Errorf("ApplySplunkConfig() idxc_secret = %s; want idxc-pw", got)
+	}
+	if got := string(secrets.Data["password"]); got == "admin-pw" {
+		t.Errorf("ApplySplunkConfig() password = %s; want a generated password", got)
+	}
+}
+
+func TestApplySplunkConfigWithExternalLicenseMaster(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.LicenseMasterURL = "https://lm.example.com:8089"
+	cr.Spec.LicenseMasterSecretRef = "lm-secret"
+
+	// an error is returned until the Secret for the external license master exists
+	c := newMockClient()
+	if _, err := ApplySplunkConfig(c, &cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySplunkConfig() returned nil; want error for missing Secret")
+	}
+	external := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "lm-secret", Namespace: "test"},
+		Data:       map[string][]byte{"password": []byte("admin-pw")},
+	}
+	c.state[getStateKey(&external)] = &external
+	if _, err := ApplySplunkConfig(c, &cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySplunkConfig() returned nil; want error for missing pass4SymmKey")
+	}
+
+	// license slaves use the pass4SymmKey provided for the external license master
+	external.Data["pass4SymmKey"] = []byte("lm-pw")
+	secrets, err := ApplySplunkConfig(c, &cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	if err != nil {
+		t.Fatalf("ApplySplunkConfig() returned %v; want nil", err)
+	}
+	if got := string(secrets.Data["pass4SymmKey"]); got != "lm-pw" {
+		t.Errorf("ApplySplunkConfig() pass4SymmKey = %s; want lm-pw", got)
+	}
+}
+
+func TestGetSplunkSecret(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: enterprise.GetSplunkSecretsName("lm", enterprise.SplunkLicenseMaster), Namespace: "central"},
+		Data:       map[string][]byte{"pass4SymmKey": []byte("lm-pw")},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&secret)] = &secret
+
+	// secrets are retrieved from the namespace of the reference, or of the custom resource if it has none
+	ref := corev1.ObjectReference{Name: "lm"}
+	if _, err := GetSplunkSecret(c, &cr, ref, enterprise.SplunkLicenseMaster, "pass4SymmKey"); err == nil {
+		t.Errorf("GetSplunkSecret() returned nil; want error for secret in another namespace")
+	}
+	ref.Namespace = "central"
+	got, err := GetSplunkSecret(c, &cr, ref, enterprise.SplunkLicenseMaster, "pass4SymmKey")
+	if err != nil {
+		t.Errorf("GetSplunkSecret() returned %v; want nil", err)
+	}
+	if string(got) != "lm-pw" {
+		t.Errorf("GetSplunkSecret() = %s; want lm-pw", got)
+	}
+	c.checkCalls(t, "TestGetSplunkSecret", map[string][]mockFuncCall{"Get": {
+		{metaName: "*v1.Secret-test-" + secret.Name},
+		{metaName: "*v1.Secret-central-" + secret.Name},
+	}})
+
+	if _, err := GetSplunkSecret(c, &cr, ref, enterprise.SplunkLicenseMaster, "idxc_secret"); err == nil {
+		t.Errorf("GetSplunkSecret() returned nil; want error for missing idxc_secret")
+	}
+}
+
+func TestGetDefaultsConfigMap(t *testing.T) {
+	current := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	current.Spec.DefaultsConfigMapRef = "splunk-defaults"
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-defaults",
+			Namespace: "test",
+		},
+		Data: map[string]string{"default.yml": "splunk:\n    hec_disabled: 1\n"},
+	}
+	getCalls := map[string][]mockFuncCall{"Get": {{metaName: "*v1.ConfigMap-test-splunk-defaults"}}}
+
+	// test missing ConfigMap
+	c := newMockClient()
+	if _, err := GetDefaultsConfigMap(c, &current, &current.Spec.CommonSplunkSpec); err == nil {
+		t.Errorf("GetDefaultsConfigMap() returned nil; want error for missing ConfigMap")
+	}
+	c.checkCalls(t, "TestGetDefaultsConfigMap(missing)", getCalls)
+
+	// test existing ConfigMap
+	c = newMockClient()
+	c.state[getStateKey(&configMap)] = &configMap
+	got, err := GetDefaultsConfigMap(c, &current, &current.Spec.CommonSplunkSpec)
+	if err != nil || got == nil || got.Data["default.yml"] != configMap.Data["default.yml"] {
+		t.Errorf("GetDefaultsConfigMap() = %v, %v; want %v, nil", got, err, configMap)
+	}
+	c.checkCalls(t, "TestGetDefaultsConfigMap(found)", getCalls)
+
+	// test ConfigMap without default.yml
+	configMap.Data = map[string]string{"defaults.yaml": "splunk: {}"}
+	if _, err = GetDefaultsConfigMap(c, &current, &current.Spec.CommonSplunkSpec); err == nil {
+		t.Errorf("GetDefaultsConfigMap() returned nil; want error for ConfigMap without default.yml")
+	}
+
+	// test no ConfigMap referenced
+	c = newMockClient()
+	current.Spec.DefaultsConfigMapRef = ""
+	got, err = GetDefaultsConfigMap(c, &current, &current.Spec.CommonSplunkSpec)
+	if got != nil || err != nil {
+		t.Errorf("GetDefaultsConfigMap() = %v, %v; want nil, nil", got, err)
+	}
+	c.checkCalls(t, "TestGetDefaultsConfigMap(not-configured)", map[string][]mockFuncCall{})
+}
+
+func TestGetReferencedSecrets(t *testing.T) {
+	current := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	current.Spec.SecretRef = "splunk-provided-secrets"
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-provided-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	getCalls := map[string][]mockFuncCall{"Get": {{metaName: "*v1.Secret-test-splunk-provided-secrets"}}}
+
+	// test missing Secret
+	c := newMockClient()
+	if _, err := GetReferencedSecrets(c, &current, &current.Spec.CommonSplunkSpec); err == nil {
+		t.Errorf("GetReferencedSecrets() returned nil; want error for missing Secret")
+	}
+	c.checkCalls(t, "TestGetReferencedSecrets(missing)", getCalls)
+
+	// test existing Secret
+	c = newMockClient()
+	c.state[getStateKey(&secret)] = &secret
+	got, err := GetReferencedSecrets(c, &current, &current.Spec.CommonSplunkSpec)
+	if err != nil || got == nil || string(got.Data["password"]) != "s3cr3t" {
+		t.Errorf("GetReferencedSecrets() = %v, %v; want %v, nil", got, err, secret)
+	}
+	c.checkCalls(t, "TestGetReferencedSecrets(found)", getCalls)
+
+	// test no Secret referenced
+	c = newMockClient()
+	current.Spec.SecretRef = ""
+	got, err = GetReferencedSecrets(c, &current, &current.Spec.CommonSplunkSpec)
+	if got != nil || err != nil {
+		t.Errorf("GetReferencedSecrets() = %v, %v; want nil, nil", got, err)
+	}
+	c.checkCalls(t, "TestGetReferencedSecrets(not-configured)", map[string][]mockFuncCall{})
+}
+
+func TestApplyConfFilesConfig(t *testing.T) {
+	funcCalls := []mockFuncCall{{metaName: "*v1.Secret-test-splunk-stack1-standalone-conf-files"}}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": funcCalls}
+	current := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	current.Spec.ConfFiles = []enterprisev1.ConfFileSpec{{Name: "limits.conf", Content: "[search]\nmax_searches_per_cpu = 2\n"}}
+	revised := current.DeepCopy()
+	revised.Spec.ConfFiles = append(revised.Spec.ConfFiles, enterprisev1.ConfFileSpec{Name: "web.conf", Content: "[settings]\nenableSplunkWebSSL = true\n"})
+	reconcile := func(c *mockClient, cr interface{}) error {
+		obj := cr.(*enterprisev1.Standalone)
+		_, err := ApplyConfFilesConfig(c, obj, &obj.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+		return err
+	}
+	reconcileTester(t, "TestApplyConfFilesConfig", &current, revised, createCalls, updateCalls, reconcile)
+
+	// nothing to do for instances that receive conf files from a cluster master or deployer
+	c := newMockClient()
+	secret, err := ApplyConfFilesConfig(c, &current, &current.Spec.CommonSplunkSpec, enterprise.SplunkIndexer)
+	if err != nil || secret != nil {
+		t.Errorf("ApplyConfFilesConfig() = %v, %v; want nil, nil", secret, err)
+	}
+	c.checkCalls(t, "TestApplyConfFilesConfig(indexer)", map[string][]mockFuncCall{})
+}
+
+func TestApplySmartStoreConfig(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-s3-keys"},
+		{metaName: "*v1.Secret-test-splunk-stack1-standalone-smartstore"},
+	}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": {funcCalls[1]}}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": {funcCalls[1]}}
+	current := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	current.Spec.SmartStore.VolList = []enterprisev1.SmartStoreVolumeSpec{
+		{Name: "s3vol", Path: "bucket/smartstore", SecretRef: "s3-keys"},
+	}
+	current.Spec.SmartStore.Defaults.VolName = "s3vol"
+	revised := current.DeepCopy()
+	revised.Spec.SmartStore.IndexList = []enterprisev1.SmartStoreIndexSpec{{Name: "main"}}
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s3-keys",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"s3_access_key": []byte("AKIA"),
+			"s3_secret_key": []byte("s3cr3t"),
+		},
+	}
+	reconcile := func(c *mockClient, cr interface{}) error {
+		obj := cr.(*enterprisev1.Standalone)
+		_, err := ApplySmartStoreConfig(c, obj, &obj.Spec.SmartStore, enterprise.SplunkStandalone)
+		return err
+	}
+	reconcileTester(t, "TestApplySmartStoreConfig", &current, revised, createCalls, updateCalls, reconcile, &secret)
+
+	// test missing volume secret
+	c := newMockClient()
+	if _, err := ApplySmartStoreConfig(c, &current, &current.Spec.SmartStore, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySmartStoreConfig() returned nil; want error for missing volume secret")
+	}
+
+	// test gcs volume secret without credentials
+	c = newMockClient()
+	c.state[getStateKey(&secret)] = &secret
+	current.Spec.SmartStore.VolList[0].Provider = enterprise.SmartStoreProviderGCS
+	if _, err := ApplySmartStoreConfig(c, &current, &current.Spec.SmartStore, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySmartStoreConfig() returned nil; want error for missing gcs_credentials")
+	}
+
+	// test missing CA secret
+	current.Spec.SmartStore.VolList[0].Provider = enterprise.SmartStoreProviderS3
+	current.Spec.SmartStore.VolList[0].CASecretRef = "minio-ca"
+	if _, err := ApplySmartStoreConfig(c, &current, &current.Spec.SmartStore, enterprise.SplunkStandalone); err == nil {
+		t.Errorf("ApplySmartStoreConfig() returned nil; want error for missing CA secret")
+	}
+
+	// test smartstore not configured
+	c = newMockClient()
+	current.Spec.SmartStore = enterprisev1.SmartStoreSpec{}
+	smartstore, err := ApplySmartStoreConfig(c, &current, &current.Spec.SmartStore, enterprise.SplunkStandalone)
+	if smartstore != nil || err != nil {
+		t.Errorf("ApplySmartStoreConfig() = %v, %v; want nil, nil", smartstore, err)
+	}
+	c.checkCalls(t, "TestApplySmartStoreConfig(not-configured)", map[string][]mockFuncCall{})
+}
+
+func TestApplyTLSConfig(t *testing.T) {
+	certificateCall := mockFuncCall{metaName: "*unstructured.Unstructured-test-splunk-stack1-standalone-certificate"}
+	certSecretCall := mockFuncCall{metaName: "*v1.Secret-test-splunk-stack1-standalone-certificate"}
+	tlsCall := mockFuncCall{metaName: "*v1.Secret-test-splunk-stack1-standalone-tls"}
+	createCalls := map[string][]mockFuncCall{"Get": {certificateCall, certSecretCall, tlsCall}, "Create": {certificateCall, tlsCall}}
+	updateCalls := map[string][]mockFuncCall{"Get": {certificateCall, certSecretCall, tlsCall}, "Update": {certificateCall}}
+	current := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	current.Spec.TLS.IssuerRef = corev1.ObjectReference{Name: "ca-issuer", Kind: "Issuer"}
+	revised := current.DeepCopy()
+	revised.Spec.TLS.DNSNames = []string{"splunk.example.com"}
+	certSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-standalone-certificate",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"tls.crt": []byte("cert"),
+			"tls.key": []byte("key"),
+			"ca.crt":  []byte("ca"),
+		},
+	}
+	dnsNames := enterprise.GetSplunkDNSNames("test", "stack1", enterprise.SplunkStandalone)
+	reconcile := func(c *mockClient, cr interface{}) error {
+		obj := cr.(*enterprisev1.Standalone)
+		_, err := ApplyTLSConfig(c, obj, &obj.Spec.TLS, enterprise.SplunkStandalone, dnsNames)
+		return err
+	}
+	reconcileTester(t, "TestApplyTLSConfig", &current, revised, createCalls, updateCalls, reconcile, &certSecret)
+
+	// test certificate not issued yet
+	c := newMockClient()
+	if _, err := ApplyTLSConfig(c, &current, &current.Spec.TLS, enterprise.SplunkStandalone, dnsNames); err == nil {
+		t.Errorf("ApplyTLSConfig() returned nil; want error for certificate that has not been issued")
+	}
+	c.checkCalls(t, "TestApplyTLSConfig(not-issued)", map[string][]mockFuncCall{"Get": {certificateCall, certSecretCall}, "Create": {certificateCall}})
+
+	// test tls not configured
+	c = newMockClient()
+	current.Spec.TLS = enterprisev1.TLSSpec{}
+	tls, err := ApplyTLSConfig(c, &current, &current.Spec.TLS, enterprise.SplunkStandalone, dnsNames)
+	if tls != nil || err != nil {
+		t.Errorf("ApplyTLSConfig() = %v, %v; want nil, nil", tls, err)
+	}
+	c.checkCalls(t, "TestApplyTLSConfig(not-configured)", map[string][]mockFuncCall{})
 }
 
 func TestApplyConfigMap(t *testing.T) {
@@ -0,0 +1,114 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ConfigReloadManager is used to apply changes to conf files on Splunk Enterprise instances using the REST API, instead of
+// restarting pods
+type ConfigReloadManager struct {
+	log             logr.Logger
+	cr              enterprisev1.MetaObject
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply reloads conf files on each host (FQDN), if they have changed since the checksum in status. Since kubelet updates
+// mounted Secrets periodically, hosts are only reloaded once all of them have the new conf files, which is determined using
+// the checksum in the Secret's marker file. It returns true once status matches the conf files, or false while waiting for
+// hosts to be updated. Status is cleared if conf files are not reloaded.
+func (mgr *ConfigReloadManager) Apply(hosts []string, confFiles *corev1.Secret, status *string) (bool, error) {
+	marker := enterprise.GetConfFilesReloadMarker(confFiles)
+	if marker == "" {
+		*status = ""
+		return true, nil
+	}
+	checksum := enterprise.GetConfFilesChecksum(confFiles)
+	if *status == checksum {
+		return true, nil
+	}
+
+	for _, host := range hosts {
+		updated, err := mgr.isUpdated(mgr.getClient(host), marker)
+		if err != nil {
+			return false, err
+		}
+		if !updated {
+			mgr.log.Info("Waiting for conf files to be updated before reloading them", "host", host)
+			return false, nil
+		}
+	}
+
+	for _, host := range hosts {
+		mgr.log.Info("Reloading conf files", "host", host)
+		c := mgr.getClient(host)
+		for _, conf := range enterprise.GetReloadableConfFiles(confFiles) {
+			err := c.ReloadConf(conf)
+			if err != nil {
+				return false, fmt.Errorf("Unable to reload %s.conf on %s: %v", conf, host, err)
+			}
+		}
+		err := c.RefreshConfiguration()
+		if err != nil {
+			return false, fmt.Errorf("Unable to refresh configuration on %s: %v", host, err)
+		}
+	}
+
+	*status = checksum
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "ConfFilesReloaded", "Reloaded conf files on %d instances", len(hosts))
+	return true, nil
+}
+
+// isUpdated for ConfigReloadManager returns true if the marker file seen by a Splunk Enterprise instance contains the
+// expected checksum
+func (mgr *ConfigReloadManager) isUpdated(c *splclient.SplunkClient, marker string) (bool, error) {
+	// the marker file is not found until the mounted Secret is first updated to include it
+	notFound := func(err error) bool {
+		responseErr, ok := err.(*splclient.ResponseError)
+		return ok && responseErr.StatusCode == http.StatusNotFound
+	}
+
+	err := c.ReloadConf(enterprise.ConfFilesMarkerConf)
+	if err != nil {
+		if notFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	value, err := c.GetConfValue(enterprise.ConfFilesAppName, enterprise.ConfFilesMarkerConf, enterprise.ConfFilesMarkerStanza, enterprise.ConfFilesMarkerKey)
+	if err != nil {
+		if notFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == marker, nil
+}
+
+// getClient for ConfigReloadManager returns a SplunkClient for the host
+func (mgr *ConfigReloadManager) getClient(host string) *splclient.SplunkClient {
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
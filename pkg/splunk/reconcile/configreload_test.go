@@ -0,0 +1,144 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestConfigReloadManagerApply(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := enterprise.GetSplunkSecrets(&cr, enterprise.SplunkStandalone, nil, nil)
+	confFiles := []enterprisev1.ConfFileSpec{{Name: "limits.conf", Content: "[search]\nmax_searches_per_cpu = 2\n"}}
+	hosts := []string{
+		"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local",
+		"splunk-stack1-standalone-1.splunk-stack1-standalone-headless.test.svc.cluster.local",
+	}
+	var mockSplunkClient *spltest.MockHTTPClient
+	addMarkerHandlers := func(host string, status int, marker string) {
+		mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://" + host + ":8089/services/configs/conf-splunk_operator/_reload",
+			Status: 200,
+		}, spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://" + host + ":8089/servicesNS/nobody/splunk-operator-conf-files/configs/conf-splunk_operator/conf_files?count=0&output_mode=json",
+			Status: status,
+			Body:   `{"entry":[{"name":"conf_files","content":{"checksum":"` + marker + `"}}]}`,
+		})
+	}
+	addReloadHandlers := func(host string) {
+		mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://" + host + ":8089/services/configs/conf-limits/_reload",
+			Status: 200,
+		}, spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://" + host + ":8089/services/debug/refresh",
+			Status: 200,
+		})
+	}
+	mgr := &ConfigReloadManager{
+		log:     log.WithName("TestConfigReloadManagerApply"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	test := func(method string, secret *corev1.Secret, status *string, want bool) {
+		got, err := mgr.Apply(hosts, secret, status)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", method, err)
+		}
+		if got != want {
+			t.Errorf("%s = %t; want %t", method, got, want)
+		}
+		mockSplunkClient.CheckRequests(t, method)
+	}
+
+	// nothing to do if conf files are not reloaded, and status is cleared
+	status := "old"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	test("TestConfigReloadManagerApply(restart)", enterprise.GetConfFilesSecret(&cr, confFiles, enterprise.SplunkStandalone, enterprise.ConfigUpdateStrategyRestart), &status, true)
+	if status != "" {
+		t.Errorf("ConfigReloadManager.Apply() status = %s; want empty", status)
+	}
+	test("TestConfigReloadManagerApply(nil)", nil, &status, true)
+
+	// wait until the mounted secret has been updated on all hosts
+	secret := enterprise.GetConfFilesSecret(&cr, confFiles, enterprise.SplunkStandalone, enterprise.ConfigUpdateStrategyReload)
+	marker := enterprise.GetConfFilesReloadMarker(secret)
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	addMarkerHandlers(hosts[0], 200, marker)
+	addMarkerHandlers(hosts[1], 200, "outdated")
+	test("TestConfigReloadManagerApply(waiting)", secret, &status, false)
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	addMarkerHandlers(hosts[0], 404, "")
+	test("TestConfigReloadManagerApply(not-found)", secret, &status, false)
+	if status != "" {
+		t.Errorf("ConfigReloadManager.Apply() status = %s; want empty while waiting", status)
+	}
+
+	// conf files are reloaded on all hosts once they have been updated
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	for _, host := range hosts {
+		addMarkerHandlers(host, 200, marker)
+	}
+	for _, host := range hosts {
+		addReloadHandlers(host)
+	}
+	test("TestConfigReloadManagerApply(reload)", secret, &status, true)
+	if status != enterprise.GetConfFilesChecksum(secret) {
+		t.Errorf("ConfigReloadManager.Apply() status = %s; want %s", status, enterprise.GetConfFilesChecksum(secret))
+	}
+
+	// nothing to do once conf files have been reloaded
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	test("TestConfigReloadManagerApply(no-change)", secret, &status, true)
+
+	// errors reloading conf files are returned
+	status = ""
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	for _, host := range hosts {
+		addMarkerHandlers(host, 200, marker)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://" + hosts[0] + ":8089/services/configs/conf-limits/_reload",
+		Status: 500,
+	})
+	if _, err := mgr.Apply(hosts, secret, &status); err == nil {
+		t.Errorf("ConfigReloadManager.Apply() returned nil; want error when conf files cannot be reloaded")
+	}
+	if status != "" {
+		t.Errorf("ConfigReloadManager.Apply() status = %s; want empty after an error", status)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyDaemonSet(t *testing.T) {
+	funcCalls := []mockFuncCall{{metaName: "*v1.DaemonSet-test-splunk-uf1-universal-forwarder"}}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": funcCalls}
+	current := appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-uf1-universal-forwarder",
+			Namespace: "test",
+		},
+		Status: appsv1.DaemonSetStatus{
+			DesiredNumberScheduled: 3,
+			UpdatedNumberScheduled: 3,
+			NumberReady:            3,
+		},
+	}
+	wantPhases := []enterprisev1.ResourcePhase{
+		enterprisev1.PhasePending,
+		enterprisev1.PhaseReady,
+		enterprisev1.PhaseUpdating,
+	}
+	wantPhaseNum := 0
+	reconcile := func(c *mockClient, cr interface{}) error {
+		gotPhase, err := ApplyDaemonSet(c, cr.(*appsv1.DaemonSet))
+		if gotPhase != wantPhases[wantPhaseNum] {
+			t.Errorf("TestApplyDaemonSet() got phase[%d] = %s; want %s", wantPhaseNum, gotPhase, wantPhases[wantPhaseNum])
+		}
+		wantPhaseNum++
+		return err
+	}
+
+	// test update
+	revised := current.DeepCopy()
+	revised.Spec.Template.ObjectMeta.Labels = map[string]string{"one": "two"}
+	reconcileTester(t, "TestApplyDaemonSet", &current, revised, createCalls, updateCalls, reconcile)
+
+	// check for no updates, except pending pod updates (in progress)
+	c := newMockClient()
+	c.state[getStateKey(&current)] = &current
+	current.Status.UpdatedNumberScheduled = 1
+	gotPhase, err := ApplyDaemonSet(c, &current)
+	if gotPhase != enterprisev1.PhaseUpdating || err != nil {
+		t.Errorf("TestApplyDaemonSet() = %s, %v; want %s, nil", gotPhase, err, enterprisev1.PhaseUpdating)
+	}
+
+	// check for no updates, except waiting for pods to become ready
+	c = newMockClient()
+	c.state[getStateKey(&current)] = &current
+	current.Status.UpdatedNumberScheduled = 3
+	current.Status.NumberReady = 2
+	gotPhase, err = ApplyDaemonSet(c, &current)
+	if gotPhase != enterprisev1.PhaseScalingUp || err != nil {
+		t.Errorf("TestApplyDaemonSet() = %s, %v; want %s, nil", gotPhase, err, enterprisev1.PhaseScalingUp)
+	}
+	current.Status.NumberReady = 0
+	gotPhase, _ = ApplyDaemonSet(c, &current)
+	if gotPhase != enterprisev1.PhasePending {
+		t.Errorf("TestApplyDaemonSet() got phase = %s; want %s", gotPhase, enterprisev1.PhasePending)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// DecommissionManager is used to remove the Splunk Enterprise instances of a custom resource from the cluster master and
+// monitoring console that they are registered with, before the custom resource is deleted
+type DecommissionManager struct {
+	log             logr.Logger
+	cr              enterprisev1.MetaObject
+	spec            *enterprisev1.CommonSplunkSpec
+	hosts           []string
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// newDecommissionManager returns a DecommissionManager for a custom resource, with the hosts (FQDNs) that it registers
+// with a monitoring console. It returns nil for custom resources that do not have any Splunk Enterprise instances.
+func newDecommissionManager(cr enterprisev1.MetaObject) *DecommissionManager {
+	mgr := &DecommissionManager{
+		log:             log.WithName("DecommissionManager").WithValues("kind", cr.GetTypeMeta().Kind, "name", cr.GetIdentifier(), "namespace", cr.GetNamespace()),
+		cr:              cr,
+		newSplunkClient: splclient.NewSplunkClient,
+	}
+	switch cr := cr.(type) {
+	case *enterprisev1.Standalone:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+		mgr.hosts = strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkStandalone, cr.GetIdentifier(), cr.Spec.Replicas, false), ",")
+	case *enterprisev1.SearchHeadCluster:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+		mgr.hosts = strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkSearchHead, cr.GetIdentifier(), cr.Spec.Replicas, false), ",")
+	case *enterprisev1.IndexerCluster:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+		mgr.hosts = getIndexerClusterHosts(cr)
+	case *enterprisev1.ClusterMaster:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+		mgr.hosts = []string{resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, cr.GetIdentifier(), false))}
+	case *enterprisev1.LicenseMaster:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+	case *enterprisev1.MonitoringConsole:
+		mgr.spec = &cr.Spec.CommonSplunkSpec
+	default:
+		return nil
+	}
+	return mgr
+}
+
+// IsRequired for DecommissionManager returns true if the custom resource has instances that must be removed from a referenced
+// ClusterMaster, an external cluster master or a monitoring console before it is deleted
+func (mgr *DecommissionManager) IsRequired() bool {
+	if idxc, ok := mgr.cr.(*enterprisev1.IndexerCluster); ok && !enterprise.HasOwnClusterMaster(&idxc.Spec) {
+		return true
+	}
+	return len(mgr.hosts) > 0 && mgr.spec.MonitoringConsoleRef.Name != ""
+}
+
+// Apply for DecommissionManager decommissions the indexer cluster peers of an IndexerCluster that references a ClusterMaster
+// or joins an external cluster master, and then removes all instances from a referenced monitoring console. It returns true
+// once complete, and should be called again until it does. The license master automatically removes license slaves that stop reporting to it.
+func (mgr *DecommissionManager) Apply(c ControllerClient) (bool, error) {
+	if idxc, ok := mgr.cr.(*enterprisev1.IndexerCluster); ok && !enterprise.HasOwnClusterMaster(&idxc.Spec) {
+		complete, err := mgr.decommissionIndexerClusterPeers(c, idxc)
+		if err != nil || !complete {
+			return false, err
+		}
+	}
+
+	mcManager := MonitoringConsolePeerManager{log: mgr.log, cr: mgr.cr, spec: mgr.spec, newSplunkClient: mgr.newSplunkClient}
+	err := mcManager.UnregisterPeers(c, mgr.hosts)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// decommissionIndexerClusterPeers for DecommissionManager decommissions all indexer cluster peers of an IndexerCluster, including
+// those of each site, from a referenced ClusterMaster or external cluster master. Nothing is done if the ClusterMaster, or the
+// Secret used to access the external cluster master, no longer exists.
+func (mgr *DecommissionManager) decommissionIndexerClusterPeers(c ControllerClient, cr *enterprisev1.IndexerCluster) (bool, error) {
+	var clusterMasterSecrets *corev1.Secret
+	var err error
+	if enterprise.IsExternalClusterMasterConfigured(&cr.Spec.ExternalClusterMaster) {
+		clusterMasterSecrets, err = getExternalClusterMasterSecret(c, cr)
+	} else {
+		_, clusterMasterSecrets, err = getReferencedClusterMaster(c, cr)
+	}
+	if err != nil {
+		mgr.log.Info("Unable to get cluster master; skipping decommission of indexer cluster peers", "error", err.Error())
+		return true, nil
+	}
+
+	var secrets corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: enterprise.GetSplunkSecretsName(cr.GetIdentifier(), enterprise.SplunkIndexer)}
+	err = c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return false, err
+	}
+
+	podManager := IndexerClusterPodManager{log: mgr.log, cr: cr, secrets: &secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: mgr.newSplunkClient}
+	if len(cr.Status.Sites) == 0 {
+		return podManager.decommissionPeers()
+	}
+	for idx := range cr.Status.Sites {
+		podManager.site = &cr.Status.Sites[idx]
+		complete, err := podManager.decommissionPeers()
+		if err != nil || !complete {
+			return false, err
+		}
+	}
+	return true, nil
+}
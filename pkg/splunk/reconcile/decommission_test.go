@@ -0,0 +1,181 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestDecommissionManagerIsRequired(t *testing.T) {
+	test := func(cr enterprisev1.MetaObject, want bool) {
+		mgr := newDecommissionManager(cr)
+		if got := mgr != nil && mgr.IsRequired(); got != want {
+			t.Errorf("IsRequired() for %s = %t; want %t", cr.GetTypeMeta().Kind, got, want)
+		}
+	}
+
+	standalone := enterprisev1.Standalone{
+		TypeMeta:   metav1.TypeMeta{Kind: "Standalone"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+		Spec:       enterprisev1.StandaloneSpec{Replicas: 1},
+	}
+	test(&standalone, false)
+	standalone.Spec.MonitoringConsoleRef.Name = "mc1"
+	test(&standalone, true)
+
+	// license masters are not registered with a monitoring console by the operator
+	licenseMaster := enterprisev1.LicenseMaster{
+		TypeMeta:   metav1.TypeMeta{Kind: "LicenseMaster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	licenseMaster.Spec.MonitoringConsoleRef.Name = "mc1"
+	test(&licenseMaster, false)
+
+	// indexer clusters that reference a ClusterMaster always need their peers decommissioned
+	idxc := enterprisev1.IndexerCluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "IndexerCluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+		Spec:       enterprisev1.IndexerClusterSpec{Replicas: 1},
+	}
+	test(&idxc, false)
+	idxc.Spec.ClusterMasterRef.Name = "cm"
+	test(&idxc, true)
+
+	// spark clusters have no Splunk Enterprise instances
+	spark := enterprisev1.Spark{
+		TypeMeta:   metav1.TypeMeta{Kind: "Spark"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	test(&spark, false)
+}
+
+func TestDecommissionManagerApply(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.IndexerClusterSpec{
+			Replicas: 2,
+			CommonSplunkSpec: enterprisev1.CommonSplunkSpec{
+				ClusterMasterRef: corev1.ObjectReference{Name: "cm"},
+			},
+		},
+		Status: enterprisev1.IndexerClusterStatus{
+			Peers: []enterprisev1.IndexerClusterMemberStatus{
+				{Name: "splunk-stack1-indexer-0"},
+				{Name: "splunk-stack1-indexer-1"},
+			},
+		},
+	}
+	clusterMaster := enterprisev1.ClusterMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cm",
+			Namespace: "test",
+		},
+	}
+	clusterMasterSecrets := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-cm-indexer-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{"password": []byte("123")},
+	}
+	secrets := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-indexer-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{"password": []byte("456")},
+	}
+
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := newDecommissionManager(&cr)
+	mgr.newSplunkClient = func(managementURI, username, password string) *splclient.SplunkClient {
+		c := splclient.NewSplunkClient(managementURI, username, password)
+		c.Client = mockSplunkClient
+		return c
+	}
+	peersURL := "https://splunk-cm-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/peers?count=0&output_mode=json"
+	test := func(method, peersBody string, wantComplete bool, handlers ...spltest.MockHTTPHandler) {
+		mockSplunkClient = &spltest.MockHTTPClient{}
+		mockSplunkClient.AddHandlers(append([]spltest.MockHTTPHandler{{Method: "GET", URL: peersURL, Status: 200, Err: nil, Body: peersBody}}, handlers...)...)
+		c := newMockClient()
+		for _, obj := range []runtime.Object{&clusterMaster, &clusterMasterSecrets, &secrets} {
+			c.state[getStateKey(obj)] = obj
+		}
+		complete, err := mgr.Apply(c)
+		if complete != wantComplete || err != nil {
+			t.Errorf("%s: Apply() returned %t, %v; want %t, nil", method, complete, err, wantComplete)
+		}
+		mockSplunkClient.CheckRequests(t, method)
+	}
+
+	// peers that are up are decommissioned one at a time
+	test("TestDecommissionManagerApply(up)",
+		`{"entry":[{"name":"ID0","content":{"label":"splunk-stack1-indexer-0","status":"Up"}},{"name":"ID1","content":{"label":"splunk-stack1-indexer-1","status":"Up"}}]}`,
+		false,
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://splunk-stack1-indexer-0.splunk-stack1-indexer-headless.test.svc.cluster.local:8089/services/cluster/slave/control/control/decommission?enforce_counts=1",
+			Status: 200,
+			Err:    nil,
+			Body:   ``,
+		})
+
+	// wait while a peer is decommissioning
+	test("TestDecommissionManagerApply(decommissioning)",
+		`{"entry":[{"name":"ID0","content":{"label":"splunk-stack1-indexer-0","status":"Decommissioning"}},{"name":"ID1","content":{"label":"splunk-stack1-indexer-1","status":"Up"}}]}`,
+		false)
+
+	// peers that have shut down are removed from the cluster master, and complete once none remain
+	test("TestDecommissionManagerApply(removed)",
+		`{"entry":[{"name":"ID0","content":{"label":"splunk-stack1-indexer-0","status":"GracefulShutdown"}},{"name":"ID1","content":{"label":"splunk-stack1-indexer-1","status":"Down"}}]}`,
+		true,
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://splunk-cm-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/remove_peers?peers=ID0",
+			Status: 200,
+			Err:    nil,
+			Body:   ``,
+		},
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://splunk-cm-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/remove_peers?peers=ID1",
+			Status: 200,
+			Err:    nil,
+			Body:   ``,
+		})
+
+	// nothing to do if the ClusterMaster no longer exists
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	c := newMockClient()
+	complete, err := mgr.Apply(c)
+	if !complete || err != nil {
+		t.Errorf("Apply() returned %t, %v; want true, nil for missing ClusterMaster", complete, err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestDecommissionManagerApply(missing)")
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplyDeploymentServer reconciles the state for a Splunk Enterprise deployment server.
+func ApplyDeploymentServer(client ControllerClient, cr *enterprisev1.DeploymentServer) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyDeploymentServer(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplyDeploymentServer").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateDeploymentServerSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		client.Status().Update(context.TODO(), cr)
+	}()
+
+	// check if deletion has been requested
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
+			cr.Status.Phase = enterprisev1.PhaseTerminating
+		} else {
+			result.Requeue = false
+		}
+		return result, err
+	}
+
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update general config resources
+	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkDeploymentServer, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkDeploymentServer))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update app repository configuration; apps are installed as deployment apps
+	appManager := AppRepoManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, status: &cr.Status.AppRepo, newS3Client: splclient.NewS3Client, newAzureClient: splclient.NewAzureBlobClient}
+	apps, err := appManager.Apply(client, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve server classes and deployment apps from referenced ConfigMaps
+	configMaps, err := GetDeploymentServerConfigMaps(client, cr)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a stable service used by deployment clients within the Kubernetes cluster
+	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkDeploymentServer, false))
+	if err != nil {
+		return result, err
+	}
+	cr.Status.TargetURI = enterprise.GetDeploymentServerTargetURI(cr)
+
+	// create or update an external service used by deployment clients outside of the Kubernetes cluster, if enabled
+	cr.Status.ExternalTargetURI = ""
+	if cr.Spec.ExternalService.Enabled {
+		service := enterprise.GetDeploymentServerExternalService(cr)
+		err = ApplyService(client, service)
+		if err != nil {
+			return result, err
+		}
+		if endpoints := enterprise.GetIndexerDiscoveryEndpoints(service); len(endpoints) > 0 {
+			cr.Status.ExternalTargetURI = endpoints[0]
+		}
+	}
+
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkDeploymentServer, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkDeploymentServer, false))
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkDeploymentServer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update statefulset
+	statefulSet, err := enterprise.GetDeploymentServerStatefulSet(cr)
+	if err != nil {
+		return result, err
+	}
+	if apps != nil {
+		// restart pods to install any changes to app packages
+		enterprise.SetAppsChecksum(&statefulSet.Spec.Template, enterprise.GetAppsChecksum(apps))
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
+	enterprise.SetDeploymentAppsChecksum(&statefulSet.Spec.Template, configMaps)
+	mgr := DefaultStatefulSetPodManager{}
+	phase, err := mgr.Update(client, statefulSet, 1)
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if cr.Status.Phase == enterprisev1.PhaseReady || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkDeploymentServer, cr.GetIdentifier(), 1, false), ","))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// reload changes to conf files once the deployment server is ready, if configUpdateStrategy is reload
+	reloaded := true
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		reloadManager := ConfigReloadManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		reloaded, err = reloadManager.Apply(strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkDeploymentServer, cr.GetIdentifier(), 1, false), ","), confFiles, &cr.Status.ConfFilesChecksum)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, and no conf files are waiting to be reloaded
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		cr.Status.Apps = apps
+		result.Requeue = !reloaded
+	}
+	return result, nil
+}
+
+// GetDeploymentServerConfigMaps retrieves the ConfigMaps providing server classes and deployment apps that are referenced by a
+// deployment server. An error is returned if any of them do not exist, or if the server class ConfigMap does not contain a
+// serverclass.conf file.
+func GetDeploymentServerConfigMaps(client ControllerClient, cr *enterprisev1.DeploymentServer) ([]*corev1.ConfigMap, error) {
+	var configMaps []*corev1.ConfigMap
+
+	if cr.Spec.ServerClassConfigMapRef != "" {
+		var configMap corev1.ConfigMap
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.ServerClassConfigMapRef}
+		err := client.Get(context.TODO(), namespacedName, &configMap)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get server class ConfigMap %s: %v", cr.Spec.ServerClassConfigMapRef, err)
+		}
+		if err = enterprise.ValidateServerClassConfigMap(&configMap); err != nil {
+			return nil, err
+		}
+		configMaps = append(configMaps, &configMap)
+	}
+
+	apps, err := getDeploymentAppConfigMaps(client, cr, cr.Spec.DeploymentApps)
+	if err != nil {
+		return nil, err
+	}
+	return append(configMaps, apps...), nil
+}
+
+// getDeploymentAppConfigMaps retrieves the ConfigMaps providing the configuration files of apps distributed by a deployment server
+// or search head cluster deployer. An error is returned if any of them do not exist.
+func getDeploymentAppConfigMaps(client ControllerClient, cr enterprisev1.MetaObject, apps []enterprisev1.DeploymentAppSpec) ([]*corev1.ConfigMap, error) {
+	var configMaps []*corev1.ConfigMap
+	for _, app := range apps {
+		var configMap corev1.ConfigMap
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: app.ConfigMapRef}
+		err := client.Get(context.TODO(), namespacedName, &configMap)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get ConfigMap %s for deployment app %s: %v", app.ConfigMapRef, app.Name, err)
+		}
+		configMaps = append(configMaps, &configMap)
+	}
+	return configMaps, nil
+}
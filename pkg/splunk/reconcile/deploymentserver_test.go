@@ -0,0 +1,133 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestApplyDeploymentServer(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-deployment-server-secrets"},
+		{metaName: "*v1.ConfigMap-test-serverclasses"},
+		{metaName: "*v1.ConfigMap-test-outputs"},
+		{metaName: "*v1.Service-test-splunk-stack1-deployment-server-service"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-deployment-server"},
+	}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": {funcCalls[0], funcCalls[3], funcCalls[4]}}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": {funcCalls[4]}}
+	current := enterprisev1.DeploymentServer{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "DeploymentServer",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.DeploymentServerSpec{
+			ServerClassConfigMapRef: "serverclasses",
+			DeploymentApps:          []enterprisev1.DeploymentAppSpec{{Name: "uf_outputs", ConfigMapRef: "outputs"}},
+		},
+	}
+	serverClasses := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverclasses",
+			Namespace: "test",
+		},
+		Data: map[string]string{"serverclass.conf": "[serverClass:linux:app:uf_outputs]\n"},
+	}
+	outputs := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "outputs",
+			Namespace: "test",
+		},
+		Data: map[string]string{"outputs.conf": "[tcpout]\n"},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyDeploymentServer(c, cr.(*enterprisev1.DeploymentServer))
+		return err
+	}
+	reconcileTester(t, "TestApplyDeploymentServer", &current, revised, createCalls, updateCalls, reconcile, &serverClasses, &outputs)
+
+	// test deletion
+	currentTime := metav1.NewTime(time.Now())
+	revised.ObjectMeta.DeletionTimestamp = &currentTime
+	revised.ObjectMeta.Finalizers = []string{"enterprise.splunk.com/delete-pvc"}
+	deleteFunc := func(cr enterprisev1.MetaObject, c ControllerClient) (bool, error) {
+		_, err := ApplyDeploymentServer(c, cr.(*enterprisev1.DeploymentServer))
+		return true, err
+	}
+	splunkDeletionTester(t, revised, deleteFunc)
+}
+
+func TestGetDeploymentServerConfigMaps(t *testing.T) {
+	cr := enterprisev1.DeploymentServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	// nothing to retrieve if no ConfigMaps are referenced
+	c := newMockClient()
+	configMaps, err := GetDeploymentServerConfigMaps(c, &cr)
+	if err != nil || len(configMaps) != 0 {
+		t.Errorf("GetDeploymentServerConfigMaps() = %v, %v; want empty, nil", configMaps, err)
+	}
+
+	// referenced ConfigMaps must exist
+	cr.Spec.ServerClassConfigMapRef = "serverclasses"
+	if _, err = GetDeploymentServerConfigMaps(c, &cr); err == nil {
+		t.Errorf("GetDeploymentServerConfigMaps() returned nil; want error for missing server class ConfigMap")
+	}
+
+	// server class ConfigMaps must contain serverclass.conf
+	serverClasses := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverclasses",
+			Namespace: "test",
+		},
+		Data: map[string]string{"inputs.conf": ""},
+	}
+	c.state[getStateKey(&serverClasses)] = &serverClasses
+	if _, err = GetDeploymentServerConfigMaps(c, &cr); err == nil {
+		t.Errorf("GetDeploymentServerConfigMaps() returned nil; want error for missing serverclass.conf")
+	}
+	serverClasses.Data = map[string]string{"serverclass.conf": ""}
+
+	cr.Spec.DeploymentApps = []enterprisev1.DeploymentAppSpec{{Name: "uf_outputs", ConfigMapRef: "outputs"}}
+	if _, err = GetDeploymentServerConfigMaps(c, &cr); err == nil {
+		t.Errorf("GetDeploymentServerConfigMaps() returned nil; want error for missing deployment app ConfigMap")
+	}
+	outputs := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "outputs",
+			Namespace: "test",
+		},
+	}
+	c.state[getStateKey(&outputs)] = &outputs
+	configMaps, err = GetDeploymentServerConfigMaps(c, &cr)
+	if err != nil || len(configMaps) != 2 {
+		t.Errorf("GetDeploymentServerConfigMaps() = %v, %v; want 2 ConfigMaps, nil", configMaps, err)
+	}
+}
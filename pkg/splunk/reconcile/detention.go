@@ -0,0 +1,130 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// interval used to check indexer cluster peers for detention, once they are ready
+const detentionCheckInterval = time.Minute
+
+// setPeerDetentionStatus updates the detention reported for an indexer cluster peer using the status reported for it by
+// the cluster master, and returns true if the peer has just entered a detention mode. The start time is reset whenever
+// the mode changes, and remediations are only counted while a peer remains in the same mode.
+func setPeerDetentionStatus(status *enterprisev1.IndexerClusterPeerDetentionStatus, peerStatus string, now time.Time) bool {
+	mode := enterprise.GetPeerDetentionMode(peerStatus)
+	if mode == status.Mode {
+		return false
+	}
+	*status = enterprisev1.IndexerClusterPeerDetentionStatus{Mode: mode}
+	if mode == "" {
+		return false
+	}
+	status.StartTime = metav1.NewTime(now)
+	return true
+}
+
+// isDetentionRemediationDue returns true if an indexer cluster peer has been in automatic detention for at least delay since
+// it was detained, or since the most recent remediation attempted for it
+func isDetentionRemediationDue(status *enterprisev1.IndexerClusterPeerDetentionStatus, delay time.Duration, now time.Time) bool {
+	if status.Mode != enterprise.PeerDetentionAutomatic {
+		return false
+	}
+	since := status.StartTime.Time
+	if status.RemediationTime.After(since) {
+		since = status.RemediationTime.Time
+	}
+	return now.Sub(since) >= delay
+}
+
+// hasDetainedPeers returns true if any peers of an indexer cluster, including the peers of each site, are in detention
+func hasDetainedPeers(cr *enterprisev1.IndexerCluster) bool {
+	for _, peer := range cr.Status.Peers {
+		if peer.Detention.Mode != "" {
+			return true
+		}
+	}
+	for _, site := range cr.Status.Sites {
+		for _, peer := range site.Peers {
+			if peer.Detention.Mode != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remediateDetention for IndexerClusterPodManager attempts the remediation configured for indexer cluster peers that have
+// remained in automatic detention for longer than the remediation delay, including the peers of each site. Buckets are only
+// rebalanced once for all of the peers that are due. Nothing is done if remediation is not enabled.
+func (mgr *IndexerClusterPodManager) remediateDetention(now time.Time) error {
+	spec := &mgr.cr.Spec.Detention
+	if !enterprise.IsDetentionRemediationEnabled(spec) {
+		return nil
+	}
+	delay := time.Duration(spec.RemediationDelaySeconds) * time.Second
+
+	managers := []IndexerClusterPodManager{*mgr}
+	for idx := range mgr.cr.Status.Sites {
+		siteManager := *mgr
+		siteManager.site = &mgr.cr.Status.Sites[idx]
+		managers = append(managers, siteManager)
+	}
+	var due []*enterprisev1.IndexerClusterMemberStatus
+	var clients []*splclient.SplunkClient
+	for idx := range managers {
+		peers := managers[idx].getPeers()
+		for n := range *peers {
+			peer := &(*peers)[n]
+			if isDetentionRemediationDue(&peer.Detention, delay, now) {
+				due = append(due, peer)
+				clients = append(clients, managers[idx].getClient(int32(n)))
+			}
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	if spec.Remediation == enterprise.DetentionRemediationRebalance {
+		mgr.log.Info("Rebalancing buckets for indexer cluster peers in automatic detention", "peers", len(due))
+		err := mgr.getClusterMasterClient().RebalanceClusterBuckets()
+		if err != nil {
+			return fmt.Errorf("Unable to rebalance buckets for indexer cluster peers in automatic detention: %v", err)
+		}
+	}
+	for n, peer := range due {
+		if spec.Remediation == enterprise.DetentionRemediationRestart {
+			mgr.log.Info("Restarting indexer cluster peer in automatic detention", "peerName", peer.Name)
+			err := clients[n].RestartSplunk()
+			if err != nil {
+				return fmt.Errorf("Unable to restart indexer cluster peer %s in automatic detention: %v", peer.Name, err)
+			}
+		}
+		peer.Detention.RemediationTime = metav1.NewTime(now)
+		peer.Detention.Remediations++
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "DetentionRemediated", "Attempted %s remediation for indexer cluster peer %s in automatic detention (attempt %d)", spec.Remediation, peer.Name, peer.Detention.Remediations)
+	}
+	return nil
+}
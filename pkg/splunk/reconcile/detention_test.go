@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestSetPeerDetentionStatus(t *testing.T) {
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	var status enterprisev1.IndexerClusterPeerDetentionStatus
+
+	test := func(peerStatus string, now time.Time, wantDetained bool, want enterprisev1.IndexerClusterPeerDetentionStatus) {
+		detained := setPeerDetentionStatus(&status, peerStatus, now)
+		if detained != wantDetained {
+			t.Errorf("setPeerDetentionStatus(%s) = %t; want %t", peerStatus, detained, wantDetained)
+		}
+		if status.Mode != want.Mode || !status.StartTime.Equal(&want.StartTime) || status.Remediations != want.Remediations {
+			t.Errorf("setPeerDetentionStatus(%s) status = %v; want %v", peerStatus, status, want)
+		}
+	}
+
+	test("Up", start, false, enterprisev1.IndexerClusterPeerDetentionStatus{})
+	test("AutomaticDetention", start, true, enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "automatic", StartTime: metav1.NewTime(start)})
+	status.Remediations = 1
+	test("AutomaticDetention", start.Add(time.Hour), false, enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "automatic", StartTime: metav1.NewTime(start), Remediations: 1})
+	test("ManualDetention", start.Add(time.Hour), true, enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "manual", StartTime: metav1.NewTime(start.Add(time.Hour))})
+	test("Up", start.Add(2*time.Hour), false, enterprisev1.IndexerClusterPeerDetentionStatus{})
+}
+
+func TestIsDetentionRemediationDue(t *testing.T) {
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	test := func(status enterprisev1.IndexerClusterPeerDetentionStatus, now time.Time, want bool) {
+		if got := isDetentionRemediationDue(&status, 15*time.Minute, now); got != want {
+			t.Errorf("isDetentionRemediationDue(%v, %s) = %t; want %t", status, now, got, want)
+		}
+	}
+
+	automatic := enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "automatic", StartTime: metav1.NewTime(start)}
+	test(enterprisev1.IndexerClusterPeerDetentionStatus{}, start.Add(time.Hour), false)
+	test(enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "manual", StartTime: metav1.NewTime(start)}, start.Add(time.Hour), false)
+	test(automatic, start.Add(10*time.Minute), false)
+	test(automatic, start.Add(15*time.Minute), true)
+	automatic.RemediationTime = metav1.NewTime(start.Add(15 * time.Minute))
+	test(automatic, start.Add(20*time.Minute), false)
+	test(automatic, start.Add(30*time.Minute), true)
+}
+
+func TestIndexerClusterRemediateDetention(t *testing.T) {
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	cr := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Status.Peers = []enterprisev1.IndexerClusterMemberStatus{
+		{Name: "splunk-stack1-indexer-0", Status: "Up"},
+		{Name: "splunk-stack1-indexer-1", Status: "AutomaticDetention", Detention: enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "automatic", StartTime: metav1.NewTime(start)}},
+		{Name: "splunk-stack1-indexer-2", Status: "ManualDetention", Detention: enterprisev1.IndexerClusterPeerDetentionStatus{Mode: "manual", StartTime: metav1.NewTime(start)}},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestIndexerClusterRemediateDetention"),
+		cr:      &cr,
+		secrets: &corev1.Secret{Data: map[string][]byte{"password": []byte{'1', '2', '3'}}},
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	test := func(testname string, remediation string, now time.Time, wantRemediations int32, handlers ...spltest.MockHTTPHandler) {
+		cr.Spec.Detention = enterprisev1.IndexerClusterDetentionSpec{Remediation: remediation, RemediationDelaySeconds: 900}
+		mockSplunkClient = &spltest.MockHTTPClient{}
+		mockSplunkClient.AddHandlers(handlers...)
+		err := mgr.remediateDetention(now)
+		if err != nil {
+			t.Errorf("%s: remediateDetention() returned %v; want nil", testname, err)
+		}
+		if got := cr.Status.Peers[1].Detention.Remediations; got != wantRemediations {
+			t.Errorf("%s: remediateDetention() remediations = %d; want %d", testname, got, wantRemediations)
+		}
+		if got := cr.Status.Peers[2].Detention.Remediations; got != 0 {
+			t.Errorf("%s: remediateDetention() remediations of peer in manual detention = %d; want 0", testname, got)
+		}
+		mockSplunkClient.CheckRequests(t, testname)
+	}
+
+	// peers are only reported if remediation is not enabled, or until the remediation delay has passed
+	test("TestIndexerClusterRemediateDetention(none)", "None", start.Add(time.Hour), 0)
+	test("TestIndexerClusterRemediateDetention(delay)", "Restart", start.Add(10*time.Minute), 0)
+
+	// peers still in automatic detention are restarted
+	test("TestIndexerClusterRemediateDetention(restart)", "Restart", start.Add(15*time.Minute), 1, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-indexer-1.splunk-stack1-indexer-headless.test.svc.cluster.local:8089/services/server/control/restart",
+		Status: 200,
+	})
+	test("TestIndexerClusterRemediateDetention(restarted)", "Restart", start.Add(20*time.Minute), 1)
+
+	// buckets are rebalanced once the remediation delay has passed again
+	test("TestIndexerClusterRemediateDetention(rebalance)", "Rebalance", start.Add(30*time.Minute), 2, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/rebalance_buckets?action=start",
+		Status: 200,
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// annotation used to save a checksum of the desired state that the operator last applied to a Kubernetes resource, which
+// is used to tell changes made by others (drift) apart from changes to the desired state
+const desiredChecksumAnnotation = "enterprise.splunk.com/desired-checksum"
+
+// getDesiredChecksum returns a checksum of the desired state of a Kubernetes resource, or of the parts of it that are
+// managed by the operator
+func getDesiredChecksum(desired interface{}) string {
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// setDesiredChecksum saves the checksum of the desired state applied to a Kubernetes resource in its annotations
+func setDesiredChecksum(obj metav1.Object, checksum string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[desiredChecksumAnnotation] = checksum
+	obj.SetAnnotations(annotations)
+}
+
+// isDriftWarnOnly returns true if the custom resource that controls a Kubernetes resource only reports drift, using its
+// drift-policy annotation. Drift is reverted if the custom resource cannot be retrieved.
+func isDriftWarnOnly(c ControllerClient, obj metav1.Object) bool {
+	cr := getControllingCustomResource(c, obj)
+	return cr != nil && enterprise.IsDriftWarnOnly(cr)
+}
+
+// getControllingCustomResource returns the custom resource that controls a Kubernetes resource, or nil if it has none
+// or it cannot be found
+func getControllingCustomResource(c ControllerClient, obj metav1.Object) *unstructured.Unstructured {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil {
+		return nil
+	}
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion(owner.APIVersion)
+	cr.SetKind(owner.Kind)
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name}, cr)
+	if err != nil {
+		return nil
+	}
+	return cr
+}
+
+// checkDrift is used before updating a Kubernetes resource that differs from its desired state, where original is the
+// resource before the desired state was merged into current. If the desired state has not changed since it was last
+// applied, the resource has been changed outside of the operator, and this drift is reported using an event for the
+// custom resource that owns it. It returns false if the update should be skipped, because the owner only reports drift.
+func checkDrift(c ControllerClient, original, current ResourceObject, checksum string) bool {
+	if original.GetObjectMeta().GetAnnotations()[desiredChecksumAnnotation] != checksum {
+		return true
+	}
+
+	kind := getObjectKind(current)
+	name := current.GetObjectMeta().GetName()
+	fields := strings.Join(getChangedFields(original, current), ", ")
+	scopedLog := log.WithName("checkDrift").WithValues("kind", kind, "name", name, "namespace", current.GetObjectMeta().GetNamespace())
+	if isDriftWarnOnly(c, current.GetObjectMeta()) {
+		scopedLog.Info("Detected changes made outside of the operator", "fields", fields)
+		recordOwnerEvent(current.GetObjectMeta(), corev1.EventTypeWarning, "DriftDetected",
+			"%s %s was changed outside of the operator (%s); leaving it unchanged due to drift policy", kind, name, fields)
+		return false
+	}
+	scopedLog.Info("Reverting changes made outside of the operator", "fields", fields)
+	recordOwnerEvent(current.GetObjectMeta(), corev1.EventTypeWarning, "DriftCorrected",
+		"Reverted changes made outside of the operator to %s %s (%s)", kind, name, fields)
+	return true
+}
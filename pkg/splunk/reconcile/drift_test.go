@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestApplyStatefulSetDrift(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v1beta1",
+			Kind:       "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	var replicas int32 = 1
+	desired := func(version string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "splunk-stack1-standalone",
+				Namespace:       "test",
+				OwnerReferences: []metav1.OwnerReference{resources.AsOwner(&cr)},
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "splunk", "version": version}},
+				},
+			},
+		}
+	}
+	statefulSetCalls := []mockFuncCall{{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"}}
+	ownerCalls := []mockFuncCall{{metaName: "*unstructured.Unstructured-test-stack1"}}
+	test := func(testname string, revised *appsv1.StatefulSet, wantPhase enterprisev1.ResourcePhase, wantCalls map[string][]mockFuncCall, c *mockClient) {
+		c.resetCalls()
+		phase, err := ApplyStatefulSet(c, revised)
+		if err != nil || phase != wantPhase {
+			t.Errorf("%s: ApplyStatefulSet() = %s, %v; want %s, nil", testname, phase, err, wantPhase)
+		}
+		c.checkCalls(t, testname, wantCalls)
+	}
+
+	c := newMockClient()
+	test("TestApplyStatefulSetDrift(create)", desired("1"), enterprisev1.PhasePending,
+		map[string][]mockFuncCall{"Get": statefulSetCalls, "Create": statefulSetCalls}, c)
+	checkEvents(t, "TestApplyStatefulSetDrift(create)", recorder, "Normal StatefulSetCreated Created StatefulSet splunk-stack1-standalone")
+
+	// changes to the desired state are not drift
+	test("TestApplyStatefulSetDrift(update)", desired("2"), enterprisev1.PhaseUpdating,
+		map[string][]mockFuncCall{"Get": statefulSetCalls, "Update": statefulSetCalls}, c)
+	checkEvents(t, "TestApplyStatefulSetDrift(update)", recorder)
+
+	// changes made outside of the operator are reverted
+	current := c.state["*v1.StatefulSet-test-splunk-stack1-standalone"].(*appsv1.StatefulSet)
+	current.Spec.Template.ObjectMeta.Labels = map[string]string{"app": "edited"}
+	test("TestApplyStatefulSetDrift(correct)", desired("2"), enterprisev1.PhaseUpdating,
+		map[string][]mockFuncCall{"Get": append(statefulSetCalls, ownerCalls...), "Update": statefulSetCalls}, c)
+	checkEvents(t, "TestApplyStatefulSetDrift(correct)", recorder,
+		"Warning DriftCorrected Reverted changes made outside of the operator to StatefulSet splunk-stack1-standalone (spec.template.metadata.labels)")
+
+	// changes made outside of the operator are only reported if the owner's drift policy is warn
+	owner := &unstructured.Unstructured{}
+	owner.SetName("stack1")
+	owner.SetNamespace("test")
+	owner.SetAnnotations(map[string]string{enterprise.DriftPolicyAnnotation: enterprise.DriftPolicyWarn})
+	c.state[getStateKey(owner)] = owner
+	current = c.state["*v1.StatefulSet-test-splunk-stack1-standalone"].(*appsv1.StatefulSet)
+	current.Spec.Template.ObjectMeta.Labels = map[string]string{"app": "edited"}
+	revised := desired("2")
+	test("TestApplyStatefulSetDrift(warn)", revised, enterprisev1.PhaseReady,
+		map[string][]mockFuncCall{"Get": append(statefulSetCalls, ownerCalls...)}, c)
+	checkEvents(t, "TestApplyStatefulSetDrift(warn)", recorder,
+		"Warning DriftDetected StatefulSet splunk-stack1-standalone was changed outside of the operator (spec.template.metadata.labels); leaving it unchanged due to drift policy")
+	if got := revised.Spec.Template.ObjectMeta.Labels["app"]; got != "edited" {
+		t.Errorf("TestApplyStatefulSetDrift(warn): ApplyStatefulSet() label app = %s; want edited", got)
+	}
+}
+
+func TestApplyConfigMapDrift(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v1beta1",
+			Kind:       "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	desired := func() *corev1.ConfigMap {
+		configMap := enterprise.GetSplunkDefaults("stack1", "test", enterprise.SplunkStandalone, "splunk:\n  hec_disabled: 1\n")
+		configMap.SetOwnerReferences([]metav1.OwnerReference{resources.AsOwner(&cr)})
+		return configMap
+	}
+	configMapCalls := []mockFuncCall{{metaName: "*v1.ConfigMap-test-splunk-stack1-standalone-defaults"}}
+
+	c := newMockClient()
+	if err := ApplyConfigMap(c, desired()); err != nil {
+		t.Errorf("ApplyConfigMap() returned %v; want nil", err)
+	}
+	current := c.state["*v1.ConfigMap-test-splunk-stack1-standalone-defaults"].(*corev1.ConfigMap)
+	if current.GetAnnotations()[desiredChecksumAnnotation] != getDesiredChecksum(desired().Data) {
+		t.Errorf("ApplyConfigMap() annotations = %v; want desired checksum", current.GetAnnotations())
+	}
+
+	// changes made outside of the operator are reverted
+	current.Data = map[string]string{"default.yml": "edited"}
+	c.resetCalls()
+	if err := ApplyConfigMap(c, desired()); err != nil {
+		t.Errorf("ApplyConfigMap() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplyConfigMapDrift(correct)", map[string][]mockFuncCall{
+		"Get":    {configMapCalls[0], {metaName: "*unstructured.Unstructured-test-stack1"}},
+		"Update": configMapCalls,
+	})
+	checkEvents(t, "TestApplyConfigMapDrift(correct)", recorder,
+		"Warning DriftCorrected Reverted changes made outside of the operator to ConfigMap splunk-stack1-standalone-defaults (data)")
+	current = c.state["*v1.ConfigMap-test-splunk-stack1-standalone-defaults"].(*corev1.ConfigMap)
+	if current.Data["default.yml"] != "splunk:\n  hec_disabled: 1\n" {
+		t.Errorf("ApplyConfigMap() data = %v; want desired data", current.Data)
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+const (
+	// maximum depth of the field paths reported for updates during dry runs (for example, "spec.template.spec.containers")
+	maxDryRunFieldDepth = 4
+
+	// maximum length of the message of DryRun events
+	maxDryRunEventLength = 1024
+)
+
+// dryRuns contains the UIDs of all custom resources that are being dry run, and is used to discard the events that
+// would otherwise be recorded for them
+var dryRuns sync.Map
+
+// dryRunClient is a ControllerClient used to dry run reconciles. Changes to Kubernetes resources are recorded instead of
+// being made, and resources that would have been created, updated or deleted are returned that way by Get (but not List)
+// for the rest of the dry run.
+type dryRunClient struct {
+	ControllerClient
+
+	// mutex is used to protect changes and objects
+	mutex sync.Mutex
+
+	// changes that would have been made, in order
+	changes []string
+
+	// objects that would have been created or updated, or nil for objects that would have been deleted
+	objects map[string]runtime.Object
+}
+
+// newDryRunClient returns a new dryRunClient used to dry run reconciles with a ControllerClient
+func newDryRunClient(c ControllerClient) *dryRunClient {
+	return &dryRunClient{ControllerClient: c, objects: make(map[string]runtime.Object)}
+}
+
+// getObjectKind returns the kind of a Kubernetes resource
+func getObjectKind(obj runtime.Object) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	return reflect.TypeOf(obj).Elem().Name()
+}
+
+// getDryRunKey returns the key used to store a Kubernetes resource in dryRunClient objects
+func getDryRunKey(obj runtime.Object, key client.ObjectKey) string {
+	return fmt.Sprintf("%T/%s/%s", obj, getObjectKind(obj), key)
+}
+
+// addChange records a change that would have been made
+func (c *dryRunClient) addChange(format string, args ...interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.changes = append(c.changes, fmt.Sprintf(format, args...))
+}
+
+// getChanges returns all changes that would have been made
+func (c *dryRunClient) getChanges() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.changes...)
+}
+
+// store records the state that a Kubernetes resource would have, or nil if it would have been deleted
+func (c *dryRunClient) store(obj runtime.Object, deleted bool) {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	key := getDryRunKey(obj, client.ObjectKey{Namespace: metaObj.GetNamespace(), Name: metaObj.GetName()})
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if deleted {
+		c.objects[key] = nil
+	} else {
+		c.objects[key] = obj.DeepCopyObject()
+	}
+}
+
+// getName returns the name of a Kubernetes resource
+func getName(obj runtime.Object) string {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return metaObj.GetName()
+}
+
+// Get returns the state that a Kubernetes resource would have after the changes recorded, or its current state if none
+// have been recorded for it
+func (c *dryRunClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.mutex.Lock()
+	stored, ok := c.objects[getDryRunKey(obj, key)]
+	c.mutex.Unlock()
+	if !ok {
+		return c.ControllerClient.Get(ctx, key, obj)
+	}
+	if stored == nil {
+		return errors.NewNotFound(schema.GroupResource{Resource: getObjectKind(obj)}, key.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored.DeepCopyObject()).Elem())
+	return nil
+}
+
+// Create records that a Kubernetes resource would have been created
+func (c *dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.addChange("Create %s %s", getObjectKind(obj), getName(obj))
+	c.store(obj, false)
+	return nil
+}
+
+// Update records that a Kubernetes resource would have been updated, and which of its fields would have changed
+func (c *dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	change := fmt.Sprintf("Update %s %s", getObjectKind(obj), getName(obj))
+	current := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+	current.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	metaObj, err := meta.Accessor(obj)
+	if err == nil {
+		err = c.Get(ctx, client.ObjectKey{Namespace: metaObj.GetNamespace(), Name: metaObj.GetName()}, current)
+	}
+	if err == nil {
+		if fields := getChangedFields(current, obj); len(fields) > 0 {
+			change = fmt.Sprintf("%s (%s)", change, strings.Join(fields, ", "))
+		}
+	}
+	c.addChange("%s", change)
+	c.store(obj, false)
+	return nil
+}
+
+// Patch records that a Kubernetes resource would have been patched
+func (c *dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.addChange("Patch %s %s", getObjectKind(obj), getName(obj))
+	return nil
+}
+
+// Delete records that a Kubernetes resource would have been deleted
+func (c *dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.addChange("Delete %s %s", getObjectKind(obj), getName(obj))
+	c.store(obj, true)
+	return nil
+}
+
+// DeleteAllOf records that Kubernetes resources would have been deleted
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.addChange("Delete all %s", getObjectKind(obj))
+	return nil
+}
+
+// Status returns a StatusWriter that discards all updates, since the status of a custom resource is reported separately
+// after it has been dry run
+func (c *dryRunClient) Status() client.StatusWriter {
+	return dryRunStatusWriter{}
+}
+
+// dryRunStatusWriter is a StatusWriter that discards all updates
+type dryRunStatusWriter struct{}
+
+// Update does nothing and returns nil
+func (w dryRunStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return nil
+}
+
+// Patch does nothing and returns nil
+func (w dryRunStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return nil
+}
+
+// getChangedFields returns the paths of the fields of a Kubernetes resource that differ between current and revised,
+// ignoring its status and all metadata other than labels, annotations, owner references and finalizers
+func getChangedFields(current, revised runtime.Object) []string {
+	values := make([]map[string]interface{}, 2)
+	for i, obj := range []runtime.Object{current, revised} {
+		data, err := json.Marshal(obj)
+		if err == nil {
+			err = json.Unmarshal(data, &values[i])
+		}
+		if err != nil {
+			return nil
+		}
+		delete(values[i], "apiVersion")
+		delete(values[i], "kind")
+		delete(values[i], "status")
+		if metadata, ok := values[i]["metadata"].(map[string]interface{}); ok {
+			for k := range metadata {
+				if k != "labels" && k != "annotations" && k != "ownerReferences" && k != "finalizers" {
+					delete(metadata, k)
+				}
+			}
+		}
+	}
+
+	var fields []string
+	diffFields("", values[0], values[1], 0, &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+// isEmptyValue returns true if a value decoded from JSON is null, or an empty object or array
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// diffFields appends the paths of all fields that differ between two values decoded from JSON to fields, up to a
+// maximum depth
+func diffFields(path string, current, revised interface{}, depth int, fields *[]string) {
+	if isEmptyValue(current) && isEmptyValue(revised) {
+		return
+	}
+	currentMap, currentOk := current.(map[string]interface{})
+	revisedMap, revisedOk := revised.(map[string]interface{})
+	if !currentOk || !revisedOk || depth >= maxDryRunFieldDepth {
+		if !reflect.DeepEqual(current, revised) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+
+	keys := make(map[string]bool)
+	for k := range currentMap {
+		keys[k] = true
+	}
+	for k := range revisedMap {
+		keys[k] = true
+	}
+	for k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		diffFields(child, currentMap[k], revisedMap[k], depth+1, fields)
+	}
+}
+
+// dryRunHTTPClient is used by Splunk REST API clients during dry runs. GET requests are sent, while other requests are
+// recorded as changes and return an error, so that nothing that depends on them is done.
+type dryRunHTTPClient struct {
+	client splclient.SplunkHTTPClient
+	dryRun *dryRunClient
+}
+
+// Do sends GET requests, and records all other requests instead of sending them
+func (c *dryRunHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Method == http.MethodGet {
+		return c.client.Do(request)
+	}
+	change := fmt.Sprintf("%s %s://%s%s", request.Method, request.URL.Scheme, request.URL.Host, request.URL.Path)
+	c.dryRun.addChange("%s", change)
+	return nil, fmt.Errorf("dry run stopped before %s", change)
+}
+
+// getSplunkClientFactory returns the function used to create Splunk REST API clients for instances reconciled using a
+// ControllerClient, which only send GET requests during dry runs, and record spans for all requests during traced reconciles
+func getSplunkClientFactory(c ControllerClient) func(managementURI, username, password string) *splclient.SplunkClient {
+	dryRun, ok := c.(*dryRunClient)
+	span := getReconcileSpan(c)
+	if !ok && span == nil {
+		return splclient.NewSplunkClient
+	}
+	return func(managementURI, username, password string) *splclient.SplunkClient {
+		splunkClient := splclient.NewSplunkClient(managementURI, username, password)
+		if ok {
+			splunkClient.Client = &dryRunHTTPClient{client: splunkClient.Client, dryRun: dryRun}
+			splunkClient.MaxRetries = 0
+		}
+		if span != nil {
+			splunkClient.Client = &tracingHTTPClient{client: splunkClient.Client, span: span}
+		}
+		return splunkClient
+	}
+}
+
+// isDryRunRequested returns true if dry runs have been requested for a custom resource, and it is not already being dry
+// run using c. Custom resources that are being deleted are never dry run, so that they can always be cleaned up.
+func isDryRunRequested(c ControllerClient, cr enterprisev1.MetaObject) bool {
+	_, ok := c.(*dryRunClient)
+	return !ok && enterprise.IsDryRun(cr) && cr.GetObjectMeta().GetDeletionTimestamp() == nil
+}
+
+// isDryRunning returns true if an object is a custom resource that is being dry run
+func isDryRunning(obj runtime.Object) bool {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	_, ok := dryRuns.Load(metaObj.GetUID())
+	return ok
+}
+
+// getDryRunSummary returns the message of the event recorded for a dry run
+func getDryRunSummary(status *enterprisev1.DryRunStatus) string {
+	var sb strings.Builder
+	if len(status.Changes) == 0 {
+		sb.WriteString("Dry run found no changes")
+	} else {
+		fmt.Fprintf(&sb, "Dry run found %d changes: %s", len(status.Changes), strings.Join(status.Changes, "; "))
+	}
+	if status.Error != "" {
+		fmt.Fprintf(&sb, " (stopped by error: %s)", status.Error)
+	}
+	summary := sb.String()
+	if len(summary) > maxDryRunEventLength {
+		summary = summary[:maxDryRunEventLength-3] + "..."
+	}
+	return summary
+}
+
+// applyDryRun dry runs a reconcile of a custom resource using apply, and reports all changes that it would make (along
+// with any error that stopped it) in status, instead of making them. All other changes that apply makes to the custom
+// resource are discarded. A DryRun event is recorded whenever the changes found are different from the previous dry run.
+func applyDryRun(c ControllerClient, cr enterprisev1.MetaObject, status **enterprisev1.DryRunStatus, apply func(ControllerClient) (reconcile.Result, error)) (reconcile.Result, error) {
+	scopedLog := log.WithName("applyDryRun").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+	original := cr.DeepCopyObject()
+	previous := *status
+
+	dryRun := newDryRunClient(c)
+	uid := cr.GetObjectMeta().GetUID()
+	dryRuns.Store(uid, true)
+	_, err := apply(dryRun)
+	dryRuns.Delete(uid)
+
+	// discard changes made to the custom resource, and only report the dry run in its status
+	reflect.ValueOf(cr).Elem().Set(reflect.ValueOf(original).Elem())
+	*status = &enterprisev1.DryRunStatus{
+		ObservedGeneration: cr.GetObjectMeta().GetGeneration(),
+		LastDryRunTime:     metav1.Now(),
+		Changes:            dryRun.getChanges(),
+	}
+	if err != nil {
+		(*status).Error = err.Error()
+	}
+	scopedLog.Info("Dry run complete", "changes", (*status).Changes, "error", (*status).Error)
+
+	if previous == nil || !reflect.DeepEqual(previous.Changes, (*status).Changes) || previous.Error != (*status).Error {
+		recordEvent(cr, corev1.EventTypeNormal, "DryRun", "%s", getDryRunSummary(*status))
+	}
+
+	// nothing changes until the custom resource or its resources do, so there is no need to requeue
+	return reconcile.Result{}, c.Status().Update(context.TODO(), cr)
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestDryRunClient(t *testing.T) {
+	ctx := context.TODO()
+	var replicas int32 = 1
+	current := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone", Namespace: "test"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&current)] = &current
+	dryRun := newDryRunClient(c)
+
+	// created resources are returned by Get, but not created
+	service := corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone-headless", Namespace: "test"}}
+	dryRun.Create(ctx, &service)
+	var gotService corev1.Service
+	err := dryRun.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-standalone-headless"}, &gotService)
+	if err != nil || gotService.GetName() != service.GetName() {
+		t.Errorf("dryRunClient.Get() = %v, %v; want %s, nil", gotService, err, service.GetName())
+	}
+
+	// updates report the fields that would change
+	revised := current.DeepCopy()
+	var revisedReplicas int32 = 3
+	revised.Spec.Replicas = &revisedReplicas
+	revised.Spec.Template.ObjectMeta.Annotations = map[string]string{"enterprise.splunk.com/secrets-checksum": "abc"}
+	dryRun.Update(ctx, revised)
+
+	// deleted resources are not found
+	dryRun.Delete(ctx, revised)
+	err = dryRun.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-standalone"}, &appsv1.StatefulSet{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("dryRunClient.Get() returned %v; want NotFound", err)
+	}
+
+	dryRun.DeleteAllOf(ctx, &corev1.Pod{})
+	dryRun.Status().Update(ctx, revised)
+
+	want := []string{
+		"Create Service splunk-stack1-standalone-headless",
+		"Update StatefulSet splunk-stack1-standalone (spec.replicas, spec.template.metadata.annotations)",
+		"Delete StatefulSet splunk-stack1-standalone",
+		"Delete all Pod",
+	}
+	if got := dryRun.getChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dryRunClient changes = %v; want %v", got, want)
+	}
+	c.checkCalls(t, "TestDryRunClient", map[string][]mockFuncCall{
+		"Get": {{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"}},
+	})
+}
+
+func TestGetChangedFields(t *testing.T) {
+	test := func(current, revised corev1.Service, want []string) {
+		if got := getChangedFields(&current, &revised); !reflect.DeepEqual(got, want) {
+			t.Errorf("getChangedFields() = %v; want %v", got, want)
+		}
+	}
+
+	current := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "test", ResourceVersion: "1"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+	}
+	revised := current
+	test(current, revised, nil)
+	revised.ObjectMeta.ResourceVersion = "2"
+	revised.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	revised.Spec.Selector = map[string]string{}
+	test(current, revised, nil)
+	revised.Spec.Type = corev1.ServiceTypeLoadBalancer
+	revised.ObjectMeta.Labels = map[string]string{"app.kubernetes.io/name": "standalone"}
+	test(current, revised, []string{"metadata.labels.app.kubernetes.io/name", "spec.type"})
+}
+
+func TestDryRunHTTPClient(t *testing.T) {
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-cm:8089/services/cluster/master/info?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{}`,
+	})
+	dryRun := newDryRunClient(newMockClient())
+	splunkClient := getSplunkClientFactory(dryRun)("https://splunk-cm:8089", "admin", "changeme")
+	splunkClient.Client.(*dryRunHTTPClient).client = mockSplunkClient
+
+	// GET requests are sent, and all other requests are only recorded
+	if err := splunkClient.Get("/services/cluster/master/info", nil); err != nil {
+		t.Errorf("SplunkClient.Get() returned %v; want nil", err)
+	}
+	if err := splunkClient.ApplyClusterMasterBundle(); err == nil {
+		t.Errorf("SplunkClient.ApplyClusterMasterBundle() returned nil; want error")
+	}
+	mockSplunkClient.CheckRequests(t, "TestDryRunHTTPClient")
+	want := []string{"POST https://splunk-cm:8089/services/cluster/master/control/default/apply"}
+	if got := dryRun.getChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dryRunHTTPClient changes = %v; want %v", got, want)
+	}
+
+	// clients are not changed when reconciles are not dry run
+	splunkClient = getSplunkClientFactory(newMockClient())("https://splunk-cm:8089", "admin", "changeme")
+	if _, ok := splunkClient.Client.(*dryRunHTTPClient); ok {
+		t.Errorf("getSplunkClientFactory() returned a dry run client; want default")
+	}
+}
+
+func TestApplyStandaloneDryRun(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Generation:  2,
+			Annotations: map[string]string{enterprise.DryRunAnnotation: "true"},
+		},
+	}
+	original := cr.DeepCopy()
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-standalone-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-standalone-headless"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"},
+	}
+
+	// nothing is created, and the changes that would be made are reported in status
+	c := newMockClient()
+	result, err := ApplyStandalone(c, &cr)
+	if err != nil || result.Requeue {
+		t.Errorf("ApplyStandalone() = %v, %v; want no requeue, nil", result, err)
+	}
+	c.checkCalls(t, "TestApplyStandaloneDryRun", map[string][]mockFuncCall{"Get": funcCalls})
+	if cr.Status.DryRun == nil {
+		t.Fatalf("ApplyStandalone() status.dryRun = nil; want changes")
+	}
+	want := []string{
+		"Create Secret splunk-stack1-standalone-secrets",
+		"Create Service splunk-stack1-standalone-headless",
+		"Create StatefulSet splunk-stack1-standalone",
+	}
+	if !reflect.DeepEqual(cr.Status.DryRun.Changes, want) || cr.Status.DryRun.Error != "" || cr.Status.DryRun.ObservedGeneration != 2 {
+		t.Errorf("ApplyStandalone() status.dryRun = %v; want changes %v for generation 2", cr.Status.DryRun, want)
+	}
+
+	// all other changes to the custom resource are discarded
+	if !reflect.DeepEqual(cr.Spec, original.Spec) || cr.Status.Phase != original.Status.Phase || cr.Status.ObservedGeneration != 0 {
+		t.Errorf("ApplyStandalone() changed custom resource during dry run: spec=%v phase=%s", cr.Spec, cr.Status.Phase)
+	}
+
+	// dry run status is removed once dry runs are no longer requested
+	cr.ObjectMeta.Annotations = nil
+	c.resetCalls()
+	_, err = ApplyStandalone(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyStandalone() returned %v; want nil", err)
+	}
+	if cr.Status.DryRun != nil {
+		t.Errorf("ApplyStandalone() status.dryRun = %v; want nil", cr.Status.DryRun)
+	}
+	c.checkCalls(t, "TestApplyStandaloneDryRun(disabled)", map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls})
+}
+
+func TestGetDryRunSummary(t *testing.T) {
+	test := func(status enterprisev1.DryRunStatus, want string) {
+		if got := getDryRunSummary(&status); got != want {
+			t.Errorf("getDryRunSummary() = %s; want %s", got, want)
+		}
+	}
+
+	test(enterprisev1.DryRunStatus{}, "Dry run found no changes")
+	test(enterprisev1.DryRunStatus{Changes: []string{"Create Service s1", "Update StatefulSet s1 (spec.replicas)"}},
+		"Dry run found 2 changes: Create Service s1; Update StatefulSet s1 (spec.replicas)")
+	test(enterprisev1.DryRunStatus{Changes: []string{"POST https://s1:8089/services/apps/local"}, Error: "dry run stopped before POST https://s1:8089/services/apps/local"},
+		"Dry run found 1 changes: POST https://s1:8089/services/apps/local (stopped by error: dry run stopped before POST https://s1:8089/services/apps/local)")
+
+	got := getDryRunSummary(&enterprisev1.DryRunStatus{Changes: []string{strings.Repeat("x", 2000)}})
+	if len(got) != maxDryRunEventLength || !strings.HasSuffix(got, "...") {
+		t.Errorf("getDryRunSummary() length = %d; want %d ending with ...", len(got), maxDryRunEventLength)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// eventRecorder is used to record Kubernetes events for custom resources; events are discarded until SetEventRecorder is called
+var eventRecorder record.EventRecorder = &record.FakeRecorder{}
+
+// SetEventRecorder sets the recorder used to record Kubernetes events for custom resources
+func SetEventRecorder(recorder record.EventRecorder) {
+	eventRecorder = recorder
+}
+
+// recordEvent records a Kubernetes event for a custom resource, unless it is being dry run
+func recordEvent(cr runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if isDryRunning(cr) {
+		return
+	}
+	eventRecorder.Eventf(cr, eventType, reason, messageFmt, args...)
+}
+
+// recordOwnerEvent records a Kubernetes event for the custom resource that controls a Kubernetes object, if any, unless
+// it is being dry run
+func recordOwnerEvent(obj metav1.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if _, ok := dryRuns.Load(owner.UID); ok {
+			continue
+		}
+		ref := &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Namespace:  obj.GetNamespace(),
+			UID:        owner.UID,
+		}
+		eventRecorder.Eventf(ref, eventType, reason, messageFmt, args...)
+	}
+}
+
+// recordPhaseEvents records Kubernetes events for a custom resource after it has been reconciled, if reconciling it
+// failed or if it has finished updating to a new desired state (spec)
+func recordPhaseEvents(cr runtime.Object, oldPhase, phase enterprisev1.ResourcePhase, err error) {
+	if err != nil {
+		recordEvent(cr, corev1.EventTypeWarning, "ReconcileError", "%v", err)
+		return
+	}
+	if oldPhase == enterprisev1.PhaseUpdating && phase == enterprisev1.PhaseReady {
+		recordEvent(cr, corev1.EventTypeNormal, "UpgradeCompleted", "All pods have been updated")
+	}
+}
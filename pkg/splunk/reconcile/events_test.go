@@ -0,0 +1,117 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// checkEvents verifies that the events recorded by a FakeRecorder match those wanted
+func checkEvents(t *testing.T, method string, recorder *record.FakeRecorder, want ...string) {
+	for _, wantEvent := range want {
+		select {
+		case got := <-recorder.Events:
+			if got != wantEvent {
+				t.Errorf("%s recorded event %s; want %s", method, got, wantEvent)
+			}
+		default:
+			t.Errorf("%s did not record event %s", method, wantEvent)
+		}
+	}
+	select {
+	case got := <-recorder.Events:
+		t.Errorf("%s recorded unexpected event %s", method, got)
+	default:
+	}
+}
+
+func TestRecordOwnerEvent(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v1beta1",
+			Kind:       "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	var replicas int32 = 1
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-standalone",
+			Namespace: "test",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	// events are not recorded for objects without a controller
+	recordOwnerEvent(statefulSet, corev1.EventTypeNormal, "StatefulSetCreated", "Created StatefulSet %s", statefulSet.GetName())
+	checkEvents(t, "recordOwnerEvent()", recorder)
+
+	// events for StatefulSets are recorded for the custom resource that owns them
+	statefulSet.SetOwnerReferences([]metav1.OwnerReference{resources.AsOwner(&cr)})
+	c := newMockClient()
+	if _, err := ApplyStatefulSet(c, statefulSet); err != nil {
+		t.Errorf("ApplyStatefulSet() returned %v; want nil", err)
+	}
+	checkEvents(t, "ApplyStatefulSet()", recorder, "Normal StatefulSetCreated Created StatefulSet splunk-stack1-standalone")
+
+	statefulSet.Status.Replicas = 1
+	statefulSet.Status.ReadyReplicas = 1
+	if _, err := UpdateStatefulSetPods(c, statefulSet, &DefaultStatefulSetPodManager{}, 3); err != nil {
+		t.Errorf("UpdateStatefulSetPods() returned %v; want nil", err)
+	}
+	checkEvents(t, "UpdateStatefulSetPods()", recorder, "Normal ScalingUp Scaling up StatefulSet splunk-stack1-standalone to 3 replicas")
+}
+
+func TestRecordPhaseEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	recordPhaseEvents(&cr, enterprisev1.PhaseReady, enterprisev1.PhaseReady, nil)
+	checkEvents(t, "recordPhaseEvents(ready)", recorder)
+
+	recordPhaseEvents(&cr, enterprisev1.PhaseUpdating, enterprisev1.PhaseUpdating, nil)
+	checkEvents(t, "recordPhaseEvents(updating)", recorder)
+
+	recordPhaseEvents(&cr, enterprisev1.PhaseUpdating, enterprisev1.PhaseReady, nil)
+	checkEvents(t, "recordPhaseEvents(upgraded)", recorder, "Normal UpgradeCompleted All pods have been updated")
+
+	recordPhaseEvents(&cr, enterprisev1.PhasePending, enterprisev1.PhaseError, errors.New("Unable to find Secret"))
+	checkEvents(t, "recordPhaseEvents(error)", recorder, "Warning ReconcileError Unable to find Secret")
+}
@@ -22,13 +22,76 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 )
 
 const (
 	splunkFinalizerDeletePVC = "enterprise.splunk.com/delete-pvc"
+
+	// finalizer used to decommission instances from a referenced ClusterMaster and monitoring console, before any others
+	splunkFinalizerDecommission = "enterprise.splunk.com/decommission"
+
+	// finalizer used to block deletion of custom resources with a deletion-protection annotation, before anything is cleaned up
+	splunkFinalizerDeletionProtection = "enterprise.splunk.com/deletion-protection"
 )
 
+// ApplySplunkFinalizers adds the finalizers required to clean up after a custom resource when it is deleted, if missing:
+// one to decommission its instances if they are registered with a referenced ClusterMaster or monitoring console, and one
+// to delete its persistent volume claims if pvcCleanupPolicy is "Delete". Another is added to block deletion if it has a
+// deletion-protection annotation. Finalizers that are no longer required are left in place, since they do nothing if
+// there is nothing to clean up. Only the finalizers are patched, so any defaults applied to the spec during validation
+// are not persisted.
+func ApplySplunkFinalizers(cr enterprisev1.MetaObject, c ControllerClient, spec *enterprisev1.CommonSplunkSpec) error {
+	finalizers := cr.GetObjectMeta().GetFinalizers()
+	changed := false
+	if enterprise.IsDeletionProtected(cr) && !hasFinalizer(finalizers, splunkFinalizerDeletionProtection) {
+		finalizers = append(finalizers, splunkFinalizerDeletionProtection)
+		changed = true
+	}
+	if mgr := newDecommissionManager(cr); mgr != nil && mgr.IsRequired() && !hasFinalizer(finalizers, splunkFinalizerDecommission) {
+		finalizers = append(finalizers, splunkFinalizerDecommission)
+		changed = true
+	}
+	if spec.PVCCleanupPolicy == enterprise.PVCCleanupPolicyDelete && !hasFinalizer(finalizers, splunkFinalizerDeletePVC) {
+		finalizers = append(finalizers, splunkFinalizerDeletePVC)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	scopedLog := log.WithName("ApplySplunkFinalizers").WithValues("kind", cr.GetTypeMeta().Kind, "name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+	scopedLog.Info("Adding finalizers", "finalizers", finalizers)
+	patch := client.MergeFrom(cr.DeepCopyObject())
+	cr.GetObjectMeta().SetFinalizers(finalizers)
+	return c.Patch(context.Background(), cr, patch)
+}
+
+// addSplunkFinalizer adds a finalizer to a custom resource, if missing. Only the finalizers are patched.
+func addSplunkFinalizer(cr enterprisev1.MetaObject, c ControllerClient, finalizer string) error {
+	finalizers := cr.GetObjectMeta().GetFinalizers()
+	if hasFinalizer(finalizers, finalizer) {
+		return nil
+	}
+
+	scopedLog := log.WithName("addSplunkFinalizer").WithValues("kind", cr.GetTypeMeta().Kind, "name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+	scopedLog.Info("Adding finalizer", "name", finalizer)
+	patch := client.MergeFrom(cr.DeepCopyObject())
+	cr.GetObjectMeta().SetFinalizers(append(finalizers, finalizer))
+	return c.Patch(context.Background(), cr, patch)
+}
+
+// hasFinalizer returns true if a list of finalizers includes the given one
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
 // CheckSplunkDeletion checks to see if deletion was requested for the custom resource.
 // If so, it will process and remove any remaining finalizers.
 func CheckSplunkDeletion(cr enterprisev1.MetaObject, c ControllerClient) (bool, error) {
@@ -51,7 +114,40 @@ func CheckSplunkDeletion(cr enterprisev1.MetaObject, c ControllerClient) (bool,
 
 	scopedLog.Info("Deletion requested")
 
-	// process each finalizer
+	// nothing is cleaned up until deletion protection is removed, so that instances and their volumes are left untouched
+	// until deletion has been confirmed
+	if hasFinalizer(cr.GetObjectMeta().GetFinalizers(), splunkFinalizerDeletionProtection) {
+		if enterprise.IsDeletionProtected(cr) {
+			scopedLog.Info("Deletion blocked by deletion protection")
+			recordEvent(cr, corev1.EventTypeWarning, "DeletionBlocked",
+				"Deletion is blocked until the %s annotation is removed", enterprise.DeletionProtectionAnnotation)
+			return false, nil
+		}
+		if err := RemoveSplunkFinalizer(cr, c, splunkFinalizerDeletionProtection); err != nil {
+			return false, err
+		}
+	}
+
+	// decommission instances first, since other finalizers may delete their volumes
+	if hasFinalizer(cr.GetObjectMeta().GetFinalizers(), splunkFinalizerDecommission) {
+		mgr := newDecommissionManager(cr)
+		if mgr != nil {
+			mgr.newSplunkClient = getSplunkClientFactory(c)
+			complete, err := mgr.Apply(c)
+			if err != nil {
+				return false, err
+			}
+			if !complete {
+				scopedLog.Info("Waiting for instances to be decommissioned")
+				return false, nil
+			}
+		}
+		if err := RemoveSplunkFinalizer(cr, c, splunkFinalizerDecommission); err != nil {
+			return false, err
+		}
+	}
+
+	// process each remaining finalizer
 	for _, finalizer := range cr.GetObjectMeta().GetFinalizers() {
 		switch finalizer {
 		case splunkFinalizerDeletePVC:
@@ -81,10 +177,16 @@ func DeleteSplunkPvc(cr enterprisev1.MetaObject, c ControllerClient) error {
 		component = "standalone"
 	case "LicenseMaster":
 		component = "license-master"
+	case "MonitoringConsole":
+		component = "monitoring-console"
+	case "DeploymentServer":
+		component = "deployment-server"
 	case "SearchHeadCluster":
 		component = "search-head"
 	case "IndexerCluster":
 		component = "indexer"
+	case "ClusterMaster":
+		component = "cluster-master"
 	default:
 		scopedLog.Info("Skipping PVC removal")
 		return nil
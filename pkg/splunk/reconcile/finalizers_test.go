@@ -16,6 +16,7 @@ package reconcile
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -23,7 +24,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 )
 
 func splunkDeletionTester(t *testing.T, cr enterprisev1.MetaObject, delete func(enterprisev1.MetaObject, ControllerClient) (bool, error)) {
@@ -33,10 +34,14 @@ func splunkDeletionTester(t *testing.T, cr enterprisev1.MetaObject, delete func(
 		component = "standalone"
 	case "LicenseMaster":
 		component = "license-master"
+	case "MonitoringConsole":
+		component = "monitoring-console"
 	case "SearchHeadCluster":
 		component = "search-head"
 	case "IndexerCluster":
 		component = "indexer"
+	case "ClusterMaster":
+		component = "cluster-master"
 	}
 
 	labels := map[string]string{
@@ -62,7 +67,7 @@ func splunkDeletionTester(t *testing.T, cr enterprisev1.MetaObject, delete func(
 	if cr.GetObjectMeta().GetDeletionTimestamp() != nil {
 		wantDeleted = true
 		mockCalls["Update"] = []mockFuncCall{
-			{metaName: fmt.Sprintf("*v1alpha2.%s-%s-%s", cr.GetTypeMeta().Kind, cr.GetNamespace(), cr.GetIdentifier())},
+			{metaName: fmt.Sprintf("*v1beta1.%s-%s-%s", cr.GetTypeMeta().Kind, cr.GetNamespace(), cr.GetIdentifier())},
 		}
 		if component != "" {
 			mockCalls["Delete"] = []mockFuncCall{
@@ -101,6 +106,20 @@ func TestCheckSplunkDeletion(t *testing.T) {
 	cr.ObjectMeta.Finalizers = []string{"enterprise.splunk.com/delete-pvc"}
 	splunkDeletionTester(t, &cr, CheckSplunkDeletion)
 
+	// cluster masters only delete their own PVCs, and not those of an indexer cluster with the same name
+	cm := enterprisev1.ClusterMaster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterMaster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stack1",
+			Namespace:         "test",
+			DeletionTimestamp: &currentTime,
+			Finalizers:        []string{"enterprise.splunk.com/delete-pvc"},
+		},
+	}
+	splunkDeletionTester(t, &cm, CheckSplunkDeletion)
+
 	// try with unrecognized finalizer
 	c := newMockClient()
 	cr.ObjectMeta.Finalizers = append(cr.ObjectMeta.Finalizers, "bad-finalizer")
@@ -109,3 +128,146 @@ func TestCheckSplunkDeletion(t *testing.T) {
 		t.Errorf("CheckSplunkDeletion() returned %t, %v; want false, (error)", deleted, err)
 	}
 }
+
+func TestApplySplunkFinalizers(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.StandaloneSpec{
+			Replicas: 1,
+		},
+	}
+
+	test := func(wantFinalizers []string, wantPatch bool) {
+		c := newMockClient()
+		err := ApplySplunkFinalizers(&cr, c, &cr.Spec.CommonSplunkSpec)
+		if err != nil {
+			t.Errorf("ApplySplunkFinalizers() returned %v; want nil", err)
+		}
+		if !reflect.DeepEqual(cr.ObjectMeta.Finalizers, wantFinalizers) {
+			t.Errorf("ApplySplunkFinalizers() finalizers = %v; want %v", cr.ObjectMeta.Finalizers, wantFinalizers)
+		}
+		wantCalls := map[string][]mockFuncCall{}
+		if wantPatch {
+			wantCalls["Patch"] = []mockFuncCall{{metaName: "*v1beta1.Standalone-test-stack1"}}
+		}
+		c.checkCalls(t, "TestApplySplunkFinalizers", wantCalls)
+	}
+
+	// no finalizers are needed by default
+	test(nil, false)
+
+	// persistent volume claims are deleted with the custom resource if the cleanup policy is "Delete"
+	cr.Spec.PVCCleanupPolicy = "Delete"
+	test([]string{"enterprise.splunk.com/delete-pvc"}, true)
+
+	// instances are decommissioned from a referenced monitoring console
+	cr.Spec.MonitoringConsoleRef.Name = "mc1"
+	test([]string{"enterprise.splunk.com/delete-pvc", "enterprise.splunk.com/decommission"}, true)
+
+	// finalizers are only added once, and are not removed if no longer required
+	cr.Spec.PVCCleanupPolicy = "Retain"
+	cr.Spec.MonitoringConsoleRef.Name = ""
+	test([]string{"enterprise.splunk.com/delete-pvc", "enterprise.splunk.com/decommission"}, false)
+
+	// deletion is blocked if deletion protection is enabled
+	cr.ObjectMeta.Annotations = map[string]string{"enterprise.splunk.com/deletion-protection": "true"}
+	test([]string{"enterprise.splunk.com/delete-pvc", "enterprise.splunk.com/decommission", "enterprise.splunk.com/deletion-protection"}, true)
+}
+
+func TestCheckSplunkDeletionWithDeletionProtection(t *testing.T) {
+	now := metav1.NewTime(time.Now())
+	cr := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stack1",
+			Namespace:         "test",
+			DeletionTimestamp: &now,
+			Annotations:       map[string]string{"enterprise.splunk.com/deletion-protection": "true"},
+			Finalizers:        []string{"enterprise.splunk.com/deletion-protection", "enterprise.splunk.com/delete-pvc"},
+		},
+	}
+
+	// nothing is cleaned up while deletion protection is enabled
+	c := newMockClient()
+	deleted, err := CheckSplunkDeletion(&cr, c)
+	if deleted || err != nil {
+		t.Errorf("CheckSplunkDeletion() returned %t, %v; want false, nil", deleted, err)
+	}
+	c.checkCalls(t, "TestCheckSplunkDeletionWithDeletionProtection(blocked)", map[string][]mockFuncCall{})
+	if len(cr.ObjectMeta.Finalizers) != 2 {
+		t.Errorf("CheckSplunkDeletion() finalizers = %v; want unchanged", cr.ObjectMeta.Finalizers)
+	}
+
+	// deletion continues once the annotation is removed
+	cr.ObjectMeta.Annotations = nil
+	c.listObj = &corev1.PersistentVolumeClaimList{}
+	deleted, err = CheckSplunkDeletion(&cr, c)
+	if !deleted || err != nil {
+		t.Errorf("CheckSplunkDeletion() returned %t, %v; want true, nil", deleted, err)
+	}
+	c.checkCalls(t, "TestCheckSplunkDeletionWithDeletionProtection", map[string][]mockFuncCall{
+		"Update": {
+			{metaName: "*v1beta1.IndexerCluster-test-stack1"},
+			{metaName: "*v1beta1.IndexerCluster-test-stack1"},
+		},
+		"List": {{listOpts: []client.ListOption{
+			client.InNamespace("test"),
+			client.MatchingLabels(map[string]string{"app.kubernetes.io/part-of": "splunk-stack1-indexer"}),
+		}}},
+	})
+	if len(cr.ObjectMeta.Finalizers) != 0 {
+		t.Errorf("CheckSplunkDeletion() finalizers = %v; want none", cr.ObjectMeta.Finalizers)
+	}
+}
+
+func TestCheckSplunkDeletionWithDecommission(t *testing.T) {
+	now := metav1.NewTime(time.Now())
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stack1",
+			Namespace:         "test",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"enterprise.splunk.com/delete-pvc", "enterprise.splunk.com/decommission"},
+		},
+		Spec: enterprisev1.StandaloneSpec{
+			Replicas: 1,
+			CommonSplunkSpec: enterprisev1.CommonSplunkSpec{
+				MonitoringConsoleRef: corev1.ObjectReference{Name: "mc1"},
+			},
+		},
+	}
+
+	// instances are decommissioned before persistent volume claims are deleted; removal from the monitoring
+	// console is skipped since it no longer exists
+	c := newMockClient()
+	c.listObj = &corev1.PersistentVolumeClaimList{}
+	deleted, err := CheckSplunkDeletion(&cr, c)
+	if !deleted || err != nil {
+		t.Errorf("CheckSplunkDeletion() returned %t, %v; want true, nil", deleted, err)
+	}
+	c.checkCalls(t, "TestCheckSplunkDeletionWithDecommission", map[string][]mockFuncCall{
+		"Get": {{metaName: "*v1.Secret-test-splunk-mc1-monitoring-console-secrets"}},
+		"Update": {
+			{metaName: "*v1beta1.Standalone-test-stack1"},
+			{metaName: "*v1beta1.Standalone-test-stack1"},
+		},
+		"List": {{listOpts: []client.ListOption{
+			client.InNamespace("test"),
+			client.MatchingLabels(map[string]string{"app.kubernetes.io/part-of": "splunk-stack1-standalone"}),
+		}}},
+	})
+	if len(cr.ObjectMeta.Finalizers) != 0 {
+		t.Errorf("CheckSplunkDeletion() finalizers = %v; want none", cr.ObjectMeta.Finalizers)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// verifyUpgradeHealth runs health checks once all pods of a custom resource are ready with a new image, and records the
+// image in status when they pass, so that they are only run once after each upgrade. Nothing is checked the first time
+// an image is recorded, since there was no upgrade. It returns an error describing the checks that failed, which is used
+// to report the custom resource as degraded instead of ready.
+func verifyUpgradeHealth(cr enterprisev1.MetaObject, image string, verifiedImage *string, checkHealth func() error) error {
+	if *verifiedImage == image {
+		return nil
+	}
+	if *verifiedImage != "" {
+		if err := checkHealth(); err != nil {
+			return fmt.Errorf("Health checks failed after upgrade to %s: %v", image, err)
+		}
+		recordEvent(cr, corev1.EventTypeNormal, "UpgradeVerified", "Health checks passed after upgrade to %s", image)
+	}
+	*verifiedImage = image
+	return nil
+}
+
+// checkKVStoreHealth returns an error if the KV store is not ready on any of the hosts, unless it has been disabled
+func checkKVStoreHealth(hosts []string, secrets *corev1.Secret, newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient) error {
+	for _, host := range hosts {
+		c := newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", enterprise.GetAppliedAdminPassword(secrets))
+		status, err := c.GetKVStoreStatus()
+		if err != nil {
+			return fmt.Errorf("unable to get KV store status of %s: %v", host, err)
+		}
+		if status != "ready" && status != "disabled" {
+			return fmt.Errorf("KV store is not ready on %s (status=%s)", host, status)
+		}
+	}
+	return nil
+}
+
+// checkClusterMasterHealth returns an error if the cluster master reports that any peers are down, that the replication or
+// search factors are not met, or that some data is not searchable
+func checkClusterMasterHealth(c *splclient.SplunkClient) error {
+	health, err := c.GetClusterMasterHealth()
+	if err != nil {
+		return fmt.Errorf("unable to get indexer cluster health: %v", err)
+	}
+
+	var failed []string
+	if health.AllPeersAreUp != "1" {
+		failed = append(failed, "not all peers are up")
+	}
+	if health.ReplicationFactorMet != "1" {
+		failed = append(failed, "replication factor is not met")
+	}
+	if health.SearchFactorMet != "1" {
+		failed = append(failed, "search factor is not met")
+	}
+	if health.AllDataIsSearchable != "1" {
+		failed = append(failed, "not all data is searchable")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestVerifyUpgradeHealth(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	checks := 0
+	var checkErr error
+	checkHealth := func() error {
+		checks++
+		return checkErr
+	}
+
+	test := func(testMethod, image string, wantErr bool, wantChecks int, wantVerifiedImage string) {
+		err := verifyUpgradeHealth(&cr, image, &cr.Status.VerifiedImage, checkHealth)
+		if wantErr && err == nil {
+			t.Errorf("%s returned nil; want error", testMethod)
+		} else if !wantErr && err != nil {
+			t.Errorf("%s returned %v; want nil", testMethod, err)
+		}
+		if checks != wantChecks {
+			t.Errorf("%s ran %d health checks; want %d", testMethod, checks, wantChecks)
+		}
+		if cr.Status.VerifiedImage != wantVerifiedImage {
+			t.Errorf("%s status.verifiedImage = %s; want %s", testMethod, cr.Status.VerifiedImage, wantVerifiedImage)
+		}
+	}
+
+	// nothing is checked for the first image, or until the image is changed
+	test("TestVerifyUpgradeHealth(first)", "splunk/splunk:8.0.5", false, 0, "splunk/splunk:8.0.5")
+	test("TestVerifyUpgradeHealth(no-change)", "splunk/splunk:8.0.5", false, 0, "splunk/splunk:8.0.5")
+
+	// failed checks are retried until they pass
+	checkErr = fmt.Errorf("KV store is not ready")
+	test("TestVerifyUpgradeHealth(failed)", "splunk/splunk:8.1.0", true, 1, "splunk/splunk:8.0.5")
+	test("TestVerifyUpgradeHealth(failed-again)", "splunk/splunk:8.1.0", true, 2, "splunk/splunk:8.0.5")
+	checkErr = nil
+	test("TestVerifyUpgradeHealth(passed)", "splunk/splunk:8.1.0", false, 3, "splunk/splunk:8.1.0")
+	test("TestVerifyUpgradeHealth(verified)", "splunk/splunk:8.1.0", false, 3, "splunk/splunk:8.1.0")
+}
+
+func TestCheckKVStoreHealth(t *testing.T) {
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	newSplunkClient := func(managementURI, username, password string) *splclient.SplunkClient {
+		c := splclient.NewSplunkClient(managementURI, username, password)
+		c.Client = mockSplunkClient
+		return c
+	}
+	hosts := []string{
+		"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local",
+		"splunk-stack1-standalone-1.splunk-stack1-standalone-headless.test.svc.cluster.local",
+	}
+	kvstoreStatus := func(host, status string) spltest.MockHTTPHandler {
+		return spltest.MockHTTPHandler{Method: "GET", URL: fmt.Sprintf("https://%s:8089/services/kvstore/status?count=0&output_mode=json", host), Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"content":{"current":{"status":"%s"}}}]}`, status)}
+	}
+
+	test := func(testMethod string, wantErr bool) {
+		err := checkKVStoreHealth(hosts, secrets, newSplunkClient)
+		if wantErr && err == nil {
+			t.Errorf("%s returned nil; want error", testMethod)
+		} else if !wantErr && err != nil {
+			t.Errorf("%s returned %v; want nil", testMethod, err)
+		}
+		mockSplunkClient.CheckRequests(t, testMethod)
+	}
+
+	mockSplunkClient.AddHandlers(kvstoreStatus(hosts[0], "ready"), kvstoreStatus(hosts[1], "disabled"))
+	test("TestCheckKVStoreHealth(ready)", false)
+
+	// hosts after the first one that is not ready are not checked
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(kvstoreStatus(hosts[0], "starting"))
+	test("TestCheckKVStoreHealth(starting)", true)
+}
+
+func TestCheckClusterMasterHealth(t *testing.T) {
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	c := splclient.NewSplunkClient("https://splunk-cm-cluster-master-service:8089", "admin", "p@ssw0rd")
+	c.Client = mockSplunkClient
+	healthURL := "https://splunk-cm-cluster-master-service:8089/services/cluster/master/health?count=0&output_mode=json"
+
+	test := func(testMethod, health string, wantErr string) {
+		mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: healthURL, Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"content":{%s}}]}`, health)})
+		err := checkClusterMasterHealth(c)
+		if wantErr == "" && err != nil {
+			t.Errorf("%s returned %v; want nil", testMethod, err)
+		} else if wantErr != "" && (err == nil || err.Error() != wantErr) {
+			t.Errorf("%s returned %v; want %s", testMethod, err, wantErr)
+		}
+		mockSplunkClient.CheckRequests(t, testMethod)
+	}
+
+	test("TestCheckClusterMasterHealth(healthy)", `"all_data_is_searchable":"1","all_peers_are_up":"1","replication_factor_met":"1","search_factor_met":"1"`, "")
+	test("TestCheckClusterMasterHealth(unhealthy)", `"all_data_is_searchable":"0","all_peers_are_up":"1","replication_factor_met":"0","search_factor_met":"1"`,
+		"replication factor is not met, not all data is searchable")
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+const (
+	// finalizer used to remove a HEC token from Splunk Enterprise instances when its HecToken is deleted
+	splunkFinalizerDeleteHecToken = "enterprise.splunk.com/delete-hec-token"
+
+	// interval used to check that a HEC token is configured on new instances, such as indexers added by scaling up
+	hecTokenResyncInterval = time.Minute * 5
+)
+
+// ApplyHecToken reconciles the state of an HTTP Event Collector (HEC) token configured on a Splunk Enterprise custom resource.
+func ApplyHecToken(client ControllerClient, cr *enterprisev1.HecToken) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyHecToken(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplyHecToken").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateHecTokenSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	mgr := HecTokenManager{log: scopedLog, cr: cr, newSplunkClient: getSplunkClientFactory(client)}
+
+	// remove the token from Splunk Enterprise instances if deletion was requested
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		if hasFinalizer(cr.GetFinalizers(), splunkFinalizerDeleteHecToken) {
+			if err = mgr.Delete(client); err != nil {
+				return result, err
+			}
+			if err = RemoveSplunkFinalizer(cr, client, splunkFinalizerDeleteHecToken); err != nil {
+				return result, err
+			}
+		}
+		result.Requeue = false
+		return result, nil
+	}
+
+	err = addSplunkFinalizer(cr, client, splunkFinalizerDeleteHecToken)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		err = client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			scopedLog.Error(err, "Status update failed")
+		}
+	}()
+
+	phase, err := mgr.Apply(client, time.Now())
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+
+	// once the token is configured, periodically check that it is configured on new instances and whether rotation is due
+	if phase == enterprisev1.PhaseReady {
+		result.RequeueAfter = hecTokenResyncInterval
+	}
+	return result, nil
+}
+
+// HecTokenManager is used to configure an HTTP Event Collector (HEC) token on the instances of a Splunk Enterprise custom resource
+type HecTokenManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.HecToken
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply creates or updates the Kubernetes Secret used by applications to send events with the token, rotating its value if
+// due, and configures the token on each instance of the target once it is ready. Instances configured with a different token
+// value (after it has been rotated or changed manually in the Secret) have their token recreated with the new value.
+func (mgr *HecTokenManager) Apply(c ControllerClient, now time.Time) (enterprisev1.ResourcePhase, error) {
+	secret, err := mgr.applySecret(c, now)
+	if err != nil {
+		return enterprisev1.PhaseError, err
+	}
+	mgr.cr.Status.SecretName = secret.GetName()
+
+	hosts, targetPhase, err := mgr.getTargetHosts(c)
+	if err != nil {
+		mgr.log.Info("Waiting for HEC token target to be created", "target", mgr.cr.Spec.TargetRef.Name)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "TargetNotFound", fmt.Sprintf("waiting for %s %s to be created", mgr.cr.Spec.TargetRef.Kind, mgr.cr.Spec.TargetRef.Name)
+		return enterprisev1.PhasePending, nil
+	}
+	if targetPhase != enterprisev1.PhaseReady {
+		mgr.log.Info("Waiting for HEC token target to become ready", "target", mgr.cr.Spec.TargetRef.Name, "phase", targetPhase)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "TargetNotReady", getNotReadyMessage(mgr.cr.Spec.TargetRef.Kind+" "+mgr.cr.Spec.TargetRef.Name, "")
+		return enterprisev1.PhasePending, nil
+	}
+
+	password, err := mgr.getAdminPassword(c)
+	if err != nil {
+		return enterprisev1.PhaseError, err
+	}
+
+	name := enterprise.GetHecTokenName(mgr.cr)
+	for _, host := range hosts {
+		err = mgr.applyHTTPInput(mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", password), host, name, string(secret.Data["hec_token"]))
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
+	mgr.cr.Status.TokenName = name
+	mgr.cr.Status.Instances = int32(len(hosts))
+	return enterprisev1.PhaseReady, nil
+}
+
+// Delete removes the token from each instance of the target. Nothing is done if the target no longer exists, or is being deleted.
+func (mgr *HecTokenManager) Delete(c ControllerClient) error {
+	name := mgr.cr.Status.TokenName
+	if name == "" {
+		return nil
+	}
+
+	hosts, _, err := mgr.getTargetHosts(c)
+	if err != nil {
+		mgr.log.Info("Skipping HEC token removal for missing target", "target", mgr.cr.Spec.TargetRef.Name)
+		return nil
+	}
+	if hosts == nil {
+		return nil
+	}
+	password, err := mgr.getAdminPassword(c)
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		splunkClient := mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", password)
+		inputs, err := splunkClient.GetHTTPInputs()
+		if err != nil {
+			return fmt.Errorf("Unable to get HEC tokens for %s: %v", host, err)
+		}
+		if _, ok := inputs[name]; !ok {
+			continue
+		}
+		mgr.log.Info("Deleting HEC token", "host", host, "token", name)
+		if err = splunkClient.DeleteHTTPInput(name); err != nil {
+			return fmt.Errorf("Unable to delete HEC token %s for %s: %v", name, host, err)
+		}
+	}
+	return nil
+}
+
+// applySecret for HecTokenManager creates the Kubernetes Secret containing the token value and HEC URL if missing, or updates
+// it if the token is due to be rotated or the URL has changed. It returns the current Secret.
+func (mgr *HecTokenManager) applySecret(c ControllerClient, now time.Time) (*corev1.Secret, error) {
+	revised := enterprise.GetHecTokenSecret(mgr.cr)
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return revised, CreateResource(c, revised)
+	}
+
+	changed := false
+	if current.Data == nil {
+		current.Data = make(map[string][]byte)
+	}
+	if len(current.Data["hec_token"]) == 0 || enterprise.IsSecretsRotationDue(&current, mgr.cr.Spec.RotationInterval, now) {
+		mgr.log.Info("Generating new HEC token value", "secret", current.GetName())
+		enterprise.RotateHecToken(&current, now)
+		changed = true
+	}
+	if string(current.Data["hec_url"]) != string(revised.Data["hec_url"]) {
+		current.Data["hec_url"] = revised.Data["hec_url"]
+		changed = true
+	}
+	if changed {
+		return &current, UpdateResource(c, &current)
+	}
+	return &current, nil
+}
+
+// getTargetHosts for HecTokenManager returns the FQDNs of the instances of the target that receive events, and the phase of the
+// target. No hosts are returned if the target is being deleted, and error is returned if the target does not exist.
+func (mgr *HecTokenManager) getTargetHosts(c ControllerClient) ([]string, enterprisev1.ResourcePhase, error) {
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.cr.Spec.TargetRef.Name}
+
+	if mgr.cr.Spec.TargetRef.Kind == "IndexerCluster" {
+		var idxc enterprisev1.IndexerCluster
+		err := c.Get(context.TODO(), namespacedName, &idxc)
+		if err != nil {
+			return nil, "", err
+		}
+		if idxc.ObjectMeta.DeletionTimestamp != nil {
+			return nil, idxc.Status.Phase, nil
+		}
+		if err = enterprise.ValidateIndexerClusterSpec(&idxc.Spec); err != nil {
+			return nil, idxc.Status.Phase, err
+		}
+		return getIndexerClusterPeerHosts(&idxc), idxc.Status.Phase, nil
+	}
+
+	var standalone enterprisev1.Standalone
+	err := c.Get(context.TODO(), namespacedName, &standalone)
+	if err != nil {
+		return nil, "", err
+	}
+	if standalone.ObjectMeta.DeletionTimestamp != nil {
+		return nil, standalone.Status.Phase, nil
+	}
+	if err = enterprise.ValidateStandaloneSpec(&standalone.Spec); err != nil {
+		return nil, standalone.Status.Phase, err
+	}
+	urls := enterprise.GetSplunkStatefulsetUrls(mgr.cr.GetNamespace(), enterprise.SplunkStandalone, standalone.GetIdentifier(), standalone.Spec.Replicas, false)
+	return strings.Split(urls, ","), standalone.Status.Phase, nil
+}
+
+// getAdminPassword for HecTokenManager returns the admin password used by instances of the target
+func (mgr *HecTokenManager) getAdminPassword(c ControllerClient) (string, error) {
+	instanceType := enterprise.GetHecTokenInstanceType(mgr.cr.Spec.TargetRef.Kind)
+	secretsName := enterprise.GetSplunkSecretsName(mgr.cr.Spec.TargetRef.Name, instanceType)
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: secretsName}
+	var secrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+	return enterprise.GetAppliedAdminPassword(&secrets), nil
+}
+
+// applyHTTPInput for HecTokenManager creates or updates the token on a Splunk Enterprise instance. The token is recreated if
+// its value has changed, since splunkd does not allow the value of an existing token to be updated. A token previously
+// configured with a different name is removed.
+func (mgr *HecTokenManager) applyHTTPInput(splunkClient *splclient.SplunkClient, host, name, token string) error {
+	inputs, err := splunkClient.GetHTTPInputs()
+	if err != nil {
+		return fmt.Errorf("Unable to get HEC tokens for %s: %v", host, err)
+	}
+
+	if old := mgr.cr.Status.TokenName; old != "" && old != name {
+		if _, ok := inputs[old]; ok {
+			mgr.log.Info("Deleting renamed HEC token", "host", host, "token", old)
+			if err = splunkClient.DeleteHTTPInput(old); err != nil {
+				return fmt.Errorf("Unable to delete HEC token %s for %s: %v", old, host, err)
+			}
+		}
+	}
+
+	spec := &mgr.cr.Spec
+	current, ok := inputs[name]
+	if ok && current.Token != token {
+		mgr.log.Info("Deleting HEC token to change its value", "host", host, "token", name)
+		if err = splunkClient.DeleteHTTPInput(name); err != nil {
+			return fmt.Errorf("Unable to delete HEC token %s for %s: %v", name, host, err)
+		}
+		ok = false
+	}
+	if !ok {
+		mgr.log.Info("Creating HEC token", "host", host, "token", name)
+		err = splunkClient.CreateHTTPInput(name, token, spec.Index, spec.Sourcetype, spec.Indexes)
+	} else if !isHTTPInputCurrent(spec, &current) {
+		mgr.log.Info("Updating HEC token", "host", host, "token", name)
+		err = splunkClient.UpdateHTTPInput(name, spec.Index, spec.Sourcetype, spec.Indexes)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to configure HEC token %s for %s: %v", name, host, err)
+	}
+	return nil
+}
+
+// isHTTPInputCurrent returns true if the settings of a token on a Splunk Enterprise instance match its spec
+func isHTTPInputCurrent(spec *enterprisev1.HecTokenSpec, current *splclient.HTTPInputInfo) bool {
+	if spec.Index != current.Index || spec.Sourcetype != current.Sourcetype {
+		return false
+	}
+	want := append([]string{}, spec.Indexes...)
+	got := append([]string{}, current.Indexes...)
+	sort.Strings(want)
+	sort.Strings(got)
+	return strings.Join(want, ",") == strings.Join(got, ",")
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestHecTokenManagerApply(t *testing.T) {
+	cr := enterprisev1.HecToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.HecTokenSpec{
+			TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"},
+			Index:     "main",
+		},
+	}
+	c := newMockClient()
+
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := &HecTokenManager{
+		log: log.WithName("TestHecTokenManagerApply"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	now := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	checkApply := func(method string, wantPhase enterprisev1.ResourcePhase) {
+		phase, err := mgr.Apply(c, now)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", method, err)
+		}
+		if phase != wantPhase {
+			t.Errorf("%s phase = %s; want %s", method, phase, wantPhase)
+		}
+	}
+	secretCall := mockFuncCall{metaName: "*v1.Secret-test-splunk-app1-hec-token"}
+	targetCall := mockFuncCall{metaName: "*v1beta1.Standalone-test-stack1"}
+	secretsCall := mockFuncCall{metaName: "*v1.Secret-test-" + enterprise.GetSplunkSecretsName("stack1", enterprise.SplunkStandalone)}
+	inputsURL := "https://splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local:8089/services/data/inputs/http"
+
+	// the token Secret is created, while waiting for the target to be created
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	checkApply("HecTokenManager.Apply(missing)", enterprisev1.PhasePending)
+	c.checkCalls(t, "HecTokenManager.Apply(missing)", map[string][]mockFuncCall{
+		"Get":    {secretCall, targetCall},
+		"Create": {secretCall},
+	})
+	if cr.Status.SecretName != "splunk-app1-hec-token" {
+		t.Errorf("HecTokenManager.Apply(missing) secretName = %s; want %s", cr.Status.SecretName, "splunk-app1-hec-token")
+	}
+	secret := c.state[secretCall.metaName].(*corev1.Secret)
+	token := string(secret.Data["hec_token"])
+
+	// wait for the target to become ready
+	c.resetCalls()
+	target := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Status: enterprisev1.StandaloneStatus{Phase: enterprisev1.PhasePending},
+	}
+	c.state[getStateKey(&target)] = &target
+	c.state[secretsCall.metaName] = enterprise.GetSplunkSecrets(&target, enterprise.SplunkStandalone, nil, nil)
+	checkApply("HecTokenManager.Apply(pending)", enterprisev1.PhasePending)
+	c.checkCalls(t, "HecTokenManager.Apply(pending)", map[string][]mockFuncCall{"Get": {secretCall, targetCall}})
+
+	// the token is created once the target is ready
+	c.resetCalls()
+	target.Status.Phase = enterprisev1.PhaseReady
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 200, Body: `{"entry":[]}`},
+		spltest.MockHTTPHandler{Method: "POST", URL: inputsURL, Status: 201},
+	)
+	checkApply("HecTokenManager.Apply(create)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Apply(create)")
+	c.checkCalls(t, "HecTokenManager.Apply(create)", map[string][]mockFuncCall{"Get": {secretCall, targetCall, secretsCall}})
+	if cr.Status.TokenName != "app1" || cr.Status.Instances != 1 {
+		t.Errorf("HecTokenManager.Apply(create) status = %v; want tokenName=app1 instances=1", cr.Status)
+	}
+
+	// nothing is changed once the token is current
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"name":"http://app1","content":{"token":"%s","index":"main"}}]}`, token)},
+	)
+	checkApply("HecTokenManager.Apply(current)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Apply(current)")
+
+	// settings are updated if they have changed
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"name":"http://app1","content":{"token":"%s","index":"app1"}}]}`, token)},
+		spltest.MockHTTPHandler{Method: "POST", URL: inputsURL + "/app1", Status: 200},
+	)
+	checkApply("HecTokenManager.Apply(update)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Apply(update)")
+
+	// the token is recreated once it is rotated
+	c.resetCalls()
+	cr.Spec.RotationInterval = "720h"
+	secret.ObjectMeta.Annotations = map[string]string{enterprise.SecretsRotatedAnnotation: now.Add(-time.Hour * 721).Format(time.RFC3339)}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"name":"http://app1","content":{"token":"%s","index":"main"}}]}`, token)},
+		spltest.MockHTTPHandler{Method: "DELETE", URL: inputsURL + "/app1", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: inputsURL, Status: 201},
+	)
+	checkApply("HecTokenManager.Apply(rotate)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Apply(rotate)")
+	c.checkCalls(t, "HecTokenManager.Apply(rotate)", map[string][]mockFuncCall{
+		"Get":    {secretCall, targetCall, secretsCall},
+		"Update": {secretCall},
+	})
+	secret = c.state[secretCall.metaName].(*corev1.Secret)
+	if string(secret.Data["hec_token"]) == token {
+		t.Errorf("HecTokenManager.Apply(rotate) did not change hec_token")
+	}
+
+	// tokens that are renamed are removed
+	cr.Spec.TokenName = "app1-events"
+	token = string(secret.Data["hec_token"])
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"name":"http://app1","content":{"token":"%s","index":"main"}}]}`, token)},
+		spltest.MockHTTPHandler{Method: "DELETE", URL: inputsURL + "/app1", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: inputsURL, Status: 201},
+	)
+	checkApply("HecTokenManager.Apply(rename)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Apply(rename)")
+	if cr.Status.TokenName != "app1-events" {
+		t.Errorf("HecTokenManager.Apply(rename) tokenName = %s; want %s", cr.Status.TokenName, "app1-events")
+	}
+
+	// errors from splunkd are returned
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: inputsURL + "?count=0&output_mode=json", Status: 503})
+	if phase, err := mgr.Apply(c, now); err == nil || phase != enterprisev1.PhaseError {
+		t.Errorf("HecTokenManager.Apply() = %s, %v; want %s, error", phase, err, enterprisev1.PhaseError)
+	}
+}
+
+func TestHecTokenManagerDelete(t *testing.T) {
+	cr := enterprisev1.HecToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.HecTokenSpec{
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"},
+		},
+		Status: enterprisev1.HecTokenStatus{TokenName: "app1"},
+	}
+	c := newMockClient()
+
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := &HecTokenManager{
+		log: log.WithName("TestHecTokenManagerDelete"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// nothing to do if the target no longer exists
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.Delete(c); err != nil {
+		t.Errorf("HecTokenManager.Delete(missing) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Delete(missing)")
+
+	// the token is removed from each indexer that has it
+	target := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.IndexerClusterSpec{Replicas: 2},
+	}
+	c.state[getStateKey(&target)] = &target
+	secrets := enterprise.GetSplunkSecrets(&target, enterprise.SplunkIndexer, nil, nil)
+	c.state[getStateKey(secrets)] = secrets
+	inputsURL := "https://splunk-stack1-indexer-%d.splunk-stack1-indexer-headless.test.svc.cluster.local:8089/services/data/inputs/http"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: fmt.Sprintf(inputsURL, 0) + "?count=0&output_mode=json", Status: 200,
+			Body: `{"entry":[{"name":"http://app1","content":{"token":"01234567-89AB-CDEF-0123-456789ABCDEF"}}]}`},
+		spltest.MockHTTPHandler{Method: "DELETE", URL: fmt.Sprintf(inputsURL, 0) + "/app1", Status: 200},
+		spltest.MockHTTPHandler{Method: "GET", URL: fmt.Sprintf(inputsURL, 1) + "?count=0&output_mode=json", Status: 200, Body: `{"entry":[]}`},
+	)
+	if err := mgr.Delete(c); err != nil {
+		t.Errorf("HecTokenManager.Delete() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Delete()")
+
+	// nothing to do if the target is being deleted
+	now := metav1.Now()
+	target.ObjectMeta.DeletionTimestamp = &now
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.Delete(c); err != nil {
+		t.Errorf("HecTokenManager.Delete(deleting) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "HecTokenManager.Delete(deleting)")
+}
+
+func TestApplyHecToken(t *testing.T) {
+	cr := enterprisev1.HecToken{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "HecToken",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "app1",
+			Namespace:  "test",
+			Generation: 1,
+		},
+		Spec: enterprisev1.HecTokenSpec{
+			TargetRef: corev1.ObjectReference{Kind: "Standalone", Name: "stack1"},
+		},
+	}
+	c := newMockClient()
+
+	// a finalizer is added, and the token is pending until its target exists
+	result, err := ApplyHecToken(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyHecToken() returned %v; want nil", err)
+	}
+	if !result.Requeue || cr.Status.Phase != enterprisev1.PhasePending {
+		t.Errorf("ApplyHecToken() = %v, phase %s; want requeue, %s", result, cr.Status.Phase, enterprisev1.PhasePending)
+	}
+	if !hasFinalizer(cr.GetFinalizers(), splunkFinalizerDeleteHecToken) {
+		t.Errorf("ApplyHecToken() finalizers = %v; want %s", cr.GetFinalizers(), splunkFinalizerDeleteHecToken)
+	}
+	if cr.Status.ObservedGeneration != 1 {
+		t.Errorf("ApplyHecToken() observedGeneration = %d; want 1", cr.Status.ObservedGeneration)
+	}
+
+	// invalid specs are rejected
+	cr.Spec.TargetRef.Kind = "Spark"
+	if _, err = ApplyHecToken(c, &cr); err == nil {
+		t.Errorf("ApplyHecToken() returned nil; want error for invalid targetRef kind")
+	}
+}
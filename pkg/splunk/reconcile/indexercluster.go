@@ -17,14 +17,19 @@ package reconcile
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/go-logr/logr"
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
@@ -33,10 +38,23 @@ import (
 // ApplyIndexerCluster reconciles the state of a Splunk Enterprise indexer cluster.
 func ApplyIndexerCluster(client ControllerClient, cr *enterprisev1.IndexerCluster) (reconcile.Result, error) {
 
-	// unless modified, reconcile for this object will be requeued after 5 seconds
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyIndexerCluster(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
 	result := reconcile.Result{
 		Requeue:      true,
-		RequeueAfter: time.Second * 5,
+		RequeueAfter: getRequeueInterval(cr),
 	}
 	scopedLog := log.WithName("ApplyIndexerCluster").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
 
@@ -46,8 +64,13 @@ func ApplyIndexerCluster(client ControllerClient, cr *enterprisev1.IndexerCluste
 		return result, err
 	}
 
+	// scale the number of peers according to the scaling schedule, if configured
+	nextScheduledScaling := ApplyScalingSchedule(cr, cr.Spec.ScalingSchedule, &cr.Spec.Replicas, &cr.Status.ScalingSchedule, time.Now())
+
 	// updates status after function completes
+	oldPhase := cr.Status.Phase
 	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
 	cr.Status.ClusterMasterPhase = enterprisev1.PhaseError
 	cr.Status.Replicas = cr.Spec.Replicas
 	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-indexer", cr.GetIdentifier())
@@ -55,6 +78,13 @@ func ApplyIndexerCluster(client ControllerClient, cr *enterprisev1.IndexerCluste
 		cr.Status.Peers = []enterprisev1.IndexerClusterMemberStatus{}
 	}
 	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
 		err = client.Status().Update(context.TODO(), cr)
 		if err != nil {
 			scopedLog.Error(err, "Status update failed")
@@ -63,8 +93,9 @@ func ApplyIndexerCluster(client ControllerClient, cr *enterprisev1.IndexerCluste
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
-		terminating, err := CheckSplunkDeletion(cr, client)
-		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
 			cr.Status.Phase = enterprisev1.PhaseTerminating
 			cr.Status.ClusterMasterPhase = enterprisev1.PhaseTerminating
 		} else {
@@ -73,67 +104,583 @@ func ApplyIndexerCluster(client ControllerClient, cr *enterprisev1.IndexerCluste
 		return result, err
 	}
 
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// wait for persistent volume claims to be restored from a backup, if requested
+	pending, err := isRestorePending(client, cr)
+	if pending {
+		cr.Status.Phase = enterprisev1.PhasePending
+		cr.Status.ClusterMasterPhase = enterprisev1.PhasePending
+		cr.Status.Reason, cr.Status.Message = getRestorePendingReason(cr)
+		return result, err
+	}
+
+	// upgrade to the image requested using the splunk-image annotation, once linked resources have been upgraded in order
+	upgradePending, err := ApplyUpgradeImage(client, cr, &cr.Spec.CommonSplunkSpec, getIndexerClusterUpgradeStatefulSetName(cr))
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkIndexer)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update general config resources
 	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkIndexer)
 	if err != nil {
 		return result, err
 	}
 
-	// create or update a headless service for indexer cluster
-	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkIndexer, true))
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
 	if err != nil {
 		return result, err
 	}
 
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkIndexer, getIndexerClusterDNSNames(cr))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update smartstore configuration (this is pushed to peers by the cluster master)
+	smartstore, err := ApplySmartStoreConfig(client, cr, &cr.Spec.SmartStore, enterprise.SplunkIndexer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured (these are pushed to peers by the cluster master)
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update app repository configuration (apps are pushed to peers by the cluster master)
+	appManager := AppRepoManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, status: &cr.Status.AppRepo, newS3Client: splclient.NewS3Client, newAzureClient: splclient.NewAzureBlobClient}
+	apps, err := appManager.Apply(client, enterprise.SplunkClusterMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a headless service for indexer cluster
+	if len(cr.Spec.Sites) == 0 {
+		err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkIndexer, true))
+		if err != nil {
+			return result, err
+		}
+	}
+
 	// create or update a regular service for indexer cluster (ingestion)
 	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkIndexer, false))
 	if err != nil {
 		return result, err
 	}
 
-	// create or update a regular service for the cluster master
-	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkClusterMaster, false))
+	// create or update a regular service for the cluster master, unless a ClusterMaster or external cluster master is used
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkClusterMaster, false))
+		if err != nil {
+			return result, err
+		}
+	} else {
+		// remove the cluster master this indexer cluster created before it switched to a ClusterMaster or external one
+		err = deleteIndexerClusterMaster(client, cr)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkIndexer)
 	if err != nil {
 		return result, err
 	}
 
-	// create or update statefulset for the cluster master
-	statefulSet, err := enterprise.GetClusterMasterStatefulSet(cr)
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkIndexer)
 	if err != nil {
 		return result, err
 	}
-	clusterMasterManager := DefaultStatefulSetPodManager{}
-	phase, err := clusterMasterManager.Update(client, statefulSet, 1)
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkIndexer, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkIndexer, false))
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		services = append(services, enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkClusterMaster, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkClusterMaster, false))...)
+	}
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
 	if err != nil {
 		return result, err
 	}
-	cr.Status.ClusterMasterPhase = phase
 
-	// create or update statefulset for the indexers
-	statefulSet, err = enterprise.GetIndexerStatefulSet(cr)
+	// create or update ServiceMonitors for Prometheus, if enabled
+	monitoredTypes := []enterprise.InstanceType{enterprise.SplunkIndexer}
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		monitoredTypes = append(monitoredTypes, enterprise.SplunkClusterMaster)
+	}
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, monitoredTypes...)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update external services and configuration for indexer discovery, if enabled
+	discovery, err := ApplyIndexerDiscoveryConfig(client, cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkIndexer, &cr.Status.IndexerDiscovery)
+	if err != nil {
+		return result, err
+	}
+	var clusterMasterDiscovery *corev1.Secret
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		clusterMasterDiscovery, err = ApplyIndexerDiscoveryConfig(client, cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkClusterMaster, &cr.Status.IndexerDiscovery)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// create or update external services for each indexer cluster peer, if enabled
+	err = ApplyIndexerPodServices(client, cr)
 	if err != nil {
 		return result, err
 	}
-	mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: splclient.NewSplunkClient}
-	phase, err = mgr.Update(client, statefulSet, cr.Spec.Replicas)
+
+	// create or update the cluster master, or use the status of a referenced ClusterMaster or external cluster master
+	var clusterMasterSecrets *corev1.Secret
+	switch {
+	case cr.Spec.ClusterMasterRef.Name != "":
+		var clusterMaster *enterprisev1.ClusterMaster
+		clusterMaster, clusterMasterSecrets, err = getReferencedClusterMaster(client, cr)
+		if err != nil {
+			cr.Status.ClusterMasterPhase = enterprisev1.PhasePending
+			return result, err
+		}
+		cr.Status.ClusterMasterPhase = clusterMaster.Status.Phase
+		if clusterMaster.Status.Phase != enterprisev1.PhaseReady {
+			cr.Status.Reason, cr.Status.Message = "ClusterMasterNotReady", getNotReadyMessage("ClusterMaster "+clusterMaster.GetName(), clusterMaster.Status.Message)
+		}
+
+		// indexer discovery is enabled on the referenced ClusterMaster
+		cr.Status.IndexerDiscovery.MasterURI = clusterMaster.Status.IndexerDiscovery.MasterURI
+	case enterprise.IsExternalClusterMasterConfigured(&cr.Spec.ExternalClusterMaster):
+		clusterMasterSecrets, err = getExternalClusterMasterSecret(client, cr)
+		if err != nil {
+			cr.Status.ClusterMasterPhase = enterprisev1.PhasePending
+			return result, err
+		}
+
+		// the external cluster master is reached using its REST API when the status of peers is updated
+		cr.Status.ClusterMasterPhase = enterprisev1.PhaseReady
+		cr.Status.IndexerDiscovery.MasterURI = ""
+	default:
+		err = applyIndexerClusterMaster(client, cr, secrets, tls, defaults, smartstore, clusterMasterDiscovery, confFiles, apps, scopedLog)
+		if err != nil {
+			return result, err
+		}
+
+		// indexer cluster peers are upgraded after their cluster master
+		if enterprise.GetRequestedImage(cr.GetObjectMeta()) == cr.Spec.Image && cr.Status.ClusterMasterPhase != enterprisev1.PhaseReady {
+			upgradePending = true
+			err = keepCurrentImage(client, cr.GetNamespace(), getIndexerStatefulSetName(cr), &cr.Spec.CommonSplunkSpec)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	if !enterprise.HasOwnClusterMaster(&cr.Spec) {
+		// smartstore configuration, app packages, defaults and conf files are pushed by the cluster master
+		cr.Status.SmartStoreChecksum = ""
+		cr.Status.Apps = nil
+		cr.Status.Bundle.DefaultsChecksum = ""
+		cr.Status.Bundle.ConfFilesChecksum = ""
+	}
+
+	// create or update statefulset for the indexers
+	var phase enterprisev1.ResourcePhase
+	if len(cr.Spec.Sites) > 0 {
+		phase, err = applyIndexerClusterSites(client, cr, secrets, clusterMasterSecrets, tls, discovery, defaults, scopedLog)
+	} else {
+		cr.Status.Sites = nil
+		var statefulSet *appsv1.StatefulSet
+		statefulSet, err = enterprise.GetIndexerStatefulSet(cr)
+		if err != nil {
+			return result, err
+		}
+		enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+		enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+		enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+		enterprise.SetIndexerDiscoveryChecksum(&statefulSet.Spec.Template, discovery)
+
+		// limit the number of pods that may be evicted at the same time
+		err = ApplyPodDisruptionBudget(client, enterprise.GetSplunkPodDisruptionBudget(statefulSet, cr.Spec.MaxUnavailable))
+		if err != nil {
+			return result, err
+		}
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		phase, err = mgr.Update(client, statefulSet, cr.Spec.Replicas)
+		if err == nil && cr.Status.Reason == "" {
+			cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+		}
+	}
 	if err != nil {
 		return result, err
 	}
 	cr.Status.Phase = phase
+	if cr.Status.Reason == "" && phase != enterprisev1.PhaseReady && (!cr.Status.Initialized || !cr.Status.IndexingReady || !cr.Status.ServiceReady) {
+		cr.Status.Reason, cr.Status.Message = "IndexerClusterNotReady", "waiting for the indexer cluster to be initialized and ready for indexing"
+	}
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if (cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.ClusterMasterPhase == enterprisev1.PhaseReady) || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, getIndexerClusterHosts(cr))
+		if err != nil {
+			return result, err
+		}
+	}
 
-	// no need to requeue if everything is ready
+	// take the cluster master out of maintenance mode once all indexer peers have been updated
+	if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.OperatorMaintenanceMode {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.setMaintenanceMode(false)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// restart indexer cluster peers requested using the restarted-at annotation; unless searchable rolling restarts are
+	// enabled, this is handled by recycling peer pods like any other update
+	if !enterprise.IsSearchableRollingRestart(&cr.Spec) {
+		cr.Status.RollingRestartRequest = enterprise.GetRestartRequest(cr)
+	} else if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.ClusterMasterPhase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.rollingRestartPeers()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// remove excess bucket copies from the remaining peers after scaling down, unless disabled
+	if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.ClusterMasterPhase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.removeExcessBuckets()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// verify that the cluster is healthy after an upgrade, before reporting it as ready
+	if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.ClusterMasterPhase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = verifyUpgradeHealth(cr, enterprise.GetSplunkImage(cr.Spec.Image), &cr.Status.VerifiedImage, func() error {
+			return checkClusterMasterHealth(mgr.getClusterMasterClient())
+		})
+		if err != nil {
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+	}
+
+	// register cluster master and indexers with the monitoring console, once they are ready
 	if cr.Status.Phase == enterprisev1.PhaseReady {
+		mcManager := MonitoringConsolePeerManager{log: scopedLog, cr: cr, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mcManager.RegisterPeers(client, getIndexerClusterHosts(cr))
+		if err != nil {
+			return result, err
+		}
+
+		// collect load metrics from indexer peers, if enabled
+		collectLoadMetrics(&cr.Spec.LoadMetrics, enterprise.SplunkIndexer, getIndexerClusterPeerHosts(cr), secrets, getSplunkClientFactory(client), &cr.Status.LoadMetrics)
+	}
+
+	// remediate indexer cluster peers that remain in automatic detention, if configured
+	if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.ClusterMasterPhase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.remediateDetention(time.Now())
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, the latest cluster bundle has been pushed to indexer cluster peers, no
+	// rolling restart or excess bucket cleanup is pending, and no upgrades are waiting for linked resources
+	if cr.Status.Phase == enterprisev1.PhaseReady && !cr.Status.Bundle.PushInProgress && !cr.Status.RollingRestartInProgress && !cr.Status.ExcessBucketCleanupPending && !upgradePending {
 		result.Requeue = false
 	}
+
+	// wait until the cluster is next scaled by its schedule, if configured
+	if !result.Requeue && nextScheduledScaling > 0 {
+		result.Requeue = true
+		result.RequeueAfter = nextScheduledScaling
+	}
+
+	// keep refreshing load metrics, if enabled
+	if cr.Spec.LoadMetrics.Enabled && (!result.Requeue || result.RequeueAfter > loadMetricsInterval) {
+		result.Requeue = true
+		result.RequeueAfter = loadMetricsInterval
+	}
+
+	// keep checking peers for detention while any are detained, or once they are ready if remediation is enabled
+	checkDetention := hasDetainedPeers(cr) || (cr.Status.Phase == enterprisev1.PhaseReady && enterprise.IsDetentionRemediationEnabled(&cr.Spec.Detention))
+	if checkDetention && (!result.Requeue || result.RequeueAfter > detentionCheckInterval) {
+		result.Requeue = true
+		result.RequeueAfter = detentionCheckInterval
+	}
 	return result, nil
 }
 
+// deleteIndexerClusterMaster deletes the StatefulSet, Service and PodDisruptionBudget of a cluster master that is controlled
+// by an indexer cluster, once it uses a ClusterMaster or external cluster master instead. Persistent volume claims are kept.
+func deleteIndexerClusterMaster(client ControllerClient, cr *enterprisev1.IndexerCluster) error {
+	statefulSetName := enterprise.GetSplunkStatefulsetName(enterprise.SplunkClusterMaster, cr.GetIdentifier())
+	serviceName := enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, cr.GetIdentifier(), false)
+	objects := []struct {
+		name string
+		obj  runtime.Object
+	}{
+		{statefulSetName, &appsv1.StatefulSet{}},
+		{serviceName, &corev1.Service{}},
+		{statefulSetName, &policyv1beta1.PodDisruptionBudget{}},
+	}
+	for _, o := range objects {
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: o.name}
+		if err := client.Get(context.TODO(), namespacedName, o.obj); err != nil {
+			continue
+		}
+		if metaObj, ok := o.obj.(metav1.Object); !ok || !metav1.IsControlledBy(metaObj, cr) {
+			continue
+		}
+		log.WithName("deleteIndexerClusterMaster").Info("Deleting cluster master that is no longer used",
+			"kind", fmt.Sprintf("%T", o.obj), "name", o.name, "namespace", cr.GetNamespace())
+		if err := client.Delete(context.TODO(), o.obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyIndexerClusterMaster creates or updates the statefulset for the cluster master of an indexer cluster, and pushes
+// smartstore configuration, app packages, defaults and conf files to indexer cluster peers once it is ready
+func applyIndexerClusterMaster(client ControllerClient, cr *enterprisev1.IndexerCluster, secrets, tls *corev1.Secret, defaults *corev1.ConfigMap, smartstore, discovery, confFiles *corev1.Secret, apps []enterprisev1.AppStatus, scopedLog logr.Logger) error {
+	statefulSet, err := enterprise.GetIndexerClusterMasterStatefulSet(cr)
+	if err != nil {
+		return err
+	}
+	if smartstore != nil {
+		// restart cluster master to ensure it always pushes the latest smartstore configuration
+		enterprise.SetSmartStoreChecksum(&statefulSet.Spec.Template, enterprise.GetSmartStoreChecksum(smartstore))
+	}
+	if apps != nil {
+		// restart cluster master to ensure it always pushes the latest app packages
+		enterprise.SetAppsChecksum(&statefulSet.Spec.Template, enterprise.GetAppsChecksum(apps))
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetIndexerDiscoveryChecksum(&statefulSet.Spec.Template, discovery)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
+
+	// limit the number of pods that may be evicted at the same time
+	err = ApplyPodDisruptionBudget(client, enterprise.GetSplunkPodDisruptionBudget(statefulSet, cr.Spec.MaxUnavailable))
+	if err != nil {
+		return err
+	}
+	clusterMasterManager := DefaultStatefulSetPodManager{}
+	phase, err := clusterMasterManager.Update(client, statefulSet, 1)
+	if err != nil {
+		return err
+	}
+	cr.Status.ClusterMasterPhase = phase
+	if phase != enterprisev1.PhaseReady {
+		_, message := getStatefulSetPhaseReason(client, statefulSet, phase)
+		cr.Status.Reason, cr.Status.Message = "ClusterMasterNotReady", getNotReadyMessage("cluster master", message)
+	}
+
+	// push smartstore configuration to indexer cluster peers
+	if smartstore == nil {
+		cr.Status.SmartStoreChecksum = ""
+	} else if phase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.pushSmartStoreConfig(enterprise.GetSmartStoreChecksum(smartstore))
+		if err != nil {
+			return err
+		}
+	}
+
+	// push app packages to indexer cluster peers
+	if apps == nil {
+		cr.Status.Apps = nil
+	} else if phase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.pushApps(apps)
+		if err != nil {
+			return err
+		}
+	}
+
+	// push defaults to indexer cluster peers
+	if defaults == nil {
+		cr.Status.Bundle.DefaultsChecksum = ""
+	} else if phase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.pushDefaults(enterprise.GetDefaultsChecksum(defaults))
+		if err != nil {
+			return err
+		}
+	}
+
+	// push conf files to indexer cluster peers
+	if confFiles == nil {
+		cr.Status.Bundle.ConfFilesChecksum = ""
+	} else if phase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.pushConfFiles(enterprise.GetConfFilesChecksum(confFiles))
+		if err != nil {
+			return err
+		}
+	}
+
+	// push the cluster bundle again if requested using the bundle-push annotation
+	if phase == enterprisev1.PhaseReady {
+		mgr := IndexerClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mgr.pushRequestedBundle()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getIndexerClusterHosts returns the FQDNs of the cluster master and all indexers in an indexer cluster. A referenced ClusterMaster
+// or external cluster master is not included, since it is managed separately.
+func getIndexerClusterHosts(cr *enterprisev1.IndexerCluster) []string {
+	hosts := []string{}
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		hosts = append(hosts, resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, cr.GetIdentifier(), false)))
+	}
+	return append(hosts, getIndexerClusterPeerHosts(cr)...)
+}
+
+// getIndexerClusterPeerHosts returns the FQDNs of all indexers in an indexer cluster, including each site of a multisite cluster.
+func getIndexerClusterPeerHosts(cr *enterprisev1.IndexerCluster) []string {
+	if len(cr.Spec.Sites) == 0 {
+		return strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkIndexer, cr.GetIdentifier(), cr.Spec.Replicas, false), ",")
+	}
+	hosts := []string{}
+	for _, site := range cr.Spec.Sites {
+		siteIdentifier := enterprise.GetSplunkSiteIdentifier(cr.GetIdentifier(), site.Name)
+		hosts = append(hosts, strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkIndexer, siteIdentifier, site.Replicas, false), ",")...)
+	}
+	return hosts
+}
+
+// getIndexerClusterDNSNames returns the DNS names used to reach the cluster master and indexers of an indexer cluster, including each site
+func getIndexerClusterDNSNames(cr *enterprisev1.IndexerCluster) []string {
+	instanceTypes := []enterprise.InstanceType{enterprise.SplunkIndexer}
+	if enterprise.HasOwnClusterMaster(&cr.Spec) {
+		instanceTypes = append([]enterprise.InstanceType{enterprise.SplunkClusterMaster}, instanceTypes...)
+	}
+	dnsNames := enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), instanceTypes...)
+	for _, site := range cr.Spec.Sites {
+		siteIdentifier := enterprise.GetSplunkSiteIdentifier(cr.GetIdentifier(), site.Name)
+		dnsNames = append(dnsNames, "*."+resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkIndexer, siteIdentifier, true)))
+	}
+	return dnsNames
+}
+
+// applyIndexerClusterSites creates or updates the headless service and statefulset of indexers for each site of a multisite indexer cluster
+func applyIndexerClusterSites(client ControllerClient, cr *enterprisev1.IndexerCluster, secrets, clusterMasterSecrets, tls, discovery *corev1.Secret, defaults *corev1.ConfigMap, scopedLog logr.Logger) (enterprisev1.ResourcePhase, error) {
+	// keep site status in the same order as sites in the spec, preserving any peer status we already have
+	siteStatus := make([]enterprisev1.IndexerClusterSiteStatus, len(cr.Spec.Sites))
+	for idx, site := range cr.Spec.Sites {
+		siteStatus[idx] = enterprisev1.IndexerClusterSiteStatus{
+			Name:     site.Name,
+			Phase:    enterprisev1.PhaseError,
+			Replicas: site.Replicas,
+			Peers:    []enterprisev1.IndexerClusterMemberStatus{},
+		}
+		for _, prev := range cr.Status.Sites {
+			if prev.Name == site.Name && prev.Peers != nil {
+				siteStatus[idx].Peers = prev.Peers
+			}
+		}
+	}
+	cr.Status.Sites = siteStatus
+
+	// the indexer cluster is only ready when all of its sites are ready
+	phase := enterprisev1.PhaseReady
+	cr.Status.ReadyReplicas = 0
+	for idx, site := range cr.Spec.Sites {
+		err := ApplyService(client, enterprise.GetIndexerSiteService(cr, site.Name))
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+
+		statefulSet, err := enterprise.GetIndexerSiteStatefulSet(cr, site)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+		enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+		enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+		enterprise.SetIndexerDiscoveryChecksum(&statefulSet.Spec.Template, discovery)
+
+		// limit the number of pods that may be evicted at the same time
+		err = ApplyPodDisruptionBudget(client, enterprise.GetSplunkPodDisruptionBudget(statefulSet, cr.Spec.MaxUnavailable))
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		mgr := IndexerClusterPodManager{log: scopedLog.WithValues("site", site.Name), cr: cr, secrets: secrets, clusterMasterSecrets: clusterMasterSecrets, newSplunkClient: getSplunkClientFactory(client), site: &cr.Status.Sites[idx]}
+		sitePhase, err := mgr.Update(client, statefulSet, site.Replicas)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+		cr.Status.Sites[idx].Phase = sitePhase
+		if sitePhase != enterprisev1.PhaseReady && cr.Status.Reason == "" {
+			cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, sitePhase)
+		}
+		cr.Status.ReadyReplicas += cr.Status.Sites[idx].ReadyReplicas
+		if phase == enterprisev1.PhaseReady {
+			phase = sitePhase
+		}
+	}
+
+	return phase, nil
+}
+
+// getIndexerStatefulSetName returns the name of the StatefulSet for indexer cluster peers, or for the peers of the first site
+// of a multisite indexer cluster
+func getIndexerStatefulSetName(cr *enterprisev1.IndexerCluster) string {
+	if len(cr.Spec.Sites) > 0 {
+		return enterprise.GetSplunkStatefulsetName(enterprise.SplunkIndexer, enterprise.GetSplunkSiteIdentifier(cr.GetIdentifier(), cr.Spec.Sites[0].Name))
+	}
+	return enterprise.GetSplunkStatefulsetName(enterprise.SplunkIndexer, cr.GetIdentifier())
+}
+
+// getIndexerClusterUpgradeStatefulSetName returns the name of the StatefulSet that is upgraded first for an indexer cluster,
+// which is its cluster master unless it references a ClusterMaster or joins an external cluster master
+func getIndexerClusterUpgradeStatefulSetName(cr *enterprisev1.IndexerCluster) string {
+	if !enterprise.HasOwnClusterMaster(&cr.Spec) {
+		return getIndexerStatefulSetName(cr)
+	}
+	return enterprise.GetSplunkStatefulsetName(enterprise.SplunkClusterMaster, cr.GetIdentifier())
+}
+
 // IndexerClusterPodManager is used to manage the pods within a search head cluster
 type IndexerClusterPodManager struct {
 	log             logr.Logger
 	cr              *enterprisev1.IndexerCluster
 	secrets         *corev1.Secret
 	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+
+	// secrets of a referenced ClusterMaster, or nil if the indexer cluster has its own cluster master
+	clusterMasterSecrets *corev1.Secret
+
+	// status of the site managed for multisite indexer clusters, or nil if not multisite
+	site *enterprisev1.IndexerClusterSiteStatus
 }
 
 // Update for IndexerClusterPodManager handles all updates for a statefulset of indexers
@@ -146,7 +693,7 @@ func (mgr *IndexerClusterPodManager) Update(c ControllerClient, statefulSet *app
 
 	// update CR status with SHC information
 	err = mgr.updateStatus(statefulSet)
-	if err != nil || mgr.cr.Status.ReadyReplicas == 0 || !mgr.cr.Status.Initialized || !mgr.cr.Status.IndexingReady || !mgr.cr.Status.ServiceReady {
+	if err != nil || statefulSet.Status.ReadyReplicas == 0 || !mgr.cr.Status.Initialized || !mgr.cr.Status.IndexingReady || !mgr.cr.Status.ServiceReady {
 		mgr.log.Error(err, "Indexer cluster is not ready")
 		return enterprisev1.PhasePending, nil
 	}
@@ -157,6 +704,12 @@ func (mgr *IndexerClusterPodManager) Update(c ControllerClient, statefulSet *app
 
 // PrepareScaleDown for IndexerClusterPodManager prepares indexer pod to be removed via scale down event; it returns true when ready
 func (mgr *IndexerClusterPodManager) PrepareScaleDown(n int32) (bool, error) {
+	// bucket fixup is required to decommission a peer, so take the cluster master out of any maintenance mode we started
+	err := mgr.setMaintenanceMode(false)
+	if err != nil {
+		return false, err
+	}
+
 	// first, decommission indexer peer with enforceCounts=true; this will rebalance buckets across other peers
 	complete, err := mgr.decommission(n, true)
 	if err != nil {
@@ -168,24 +721,49 @@ func (mgr *IndexerClusterPodManager) PrepareScaleDown(n int32) (bool, error) {
 
 	// next, remove the peer
 	c := mgr.getClusterMasterClient()
-	return true, c.RemoveIndexerClusterPeer(mgr.cr.Status.Peers[n].ID)
+	err = c.RemoveIndexerClusterPeer((*mgr.getPeers())[n].ID)
+	if err != nil {
+		return true, err
+	}
+	peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.getIdentifier(), n)
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "PeerDecommissioned", "Decommissioned indexer cluster peer %s", peerName)
+
+	// excess bucket copies are removed from the remaining peers once scaling down has completed
+	mgr.cr.Status.ExcessBucketCleanupPending = !mgr.cr.Spec.DisableExcessBucketCleanup
+	return true, nil
 }
 
 // PrepareRecycle for IndexerClusterPodManager prepares indexer pod to be recycled for updates; it returns true when ready
 func (mgr *IndexerClusterPodManager) PrepareRecycle(n int32) (bool, error) {
+	// wait for the indexer cluster to recover from any previous recycle before taking another peer offline
+	if (*mgr.getPeers())[n].Status == "Up" {
+		healthy, err := mgr.isClusterHealthy()
+		if err != nil || !healthy {
+			return false, err
+		}
+
+		// avoid bucket fixup while the peer is offline, if requested
+		if mgr.cr.Spec.MaintenanceMode {
+			err = mgr.setMaintenanceMode(true)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
 	return mgr.decommission(n, false)
 }
 
 // FinishRecycle for IndexerClusterPodManager completes recycle event for indexer pod; it returns true when complete
 func (mgr *IndexerClusterPodManager) FinishRecycle(n int32) (bool, error) {
-	return mgr.cr.Status.Peers[n].Status == "Up", nil
+	return (*mgr.getPeers())[n].Status == "Up", nil
 }
 
 // decommission for IndexerClusterPodManager decommissions an indexer pod; it returns true when ready
 func (mgr *IndexerClusterPodManager) decommission(n int32, enforceCounts bool) (bool, error) {
-	peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.cr.GetIdentifier(), n)
+	peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.getIdentifier(), n)
+	peerStatus := (*mgr.getPeers())[n].Status
 
-	switch mgr.cr.Status.Peers[n].Status {
+	switch peerStatus {
 	case "Up":
 		mgr.log.Info("Decommissioning indexer cluster peer", "peerName", peerName, "enforceCounts", enforceCounts)
 		c := mgr.getClient(n)
@@ -200,11 +778,11 @@ func (mgr *IndexerClusterPodManager) decommission(n int32, enforceCounts bool) (
 		return false, nil
 
 	case "GracefulShutdown":
-		mgr.log.Info("Decommission complete", "peerName", peerName, "Status", mgr.cr.Status.Peers[n].Status)
+		mgr.log.Info("Decommission complete", "peerName", peerName, "Status", peerStatus)
 		return true, nil
 
 	case "Down":
-		mgr.log.Info("Decommission complete", "peerName", peerName, "Status", mgr.cr.Status.Peers[n].Status)
+		mgr.log.Info("Decommission complete", "peerName", peerName, "Status", peerStatus)
 		return true, nil
 
 	case "": // this can happen after the peer has been removed from the indexer cluster
@@ -213,32 +791,314 @@ func (mgr *IndexerClusterPodManager) decommission(n int32, enforceCounts bool) (
 	}
 
 	// unhandled status
-	return false, fmt.Errorf("Status=%s", mgr.cr.Status.Peers[n].Status)
+	return false, fmt.Errorf("Status=%s", peerStatus)
+}
+
+// decommissionPeers for IndexerClusterPodManager takes the indexer cluster peers it manages offline one at a time, with
+// enforceCounts=true so that their buckets are replicated to the other peers of a referenced ClusterMaster, and removes them
+// from the cluster master. It is used before an indexer cluster is deleted, and returns true once all peers have been removed.
+func (mgr *IndexerClusterPodManager) decommissionPeers() (bool, error) {
+	c := mgr.getClusterMasterClient()
+	peers, err := c.GetClusterMasterPeers()
+	if err != nil {
+		return false, err
+	}
+
+	for n, peer := range *mgr.getPeers() {
+		peerInfo, ok := peers[peer.Name]
+		if !ok {
+			continue
+		}
+		switch peerInfo.Status {
+		case "Up":
+			mgr.log.Info("Decommissioning indexer cluster peer", "peerName", peer.Name, "enforceCounts", true)
+			return false, mgr.getClient(int32(n)).DecommissionIndexerClusterPeer(true)
+
+		case "GracefulShutdown", "Down":
+			mgr.log.Info("Removing indexer cluster peer", "peerName", peer.Name, "Status", peerInfo.Status)
+			err = c.RemoveIndexerClusterPeer(peerInfo.ID)
+			if err != nil {
+				return false, err
+			}
+			recordEvent(mgr.cr, corev1.EventTypeNormal, "PeerDecommissioned", "Decommissioned indexer cluster peer %s", peer.Name)
+
+		default:
+			mgr.log.Info("Waiting for decommission to complete", "peerName", peer.Name, "Status", peerInfo.Status)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isClusterHealthy for IndexerClusterPodManager returns true if bucket replication has completed and all data in the indexer cluster is searchable
+func (mgr *IndexerClusterPodManager) isClusterHealthy() (bool, error) {
+	c := mgr.getClusterMasterClient()
+	health, err := c.GetClusterMasterHealth()
+	if err != nil {
+		return false, err
+	}
+
+	// replication and search factors may not be met while bucket fixup is halted by maintenance mode
+	if mgr.cr.Status.OperatorMaintenanceMode {
+		if health.AllPeersAreUp != "1" {
+			mgr.log.Info("Waiting for all indexer cluster peers to be up", "allPeersAreUp", health.AllPeersAreUp)
+			return false, nil
+		}
+		return true, nil
+	}
+
+	if health.AllPeersAreUp != "1" || health.ReplicationFactorMet != "1" || health.SearchFactorMet != "1" || health.AllDataIsSearchable != "1" {
+		mgr.log.Info("Waiting for indexer cluster to become healthy",
+			"allPeersAreUp", health.AllPeersAreUp,
+			"replicationFactorMet", health.ReplicationFactorMet,
+			"searchFactorMet", health.SearchFactorMet,
+			"allDataIsSearchable", health.AllDataIsSearchable)
+		return false, nil
+	}
+	return true, nil
+}
+
+// setMaintenanceMode for IndexerClusterPodManager puts the cluster master into maintenance mode, or takes it out of
+// maintenance mode if the operator previously enabled it. Maintenance mode that was enabled by other means is left unchanged.
+func (mgr *IndexerClusterPodManager) setMaintenanceMode(enable bool) error {
+	if enable == mgr.cr.Status.OperatorMaintenanceMode || (enable && mgr.cr.Status.MaintenanceMode) {
+		return nil
+	}
+
+	mgr.log.Info("Setting indexer cluster maintenance mode", "enabled", enable)
+	c := mgr.getClusterMasterClient()
+	err := c.SetClusterMasterMaintenanceMode(enable)
+	if err != nil {
+		return err
+	}
+	mgr.cr.Status.OperatorMaintenanceMode = enable
+	mgr.cr.Status.MaintenanceMode = enable
+	if enable {
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "MaintenanceModeEnabled", "Enabled maintenance mode to update indexer cluster peers")
+	} else {
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "MaintenanceModeDisabled", "Disabled maintenance mode for indexer cluster")
+	}
+	return nil
+}
+
+// rollingRestartPeers for IndexerClusterPodManager requests a searchable rolling restart of all indexer cluster peers from
+// the cluster master, if a restart was requested using the restarted-at annotation. It waits for the cluster to be healthy
+// and for any rolling restart already in progress to complete first.
+func (mgr *IndexerClusterPodManager) rollingRestartPeers() error {
+	if !enterprise.IsRestartRequested(mgr.cr, mgr.cr.Status.RollingRestartRequest) || mgr.cr.Status.RollingRestartInProgress {
+		return nil
+	}
+	healthy, err := mgr.isClusterHealthy()
+	if err != nil || !healthy {
+		return err
+	}
+
+	request := enterprise.GetRestartRequest(mgr.cr)
+	mgr.log.Info("Starting searchable rolling restart of indexer cluster peers", "request", request)
+	c := mgr.getClusterMasterClient()
+	err = c.RollingRestartClusterPeers(true)
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.RollingRestartRequest = request
+	mgr.cr.Status.RollingRestartInProgress = true
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "RollingRestartStarted", "Started searchable rolling restart of indexer cluster peers")
+	return nil
+}
+
+// removeExcessBuckets for IndexerClusterPodManager removes excess bucket copies from indexer cluster peers after peers have
+// been decommissioned to scale down, so that the storage they use is reclaimed. It waits for the cluster to be healthy first,
+// so that bucket fixup has finished creating the copies needed to meet the replication and search factors.
+func (mgr *IndexerClusterPodManager) removeExcessBuckets() error {
+	if !mgr.cr.Status.ExcessBucketCleanupPending {
+		return nil
+	}
+	if mgr.cr.Spec.DisableExcessBucketCleanup {
+		mgr.cr.Status.ExcessBucketCleanupPending = false
+		return nil
+	}
+	healthy, err := mgr.isClusterHealthy()
+	if err != nil || !healthy {
+		return err
+	}
+
+	// the storage reclaimed is estimated before removal, since excess copies are no longer reported afterwards
+	c := mgr.getClusterMasterClient()
+	indexes, err := c.GetClusterMasterIndexes()
+	if err != nil {
+		return err
+	}
+	reclaimed := getExcessBucketBytes(indexes)
+
+	mgr.log.Info("Removing excess bucket copies from indexer cluster peers", "bytes", reclaimed)
+	err = c.RemoveExcessBuckets()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.ExcessBucketCleanupPending = false
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "ExcessBucketsRemoved", "Removed excess bucket copies from indexer cluster peers, reclaiming approximately %d bytes", reclaimed)
+	return nil
+}
+
+// getExcessBucketBytes returns the approximate storage used by excess bucket copies across all indexes, using the average
+// size of the buckets in each index
+func getExcessBucketBytes(indexes map[string]splclient.ClusterMasterIndexInfo) int64 {
+	var total int64
+	for _, index := range indexes {
+		if index.NumBuckets > 0 {
+			total += index.TotalExcessBucketCopies * index.IndexSize / index.NumBuckets
+		}
+	}
+	return total
+}
+
+// pushSmartStoreConfig for IndexerClusterPodManager applies the cluster bundle on the cluster master, if smartstore configuration has changed
+func (mgr *IndexerClusterPodManager) pushSmartStoreConfig(checksum string) error {
+	if mgr.cr.Status.SmartStoreChecksum == checksum {
+		return nil
+	}
+
+	mgr.log.Info("Applying cluster bundle to push SmartStore configuration", "checksum", checksum)
+	c := mgr.getClusterMasterClient()
+	err := c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.SmartStoreChecksum = checksum
+	return nil
+}
+
+// pushApps for IndexerClusterPodManager applies the cluster bundle on the cluster master, if app package versions have changed
+func (mgr *IndexerClusterPodManager) pushApps(apps []enterprisev1.AppStatus) error {
+	checksum := enterprise.GetAppsChecksum(apps)
+	if mgr.cr.Status.Apps != nil && enterprise.GetAppsChecksum(mgr.cr.Status.Apps) == checksum {
+		return nil
+	}
+
+	mgr.log.Info("Applying cluster bundle to push app packages", "checksum", checksum)
+	c := mgr.getClusterMasterClient()
+	err := c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.Apps = apps
+	return nil
+}
+
+// pushDefaults for IndexerClusterPodManager applies the cluster bundle on the cluster master, if the defaults ConfigMap has changed
+func (mgr *IndexerClusterPodManager) pushDefaults(checksum string) error {
+	if mgr.cr.Status.Bundle.DefaultsChecksum == checksum {
+		return nil
+	}
+
+	mgr.log.Info("Applying cluster bundle to push defaults", "checksum", checksum)
+	c := mgr.getClusterMasterClient()
+	err := c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.Bundle.DefaultsChecksum = checksum
+	return nil
+}
+
+// pushConfFiles for IndexerClusterPodManager applies the cluster bundle on the cluster master, if conf files have changed
+func (mgr *IndexerClusterPodManager) pushConfFiles(checksum string) error {
+	if mgr.cr.Status.Bundle.ConfFilesChecksum == checksum {
+		return nil
+	}
+
+	mgr.log.Info("Applying cluster bundle to push conf files", "checksum", checksum)
+	c := mgr.getClusterMasterClient()
+	err := c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.Bundle.ConfFilesChecksum = checksum
+	return nil
+}
+
+// pushRequestedBundle for IndexerClusterPodManager applies the cluster bundle on the cluster master, if a push was requested
+// using the bundle-push annotation
+func (mgr *IndexerClusterPodManager) pushRequestedBundle() error {
+	if !enterprise.IsBundlePushRequested(mgr.cr, mgr.cr.Status.Bundle.PushRequest) {
+		return nil
+	}
+
+	request := enterprise.GetBundlePushRequest(mgr.cr)
+	mgr.log.Info("Applying cluster bundle to push requested by annotation", "request", request)
+	c := mgr.getClusterMasterClient()
+	err := c.ApplyClusterMasterBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.Bundle.PushRequest = request
+	return nil
 }
 
 // getClient for IndexerClusterPodManager returns a SplunkClient for the member n
 func (mgr *IndexerClusterPodManager) getClient(n int32) *splclient.SplunkClient {
-	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.cr.GetIdentifier(), n)
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.getIdentifier(), n)
 	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(),
-		fmt.Sprintf("%s.%s", memberName, enterprise.GetSplunkServiceName(enterprise.SplunkIndexer, mgr.cr.GetIdentifier(), true)))
-	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(mgr.secrets.Data["password"]))
+		fmt.Sprintf("%s.%s", memberName, enterprise.GetSplunkServiceName(enterprise.SplunkIndexer, mgr.getIdentifier(), true)))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
+
+// getIdentifier for IndexerClusterPodManager returns the identifier used to name the indexer statefulset and its pods
+func (mgr *IndexerClusterPodManager) getIdentifier() string {
+	if mgr.site != nil {
+		return enterprise.GetSplunkSiteIdentifier(mgr.cr.GetIdentifier(), mgr.site.Name)
+	}
+	return mgr.cr.GetIdentifier()
+}
+
+// getPeers for IndexerClusterPodManager returns the status of the indexer cluster peers it manages
+func (mgr *IndexerClusterPodManager) getPeers() *[]enterprisev1.IndexerClusterMemberStatus {
+	if mgr.site != nil {
+		return &mgr.site.Peers
+	}
+	return &mgr.cr.Status.Peers
 }
 
 // getClusterMasterClient for IndexerClusterPodManager returns a SplunkClient for cluster master
 func (mgr *IndexerClusterPodManager) getClusterMasterClient() *splclient.SplunkClient {
-	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, mgr.cr.GetIdentifier(), false))
-	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(mgr.secrets.Data["password"]))
+	if spec := &mgr.cr.Spec.ExternalClusterMaster; enterprise.IsExternalClusterMasterConfigured(spec) && mgr.clusterMasterSecrets != nil {
+		return mgr.newSplunkClient(enterprise.GetExternalClusterMasterURI(spec), "admin", enterprise.GetAppliedAdminPassword(mgr.clusterMasterSecrets))
+	}
+	namespace, identifier, secrets := mgr.cr.GetNamespace(), mgr.cr.GetIdentifier(), mgr.secrets
+	if ref := mgr.cr.Spec.ClusterMasterRef; ref.Name != "" {
+		identifier = ref.Name
+		if ref.Namespace != "" {
+			namespace = ref.Namespace
+		}
+		if mgr.clusterMasterSecrets != nil {
+			secrets = mgr.clusterMasterSecrets
+		}
+	}
+	fqdnName := resources.GetServiceFQDN(namespace, enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, identifier, false))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(secrets))
 }
 
 // updateStatus for IndexerClusterPodManager uses the REST API to update the status for a SearcHead custom resource
 func (mgr *IndexerClusterPodManager) updateStatus(statefulSet *appsv1.StatefulSet) error {
-	mgr.cr.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+	if mgr.site != nil {
+		mgr.site.ReadyReplicas = statefulSet.Status.ReadyReplicas
+	} else {
+		mgr.cr.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+	}
 
 	if mgr.cr.Status.ClusterMasterPhase != enterprisev1.PhaseReady {
 		mgr.cr.Status.Initialized = false
 		mgr.cr.Status.IndexingReady = false
 		mgr.cr.Status.ServiceReady = false
 		mgr.cr.Status.MaintenanceMode = false
+		mgr.cr.Status.RollingRestartInProgress = false
 		return fmt.Errorf("Waiting for cluster master to become ready")
 	}
 
@@ -252,15 +1112,22 @@ func (mgr *IndexerClusterPodManager) updateStatus(statefulSet *appsv1.StatefulSe
 	mgr.cr.Status.IndexingReady = clusterInfo.IndexingReady
 	mgr.cr.Status.ServiceReady = clusterInfo.ServiceReady
 	mgr.cr.Status.MaintenanceMode = clusterInfo.MaintenanceMode
+	mgr.cr.Status.RollingRestartInProgress = clusterInfo.RollingRestart
+	setClusterBundleStatus(&mgr.cr.Status.Bundle, clusterInfo)
 
 	// get peer information from cluster master
 	peers, err := c.GetClusterMasterPeers()
 	if err != nil {
 		return err
 	}
+	currentPeers := mgr.getPeers()
+	now := time.Now()
 	for n := int32(0); n < statefulSet.Status.Replicas; n++ {
-		peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.cr.GetIdentifier(), n)
+		peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkIndexer, mgr.getIdentifier(), n)
 		peerStatus := enterprisev1.IndexerClusterMemberStatus{Name: peerName}
+		if n < int32(len(*currentPeers)) && (*currentPeers)[n].Name == peerName {
+			peerStatus.Detention = (*currentPeers)[n].Detention
+		}
 		peerInfo, ok := peers[peerName]
 		if ok {
 			peerStatus.ID = peerInfo.ID
@@ -271,16 +1138,19 @@ func (mgr *IndexerClusterPodManager) updateStatus(statefulSet *appsv1.StatefulSe
 		} else {
 			mgr.log.Info("Peer is not known by cluster master", "peerName", peerName)
 		}
-		if n < int32(len(mgr.cr.Status.Peers)) {
-			mgr.cr.Status.Peers[n] = peerStatus
+		if setPeerDetentionStatus(&peerStatus.Detention, peerStatus.Status, now) {
+			recordEvent(mgr.cr, corev1.EventTypeWarning, "PeerDetained", "Indexer cluster peer %s is in %s detention", peerName, peerStatus.Detention.Mode)
+		}
+		if n < int32(len(*currentPeers)) {
+			(*currentPeers)[n] = peerStatus
 		} else {
-			mgr.cr.Status.Peers = append(mgr.cr.Status.Peers, peerStatus)
+			*currentPeers = append(*currentPeers, peerStatus)
 		}
 	}
 
 	// truncate any extra peers that we didn't check (leftover from scale down)
-	if statefulSet.Status.Replicas < int32(len(mgr.cr.Status.Peers)) {
-		mgr.cr.Status.Peers = mgr.cr.Status.Peers[:statefulSet.Status.Replicas]
+	if statefulSet.Status.Replicas < int32(len(*currentPeers)) {
+		*currentPeers = (*currentPeers)[:statefulSet.Status.Replicas]
 	}
 
 	return nil
@@ -15,6 +15,7 @@
 package reconcile
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -24,8 +25,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
 )
 
@@ -35,11 +38,13 @@ func TestApplyIndexerCluster(t *testing.T) {
 		{metaName: "*v1.Service-test-splunk-stack1-indexer-headless"},
 		{metaName: "*v1.Service-test-splunk-stack1-indexer-service"},
 		{metaName: "*v1.Service-test-splunk-stack1-cluster-master-service"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-cluster-master"},
 		{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-indexer"},
 		{metaName: "*v1.StatefulSet-test-splunk-stack1-indexer"},
 	}
 	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
-	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[4], funcCalls[5]}}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[5], funcCalls[7]}}
 
 	current := enterprisev1.IndexerCluster{
 		TypeMeta: metav1.TypeMeta{
@@ -69,6 +74,207 @@ func TestApplyIndexerCluster(t *testing.T) {
 	splunkDeletionTester(t, revised, deleteFunc)
 }
 
+func TestApplyIndexerClusterMultisite(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-indexer-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-indexer-service"},
+		{metaName: "*v1.Service-test-splunk-stack1-cluster-master-service"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1.Service-test-splunk-stack1-site1-indexer-headless"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-site1-indexer"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-site1-indexer"},
+		{metaName: "*v1.Service-test-splunk-stack1-site2-indexer-headless"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-site2-indexer"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-site2-indexer"},
+	}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[4], funcCalls[7], funcCalls[10]}}
+
+	current := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.IndexerClusterSpec{
+			Sites: []enterprisev1.IndexerClusterSiteSpec{
+				{Name: "site1", Replicas: 2},
+				{Name: "site2", Replicas: 1},
+			},
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyIndexerCluster(c, cr.(*enterprisev1.IndexerCluster))
+		return err
+	}
+	reconcileTester(t, "TestApplyIndexerClusterMultisite", &current, revised, createCalls, updateCalls, reconcile)
+
+	if len(revised.Status.Sites) != 2 || revised.Status.Sites[0].Name != "site1" || revised.Status.Sites[1].Replicas != 1 {
+		t.Errorf("TestApplyIndexerClusterMultisite() status sites = %v; want site1 and site2", revised.Status.Sites)
+	}
+	if revised.Status.Replicas != 3 {
+		t.Errorf("TestApplyIndexerClusterMultisite() status replicas = %d; want %d", revised.Status.Replicas, 3)
+	}
+}
+
+func TestApplyIndexerClusterWithClusterMasterRef(t *testing.T) {
+	getCalls := []mockFuncCall{
+		{metaName: "*v1beta1.ClusterMaster-test-cm"},
+		{metaName: "*v1.Secret-test-splunk-cm-indexer-secrets"},
+		{metaName: "*v1.Secret-test-splunk-stack1-indexer-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-indexer-headless"},
+		{metaName: "*v1.Service-test-splunk-stack1-indexer-service"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1.Service-test-splunk-stack1-cluster-master-service"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1beta1.ClusterMaster-test-cm"},
+		{metaName: "*v1.Secret-test-splunk-cm-indexer-secrets"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-indexer"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-indexer"},
+	}
+	funcCalls := []mockFuncCall{getCalls[2], getCalls[3], getCalls[4], getCalls[10], getCalls[11]}
+	createCalls := map[string][]mockFuncCall{"Get": getCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": getCalls, "Update": []mockFuncCall{funcCalls[4]}}
+
+	current := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "stack1",
+			Namespace:  "test",
+			Finalizers: []string{"enterprise.splunk.com/decommission"},
+		},
+		Spec: enterprisev1.IndexerClusterSpec{
+			CommonSplunkSpec: enterprisev1.CommonSplunkSpec{
+				ClusterMasterRef: corev1.ObjectReference{Name: "cm"},
+			},
+		},
+	}
+	clusterMaster := enterprisev1.ClusterMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cm",
+			Namespace: "test",
+		},
+		Status: enterprisev1.ClusterMasterStatus{
+			Phase: enterprisev1.PhasePending,
+		},
+	}
+	clusterMasterSecrets := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-cm-indexer-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"idxc_secret": []byte("abc123"),
+			"password":    []byte("123"),
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyIndexerCluster(c, cr.(*enterprisev1.IndexerCluster))
+		return err
+	}
+	reconcileTester(t, "TestApplyIndexerClusterWithClusterMasterRef", &current, revised, createCalls, updateCalls, reconcile, &clusterMaster, &clusterMasterSecrets)
+
+	if revised.Status.ClusterMasterPhase != enterprisev1.PhasePending {
+		t.Errorf("TestApplyIndexerClusterWithClusterMasterRef() cluster master phase = %s; want %s", revised.Status.ClusterMasterPhase, enterprisev1.PhasePending)
+	}
+
+	// an error is returned if the referenced ClusterMaster does not exist
+	c := newMockClient()
+	c.state[getStateKey(&clusterMasterSecrets)] = &clusterMasterSecrets
+	if _, err := ApplyIndexerCluster(c, current.DeepCopy()); err == nil {
+		t.Errorf("ApplyIndexerCluster() returned nil; want error for missing ClusterMaster")
+	}
+}
+
+func TestDeleteIndexerClusterMaster(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+			UID:       "idxc-uid",
+		},
+	}
+	statefulSet := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "splunk-stack1-cluster-master",
+			Namespace:       "test",
+			OwnerReferences: []metav1.OwnerReference{resources.AsOwner(&cr)},
+		},
+	}
+	service := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-cluster-master-service",
+			Namespace: "test",
+		},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&statefulSet)] = &statefulSet
+	c.state[getStateKey(&service)] = &service
+
+	// only the cluster master controlled by the indexer cluster is deleted
+	err := deleteIndexerClusterMaster(c, &cr)
+	if err != nil {
+		t.Errorf("deleteIndexerClusterMaster() returned %v; want nil", err)
+	}
+	getCalls := []mockFuncCall{
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-cluster-master"},
+		{metaName: "*v1.Service-test-splunk-stack1-cluster-master-service"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-cluster-master"},
+	}
+	c.checkCalls(t, "TestDeleteIndexerClusterMaster", map[string][]mockFuncCall{"Get": getCalls, "Delete": {getCalls[0]}})
+}
+
+func TestGetClusterMasterClientWithRef(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{Data: map[string][]byte{"password": []byte("123")}}
+	clusterMasterSecrets := &corev1.Secret{Data: map[string][]byte{"password": []byte("456")}}
+	mgr := &IndexerClusterPodManager{
+		log:                  log.WithName("TestGetClusterMasterClientWithRef"),
+		cr:                   &cr,
+		secrets:              secrets,
+		clusterMasterSecrets: clusterMasterSecrets,
+		newSplunkClient:      splclient.NewSplunkClient,
+	}
+
+	test := func(wantURI, wantPassword string) {
+		c := mgr.getClusterMasterClient()
+		if c.ManagementURI != wantURI || c.Password != wantPassword {
+			t.Errorf("getClusterMasterClient() = %s, %s; want %s, %s", c.ManagementURI, c.Password, wantURI, wantPassword)
+		}
+	}
+
+	// the indexer cluster's own cluster master is used by default
+	test("https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089", "123")
+
+	// a referenced ClusterMaster is used instead, with its own admin password
+	cr.Spec.ClusterMasterRef.Name = "cm"
+	test("https://splunk-cm-cluster-master-service.test.svc.cluster.local:8089", "456")
+	cr.Spec.ClusterMasterRef.Namespace = "other"
+	test("https://splunk-cm-cluster-master-service.other.svc.cluster.local:8089", "456")
+
+	// an external cluster master is reached using its management URL, with the admin password of its Secret
+	cr.Spec.ClusterMasterRef = corev1.ObjectReference{}
+	cr.Spec.ExternalClusterMaster = enterprisev1.ExternalClusterMasterSpec{URL: "https://cm.example.com", SecretRef: "cm-secret"}
+	test("https://cm.example.com:8089", "456")
+}
+
 func indexerClusterPodManagerTester(t *testing.T, method string, mockHandlers []spltest.MockHTTPHandler,
 	desiredReplicas int32, wantPhase enterprisev1.ResourcePhase, statefulSet *appsv1.StatefulSet,
 	wantCalls map[string][]mockFuncCall, wantError error, initObjects ...runtime.Object) {
@@ -171,7 +377,22 @@ func TestIndexerClusterPodManager(t *testing.T) {
 	method := "IndexerClusterPodManager.Update(All pods ready)"
 	indexerClusterPodManagerTester(t, method, mockHandlers, 1, enterprisev1.PhaseReady, statefulSet, wantCalls, nil, statefulSet, pod)
 
+	// test pod needs update => wait for indexer cluster to become healthy
+	healthHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/health?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"links":{},"origin":"https://localhost:8089/services/cluster/master/health","updated":"2020-05-12T17:36:01+00:00","generator":{"build":"a7f645ddaf91","version":"8.0.2"},"entry":[{"name":"master","id":"https://localhost:8089/services/cluster/master/health/master","updated":"1970-01-01T00:00:00+00:00","links":{"alternate":"/services/cluster/master/health/master","list":"/services/cluster/master/health/master"},"author":"system","acl":{"app":"","can_list":true,"can_write":true,"modifiable":false,"owner":"system","perms":{"read":["admin","splunk-system-role"],"write":["admin","splunk-system-role"]},"removable":false,"sharing":"system"},"content":{"all_data_is_searchable":"0","all_peers_are_up":"1","cm_version_is_compatible":"1","eai:acl":null,"multisite":"0","no_fixup_tasks_in_progress":"0","pre_flight_check":"1","replication_factor_met":"0","search_factor_met":"0","site_replication_factor_met":"1","site_search_factor_met":"1","splunk_version_peer_count":"{ 8.0.2: 3 }"}}],"paging":{"total":1,"perPage":30,"offset":0},"messages":[]}`,
+	}
+	mockHandlers = append(mockHandlers, healthHandler)
+	pod.ObjectMeta.Labels["controller-revision-hash"] = "v0"
+	method = "IndexerClusterPodManager.Update(Cluster Not Healthy)"
+	indexerClusterPodManagerTester(t, method, mockHandlers, 1, enterprisev1.PhaseUpdating, statefulSet, wantCalls, nil, statefulSet, pod)
+
 	// test pod needs update => decommission
+	mockHandlers[2].Body = strings.Replace(mockHandlers[2].Body, `"all_data_is_searchable":"0"`, `"all_data_is_searchable":"1"`, 1)
+	mockHandlers[2].Body = strings.Replace(mockHandlers[2].Body, `"replication_factor_met":"0","search_factor_met":"0"`, `"replication_factor_met":"1","search_factor_met":"1"`, 1)
 	mockHandlers = append(mockHandlers, spltest.MockHTTPHandler{
 		Method: "POST",
 		URL:    "https://splunk-stack1-indexer-0.splunk-stack1-indexer-headless.test.svc.cluster.local:8089/services/cluster/slave/control/control/decommission?enforce_counts=0",
@@ -179,7 +400,6 @@ func TestIndexerClusterPodManager(t *testing.T) {
 		Err:    nil,
 		Body:   ``,
 	})
-	pod.ObjectMeta.Labels["controller-revision-hash"] = "v0"
 	method = "IndexerClusterPodManager.Update(Decommission Pod)"
 	indexerClusterPodManagerTester(t, method, mockHandlers, 1, enterprisev1.PhaseUpdating, statefulSet, wantCalls, nil, statefulSet, pod)
 
@@ -233,3 +453,508 @@ func TestIndexerClusterPodManager(t *testing.T) {
 	method = "IndexerClusterPodManager.Update(Decommission)"
 	indexerClusterPodManagerTester(t, method, mockHandlers, 1, enterprisev1.PhaseScalingDown, statefulSet, wantCalls, nil, statefulSet, pod, pvcList[0], pvcList[1])
 }
+
+func TestIndexerClusterMaintenanceMode(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	newMockSplunkClient := func(enable bool) *spltest.MockHTTPClient {
+		c := &spltest.MockHTTPClient{}
+		c.AddHandlers(spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    fmt.Sprintf("https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/maintenance_mode?mode=%t", enable),
+			Status: 200,
+			Err:    nil,
+			Body:   ``,
+		})
+		return c
+	}
+	mockSplunkClient := newMockSplunkClient(true)
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestIndexerClusterMaintenanceMode"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// maintenance mode is enabled by the operator
+	if err := mgr.setMaintenanceMode(true); err != nil {
+		t.Errorf("setMaintenanceMode(true) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterMaintenanceMode(enable)")
+	if !cr.Status.OperatorMaintenanceMode || !cr.Status.MaintenanceMode {
+		t.Errorf("setMaintenanceMode(true) status = %t, %t; want true, true", cr.Status.OperatorMaintenanceMode, cr.Status.MaintenanceMode)
+	}
+
+	// nothing to do if already enabled
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.setMaintenanceMode(true); err != nil {
+		t.Errorf("setMaintenanceMode(true) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterMaintenanceMode(enabled)")
+
+	// only peers being up is required for health while in maintenance mode
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/health?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"name":"master","content":{"all_data_is_searchable":"0","all_peers_are_up":"1","replication_factor_met":"0","search_factor_met":"0"}}]}`,
+	})
+	healthy, err := mgr.isClusterHealthy()
+	if !healthy || err != nil {
+		t.Errorf("isClusterHealthy() = %t, %v; want true, nil", healthy, err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterMaintenanceMode(health)")
+
+	// maintenance mode is disabled by the operator
+	mockSplunkClient = newMockSplunkClient(false)
+	if err := mgr.setMaintenanceMode(false); err != nil {
+		t.Errorf("setMaintenanceMode(false) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterMaintenanceMode(disable)")
+	if cr.Status.OperatorMaintenanceMode || cr.Status.MaintenanceMode {
+		t.Errorf("setMaintenanceMode(false) status = %t, %t; want false, false", cr.Status.OperatorMaintenanceMode, cr.Status.MaintenanceMode)
+	}
+
+	// maintenance mode enabled by other means is left unchanged
+	cr.Status.MaintenanceMode = true
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.setMaintenanceMode(true); err != nil {
+		t.Errorf("setMaintenanceMode(true) returned %v; want nil", err)
+	}
+	if err := mgr.setMaintenanceMode(false); err != nil {
+		t.Errorf("setMaintenanceMode(false) returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterMaintenanceMode(manual)")
+	if cr.Status.OperatorMaintenanceMode || !cr.Status.MaintenanceMode {
+		t.Errorf("setMaintenanceMode() status = %t, %t; want false, true", cr.Status.OperatorMaintenanceMode, cr.Status.MaintenanceMode)
+	}
+}
+
+func TestIndexerClusterRollingRestartPeers(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.RollingRestartMode = enterprise.RollingRestartModeSearchable
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	healthHandler := func(healthy string) spltest.MockHTTPHandler {
+		return spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/health?count=0&output_mode=json",
+			Status: 200,
+			Err:    nil,
+			Body:   fmt.Sprintf(`{"entry":[{"name":"master","content":{"all_data_is_searchable":"1","all_peers_are_up":"1","replication_factor_met":"%s","search_factor_met":"1"}}]}`, healthy),
+		}
+	}
+	restartHandler := spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/restart?searchable=true",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestIndexerClusterRollingRestartPeers"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// nothing to do if a restart has not been requested
+	if err := mgr.rollingRestartPeers(); err != nil {
+		t.Errorf("rollingRestartPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRollingRestartPeers(none)")
+
+	// wait for the cluster to become healthy before restarting peers
+	cr.ObjectMeta.Annotations = map[string]string{enterprise.RestartAnnotation: "2020-06-01T10:00:00Z"}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(healthHandler("0"))
+	if err := mgr.rollingRestartPeers(); err != nil {
+		t.Errorf("rollingRestartPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRollingRestartPeers(unhealthy)")
+	if cr.Status.RollingRestartRequest != "" {
+		t.Errorf("rollingRestartPeers() status.rollingRestartRequest = %s; want empty", cr.Status.RollingRestartRequest)
+	}
+
+	// a searchable rolling restart is requested from the cluster master
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(healthHandler("1"), restartHandler)
+	if err := mgr.rollingRestartPeers(); err != nil {
+		t.Errorf("rollingRestartPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRollingRestartPeers(restart)")
+	if cr.Status.RollingRestartRequest != "2020-06-01T10:00:00Z" || !cr.Status.RollingRestartInProgress {
+		t.Errorf("rollingRestartPeers() status = %s, %t; want %s, true", cr.Status.RollingRestartRequest, cr.Status.RollingRestartInProgress, "2020-06-01T10:00:00Z")
+	}
+
+	// a new request waits for the rolling restart in progress to complete
+	cr.ObjectMeta.Annotations[enterprise.RestartAnnotation] = "2020-06-02T10:00:00Z"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.rollingRestartPeers(); err != nil {
+		t.Errorf("rollingRestartPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRollingRestartPeers(in-progress)")
+
+	// errors from the cluster master are returned, and the request is retried
+	cr.Status.RollingRestartInProgress = false
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	restartHandler.Status = 500
+	mockSplunkClient.AddHandlers(healthHandler("1"), restartHandler)
+	if err := mgr.rollingRestartPeers(); err == nil {
+		t.Errorf("rollingRestartPeers() returned nil; want error")
+	}
+	if cr.Status.RollingRestartRequest != "2020-06-01T10:00:00Z" {
+		t.Errorf("rollingRestartPeers() status.rollingRestartRequest = %s; want %s", cr.Status.RollingRestartRequest, "2020-06-01T10:00:00Z")
+	}
+}
+
+func TestIndexerClusterRemoveExcessBuckets(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	healthHandler := func(healthy string) spltest.MockHTTPHandler {
+		return spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/health?count=0&output_mode=json",
+			Status: 200,
+			Err:    nil,
+			Body:   fmt.Sprintf(`{"entry":[{"name":"master","content":{"all_data_is_searchable":"1","all_peers_are_up":"1","replication_factor_met":"%s","search_factor_met":"1"}}]}`, healthy),
+		}
+	}
+	indexesHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/indexes?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"name":"_internal","content":{"index_size":1048576,"num_buckets":0,"total_excess_bucket_copies":2}},{"name":"main","content":{"index_size":52428800,"num_buckets":10,"total_excess_bucket_copies":4}}]}`,
+	}
+	pruneHandler := spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/prune_index",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestIndexerClusterRemoveExcessBuckets"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// nothing to do unless peers have been decommissioned to scale down
+	if err := mgr.removeExcessBuckets(); err != nil {
+		t.Errorf("removeExcessBuckets() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRemoveExcessBuckets(none)")
+
+	// wait for the cluster to become healthy before removing excess buckets
+	cr.Status.ExcessBucketCleanupPending = true
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(healthHandler("0"))
+	if err := mgr.removeExcessBuckets(); err != nil {
+		t.Errorf("removeExcessBuckets() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRemoveExcessBuckets(unhealthy)")
+	if !cr.Status.ExcessBucketCleanupPending {
+		t.Errorf("removeExcessBuckets() status.excessBucketCleanupPending = false; want true")
+	}
+
+	// errors from the cluster master are returned, and removal is retried
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	pruneHandler.Status = 500
+	mockSplunkClient.AddHandlers(healthHandler("1"), indexesHandler, pruneHandler)
+	if err := mgr.removeExcessBuckets(); err == nil {
+		t.Errorf("removeExcessBuckets() returned nil; want error")
+	}
+	if !cr.Status.ExcessBucketCleanupPending {
+		t.Errorf("removeExcessBuckets() status.excessBucketCleanupPending = false; want true")
+	}
+
+	// excess buckets are removed by the cluster master
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	pruneHandler.Status = 200
+	mockSplunkClient.AddHandlers(healthHandler("1"), indexesHandler, pruneHandler)
+	if err := mgr.removeExcessBuckets(); err != nil {
+		t.Errorf("removeExcessBuckets() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRemoveExcessBuckets(remove)")
+	if cr.Status.ExcessBucketCleanupPending {
+		t.Errorf("removeExcessBuckets() status.excessBucketCleanupPending = true; want false")
+	}
+
+	// nothing is removed if cleanup has been disabled
+	cr.Spec.DisableExcessBucketCleanup = true
+	cr.Status.ExcessBucketCleanupPending = true
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.removeExcessBuckets(); err != nil {
+		t.Errorf("removeExcessBuckets() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterRemoveExcessBuckets(disabled)")
+	if cr.Status.ExcessBucketCleanupPending {
+		t.Errorf("removeExcessBuckets() status.excessBucketCleanupPending = true; want false")
+	}
+}
+
+func TestGetExcessBucketBytes(t *testing.T) {
+	indexes := map[string]splclient.ClusterMasterIndexInfo{
+		"_internal": {Name: "_internal", IndexSize: 1048576, NumBuckets: 0, TotalExcessBucketCopies: 2},
+		"main":      {Name: "main", IndexSize: 52428800, NumBuckets: 10, TotalExcessBucketCopies: 4},
+		"summary":   {Name: "summary", IndexSize: 3000, NumBuckets: 3, TotalExcessBucketCopies: 1},
+	}
+	if got, want := getExcessBucketBytes(indexes), int64(4*5242880+1000); got != want {
+		t.Errorf("getExcessBucketBytes() = %d; want %d", got, want)
+	}
+	if got := getExcessBucketBytes(nil); got != 0 {
+		t.Errorf("getExcessBucketBytes(nil) = %d; want 0", got)
+	}
+}
+
+func TestPushSmartStoreConfig(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/apply",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestPushSmartStoreConfig"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// bundle is applied when checksum changes
+	if err := mgr.pushSmartStoreConfig("abc123"); err != nil {
+		t.Errorf("pushSmartStoreConfig() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushSmartStoreConfig")
+	if cr.Status.SmartStoreChecksum != "abc123" {
+		t.Errorf("pushSmartStoreConfig() checksum = %s; want %s", cr.Status.SmartStoreChecksum, "abc123")
+	}
+
+	// nothing to do when checksum is unchanged
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.pushSmartStoreConfig("abc123"); err != nil {
+		t.Errorf("pushSmartStoreConfig() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushSmartStoreConfig")
+}
+
+func TestPushDefaults(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/apply",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestPushDefaults"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// bundle is applied when checksum changes
+	if err := mgr.pushDefaults("abc123"); err != nil {
+		t.Errorf("pushDefaults() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushDefaults")
+	if cr.Status.Bundle.DefaultsChecksum != "abc123" {
+		t.Errorf("pushDefaults() checksum = %s; want %s", cr.Status.Bundle.DefaultsChecksum, "abc123")
+	}
+
+	// nothing to do when checksum is unchanged
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.pushDefaults("abc123"); err != nil {
+		t.Errorf("pushDefaults() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushDefaults")
+}
+
+func TestPushConfFiles(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/apply",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestPushConfFiles"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// bundle is applied when checksum changes
+	if err := mgr.pushConfFiles("abc123"); err != nil {
+		t.Errorf("pushConfFiles() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushConfFiles")
+	if cr.Status.Bundle.ConfFilesChecksum != "abc123" {
+		t.Errorf("pushConfFiles() checksum = %s; want %s", cr.Status.Bundle.ConfFilesChecksum, "abc123")
+	}
+
+	// nothing to do when checksum is unchanged
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.pushConfFiles("abc123"); err != nil {
+		t.Errorf("pushConfFiles() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushConfFiles")
+}
+
+func TestPushApps(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	newMockSplunkClient := func() *spltest.MockHTTPClient {
+		c := &spltest.MockHTTPClient{}
+		c.AddHandlers(spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://splunk-stack1-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/default/apply",
+			Status: 200,
+			Err:    nil,
+			Body:   ``,
+		})
+		return c
+	}
+	mockSplunkClient := newMockSplunkClient()
+	mgr := &IndexerClusterPodManager{
+		log:     log.WithName("TestPushApps"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	apps := []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "abc123"}}
+
+	// bundle is applied when apps are first installed
+	if err := mgr.pushApps(apps); err != nil {
+		t.Errorf("pushApps() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushApps")
+	if len(cr.Status.Apps) != 1 || cr.Status.Apps[0] != apps[0] {
+		t.Errorf("pushApps() status apps = %v; want %v", cr.Status.Apps, apps)
+	}
+
+	// nothing to do when app versions are unchanged
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.pushApps(apps); err != nil {
+		t.Errorf("pushApps() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushApps")
+
+	// bundle is applied when an app version changes
+	mockSplunkClient = newMockSplunkClient()
+	updated := []enterprisev1.AppStatus{{Source: "security", Key: "security/app1.tgz", Version: "def456"}}
+	if err := mgr.pushApps(updated); err != nil {
+		t.Errorf("pushApps() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestPushApps")
+	if cr.Status.Apps[0].Version != "def456" {
+		t.Errorf("pushApps() status version = %s; want %s", cr.Status.Apps[0].Version, "def456")
+	}
+}
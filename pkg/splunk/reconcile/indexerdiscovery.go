@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyIndexerDiscoveryConfig creates or updates the external Kubernetes Service used by forwarders to reach a cluster master
+// or indexer cluster peers, and a Kubernetes Secret containing the generated indexer discovery configuration for them. The
+// external endpoints of the service are reported in status. It returns the Secret if indexer discovery is enabled, or nil
+// if it is not.
+func ApplyIndexerDiscoveryConfig(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.IndexerDiscoverySpec, instanceType enterprise.InstanceType, status *enterprisev1.IndexerDiscoveryStatus) (*corev1.Secret, error) {
+	if !enterprise.IsIndexerDiscoveryEnabled(spec) {
+		setIndexerDiscoveryStatus(status, instanceType, nil)
+		return nil, nil
+	}
+
+	// the service is updated to its current state, which includes any addresses assigned to it
+	service := enterprise.GetIndexerDiscoveryService(cr, spec, instanceType)
+	err := ApplyService(client, service)
+	if err != nil {
+		return nil, err
+	}
+	setIndexerDiscoveryStatus(status, instanceType, enterprise.GetIndexerDiscoveryEndpoints(service))
+
+	scopedLog := log.WithName("ApplyIndexerDiscoveryConfig").WithValues(
+		"name", enterprise.GetSplunkIndexerDiscoveryName(cr.GetIdentifier(), instanceType),
+		"namespace", cr.GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: enterprise.GetSplunkIndexerDiscoveryName(cr.GetIdentifier(), instanceType)}
+	var current corev1.Secret
+
+	err = client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		revised := enterprise.GetIndexerDiscoverySecret(cr, spec, instanceType, service, nil)
+		revised.SetOwnerReferences(append(revised.GetOwnerReferences(), resources.AsOwner(cr)))
+		return revised, CreateResource(client, revised)
+	}
+
+	revised := enterprise.GetIndexerDiscoverySecret(cr, spec, instanceType, service, &current)
+	if !reflect.DeepEqual(revised.Data, current.Data) {
+		scopedLog.Info("Updating existing indexer discovery Secret")
+		current.Data = revised.Data
+		err = UpdateResource(client, &current)
+	} else {
+		scopedLog.Info("No changes for indexer discovery Secret")
+	}
+
+	return &current, err
+}
+
+// setIndexerDiscoveryStatus updates the master URI (for cluster masters) or endpoints (for indexer cluster peers) reported
+// in status using the external endpoints of an indexer discovery service
+func setIndexerDiscoveryStatus(status *enterprisev1.IndexerDiscoveryStatus, instanceType enterprise.InstanceType, endpoints []string) {
+	if instanceType == enterprise.SplunkIndexer {
+		status.Endpoints = endpoints
+		return
+	}
+	status.MasterURI = ""
+	if len(endpoints) > 0 {
+		status.MasterURI = "https://" + endpoints[0]
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyIndexerDiscoveryConfig(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	status := enterprisev1.IndexerDiscoveryStatus{MasterURI: "https://old:8089", Endpoints: []string{"old:9997"}}
+
+	// nothing to do if indexer discovery is not enabled
+	c := newMockClient()
+	secret, err := ApplyIndexerDiscoveryConfig(c, &cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkClusterMaster, &status)
+	if err != nil || secret != nil {
+		t.Errorf("ApplyIndexerDiscoveryConfig() = %v, %v; want nil, nil", secret, err)
+	}
+	if status.MasterURI != "" {
+		t.Errorf("ApplyIndexerDiscoveryConfig() status.masterUri = %s; want empty", status.MasterURI)
+	}
+	c.checkCalls(t, "TestApplyIndexerDiscoveryConfig(disabled)", map[string][]mockFuncCall{})
+
+	// service and configuration are created for the cluster master
+	cr.Spec.IndexerDiscovery = enterprisev1.IndexerDiscoverySpec{Enabled: true, ServiceType: corev1.ServiceTypeLoadBalancer}
+	serviceCalls := []mockFuncCall{{metaName: "*v1.Service-test-splunk-stack1-cluster-master-discovery"}}
+	secretCalls := []mockFuncCall{{metaName: "*v1.Secret-test-splunk-stack1-cluster-master-discovery-config"}}
+	secret, err = ApplyIndexerDiscoveryConfig(c, &cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkClusterMaster, &status)
+	if err != nil || secret == nil {
+		t.Fatalf("ApplyIndexerDiscoveryConfig() = %v, %v; want secret, nil", secret, err)
+	}
+	c.checkCalls(t, "TestApplyIndexerDiscoveryConfig(create)", map[string][]mockFuncCall{
+		"Get":    {serviceCalls[0], secretCalls[0]},
+		"Create": {serviceCalls[0], secretCalls[0]},
+	})
+	pass4SymmKey := secret.Data["pass4SymmKey"]
+
+	// master URI is reported once the load balancer has an address, and pass4SymmKey is kept
+	service := c.state["*v1.Service-test-splunk-stack1-cluster-master-discovery"].(*corev1.Service)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "cm.elb.amazonaws.com"}}
+	c.resetCalls()
+	secret, err = ApplyIndexerDiscoveryConfig(c, &cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkClusterMaster, &status)
+	if err != nil {
+		t.Errorf("ApplyIndexerDiscoveryConfig() returned %v; want nil", err)
+	}
+	if status.MasterURI != "https://cm.elb.amazonaws.com:8089" {
+		t.Errorf("ApplyIndexerDiscoveryConfig() status.masterUri = %s; want %s", status.MasterURI, "https://cm.elb.amazonaws.com:8089")
+	}
+	if !reflect.DeepEqual(secret.Data["pass4SymmKey"], pass4SymmKey) {
+		t.Errorf("ApplyIndexerDiscoveryConfig() pass4SymmKey = %s; want %s", secret.Data["pass4SymmKey"], pass4SymmKey)
+	}
+	c.checkCalls(t, "TestApplyIndexerDiscoveryConfig(no-change)", map[string][]mockFuncCall{"Get": {serviceCalls[0], secretCalls[0]}})
+
+	// peer configuration is updated once the load balancer has an address
+	serviceCalls = []mockFuncCall{{metaName: "*v1.Service-test-splunk-stack1-indexer-discovery"}}
+	secretCalls = []mockFuncCall{{metaName: "*v1.Secret-test-splunk-stack1-indexer-discovery-config"}}
+	_, err = ApplyIndexerDiscoveryConfig(c, &cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkIndexer, &status)
+	if err != nil {
+		t.Errorf("ApplyIndexerDiscoveryConfig() returned %v; want nil", err)
+	}
+	if status.Endpoints != nil {
+		t.Errorf("ApplyIndexerDiscoveryConfig() status.endpoints = %v; want nil", status.Endpoints)
+	}
+	service = c.state["*v1.Service-test-splunk-stack1-indexer-discovery"].(*corev1.Service)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	c.resetCalls()
+	secret, err = ApplyIndexerDiscoveryConfig(c, &cr, &cr.Spec.IndexerDiscovery, enterprise.SplunkIndexer, &status)
+	if err != nil {
+		t.Errorf("ApplyIndexerDiscoveryConfig() returned %v; want nil", err)
+	}
+	if !reflect.DeepEqual(status.Endpoints, []string{"10.1.2.3:9997"}) {
+		t.Errorf("ApplyIndexerDiscoveryConfig() status.endpoints = %v; want %v", status.Endpoints, []string{"10.1.2.3:9997"})
+	}
+	if got, want := string(secret.Data["server.conf"]), "[clustering]\nregister_forwarder_address = 10.1.2.3\n"; got != want {
+		t.Errorf("ApplyIndexerDiscoveryConfig() server.conf = %s; want %s", got, want)
+	}
+	c.checkCalls(t, "TestApplyIndexerDiscoveryConfig(update)", map[string][]mockFuncCall{
+		"Get":    {serviceCalls[0], secretCalls[0]},
+		"Update": secretCalls,
+	})
+}
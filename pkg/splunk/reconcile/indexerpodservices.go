@@ -0,0 +1,72 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplyIndexerPodServices creates or updates an external Kubernetes Service for each indexer cluster peer, if enabled, and
+// deletes the services of peers that have been removed (or of all peers, once they are disabled). The external endpoint
+// of each peer is reported in status.
+func ApplyIndexerPodServices(client ControllerClient, cr *enterprisev1.IndexerCluster) error {
+	var podNames []string
+	if enterprise.IsIndexerPodServicesEnabled(&cr.Spec.PodServices) {
+		podNames = enterprise.GetIndexerPodNames(cr)
+	}
+
+	// the services are updated to their current state, which includes any addresses assigned to them
+	var podServices []enterprisev1.IndexerPodServiceStatus
+	serviceNames := make(map[string]bool)
+	for _, podName := range podNames {
+		service := enterprise.GetIndexerPodService(cr, &cr.Spec.PodServices, podName)
+		serviceName := service.GetName()
+		err := ApplyService(client, service)
+		if err != nil {
+			return err
+		}
+		serviceNames[serviceName] = true
+		podServices = append(podServices, enterprisev1.IndexerPodServiceStatus{
+			Name:     podName,
+			Service:  serviceName,
+			Endpoint: enterprise.GetIndexerPodServiceEndpoint(service),
+		})
+	}
+
+	// services that are no longer used are tracked using status, so that they are only deleted once
+	for _, prev := range cr.Status.PodServices {
+		if serviceNames[prev.Service] {
+			continue
+		}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: prev.Service}
+		var current corev1.Service
+		if err := client.Get(context.TODO(), namespacedName, &current); err != nil {
+			continue
+		}
+		log.WithName("ApplyIndexerPodServices").Info("Deleting unused external service", "name", prev.Service, "namespace", cr.GetNamespace())
+		if err := client.Delete(context.TODO(), &current); err != nil {
+			return err
+		}
+	}
+
+	cr.Status.PodServices = podServices
+	return nil
+}
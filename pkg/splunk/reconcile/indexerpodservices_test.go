@@ -0,0 +1,97 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestApplyIndexerPodServices(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.IndexerClusterSpec{Replicas: 2},
+	}
+
+	// nothing to do if external services are not enabled
+	c := newMockClient()
+	err := ApplyIndexerPodServices(c, &cr)
+	if err != nil || cr.Status.PodServices != nil {
+		t.Errorf("ApplyIndexerPodServices() = %v, status %v; want nil, nil", err, cr.Status.PodServices)
+	}
+	c.checkCalls(t, "TestApplyIndexerPodServices(disabled)", map[string][]mockFuncCall{})
+
+	// one service is created for each peer
+	cr.Spec.PodServices = enterprisev1.IndexerPodServicesSpec{Enabled: true, ServiceType: corev1.ServiceTypeLoadBalancer}
+	serviceCalls := []mockFuncCall{
+		{metaName: "*v1.Service-test-splunk-stack1-indexer-0-external"},
+		{metaName: "*v1.Service-test-splunk-stack1-indexer-1-external"},
+	}
+	err = ApplyIndexerPodServices(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyIndexerPodServices() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplyIndexerPodServices(create)", map[string][]mockFuncCall{"Get": serviceCalls, "Create": serviceCalls})
+	if len(cr.Status.PodServices) != 2 || cr.Status.PodServices[1].Name != "splunk-stack1-indexer-1" || cr.Status.PodServices[1].Endpoint != "" {
+		t.Errorf("ApplyIndexerPodServices() status = %v; want 2 peers without endpoints", cr.Status.PodServices)
+	}
+
+	// endpoints are reported once load balancers have an address
+	service := c.state["*v1.Service-test-splunk-stack1-indexer-1-external"].(*corev1.Service)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	c.resetCalls()
+	err = ApplyIndexerPodServices(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyIndexerPodServices() returned %v; want nil", err)
+	}
+	if cr.Status.PodServices[1].Endpoint != "10.1.2.3:9997" {
+		t.Errorf("ApplyIndexerPodServices() endpoint = %s; want %s", cr.Status.PodServices[1].Endpoint, "10.1.2.3:9997")
+	}
+	c.checkCalls(t, "TestApplyIndexerPodServices(no-change)", map[string][]mockFuncCall{"Get": serviceCalls})
+
+	// services of removed peers are deleted
+	cr.Spec.Replicas = 1
+	c.resetCalls()
+	err = ApplyIndexerPodServices(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyIndexerPodServices() returned %v; want nil", err)
+	}
+	if len(cr.Status.PodServices) != 1 {
+		t.Errorf("ApplyIndexerPodServices() status = %v; want 1 peer", cr.Status.PodServices)
+	}
+	c.checkCalls(t, "TestApplyIndexerPodServices(scale-down)", map[string][]mockFuncCall{
+		"Get":    serviceCalls,
+		"Delete": serviceCalls[1:],
+	})
+
+	// all services are deleted once disabled
+	cr.Spec.PodServices.Enabled = false
+	c.resetCalls()
+	err = ApplyIndexerPodServices(c, &cr)
+	if err != nil || cr.Status.PodServices != nil {
+		t.Errorf("ApplyIndexerPodServices() = %v, status %v; want nil, nil", err, cr.Status.PodServices)
+	}
+	c.checkCalls(t, "TestApplyIndexerPodServices(disable)", map[string][]mockFuncCall{
+		"Get":    serviceCalls[:1],
+		"Delete": serviceCalls[:1],
+	})
+}
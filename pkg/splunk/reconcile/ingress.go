@@ -0,0 +1,69 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplySplunkIngress creates or updates a Kubernetes Ingress for the Splunk Web and HTTP Event Collector endpoints of a
+// SplunkEnterprise resource. It does nothing if an ingress host is not configured.
+func ApplySplunkIngress(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.IngressSpec, instanceType enterprise.InstanceType) error {
+	if !enterprise.IsIngressConfigured(spec) {
+		return nil
+	}
+	return ApplyIngress(client, enterprise.GetSplunkIngress(cr, spec, instanceType))
+}
+
+// ApplyIngress creates or updates a Kubernetes Ingress
+func ApplyIngress(client ControllerClient, revised *networkingv1beta1.Ingress) error {
+	scopedLog := log.WithName("ApplyIngress").WithValues(
+		"name", revised.GetObjectMeta().GetName(),
+		"namespace", revised.GetObjectMeta().GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current networkingv1beta1.Ingress
+
+	err := client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return CreateResource(client, revised)
+	}
+
+	// check for changes in rules, tls and annotations (labels and annotations added by others are kept)
+	hasUpdates := resources.CompareByMarshall(current.Spec, revised.Spec)
+	if hasUpdates {
+		current.Spec = revised.Spec
+	}
+	if MergeServiceMetaUpdates(&current.ObjectMeta, &revised.ObjectMeta, current.GetObjectMeta().GetName()) {
+		hasUpdates = true
+	}
+	*revised = current // caller expects that object passed represents latest state
+
+	// only update if there are material differences
+	if hasUpdates {
+		scopedLog.Info("Updating existing Ingress")
+		return UpdateResource(client, revised)
+	}
+
+	scopedLog.Info("No update to existing Ingress")
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyIngress(t *testing.T) {
+	funcCalls := []mockFuncCall{{metaName: "*v1beta1.Ingress-test-splunk-stack1-standalone-ingress"}}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": funcCalls}
+	current := networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-standalone-ingress",
+			Namespace: "test",
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{Host: "splunk.example.com"}},
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Rules[0].Host = "splunk2.example.com"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		return ApplyIngress(c, cr.(*networkingv1beta1.Ingress))
+	}
+	reconcileTester(t, "TestApplyIngress", &current, revised, createCalls, updateCalls, reconcile)
+
+	// test updating annotations
+	revised = current.DeepCopy()
+	revised.ObjectMeta.Annotations = map[string]string{"kubernetes.io/ingress.class": "nginx"}
+	reconcileTester(t, "TestApplyIngress", &current, revised, createCalls, updateCalls, reconcile)
+}
+
+func TestApplySplunkIngress(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	// nothing to do without a host
+	c := newMockClient()
+	if err := ApplySplunkIngress(c, &cr, &cr.Spec.Ingress, enterprise.SplunkStandalone); err != nil {
+		t.Errorf("ApplySplunkIngress() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplySplunkIngress(no-host)", map[string][]mockFuncCall{})
+
+	// ingress is created when a host is configured
+	cr.Spec.Ingress = enterprisev1.IngressSpec{Host: "splunk.example.com", WebPath: "/", HECPath: "/services/collector"}
+	funcCalls := []mockFuncCall{{metaName: "*v1beta1.Ingress-test-splunk-stack1-standalone-ingress"}}
+	if err := ApplySplunkIngress(c, &cr, &cr.Spec.Ingress, enterprise.SplunkStandalone); err != nil {
+		t.Errorf("ApplySplunkIngress() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplySplunkIngress(create)", map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls})
+}
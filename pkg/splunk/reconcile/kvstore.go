@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+)
+
+const (
+	// interval used to refresh the KV store status of search head cluster members, once they are ready
+	kvStoreStatusInterval = time.Minute
+
+	// maximum time that the KV store of a search head cluster member may spend starting up or resyncing, before it is
+	// reported as stuck
+	kvStoreResyncTimeout = 30 * time.Minute
+)
+
+// replication statuses reported while the KV store of a search head cluster member is resyncing with the KV store captain
+var kvStoreResyncStatuses = map[string]bool{
+	"Initial sync": true,
+	"Recovering":   true,
+	"Rollback":     true,
+	"Startup":      true,
+}
+
+// updateKVStoreStatus for SearchHeadClusterPodManager updates the KV store state reported for each member using the REST
+// API. Errors are logged and the state of members that cannot be queried is left unchanged.
+func (mgr *SearchHeadClusterPodManager) updateKVStoreStatus(now time.Time) {
+	for n := range mgr.cr.Status.Members {
+		member := &mgr.cr.Status.Members[n]
+		info, err := mgr.getClient(int32(n)).GetKVStoreInfo()
+		if err != nil {
+			mgr.log.Error(err, "Unable to retrieve KV store status", "memberName", member.Name)
+			continue
+		}
+		setKVStoreStatus(&member.KVStore, info, now)
+	}
+}
+
+// setKVStoreStatus updates the KV store state reported for a search head cluster member, and its transition time if
+// the state has changed
+func setKVStoreStatus(status *enterprisev1.SearchHeadClusterMemberKVStoreStatus, info *splclient.KVStoreInfo, now time.Time) {
+	if status.Status != info.Status || status.ReplicationStatus != info.ReplicationStatus || status.LastTransitionTime.IsZero() {
+		status.LastTransitionTime = metav1.NewTime(now)
+	}
+	status.Status = info.Status
+	status.ReplicationStatus = info.ReplicationStatus
+}
+
+// checkKVStoreMembers returns an error describing the search head cluster members whose KV store is down, or has been
+// starting up or resyncing for longer than kvStoreResyncTimeout. Members whose KV store state is not known are ignored.
+func checkKVStoreMembers(members []enterprisev1.SearchHeadClusterMemberStatus, now time.Time) error {
+	var failed []string
+	for _, member := range members {
+		kvStore := member.KVStore
+		switch {
+		case kvStore.Status == "" || kvStore.Status == "disabled":
+			continue
+		case kvStore.Status == "failed" || kvStore.ReplicationStatus == "Down":
+			failed = append(failed, fmt.Sprintf("KV store is down on %s (status=%s, replicationStatus=%s)", member.Name, kvStore.Status, kvStore.ReplicationStatus))
+		case kvStore.Status == "ready" && !kvStoreResyncStatuses[kvStore.ReplicationStatus]:
+			continue
+		case now.Sub(kvStore.LastTransitionTime.Time) > kvStoreResyncTimeout:
+			failed = append(failed, fmt.Sprintf("KV store resync is stuck on %s (status=%s, replicationStatus=%s, since %s)", member.Name, kvStore.Status, kvStore.ReplicationStatus, kvStore.LastTransitionTime.UTC().Format(time.RFC3339)))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestSearchHeadClusterUpdateKVStoreStatus(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Status.Members = []enterprisev1.SearchHeadClusterMemberStatus{
+		{Name: "splunk-stack1-search-head-0"},
+		{Name: "splunk-stack1-search-head-1", KVStore: enterprisev1.SearchHeadClusterMemberKVStoreStatus{Status: "ready", ReplicationStatus: "KV store captain"}},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &SearchHeadClusterPodManager{
+		log:     log.WithName("TestSearchHeadClusterUpdateKVStoreStatus"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// state of members that cannot be queried is left unchanged
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-search-head-0.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/kvstore/status?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"status","content":{"current":{"replicationStatus":"Initial sync","status":"starting"}}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/kvstore/status?count=0&output_mode=json",
+		Status: 500,
+	})
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	mgr.updateKVStoreStatus(now)
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterUpdateKVStoreStatus")
+	want := enterprisev1.SearchHeadClusterMemberKVStoreStatus{Status: "starting", ReplicationStatus: "Initial sync", LastTransitionTime: metav1.NewTime(now)}
+	if got := cr.Status.Members[0].KVStore; got != want {
+		t.Errorf("updateKVStoreStatus() members[0].kvStore = %v; want %v", got, want)
+	}
+	if got := cr.Status.Members[1].KVStore.Status; got != "ready" {
+		t.Errorf("updateKVStoreStatus() members[1].kvStore.status = %s; want %s", got, "ready")
+	}
+}
+
+func TestSetKVStoreStatus(t *testing.T) {
+	status := enterprisev1.SearchHeadClusterMemberKVStoreStatus{}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// transition time is only changed when the state changes
+	setKVStoreStatus(&status, &splclient.KVStoreInfo{Status: "starting", ReplicationStatus: "Startup"}, now)
+	if !status.LastTransitionTime.Time.Equal(now) {
+		t.Errorf("setKVStoreStatus() lastTransitionTime = %v; want %v", status.LastTransitionTime, now)
+	}
+	setKVStoreStatus(&status, &splclient.KVStoreInfo{Status: "starting", ReplicationStatus: "Startup"}, now.Add(time.Minute))
+	if !status.LastTransitionTime.Time.Equal(now) {
+		t.Errorf("setKVStoreStatus() lastTransitionTime = %v; want %v", status.LastTransitionTime, now)
+	}
+	setKVStoreStatus(&status, &splclient.KVStoreInfo{Status: "ready", ReplicationStatus: "Non-captain KV store member"}, now.Add(2*time.Minute))
+	if !status.LastTransitionTime.Time.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("setKVStoreStatus() lastTransitionTime = %v; want %v", status.LastTransitionTime, now.Add(2*time.Minute))
+	}
+	if status.Status != "ready" || status.ReplicationStatus != "Non-captain KV store member" {
+		t.Errorf("setKVStoreStatus() status = %v; want ready, Non-captain KV store member", status)
+	}
+}
+
+func TestCheckKVStoreMembers(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	test := func(status, replicationStatus string, since time.Duration, wantErr bool) {
+		members := []enterprisev1.SearchHeadClusterMemberStatus{{
+			Name: "splunk-stack1-search-head-0",
+			KVStore: enterprisev1.SearchHeadClusterMemberKVStoreStatus{
+				Status:             status,
+				ReplicationStatus:  replicationStatus,
+				LastTransitionTime: metav1.NewTime(now.Add(-since)),
+			},
+		}}
+		err := checkKVStoreMembers(members, now)
+		if wantErr && err == nil {
+			t.Errorf("checkKVStoreMembers(%s, %s, %s) returned nil; want error", status, replicationStatus, since)
+		} else if !wantErr && err != nil {
+			t.Errorf("checkKVStoreMembers(%s, %s, %s) returned %v; want nil", status, replicationStatus, since, err)
+		}
+	}
+
+	test("", "", time.Hour, false)
+	test("disabled", "", time.Hour, false)
+	test("ready", "KV store captain", time.Hour, false)
+	test("ready", "Non-captain KV store member", time.Hour, false)
+	test("failed", "Non-captain KV store member", time.Minute, true)
+	test("ready", "Down", time.Minute, true)
+	test("starting", "Startup", time.Minute, false)
+	test("starting", "Startup", time.Hour, true)
+	test("ready", "Initial sync", 10*time.Minute, false)
+	test("ready", "Initial sync", time.Hour, true)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// BackupKVStore for SearchHeadClusterPodManager backs up the KV store of the search head cluster before its members are
+// upgraded to a new image, and records the location of the backup in status. It returns true if the upgrade must wait for
+// a backup that is still in progress. Nothing is done if KV store backups are not enabled, if the members do not exist yet,
+// or if they are already using the image requested.
+func (mgr *SearchHeadClusterPodManager) BackupKVStore(c ControllerClient, now time.Time) (bool, error) {
+	spec := &mgr.cr.Spec.KVStoreBackup
+	status := &mgr.cr.Status.KVStoreBackup
+	if !enterprise.IsKVStoreBackupEnabled(spec) {
+		return false, nil
+	}
+
+	image := enterprise.GetSplunkImage(mgr.cr.Spec.Image)
+	if status.Image == image && status.Completed {
+		return false, nil
+	}
+
+	// backups are only needed if members are running another image
+	var statefulSet appsv1.StatefulSet
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, mgr.getIdentifier())}
+	err := c.Get(context.TODO(), namespacedName, &statefulSet)
+	if err != nil {
+		return false, nil
+	}
+	for _, container := range statefulSet.Spec.Template.Spec.Containers {
+		if container.Name == "splunk" && container.Image == image {
+			return false, nil
+		}
+	}
+
+	scopedLog := mgr.log.WithName("BackupKVStore")
+	if status.Image != image {
+		backupName := enterprise.GetKVStoreBackupName(mgr.cr.GetIdentifier(), now)
+		podName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), 0)
+		*status = enterprisev1.SearchHeadClusterKVStoreBackupStatus{
+			Image:     image,
+			Name:      backupName,
+			Location:  enterprise.GetKVStoreBackupLocation(spec, backupName, podName),
+			StartTime: metav1.NewTime(now),
+		}
+
+		// the KV store is replicated to all members, so backing up the first one is enough
+		scopedLog.Info("Backing up KV store before upgrade", "backupName", backupName, "location", status.Location, "image", image)
+		if spec.Target == enterprise.KVStoreBackupTargetS3 {
+			err = mgr.uploadKVStoreBackup(c, backupName)
+		} else {
+			err = mgr.getClient(0).CreateKVStoreBackup(backupName)
+		}
+		if err != nil {
+			// start over on the next attempt
+			status.Image = ""
+			return true, fmt.Errorf("Unable to back up KV store before upgrade: %v", err)
+		}
+	}
+
+	// archives are created asynchronously on the var volume
+	if spec.Target != enterprise.KVStoreBackupTargetS3 {
+		info, err := mgr.getClient(0).GetKVStoreInfo()
+		if err != nil {
+			return true, err
+		}
+		if info.BackupRestoreStatus != "Ready" {
+			scopedLog.Info("Waiting for KV store backup to complete", "backupName", status.Name, "backupRestoreStatus", info.BackupRestoreStatus)
+			return true, nil
+		}
+	}
+
+	status.Completed = true
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "KVStoreBackedUp", "KV store backed up to %s before upgrading to %s", status.Location, image)
+	return false, nil
+}
+
+// uploadKVStoreBackup for SearchHeadClusterPodManager uploads the documents of every KV store collection on the first
+// member to an S3 compatible bucket, as one JSON array per collection
+func (mgr *SearchHeadClusterPodManager) uploadKVStoreBackup(c ControllerClient, backupName string) error {
+	spec := &mgr.cr.Spec.KVStoreBackup
+	var secret corev1.Secret
+	if spec.S3.SecretRef != "" {
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: spec.S3.SecretRef}
+		err := c.Get(context.TODO(), namespacedName, &secret)
+		if err != nil {
+			return fmt.Errorf("unable to get secret for KV store backups: %v", err)
+		}
+	}
+	s3Client := mgr.newS3Client(spec.S3.Endpoint, spec.S3.Region, string(secret.Data["s3_access_key"]), string(secret.Data["s3_secret_key"]))
+
+	source := mgr.getClient(0)
+	collections, err := source.GetKVStoreCollections()
+	if err != nil {
+		return fmt.Errorf("unable to get KV store collections: %v", err)
+	}
+	for _, collection := range collections {
+		documents := []json.RawMessage{}
+		for skip := 0; ; skip += kvStoreSyncBatchSize {
+			batch, err := source.GetKVStoreCollectionData(collection.App, collection.Name, skip, kvStoreSyncBatchSize)
+			if err != nil {
+				return fmt.Errorf("unable to get documents of KV store collection %s/%s: %v", collection.App, collection.Name, err)
+			}
+			documents = append(documents, batch...)
+			if len(batch) < kvStoreSyncBatchSize {
+				break
+			}
+		}
+		data, err := json.Marshal(documents)
+		if err != nil {
+			return err
+		}
+		key := enterprise.GetKVStoreBackupObjectName(spec, backupName, collection.App, collection.Name)
+		err = s3Client.PutObject(spec.S3.Bucket, key, data)
+		if err != nil {
+			return fmt.Errorf("unable to upload documents of KV store collection %s/%s: %v", collection.App, collection.Name, err)
+		}
+	}
+	return nil
+}
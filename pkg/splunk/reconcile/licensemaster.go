@@ -16,22 +16,40 @@ package reconcile
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 )
 
 // ApplyLicenseMaster reconciles the state for the Splunk Enterprise license master.
 func ApplyLicenseMaster(client ControllerClient, cr *enterprisev1.LicenseMaster) (reconcile.Result, error) {
 
-	// unless modified, reconcile for this object will be requeued after 5 seconds
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyLicenseMaster(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
 	result := reconcile.Result{
 		Requeue:      true,
-		RequeueAfter: time.Second * 5,
+		RequeueAfter: getRequeueInterval(cr),
 	}
+	scopedLog := log.WithName("ApplyLicenseMaster").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
 
 	// validate and updates defaults for CR
 	err := enterprise.ValidateLicenseMasterSpec(&cr.Spec)
@@ -40,15 +58,25 @@ func ApplyLicenseMaster(client ControllerClient, cr *enterprisev1.LicenseMaster)
 	}
 
 	// updates status after function completes
+	oldPhase := cr.Status.Phase
 	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
 	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
 		client.Status().Update(context.TODO(), cr)
 	}()
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
-		terminating, err := CheckSplunkDeletion(cr, client)
-		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
 			cr.Status.Phase = enterprisev1.PhaseTerminating
 		} else {
 			result.Requeue = false
@@ -56,8 +84,44 @@ func ApplyLicenseMaster(client ControllerClient, cr *enterprisev1.LicenseMaster)
 		return result, err
 	}
 
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// upgrade to the image requested using the splunk-image annotation, once linked resources have been upgraded in order
+	upgradePending, err := ApplyUpgradeImage(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.GetSplunkStatefulsetName(enterprise.SplunkLicenseMaster, cr.GetIdentifier()))
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkLicenseMaster)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update general config resources
-	_, err = ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkLicenseMaster)
+	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkLicenseMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkLicenseMaster, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkLicenseMaster))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkLicenseMaster)
 	if err != nil {
 		return result, err
 	}
@@ -68,21 +132,105 @@ func ApplyLicenseMaster(client ControllerClient, cr *enterprisev1.LicenseMaster)
 		return result, err
 	}
 
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkLicenseMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkLicenseMaster)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkLicenseMaster, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkLicenseMaster, false))
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkLicenseMaster)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update statefulset
 	statefulSet, err := enterprise.GetLicenseMasterStatefulSet(cr)
 	if err != nil {
 		return result, err
 	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
 	mgr := DefaultStatefulSetPodManager{}
 	phase, err := mgr.Update(client, statefulSet, 1)
 	if err != nil {
 		return result, err
 	}
 	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if cr.Status.Phase == enterprisev1.PhaseReady || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkLicenseMaster, cr.GetIdentifier(), 1, false), ","))
+		if err != nil {
+			return result, err
+		}
+	}
 
-	// no need to requeue if everything is ready
+	// reload changes to conf files once the license master is ready, if configUpdateStrategy is reload
+	reloaded := true
 	if cr.Status.Phase == enterprisev1.PhaseReady {
+		reloadManager := ConfigReloadManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		reloaded, err = reloadManager.Apply(strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkLicenseMaster, cr.GetIdentifier(), 1, false), ","), confFiles, &cr.Status.ConfFilesChecksum)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// configure license pools and report their usage once the license master is ready
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		poolManager := LicensePoolManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		cr.Status.LicensePools, err = poolManager.Apply()
+		if err != nil {
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+
+		// report the usage of all license stacks and pools, if enabled
+		poolManager.CollectUsage(&cr.Status.LicenseUsage)
+	}
+
+	// no need to requeue if everything is ready, no upgrades are waiting for linked resources, and no conf files are waiting
+	// to be reloaded
+	if cr.Status.Phase == enterprisev1.PhaseReady && !upgradePending && reloaded {
 		result.Requeue = false
 	}
+
+	// keep refreshing license usage, if enabled
+	if cr.Spec.LicenseUsage.Enabled && (!result.Requeue || result.RequeueAfter > licenseUsageInterval) {
+		result.Requeue = true
+		result.RequeueAfter = licenseUsageInterval
+	}
 	return result, nil
 }
+
+// getExternalLicenseMasterSecret retrieves the Secret referenced by licenseMasterSecretRef, which must include the pass4SymmKey
+// that license slaves use to connect to an external license master
+func getExternalLicenseMasterSecret(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec) (*corev1.Secret, error) {
+	name := spec.LicenseMasterSecretRef
+	var secret corev1.Secret
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: cr.GetNamespace(), Name: name}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get Secret %s for external license master: %v", name, err)
+	}
+	if len(secret.Data["pass4SymmKey"]) == 0 {
+		return nil, fmt.Errorf("Secret %s for external license master does not include pass4SymmKey", name)
+	}
+	return &secret, nil
+}
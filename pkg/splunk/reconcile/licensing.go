@@ -0,0 +1,176 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// interval used to refresh license usage, when license usage reporting is enabled
+const licenseUsageInterval = time.Minute
+
+// LicensePoolManager is used to configure license pools on a Splunk Enterprise license master
+type LicensePoolManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.LicenseMaster
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply creates or updates each license pool in the license master's spec, and returns the usage of those pools.
+// Pools that are not in the spec (such as the default pools created by Splunk) are left unchanged.
+// It does nothing if no license pools are configured.
+func (mgr *LicensePoolManager) Apply() ([]enterprisev1.LicensePoolStatus, error) {
+	if len(mgr.cr.Spec.LicensePools) == 0 {
+		return nil, nil
+	}
+
+	c := mgr.getLicenseMasterClient()
+	stacks, err := c.GetLicenseStacks()
+	if err != nil {
+		return nil, err
+	}
+	pools, err := c.GetLicensePools()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	for _, pool := range mgr.cr.Spec.LicensePools {
+		stack, ok := stacks[pool.StackID]
+		if !ok {
+			return nil, fmt.Errorf("License stack %s is not available for license pool %s", pool.StackID, pool.Name)
+		}
+		current, ok := pools[pool.Name]
+		if !ok {
+			mgr.log.Info("Creating license pool", "pool", pool.Name, "stack", pool.StackID, "quota", pool.Quota)
+			err = c.CreateLicensePool(pool.Name, pool.StackID, pool.Quota, pool.Slaves, pool.Description)
+		} else if current.StackID != pool.StackID {
+			return nil, fmt.Errorf("License pool %s uses stack %s; the stack of an existing pool cannot be changed to %s", pool.Name, current.StackID, pool.StackID)
+		} else if !isLicensePoolCurrent(&pool, &current, stack.Quota) {
+			mgr.log.Info("Updating license pool", "pool", pool.Name, "quota", pool.Quota)
+			err = c.UpdateLicensePool(pool.Name, pool.Quota, pool.Slaves, pool.Description)
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	// refresh usage if any pools were changed
+	if changed {
+		pools, err = c.GetLicensePools()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := []enterprisev1.LicensePoolStatus{}
+	for _, pool := range mgr.cr.Spec.LicensePools {
+		current := pools[pool.Name]
+		statuses = append(statuses, enterprisev1.LicensePoolStatus{
+			Name:      pool.Name,
+			StackID:   pool.StackID,
+			Quota:     current.EffectiveQuota,
+			UsedBytes: current.UsedBytes,
+		})
+	}
+	return statuses, nil
+}
+
+// CollectUsage updates the license usage reported in status with the usage of every license stack and pool on the
+// license master, if license usage reporting is enabled. The usage of a stack is the total used by all of its pools.
+// Errors are logged and status is left unchanged, so that the most recent usage is kept while the license master
+// cannot be queried.
+func (mgr *LicensePoolManager) CollectUsage(status *enterprisev1.LicenseUsageStatus) {
+	if !mgr.cr.Spec.LicenseUsage.Enabled {
+		*status = enterprisev1.LicenseUsageStatus{}
+		return
+	}
+
+	c := mgr.getLicenseMasterClient()
+	stacks, err := c.GetLicenseStacks()
+	if err != nil {
+		mgr.log.Error(err, "Unable to get license stacks")
+		return
+	}
+	pools, err := c.GetLicensePools()
+	if err != nil {
+		mgr.log.Error(err, "Unable to get license pools")
+		return
+	}
+
+	usedBytes := make(map[string]int64)
+	poolStatuses := []enterprisev1.LicensePoolStatus{}
+	for name, pool := range pools {
+		usedBytes[pool.StackID] += pool.UsedBytes
+		poolStatuses = append(poolStatuses, enterprisev1.LicensePoolStatus{
+			Name:      name,
+			StackID:   pool.StackID,
+			Quota:     pool.EffectiveQuota,
+			UsedBytes: pool.UsedBytes,
+		})
+	}
+	sort.Slice(poolStatuses, func(i, j int) bool { return poolStatuses[i].Name < poolStatuses[j].Name })
+
+	stackStatuses := []enterprisev1.LicenseStackStatus{}
+	for name, stack := range stacks {
+		stackStatuses = append(stackStatuses, enterprisev1.LicenseStackStatus{
+			Name:      name,
+			Type:      stack.Type,
+			Quota:     stack.Quota,
+			UsedBytes: usedBytes[name],
+		})
+	}
+	sort.Slice(stackStatuses, func(i, j int) bool { return stackStatuses[i].Name < stackStatuses[j].Name })
+
+	*status = enterprisev1.LicenseUsageStatus{Stacks: stackStatuses, Pools: poolStatuses, LastUpdated: metav1.Now()}
+}
+
+// isLicensePoolCurrent returns true if a license pool on the license master matches its spec, where stackQuota
+// is the volume available in its license stack
+func isLicensePoolCurrent(pool *enterprisev1.LicensePoolSpec, current *splclient.LicensePoolInfo, stackQuota int64) bool {
+	if pool.Description != current.Description {
+		return false
+	}
+	if enterprise.GetLicensePoolQuotaBytes(pool.Quota, stackQuota) != current.EffectiveQuota {
+		return false
+	}
+	want := append([]string{}, pool.Slaves...)
+	got := append([]string{}, current.Slaves...)
+	sort.Strings(want)
+	sort.Strings(got)
+	return strings.Join(want, ",") == strings.Join(got, ",")
+}
+
+// getLicenseMasterClient for LicensePoolManager returns a SplunkClient for the license master
+func (mgr *LicensePoolManager) getLicenseMasterClient() *splclient.SplunkClient {
+	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkLicenseMaster, mgr.cr.GetIdentifier(), false))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
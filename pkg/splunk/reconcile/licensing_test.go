@@ -0,0 +1,223 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestLicensePoolManagerApply(t *testing.T) {
+	cr := enterprisev1.LicenseMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := &LicensePoolManager{
+		log:     log.WithName("TestLicensePoolManagerApply"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// nothing to do without license pools
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	pools, err := mgr.Apply()
+	if pools != nil || err != nil {
+		t.Errorf("LicensePoolManager.Apply() = %v, %v; want nil, nil", pools, err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerApply(no-pools)")
+
+	// new pools are created and changed pools are updated
+	cr.Spec.LicensePools = []enterprisev1.LicensePoolSpec{
+		{Name: "pool1", StackID: "enterprise", Quota: "512MB", Slaves: []string{"*"}},
+		{Name: "pool2", StackID: "enterprise", Quota: "1GB", Slaves: []string{"*"}},
+		{Name: "pool3", StackID: "enterprise", Quota: "MAX", Slaves: []string{"*"}},
+	}
+	baseURL := "https://splunk-stack1-license-master-service.test.svc.cluster.local:8089"
+	stacksHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    baseURL + "/services/licenser/stacks?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"enterprise","content":{"label":"Splunk Enterprise","quota":10737418240,"type":"enterprise"}}]}`,
+	}
+	poolsHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    baseURL + "/services/licenser/pools?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"pool1","content":{"description":"","stack_id":"enterprise","effective_quota":536870912,"used_bytes":1024,"slaves":["*"]}},{"name":"pool3","content":{"description":"","stack_id":"enterprise","effective_quota":104857600,"used_bytes":2048,"slaves":["*"]}}]}`,
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(stacksHandler, poolsHandler, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    baseURL + "/services/licenser/pools",
+		Status: 201,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    baseURL + "/services/licenser/pools/pool3",
+		Status: 200,
+	}, poolsHandler)
+	pools, err = mgr.Apply()
+	if err != nil {
+		t.Errorf("LicensePoolManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerApply(changed)")
+	want := []enterprisev1.LicensePoolStatus{
+		{Name: "pool1", StackID: "enterprise", Quota: 536870912, UsedBytes: 1024},
+		{Name: "pool2", StackID: "enterprise"},
+		{Name: "pool3", StackID: "enterprise", Quota: 104857600, UsedBytes: 2048},
+	}
+	if len(pools) != len(want) {
+		t.Fatalf("LicensePoolManager.Apply() pools = %v; want %v", pools, want)
+	}
+	for n := range want {
+		if pools[n] != want[n] {
+			t.Errorf("LicensePoolManager.Apply() pools[%d] = %v; want %v", n, pools[n], want[n])
+		}
+	}
+
+	// pools are not updated if they have not changed
+	cr.Spec.LicensePools = cr.Spec.LicensePools[:1]
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(stacksHandler, poolsHandler)
+	if _, err = mgr.Apply(); err != nil {
+		t.Errorf("LicensePoolManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerApply(unchanged)")
+
+	// license stacks must be available
+	cr.Spec.LicensePools[0].StackID = "download-trial"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(stacksHandler, poolsHandler)
+	if _, err = mgr.Apply(); err == nil {
+		t.Errorf("LicensePoolManager.Apply() returned nil; want error for missing license stack")
+	}
+}
+
+func TestIsLicensePoolCurrent(t *testing.T) {
+	pool := enterprisev1.LicensePoolSpec{Name: "pool1", StackID: "enterprise", Quota: "MAX", Slaves: []string{"guid1", "guid2"}}
+	test := func(current splclient.LicensePoolInfo, want bool) {
+		if got := isLicensePoolCurrent(&pool, &current, 1000); got != want {
+			t.Errorf("isLicensePoolCurrent(%v) = %t; want %t", current, got, want)
+		}
+	}
+
+	test(splclient.LicensePoolInfo{StackID: "enterprise", EffectiveQuota: 1000, Slaves: []string{"guid2", "guid1"}}, true)
+	test(splclient.LicensePoolInfo{StackID: "enterprise", EffectiveQuota: 500, Slaves: []string{"guid1", "guid2"}}, false)
+	test(splclient.LicensePoolInfo{StackID: "enterprise", EffectiveQuota: 1000, Slaves: []string{"guid1"}}, false)
+	test(splclient.LicensePoolInfo{StackID: "enterprise", EffectiveQuota: 1000, Slaves: []string{"guid1", "guid2"}, Description: "test"}, false)
+}
+
+func TestLicensePoolManagerCollectUsage(t *testing.T) {
+	cr := enterprisev1.LicenseMaster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := &LicensePoolManager{
+		log:     log.WithName("TestLicensePoolManagerCollectUsage"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// usage is cleared if reporting is not enabled
+	status := enterprisev1.LicenseUsageStatus{Pools: []enterprisev1.LicensePoolStatus{{Name: "pool1"}}}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mgr.CollectUsage(&status)
+	if status.Pools != nil || !status.LastUpdated.IsZero() {
+		t.Errorf("LicensePoolManager.CollectUsage() status = %v; want empty", status)
+	}
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerCollectUsage(disabled)")
+
+	// usage of every stack and pool is reported
+	cr.Spec.LicenseUsage.Enabled = true
+	baseURL := "https://splunk-stack1-license-master-service.test.svc.cluster.local:8089"
+	stacksHandler := spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    baseURL + "/services/licenser/stacks?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"enterprise","content":{"label":"Splunk Enterprise","quota":10737418240,"type":"enterprise"}},{"name":"forwarder","content":{"label":"Splunk Forwarder","quota":1048576,"type":"forwarder"}}]}`,
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(stacksHandler, spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    baseURL + "/services/licenser/pools?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"pool2","content":{"stack_id":"enterprise","effective_quota":536870912,"used_bytes":1024}},{"name":"pool1","content":{"stack_id":"enterprise","effective_quota":104857600,"used_bytes":2048}},{"name":"auto_generated_pool_forwarder","content":{"stack_id":"forwarder","effective_quota":1048576,"used_bytes":0}}]}`,
+	})
+	mgr.CollectUsage(&status)
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerCollectUsage(enabled)")
+	wantStacks := []enterprisev1.LicenseStackStatus{
+		{Name: "enterprise", Type: "enterprise", Quota: 10737418240, UsedBytes: 3072},
+		{Name: "forwarder", Type: "forwarder", Quota: 1048576},
+	}
+	wantPools := []enterprisev1.LicensePoolStatus{
+		{Name: "auto_generated_pool_forwarder", StackID: "forwarder", Quota: 1048576},
+		{Name: "pool1", StackID: "enterprise", Quota: 104857600, UsedBytes: 2048},
+		{Name: "pool2", StackID: "enterprise", Quota: 536870912, UsedBytes: 1024},
+	}
+	if !reflect.DeepEqual(status.Stacks, wantStacks) {
+		t.Errorf("LicensePoolManager.CollectUsage() stacks = %v; want %v", status.Stacks, wantStacks)
+	}
+	if !reflect.DeepEqual(status.Pools, wantPools) {
+		t.Errorf("LicensePoolManager.CollectUsage() pools = %v; want %v", status.Pools, wantPools)
+	}
+	if status.LastUpdated.IsZero() {
+		t.Errorf("LicensePoolManager.CollectUsage() did not set lastUpdated")
+	}
+
+	// most recent usage is kept if the license master cannot be queried
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    stacksHandler.URL,
+		Status: 500,
+	})
+	mgr.CollectUsage(&status)
+	mockSplunkClient.CheckRequests(t, "TestLicensePoolManagerCollectUsage(error)")
+	if !reflect.DeepEqual(status.Pools, wantPools) {
+		t.Errorf("LicensePoolManager.CollectUsage() pools = %v; want %v", status.Pools, wantPools)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// interval used to refresh load metrics, when they are enabled
+const loadMetricsInterval = 30 * time.Second
+
+// collectLoadMetrics updates the load metrics reported in status using the REST API of each host, if they are enabled.
+// Search concurrency is the total number of searches running on all search heads, and the indexing queue fill percentage
+// is the average for all indexers. Hosts that cannot be queried (for example, while they are starting up) are not counted,
+// and status is left unchanged if none of them can be queried.
+func collectLoadMetrics(spec *enterprisev1.LoadMetricsSpec, instanceType enterprise.InstanceType, hosts []string, secrets *corev1.Secret, newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient, status *enterprisev1.LoadMetricsStatus) {
+	if !spec.Enabled {
+		*status = enterprisev1.LoadMetricsStatus{}
+		return
+	}
+
+	scopedLog := log.WithName("collectLoadMetrics").WithValues("instanceType", instanceType)
+	var instances, searches int32
+	var fillRatio float64
+	for _, host := range hosts {
+		c := newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", enterprise.GetAppliedAdminPassword(secrets))
+		if instanceType == enterprise.SplunkIndexer {
+			ratio, err := c.GetIndexQueueFillRatio()
+			if err != nil {
+				scopedLog.Error(err, "Unable to get index queue fill ratio", "host", host)
+				continue
+			}
+			fillRatio += ratio
+		} else {
+			n, err := c.GetRunningSearchCount()
+			if err != nil {
+				scopedLog.Error(err, "Unable to get running searches", "host", host)
+				continue
+			}
+			searches += int32(n)
+		}
+		instances++
+	}
+	if instances == 0 {
+		return
+	}
+
+	*status = enterprisev1.LoadMetricsStatus{Instances: instances}
+	if instanceType == enterprise.SplunkIndexer {
+		status.IndexingQueueFillPercent = int32(math.Round(fillRatio / float64(instances) * 100))
+	} else {
+		status.SearchConcurrency = searches
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestCollectLoadMetrics(t *testing.T) {
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	newSplunkClient := func(managementURI, username, password string) *splclient.SplunkClient {
+		c := splclient.NewSplunkClient(managementURI, username, password)
+		c.Client = mockSplunkClient
+		return c
+	}
+	spec := enterprisev1.LoadMetricsSpec{Enabled: true}
+	status := enterprisev1.LoadMetricsStatus{}
+
+	test := func(testMethod string, instanceType enterprise.InstanceType, hosts []string, want enterprisev1.LoadMetricsStatus) {
+		collectLoadMetrics(&spec, instanceType, hosts, secrets, newSplunkClient, &status)
+		if status != want {
+			t.Errorf("%s status = %v; want %v", testMethod, status, want)
+		}
+		mockSplunkClient.CheckRequests(t, testMethod)
+	}
+
+	// searches running on all search heads are counted, ignoring those that cannot be queried
+	shHosts := []string{
+		"splunk-stack1-search-head-0.splunk-stack1-search-head-headless.test.svc.cluster.local",
+		"splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local",
+		"splunk-stack1-search-head-2.splunk-stack1-search-head-headless.test.svc.cluster.local",
+	}
+	searchJobs := func(host string, status int, body string) spltest.MockHTTPHandler {
+		return spltest.MockHTTPHandler{Method: "GET", URL: fmt.Sprintf("https://%s:8089/services/search/jobs?count=0&output_mode=json", host), Status: status, Body: body}
+	}
+	mockSplunkClient.AddHandlers(
+		searchJobs(shHosts[0], 200, `{"entry":[{"content":{"dispatchState":"RUNNING"}},{"content":{"dispatchState":"DONE"}}]}`),
+		searchJobs(shHosts[1], 503, ""),
+		searchJobs(shHosts[2], 200, `{"entry":[{"content":{"dispatchState":"RUNNING"}},{"content":{"dispatchState":"RUNNING"}}]}`),
+	)
+	test("TestCollectLoadMetrics(search-heads)", enterprise.SplunkSearchHead, shHosts, enterprisev1.LoadMetricsStatus{SearchConcurrency: 3, Instances: 2})
+
+	// status is unchanged if no instances can be queried
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(searchJobs(shHosts[0], 503, ""))
+	test("TestCollectLoadMetrics(unavailable)", enterprise.SplunkSearchHead, shHosts[:1], enterprisev1.LoadMetricsStatus{SearchConcurrency: 3, Instances: 2})
+
+	// index queue fill ratios are averaged for all indexers
+	idxHosts := []string{
+		"splunk-stack1-indexer-0.splunk-stack1-indexer-headless.test.svc.cluster.local",
+		"splunk-stack1-indexer-1.splunk-stack1-indexer-headless.test.svc.cluster.local",
+	}
+	queues := func(host string, currentSize int) spltest.MockHTTPHandler {
+		return spltest.MockHTTPHandler{Method: "GET", URL: fmt.Sprintf("https://%s:8089/services/server/introspection/queues?count=0&output_mode=json", host), Status: 200,
+			Body: fmt.Sprintf(`{"entry":[{"name":"indexqueue","content":{"current_size_bytes":%d,"max_size_bytes":1000}}]}`, currentSize)}
+	}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(queues(idxHosts[0], 100), queues(idxHosts[1], 400))
+	test("TestCollectLoadMetrics(indexers)", enterprise.SplunkIndexer, idxHosts, enterprisev1.LoadMetricsStatus{IndexingQueueFillPercent: 25, Instances: 2})
+
+	// status is cleared if load metrics are disabled
+	spec.Enabled = false
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	test("TestCollectLoadMetrics(disabled)", enterprise.SplunkIndexer, idxHosts, enterprisev1.LoadMetricsStatus{})
+}
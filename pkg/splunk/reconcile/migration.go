@@ -0,0 +1,112 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyStandaloneMigration tracks the migration of standalone instances into an indexer cluster in status, if one has been
+// requested. It returns the indexer cluster once the instances should be converted into its peers, or nil while they remain
+// standalone instances. Migrations start once the indexer cluster is ready, and cannot be changed or removed after that,
+// since the instances have already joined its cluster master.
+func ApplyStandaloneMigration(client ControllerClient, cr *enterprisev1.Standalone) (*enterprisev1.IndexerCluster, error) {
+	status := cr.Status.Migration
+	started := status != nil && status.Phase != enterprisev1.PhasePending
+	if !enterprise.IsStandaloneMigrationConfigured(&cr.Spec) {
+		if started {
+			return nil, fmt.Errorf("Migration into IndexerCluster %s has already started; it cannot be removed", status.IndexerCluster)
+		}
+		cr.Status.Migration = nil
+		return nil, nil
+	}
+
+	ref := cr.Spec.Migration.IndexerClusterRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+	target := fmt.Sprintf("%s/%s", namespace, ref.Name)
+	if started && status.IndexerCluster != target {
+		return nil, fmt.Errorf("Migration into IndexerCluster %s has already started; it cannot be changed to %s", status.IndexerCluster, target)
+	}
+	if !started {
+		status = &enterprisev1.StandaloneMigrationStatus{IndexerCluster: target, Phase: enterprisev1.PhasePending}
+		cr.Status.Migration = status
+	}
+
+	var idxc enterprisev1.IndexerCluster
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: ref.Name}, &idxc)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get IndexerCluster %s: %v", target, err)
+	}
+	if err = enterprise.ValidateStandaloneMigrationTarget(&idxc); err != nil {
+		return nil, err
+	}
+
+	if status.Phase == enterprisev1.PhasePending {
+		if idxc.Status.Phase != enterprisev1.PhaseReady {
+			scopedLog := log.WithName("ApplyStandaloneMigration").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+			scopedLog.Info("Waiting for IndexerCluster to be ready before migrating", "indexerCluster", target)
+			return nil, nil
+		}
+		status.Phase = enterprisev1.PhaseUpdating
+		recordEvent(cr, corev1.EventTypeNormal, "MigrationStarted", "Converting standalone instances into peers of IndexerCluster %s", target)
+	}
+	return &idxc, nil
+}
+
+// verifyStandaloneMigration updates the standalone instances reported as peers in the migration status, using the cluster
+// master of the indexer cluster that they are converted into peers of. The migration is complete once all of them are up.
+func verifyStandaloneMigration(client ControllerClient, cr *enterprisev1.Standalone, idxc *enterprisev1.IndexerCluster, newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient) error {
+	status := cr.Status.Migration
+	ref, instanceType := enterprise.GetIndexerClusterMasterRef(idxc)
+
+	var secrets corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: ref.Namespace, Name: enterprise.GetSplunkSecretsName(ref.Name, instanceType)}
+	err := client.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return fmt.Errorf("Unable to get secrets for the cluster master of IndexerCluster %s: %v", status.IndexerCluster, err)
+	}
+
+	fqdnName := resources.GetServiceFQDN(ref.Namespace, enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, ref.Name, false))
+	c := newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(&secrets))
+	peers, err := c.GetClusterMasterPeers()
+	if err != nil {
+		return err
+	}
+
+	status.Peers = []string{}
+	for n := int32(0); n < cr.Spec.Replicas; n++ {
+		peerName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkStandalone, cr.GetIdentifier(), n)
+		if peerInfo, ok := peers[peerName]; ok && peerInfo.Status == "Up" {
+			status.Peers = append(status.Peers, peerName)
+		}
+	}
+	if status.Phase != enterprisev1.PhaseReady && int32(len(status.Peers)) == cr.Spec.Replicas {
+		status.Phase = enterprisev1.PhaseReady
+		recordEvent(cr, corev1.EventTypeNormal, "MigrationCompleted", "All standalone instances are up as peers of IndexerCluster %s", status.IndexerCluster)
+	}
+	return nil
+}
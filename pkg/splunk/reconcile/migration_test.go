@@ -0,0 +1,170 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestApplyStandaloneMigration(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "idxc",
+			Namespace: "test",
+		},
+	}
+	c := newMockClient()
+
+	test := func(testname string, wantTarget bool, wantErr bool, wantStatus *enterprisev1.StandaloneMigrationStatus) {
+		target, err := ApplyStandaloneMigration(c, &cr)
+		if wantErr && err == nil {
+			t.Errorf("%s: ApplyStandaloneMigration() returned nil; want error", testname)
+		} else if !wantErr && err != nil {
+			t.Errorf("%s: ApplyStandaloneMigration() returned %v; want nil", testname, err)
+		}
+		if (target != nil) != wantTarget {
+			t.Errorf("%s: ApplyStandaloneMigration() target = %v; want %t", testname, target, wantTarget)
+		}
+		if !reflect.DeepEqual(cr.Status.Migration, wantStatus) {
+			t.Errorf("%s: ApplyStandaloneMigration() status.migration = %v; want %v", testname, cr.Status.Migration, wantStatus)
+		}
+	}
+
+	// nothing to do if no migration has been requested
+	test("TestApplyStandaloneMigration(none)", false, false, nil)
+	c.checkCalls(t, "TestApplyStandaloneMigration(none)", map[string][]mockFuncCall{})
+
+	// migration is pending until the indexer cluster exists and is ready
+	cr.Spec.Migration.IndexerClusterRef.Name = "idxc"
+	pending := &enterprisev1.StandaloneMigrationStatus{IndexerCluster: "test/idxc", Phase: enterprisev1.PhasePending}
+	test("TestApplyStandaloneMigration(missing)", false, true, pending)
+	c.state[getStateKey(&idxc)] = &idxc
+	test("TestApplyStandaloneMigration(pending)", false, false, pending)
+	c.checkCalls(t, "TestApplyStandaloneMigration(pending)", map[string][]mockFuncCall{
+		"Get": {{metaName: "*v1beta1.IndexerCluster-test-idxc"}, {metaName: "*v1beta1.IndexerCluster-test-idxc"}},
+	})
+	checkEvents(t, "TestApplyStandaloneMigration(pending)", recorder)
+
+	// the target can still be changed or removed while pending
+	cr.Spec.Migration.IndexerClusterRef.Name = ""
+	test("TestApplyStandaloneMigration(cancelled)", false, false, nil)
+	cr.Spec.Migration.IndexerClusterRef.Name = "idxc"
+
+	// migration starts once the indexer cluster is ready
+	idxc.Status.Phase = enterprisev1.PhaseReady
+	updating := &enterprisev1.StandaloneMigrationStatus{IndexerCluster: "test/idxc", Phase: enterprisev1.PhaseUpdating}
+	test("TestApplyStandaloneMigration(started)", true, false, updating)
+	checkEvents(t, "TestApplyStandaloneMigration(started)", recorder, "Normal MigrationStarted Converting standalone instances into peers of IndexerCluster test/idxc")
+	test("TestApplyStandaloneMigration(updating)", true, false, updating)
+	checkEvents(t, "TestApplyStandaloneMigration(updating)", recorder)
+
+	// the target cannot be changed or removed after that
+	cr.Spec.Migration.IndexerClusterRef.Namespace = "other"
+	test("TestApplyStandaloneMigration(changed)", false, true, updating)
+	cr.Spec.Migration.IndexerClusterRef = corev1.ObjectReference{}
+	test("TestApplyStandaloneMigration(removed)", false, true, updating)
+
+	// indexer clusters that peers cannot join are rejected
+	cr.Spec.Migration.IndexerClusterRef.Name = "idxc"
+	idxc.Spec.Sites = []enterprisev1.IndexerClusterSiteSpec{{Name: "site1", Replicas: 3}}
+	test("TestApplyStandaloneMigration(multisite)", false, true, updating)
+}
+
+func TestVerifyStandaloneMigration(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Replicas = 2
+	cr.Status.Migration = &enterprisev1.StandaloneMigrationStatus{IndexerCluster: "central/idxc", Phase: enterprisev1.PhaseUpdating}
+	idxc := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "idxc",
+			Namespace: "central",
+		},
+	}
+	idxc.Spec.ClusterMasterRef.Name = "cm"
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	newSplunkClient := func(managementURI, username, password string) *splclient.SplunkClient {
+		c := splclient.NewSplunkClient(managementURI, username, password)
+		c.Client = mockSplunkClient
+		return c
+	}
+	c := newMockClient()
+
+	// secrets of the cluster master are required
+	err := verifyStandaloneMigration(c, &cr, &idxc, newSplunkClient)
+	if err == nil {
+		t.Errorf("verifyStandaloneMigration() returned nil; want error")
+	}
+	secrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-cm-indexer-secrets", Namespace: "central"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	c.state[getStateKey(secrets)] = secrets
+
+	test := func(testname string, body string, wantStatus enterprisev1.StandaloneMigrationStatus) {
+		mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://splunk-cm-cluster-master-service.central.svc.cluster.local:8089/services/cluster/master/peers?count=0&output_mode=json",
+			Status: 200,
+			Body:   body,
+		})
+		err := verifyStandaloneMigration(c, &cr, &idxc, newSplunkClient)
+		if err != nil {
+			t.Errorf("%s: verifyStandaloneMigration() returned %v; want nil", testname, err)
+		}
+		mockSplunkClient.CheckRequests(t, testname)
+		if !reflect.DeepEqual(*cr.Status.Migration, wantStatus) {
+			t.Errorf("%s: verifyStandaloneMigration() status.migration = %v; want %v", testname, *cr.Status.Migration, wantStatus)
+		}
+	}
+
+	// migration continues until all instances are up as peers
+	test("TestVerifyStandaloneMigration(updating)",
+		`{"entry":[{"name":"ID0","content":{"label":"splunk-stack1-standalone-0","status":"Up"}},{"name":"ID1","content":{"label":"splunk-stack1-standalone-1","status":"Down"}}]}`,
+		enterprisev1.StandaloneMigrationStatus{IndexerCluster: "central/idxc", Phase: enterprisev1.PhaseUpdating, Peers: []string{"splunk-stack1-standalone-0"}})
+	checkEvents(t, "TestVerifyStandaloneMigration(updating)", recorder)
+
+	test("TestVerifyStandaloneMigration(completed)",
+		`{"entry":[{"name":"ID0","content":{"label":"splunk-stack1-standalone-0","status":"Up"}},{"name":"ID1","content":{"label":"splunk-stack1-standalone-1","status":"Up"}}]}`,
+		enterprisev1.StandaloneMigrationStatus{IndexerCluster: "central/idxc", Phase: enterprisev1.PhaseReady, Peers: []string{"splunk-stack1-standalone-0", "splunk-stack1-standalone-1"}})
+	checkEvents(t, "TestVerifyStandaloneMigration(completed)", recorder, "Normal MigrationCompleted All standalone instances are up as peers of IndexerCluster central/idxc")
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplySplunkServiceMonitors creates or updates a Prometheus Operator ServiceMonitor for each type of Splunk instance managed
+// by a SplunkEnterprise resource. It does nothing if ServiceMonitors are not enabled.
+func ApplySplunkServiceMonitors(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.MonitoringSpec, instanceTypes ...enterprise.InstanceType) error {
+	if !enterprise.IsServiceMonitorEnabled(spec) {
+		return nil
+	}
+	for _, instanceType := range instanceTypes {
+		if err := ApplyServiceMonitor(client, enterprise.GetSplunkServiceMonitor(cr, spec, instanceType)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyServiceMonitor creates or updates a Prometheus Operator ServiceMonitor. It does nothing if the Prometheus Operator
+// CRDs are not installed, so that resources can enable ServiceMonitors before monitoring is set up in a cluster.
+func ApplyServiceMonitor(client ControllerClient, serviceMonitor *unstructured.Unstructured) error {
+	scopedLog := log.WithName("ApplyServiceMonitor").WithValues(
+		"name", serviceMonitor.GetName(),
+		"namespace", serviceMonitor.GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: serviceMonitor.GetNamespace(), Name: serviceMonitor.GetName()}
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(serviceMonitor.GroupVersionKind())
+
+	err := client.Get(context.TODO(), namespacedName, current)
+	if meta.IsNoMatchError(err) {
+		scopedLog.Info("Prometheus Operator CRDs are not installed; skipping ServiceMonitor")
+		return nil
+	}
+	if err == nil {
+		hasUpdates := false
+		if !reflect.DeepEqual(serviceMonitor.Object["spec"], current.Object["spec"]) {
+			current.Object["spec"] = serviceMonitor.Object["spec"]
+			hasUpdates = true
+		}
+		labels := current.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		for k, v := range serviceMonitor.GetLabels() {
+			if labels[k] != v {
+				labels[k] = v
+				hasUpdates = true
+			}
+		}
+		if hasUpdates {
+			scopedLog.Info("Updating existing ServiceMonitor")
+			current.SetLabels(labels)
+			err = client.Update(context.TODO(), current)
+		} else {
+			scopedLog.Info("No changes for ServiceMonitor")
+		}
+	} else {
+		err = client.Create(context.TODO(), serviceMonitor)
+		if err == nil {
+			scopedLog.Info("Created ServiceMonitor")
+		}
+	}
+
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("Unable to apply ServiceMonitor %s: %v", serviceMonitor.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplySplunkServiceMonitors(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	spec := enterprisev1.MonitoringSpec{}
+	searchHeadCall := mockFuncCall{metaName: "*unstructured.Unstructured-test-splunk-stack1-search-head-monitor"}
+	deployerCall := mockFuncCall{metaName: "*unstructured.Unstructured-test-splunk-stack1-deployer-monitor"}
+	apply := func(c *mockClient) {
+		if err := ApplySplunkServiceMonitors(c, &cr, &spec, enterprise.SplunkSearchHead, enterprise.SplunkDeployer); err != nil {
+			t.Errorf("ApplySplunkServiceMonitors() returned %v; want nil", err)
+		}
+	}
+
+	// nothing to do unless enabled
+	c := newMockClient()
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(disabled)", map[string][]mockFuncCall{})
+
+	// create a ServiceMonitor for each instance type
+	spec = enterprisev1.MonitoringSpec{ServiceMonitor: true, Port: "splunkd", Path: "/metrics"}
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(create)", map[string][]mockFuncCall{
+		"Get":    {searchHeadCall, deployerCall},
+		"Create": {searchHeadCall, deployerCall},
+	})
+
+	// no updates if nothing has changed
+	c.resetCalls()
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(no-change)", map[string][]mockFuncCall{"Get": {searchHeadCall, deployerCall}})
+
+	// update when the scrape interval changes
+	c.resetCalls()
+	spec.Interval = "30s"
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(update)", map[string][]mockFuncCall{
+		"Get":    {searchHeadCall, deployerCall},
+		"Update": {searchHeadCall, deployerCall},
+	})
+
+	// update when labels are added
+	c.resetCalls()
+	spec.Labels = map[string]string{"release": "prometheus"}
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(labels)", map[string][]mockFuncCall{
+		"Get":    {searchHeadCall, deployerCall},
+		"Update": {searchHeadCall, deployerCall},
+	})
+
+	// skip if the Prometheus Operator CRDs are not installed
+	c = newMockClient()
+	c.notFoundError = &meta.NoKindMatchError{GroupKind: enterprise.ServiceMonitorGroupVersionKind.GroupKind()}
+	apply(c)
+	c.checkCalls(t, "TestApplySplunkServiceMonitors(no-crds)", map[string][]mockFuncCall{"Get": {searchHeadCall, deployerCall}})
+}
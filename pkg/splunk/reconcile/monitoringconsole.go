@@ -0,0 +1,288 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyMonitoringConsole reconciles the state for a Splunk Enterprise monitoring console.
+func ApplyMonitoringConsole(client ControllerClient, cr *enterprisev1.MonitoringConsole) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyMonitoringConsole(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplyMonitoringConsole").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateMonitoringConsoleSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		client.Status().Update(context.TODO(), cr)
+	}()
+
+	// check if deletion has been requested
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
+			cr.Status.Phase = enterprisev1.PhaseTerminating
+		} else {
+			result.Requeue = false
+		}
+		return result, err
+	}
+
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update general config resources
+	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkMonitoringConsole, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkMonitoringConsole))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a service
+	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkMonitoringConsole, false))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkMonitoringConsole, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkMonitoringConsole, false))
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkMonitoringConsole)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update statefulset
+	statefulSet, err := enterprise.GetMonitoringConsoleStatefulSet(cr)
+	if err != nil {
+		return result, err
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
+	mgr := DefaultStatefulSetPodManager{}
+	phase, err := mgr.Update(client, statefulSet, 1)
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if cr.Status.Phase == enterprisev1.PhaseReady || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkMonitoringConsole, cr.GetIdentifier(), 1, false), ","))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// reload changes to conf files once the monitoring console is ready, if configUpdateStrategy is reload
+	reloaded := true
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		reloadManager := ConfigReloadManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		reloaded, err = reloadManager.Apply(strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkMonitoringConsole, cr.GetIdentifier(), 1, false), ","), confFiles, &cr.Status.ConfFilesChecksum)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, and no conf files are waiting to be reloaded
+	if cr.Status.Phase == enterprisev1.PhaseReady && reloaded {
+		result.Requeue = false
+	}
+	return result, nil
+}
+
+// MonitoringConsolePeerManager is used to register Splunk Enterprise instances as search peers of a monitoring console
+type MonitoringConsolePeerManager struct {
+	log             logr.Logger
+	cr              enterprisev1.MetaObject
+	spec            *enterprisev1.CommonSplunkSpec
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// RegisterPeers adds each host (FQDN) as a search peer of the monitoring console referenced by the custom resource, if not already registered.
+// It does nothing if the custom resource does not reference a monitoring console.
+func (mgr *MonitoringConsolePeerManager) RegisterPeers(c ControllerClient, hosts []string) error {
+	ref := mgr.spec.MonitoringConsoleRef
+	if ref.Name == "" {
+		return nil
+	}
+
+	// peers are registered using the monitoring console's admin password
+	mcPassword, err := GetSplunkSecret(c, mgr.cr, ref, enterprise.SplunkMonitoringConsole, "password")
+	if err != nil {
+		return err
+	}
+	mcClient := mgr.getMonitoringConsoleClient(string(mcPassword))
+
+	peers, err := mcClient.GetSearchPeers()
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		peer := fmt.Sprintf("%s:8089", host)
+		if _, ok := peers[peer]; ok {
+			continue
+		}
+		mgr.log.Info("Registering search peer with monitoring console", "peer", peer, "monitoringConsole", ref.Name)
+		err = mcClient.AddSearchPeer(peer, "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnregisterPeers removes each host (FQDN) from the search peers of the monitoring console referenced by the custom resource, if
+// registered. It does nothing if the custom resource does not reference a monitoring console, or if the monitoring console no
+// longer exists.
+func (mgr *MonitoringConsolePeerManager) UnregisterPeers(c ControllerClient, hosts []string) error {
+	ref := mgr.spec.MonitoringConsoleRef
+	if ref.Name == "" {
+		return nil
+	}
+
+	mcPassword, err := GetSplunkSecret(c, mgr.cr, ref, enterprise.SplunkMonitoringConsole, "password")
+	if err != nil {
+		mgr.log.Info("Unable to get monitoring console secrets; skipping removal of search peers", "monitoringConsole", ref.Name, "error", err.Error())
+		return nil
+	}
+	mcClient := mgr.getMonitoringConsoleClient(string(mcPassword))
+
+	peers, err := mcClient.GetSearchPeers()
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		peer := fmt.Sprintf("%s:8089", host)
+		if _, ok := peers[peer]; !ok {
+			continue
+		}
+		mgr.log.Info("Removing search peer from monitoring console", "peer", peer, "monitoringConsole", ref.Name)
+		err = mcClient.RemoveSearchPeer(peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getMonitoringConsoleClient for MonitoringConsolePeerManager returns a SplunkClient for the referenced monitoring console
+func (mgr *MonitoringConsolePeerManager) getMonitoringConsoleClient(password string) *splclient.SplunkClient {
+	ref := mgr.spec.MonitoringConsoleRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = mgr.cr.GetNamespace()
+	}
+	fqdnName := resources.GetServiceFQDN(namespace, enterprise.GetSplunkServiceName(enterprise.SplunkMonitoringConsole, ref.Name, false))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", password)
+}
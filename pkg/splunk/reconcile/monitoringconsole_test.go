@@ -0,0 +1,205 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestApplyMonitoringConsole(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-monitoring-console-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-monitoring-console-service"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-monitoring-console"},
+	}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[2]}}
+	current := enterprisev1.MonitoringConsole{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "MonitoringConsole",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyMonitoringConsole(c, cr.(*enterprisev1.MonitoringConsole))
+		return err
+	}
+	reconcileTester(t, "TestApplyMonitoringConsole", &current, revised, createCalls, updateCalls, reconcile)
+
+	// test deletion
+	currentTime := metav1.NewTime(time.Now())
+	revised.ObjectMeta.DeletionTimestamp = &currentTime
+	revised.ObjectMeta.Finalizers = []string{"enterprise.splunk.com/delete-pvc"}
+	deleteFunc := func(cr enterprisev1.MetaObject, c ControllerClient) (bool, error) {
+		_, err := ApplyMonitoringConsole(c, cr.(*enterprisev1.MonitoringConsole))
+		return true, err
+	}
+	splunkDeletionTester(t, revised, deleteFunc)
+}
+
+func TestMonitoringConsoleRegisterPeers(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mcSecrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-mc1-monitoring-console-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"password": []byte{'4', '5', '6'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &MonitoringConsolePeerManager{
+		log:     log.WithName("TestMonitoringConsoleRegisterPeers"),
+		cr:      &cr,
+		spec:    &cr.Spec.CommonSplunkSpec,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	hosts := []string{
+		"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local",
+		"splunk-stack1-standalone-1.splunk-stack1-standalone-headless.test.svc.cluster.local",
+	}
+
+	// nothing to do without a monitoring console reference
+	c := newMockClient()
+	if err := mgr.RegisterPeers(c, hosts); err != nil {
+		t.Errorf("RegisterPeers() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestMonitoringConsoleRegisterPeers(no-ref)", map[string][]mockFuncCall{})
+	mockSplunkClient.CheckRequests(t, "TestMonitoringConsoleRegisterPeers(no-ref)")
+
+	// only peers that are not already registered are added
+	cr.Spec.MonitoringConsoleRef.Name = "mc1"
+	c.state[getStateKey(mcSecrets)] = mcSecrets
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-mc1-monitoring-console-service.test.svc.cluster.local:8089/services/search/distributed/peers?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"name":"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local:8089","content":{"peerName":"splunk-stack1-standalone-0","status":"Up"}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-mc1-monitoring-console-service.test.svc.cluster.local:8089/services/search/distributed/peers",
+		Status: 201,
+		Err:    nil,
+		Body:   ``,
+	})
+	if err := mgr.RegisterPeers(c, hosts); err != nil {
+		t.Errorf("RegisterPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestMonitoringConsoleRegisterPeers")
+
+	// monitoring console secrets are required
+	c = newMockClient()
+	if err := mgr.RegisterPeers(c, hosts); err == nil {
+		t.Errorf("RegisterPeers() returned nil; want error for missing monitoring console secrets")
+	}
+}
+
+func TestMonitoringConsoleUnregisterPeers(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	mcSecrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-mc1-monitoring-console-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"password": []byte{'4', '5', '6'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &MonitoringConsolePeerManager{
+		log:  log.WithName("TestMonitoringConsoleUnregisterPeers"),
+		cr:   &cr,
+		spec: &cr.Spec.CommonSplunkSpec,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	hosts := []string{
+		"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local",
+		"splunk-stack1-standalone-1.splunk-stack1-standalone-headless.test.svc.cluster.local",
+	}
+
+	// nothing to do without a monitoring console reference
+	c := newMockClient()
+	if err := mgr.UnregisterPeers(c, hosts); err != nil {
+		t.Errorf("UnregisterPeers() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestMonitoringConsoleUnregisterPeers(no-ref)", map[string][]mockFuncCall{})
+	mockSplunkClient.CheckRequests(t, "TestMonitoringConsoleUnregisterPeers(no-ref)")
+
+	// nothing to do if the monitoring console no longer exists
+	cr.Spec.MonitoringConsoleRef.Name = "mc1"
+	if err := mgr.UnregisterPeers(c, hosts); err != nil {
+		t.Errorf("UnregisterPeers() returned %v; want nil for missing monitoring console", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestMonitoringConsoleUnregisterPeers(missing)")
+
+	// only peers that are registered are removed
+	c.state[getStateKey(mcSecrets)] = mcSecrets
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-mc1-monitoring-console-service.test.svc.cluster.local:8089/services/search/distributed/peers?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"name":"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local:8089","content":{"peerName":"splunk-stack1-standalone-0","status":"Up"}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "DELETE",
+		URL:    "https://splunk-mc1-monitoring-console-service.test.svc.cluster.local:8089/services/search/distributed/peers/splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local:8089",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	if err := mgr.UnregisterPeers(c, hosts); err != nil {
+		t.Errorf("UnregisterPeers() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestMonitoringConsoleUnregisterPeers")
+}
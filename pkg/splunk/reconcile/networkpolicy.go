@@ -0,0 +1,61 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplySplunkNetworkPolicy creates or updates a Kubernetes NetworkPolicy restricting traffic to the Splunk ports of the
+// instances of a SplunkEnterprise resource. It does nothing if a network policy is not enabled.
+func ApplySplunkNetworkPolicy(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.NetworkPolicySpec, instanceType enterprise.InstanceType) error {
+	if !enterprise.IsNetworkPolicyEnabled(spec) {
+		return nil
+	}
+	return ApplyNetworkPolicy(client, enterprise.GetSplunkNetworkPolicy(cr, spec, instanceType))
+}
+
+// ApplyNetworkPolicy creates or updates a Kubernetes NetworkPolicy
+func ApplyNetworkPolicy(client ControllerClient, revised *networkingv1.NetworkPolicy) error {
+	scopedLog := log.WithName("ApplyNetworkPolicy").WithValues(
+		"name", revised.GetObjectMeta().GetName(),
+		"namespace", revised.GetObjectMeta().GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current networkingv1.NetworkPolicy
+
+	err := client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return CreateResource(client, revised)
+	}
+
+	// only update if there are material differences
+	if !resources.CompareByMarshall(current.Spec, revised.Spec) {
+		scopedLog.Info("No update to existing NetworkPolicy")
+		return nil
+	}
+
+	scopedLog.Info("Updating existing NetworkPolicy")
+	current.Spec = revised.Spec
+	*revised = current // caller expects that object passed represents latest state
+	return UpdateResource(client, revised)
+}
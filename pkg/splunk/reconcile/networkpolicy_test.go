@@ -0,0 +1,70 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyNetworkPolicy(t *testing.T) {
+	funcCalls := []mockFuncCall{{metaName: "*v1.NetworkPolicy-test-splunk-stack1-standalone-network-policy"}}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": funcCalls}
+	current := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-standalone-network-policy",
+			Namespace: "test",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/16"}}}}},
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Ingress[0].From[0].IPBlock.CIDR = "10.1.0.0/16"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		return ApplyNetworkPolicy(c, cr.(*networkingv1.NetworkPolicy))
+	}
+	reconcileTester(t, "TestApplyNetworkPolicy", &current, revised, createCalls, updateCalls, reconcile)
+}
+
+func TestApplySplunkNetworkPolicy(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	// nothing to do unless enabled
+	c := newMockClient()
+	if err := ApplySplunkNetworkPolicy(c, &cr, &cr.Spec.NetworkPolicy, enterprise.SplunkStandalone); err != nil {
+		t.Errorf("ApplySplunkNetworkPolicy() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplySplunkNetworkPolicy(disabled)", map[string][]mockFuncCall{})
+
+	// network policy is created when enabled
+	cr.Spec.NetworkPolicy = enterprisev1.NetworkPolicySpec{Enabled: true}
+	funcCalls := []mockFuncCall{{metaName: "*v1.NetworkPolicy-test-splunk-stack1-standalone-network-policy"}}
+	if err := ApplySplunkNetworkPolicy(c, &cr, &cr.Spec.NetworkPolicy, enterprise.SplunkStandalone); err != nil {
+		t.Errorf("ApplySplunkNetworkPolicy() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplySplunkNetworkPolicy(create)", map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls})
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyOpenShiftServiceAccount creates the ServiceAccount and RoleBinding used to run pods with the anyuid
+// SecurityContextConstraints in a namespace, if they do not already exist. It does nothing if OpenShift compatibility
+// is not enabled. These are shared by all resources in the namespace, so they are not owned by any of them.
+func ApplyOpenShiftServiceAccount(client ControllerClient, namespace string) error {
+	if !resources.IsOpenShiftCompatibilityEnabled() {
+		return nil
+	}
+
+	var serviceAccount corev1.ServiceAccount
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: resources.OpenShiftServiceAccountName}
+	err := client.Get(context.TODO(), namespacedName, &serviceAccount)
+	if err != nil {
+		if err = CreateResource(client, resources.GetOpenShiftServiceAccount(namespace)); err != nil {
+			return err
+		}
+	}
+
+	var roleBinding rbacv1.RoleBinding
+	err = client.Get(context.TODO(), namespacedName, &roleBinding)
+	if err != nil {
+		return CreateResource(client, resources.GetOpenShiftRoleBinding(namespace))
+	}
+
+	return nil
+}
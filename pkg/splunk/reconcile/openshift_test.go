@@ -0,0 +1,50 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"os"
+	"testing"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestApplyOpenShiftServiceAccount(t *testing.T) {
+	// nothing to do unless OpenShift compatibility is enabled
+	c := newMockClient()
+	if err := ApplyOpenShiftServiceAccount(c, "test"); err != nil {
+		t.Errorf("ApplyOpenShiftServiceAccount() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplyOpenShiftServiceAccount(disabled)", map[string][]mockFuncCall{})
+
+	os.Setenv(resources.OpenShiftCompatibilityEnv, "true")
+	defer os.Unsetenv(resources.OpenShiftCompatibilityEnv)
+
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.ServiceAccount-test-splunk-anyuid"},
+		{metaName: "*v1.RoleBinding-test-splunk-anyuid"},
+	}
+	if err := ApplyOpenShiftServiceAccount(c, "test"); err != nil {
+		t.Errorf("ApplyOpenShiftServiceAccount() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplyOpenShiftServiceAccount(create)", map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls})
+
+	// existing resources are left alone
+	c.resetCalls()
+	if err := ApplyOpenShiftServiceAccount(c, "test"); err != nil {
+		t.Errorf("ApplyOpenShiftServiceAccount() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplyOpenShiftServiceAccount(exists)", map[string][]mockFuncCall{"Get": funcCalls})
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// OrphanedResource identifies a Kubernetes object created by the operator whose custom resource no longer exists
+type OrphanedResource struct {
+	// Kind of the object, such as "StatefulSet"
+	Kind string
+
+	// Namespace of the object
+	Namespace string
+
+	// Name of the object
+	Name string
+
+	// Deleted is true if the object was deleted
+	Deleted bool
+}
+
+// orphanOwner identifies a custom resource that may own a Kubernetes object
+type orphanOwner struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+// CollectOrphanedResources finds StatefulSets, Services, Secrets and PersistentVolumeClaims labeled as managed by the
+// operator in a namespace (or all namespaces, if empty) whose custom resource no longer exists, and reports or deletes
+// them according to policy. Each orphaned object found is returned, unless policy is OrphanPolicyIgnore.
+func CollectOrphanedResources(c ControllerClient, namespace string, policy string) ([]OrphanedResource, error) {
+	if policy == enterprise.OrphanPolicyIgnore {
+		return nil, nil
+	}
+
+	scopedLog := log.WithName("CollectOrphanedResources").WithValues("namespace", namespace, "policy", policy)
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels{"app.kubernetes.io/managed-by": "splunk-operator"},
+	}
+	lists := []struct {
+		kind string
+		list runtime.Object
+	}{
+		{"StatefulSet", &appsv1.StatefulSetList{}},
+		{"Service", &corev1.ServiceList{}},
+		{"Secret", &corev1.SecretList{}},
+		{"PersistentVolumeClaim", &corev1.PersistentVolumeClaimList{}},
+	}
+
+	// custom resources are looked up once for all of the objects that they may own
+	found := make(map[orphanOwner]bool)
+	var orphans []OrphanedResource
+	for _, l := range lists {
+		err := c.List(context.TODO(), l.list, listOpts...)
+		if err != nil {
+			return orphans, err
+		}
+		items, err := meta.ExtractList(l.list)
+		if err != nil {
+			return orphans, err
+		}
+
+		for _, item := range items {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return orphans, err
+			}
+			if obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			orphaned, err := isOrphanedResource(c, obj, found)
+			if err != nil {
+				return orphans, err
+			}
+			if !orphaned {
+				continue
+			}
+
+			orphan := OrphanedResource{Kind: l.kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			if policy == enterprise.OrphanPolicyDeleteWithVolumes || (policy == enterprise.OrphanPolicyDelete && l.kind != "PersistentVolumeClaim") {
+				scopedLog.Info("Deleting orphaned resource", "kind", l.kind, "name", obj.GetName(), "objectNamespace", obj.GetNamespace())
+				err = c.Delete(context.TODO(), item)
+				if err != nil && !errors.IsNotFound(err) {
+					return orphans, err
+				}
+				orphan.Deleted = true
+				recordEvent(item, corev1.EventTypeNormal, "OrphanDeleted", "Deleted %s %s, since its custom resource no longer exists", l.kind, obj.GetName())
+			} else {
+				scopedLog.Info("Found orphaned resource", "kind", l.kind, "name", obj.GetName(), "objectNamespace", obj.GetNamespace())
+				recordEvent(item, corev1.EventTypeWarning, "OrphanDetected", "%s %s was created for a custom resource that no longer exists", l.kind, obj.GetName())
+			}
+			orphans = append(orphans, orphan)
+		}
+	}
+
+	return orphans, nil
+}
+
+// isOrphanedResource returns true if none of the custom resources that may own a Kubernetes object exist. These are the
+// Splunk custom resources in its owner references, or those identified by its labels if it has none, such as persistent
+// volume claims created for a StatefulSet. Objects that cannot be attributed to any custom resource are never orphaned.
+// The existence of each custom resource is kept in found.
+func isOrphanedResource(c ControllerClient, obj metav1.Object, found map[orphanOwner]bool) (bool, error) {
+	var owners []orphanOwner
+	for _, ref := range obj.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group == enterprisev1.SchemeGroupVersion.Group {
+			owners = append(owners, orphanOwner{apiVersion: ref.APIVersion, kind: ref.Kind, namespace: obj.GetNamespace(), name: ref.Name})
+		}
+	}
+	if len(owners) == 0 {
+		kinds, names := enterprise.GetLabeledOwners(obj.GetLabels())
+		for _, kind := range kinds {
+			for _, name := range names {
+				owners = append(owners, orphanOwner{apiVersion: enterprisev1.SchemeGroupVersion.String(), kind: kind, namespace: obj.GetNamespace(), name: name})
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return false, nil
+	}
+
+	for _, owner := range owners {
+		exists, ok := found[owner]
+		if !ok {
+			cr := &unstructured.Unstructured{}
+			cr.SetAPIVersion(owner.apiVersion)
+			cr.SetKind(owner.kind)
+			err := c.Get(context.TODO(), types.NamespacedName{Namespace: owner.namespace, Name: owner.name}, cr)
+			if err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+			exists = err == nil
+			found[owner] = exists
+		}
+		if exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
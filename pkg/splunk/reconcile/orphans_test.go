@@ -0,0 +1,115 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+func TestCollectOrphanedResources(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	SetEventRecorder(recorder)
+	defer SetEventRecorder(&record.FakeRecorder{})
+
+	c := newMockClient()
+	c.notFoundError = k8serrors.NewNotFound(schema.GroupResource{Group: "enterprise.splunk.com", Resource: "standalones"}, "")
+	c.state["*unstructured.Unstructured-test-stack1"] = &unstructured.Unstructured{}
+	newPVC := func(name string, labels map[string]string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels}}
+	}
+	c.listObj = &corev1.PersistentVolumeClaimList{
+		Items: []corev1.PersistentVolumeClaim{
+			newPVC("pvc-etc-splunk-stack1-standalone-0", resources.GetLabels("standalone", "standalone", "stack1")),
+			newPVC("pvc-etc-splunk-stack2-standalone-0", resources.GetLabels("standalone", "standalone", "stack2")),
+			newPVC("pvc-unknown", map[string]string{"app.kubernetes.io/managed-by": "splunk-operator"}),
+		},
+	}
+	listOpts := []client.ListOption{
+		client.InNamespace("test"),
+		client.MatchingLabels{"app.kubernetes.io/managed-by": "splunk-operator"},
+	}
+	listCalls := []mockFuncCall{{listOpts: listOpts}, {listOpts: listOpts}, {listOpts: listOpts}, {listOpts: listOpts}}
+	getCalls := []mockFuncCall{{metaName: "*unstructured.Unstructured-test-stack1"}, {metaName: "*unstructured.Unstructured-test-stack2"}}
+
+	test := func(testname string, policy string, want []OrphanedResource, wantCalls map[string][]mockFuncCall) {
+		c.resetCalls()
+		got, err := CollectOrphanedResources(c, "test", policy)
+		if err != nil {
+			t.Errorf("%s: CollectOrphanedResources() returned %v; want nil", testname, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: CollectOrphanedResources() = %v; want %v", testname, got, want)
+		}
+		c.checkCalls(t, testname, wantCalls)
+	}
+
+	// nothing is done unless enabled
+	test("TestCollectOrphanedResources(ignore)", enterprise.OrphanPolicyIgnore, nil, map[string][]mockFuncCall{})
+	checkEvents(t, "TestCollectOrphanedResources(ignore)", recorder)
+
+	// persistent volume claims are only reported, unless volumes are also deleted
+	want := []OrphanedResource{{Kind: "PersistentVolumeClaim", Namespace: "test", Name: "pvc-etc-splunk-stack2-standalone-0"}}
+	test("TestCollectOrphanedResources(report)", enterprise.OrphanPolicyReport, want, map[string][]mockFuncCall{"List": listCalls, "Get": getCalls})
+	checkEvents(t, "TestCollectOrphanedResources(report)", recorder,
+		"Warning OrphanDetected PersistentVolumeClaim pvc-etc-splunk-stack2-standalone-0 was created for a custom resource that no longer exists")
+	test("TestCollectOrphanedResources(delete)", enterprise.OrphanPolicyDelete, want, map[string][]mockFuncCall{"List": listCalls, "Get": getCalls})
+	checkEvents(t, "TestCollectOrphanedResources(delete)", recorder,
+		"Warning OrphanDetected PersistentVolumeClaim pvc-etc-splunk-stack2-standalone-0 was created for a custom resource that no longer exists")
+	want[0].Deleted = true
+	test("TestCollectOrphanedResources(delete-volumes)", enterprise.OrphanPolicyDeleteWithVolumes, want, map[string][]mockFuncCall{
+		"List":   listCalls,
+		"Get":    getCalls,
+		"Delete": {{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack2-standalone-0"}},
+	})
+	checkEvents(t, "TestCollectOrphanedResources(delete-volumes)", recorder,
+		"Normal OrphanDeleted Deleted PersistentVolumeClaim pvc-etc-splunk-stack2-standalone-0, since its custom resource no longer exists")
+
+	// objects with owner references are orphaned if none of their owners exist
+	newStatefulSet := func(name string, owners ...string) appsv1.StatefulSet {
+		ss := appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: resources.GetLabels("indexer", "indexer", "other")}}
+		for _, owner := range owners {
+			ss.OwnerReferences = append(ss.OwnerReferences, metav1.OwnerReference{APIVersion: "enterprise.splunk.com/v1beta1", Kind: "IndexerCluster", Name: owner})
+		}
+		return ss
+	}
+	c.listObj = &appsv1.StatefulSetList{
+		Items: []appsv1.StatefulSet{
+			newStatefulSet("splunk-stack1-indexer", "stack1"),
+			newStatefulSet("splunk-stack2-indexer", "stack2", "stack1"),
+			newStatefulSet("splunk-stack3-indexer", "stack3"),
+		},
+	}
+	want = []OrphanedResource{{Kind: "StatefulSet", Namespace: "test", Name: "splunk-stack3-indexer", Deleted: true}}
+	test("TestCollectOrphanedResources(owners)", enterprise.OrphanPolicyDelete, want, map[string][]mockFuncCall{
+		"List":   listCalls,
+		"Get":    append(getCalls, mockFuncCall{metaName: "*unstructured.Unstructured-test-stack3"}),
+		"Delete": {{metaName: "*v1.StatefulSet-test-splunk-stack3-indexer"}},
+	})
+	checkEvents(t, "TestCollectOrphanedResources(owners)", recorder,
+		"Normal OrphanDeleted Deleted StatefulSet splunk-stack3-indexer, since its custom resource no longer exists")
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// isPauseRequested returns true if reconciles of a custom resource have been paused. Custom resources that are being
+// deleted are never paused, so that they can always be cleaned up.
+func isPauseRequested(cr enterprisev1.MetaObject) bool {
+	return enterprise.IsPaused(cr) && cr.GetObjectMeta().GetDeletionTimestamp() == nil
+}
+
+// applyPaused reports that reconciles of a custom resource have been paused using a Paused condition, without making
+// any other changes to it or the resources it owns. A Paused event is recorded when the custom resource is first paused.
+func applyPaused(c ControllerClient, cr enterprisev1.MetaObject, conditions *[]enterprisev1.Condition) (reconcile.Result, error) {
+	scopedLog := log.WithName("applyPaused").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	revised := enterprise.GetPausedConditions(*conditions, cr.GetObjectMeta().GetGeneration(), time.Now())
+	if reflect.DeepEqual(revised, *conditions) {
+		scopedLog.Info("Reconciles are paused")
+		return reconcile.Result{}, nil
+	}
+
+	if previous := enterprise.GetCondition(*conditions, enterprisev1.ConditionPaused); previous == nil || previous.Status != corev1.ConditionTrue {
+		scopedLog.Info("Pausing reconciles")
+		recordEvent(cr, corev1.EventTypeNormal, "Paused", "Reconciles paused by the %s annotation", enterprise.PausedAnnotation)
+	}
+	*conditions = revised
+
+	// nothing is done until the paused annotation is removed, which triggers another reconcile
+	return reconcile.Result{}, c.Status().Update(context.TODO(), cr)
+}
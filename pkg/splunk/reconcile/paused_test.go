@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyStandalonePaused(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Generation:  2,
+			Annotations: map[string]string{enterprise.PausedAnnotation: "true"},
+		},
+	}
+
+	// nothing is created while reconciles are paused
+	c := newMockClient()
+	result, err := ApplyStandalone(c, &cr)
+	if err != nil || result.Requeue {
+		t.Errorf("ApplyStandalone() = %v, %v; want no requeue, nil", result, err)
+	}
+	c.checkCalls(t, "TestApplyStandalonePaused", map[string][]mockFuncCall{})
+	paused := enterprise.GetCondition(cr.Status.Conditions, enterprisev1.ConditionPaused)
+	if paused == nil || paused.Status != corev1.ConditionTrue || paused.ObservedGeneration != 2 {
+		t.Errorf("ApplyStandalone() paused condition = %v; want True for generation 2", paused)
+	}
+	if cr.Status.Phase != "" {
+		t.Errorf("ApplyStandalone() phase = %s; want unchanged", cr.Status.Phase)
+	}
+
+	// reconciles resume once the annotation is removed
+	cr.ObjectMeta.Annotations = nil
+	_, err = ApplyStandalone(c, &cr)
+	if err != nil {
+		t.Errorf("ApplyStandalone() returned %v; want nil", err)
+	}
+	if enterprise.GetCondition(cr.Status.Conditions, enterprisev1.ConditionPaused) != nil {
+		t.Errorf("ApplyStandalone() kept paused condition; want none")
+	}
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-stack1-standalone-secrets"},
+		{metaName: "*v1.Service-test-splunk-stack1-standalone-headless"},
+		{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"},
+	}
+	c.checkCalls(t, "TestApplyStandalonePaused(resumed)", map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls})
+
+	// custom resources that are being deleted are not paused
+	currentTime := metav1.NewTime(time.Now())
+	cr.ObjectMeta.Annotations = map[string]string{enterprise.PausedAnnotation: "true"}
+	cr.ObjectMeta.DeletionTimestamp = &currentTime
+	if isPauseRequested(&cr) {
+		t.Errorf("isPauseRequested() = true; want false while deleting")
+	}
+}
+
+func TestApplyPaused(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "stack1",
+			Namespace:  "test",
+			Generation: 3,
+		},
+	}
+	start := time.Date(2020, 5, 8, 10, 0, 0, 0, time.UTC)
+	cr.Status.Conditions = enterprise.GetStatusConditions(nil, enterprisev1.PhaseReady, 3, nil, start)
+
+	c := newMockClient()
+	if _, err := applyPaused(c, &cr, &cr.Status.Conditions); err != nil {
+		t.Errorf("applyPaused() returned %v; want nil", err)
+	}
+	if len(cr.Status.Conditions) != 5 || enterprise.GetCondition(cr.Status.Conditions, enterprisev1.ConditionReady).Status != corev1.ConditionTrue {
+		t.Errorf("applyPaused() conditions = %v; want Ready and Paused", cr.Status.Conditions)
+	}
+
+	// conditions are not changed again while the custom resource remains paused
+	want := cr.Status.Conditions[4].LastTransitionTime
+	if _, err := applyPaused(c, &cr, &cr.Status.Conditions); err != nil {
+		t.Errorf("applyPaused() returned %v; want nil", err)
+	}
+	if got := cr.Status.Conditions[4].LastTransitionTime; !got.Equal(&want) {
+		t.Errorf("applyPaused() paused lastTransitionTime = %s; want %s", got, want)
+	}
+}
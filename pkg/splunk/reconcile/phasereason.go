@@ -0,0 +1,134 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// getNotReadyMessage returns a message reporting that something is not ready yet, followed by the message explaining why (if any)
+func getNotReadyMessage(what, message string) string {
+	if message == "" {
+		return fmt.Sprintf("waiting for %s to be ready", what)
+	}
+	return fmt.Sprintf("waiting for %s to be ready: %s", what, message)
+}
+
+// getPendingVolumeClaim returns the name of a persistent volume claim used by a pod that is not bound yet, or an empty
+// string if there is none
+func getPendingVolumeClaim(c ControllerClient, pod *corev1.Pod) string {
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim == nil {
+			continue
+		}
+		var pvc corev1.PersistentVolumeClaim
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: pod.GetNamespace(), Name: v.PersistentVolumeClaim.ClaimName}, &pvc)
+		if err == nil && pvc.Status.Phase == corev1.ClaimPending {
+			return pvc.GetName()
+		}
+	}
+	return ""
+}
+
+// getPodsPhaseReason returns a reason, in CamelCase, and a human readable message explaining why the first pod matching
+// a selector that is not ready is not ready yet. Pods that cannot be scheduled until their persistent volume claims are
+// bound report the claim that is pending. Empty strings are returned if no problems are found.
+func getPodsPhaseReason(c ControllerClient, namespace string, selector *metav1.LabelSelector) (string, string) {
+	if selector == nil {
+		return "", ""
+	}
+	var pods corev1.PodList
+	err := c.List(context.TODO(), &pods, client.InNamespace(namespace), client.MatchingLabels(selector.MatchLabels))
+	if err != nil {
+		return "", ""
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].GetName() < pods.Items[j].GetName() })
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		reason, message := enterprise.GetPodNotReadyReason(pod)
+		if reason == "PodUnschedulable" && strings.Contains(message, "PersistentVolumeClaims") {
+			if claim := getPendingVolumeClaim(c, pod); claim != "" {
+				return "VolumeClaimPending", fmt.Sprintf("waiting for PersistentVolumeClaim %s of pod %s to be bound", claim, pod.GetName())
+			}
+		}
+		if reason != "" {
+			return reason, message
+		}
+	}
+	return "", ""
+}
+
+// getWorkloadPhaseReason returns a reason, in CamelCase, and a human readable message explaining why the pods of a
+// StatefulSet, Deployment or DaemonSet are in the given phase, using the number of desired, created and ready pods
+// reported in its status. Pods are only retrieved while some of those that have been created are not ready. Empty
+// strings are returned if the workload is ready.
+func getWorkloadPhaseReason(c ControllerClient, workload metav1.Object, kind string, selector *metav1.LabelSelector, phase enterprisev1.ResourcePhase, desired, created, ready int32) (string, string) {
+	name := workload.GetName()
+	switch {
+	case phase == enterprisev1.PhaseReady || phase == enterprisev1.PhaseError:
+		return "", ""
+	case phase == enterprisev1.PhaseScalingDown:
+		return "ScalingDown", fmt.Sprintf("waiting for %s %s to scale down to %d pods", kind, name, desired)
+	case created == 0:
+		return "WaitingForPods", fmt.Sprintf("waiting for %s %s to create pods", kind, name)
+	case ready < created:
+		if reason, message := getPodsPhaseReason(c, workload.GetNamespace(), selector); reason != "" {
+			return reason, message
+		}
+	case phase == enterprisev1.PhaseUpdating:
+		return "UpdatingPods", fmt.Sprintf("updating pods of %s %s", kind, name)
+	}
+	return "WaitingForPods", fmt.Sprintf("%d of %d pods of %s %s are ready", ready, desired, kind, name)
+}
+
+// getStatefulSetPhaseReason returns a reason, in CamelCase, and a human readable message explaining why the pods of a
+// StatefulSet are in the given phase (see getWorkloadPhaseReason)
+func getStatefulSetPhaseReason(c ControllerClient, statefulSet *appsv1.StatefulSet, phase enterprisev1.ResourcePhase) (string, string) {
+	var desired int32 = 1
+	if statefulSet.Spec.Replicas != nil {
+		desired = *statefulSet.Spec.Replicas
+	}
+	return getWorkloadPhaseReason(c, statefulSet, "StatefulSet", statefulSet.Spec.Selector, phase, desired, statefulSet.Status.Replicas, statefulSet.Status.ReadyReplicas)
+}
+
+// getDeploymentPhaseReason returns a reason, in CamelCase, and a human readable message explaining why the pods of a
+// Deployment are in the given phase (see getWorkloadPhaseReason)
+func getDeploymentPhaseReason(c ControllerClient, deployment *appsv1.Deployment, phase enterprisev1.ResourcePhase) (string, string) {
+	var desired int32 = 1
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	return getWorkloadPhaseReason(c, deployment, "Deployment", deployment.Spec.Selector, phase, desired, deployment.Status.Replicas, deployment.Status.ReadyReplicas)
+}
+
+// getDaemonSetPhaseReason returns a reason, in CamelCase, and a human readable message explaining why the pods of a
+// DaemonSet are in the given phase (see getWorkloadPhaseReason)
+func getDaemonSetPhaseReason(c ControllerClient, daemonSet *appsv1.DaemonSet, phase enterprisev1.ResourcePhase) (string, string) {
+	status := daemonSet.Status
+	return getWorkloadPhaseReason(c, daemonSet, "DaemonSet", daemonSet.Spec.Selector, phase, status.DesiredNumberScheduled, status.CurrentNumberScheduled, status.NumberReady)
+}
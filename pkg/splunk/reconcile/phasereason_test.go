@@ -0,0 +1,103 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestGetStatefulSetPhaseReason(t *testing.T) {
+	var replicas int32 = 2
+	labels := map[string]string{"app.kubernetes.io/instance": "splunk-stack1-standalone"}
+	statefulSet := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	c := newMockClient()
+	test := func(phase enterprisev1.ResourcePhase, wantReason, wantMessage string, wantCalls map[string][]mockFuncCall) {
+		c.resetCalls()
+		gotReason, gotMessage := getStatefulSetPhaseReason(c, &statefulSet, phase)
+		if gotReason != wantReason || gotMessage != wantMessage {
+			t.Errorf("getStatefulSetPhaseReason(%s) = %s, %s; want %s, %s", phase, gotReason, gotMessage, wantReason, wantMessage)
+		}
+		c.checkCalls(t, "getStatefulSetPhaseReason", wantCalls)
+	}
+
+	// pods are not retrieved until the StatefulSet has created some of them
+	test(enterprisev1.PhaseReady, "", "", map[string][]mockFuncCall{})
+	test(enterprisev1.PhasePending, "WaitingForPods", "waiting for StatefulSet splunk-stack1-standalone to create pods", map[string][]mockFuncCall{})
+	test(enterprisev1.PhaseScalingDown, "ScalingDown", "waiting for StatefulSet splunk-stack1-standalone to scale down to 2 pods", map[string][]mockFuncCall{})
+
+	// the first pod that is not ready is reported, along with claims that are not bound yet
+	statefulSet.Status.Replicas = 2
+	statefulSet.Status.ReadyReplicas = 1
+	ready := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone-0", Namespace: "test"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{Name: "splunk", Ready: true}}},
+	}
+	unschedulable := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone-1", Namespace: "test"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "pvc-etc",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-etc-splunk-stack1-standalone-1"}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Message: "pod has unbound immediate PersistentVolumeClaims"},
+		}},
+	}
+	c.listObj = &corev1.PodList{Items: []corev1.Pod{unschedulable, ready}}
+	listCalls := []mockFuncCall{{listOpts: []client.ListOption{client.InNamespace("test"), client.MatchingLabels(labels)}}}
+	pvcCalls := []mockFuncCall{{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack1-standalone-1"}}
+	test(enterprisev1.PhaseScalingUp, "PodUnschedulable", "pod splunk-stack1-standalone-1 cannot be scheduled: pod has unbound immediate PersistentVolumeClaims",
+		map[string][]mockFuncCall{"List": listCalls, "Get": pvcCalls})
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-etc-splunk-stack1-standalone-1", Namespace: "test"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	c.state[getStateKey(&pvc)] = &pvc
+	test(enterprisev1.PhaseScalingUp, "VolumeClaimPending", "waiting for PersistentVolumeClaim pvc-etc-splunk-stack1-standalone-1 of pod splunk-stack1-standalone-1 to be bound",
+		map[string][]mockFuncCall{"List": listCalls, "Get": pvcCalls})
+
+	// the number of ready pods is reported if none of them have problems
+	c.listObj = &corev1.PodList{Items: []corev1.Pod{ready}}
+	test(enterprisev1.PhaseScalingUp, "WaitingForPods", "1 of 2 pods of StatefulSet splunk-stack1-standalone are ready", map[string][]mockFuncCall{"List": listCalls})
+
+	// pods are updated once all of them are ready
+	statefulSet.Status.ReadyReplicas = 2
+	test(enterprisev1.PhaseUpdating, "UpdatingPods", "updating pods of StatefulSet splunk-stack1-standalone", map[string][]mockFuncCall{})
+}
+
+func TestGetDaemonSetPhaseReason(t *testing.T) {
+	daemonSet := appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-universal-forwarder", Namespace: "test"},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, CurrentNumberScheduled: 3, NumberReady: 3},
+	}
+	c := newMockClient()
+	reason, message := getDaemonSetPhaseReason(c, &daemonSet, enterprisev1.PhaseUpdating)
+	if reason != "UpdatingPods" || message != "updating pods of DaemonSet splunk-stack1-universal-forwarder" {
+		t.Errorf("getDaemonSetPhaseReason() = %s, %s; want UpdatingPods, updating pods of DaemonSet splunk-stack1-universal-forwarder", reason, message)
+	}
+	c.checkCalls(t, "getDaemonSetPhaseReason", map[string][]mockFuncCall{})
+}
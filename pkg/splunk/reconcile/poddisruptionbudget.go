@@ -0,0 +1,53 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyPodDisruptionBudget creates or updates a Kubernetes PodDisruptionBudget
+func ApplyPodDisruptionBudget(client ControllerClient, revised *policyv1beta1.PodDisruptionBudget) error {
+	scopedLog := log.WithName("ApplyPodDisruptionBudget").WithValues(
+		"name", revised.GetObjectMeta().GetName(),
+		"namespace", revised.GetObjectMeta().GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current policyv1beta1.PodDisruptionBudget
+
+	err := client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return CreateResource(client, revised)
+	}
+
+	// only replace if there are material differences
+	if !resources.CompareByMarshall(current.Spec, revised.Spec) {
+		scopedLog.Info("No update to existing PodDisruptionBudget")
+		return nil
+	}
+
+	// PodDisruptionBudgets cannot be updated before Kubernetes 1.15, so they are replaced instead
+	scopedLog.Info("Replacing existing PodDisruptionBudget")
+	err = client.Delete(context.TODO(), &current)
+	if err != nil {
+		return err
+	}
+	return CreateResource(client, revised)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestApplyPodDisruptionBudget(t *testing.T) {
+	funcCalls := []mockFuncCall{{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-indexer"}}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Delete": funcCalls, "Create": funcCalls}
+	maxUnavailable := intstr.FromInt(1)
+	current := policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-indexer",
+			Namespace: "test",
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/instance": "splunk-stack1-indexer"}},
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+	revised := current.DeepCopy()
+	revisedMaxUnavailable := intstr.FromInt(2)
+	revised.Spec.MaxUnavailable = &revisedMaxUnavailable
+	reconcile := func(c *mockClient, cr interface{}) error {
+		return ApplyPodDisruptionBudget(c, cr.(*policyv1beta1.PodDisruptionBudget))
+	}
+	reconcileTester(t, "TestApplyPodDisruptionBudget", &current, revised, createCalls, updateCalls, reconcile)
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplySplunkRestore reconciles the state of a restore of a Splunk Enterprise custom resource from a backup.
+func ApplySplunkRestore(client ControllerClient, cr *enterprisev1.SplunkRestore) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplySplunkRestore(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+	scopedLog := log.WithName("ApplySplunkRestore").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
+
+	// validate CR
+	err := enterprise.ValidateSplunkRestoreSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// nothing more to do once a restore has completed
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		result.Requeue = false
+		return result, nil
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		err = client.Status().Update(context.TODO(), cr)
+		if err != nil {
+			scopedLog.Error(err, "Status update failed")
+		}
+	}()
+
+	mgr := RestoreManager{log: scopedLog, cr: cr, newSplunkClient: getSplunkClientFactory(client)}
+	phase, err := mgr.Apply(client)
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+	if phase == enterprisev1.PhaseReady {
+		result.Requeue = false
+	}
+	return result, nil
+}
+
+// RestoreManager is used to restore a Splunk Enterprise custom resource from the VolumeSnapshots taken for a backup
+type RestoreManager struct {
+	log             logr.Logger
+	cr              *enterprisev1.SplunkRestore
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply restores the secrets and persistent volume claims used by the target of a restore, if this has not already been done.
+// Pods for a target that uses the RestoreAnnotation wait until then, so that its StatefulSets adopt the restored claims. Once
+// the target is ready, indexer cluster peers that are no longer part of the restored cluster are removed from its cluster master.
+func (mgr *RestoreManager) Apply(c ControllerClient) (enterprisev1.ResourcePhase, error) {
+	if !mgr.cr.Status.VolumesRestored {
+		err := mgr.restoreVolumes(c)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
+	targetPhase, err := mgr.getTargetPhase(c)
+	if err != nil {
+		mgr.log.Info("Waiting for restore target to be created", "target", mgr.cr.Spec.TargetRef.Name)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "TargetNotFound", fmt.Sprintf("waiting for %s %s to be created", mgr.cr.Spec.TargetRef.Kind, mgr.cr.Spec.TargetRef.Name)
+		return enterprisev1.PhasePending, nil
+	}
+	if targetPhase != enterprisev1.PhaseReady {
+		mgr.log.Info("Waiting for restore target to become ready", "target", mgr.cr.Spec.TargetRef.Name, "phase", targetPhase)
+		mgr.cr.Status.Reason, mgr.cr.Status.Message = "TargetNotReady", getNotReadyMessage(mgr.cr.Spec.TargetRef.Kind+" "+mgr.cr.Spec.TargetRef.Name, "")
+		return enterprisev1.PhasePending, nil
+	}
+
+	if mgr.cr.Spec.TargetRef.Kind == "IndexerCluster" {
+		err = mgr.removeStalePeers(c)
+		if err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
+	mgr.log.Info("Restore is complete", "backup", mgr.cr.Status.BackupName, "target", mgr.cr.Spec.TargetRef.Name)
+	return enterprisev1.PhaseReady, nil
+}
+
+// restoreVolumes for RestoreManager creates secrets and persistent volume claims for the target of a restore, using the
+// copy of secrets and the VolumeSnapshots kept for a backup.
+func (mgr *RestoreManager) restoreVolumes(c ControllerClient) error {
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.cr.Spec.BackupRef.Name}
+	var backupCR enterprisev1.SplunkBackup
+	err := c.Get(context.TODO(), namespacedName, &backupCR)
+	if err != nil {
+		return fmt.Errorf("Unable to find SplunkBackup %s: %v", mgr.cr.Spec.BackupRef.Name, err)
+	}
+	if backupCR.Spec.TargetRef.Kind != mgr.cr.Spec.TargetRef.Kind {
+		return fmt.Errorf("Unable to restore %s from a backup of %s %s", mgr.cr.Spec.TargetRef.Kind, backupCR.Spec.TargetRef.Kind, backupCR.Spec.TargetRef.Name)
+	}
+	backup, err := mgr.getBackup(&backupCR)
+	if err != nil {
+		return err
+	}
+	sourceName := backupCR.Spec.TargetRef.Name
+
+	// restored instances keep the GUIDs in their etc volumes, which must not be shared by instances that are still running
+	if mgr.cr.Spec.TargetRef.Name != sourceName && mgr.isTargetRunning(c, sourceName) {
+		return fmt.Errorf("Unable to restore a copy of %s %s while it is still running", backupCR.Spec.TargetRef.Kind, sourceName)
+	}
+
+	mgr.log.Info("Restoring from backup", "backup", backup.Name, "source", sourceName, "target", mgr.cr.Spec.TargetRef.Name)
+	err = mgr.restoreSecrets(c, backup)
+	if err != nil {
+		return err
+	}
+
+	volumes := []enterprisev1.RestoredVolumeStatus{}
+	for _, snapshotStatus := range backup.Snapshots {
+		pvcName, instanceType, err := enterprise.GetRestoredVolumeClaimName(mgr.cr, sourceName, snapshotStatus.PersistentVolumeClaimName)
+		if err != nil {
+			return err
+		}
+		size, err := mgr.getRestoreSize(c, snapshotStatus.Name)
+		if err != nil {
+			return err
+		}
+		pvc := enterprise.GetRestoredPersistentVolumeClaim(mgr.cr, pvcName, instanceType, snapshotStatus.Name, size)
+		err = mgr.createVolumeClaim(c, pvc)
+		if err != nil {
+			return err
+		}
+		volumes = append(volumes, enterprisev1.RestoredVolumeStatus{PersistentVolumeClaimName: pvcName, VolumeSnapshotName: snapshotStatus.Name})
+	}
+
+	mgr.cr.Status.BackupName = backup.Name
+	mgr.cr.Status.Volumes = volumes
+	mgr.cr.Status.VolumesRestored = true
+	return nil
+}
+
+// getBackup for RestoreManager returns the status of the backup to restore, which must be ready to use
+func (mgr *RestoreManager) getBackup(backupCR *enterprisev1.SplunkBackup) (*enterprisev1.BackupStatus, error) {
+	for idx := len(backupCR.Status.Backups) - 1; idx >= 0; idx-- {
+		backup := &backupCR.Status.Backups[idx]
+		if mgr.cr.Spec.BackupName != "" && backup.Name != mgr.cr.Spec.BackupName {
+			continue
+		}
+		if backup.ReadyToUse {
+			return backup, nil
+		}
+		if mgr.cr.Spec.BackupName != "" {
+			return nil, fmt.Errorf("Backup %s is not ready to use", backup.Name)
+		}
+	}
+	if mgr.cr.Spec.BackupName != "" {
+		return nil, fmt.Errorf("Unable to find backup %s in SplunkBackup %s", mgr.cr.Spec.BackupName, backupCR.GetIdentifier())
+	}
+	return nil, fmt.Errorf("SplunkBackup %s does not have any backups that are ready to use", backupCR.GetIdentifier())
+}
+
+// isTargetRunning for RestoreManager returns true if a custom resource of the target's kind exists with the given name
+func (mgr *RestoreManager) isTargetRunning(c ControllerClient, name string) bool {
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: name}
+	if mgr.cr.Spec.TargetRef.Kind == "IndexerCluster" {
+		return c.Get(context.TODO(), namespacedName, &enterprisev1.IndexerCluster{}) == nil
+	}
+	return c.Get(context.TODO(), namespacedName, &enterprisev1.Standalone{}) == nil
+}
+
+// getTargetPhase for RestoreManager returns the phase of the target of the restore, or error if it does not exist
+func (mgr *RestoreManager) getTargetPhase(c ControllerClient) (enterprisev1.ResourcePhase, error) {
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.cr.Spec.TargetRef.Name}
+	if mgr.cr.Spec.TargetRef.Kind == "IndexerCluster" {
+		var idxc enterprisev1.IndexerCluster
+		err := c.Get(context.TODO(), namespacedName, &idxc)
+		return idxc.Status.Phase, err
+	}
+	var standalone enterprisev1.Standalone
+	err := c.Get(context.TODO(), namespacedName, &standalone)
+	return standalone.Status.Phase, err
+}
+
+// restoreSecrets for RestoreManager creates the secrets used by the target of the restore from the copy kept for a backup,
+// since restored instances use the admin password and keys stored in their etc volumes.
+func (mgr *RestoreManager) restoreSecrets(c ControllerClient, backup *enterprisev1.BackupStatus) error {
+	secretsName := enterprise.GetSplunkBackupSecretsName(backup.Name)
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: secretsName}
+	var backupSecrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &backupSecrets)
+	if err != nil {
+		return fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+
+	secrets := enterprise.GetRestoredSecrets(mgr.cr, &backupSecrets)
+	namespacedName = types.NamespacedName{Namespace: secrets.GetNamespace(), Name: secrets.GetName()}
+	var current corev1.Secret
+	err = c.Get(context.TODO(), namespacedName, &current)
+	if err == nil {
+		if resources.CompareByMarshall(current.Data, secrets.Data) {
+			return fmt.Errorf("Secrets %s already exist, and do not match backup %s", secrets.GetName(), backup.Name)
+		}
+		return nil
+	}
+	return CreateResource(c, secrets)
+}
+
+// getRestoreSize for RestoreManager returns the minimum size of a volume restored from a VolumeSnapshot
+func (mgr *RestoreManager) getRestoreSize(c ControllerClient, snapshotName string) (resource.Quantity, error) {
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: snapshotName}
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(enterprise.VolumeSnapshotGroupVersionKind)
+	err := c.Get(context.TODO(), namespacedName, snapshot)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("Unable to get VolumeSnapshot %s: %v", snapshotName, err)
+	}
+	restoreSize, _, _ := unstructured.NestedString(snapshot.Object, "status", "restoreSize")
+	size, err := resource.ParseQuantity(restoreSize)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("Unable to get restore size of VolumeSnapshot %s: %v", snapshotName, err)
+	}
+	return size, nil
+}
+
+// createVolumeClaim for RestoreManager creates a restored persistent volume claim, unless it was already created from the same VolumeSnapshot
+func (mgr *RestoreManager) createVolumeClaim(c ControllerClient, pvc *corev1.PersistentVolumeClaim) error {
+	namespacedName := types.NamespacedName{Namespace: pvc.GetNamespace(), Name: pvc.GetName()}
+	var current corev1.PersistentVolumeClaim
+	err := c.Get(context.TODO(), namespacedName, &current)
+	if err == nil {
+		if current.Spec.DataSource == nil || current.Spec.DataSource.Name != pvc.Spec.DataSource.Name {
+			return fmt.Errorf("PersistentVolumeClaim %s already exists, and was not restored from VolumeSnapshot %s", pvc.GetName(), pvc.Spec.DataSource.Name)
+		}
+		return nil
+	}
+	return CreateResource(c, pvc)
+}
+
+// removeStalePeers for RestoreManager removes indexer cluster peers that are down from the cluster master of the target.
+// Restored peers re-register using the GUIDs kept in their etc volumes, so any that remain down were not restored.
+func (mgr *RestoreManager) removeStalePeers(c ControllerClient) error {
+	secretsName := enterprise.GetSplunkSecretsName(mgr.cr.Spec.TargetRef.Name, enterprise.SplunkIndexer)
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: secretsName}
+	var secrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		return fmt.Errorf("Unable to find secrets %s: %v", secretsName, err)
+	}
+
+	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkClusterMaster, mgr.cr.Spec.TargetRef.Name, false))
+	splunkClient := mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(&secrets))
+	peers, err := splunkClient.GetClusterMasterPeers()
+	if err != nil {
+		return fmt.Errorf("Unable to get indexer cluster peers: %v", err)
+	}
+	for label, peer := range peers {
+		if peer.Status != "Down" && peer.Status != "GracefulShutdown" {
+			continue
+		}
+		mgr.log.Info("Removing stale indexer cluster peer", "peer", label, "guid", peer.ID)
+		err = splunkClient.RemoveIndexerClusterPeer(peer.ID)
+		if err != nil {
+			return fmt.Errorf("Unable to remove indexer cluster peer %s: %v", label, err)
+		}
+		mgr.cr.Status.RemovedPeers = append(mgr.cr.Status.RemovedPeers, label)
+	}
+	return nil
+}
+
+// isRestorePending returns true if a custom resource uses the RestoreAnnotation to name a SplunkRestore
+// that has not yet restored its persistent volume claims, or that does not exist
+func isRestorePending(c ControllerClient, cr enterprisev1.MetaObject) (bool, error) {
+	name, ok := cr.GetObjectMeta().GetAnnotations()[enterprise.RestoreAnnotation]
+	if !ok {
+		return false, nil
+	}
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: name}
+	var restore enterprisev1.SplunkRestore
+	err := c.Get(context.TODO(), namespacedName, &restore)
+	if err != nil {
+		// keep waiting, since volumes created before the restore could not be replaced
+		return true, fmt.Errorf("Unable to find SplunkRestore %s: %v", name, err)
+	}
+	return !restore.Status.VolumesRestored, nil
+}
+
+// getRestorePendingReason returns the reason and message reported while a custom resource waits for its persistent volume
+// claims to be restored (see isRestorePending)
+func getRestorePendingReason(cr enterprisev1.MetaObject) (string, string) {
+	name := cr.GetObjectMeta().GetAnnotations()[enterprise.RestoreAnnotation]
+	return "RestorePending", fmt.Sprintf("waiting for SplunkRestore %s to restore persistent volume claims", name)
+}
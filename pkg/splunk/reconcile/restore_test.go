@@ -0,0 +1,245 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestRestoreManagerApply(t *testing.T) {
+	cr := enterprisev1.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SplunkRestoreSpec{
+			BackupRef: corev1.ObjectReference{Name: "backup1"},
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack2"},
+		},
+	}
+	backupName := "backup1-20200508100000"
+	pvcNames := []string{
+		"pvc-etc-splunk-stack1-cluster-master-0",
+		"pvc-var-splunk-stack1-cluster-master-0",
+		"pvc-etc-splunk-stack1-indexer-0",
+		"pvc-var-splunk-stack1-indexer-0",
+	}
+	backupCR := enterprisev1.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SplunkBackupSpec{
+			TargetRef: corev1.ObjectReference{Kind: "IndexerCluster", Name: "stack1"},
+		},
+		Status: enterprisev1.SplunkBackupStatus{
+			Backups: []enterprisev1.BackupStatus{{Name: backupName, ReadyToUse: true}},
+		},
+	}
+	c := newMockClient()
+	snapshotCalls := []mockFuncCall{}
+	for _, pvcName := range pvcNames {
+		snapshot := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"restoreSize": "10Gi"}}}
+		snapshot.SetGroupVersionKind(enterprise.VolumeSnapshotGroupVersionKind)
+		snapshot.SetName(enterprise.GetVolumeSnapshotName(backupName, pvcName))
+		snapshot.SetNamespace("test")
+		c.state[getStateKey(snapshot)] = snapshot
+		snapshotCalls = append(snapshotCalls, mockFuncCall{metaName: getStateKey(snapshot)})
+		backupCR.Status.Backups[0].Snapshots = append(backupCR.Status.Backups[0].Snapshots, enterprisev1.VolumeSnapshotStatus{
+			Name:                      snapshot.GetName(),
+			PersistentVolumeClaimName: pvcName,
+		})
+	}
+	source := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	backupSecrets := enterprise.GetBackupSecrets(&backupCR, backupName, enterprise.GetSplunkSecrets(&source, enterprise.SplunkIndexer, nil, nil))
+	for _, obj := range []runtime.Object{&backupCR, backupSecrets} {
+		c.state[getStateKey(obj)] = obj
+	}
+
+	var mockSplunkClient *spltest.MockHTTPClient
+	mgr := &RestoreManager{
+		log: log.WithName("TestRestoreManagerApply"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	checkApply := func(method string, wantPhase enterprisev1.ResourcePhase) {
+		phase, err := mgr.Apply(c)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", method, err)
+		}
+		if phase != wantPhase {
+			t.Errorf("%s phase = %s; want %s", method, phase, wantPhase)
+		}
+	}
+	backupCall := mockFuncCall{metaName: "*v1beta1.SplunkBackup-test-backup1"}
+	sourceCall := mockFuncCall{metaName: "*v1beta1.IndexerCluster-test-stack1"}
+	targetCall := mockFuncCall{metaName: "*v1beta1.IndexerCluster-test-stack2"}
+	backupSecretsCall := mockFuncCall{metaName: "*v1.Secret-test-" + enterprise.GetSplunkBackupSecretsName(backupName)}
+	secretsCall := mockFuncCall{metaName: "*v1.Secret-test-" + enterprise.GetSplunkSecretsName("stack2", enterprise.SplunkIndexer)}
+	pvcCalls := []mockFuncCall{
+		{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack2-cluster-master-0"},
+		{metaName: "*v1.PersistentVolumeClaim-test-pvc-var-splunk-stack2-cluster-master-0"},
+		{metaName: "*v1.PersistentVolumeClaim-test-pvc-etc-splunk-stack2-indexer-0"},
+		{metaName: "*v1.PersistentVolumeClaim-test-pvc-var-splunk-stack2-indexer-0"},
+	}
+
+	// secrets and persistent volume claims are restored for the target, which is then waited for
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	checkApply("RestoreManager.Apply(volumes)", enterprisev1.PhasePending)
+	getCalls := []mockFuncCall{backupCall, sourceCall, backupSecretsCall, secretsCall}
+	for n := range pvcNames {
+		getCalls = append(getCalls, snapshotCalls[n], pvcCalls[n])
+	}
+	c.checkCalls(t, "RestoreManager.Apply(volumes)", map[string][]mockFuncCall{
+		"Get":    append(getCalls, targetCall),
+		"Create": append([]mockFuncCall{secretsCall}, pvcCalls...),
+	})
+	if !cr.Status.VolumesRestored || cr.Status.BackupName != backupName || len(cr.Status.Volumes) != 4 {
+		t.Errorf("RestoreManager.Apply(volumes) status = %v; want 4 volumes restored from %s", cr.Status, backupName)
+	}
+	secrets := c.state[secretsCall.metaName].(*corev1.Secret)
+	if string(secrets.Data["password"]) != string(backupSecrets.Data["password"]) {
+		t.Errorf("RestoreManager.Apply(volumes) did not restore secrets from backup %s", backupName)
+	}
+	pvc := c.state[pvcCalls[2].metaName].(*corev1.PersistentVolumeClaim)
+	snapshotName := enterprise.GetVolumeSnapshotName(backupName, pvcNames[2])
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != snapshotName {
+		t.Errorf("RestoreManager.Apply(volumes) pvc data source = %v; want %s", pvc.Spec.DataSource, snapshotName)
+	}
+
+	// volumes are not restored again while waiting for the target to become ready
+	c.resetCalls()
+	target := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack2",
+			Namespace: "test",
+		},
+		Status: enterprisev1.IndexerClusterStatus{Phase: enterprisev1.PhasePending},
+	}
+	c.state[getStateKey(&target)] = &target
+	checkApply("RestoreManager.Apply(pending)", enterprisev1.PhasePending)
+	c.checkCalls(t, "RestoreManager.Apply(pending)", map[string][]mockFuncCall{"Get": {targetCall}})
+
+	// stale peers are removed from the cluster master once the target is ready
+	c.resetCalls()
+	target.Status.Phase = enterprisev1.PhaseReady
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{
+			Method: "GET",
+			URL:    "https://splunk-stack2-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/peers?count=0&output_mode=json",
+			Status: 200,
+			Body:   `{"entry":[{"name":"D39B1729-E2C5-4273-B9B2-534DA7C2F866","content":{"label":"splunk-stack2-indexer-0","status":"Up"}},{"name":"4D6E1E5A-8B4C-4E60-8A15-2C1A5A5B0F11","content":{"label":"splunk-stack1-indexer-0","status":"Down"}}]}`,
+		},
+		spltest.MockHTTPHandler{
+			Method: "POST",
+			URL:    "https://splunk-stack2-cluster-master-service.test.svc.cluster.local:8089/services/cluster/master/control/control/remove_peers?peers=4D6E1E5A-8B4C-4E60-8A15-2C1A5A5B0F11",
+			Status: 200,
+		},
+	)
+	checkApply("RestoreManager.Apply(ready)", enterprisev1.PhaseReady)
+	mockSplunkClient.CheckRequests(t, "RestoreManager.Apply(ready)")
+	c.checkCalls(t, "RestoreManager.Apply(ready)", map[string][]mockFuncCall{"Get": {targetCall, secretsCall}})
+	if len(cr.Status.RemovedPeers) != 1 || cr.Status.RemovedPeers[0] != "splunk-stack1-indexer-0" {
+		t.Errorf("RestoreManager.Apply(ready) removed peers = %v; want %v", cr.Status.RemovedPeers, []string{"splunk-stack1-indexer-0"})
+	}
+
+	// copies cannot be restored while the source is still running
+	cr.Status = enterprisev1.SplunkRestoreStatus{}
+	c.state[getStateKey(&source)] = &source
+	if _, err := mgr.Apply(c); err == nil {
+		t.Errorf("RestoreManager.Apply() returned nil; want error for running source")
+	}
+	delete(c.state, getStateKey(&source))
+
+	// existing volumes that were not restored from the backup are not replaced
+	c.state[pvcCalls[0].metaName] = &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-etc-splunk-stack2-cluster-master-0", Namespace: "test"}}
+	if _, err := mgr.Apply(c); err == nil {
+		t.Errorf("RestoreManager.Apply() returned nil; want error for existing persistent volume claim")
+	}
+
+	// backups must be ready to use
+	cr.Status = enterprisev1.SplunkRestoreStatus{}
+	cr.Spec.BackupName = "backup1-20200509100000"
+	if _, err := mgr.Apply(c); err == nil {
+		t.Errorf("RestoreManager.Apply() returned nil; want error for missing backup")
+	}
+	cr.Spec.BackupName = ""
+	backupCR.Status.Backups[0].ReadyToUse = false
+	if _, err := mgr.Apply(c); err == nil {
+		t.Errorf("RestoreManager.Apply() returned nil; want error for backup that is not ready to use")
+	}
+}
+
+func TestIsRestorePending(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	restore := enterprisev1.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore1",
+			Namespace: "test",
+		},
+	}
+	c := newMockClient()
+
+	// nothing to wait for without the restore annotation
+	pending, err := isRestorePending(c, &cr)
+	if pending || err != nil {
+		t.Errorf("isRestorePending() = %t, %v; want false, nil", pending, err)
+	}
+	c.checkCalls(t, "isRestorePending(no-annotation)", map[string][]mockFuncCall{})
+
+	// wait for a SplunkRestore that does not exist yet
+	cr.ObjectMeta.Annotations = map[string]string{enterprise.RestoreAnnotation: "restore1"}
+	pending, err = isRestorePending(c, &cr)
+	if !pending || err == nil {
+		t.Errorf("isRestorePending() = %t, %v; want true, error for missing SplunkRestore", pending, err)
+	}
+
+	// wait until volumes have been restored
+	c.state[getStateKey(&restore)] = &restore
+	pending, err = isRestorePending(c, &cr)
+	if !pending || err != nil {
+		t.Errorf("isRestorePending() = %t, %v; want true, nil", pending, err)
+	}
+	restore.Status.VolumesRestored = true
+	pending, err = isRestorePending(c, &cr)
+	if pending || err != nil {
+		t.Errorf("isRestorePending() = %t, %v; want false, nil", pending, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplyScalingSchedule replaces the number of replicas of a cluster with the number of replicas of the entry of its scaling
+// schedule that was scheduled most recently, if any, and reports it in status. It returns the time remaining until the
+// cluster is next scaled by its schedule, or zero if it has no scaling schedule.
+func ApplyScalingSchedule(cr enterprisev1.MetaObject, schedule []enterprisev1.ScalingScheduleEntry, replicas *int32, status *enterprisev1.ScalingScheduleStatus, now time.Time) time.Duration {
+	if len(schedule) == 0 {
+		*status = enterprisev1.ScalingScheduleStatus{}
+		return 0
+	}
+
+	entry, last, next := enterprise.GetScalingScheduleEntry(schedule, now)
+	status.NextScheduleTime = metav1.NewTime(next)
+	if entry == nil {
+		status.Schedule = ""
+		status.Replicas = 0
+		status.LastScheduleTime = metav1.Time{}
+	} else {
+		if !status.LastScheduleTime.Time.Equal(last) {
+			recordEvent(cr, corev1.EventTypeNormal, "ScheduledScaling", "Scaling to %d replicas as scheduled by \"%s\"", entry.Replicas, entry.Schedule)
+		}
+		status.Schedule = entry.Schedule
+		status.Replicas = entry.Replicas
+		status.LastScheduleTime = metav1.NewTime(last)
+		*replicas = entry.Replicas
+	}
+
+	if next.IsZero() {
+		return 0
+	}
+	return next.Sub(now)
+}
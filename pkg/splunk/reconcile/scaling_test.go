@@ -0,0 +1,71 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestApplyScalingSchedule(t *testing.T) {
+	cr := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Replicas = 5
+	now := time.Date(2020, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	// nothing changes without a scaling schedule
+	cr.Status.ScalingSchedule.Replicas = 10
+	next := ApplyScalingSchedule(&cr, cr.Spec.ScalingSchedule, &cr.Spec.Replicas, &cr.Status.ScalingSchedule, now)
+	if next != 0 || cr.Spec.Replicas != 5 || cr.Status.ScalingSchedule.Replicas != 0 {
+		t.Errorf("ApplyScalingSchedule(none) = %s, replicas %d, status %v; want 0, replicas 5, empty status", next, cr.Spec.Replicas, cr.Status.ScalingSchedule)
+	}
+
+	// replicas are taken from the entry scheduled most recently
+	cr.Spec.ScalingSchedule = []enterprisev1.ScalingScheduleEntry{
+		{Schedule: "0 8 * * 1-5", Replicas: 10},
+		{Schedule: "0 20 * * *", Replicas: 3},
+	}
+	next = ApplyScalingSchedule(&cr, cr.Spec.ScalingSchedule, &cr.Spec.Replicas, &cr.Status.ScalingSchedule, now)
+	if next != 8*time.Hour {
+		t.Errorf("ApplyScalingSchedule() = %s; want %s", next, 8*time.Hour)
+	}
+	if cr.Spec.Replicas != 10 {
+		t.Errorf("ApplyScalingSchedule() replicas = %d; want %d", cr.Spec.Replicas, 10)
+	}
+	want := enterprisev1.ScalingScheduleStatus{
+		Schedule:         "0 8 * * 1-5",
+		Replicas:         10,
+		LastScheduleTime: metav1.NewTime(time.Date(2020, time.June, 10, 8, 0, 0, 0, time.UTC)),
+		NextScheduleTime: metav1.NewTime(time.Date(2020, time.June, 10, 20, 0, 0, 0, time.UTC)),
+	}
+	if got := cr.Status.ScalingSchedule; got.Schedule != want.Schedule || got.Replicas != want.Replicas || !got.LastScheduleTime.Equal(&want.LastScheduleTime) || !got.NextScheduleTime.Equal(&want.NextScheduleTime) {
+		t.Errorf("ApplyScalingSchedule() status = %v; want %v", got, want)
+	}
+
+	// overnight, the cluster is scaled down
+	cr.Spec.Replicas = 5
+	next = ApplyScalingSchedule(&cr, cr.Spec.ScalingSchedule, &cr.Spec.Replicas, &cr.Status.ScalingSchedule, now.Add(10*time.Hour))
+	if next != 10*time.Hour || cr.Spec.Replicas != 3 || cr.Status.ScalingSchedule.Replicas != 3 {
+		t.Errorf("ApplyScalingSchedule(overnight) = %s, replicas %d, status %v; want %s, replicas 3", next, cr.Spec.Replicas, cr.Status.ScalingSchedule, 10*time.Hour)
+	}
+}
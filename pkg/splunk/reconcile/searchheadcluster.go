@@ -17,14 +17,16 @@ package reconcile
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
@@ -32,10 +34,23 @@ import (
 
 // ApplySearchHeadCluster reconciles the state for a Splunk Enterprise search head cluster.
 func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHeadCluster) (reconcile.Result, error) {
-	// unless modified, reconcile for this object will be requeued after 5 seconds
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplySearchHeadCluster(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
 	result := reconcile.Result{
 		Requeue:      true,
-		RequeueAfter: time.Second * 5,
+		RequeueAfter: getRequeueInterval(cr),
 	}
 	scopedLog := log.WithName("ApplySearchHeadCluster").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
 
@@ -45,15 +60,27 @@ func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHead
 		return result, err
 	}
 
+	// scale the number of search heads according to the scaling schedule, if configured
+	nextScheduledScaling := ApplyScalingSchedule(cr, cr.Spec.ScalingSchedule, &cr.Spec.Replicas, &cr.Status.ScalingSchedule, time.Now())
+
 	// updates status after function completes
+	oldPhase := cr.Status.Phase
 	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
 	cr.Status.DeployerPhase = enterprisev1.PhaseError
 	cr.Status.Replicas = cr.Spec.Replicas
-	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-search-head", cr.GetIdentifier())
+	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-search-head", enterprise.GetSearchHeadClusterIdentifier(cr, enterprise.GetSearchHeadClusterActiveColor(cr)))
 	if cr.Status.Members == nil {
 		cr.Status.Members = []enterprisev1.SearchHeadClusterMemberStatus{}
 	}
 	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
 		err = client.Status().Update(context.TODO(), cr)
 		if err != nil {
 			scopedLog.Error(err, "Status update failed")
@@ -62,8 +89,9 @@ func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHead
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
-		terminating, err := CheckSplunkDeletion(cr, client)
-		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
 			cr.Status.Phase = enterprisev1.PhaseTerminating
 			cr.Status.DeployerPhase = enterprisev1.PhaseTerminating
 		} else {
@@ -72,20 +100,50 @@ func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHead
 		return result, err
 	}
 
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// upgrade to the image requested using the splunk-image annotation, once linked resources have been upgraded in order
+	upgradePending, err := ApplyUpgradeImage(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.GetSplunkStatefulsetName(enterprise.SplunkDeployer, cr.GetIdentifier()))
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update general config resources
 	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead)
 	if err != nil {
 		return result, err
 	}
 
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkSearchHead, enterprise.GetSearchHeadClusterDNSNames(cr))
+	if err != nil {
+		return result, err
+	}
+
 	// create or update a headless search head cluster service
-	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkSearchHead, true))
+	err = ApplyService(client, enterprise.GetSearchHeadClusterService(cr, enterprise.GetSearchHeadClusterActiveColor(cr), true))
 	if err != nil {
 		return result, err
 	}
 
 	// create or update a regular search head cluster service
-	err = ApplyService(client, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkSearchHead, false))
+	err = ApplyService(client, enterprise.GetSearchHeadClusterService(cr, enterprise.GetSearchHeadClusterActiveColor(cr), false))
 	if err != nil {
 		return result, err
 	}
@@ -96,11 +154,65 @@ func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHead
 		return result, err
 	}
 
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkSearchHead)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkSearchHead)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkSearchHead, enterprise.GetSearchHeadClusterService(cr, enterprise.GetSearchHeadClusterActiveColor(cr), false))
+	services = append(services, enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkDeployer, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkDeployer, false))...)
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkSearchHead, enterprise.SplunkDeployer)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update app repository configuration (apps are pushed to search heads by the deployer)
+	appManager := AppRepoManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, status: &cr.Status.AppRepo, newS3Client: splclient.NewS3Client, newAzureClient: splclient.NewAzureBlobClient}
+	apps, err := appManager.Apply(client, enterprise.SplunkDeployer)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve apps staged on the deployer from referenced ConfigMaps
+	deployerApps, err := getDeploymentAppConfigMaps(client, cr, cr.Spec.DeployerApps)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured (these are pushed to members by the deployer)
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkDeployer)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update statefulset for the deployer
 	statefulSet, err := enterprise.GetDeployerStatefulSet(cr)
 	if err != nil {
 		return result, err
 	}
+	if apps != nil {
+		// restart deployer to push any changes to app packages
+		enterprise.SetAppsChecksum(&statefulSet.Spec.Template, enterprise.GetAppsChecksum(apps))
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetDeploymentAppsChecksum(&statefulSet.Spec.Template, deployerApps)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
 	deployerManager := DefaultStatefulSetPodManager{}
 	phase, err := deployerManager.Update(client, statefulSet, 1)
 	if err != nil {
@@ -108,22 +220,158 @@ func ApplySearchHeadCluster(client ControllerClient, cr *enterprisev1.SearchHead
 	}
 	cr.Status.DeployerPhase = phase
 
+	// search head cluster members are upgraded after the deployer
+	mgr := SearchHeadClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client), newS3Client: splclient.NewS3Client}
+	if enterprise.GetRequestedImage(cr.GetObjectMeta()) == cr.Spec.Image && cr.Status.DeployerPhase != enterprisev1.PhaseReady {
+		upgradePending = true
+		err = keepCurrentImage(client, cr.GetNamespace(), enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, mgr.getIdentifier()), &cr.Spec.CommonSplunkSpec)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// back up the KV store before members are upgraded to a new image, if enabled
+	backupPending, err := mgr.BackupKVStore(client, time.Now())
+	if err != nil {
+		return result, err
+	}
+	if backupPending {
+		upgradePending = true
+		err = keepCurrentImage(client, cr.GetNamespace(), enterprise.GetSplunkStatefulsetName(enterprise.SplunkSearchHead, mgr.getIdentifier()), &cr.Spec.CommonSplunkSpec)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// with the blue/green upgrade strategy, new images are rolled out to replacement members instead of recycling members
+	var replacing bool
+	if enterprise.IsBlueGreenUpgradeEnabled(&cr.Spec) || cr.Status.BlueGreen.Image != "" {
+		statefulSet, err = enterprise.GetSearchHeadReplacementStatefulSet(cr)
+		if err != nil {
+			return result, err
+		}
+		enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+		enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+		enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+		replacing, err = mgr.ApplyReplacement(client, statefulSet)
+		if err != nil {
+			return result, err
+		}
+	}
+
 	// create or update statefulset for the search heads
 	statefulSet, err = enterprise.GetSearchHeadStatefulSet(cr)
 	if err != nil {
 		return result, err
 	}
-	mgr := SearchHeadClusterPodManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: splclient.NewSplunkClient}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+
+	// limit the number of pods that may be evicted at the same time
+	err = ApplyPodDisruptionBudget(client, enterprise.GetSplunkPodDisruptionBudget(statefulSet, cr.Spec.MaxUnavailable))
+	if err != nil {
+		return result, err
+	}
 	phase, err = mgr.Update(client, statefulSet, cr.Spec.Replicas)
 	if err != nil {
 		return result, err
 	}
 	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+	if cr.Status.Reason == "" && phase != enterprisev1.PhaseReady && (!cr.Status.Initialized || !cr.Status.CaptainReady) {
+		cr.Status.Reason, cr.Status.Message = "SearchHeadClusterNotReady", "waiting for the search head cluster to be initialized and elect a captain"
+	}
+	hosts := strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkSearchHead, mgr.getIdentifier(), cr.Spec.Replicas, false), ",")
 
-	// no need to requeue if everything is ready
+	// verify that the cluster is healthy after an upgrade, before reporting it as ready
+	if cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.DeployerPhase == enterprisev1.PhaseReady {
+		err = verifyUpgradeHealth(cr, enterprise.GetSplunkImage(cr.Spec.Image), &cr.Status.VerifiedImage, func() error {
+			return mgr.checkHealth(hosts)
+		})
+		if err != nil {
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+	}
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if (cr.Status.Phase == enterprisev1.PhaseReady && cr.Status.DeployerPhase == enterprisev1.PhaseReady) || enterprise.IsSecretsRotationPending(secrets) {
+		deployerHost := resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkDeployer, cr.GetIdentifier(), false))
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, append([]string{deployerHost}, hosts...))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// track installed apps and register search heads with the monitoring console, once they are ready
 	if cr.Status.Phase == enterprisev1.PhaseReady {
+		if cr.Status.DeployerPhase == enterprisev1.PhaseReady {
+			cr.Status.Apps = apps
+
+			// push apps staged on the deployer to members, if they have changed since the last push
+			err = mgr.ApplyDeployerBundle(deployerApps, confFiles)
+			if err != nil {
+				return result, err
+			}
+
+			// premium apps are set up on the deployer, which must then push them to members again
+			deployerHost := resources.GetServiceFQDN(cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkDeployer, cr.GetIdentifier(), false))
+			premiumAppManager := PremiumAppManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+			var completed bool
+			completed, err = premiumAppManager.Apply([]string{deployerHost}, enterprise.SplunkDeployer, apps, &cr.Status.PremiumApps)
+			if err != nil {
+				return result, err
+			}
+			if completed {
+				err = mgr.pushDeployerBundle()
+				if err != nil {
+					return result, err
+				}
+			}
+		}
+		mcManager := MonitoringConsolePeerManager{log: scopedLog, cr: cr, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mcManager.RegisterPeers(client, hosts)
+		if err != nil {
+			return result, err
+		}
+
+		// collect load metrics from search heads, if enabled
+		collectLoadMetrics(&cr.Spec.LoadMetrics, enterprise.SplunkSearchHead, hosts, secrets, getSplunkClientFactory(client), &cr.Status.LoadMetrics)
+
+		// report the cluster as degraded if the KV store is down or stuck resyncing on any members
+		mgr.updateKVStoreStatus(time.Now())
+		err = checkKVStoreMembers(cr.Status.Members, time.Now())
+		if err != nil {
+			recordEvent(cr, corev1.EventTypeWarning, "KVStoreDegraded", "%v", err)
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, no upgrades are waiting for linked resources, and no members are being replaced
+	if cr.Status.Phase == enterprisev1.PhaseReady && !upgradePending && !replacing {
 		result.Requeue = false
 	}
+
+	// wait until the cluster is next scaled by its schedule, if configured
+	if !result.Requeue && nextScheduledScaling > 0 {
+		result.Requeue = true
+		result.RequeueAfter = nextScheduledScaling
+	}
+
+	// keep refreshing load metrics, if enabled
+	if cr.Spec.LoadMetrics.Enabled && (!result.Requeue || result.RequeueAfter > loadMetricsInterval) {
+		result.Requeue = true
+		result.RequeueAfter = loadMetricsInterval
+	}
+
+	// keep refreshing the KV store status of members, once they are ready
+	if cr.Status.Phase == enterprisev1.PhaseReady && (!result.Requeue || result.RequeueAfter > kvStoreStatusInterval) {
+		result.Requeue = true
+		result.RequeueAfter = kvStoreStatusInterval
+	}
 	return result, nil
 }
 
@@ -133,6 +381,10 @@ type SearchHeadClusterPodManager struct {
 	cr              *enterprisev1.SearchHeadCluster
 	secrets         *corev1.Secret
 	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+	newS3Client     func(endpoint, region, accessKey, secretKey string) *splclient.S3Client
+
+	// color of the search head cluster members managed, or empty for the members currently serving searches
+	color string
 }
 
 // Update for SearchHeadClusterPodManager handles all updates for a statefulset of search heads
@@ -163,7 +415,7 @@ func (mgr *SearchHeadClusterPodManager) PrepareScaleDown(n int32) (bool, error)
 	}
 
 	// pod is quarantined; decommission it
-	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), n)
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), n)
 	mgr.log.Info("Removing member from search head cluster", "memberName", memberName)
 	c := mgr.getClient(n)
 	err = c.RemoveSearchHeadClusterMember()
@@ -177,10 +429,18 @@ func (mgr *SearchHeadClusterPodManager) PrepareScaleDown(n int32) (bool, error)
 
 // PrepareRecycle for SearchHeadClusterPodManager prepares search head pod to be recycled for updates; it returns true when ready
 func (mgr *SearchHeadClusterPodManager) PrepareRecycle(n int32) (bool, error) {
-	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), n)
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), n)
 
 	switch mgr.cr.Status.Members[n].Status {
 	case "Up":
+		// Transfer captaincy to another member before detaining the captain
+		if memberName == mgr.cr.Status.Captain {
+			transferred, err := mgr.transferCaptaincy(n)
+			if err != nil || transferred {
+				return false, err
+			}
+		}
+
 		// Detain search head
 		mgr.log.Info("Detaining search head cluster member", "memberName", memberName)
 		c := mgr.getClient(n)
@@ -207,7 +467,7 @@ func (mgr *SearchHeadClusterPodManager) PrepareRecycle(n int32) (bool, error) {
 
 // FinishRecycle for SearchHeadClusterPodManager completes recycle event for search head pod; it returns true when complete
 func (mgr *SearchHeadClusterPodManager) FinishRecycle(n int32) (bool, error) {
-	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), n)
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), n)
 
 	switch mgr.cr.Status.Members[n].Status {
 	case "Up":
@@ -225,12 +485,152 @@ func (mgr *SearchHeadClusterPodManager) FinishRecycle(n int32) (bool, error) {
 	return false, fmt.Errorf("Status=%s", mgr.cr.Status.Members[n].Status)
 }
 
+// recycleOrder for SearchHeadClusterPodManager returns the ordinals of all search heads in the order they should be recycled.
+// Members are recycled from the highest ordinal to the lowest, except for the captain which is always recycled last.
+func (mgr *SearchHeadClusterPodManager) recycleOrder(replicas int32) []int32 {
+	order := make([]int32, 0, replicas)
+	captain := int32(-1)
+	for n := replicas - 1; n >= 0; n-- {
+		if n < int32(len(mgr.cr.Status.Members)) && mgr.cr.Status.Members[n].Name == mgr.cr.Status.Captain {
+			captain = n
+			continue
+		}
+		order = append(order, n)
+	}
+	if captain >= 0 {
+		order = append(order, captain)
+	}
+	return order
+}
+
+// transferCaptaincy for SearchHeadClusterPodManager transfers captaincy from member n to another member that is up.
+// It returns false if no other member is available to become captain.
+func (mgr *SearchHeadClusterPodManager) transferCaptaincy(n int32) (bool, error) {
+	memberName := mgr.cr.Status.Members[n].Name
+	var targetName string
+	for i, member := range mgr.cr.Status.Members {
+		if int32(i) != n && member.Status == "Up" {
+			targetName = member.Name
+			break
+		}
+	}
+	if targetName == "" {
+		mgr.log.Info("No search head cluster member is available to take over captaincy", "memberName", memberName)
+		return false, nil
+	}
+
+	c := mgr.getClient(n)
+	members, err := c.GetSearchHeadCaptainMembers()
+	if err != nil {
+		return false, err
+	}
+	target, ok := members[targetName]
+	if !ok {
+		return false, fmt.Errorf("Unable to find search head cluster member %s", targetName)
+	}
+
+	mgr.log.Info("Transferring search head cluster captaincy", "memberName", memberName, "newCaptain", targetName)
+	err = c.TransferSearchHeadCaptaincy(target.ManagementURI)
+	if err != nil {
+		return false, err
+	}
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "CaptaincyTransferred", "Transferred search head cluster captaincy from %s to %s", memberName, targetName)
+	return true, nil
+}
+
+// ApplyDeployerBundle for SearchHeadClusterPodManager pushes the configuration bundle from the deployer to the search head cluster
+// members, if the ConfigMaps providing deployer apps or the Secret containing conf files have changed since the most recent
+// successful push, or if a push was requested using the bundle-push annotation. The push is tracked in status. It does nothing
+// if no deployer apps or conf files are configured and no push was requested.
+func (mgr *SearchHeadClusterPodManager) ApplyDeployerBundle(configMaps []*corev1.ConfigMap, confFiles *corev1.Secret) error {
+	checksum := mgr.cr.Status.BundlePush.Checksum
+	if len(configMaps) > 0 {
+		checksum = enterprise.GetDeploymentAppsChecksum(configMaps)
+	}
+	confFilesChecksum := mgr.cr.Status.BundlePush.ConfFilesChecksum
+	if confFiles != nil {
+		confFilesChecksum = enterprise.GetConfFilesChecksum(confFiles)
+	}
+	if mgr.cr.Status.BundlePush.Checksum == checksum && mgr.cr.Status.BundlePush.ConfFilesChecksum == confFilesChecksum &&
+		!enterprise.IsBundlePushRequested(mgr.cr, mgr.cr.Status.BundlePush.PushRequest) {
+		return nil
+	}
+
+	err := mgr.pushDeployerBundle()
+	if err != nil {
+		return err
+	}
+
+	mgr.cr.Status.BundlePush = enterprisev1.SearchHeadClusterBundlePushStatus{Checksum: checksum, ConfFilesChecksum: confFilesChecksum, LastSuccessTime: metav1.Now(), PushRequest: enterprise.GetBundlePushRequest(mgr.cr)}
+	recordEvent(mgr.cr, corev1.EventTypeNormal, "BundlePushed", "Pushed deployer apps to search head cluster members")
+	return nil
+}
+
+// pushDeployerBundle for SearchHeadClusterPodManager pushes the configuration bundle from the deployer to the search head cluster members
+func (mgr *SearchHeadClusterPodManager) pushDeployerBundle() error {
+	// the bundle may be pushed to any member, which distributes it to the rest of the cluster
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), 0)
+	target := fmt.Sprintf("https://%s:8089", resources.GetServiceFQDN(mgr.cr.GetNamespace(),
+		fmt.Sprintf("%s.%s", memberName, enterprise.GetSplunkServiceName(enterprise.SplunkSearchHead, mgr.getIdentifier(), true))))
+	mgr.log.Info("Pushing deployer bundle to search head cluster members", "target", target)
+	return mgr.getDeployerClient().ApplySearchHeadClusterBundle(target)
+}
+
+// getDeployerClient for SearchHeadClusterPodManager returns a SplunkClient for the deployer
+func (mgr *SearchHeadClusterPodManager) getDeployerClient() *splclient.SplunkClient {
+	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(), enterprise.GetSplunkServiceName(enterprise.SplunkDeployer, mgr.cr.GetIdentifier(), false))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
+
 // getClient for SearchHeadClusterPodManager returns a SplunkClient for the member n
 func (mgr *SearchHeadClusterPodManager) getClient(n int32) *splclient.SplunkClient {
-	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), n)
+	memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), n)
 	fqdnName := resources.GetServiceFQDN(mgr.cr.GetNamespace(),
-		fmt.Sprintf("%s.%s", memberName, enterprise.GetSplunkServiceName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), true)))
-	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(mgr.secrets.Data["password"]))
+		fmt.Sprintf("%s.%s", memberName, enterprise.GetSplunkServiceName(enterprise.SplunkSearchHead, mgr.getIdentifier(), true)))
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", enterprise.GetAppliedAdminPassword(mgr.secrets))
+}
+
+// getIdentifier for SearchHeadClusterPodManager returns the identifier used to name the search head statefulset and its pods
+func (mgr *SearchHeadClusterPodManager) getIdentifier() string {
+	color := mgr.color
+	if color == "" {
+		color = enterprise.GetSearchHeadClusterActiveColor(mgr.cr)
+	}
+	return enterprise.GetSearchHeadClusterIdentifier(mgr.cr, color)
+}
+
+// getMemberApps for SearchHeadClusterPodManager returns the versions of the apps staged on the deployer that are installed on a
+// member, or nil if no deployer apps are configured or they could not be retrieved
+func (mgr *SearchHeadClusterPodManager) getMemberApps(c *splclient.SplunkClient, memberName string) map[string]string {
+	if len(mgr.cr.Spec.DeployerApps) == 0 {
+		return nil
+	}
+	localApps, err := c.GetLocalApps()
+	if err != nil {
+		mgr.log.Error(err, "Unable to retrieve apps installed on search head cluster member", "memberName", memberName)
+		return nil
+	}
+	apps := make(map[string]string)
+	for _, app := range mgr.cr.Spec.DeployerApps {
+		if localApp, ok := localApps[app.Name]; ok {
+			apps[app.Name] = localApp.Version
+		}
+	}
+	return apps
+}
+
+// checkHealth returns an error if the captain is not ready, if any members are not up, or if the KV store is not ready on any
+// of the hosts
+func (mgr *SearchHeadClusterPodManager) checkHealth(hosts []string) error {
+	if !mgr.cr.Status.CaptainReady {
+		return fmt.Errorf("search head cluster captain is not ready")
+	}
+	for _, member := range mgr.cr.Status.Members {
+		if member.Status != "Up" {
+			return fmt.Errorf("search head cluster member %s is not up (status=%s)", member.Name, member.Status)
+		}
+	}
+	return checkKVStoreHealth(hosts, mgr.secrets, mgr.newSplunkClient)
 }
 
 // updateStatus for SearchHeadClusterPodManager uses the REST API to update the status for a SearcHead custom resource
@@ -245,7 +645,7 @@ func (mgr *SearchHeadClusterPodManager) updateStatus(statefulSet *appsv1.Statefu
 	gotCaptainInfo := false
 	for n := int32(0); n < statefulSet.Status.Replicas; n++ {
 		c := mgr.getClient(n)
-		memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.cr.GetIdentifier(), n)
+		memberName := enterprise.GetSplunkStatefulsetPodName(enterprise.SplunkSearchHead, mgr.getIdentifier(), n)
 		memberStatus := enterprisev1.SearchHeadClusterMemberStatus{Name: memberName}
 		memberInfo, err := c.GetSearchHeadClusterMemberInfo()
 		if err == nil {
@@ -254,6 +654,7 @@ func (mgr *SearchHeadClusterPodManager) updateStatus(statefulSet *appsv1.Statefu
 			memberStatus.Registered = memberInfo.Registered
 			memberStatus.ActiveHistoricalSearchCount = memberInfo.ActiveHistoricalSearchCount
 			memberStatus.ActiveRealtimeSearchCount = memberInfo.ActiveRealtimeSearchCount
+			memberStatus.Apps = mgr.getMemberApps(c, memberName)
 		} else {
 			mgr.log.Error(err, "Unable to retrieve search head cluster member info", "memberName", memberName)
 		}
@@ -274,6 +675,10 @@ func (mgr *SearchHeadClusterPodManager) updateStatus(statefulSet *appsv1.Statefu
 		}
 
 		if n < int32(len(mgr.cr.Status.Members)) {
+			// keep the KV store state, which is refreshed separately once all members are ready
+			if mgr.cr.Status.Members[n].Name == memberName {
+				memberStatus.KVStore = mgr.cr.Status.Members[n].KVStore
+			}
 			mgr.cr.Status.Members[n] = memberStatus
 		} else {
 			mgr.cr.Status.Members = append(mgr.cr.Status.Members, memberStatus)
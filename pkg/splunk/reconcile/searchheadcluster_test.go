@@ -24,8 +24,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
 )
 
@@ -36,10 +37,11 @@ func TestApplySearchHeadCluster(t *testing.T) {
 		{metaName: "*v1.Service-test-splunk-stack1-search-head-service"},
 		{metaName: "*v1.Service-test-splunk-stack1-deployer-service"},
 		{metaName: "*v1.StatefulSet-test-splunk-stack1-deployer"},
+		{metaName: "*v1beta1.PodDisruptionBudget-test-splunk-stack1-search-head"},
 		{metaName: "*v1.StatefulSet-test-splunk-stack1-search-head"},
 	}
 	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": funcCalls}
-	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[4], funcCalls[5]}}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": []mockFuncCall{funcCalls[4], funcCalls[6]}}
 	statefulSet := enterprisev1.SearchHeadCluster{
 		TypeMeta: metav1.TypeMeta{
 			Kind: "SearchHeadCluster",
@@ -251,3 +253,193 @@ func TestSearchHeadClusterPodManager(t *testing.T) {
 	method = "SearchHeadClusterPodManager.Update(Remove Member)"
 	searchHeadClusterPodManagerTester(t, method, mockHandlers, 1, enterprisev1.PhaseScalingDown, statefulSet, wantCalls, nil, statefulSet, pod, pvcList[0], pvcList[1])
 }
+
+func TestSearchHeadClusterCaptainRecycle(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Status.Captain = "splunk-stack1-search-head-1"
+	cr.Status.Members = []enterprisev1.SearchHeadClusterMemberStatus{
+		{Name: "splunk-stack1-search-head-0", Status: "Up"},
+		{Name: "splunk-stack1-search-head-1", Status: "Up"},
+		{Name: "splunk-stack1-search-head-2", Status: "Up"},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &SearchHeadClusterPodManager{
+		log:     log.WithName("TestSearchHeadClusterCaptainRecycle"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// captain is recycled last
+	want := []int32{2, 0, 1}
+	got := getRecycleOrder(mgr, 3)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("getRecycleOrder() = %v; want %v", got, want)
+	}
+	got = getRecycleOrder(&DefaultStatefulSetPodManager{}, 3)
+	if len(got) != 3 || got[0] != 2 || got[1] != 1 || got[2] != 0 {
+		t.Errorf("getRecycleOrder(DefaultStatefulSetPodManager) = %v; want [2 1 0]", got)
+	}
+
+	// captaincy is transferred before the captain is detained
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/shcluster/captain/members?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"content":{"label":"splunk-stack1-search-head-0","mgmt_url":"https://splunk-stack1-search-head-0.splunk-stack1-search-head-headless.test.svc.cluster.local:8089"}},{"content":{"label":"splunk-stack1-search-head-1","mgmt_url":"https://splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local:8089","is_captain":true}}]}`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/shcluster/member/consensus/default/transfer_captaincy",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	ready, err := mgr.PrepareRecycle(1)
+	if ready || err != nil {
+		t.Errorf("PrepareRecycle(captain) = %t, %v; want false, nil", ready, err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterCaptainRecycle(transfer)")
+
+	// captain is detained if no other member is available
+	cr.Status.Members[0].Status = "ManualDetention"
+	cr.Status.Members[2].Status = "ManualDetention"
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-search-head-1.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/shcluster/member/control/control/set_manual_detention?manual_detention=on",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	ready, err = mgr.PrepareRecycle(1)
+	if ready || err != nil {
+		t.Errorf("PrepareRecycle(captain) = %t, %v; want false, nil", ready, err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterCaptainRecycle(detain)")
+}
+
+func TestSearchHeadClusterDeployerBundle(t *testing.T) {
+	cr := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &SearchHeadClusterPodManager{
+		log:     log.WithName("TestSearchHeadClusterDeployerBundle"),
+		cr:      &cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// nothing to push without deployer apps
+	if err := mgr.ApplyDeployerBundle(nil, nil); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(no-apps)")
+
+	// bundle is pushed from the deployer, and tracked in status
+	configMaps := []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "sh-base"}, Data: map[string]string{"web.conf": "[settings]\n"}}}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-deployer-service.test.svc.cluster.local:8089/services/apps/deploy",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	if err := mgr.ApplyDeployerBundle(configMaps, nil); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(push)")
+	if cr.Status.BundlePush.Checksum == "" || cr.Status.BundlePush.LastSuccessTime.IsZero() {
+		t.Errorf("ApplyDeployerBundle() status.bundlePush = %v; want checksum and lastSuccessTime", cr.Status.BundlePush)
+	}
+
+	// bundle is not pushed again until deployer apps change
+	if err := mgr.ApplyDeployerBundle(configMaps, nil); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(no-change)")
+
+	// bundle is pushed again once when requested using the bundle-push annotation, even without deployer apps
+	cr.ObjectMeta.Annotations = map[string]string{enterprise.BundlePushAnnotation: "2020-06-01T10:00:00Z"}
+	checksum := cr.Status.BundlePush.Checksum
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-deployer-service.test.svc.cluster.local:8089/services/apps/deploy",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	if err := mgr.ApplyDeployerBundle(nil, nil); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	if err := mgr.ApplyDeployerBundle(configMaps, nil); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(requested)")
+	if cr.Status.BundlePush.PushRequest != "2020-06-01T10:00:00Z" || cr.Status.BundlePush.Checksum != checksum {
+		t.Errorf("ApplyDeployerBundle() status.bundlePush = %v; want pushRequest and unchanged checksum", cr.Status.BundlePush)
+	}
+
+	// bundle is pushed again when conf files change
+	confFiles := &corev1.Secret{Data: map[string][]byte{"limits.conf": []byte("[search]\nmax_searches_per_cpu = 2\n")}}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-deployer-service.test.svc.cluster.local:8089/services/apps/deploy",
+		Status: 200,
+		Err:    nil,
+		Body:   ``,
+	})
+	if err := mgr.ApplyDeployerBundle(configMaps, confFiles); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	if err := mgr.ApplyDeployerBundle(configMaps, confFiles); err != nil {
+		t.Errorf("ApplyDeployerBundle() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(conf-files)")
+	if cr.Status.BundlePush.ConfFilesChecksum != enterprise.GetConfFilesChecksum(confFiles) || cr.Status.BundlePush.Checksum != checksum {
+		t.Errorf("ApplyDeployerBundle() status.bundlePush = %v; want confFilesChecksum and unchanged checksum", cr.Status.BundlePush)
+	}
+
+	// versions of deployer apps installed on members are reported
+	cr.Spec.DeployerApps = []enterprisev1.DeploymentAppSpec{{Name: "sh_base", ConfigMapRef: "sh-base"}, {Name: "sh_missing", ConfigMapRef: "sh-missing"}}
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-search-head-0.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/apps/local?count=0&output_mode=json",
+		Status: 200,
+		Err:    nil,
+		Body:   `{"entry":[{"name":"search","content":{"version":"8.0.3"}},{"name":"sh_base","content":{"version":"1.2.0"}}]}`,
+	})
+	apps := mgr.getMemberApps(mgr.getClient(0), "splunk-stack1-search-head-0")
+	if len(apps) != 1 || apps["sh_base"] != "1.2.0" {
+		t.Errorf("getMemberApps() = %v; want map[sh_base:1.2.0]", apps)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterDeployerBundle(member-apps)")
+}
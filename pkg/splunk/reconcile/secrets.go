@@ -0,0 +1,196 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// syncSharedSecrets updates the idxc_secret and pass4SymmKey values of a Kubernetes Secret to match those of referenced
+// resources, if provided. It returns true if the Secret was changed.
+func syncSharedSecrets(secrets *corev1.Secret, idxcSecret, pass4SymmKey []byte) bool {
+	changed := false
+	if len(idxcSecret) > 0 && !bytes.Equal(secrets.Data["idxc_secret"], idxcSecret) {
+		secrets.Data["idxc_secret"] = idxcSecret
+		changed = true
+	}
+	if len(pass4SymmKey) > 0 && !bytes.Equal(secrets.Data["pass4SymmKey"], pass4SymmKey) {
+		secrets.Data["pass4SymmKey"] = pass4SymmKey
+		changed = true
+	}
+	return changed
+}
+
+// syncReferencedSecrets updates the values of a Kubernetes Secret to match those provided by a Secret referenced using
+// secretRef, or retrieved from a secrets provider, if provided. Values that are missing from the provided Secret are kept,
+// and the version of values retrieved from a secrets provider is recorded. It returns true if the Secret was changed.
+func syncReferencedSecrets(secrets, refSecrets *corev1.Secret) bool {
+	if refSecrets == nil {
+		return false
+	}
+	changed := false
+	for _, key := range []string{"hec_token", "password", "pass4SymmKey", "idxc_secret", "shc_secret"} {
+		value := refSecrets.Data[key]
+		if len(value) > 0 && !bytes.Equal(secrets.Data[key], value) {
+			secrets.Data[key] = value
+			changed = true
+		}
+	}
+	for _, key := range []string{enterprise.SecretsProviderVersionAnnotation, enterprise.SecretsProviderSyncedAnnotation} {
+		value, ok := refSecrets.GetAnnotations()[key]
+		if ok && secrets.GetAnnotations()[key] != value {
+			if secrets.Annotations == nil {
+				secrets.Annotations = make(map[string]string)
+			}
+			secrets.Annotations[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// SecretsProviderManager is used to retrieve secret values for Splunk Enterprise instances from an external secrets manager
+type SecretsProviderManager struct {
+	log                logr.Logger
+	cr                 enterprisev1.MetaObject
+	spec               *enterprisev1.SecretsProviderSpec
+	instanceType       enterprise.InstanceType
+	newSecretsProvider func(spec *enterprisev1.SecretsProviderSpec, credentials map[string][]byte) splclient.SecretsProvider
+}
+
+// newSecretsProvider returns a SecretsProvider for an external secrets manager, using credentials from a Kubernetes Secret.
+func newSecretsProvider(spec *enterprisev1.SecretsProviderSpec, credentials map[string][]byte) splclient.SecretsProvider {
+	if spec.Type == enterprise.SecretsProviderVault {
+		return splclient.NewVaultClient(spec.Endpoint, string(credentials["vault_token"]), spec.Role)
+	}
+	return splclient.NewSecretsManagerClient(spec.Endpoint, spec.Region, string(credentials["aws_access_key"]), string(credentials["aws_secret_key"]))
+}
+
+// GetSecrets retrieves the current version of secret values from the secrets manager, once per sync interval. It returns
+// a Secret containing the values along with annotations recording their version, or nil if a secrets manager is not
+// configured or it is not yet time to check for a new version.
+func (mgr *SecretsProviderManager) GetSecrets(c ControllerClient, now time.Time) (*corev1.Secret, error) {
+	if !enterprise.IsSecretsProviderConfigured(mgr.spec) {
+		return nil, nil
+	}
+
+	// values are always retrieved before secrets are first created
+	var current corev1.Secret
+	namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: enterprise.GetSplunkSecretsName(mgr.cr.GetIdentifier(), mgr.instanceType)}
+	err := c.Get(context.TODO(), namespacedName, &current)
+	if err == nil && !enterprise.IsSecretsProviderSyncDue(&current, mgr.spec, now) {
+		return nil, nil
+	}
+
+	var credentials map[string][]byte
+	if mgr.spec.CredentialsSecretRef != "" {
+		var secret corev1.Secret
+		namespacedName = types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: mgr.spec.CredentialsSecretRef}
+		err = c.Get(context.TODO(), namespacedName, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get credentials for secrets provider: %v", err)
+		}
+		credentials = secret.Data
+	}
+
+	values, version, err := mgr.newSecretsProvider(mgr.spec, credentials).GetSecret(mgr.spec.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get secret %s from secrets provider: %v", mgr.spec.Path, err)
+	}
+	if current.GetAnnotations()[enterprise.SecretsProviderVersionAnnotation] != version {
+		mgr.log.Info("Retrieved new version of secrets from secrets provider", "path", mgr.spec.Path, "version", version)
+	}
+
+	result := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				enterprise.SecretsProviderVersionAnnotation: version,
+				enterprise.SecretsProviderSyncedAnnotation:  now.UTC().Format(time.RFC3339),
+			},
+		},
+		Data: make(map[string][]byte),
+	}
+	for k, v := range values {
+		result.Data[k] = []byte(v)
+	}
+	return result, nil
+}
+
+// SecretsRotationManager is used to roll new secret values out to Splunk Enterprise instances
+type SecretsRotationManager struct {
+	log             logr.Logger
+	spec            *enterprisev1.CommonSplunkSpec
+	secrets         *corev1.Secret
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// Apply rolls out new secret values to each host (FQDN). It should be called once all hosts are ready, or to finish a rollout
+// that is already in progress. New values are generated first if rotation is scheduled and due. The admin password is changed
+// on each host using the REST API, and the Secret's default.yml is then updated so that the remaining values are applied when
+// pods are recycled (in the same order used for any other change). It does nothing if there are no new secret values to apply.
+func (mgr *SecretsRotationManager) Apply(c ControllerClient, hosts []string) error {
+	if !enterprise.IsSecretsRotationPending(mgr.secrets) {
+		if !enterprise.IsSecretsRotationDue(mgr.secrets, mgr.spec.SecretRotationInterval, time.Now()) {
+			return nil
+		}
+		// pass4SymmKey is shared with the license master, which rotates it for all of its license slaves
+		mgr.log.Info("Generating new secrets for scheduled rotation")
+		enterprise.RotateSplunkSecrets(mgr.secrets, mgr.spec.LicenseMasterRef.Name == "" && !enterprise.IsExternalLicenseMasterConfigured(mgr.spec))
+
+		// save new values before using them, so that they are not lost if a rollout is interrupted
+		if err := UpdateResource(c, mgr.secrets); err != nil {
+			return err
+		}
+	}
+
+	oldPassword := enterprise.GetAppliedAdminPassword(mgr.secrets)
+	newPassword := string(mgr.secrets.Data["password"])
+	if oldPassword != newPassword {
+		for _, host := range hosts {
+			managementURI := fmt.Sprintf("https://%s:8089", host)
+
+			// skip hosts that already use the new password (for example, if a previous attempt was interrupted)
+			if mgr.newSplunkClient(managementURI, "admin", newPassword).CheckCredentials() == nil {
+				continue
+			}
+
+			mgr.log.Info("Changing admin password", "host", host)
+			err := mgr.newSplunkClient(managementURI, "admin", oldPassword).UpdateAdminPassword(newPassword)
+			if err != nil {
+				return fmt.Errorf("Unable to change admin password for %s: %v", host, err)
+			}
+		}
+	}
+
+	mgr.log.Info("Applying new secrets")
+	mgr.secrets.Data["default.yml"] = enterprise.GetSplunkSecretsDefaults(mgr.secrets.Data)
+	if mgr.secrets.Annotations == nil {
+		mgr.secrets.Annotations = make(map[string]string)
+	}
+	mgr.secrets.Annotations[enterprise.SecretsRotatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return UpdateResource(c, mgr.secrets)
+}
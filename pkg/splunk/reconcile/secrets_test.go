@@ -0,0 +1,307 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+)
+
+func TestSyncSharedSecrets(t *testing.T) {
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"idxc_secret":  []byte("idxc1"),
+			"pass4SymmKey": []byte("key1"),
+		},
+	}
+	if syncSharedSecrets(secrets, nil, nil) {
+		t.Errorf("syncSharedSecrets() = true without referenced values; want false")
+	}
+	if syncSharedSecrets(secrets, []byte("idxc1"), []byte("key1")) {
+		t.Errorf("syncSharedSecrets() = true for unchanged values; want false")
+	}
+	if !syncSharedSecrets(secrets, nil, []byte("key2")) {
+		t.Errorf("syncSharedSecrets() = false for new pass4SymmKey; want true")
+	}
+	if string(secrets.Data["pass4SymmKey"]) != "key2" || string(secrets.Data["idxc_secret"]) != "idxc1" {
+		t.Errorf("syncSharedSecrets() data = %v; want pass4SymmKey=key2 idxc_secret=idxc1", secrets.Data)
+	}
+}
+
+func TestSyncReferencedSecrets(t *testing.T) {
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password":    []byte("pass1"),
+			"hec_token":   []byte("token1"),
+			"idxc_secret": []byte("idxc1"),
+		},
+	}
+	if syncReferencedSecrets(secrets, nil) {
+		t.Errorf("syncReferencedSecrets() = true without referenced Secret; want false")
+	}
+	refSecrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password":  []byte("pass1"),
+			"hec_token": []byte(""),
+		},
+	}
+	if syncReferencedSecrets(secrets, refSecrets) {
+		t.Errorf("syncReferencedSecrets() = true for unchanged values; want false")
+	}
+	refSecrets.Data["password"] = []byte("pass2")
+	refSecrets.Data["shc_secret"] = []byte("shc2")
+	if !syncReferencedSecrets(secrets, refSecrets) {
+		t.Errorf("syncReferencedSecrets() = false for new password; want true")
+	}
+	if string(secrets.Data["password"]) != "pass2" || string(secrets.Data["shc_secret"]) != "shc2" ||
+		string(secrets.Data["hec_token"]) != "token1" || string(secrets.Data["idxc_secret"]) != "idxc1" {
+		t.Errorf("syncReferencedSecrets() data = %v; want password=pass2 shc_secret=shc2 hec_token=token1 idxc_secret=idxc1", secrets.Data)
+	}
+
+	// versions of values retrieved from a secrets provider are recorded
+	refSecrets.Annotations = map[string]string{enterprise.SecretsProviderVersionAnnotation: "3"}
+	if !syncReferencedSecrets(secrets, refSecrets) {
+		t.Errorf("syncReferencedSecrets() = false for new version; want true")
+	}
+	if secrets.Annotations[enterprise.SecretsProviderVersionAnnotation] != "3" {
+		t.Errorf("syncReferencedSecrets() annotations = %v; want version 3", secrets.Annotations)
+	}
+}
+
+// mockSecretsProvider is used to test SecretsProviderManager
+type mockSecretsProvider struct {
+	values  map[string]string
+	version string
+	err     error
+	paths   []string
+}
+
+// GetSecret for mockSecretsProvider returns its values and version
+func (p *mockSecretsProvider) GetSecret(path string) (map[string]string, string, error) {
+	p.paths = append(p.paths, path)
+	return p.values, p.version, p.err
+}
+
+func TestSecretsProviderManagerGetSecrets(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	provider := &mockSecretsProvider{values: map[string]string{"password": "s3cr3t"}, version: "1"}
+	var gotCredentials map[string][]byte
+	mgr := &SecretsProviderManager{
+		log:          log,
+		cr:           &cr,
+		spec:         &enterprisev1.SecretsProviderSpec{Type: enterprise.SecretsProviderAWS, Path: "splunk", SyncInterval: "5m", CredentialsSecretRef: "aws-keys"},
+		instanceType: enterprise.SplunkStandalone,
+		newSecretsProvider: func(spec *enterprisev1.SecretsProviderSpec, credentials map[string][]byte) splclient.SecretsProvider {
+			gotCredentials = credentials
+			return provider
+		},
+	}
+	credentials := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-keys",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{"aws_access_key": []byte("key1")},
+	}
+	secretsCalls := []mockFuncCall{{metaName: "*v1.Secret-test-splunk-stack1-standalone-secrets"}}
+	credentialsCalls := []mockFuncCall{{metaName: "*v1.Secret-test-aws-keys"}}
+	now := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// missing credentials are an error
+	c := newMockClient()
+	if _, err := mgr.GetSecrets(c, now); err == nil {
+		t.Errorf("GetSecrets() returned nil; want error for missing credentials")
+	}
+
+	// values are retrieved before secrets are created
+	c = newMockClient()
+	c.state[getStateKey(&credentials)] = &credentials
+	got, err := mgr.GetSecrets(c, now)
+	if err != nil {
+		t.Fatalf("GetSecrets() returned %v; want nil", err)
+	}
+	if string(got.Data["password"]) != "s3cr3t" || got.Annotations[enterprise.SecretsProviderVersionAnnotation] != "1" ||
+		got.Annotations[enterprise.SecretsProviderSyncedAnnotation] != "2020-05-01T00:00:00Z" {
+		t.Errorf("GetSecrets() = %v; want password=s3cr3t version=1 synced=2020-05-01T00:00:00Z", got)
+	}
+	if string(gotCredentials["aws_access_key"]) != "key1" || !reflect.DeepEqual(provider.paths, []string{"splunk"}) {
+		t.Errorf("GetSecrets() used credentials %v and paths %v; want key1 and [splunk]", gotCredentials, provider.paths)
+	}
+	c.checkCalls(t, "TestSecretsProviderManagerGetSecrets(create)", map[string][]mockFuncCall{"Get": {secretsCalls[0], credentialsCalls[0]}})
+
+	// values are only retrieved once per sync interval
+	secrets := enterprise.GetSplunkSecrets(&cr, enterprise.SplunkStandalone, nil, nil)
+	secrets.Annotations = got.Annotations
+	c.state[getStateKey(secrets)] = secrets
+	c.resetCalls()
+	got, err = mgr.GetSecrets(c, now.Add(time.Minute))
+	if got != nil || err != nil {
+		t.Errorf("GetSecrets() = %v, %v; want nil, nil before sync interval", got, err)
+	}
+	c.checkCalls(t, "TestSecretsProviderManagerGetSecrets(not-due)", map[string][]mockFuncCall{"Get": secretsCalls})
+	provider.version = "2"
+	c.resetCalls()
+	got, err = mgr.GetSecrets(c, now.Add(5*time.Minute))
+	if err != nil || got == nil || got.Annotations[enterprise.SecretsProviderVersionAnnotation] != "2" {
+		t.Errorf("GetSecrets() = %v, %v; want version 2, nil after sync interval", got, err)
+	}
+
+	// errors from the secrets provider are returned
+	provider.err = errors.New("AccessDenied")
+	if _, err = mgr.GetSecrets(c, now.Add(10*time.Minute)); err == nil {
+		t.Errorf("GetSecrets() returned nil; want error from secrets provider")
+	}
+
+	// nothing is done if a secrets provider is not configured
+	c = newMockClient()
+	mgr.spec = &enterprisev1.SecretsProviderSpec{}
+	got, err = mgr.GetSecrets(c, now)
+	if got != nil || err != nil {
+		t.Errorf("GetSecrets() = %v, %v; want nil, nil", got, err)
+	}
+	c.checkCalls(t, "TestSecretsProviderManagerGetSecrets(not-configured)", map[string][]mockFuncCall{})
+}
+
+func TestSecretsRotationManagerApply(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := enterprise.GetSplunkSecrets(&cr, enterprise.SplunkStandalone, nil, nil)
+	hosts := []string{
+		"splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local",
+		"splunk-stack1-standalone-1.splunk-stack1-standalone-headless.test.svc.cluster.local",
+	}
+	var mockSplunkClient *spltest.MockHTTPClient
+	addHandlers := func(checkStatus, updateStatus int) {
+		mockSplunkClient = &spltest.MockHTTPClient{}
+		for _, host := range hosts {
+			mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+				Method: "GET",
+				URL:    "https://" + host + ":8089/services/authentication/current-context?count=0&output_mode=json",
+				Status: checkStatus,
+			})
+			if checkStatus != 200 {
+				mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+					Method: "POST",
+					URL:    "https://" + host + ":8089/services/authentication/users/admin",
+					Status: updateStatus,
+				})
+			}
+		}
+	}
+	mgr := &SecretsRotationManager{
+		log:     log.WithName("TestSecretsRotationManagerApply"),
+		spec:    &cr.Spec.CommonSplunkSpec,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	secretsCall := mockFuncCall{metaName: "*v1.Secret-test-splunk-stack1-standalone-secrets"}
+	c := newMockClient()
+
+	// nothing to do without rotation interval or changes
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.Apply(c, hosts); err != nil {
+		t.Errorf("SecretsRotationManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSecretsRotationManagerApply(no-change)")
+	c.checkCalls(t, "TestSecretsRotationManagerApply(no-change)", map[string][]mockFuncCall{})
+
+	// scheduled rotation is due, since secret has never been rotated
+	cr.Spec.SecretRotationInterval = "720h"
+	oldPassword := string(secrets.Data["password"])
+	oldPass4SymmKey := string(secrets.Data["pass4SymmKey"])
+	addHandlers(401, 200)
+	if err := mgr.Apply(c, hosts); err != nil {
+		t.Errorf("SecretsRotationManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSecretsRotationManagerApply(scheduled)")
+	c.checkCalls(t, "TestSecretsRotationManagerApply(scheduled)", map[string][]mockFuncCall{"Update": {secretsCall, secretsCall}})
+	if string(secrets.Data["password"]) == oldPassword || string(secrets.Data["pass4SymmKey"]) == oldPass4SymmKey {
+		t.Errorf("SecretsRotationManager.Apply() did not rotate password and pass4SymmKey")
+	}
+	if enterprise.IsSecretsRotationPending(secrets) || enterprise.GetAppliedAdminPassword(secrets) != string(secrets.Data["password"]) {
+		t.Errorf("SecretsRotationManager.Apply() did not apply new secrets to default.yml")
+	}
+	if _, ok := secrets.GetAnnotations()[enterprise.SecretsRotatedAnnotation]; !ok {
+		t.Errorf("SecretsRotationManager.Apply() did not set %s annotation", enterprise.SecretsRotatedAnnotation)
+	}
+
+	// next rotation is not due yet
+	c.resetCalls()
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	if err := mgr.Apply(c, hosts); err != nil {
+		t.Errorf("SecretsRotationManager.Apply() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestSecretsRotationManagerApply(not-due)", map[string][]mockFuncCall{})
+
+	// manual changes are applied, skipping hosts that already use the new password
+	c.resetCalls()
+	secrets.Data["password"] = []byte("n3wp@ssw0rd")
+	addHandlers(200, 200)
+	if err := mgr.Apply(c, hosts); err != nil {
+		t.Errorf("SecretsRotationManager.Apply() returned %v; want nil", err)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSecretsRotationManagerApply(manual)")
+	c.checkCalls(t, "TestSecretsRotationManagerApply(manual)", map[string][]mockFuncCall{"Update": {secretsCall}})
+	if enterprise.GetAppliedAdminPassword(secrets) != "n3wp@ssw0rd" {
+		t.Errorf("SecretsRotationManager.Apply() did not apply manually changed password")
+	}
+
+	// errors changing the admin password are returned, and new values remain pending
+	c.resetCalls()
+	secrets.Data["password"] = []byte("an0th3rp@ssw0rd")
+	addHandlers(401, 500)
+	if err := mgr.Apply(c, hosts); err == nil {
+		t.Errorf("SecretsRotationManager.Apply() returned nil; want error when admin password cannot be changed")
+	}
+	if !enterprise.IsSecretsRotationPending(secrets) {
+		t.Errorf("SecretsRotationManager.Apply() applied new secrets after an error")
+	}
+
+	// pass4SymmKey is not rotated for license slaves
+	cr.Spec.LicenseMasterRef.Name = "stack2"
+	secrets = enterprise.GetSplunkSecrets(&cr, enterprise.SplunkStandalone, nil, nil)
+	mgr.secrets = secrets
+	oldPass4SymmKey = string(secrets.Data["pass4SymmKey"])
+	addHandlers(401, 200)
+	if err := mgr.Apply(c, hosts); err != nil {
+		t.Errorf("SecretsRotationManager.Apply() returned %v; want nil", err)
+	}
+	if string(secrets.Data["pass4SymmKey"]) != oldPass4SymmKey {
+		t.Errorf("SecretsRotationManager.Apply() rotated pass4SymmKey for a license slave")
+	}
+}
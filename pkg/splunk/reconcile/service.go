@@ -29,19 +29,32 @@ func ApplyService(client ControllerClient, revised *corev1.Service) error {
 
 	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
 	var current corev1.Service
+	checksum := getDesiredChecksum([]interface{}{revised.GetLabels(), revised.GetAnnotations(), &revised.Spec})
 
 	err := client.Get(context.TODO(), namespacedName, &current)
 	if err != nil {
+		setDesiredChecksum(revised, checksum)
 		return CreateResource(client, revised)
 	}
 
 	// check for changes in service template
+	original := current.DeepCopy()
 	hasUpdates := MergeServiceSpecUpdates(&current.Spec, &revised.Spec, current.GetObjectMeta().GetName())
+	if MergeServiceMetaUpdates(&current.ObjectMeta, &revised.ObjectMeta, current.GetObjectMeta().GetName()) {
+		hasUpdates = true
+	}
+
+	// leave changes made outside of the operator unchanged if its owner only reports drift
+	if hasUpdates && !checkDrift(client, original, &current, checksum) {
+		*revised = *original
+		return nil
+	}
 	*revised = current // caller expects that object passed represents latest state
 
 	// only update if there are material differences, as determined by comparison function
 	if hasUpdates {
 		scopedLog.Info("Updating existing Service")
+		setDesiredChecksum(revised, checksum)
 		return UpdateResource(client, revised)
 	}
 
@@ -0,0 +1,61 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplySplunkPortGroupServices creates or updates the services used to expose groups of Splunk ports with types other than
+// ClusterIP, and deletes any services reported in status that are no longer used (for example, once their group uses
+// ClusterIP again). The endpoints of the services are reported in status.
+func ApplySplunkPortGroupServices(client ControllerClient, cr enterprisev1.MetaObject, services []*corev1.Service, status *[]enterprisev1.ServiceStatus) error {
+	// the services are updated to their current state, which includes any node ports and addresses assigned to them
+	var serviceStatus []enterprisev1.ServiceStatus
+	serviceNames := make(map[string]bool)
+	for _, service := range services {
+		err := ApplyService(client, service)
+		if err != nil {
+			return err
+		}
+		serviceNames[service.GetName()] = true
+		serviceStatus = append(serviceStatus, enterprise.GetServiceStatus(service))
+	}
+
+	// services that are no longer used are tracked using status, so that they are only deleted once
+	for _, prev := range *status {
+		if serviceNames[prev.Name] {
+			continue
+		}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: prev.Name}
+		var current corev1.Service
+		if err := client.Get(context.TODO(), namespacedName, &current); err != nil {
+			continue
+		}
+		log.WithName("ApplySplunkPortGroupServices").Info("Deleting unused service", "name", prev.Name, "namespace", cr.GetNamespace())
+		if err := client.Delete(context.TODO(), &current); err != nil {
+			return err
+		}
+	}
+
+	*status = serviceStatus
+	return nil
+}
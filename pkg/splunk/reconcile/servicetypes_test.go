@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplySplunkPortGroupServices(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	c := newMockClient()
+	apply := func() error {
+		regular := enterprise.GetSplunkService(&cr, cr.Spec.CommonSpec, enterprise.SplunkStandalone, false)
+		services := enterprise.GetSplunkPortGroupServices(&cr, &cr.Spec.ServiceTypes, enterprise.SplunkStandalone, regular)
+		return ApplySplunkPortGroupServices(c, &cr, services, &cr.Status.Services)
+	}
+
+	// nothing to do if every group uses ClusterIP
+	err := apply()
+	if err != nil || cr.Status.Services != nil {
+		t.Errorf("ApplySplunkPortGroupServices() = %v, status %v; want nil, nil", err, cr.Status.Services)
+	}
+	c.checkCalls(t, "TestApplySplunkPortGroupServices(clusterip)", map[string][]mockFuncCall{})
+
+	// services are created for groups that use other types
+	cr.Spec.ServiceTypes = enterprisev1.ServiceTypesSpec{Web: corev1.ServiceTypeNodePort, HEC: corev1.ServiceTypeLoadBalancer}
+	serviceCalls := []mockFuncCall{
+		{metaName: "*v1.Service-test-splunk-stack1-standalone-web"},
+		{metaName: "*v1.Service-test-splunk-stack1-standalone-hec"},
+	}
+	err = apply()
+	if err != nil {
+		t.Errorf("ApplySplunkPortGroupServices() returned %v; want nil", err)
+	}
+	c.checkCalls(t, "TestApplySplunkPortGroupServices(create)", map[string][]mockFuncCall{"Get": serviceCalls, "Create": serviceCalls})
+	if len(cr.Status.Services) != 2 || cr.Status.Services[1].Name != "splunk-stack1-standalone-hec" || cr.Status.Services[1].Address != "" {
+		t.Errorf("ApplySplunkPortGroupServices() status = %v; want 2 services without addresses", cr.Status.Services)
+	}
+
+	// addresses are reported once load balancers have one
+	service := c.state["*v1.Service-test-splunk-stack1-standalone-hec"].(*corev1.Service)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "hec.elb.amazonaws.com"}}
+	c.resetCalls()
+	err = apply()
+	if err != nil {
+		t.Errorf("ApplySplunkPortGroupServices() returned %v; want nil", err)
+	}
+	if cr.Status.Services[1].Address != "hec.elb.amazonaws.com" || cr.Status.Services[1].Port != 8088 {
+		t.Errorf("ApplySplunkPortGroupServices() status = %v; want hec.elb.amazonaws.com:8088", cr.Status.Services[1])
+	}
+	c.checkCalls(t, "TestApplySplunkPortGroupServices(no-change)", map[string][]mockFuncCall{"Get": serviceCalls})
+
+	// services are deleted once their groups use ClusterIP again
+	cr.Spec.ServiceTypes.HEC = corev1.ServiceTypeClusterIP
+	c.resetCalls()
+	err = apply()
+	if err != nil {
+		t.Errorf("ApplySplunkPortGroupServices() returned %v; want nil", err)
+	}
+	if len(cr.Status.Services) != 1 || cr.Status.Services[0].Name != "splunk-stack1-standalone-web" {
+		t.Errorf("ApplySplunkPortGroupServices() status = %v; want web service only", cr.Status.Services)
+	}
+	c.checkCalls(t, "TestApplySplunkPortGroupServices(delete)", map[string][]mockFuncCall{
+		"Get":    serviceCalls,
+		"Delete": serviceCalls[1:],
+	})
+}
@@ -17,22 +17,52 @@ package reconcile
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"github.com/splunk/splunk-operator/pkg/splunk/spark"
 )
 
+const (
+	// interval used to check the number of active DFS searches when spark worker autoscaling is enabled
+	sparkAutoscalingInterval = 30 * time.Second
+
+	// minimum time to wait after scaling spark workers before scaling them down
+	sparkScaleDownDelay = 5 * time.Minute
+)
+
 // ApplySpark reconciles the Deployments and Services for a Spark cluster.
 func ApplySpark(client ControllerClient, cr *enterprisev1.Spark) (reconcile.Result, error) {
 
-	// unless modified, reconcile for this object will be requeued after 5 seconds
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplySpark(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
 	result := reconcile.Result{
 		Requeue:      true,
-		RequeueAfter: time.Second * 5,
+		RequeueAfter: getRequeueInterval(cr),
 	}
+	scopedLog := log.WithName("ApplySpark").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
 
 	// validate and updates defaults for CR
 	err := spark.ValidateSparkSpec(&cr.Spec)
@@ -41,17 +71,26 @@ func ApplySpark(client ControllerClient, cr *enterprisev1.Spark) (reconcile.Resu
 	}
 
 	// updates status after function completes
+	oldPhase := cr.Status.Phase
 	cr.Status.Phase = enterprisev1.PhaseError
-	cr.Status.Replicas = cr.Spec.Replicas
+	cr.Status.Reason, cr.Status.Message = "", ""
 	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-spark-worker", cr.GetIdentifier())
 	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
 		client.Status().Update(context.TODO(), cr)
 	}()
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
-		terminating, err := CheckSplunkDeletion(cr, client)
-		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
 			cr.Status.Phase = enterprisev1.PhaseTerminating
 		} else {
 			result.Requeue = false
@@ -59,6 +98,12 @@ func ApplySpark(client ControllerClient, cr *enterprisev1.Spark) (reconcile.Resu
 		return result, err
 	}
 
+	// create service account used to run pods on OpenShift, if enabled
+	err = ApplyOpenShiftServiceAccount(client, cr.GetNamespace())
+	if err != nil {
+		return result, err
+	}
+
 	// create or update a service for spark master
 	err = ApplyService(client, spark.GetSparkService(cr, spark.SparkMaster, false))
 	if err != nil {
@@ -82,17 +127,136 @@ func ApplySpark(client ControllerClient, cr *enterprisev1.Spark) (reconcile.Resu
 		return result, err
 	}
 
+	// scale spark workers based on the number of active DFS searches, if enabled
+	replicas := cr.Spec.Replicas
+	if spark.IsSparkAutoscalingEnabled(&cr.Spec) {
+		autoscaler := SparkWorkerAutoscaler{log: scopedLog, cr: cr, newSplunkClient: getSplunkClientFactory(client)}
+		replicas, err = autoscaler.GetReplicas(client)
+		if err != nil {
+			return result, err
+		}
+	}
+	cr.Status.Replicas = replicas
+
 	// create or update deployment for spark worker
 	deployment, err = spark.GetSparkDeployment(cr, spark.SparkWorker)
 	if err != nil {
 		return result, err
 	}
+	*deployment.Spec.Replicas = replicas
 	cr.Status.Phase, err = ApplyDeployment(client, deployment)
 	cr.Status.ReadyReplicas = deployment.Status.ReadyReplicas
 	if err != nil {
 		cr.Status.Phase = enterprisev1.PhaseError
-	} else if cr.Status.Phase == enterprisev1.PhaseReady {
-		result.Requeue = false
+	} else if cr.Status.Phase != enterprisev1.PhaseReady {
+		cr.Status.Reason, cr.Status.Message = getDeploymentPhaseReason(client, deployment, cr.Status.Phase)
+	} else {
+		if spark.IsSparkAutoscalingEnabled(&cr.Spec) {
+			// keep checking for changes in search load
+			result.RequeueAfter = sparkAutoscalingInterval
+		} else {
+			result.Requeue = false
+		}
 	}
 	return result, err
 }
+
+// SparkWorkerAutoscaler is used to scale spark workers based on the number of active DFS searches
+type SparkWorkerAutoscaler struct {
+	log             logr.Logger
+	cr              *enterprisev1.Spark
+	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+}
+
+// GetReplicas returns the number of spark workers needed for the DFS searches that are active on all search heads using
+// the spark cluster, and updates the status of the Spark resource. Workers are not scaled down until sparkScaleDownDelay
+// has passed since they were last scaled, to avoid restarting them while search load fluctuates.
+func (mgr *SparkWorkerAutoscaler) GetReplicas(c ControllerClient) (int32, error) {
+	activeSearches, err := mgr.getActiveSearches(c)
+	if err != nil {
+		return 0, err
+	}
+	mgr.cr.Status.ActiveSearches = activeSearches
+
+	current := mgr.cr.Status.Replicas
+	desired := spark.GetSparkWorkerReplicas(&mgr.cr.Spec, activeSearches)
+	if desired == current {
+		return current, nil
+	}
+	if desired < current && current <= mgr.cr.Spec.MaxReplicas && time.Since(mgr.cr.Status.LastScaleTime.Time) < sparkScaleDownDelay {
+		return current, nil
+	}
+
+	if current > 0 {
+		mgr.log.Info("Autoscaling spark workers", "activeSearches", activeSearches, "replicas", desired)
+		recordEvent(mgr.cr, corev1.EventTypeNormal, "WorkersAutoscaled", "Scaling spark workers from %d to %d for %d active DFS searches", current, desired, activeSearches)
+	}
+	mgr.cr.Status.LastScaleTime = metav1.Now()
+	return desired, nil
+}
+
+// getActiveSearches for SparkWorkerAutoscaler returns the total number of active DFS searches on all Standalone and
+// SearchHeadCluster instances in the same namespace that use the spark cluster
+func (mgr *SparkWorkerAutoscaler) getActiveSearches(c ControllerClient) (int32, error) {
+	var total int32
+	listOpts := []client.ListOption{client.InNamespace(mgr.cr.GetNamespace())}
+
+	standaloneList := enterprisev1.StandaloneList{}
+	err := c.List(context.TODO(), &standaloneList, listOpts...)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to list Standalone resources: %v", err)
+	}
+	for i := range standaloneList.Items {
+		cr := &standaloneList.Items[i]
+		if mgr.isSparkRef(cr, cr.Spec.SparkRef) {
+			total += mgr.countActiveSearches(c, cr, enterprise.SplunkStandalone, cr.Spec.Replicas)
+		}
+	}
+
+	shcList := enterprisev1.SearchHeadClusterList{}
+	err = c.List(context.TODO(), &shcList, listOpts...)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to list SearchHeadCluster resources: %v", err)
+	}
+	for i := range shcList.Items {
+		cr := &shcList.Items[i]
+		if mgr.isSparkRef(cr, cr.Spec.SparkRef) {
+			total += mgr.countActiveSearches(c, cr, enterprise.SplunkSearchHead, cr.Spec.Replicas)
+		}
+	}
+
+	return total, nil
+}
+
+// isSparkRef for SparkWorkerAutoscaler returns true if a custom resource's sparkRef refers to the spark cluster
+func (mgr *SparkWorkerAutoscaler) isSparkRef(cr enterprisev1.MetaObject, ref corev1.ObjectReference) bool {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+	return ref.Name == mgr.cr.GetIdentifier() && namespace == mgr.cr.GetNamespace()
+}
+
+// countActiveSearches for SparkWorkerAutoscaler returns the number of active DFS searches on each search head of a custom
+// resource. Search heads that cannot be queried (for example, while they are starting up) are not counted.
+func (mgr *SparkWorkerAutoscaler) countActiveSearches(c ControllerClient, cr enterprisev1.MetaObject, instanceType enterprise.InstanceType, replicas int32) int32 {
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: enterprise.GetSplunkSecretsName(cr.GetIdentifier(), instanceType)}
+	var secrets corev1.Secret
+	err := c.Get(context.TODO(), namespacedName, &secrets)
+	if err != nil {
+		mgr.log.Error(err, "Unable to get secrets for search heads", "name", cr.GetIdentifier())
+		return 0
+	}
+	password := enterprise.GetAppliedAdminPassword(&secrets)
+
+	var count int32
+	for _, host := range strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), instanceType, cr.GetIdentifier(), replicas, false), ",") {
+		n, err := mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", host), "admin", password).GetActiveDFSSearchCount()
+		if err != nil {
+			mgr.log.Error(err, "Unable to get active DFS searches", "host", host)
+			continue
+		}
+		count += int32(n)
+	}
+	return count
+}
@@ -15,12 +15,18 @@
 package reconcile
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
 )
 
 func TestApplySpark(t *testing.T) {
@@ -59,3 +65,103 @@ func TestApplySpark(t *testing.T) {
 	}
 	splunkDeletionTester(t, revised, deleteFunc)
 }
+
+func TestSparkWorkerAutoscaler(t *testing.T) {
+	cr := enterprisev1.Spark{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.SparkSpec{
+			MinReplicas:       1,
+			MaxReplicas:       4,
+			SearchesPerWorker: 2,
+		},
+	}
+	cr.Status.Replicas = 1
+	secrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      enterprise.GetSplunkSecretsName("stack1", enterprise.SplunkSearchHead),
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"password": []byte{'1', '2', '3'},
+		},
+	}
+	c := newMockClient()
+	c.state[getStateKey(secrets)] = secrets
+	c.listObj = &enterprisev1.SearchHeadClusterList{
+		Items: []enterprisev1.SearchHeadCluster{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+				Spec: enterprisev1.SearchHeadClusterSpec{
+					Replicas: 2,
+					SparkRef: corev1.ObjectReference{Name: "stack1"},
+				},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{Name: "stack2", Namespace: "test"},
+				Spec: enterprisev1.SearchHeadClusterSpec{
+					Replicas: 3,
+					SparkRef: corev1.ObjectReference{Name: "stack2"},
+				},
+			},
+		},
+	}
+
+	// each search head returns the given number of active DFS searches
+	var mockSplunkClient *spltest.MockHTTPClient
+	setActiveSearches := func(counts ...int) {
+		mockSplunkClient = &spltest.MockHTTPClient{}
+		for n, count := range counts {
+			jobs := []string{}
+			for i := 0; i < count; i++ {
+				jobs = append(jobs, fmt.Sprintf(`{"name":"| dfsjob [| search index=main%d]","content":{"isDone":false}}`, i))
+			}
+			mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+				Method: "GET",
+				URL:    fmt.Sprintf("https://splunk-stack1-search-head-%d.splunk-stack1-search-head-headless.test.svc.cluster.local:8089/services/search/jobs?count=0&output_mode=json", n),
+				Status: 200,
+				Err:    nil,
+				Body:   fmt.Sprintf(`{"entry":[%s]}`, strings.Join(jobs, ",")),
+			})
+		}
+	}
+	mgr := &SparkWorkerAutoscaler{
+		log: log.WithName("TestSparkWorkerAutoscaler"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+	test := func(method string, want, wantActive int32) {
+		got, err := mgr.GetReplicas(c)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", method, err)
+		}
+		if got != want || cr.Status.ActiveSearches != wantActive {
+			t.Errorf("%s = %d with %d active searches; want %d with %d", method, got, cr.Status.ActiveSearches, want, wantActive)
+		}
+		mockSplunkClient.CheckRequests(t, method)
+		cr.Status.Replicas = got
+	}
+
+	// scale up for active searches on search heads using the spark cluster
+	setActiveSearches(3, 2)
+	test("SparkWorkerAutoscaler.GetReplicas(scale up)", 3, 5)
+	if cr.Status.LastScaleTime.IsZero() {
+		t.Errorf("SparkWorkerAutoscaler.GetReplicas() did not set LastScaleTime")
+	}
+
+	// never scale above maxReplicas
+	setActiveSearches(6, 6)
+	test("SparkWorkerAutoscaler.GetReplicas(max)", 4, 12)
+
+	// wait before scaling down
+	setActiveSearches(0, 0)
+	test("SparkWorkerAutoscaler.GetReplicas(delay scale down)", 4, 0)
+	cr.Status.LastScaleTime = metav1.NewTime(time.Now().Add(-sparkScaleDownDelay))
+	setActiveSearches(0, 0)
+	test("SparkWorkerAutoscaler.GetReplicas(scale down)", 1, 0)
+}
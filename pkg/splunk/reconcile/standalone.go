@@ -17,22 +17,39 @@ package reconcile
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
 	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 )
 
 // ApplyStandalone reconciles the StatefulSet for N standalone instances of Splunk Enterprise.
 func ApplyStandalone(client ControllerClient, cr *enterprisev1.Standalone) (reconcile.Result, error) {
 
-	// unless modified, reconcile for this object will be requeued after 5 seconds
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyStandalone(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
 	result := reconcile.Result{
 		Requeue:      true,
-		RequeueAfter: time.Second * 5,
+		RequeueAfter: getRequeueInterval(cr),
 	}
+	scopedLog := log.WithName("ApplyStandalone").WithValues("name", cr.GetIdentifier(), "namespace", cr.GetNamespace())
 
 	// validate and updates defaults for CR
 	err := enterprise.ValidateStandaloneSpec(&cr.Spec)
@@ -41,17 +58,27 @@ func ApplyStandalone(client ControllerClient, cr *enterprisev1.Standalone) (reco
 	}
 
 	// updates status after function completes
+	oldPhase := cr.Status.Phase
 	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
 	cr.Status.Replicas = cr.Spec.Replicas
 	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-standalone", cr.GetIdentifier())
 	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
 		client.Status().Update(context.TODO(), cr)
 	}()
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
-		terminating, err := CheckSplunkDeletion(cr, client)
-		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted { // still waiting for instances to be decommissioned, or retrying after an error
 			cr.Status.Phase = enterprisev1.PhaseTerminating
 		} else {
 			result.Requeue = false
@@ -59,8 +86,75 @@ func ApplyStandalone(client ControllerClient, cr *enterprisev1.Standalone) (reco
 		return result, err
 	}
 
+	// add finalizers used to clean up when the custom resource is deleted
+	err = ApplySplunkFinalizers(cr, client, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// wait for persistent volume claims to be restored from a backup, if requested
+	pending, err := isRestorePending(client, cr)
+	if pending {
+		cr.Status.Phase = enterprisev1.PhasePending
+		cr.Status.Reason, cr.Status.Message = getRestorePendingReason(cr)
+		return result, err
+	}
+
+	// upgrade to the image requested using the splunk-image annotation, once linked resources have been upgraded in order
+	upgradePending, err := ApplyUpgradeImage(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.GetSplunkStatefulsetName(enterprise.SplunkStandalone, cr.GetIdentifier()))
+	if err != nil {
+		return result, err
+	}
+
+	// block changes that would result in unsupported version skew with referenced resources
+	err = ValidateReferencedVersions(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// convert instances into peers of an indexer cluster once it is ready, if a migration has been requested
+	migrationTarget, err := ApplyStandaloneMigration(client, cr)
+	if err != nil {
+		return result, err
+	}
+	spec := cr.Spec.CommonSplunkSpec
+	if migrationTarget != nil {
+		spec = enterprise.GetStandaloneMigrationSpec(&cr.Spec.CommonSplunkSpec, migrationTarget)
+	}
+
 	// create or update general config resources
-	_, err = ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	secrets, err := ApplySplunkConfig(client, cr, spec, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkStandalone, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkStandalone))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update smartstore configuration
+	smartstore, err := ApplySmartStoreConfig(client, cr, &cr.Spec.SmartStore, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update app repository configuration
+	appManager := AppRepoManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, status: &cr.Status.AppRepo, newS3Client: splclient.NewS3Client, newAzureClient: splclient.NewAzureBlobClient}
+	apps, err := appManager.Apply(client, enterprise.SplunkStandalone)
 	if err != nil {
 		return result, err
 	}
@@ -71,11 +165,53 @@ func ApplyStandalone(client ControllerClient, cr *enterprisev1.Standalone) (reco
 		return result, err
 	}
 
+	// create or update an ingress for Splunk Web and HEC, if configured
+	err = ApplySplunkIngress(client, cr, &cr.Spec.Ingress, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update services for groups of Splunk ports that do not use ClusterIP, if configured
+	services := enterprise.GetSplunkPortGroupServices(cr, &cr.Spec.ServiceTypes, enterprise.SplunkStandalone, enterprise.GetSplunkService(cr, cr.Spec.CommonSpec, enterprise.SplunkStandalone, false))
+	err = ApplySplunkPortGroupServices(client, cr, services, &cr.Status.Services)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update ServiceMonitors for Prometheus, if enabled
+	err = ApplySplunkServiceMonitors(client, cr, &cr.Spec.Monitoring, enterprise.SplunkStandalone)
+	if err != nil {
+		return result, err
+	}
+
 	// create or update statefulset
-	statefulSet, err := enterprise.GetStandaloneStatefulSet(cr)
+	var statefulSet *appsv1.StatefulSet
+	if migrationTarget != nil {
+		statefulSet, err = enterprise.GetStandalonePeerStatefulSet(cr, migrationTarget)
+	} else {
+		statefulSet, err = enterprise.GetStandaloneStatefulSet(cr)
+	}
 	if err != nil {
 		return result, err
 	}
+	if smartstore != nil {
+		// restart pods to load any changes to smartstore configuration
+		enterprise.SetSmartStoreChecksum(&statefulSet.Spec.Template, enterprise.GetSmartStoreChecksum(smartstore))
+	}
+	if apps != nil {
+		// restart pods to install any changes to app packages
+		enterprise.SetAppsChecksum(&statefulSet.Spec.Template, enterprise.GetAppsChecksum(apps))
+	}
+	enterprise.SetSecretsChecksum(&statefulSet.Spec.Template, secrets)
+	enterprise.SetTLSChecksum(&statefulSet.Spec.Template, tls)
+	enterprise.SetDefaultsChecksum(&statefulSet.Spec.Template, defaults)
+	enterprise.SetConfFilesChecksum(&statefulSet.Spec.Template, confFiles)
 	mgr := DefaultStatefulSetPodManager{}
 	phase, err := mgr.Update(client, statefulSet, cr.Spec.Replicas)
 	cr.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
@@ -83,9 +219,66 @@ func ApplyStandalone(client ControllerClient, cr *enterprisev1.Standalone) (reco
 		return result, err
 	}
 	cr.Status.Phase = phase
+	cr.Status.Reason, cr.Status.Message = getStatefulSetPhaseReason(client, statefulSet, phase)
+	hosts := strings.Split(enterprise.GetSplunkStatefulsetUrls(cr.GetNamespace(), enterprise.SplunkStandalone, cr.GetIdentifier(), cr.Spec.Replicas, false), ",")
+
+	// verify that instances are healthy after an upgrade, before reporting them as ready
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		err = verifyUpgradeHealth(cr, enterprise.GetSplunkImage(cr.Spec.Image), &cr.Status.VerifiedImage, func() error {
+			return checkKVStoreHealth(hosts, secrets, getSplunkClientFactory(client))
+		})
+		if err != nil {
+			cr.Status.Phase = enterprisev1.PhaseError
+			return result, err
+		}
+	}
+
+	// track standalone instances that have joined the indexer cluster they are migrated into, once they are ready
+	if cr.Status.Phase == enterprisev1.PhaseReady && migrationTarget != nil {
+		err = verifyStandaloneMigration(client, cr, migrationTarget, getSplunkClientFactory(client))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// roll out new secrets once all instances are ready, or to finish a rollout already in progress
+	if cr.Status.Phase == enterprisev1.PhaseReady || enterprise.IsSecretsRotationPending(secrets) {
+		secretsManager := SecretsRotationManager{log: scopedLog, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = secretsManager.Apply(client, hosts)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// reload changes to conf files once all instances are ready, if configUpdateStrategy is reload
+	reloaded := true
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		reloadManager := ConfigReloadManager{log: scopedLog, cr: cr, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		reloaded, err = reloadManager.Apply(hosts, confFiles, &cr.Status.ConfFilesChecksum)
+		if err != nil {
+			return result, err
+		}
+	}
 
-	// no need to requeue if everything is ready
+	// track installed apps and register standalone instances with the monitoring console, once they are ready
 	if cr.Status.Phase == enterprisev1.PhaseReady {
+		cr.Status.Apps = apps
+		premiumAppManager := PremiumAppManager{log: scopedLog, cr: cr, spec: &cr.Spec.AppRepo, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		_, err = premiumAppManager.Apply(hosts, enterprise.SplunkStandalone, apps, &cr.Status.PremiumApps)
+		if err != nil {
+			return result, err
+		}
+		mcManager := MonitoringConsolePeerManager{log: scopedLog, cr: cr, spec: &cr.Spec.CommonSplunkSpec, secrets: secrets, newSplunkClient: getSplunkClientFactory(client)}
+		err = mcManager.RegisterPeers(client, hosts)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// no need to requeue if everything is ready, no upgrades are waiting for linked resources, no conf files are waiting
+	// to be reloaded, and no migration is waiting to start or complete
+	migrated := cr.Status.Migration == nil || cr.Status.Migration.Phase == enterprisev1.PhaseReady
+	if cr.Status.Phase == enterprisev1.PhaseReady && !upgradePending && reloaded && migrated {
 		result.Requeue = false
 	}
 	return result, nil
@@ -20,7 +20,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 )
 
 func TestApplyStandalone(t *testing.T) {
@@ -58,3 +58,33 @@ func TestApplyStandalone(t *testing.T) {
 	}
 	splunkDeletionTester(t, revised, deleteFunc)
 }
+
+func TestApplyStandaloneDeletionError(t *testing.T) {
+	currentTime := metav1.NewTime(time.Now())
+	cr := enterprisev1.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stack1",
+			Namespace:         "test",
+			Generation:        2,
+			DeletionTimestamp: &currentTime,
+			Finalizers:        []string{"enterprise.splunk.com/unknown"},
+		},
+	}
+	cr.Status.ObservedGeneration = 1
+	c := newMockClient()
+
+	// failed deletions are reported in status, and do not mark the generation as observed
+	_, err := ApplyStandalone(c, &cr)
+	if err == nil {
+		t.Fatalf("ApplyStandalone() returned nil; want error for unrecognized finalizer")
+	}
+	if cr.Status.LastError == nil || cr.Status.LastError.Message != err.Error() || cr.Status.LastError.Count != 1 {
+		t.Errorf("ApplyStandalone() lastError = %v; want %v with count 1", cr.Status.LastError, err)
+	}
+	if cr.Status.Phase != enterprisev1.PhaseTerminating || cr.Status.ObservedGeneration != 1 {
+		t.Errorf("ApplyStandalone() phase = %s, observedGeneration = %d; want %s, 1", cr.Status.Phase, cr.Status.ObservedGeneration, enterprisev1.PhaseTerminating)
+	}
+}
@@ -20,10 +20,13 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 )
 
 // StatefulSetPodManager is used to manage the pods within a StatefulSet
@@ -41,6 +44,25 @@ type StatefulSetPodManager interface {
 	FinishRecycle(int32) (bool, error)
 }
 
+// podRecycleOrderer may be implemented by a StatefulSetPodManager to change the order in which pods are checked for updates
+type podRecycleOrderer interface {
+	// recycleOrder returns the ordinals of all pods in the order they should be recycled
+	recycleOrder(replicas int32) []int32
+}
+
+// getRecycleOrder returns the ordinals of all pods in the order they should be recycled. Pods are recycled from the
+// highest ordinal to the lowest, unless the StatefulSetPodManager implements podRecycleOrderer.
+func getRecycleOrder(mgr StatefulSetPodManager, replicas int32) []int32 {
+	if orderer, ok := mgr.(podRecycleOrderer); ok {
+		return orderer.recycleOrder(replicas)
+	}
+	order := make([]int32, 0, replicas)
+	for n := replicas - 1; n >= 0; n-- {
+		order = append(order, n)
+	}
+	return order
+}
+
 // DefaultStatefulSetPodManager is a simple StatefulSetPodManager that does nothing
 type DefaultStatefulSetPodManager struct{}
 
@@ -72,28 +94,78 @@ func (mgr *DefaultStatefulSetPodManager) FinishRecycle(n int32) (bool, error) {
 func ApplyStatefulSet(c ControllerClient, revised *appsv1.StatefulSet) (enterprisev1.ResourcePhase, error) {
 	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
 	var current appsv1.StatefulSet
+	checksum := getDesiredChecksum(&revised.Spec.Template)
 
 	err := c.Get(context.TODO(), namespacedName, &current)
 	if err != nil {
 		// no StatefulSet exists -> just create a new one
+		setDesiredChecksum(revised, checksum)
 		err = CreateResource(c, revised)
+		if err == nil {
+			recordOwnerEvent(revised, corev1.EventTypeNormal, "StatefulSetCreated", "Created StatefulSet %s", revised.GetName())
+		}
 		return enterprisev1.PhasePending, err
 	}
 
 	// found an existing StatefulSet
 
+	// wait for a StatefulSet that is being replaced to be removed (see expandVolumeClaims)
+	if current.GetDeletionTimestamp() != nil {
+		*revised = current
+		return enterprisev1.PhaseUpdating, nil
+	}
+
+	// take ownership of StatefulSets that were created by others, if requested (see adoptStatefulSet)
+	replaced, err := adoptStatefulSet(c, &current, revised)
+	if err != nil {
+		return enterprisev1.PhaseError, err
+	}
+	if replaced {
+		*revised = current
+		return enterprisev1.PhaseUpdating, nil
+	}
+
+	// volumeClaimTemplates cannot be updated, so check for increases in requested storage separately
+	replaced, err = expandVolumeClaims(c, &current, revised.Spec.VolumeClaimTemplates)
+	if err != nil {
+		return enterprisev1.PhaseError, err
+	}
+	if replaced {
+		*revised = current
+		return enterprisev1.PhaseUpdating, nil
+	}
+
+	// leave fields that are managed by others unchanged
+	keepUnmanagedFields(&current, revised)
+	unmanagedUpdated := mergeUnmanagedFieldsAnnotation(&current, revised)
+
 	// check for changes in Pod template
+	original := current.DeepCopy()
 	hasUpdates := MergePodUpdates(&current.Spec.Template, &revised.Spec.Template, current.GetObjectMeta().GetName())
+
+	// leave changes made outside of the operator unchanged if its owner only reports drift
+	if hasUpdates && !checkDrift(c, original, &current, checksum) {
+		*revised = *original
+		return enterprisev1.PhaseReady, nil
+	}
 	*revised = current // caller expects that object passed represents latest state
 
 	// only update if there are material differences, as determined by comparison function
 	if hasUpdates {
+		setDesiredChecksum(revised, checksum)
 		// this updates the desired state template, but doesn't actually modify any pods
 		// because we use an "OnUpdate" strategy https://kubernetes.io/docs/concepts/workloads/controllers/statefulset/#update-strategies
 		// note also that this ignores Replicas, which is handled below by UpdateStatefulSetPods
 		return enterprisev1.PhaseUpdating, UpdateResource(c, revised)
 	}
 
+	// changes to the fields that are managed by others do not require pods to be updated
+	if unmanagedUpdated {
+		if err := UpdateResource(c, revised); err != nil {
+			return enterprisev1.PhaseError, err
+		}
+	}
+
 	// scaling and pod updates are handled by UpdateStatefulSetPods
 	return enterprisev1.PhaseReady, nil
 }
@@ -121,12 +193,21 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 
 	// readyReplicas == replicas
 
+	// leave scaling to others, such as a HorizontalPodAutoscaler, if replicas are not managed by the operator
+	if enterprise.IsUnmanagedField(statefulSet, enterprise.UnmanagedReplicas) {
+		desiredReplicas = replicas
+	}
+
 	// check for scaling up
 	if readyReplicas < desiredReplicas {
 		// scale up StatefulSet to match desiredReplicas
 		scopedLog.Info("Scaling replicas up", "replicas", desiredReplicas)
 		*statefulSet.Spec.Replicas = desiredReplicas
-		return enterprisev1.PhaseScalingUp, UpdateResource(c, statefulSet)
+		err := UpdateResource(c, statefulSet)
+		if err == nil {
+			recordOwnerEvent(statefulSet, corev1.EventTypeNormal, "ScalingUp", "Scaling up StatefulSet %s to %d replicas", statefulSet.GetName(), desiredReplicas)
+		}
+		return enterprisev1.PhaseScalingUp, err
 	}
 
 	// check for scaling down
@@ -152,9 +233,13 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 			scopedLog.Error(err, "Scale down update failed for StatefulSet")
 			return enterprisev1.PhaseError, err
 		}
+		recordOwnerEvent(statefulSet, corev1.EventTypeNormal, "ScalingDown", "Scaling down StatefulSet %s to %d replicas", statefulSet.GetName(), n)
 
 		// delete PVCs used by the pod so that a future scale up will have clean state
 		for _, vol := range []string{"pvc-etc", "pvc-var"} {
+			if isEphemeralVolume(statefulSet, vol) {
+				continue
+			}
 			namespacedName := types.NamespacedName{
 				Namespace: statefulSet.GetNamespace(),
 				Name:      fmt.Sprintf("%s-%s", vol, podName),
@@ -180,7 +265,7 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 	// readyReplicas == desiredReplicas
 
 	// check existing pods for desired updates
-	for n := readyReplicas - 1; n >= 0; n-- {
+	for _, n := range getRecycleOrder(mgr, readyReplicas) {
 		// get Pod
 		podName := fmt.Sprintf("%s-%d", statefulSet.GetName(), n)
 		namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: podName}
@@ -196,7 +281,12 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 		}
 
 		// terminate pod if it has pending updates; k8s will start a new one with revised template
-		if statefulSet.Status.UpdateRevision != "" && statefulSet.Status.UpdateRevision != pod.GetLabels()["controller-revision-hash"] {
+		recycle := statefulSet.Status.UpdateRevision != "" && statefulSet.Status.UpdateRevision != pod.GetLabels()["controller-revision-hash"]
+		if !recycle {
+			// pods must also be restarted to finish resizing the file systems of expanded volumes
+			recycle = isFileSystemResizePending(c, statefulSet, podName)
+		}
+		if recycle {
 			// pod needs to be updated; first, prepare it to be recycled
 			ready, err := mgr.PrepareRecycle(n)
 			if err != nil {
@@ -218,6 +308,7 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 				scopedLog.Error(err, "Unable to delete Pod", "podName", podName)
 				return enterprisev1.PhaseError, err
 			}
+			recordOwnerEvent(statefulSet, corev1.EventTypeNormal, "RecyclingPod", "Recycling pod %s to apply updates", podName)
 
 			// only delete one at a time
 			return enterprisev1.PhaseUpdating, nil
@@ -239,3 +330,129 @@ func UpdateStatefulSetPods(c ControllerClient, statefulSet *appsv1.StatefulSet,
 	scopedLog.Info("All pods are ready")
 	return enterprisev1.PhaseReady, nil
 }
+
+// isEphemeralVolume returns true if the pods of a StatefulSet use an emptyDir volume with the given name, instead of a persistent volume claim
+func isEphemeralVolume(statefulSet *appsv1.StatefulSet, name string) bool {
+	for _, vol := range statefulSet.Spec.Template.Spec.Volumes {
+		if vol.Name == name && vol.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expandVolumeClaims requests more storage for the persistent volume claims of a StatefulSet's pods, for each of its volume claim
+// templates that has grown in revised and uses a StorageClass that allows volume expansion. Since volumeClaimTemplates are
+// immutable, the StatefulSet is then deleted without deleting its pods, so that it can be recreated with the revised templates.
+// It returns true if the StatefulSet was deleted.
+func expandVolumeClaims(c ControllerClient, statefulSet *appsv1.StatefulSet, revised []corev1.PersistentVolumeClaim) (bool, error) {
+	scopedLog := log.WithName("expandVolumeClaims").WithValues(
+		"name", statefulSet.GetObjectMeta().GetName(),
+		"namespace", statefulSet.GetObjectMeta().GetNamespace())
+
+	expanded := false
+	for _, claim := range revised {
+		var template *corev1.PersistentVolumeClaim
+		for idx := range statefulSet.Spec.VolumeClaimTemplates {
+			if statefulSet.Spec.VolumeClaimTemplates[idx].GetName() == claim.GetName() {
+				template = &statefulSet.Spec.VolumeClaimTemplates[idx]
+			}
+		}
+		if template == nil {
+			continue
+		}
+		currentStorage := template.Spec.Resources.Requests[corev1.ResourceStorage]
+		revisedStorage := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+		if revisedStorage.Cmp(currentStorage) <= 0 {
+			// persistent volume claims cannot be shrunk
+			continue
+		}
+
+		// persistent volume claims are created along with each pod, so some may not exist yet
+		pvcs := []corev1.PersistentVolumeClaim{}
+		for n := int32(0); n < *statefulSet.Spec.Replicas; n++ {
+			namespacedName := types.NamespacedName{
+				Namespace: statefulSet.GetNamespace(),
+				Name:      fmt.Sprintf("%s-%s-%d", claim.GetName(), statefulSet.GetName(), n),
+			}
+			var pvc corev1.PersistentVolumeClaim
+			if err := c.Get(context.TODO(), namespacedName, &pvc); err == nil {
+				pvcs = append(pvcs, pvc)
+			}
+		}
+		if len(pvcs) > 0 {
+			allowed, err := isVolumeExpansionAllowed(c, pvcs[0].Spec.StorageClassName)
+			if err != nil {
+				return false, err
+			}
+			if !allowed {
+				scopedLog.Info("StorageClass does not allow volume expansion", "volume", claim.GetName())
+				recordOwnerEvent(statefulSet, corev1.EventTypeWarning, "ExpansionNotAllowed", "Unable to expand %s volumes of StatefulSet %s to %s: StorageClass does not allow volume expansion", claim.GetName(), statefulSet.GetName(), revisedStorage.String())
+				continue
+			}
+		}
+
+		for idx := range pvcs {
+			pvcStorage := pvcs[idx].Spec.Resources.Requests[corev1.ResourceStorage]
+			if revisedStorage.Cmp(pvcStorage) <= 0 {
+				continue
+			}
+			scopedLog.Info("Expanding PVC", "pvcName", pvcs[idx].GetName(), "storage", revisedStorage.String())
+			if pvcs[idx].Spec.Resources.Requests == nil {
+				pvcs[idx].Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvcs[idx].Spec.Resources.Requests[corev1.ResourceStorage] = revisedStorage
+			if err := UpdateResource(c, &pvcs[idx]); err != nil {
+				return false, err
+			}
+		}
+		recordOwnerEvent(statefulSet, corev1.EventTypeNormal, "VolumesExpanded", "Expanded %s volumes of StatefulSet %s to %s", claim.GetName(), statefulSet.GetName(), revisedStorage.String())
+		expanded = true
+	}
+	if !expanded {
+		return false, nil
+	}
+
+	// orphaned pods are adopted by the new StatefulSet, and are only recycled if they need to be
+	scopedLog.Info("Replacing StatefulSet to update volume claim templates")
+	err := c.Delete(context.TODO(), statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil {
+		scopedLog.Error(err, "Unable to delete StatefulSet")
+		return false, err
+	}
+	return true, nil
+}
+
+// isVolumeExpansionAllowed returns true if a StorageClass allows persistent volume claims to be expanded
+func isVolumeExpansionAllowed(c ControllerClient, storageClassName *string) (bool, error) {
+	if storageClassName == nil || *storageClassName == "" {
+		return false, nil
+	}
+	var storageClass storagev1.StorageClass
+	err := c.Get(context.TODO(), types.NamespacedName{Name: *storageClassName}, &storageClass)
+	if err != nil {
+		return false, fmt.Errorf("Unable to get StorageClass %s: %v", *storageClassName, err)
+	}
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// isFileSystemResizePending returns true if a persistent volume claim of a StatefulSet's pod has been expanded, but is waiting
+// for the pod to be restarted to finish resizing its file system
+func isFileSystemResizePending(c ControllerClient, statefulSet *appsv1.StatefulSet, podName string) bool {
+	for _, claim := range statefulSet.Spec.VolumeClaimTemplates {
+		namespacedName := types.NamespacedName{
+			Namespace: statefulSet.GetNamespace(),
+			Name:      fmt.Sprintf("%s-%s", claim.GetName(), podName),
+		}
+		var pvc corev1.PersistentVolumeClaim
+		if err := c.Get(context.TODO(), namespacedName, &pvc); err != nil {
+			continue
+		}
+		for _, condition := range pvc.Status.Conditions {
+			if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"testing"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -197,3 +199,95 @@ func TestDefaultStatefulSetPodManager(t *testing.T) {
 	method := "DefaultStatefulSetPodManager.Update"
 	podManagerTester(t, method, &mgr)
 }
+
+func TestExpandVolumeClaims(t *testing.T) {
+	storageClassName := "fast"
+	newClaim := func(name, storage string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClassName,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+				},
+			},
+		}
+	}
+	var replicas int32 = 1
+	current := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1",
+			Namespace: "test",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:             &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{newClaim("pvc-etc", "10Gi"), newClaim("pvc-var", "100Gi")},
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.VolumeClaimTemplates[1] = newClaim("pvc-var", "200Gi")
+	allowExpansion := true
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: storageClassName},
+		AllowVolumeExpansion: &allowExpansion,
+	}
+	pvc := newClaim("pvc-var-splunk-stack1-0", "100Gi")
+	statefulSetCall := mockFuncCall{metaName: "*v1.StatefulSet-test-splunk-stack1"}
+	pvcCall := mockFuncCall{metaName: "*v1.PersistentVolumeClaim-test-pvc-var-splunk-stack1-0"}
+	storageClassCall := mockFuncCall{metaName: "*v1.StorageClass--fast"}
+
+	// claims are expanded and StatefulSet is replaced
+	c := newMockClient()
+	c.state[getStateKey(current)] = current.DeepCopy()
+	c.state[getStateKey(&pvc)] = pvc.DeepCopy()
+	c.state[getStateKey(storageClass)] = storageClass
+	phase, err := ApplyStatefulSet(c, revised.DeepCopy())
+	if phase != enterprisev1.PhaseUpdating || err != nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseUpdating)
+	}
+	c.checkCalls(t, "TestExpandVolumeClaims(expand)", map[string][]mockFuncCall{
+		"Get":    {statefulSetCall, pvcCall, storageClassCall},
+		"Update": {pvcCall},
+		"Delete": {statefulSetCall},
+	})
+	got := c.state[getStateKey(&pvc)].(*corev1.PersistentVolumeClaim).Spec.Resources.Requests[corev1.ResourceStorage]
+	if got.String() != "200Gi" {
+		t.Errorf("ApplyStatefulSet() PVC storage = %s; want %s", got.String(), "200Gi")
+	}
+
+	// nothing is changed if the StorageClass does not allow expansion
+	allowExpansion = false
+	c = newMockClient()
+	c.state[getStateKey(current)] = current.DeepCopy()
+	c.state[getStateKey(&pvc)] = pvc.DeepCopy()
+	c.state[getStateKey(storageClass)] = storageClass
+	phase, err = ApplyStatefulSet(c, revised.DeepCopy())
+	if phase != enterprisev1.PhaseReady || err != nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseReady)
+	}
+	c.checkCalls(t, "TestExpandVolumeClaims(not allowed)", map[string][]mockFuncCall{"Get": {statefulSetCall, pvcCall, storageClassCall}})
+
+	// wait for StatefulSet being replaced to be removed
+	deleting := current.DeepCopy()
+	now := metav1.Now()
+	deleting.ObjectMeta.DeletionTimestamp = &now
+	c = newMockClient()
+	c.state[getStateKey(deleting)] = deleting
+	phase, err = ApplyStatefulSet(c, revised.DeepCopy())
+	if phase != enterprisev1.PhaseUpdating || err != nil {
+		t.Errorf("ApplyStatefulSet() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseUpdating)
+	}
+	c.checkCalls(t, "TestExpandVolumeClaims(deleting)", map[string][]mockFuncCall{"Get": {statefulSetCall}})
+
+	// pods are recycled to finish resizing file systems
+	if isFileSystemResizePending(c, current, "splunk-stack1-0") {
+		t.Errorf("isFileSystemResizePending() = true; want false")
+	}
+	pvc.Status.Conditions = []corev1.PersistentVolumeClaimCondition{
+		{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+	}
+	c.state[getStateKey(&pvc)] = &pvc
+	if !isFileSystemResizePending(c, current, "splunk-stack1-0") {
+		t.Errorf("isFileSystemResizePending() = false; want true")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
+)
+
+// tracingClient is a ControllerClient that records a span for each request made to the Kubernetes API during a reconcile,
+// as a child of the span of the reconcile. Splunk REST API clients created for reconciles using it record spans for their
+// requests, too.
+type tracingClient struct {
+	ControllerClient
+
+	// span of the reconcile
+	span *tracing.Span
+}
+
+// NewTracingClient returns a ControllerClient that records requests made during a reconcile as children of its span, or c
+// if span is nil (because tracing is disabled)
+func NewTracingClient(c ControllerClient, span *tracing.Span) ControllerClient {
+	if span == nil {
+		return c
+	}
+	return &tracingClient{ControllerClient: c, span: span}
+}
+
+// getReconcileSpan returns the span of the reconcile using a ControllerClient, or nil if it is not being traced
+func getReconcileSpan(c ControllerClient) *tracing.Span {
+	for {
+		switch v := c.(type) {
+		case *tracingClient:
+			return v.span
+		case *dryRunClient:
+			c = v.ControllerClient
+		default:
+			return nil
+		}
+	}
+}
+
+// startObjectSpan starts a span for a request made to the Kubernetes API for a resource
+func startObjectSpan(parent *tracing.Span, verb string, obj runtime.Object) *tracing.Span {
+	span := tracing.StartSpan(parent, fmt.Sprintf("%s %s", verb, getObjectKind(obj)), tracing.SpanKindClient)
+	if metaObj, err := meta.Accessor(obj); err == nil {
+		span.SetAttribute("k8s.namespace", metaObj.GetNamespace())
+		span.SetAttribute("k8s.name", metaObj.GetName())
+	}
+	return span
+}
+
+// Get retrieves a Kubernetes resource, recording a span for the request
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	span := tracing.StartSpan(c.span, fmt.Sprintf("Get %s", getObjectKind(obj)), tracing.SpanKindClient,
+		"k8s.namespace", key.Namespace, "k8s.name", key.Name)
+	err := c.ControllerClient.Get(ctx, key, obj)
+	span.End(err)
+	return err
+}
+
+// List retrieves a list of Kubernetes resources, recording a span for the request
+func (c *tracingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	span := tracing.StartSpan(c.span, fmt.Sprintf("List %s", getObjectKind(list)), tracing.SpanKindClient)
+	err := c.ControllerClient.List(ctx, list, opts...)
+	span.End(err)
+	return err
+}
+
+// Create creates a Kubernetes resource, recording a span for the request
+func (c *tracingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	span := startObjectSpan(c.span, "Create", obj)
+	err := c.ControllerClient.Create(ctx, obj, opts...)
+	span.End(err)
+	return err
+}
+
+// Update updates a Kubernetes resource, recording a span for the request
+func (c *tracingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	span := startObjectSpan(c.span, "Update", obj)
+	err := c.ControllerClient.Update(ctx, obj, opts...)
+	span.End(err)
+	return err
+}
+
+// Patch patches a Kubernetes resource, recording a span for the request
+func (c *tracingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	span := startObjectSpan(c.span, "Patch", obj)
+	err := c.ControllerClient.Patch(ctx, obj, patch, opts...)
+	span.End(err)
+	return err
+}
+
+// Delete deletes a Kubernetes resource, recording a span for the request
+func (c *tracingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	span := startObjectSpan(c.span, "Delete", obj)
+	err := c.ControllerClient.Delete(ctx, obj, opts...)
+	span.End(err)
+	return err
+}
+
+// DeleteAllOf deletes all Kubernetes resources matching options, recording a span for the request
+func (c *tracingClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	span := tracing.StartSpan(c.span, fmt.Sprintf("DeleteAllOf %s", getObjectKind(obj)), tracing.SpanKindClient)
+	err := c.ControllerClient.DeleteAllOf(ctx, obj, opts...)
+	span.End(err)
+	return err
+}
+
+// Status returns a StatusWriter that records a span for each update of the status of a custom resource
+func (c *tracingClient) Status() client.StatusWriter {
+	return &tracingStatusWriter{StatusWriter: c.ControllerClient.Status(), span: c.span}
+}
+
+// tracingStatusWriter is a StatusWriter that records a span for each request, as a child of the span of a reconcile
+type tracingStatusWriter struct {
+	client.StatusWriter
+
+	// span of the reconcile
+	span *tracing.Span
+}
+
+// Update updates the status of a custom resource, recording a span for the request
+func (w *tracingStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	span := startObjectSpan(w.span, "UpdateStatus", obj)
+	err := w.StatusWriter.Update(ctx, obj, opts...)
+	span.End(err)
+	return err
+}
+
+// Patch patches the status of a custom resource, recording a span for the request
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	span := startObjectSpan(w.span, "PatchStatus", obj)
+	err := w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	span.End(err)
+	return err
+}
+
+// tracingHTTPClient is used by Splunk REST API clients during traced reconciles, and records a span for each request
+// (including retries) as a child of the span of the reconcile
+type tracingHTTPClient struct {
+	client splclient.SplunkHTTPClient
+	span   *tracing.Span
+}
+
+// Do sends a request, recording a span for it. Responses with an error status code are recorded as failures.
+func (c *tracingHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	span := tracing.StartSpan(c.span, fmt.Sprintf("%s %s", request.Method, request.URL.Path), tracing.SpanKindClient,
+		"http.method", request.Method,
+		"http.url", fmt.Sprintf("%s://%s%s", request.URL.Scheme, request.URL.Host, request.URL.Path))
+	response, err := c.client.Do(request)
+	spanErr := err
+	if err == nil {
+		span.SetAttribute("http.status_code", response.StatusCode)
+		if response.StatusCode >= 400 {
+			spanErr = fmt.Errorf("%s", http.StatusText(response.StatusCode))
+		}
+	}
+	span.End(spanErr)
+	return response, err
+}
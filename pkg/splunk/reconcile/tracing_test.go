@@ -0,0 +1,149 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	"github.com/splunk/splunk-operator/pkg/splunk/tracing"
+)
+
+// testSpanExporter records all spans that have ended
+type testSpanExporter struct {
+	spans []*tracing.Span
+}
+
+func (e *testSpanExporter) Export(span *tracing.Span) {
+	e.spans = append(e.spans, span)
+}
+
+// checkSpans checks the names and errors of spans, and that they are all children of parent
+func (e *testSpanExporter) checkSpans(t *testing.T, testname string, parent *tracing.Span, wantNames []string, wantErrs []string) {
+	if len(e.spans) != len(wantNames) {
+		t.Fatalf("%s exported %d spans; want %d", testname, len(e.spans), len(wantNames))
+	}
+	for i, span := range e.spans {
+		if span.Name() != wantNames[i] || span.Err() != wantErrs[i] {
+			t.Errorf("%s span %d = %s (%s); want %s (%s)", testname, i, span.Name(), span.Err(), wantNames[i], wantErrs[i])
+		}
+		if span.TraceID() != parent.TraceID() || span.ParentSpanID() != parent.SpanID() {
+			t.Errorf("%s span %d is not a child of %s", testname, i, parent.Name())
+		}
+	}
+	e.spans = nil
+}
+
+func TestTracingClient(t *testing.T) {
+	// clients are not changed when tracing is disabled
+	c := newMockClient()
+	if got := NewTracingClient(c, nil); got != c {
+		t.Errorf("NewTracingClient(nil) = %v; want %v", got, c)
+	}
+
+	exporter := &testSpanExporter{}
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	span := tracing.StartSpan(nil, "Reconcile Standalone", tracing.SpanKindInternal)
+	tc := NewTracingClient(c, span)
+	if getReconcileSpan(tc) != span || getReconcileSpan(newDryRunClient(tc)) != span || getReconcileSpan(c) != nil {
+		t.Errorf("getReconcileSpan() did not return the span of the reconcile")
+	}
+
+	cr := enterprisev1.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"}}
+	secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-secret", Namespace: "test"}}
+	if err := tc.Create(context.TODO(), &secret); err != nil {
+		t.Errorf("tracingClient.Create() returned %v; want nil", err)
+	}
+	var service corev1.Service
+	if err := tc.Get(context.TODO(), types.NamespacedName{Namespace: "test", Name: "splunk-stack1-service"}, &service); err == nil {
+		t.Errorf("tracingClient.Get() returned nil; want error")
+	}
+	if err := tc.Delete(context.TODO(), &secret); err != nil {
+		t.Errorf("tracingClient.Delete() returned %v; want nil", err)
+	}
+	if err := tc.Status().Update(context.TODO(), &cr); err != nil {
+		t.Errorf("tracingClient.Status().Update() returned %v; want nil", err)
+	}
+	if got := exporter.spans[0].Attribute("k8s.name"); got != "splunk-stack1-secret" {
+		t.Errorf("tracingClient.Create() span k8s.name = %v; want splunk-stack1-secret", got)
+	}
+	exporter.checkSpans(t, "TestTracingClient", span,
+		[]string{"Create Secret", "Get Service", "Delete Secret", "UpdateStatus Standalone"},
+		[]string{"", "NotFound", "", ""})
+	c.checkCalls(t, "TestTracingClient", map[string][]mockFuncCall{
+		"Create": {{metaName: "*v1.Secret-test-splunk-stack1-secret"}},
+		"Get":    {{metaName: "*v1.Service-test-splunk-stack1-service"}},
+		"Delete": {{metaName: "*v1.Secret-test-splunk-stack1-secret"}},
+	})
+}
+
+func TestTracingHTTPClient(t *testing.T) {
+	exporter := &testSpanExporter{}
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-cm:8089/services/cluster/master/info?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{}`,
+	}, spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-cm:8089/services/cluster/master/control/default/apply",
+		Status: 500,
+		Body:   ``,
+	})
+	span := tracing.StartSpan(nil, "Reconcile ClusterMaster", tracing.SpanKindInternal)
+	splunkClient := getSplunkClientFactory(NewTracingClient(newMockClient(), span))("https://splunk-cm:8089", "admin", "changeme")
+	splunkClient.Client.(*tracingHTTPClient).client = mockSplunkClient
+	splunkClient.MaxRetries = 0
+
+	// a span is recorded for each request, and error responses are recorded as failures
+	if err := splunkClient.Get("/services/cluster/master/info", nil); err != nil {
+		t.Errorf("SplunkClient.Get() returned %v; want nil", err)
+	}
+	if err := splunkClient.ApplyClusterMasterBundle(); err == nil {
+		t.Errorf("SplunkClient.ApplyClusterMasterBundle() returned nil; want error")
+	}
+	mockSplunkClient.CheckRequests(t, "TestTracingHTTPClient")
+	exporter.checkSpans(t, "TestTracingHTTPClient", span,
+		[]string{"GET /services/cluster/master/info", "POST /services/cluster/master/control/default/apply"},
+		[]string{"", "Internal Server Error"})
+
+	// requests stopped by dry runs are recorded, too
+	dryRun := newDryRunClient(NewTracingClient(newMockClient(), span))
+	splunkClient = getSplunkClientFactory(dryRun)("https://splunk-cm:8089", "admin", "changeme")
+	if err := splunkClient.ApplyClusterMasterBundle(); err == nil {
+		t.Errorf("SplunkClient.ApplyClusterMasterBundle() returned nil; want error")
+	}
+	exporter.checkSpans(t, "TestTracingHTTPClient(dry run)", span,
+		[]string{"POST /services/cluster/master/control/default/apply"},
+		[]string{"dry run stopped before POST https://splunk-cm:8089/services/cluster/master/control/default/apply"})
+
+	// clients are not changed when reconciles are not traced
+	splunkClient = getSplunkClientFactory(newMockClient())("https://splunk-cm:8089", "admin", "changeme")
+	if _, ok := splunkClient.Client.(*tracingHTTPClient); ok {
+		t.Errorf("getSplunkClientFactory() returned a tracing client; want default")
+	}
+}
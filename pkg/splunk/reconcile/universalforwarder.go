@@ -0,0 +1,279 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/resources"
+)
+
+// ApplyUniversalForwarder reconciles the state for Splunk universal forwarders.
+func ApplyUniversalForwarder(client ControllerClient, cr *enterprisev1.UniversalForwarder) (reconcile.Result, error) {
+
+	// leave the custom resource and everything it owns unchanged while reconciles are paused
+	if isPauseRequested(cr) {
+		return applyPaused(client, cr, &cr.Status.Conditions)
+	}
+
+	// report the changes that would be made instead of making them, if dry runs have been requested
+	if isDryRunRequested(client, cr) {
+		return applyDryRun(client, cr, &cr.Status.DryRun, func(c ControllerClient) (reconcile.Result, error) {
+			return ApplyUniversalForwarder(c, cr)
+		})
+	}
+	cr.Status.DryRun = nil
+
+	// unless modified, reconcile for this object will be requeued after its requeue interval (5 seconds by default)
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: getRequeueInterval(cr),
+	}
+
+	// validate and updates defaults for CR
+	err := enterprise.ValidateUniversalForwarderSpec(&cr.Spec)
+	if err != nil {
+		return result, err
+	}
+
+	// updates status after function completes
+	oldPhase := cr.Status.Phase
+	cr.Status.Phase = enterprisev1.PhaseError
+	cr.Status.Reason, cr.Status.Message = "", ""
+	defer func() {
+		if err == nil {
+			cr.Status.ObservedGeneration = cr.GetGeneration()
+		}
+		cr.Status.Reason, cr.Status.Message = enterprise.GetPhaseReason(cr.Status.Phase, cr.Status.Reason, cr.Status.Message, err)
+		cr.Status.LastError = enterprise.GetLastError(cr.Status.LastError, err, time.Now())
+		cr.Status.Conditions = enterprise.GetStatusConditions(cr.Status.Conditions, cr.Status.Phase, cr.GetGeneration(), err, time.Now())
+		recordPhaseEvents(cr, oldPhase, cr.Status.Phase, err)
+		client.Status().Update(context.TODO(), cr)
+	}()
+
+	// check if deletion has been requested (universal forwarders do not use persistent volumes, so no finalizers are added)
+	if cr.ObjectMeta.DeletionTimestamp != nil {
+		var deleted bool
+		deleted, err = CheckSplunkDeletion(cr, client)
+		if !deleted {
+			cr.Status.Phase = enterprisev1.PhaseTerminating
+		} else {
+			result.Requeue = false
+		}
+		return result, err
+	}
+
+	// create or update general config resources
+	secrets, err := ApplySplunkConfig(client, cr, cr.Spec.CommonSplunkSpec, enterprise.SplunkUniversalForwarder)
+	if err != nil {
+		return result, err
+	}
+
+	// retrieve defaults from a referenced ConfigMap, if configured
+	defaults, err := GetDefaultsConfigMap(client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update tls configuration
+	tls, err := ApplyTLSConfig(client, cr, &cr.Spec.TLS, enterprise.SplunkUniversalForwarder, enterprise.GetSplunkDNSNames(cr.GetNamespace(), cr.GetIdentifier(), enterprise.SplunkUniversalForwarder))
+	if err != nil {
+		return result, err
+	}
+
+	// create or update conf files, if configured
+	confFiles, err := ApplyConfFilesConfig(client, cr, &cr.Spec.CommonSplunkSpec, enterprise.SplunkUniversalForwarder)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update outputs configuration for the referenced indexer cluster
+	outputs, err := ApplyUniversalForwarderOutputs(client, cr)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update a network policy restricting traffic to Splunk ports, if enabled
+	err = ApplySplunkNetworkPolicy(client, cr, &cr.Spec.NetworkPolicy, enterprise.SplunkUniversalForwarder)
+	if err != nil {
+		return result, err
+	}
+
+	// create or update the daemonset or deployment, and remove the other kind of workload if the workload type has changed
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: enterprise.GetSplunkDeploymentName(enterprise.SplunkUniversalForwarder, cr.GetIdentifier())}
+	var phase enterprisev1.ResourcePhase
+	if cr.Spec.WorkloadType == enterprise.UniversalForwarderDeployment {
+		if err = deleteUnusedWorkload(client, namespacedName, &appsv1.DaemonSet{}); err != nil {
+			return result, err
+		}
+		deployment := enterprise.GetUniversalForwarderDeployment(cr)
+		setUniversalForwarderChecksums(&deployment.Spec.Template, secrets, tls, defaults, outputs, confFiles)
+		phase, err = ApplyDeployment(client, deployment)
+		cr.Status.Replicas = cr.Spec.Replicas
+		cr.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+		if err == nil {
+			cr.Status.Reason, cr.Status.Message = getDeploymentPhaseReason(client, deployment, phase)
+		}
+	} else {
+		if err = deleteUnusedWorkload(client, namespacedName, &appsv1.Deployment{}); err != nil {
+			return result, err
+		}
+		daemonSet := enterprise.GetUniversalForwarderDaemonSet(cr)
+		setUniversalForwarderChecksums(&daemonSet.Spec.Template, secrets, tls, defaults, outputs, confFiles)
+		phase, err = ApplyDaemonSet(client, daemonSet)
+		cr.Status.Replicas = daemonSet.Status.DesiredNumberScheduled
+		cr.Status.ReadyReplicas = daemonSet.Status.NumberReady
+		if err == nil {
+			cr.Status.Reason, cr.Status.Message = getDaemonSetPhaseReason(client, daemonSet, phase)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	cr.Status.Phase = phase
+
+	// no need to requeue if everything is ready
+	if cr.Status.Phase == enterprisev1.PhaseReady {
+		result.Requeue = false
+	}
+	return result, nil
+}
+
+// setUniversalForwarderChecksums annotates the pod template of universal forwarders with checksums of their configuration,
+// so that pods are recycled when it changes
+func setUniversalForwarderChecksums(podTemplateSpec *corev1.PodTemplateSpec, secrets, tls *corev1.Secret, defaults *corev1.ConfigMap, outputs, confFiles *corev1.Secret) {
+	enterprise.SetSecretsChecksum(podTemplateSpec, secrets)
+	enterprise.SetTLSChecksum(podTemplateSpec, tls)
+	enterprise.SetDefaultsChecksum(podTemplateSpec, defaults)
+	enterprise.SetOutputsChecksum(podTemplateSpec, outputs)
+	enterprise.SetConfFilesChecksum(podTemplateSpec, confFiles)
+}
+
+// deleteUnusedWorkload deletes a DaemonSet or Deployment that is no longer used by universal forwarders, if it exists
+func deleteUnusedWorkload(c ControllerClient, namespacedName types.NamespacedName, obj ResourceObject) error {
+	if err := c.Get(context.TODO(), namespacedName, obj); err != nil {
+		return nil
+	}
+	log.WithName("deleteUnusedWorkload").Info("Deleting unused workload", "kind", fmt.Sprintf("%T", obj), "name", namespacedName.Name, "namespace", namespacedName.Namespace)
+	return c.Delete(context.TODO(), obj)
+}
+
+// getForwarderIndexerCluster retrieves the IndexerCluster referenced by universal forwarders, and the indexer discovery and TLS
+// configuration used to send data to its peers. The indexer discovery configuration is only returned if indexer discovery is
+// enabled on the cluster master, and the TLS configuration if TLS is configured for the indexer cluster.
+func getForwarderIndexerCluster(c ControllerClient, cr *enterprisev1.UniversalForwarder) (*enterprisev1.IndexerCluster, *corev1.Secret, *corev1.Secret, error) {
+	ref := cr.Spec.IndexerClusterRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+
+	var idxc enterprisev1.IndexerCluster
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: ref.Name}, &idxc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to get IndexerCluster %s: %v", ref.Name, err)
+	}
+
+	// apply the same defaults used by the indexer cluster (e.g. number of replicas)
+	if err = enterprise.ValidateIndexerClusterSpec(&idxc.Spec); err != nil {
+		return nil, nil, nil, fmt.Errorf("IndexerCluster %s is invalid: %v", ref.Name, err)
+	}
+
+	// indexer discovery is enabled on the referenced ClusterMaster, or on the indexer cluster's own master; forwarders send data
+	// to the peers of an external cluster master without it
+	clusterMasterName, clusterMasterNamespace := idxc.GetIdentifier(), idxc.GetNamespace()
+	discoverySpec := &idxc.Spec.IndexerDiscovery
+	if enterprise.IsExternalClusterMasterConfigured(&idxc.Spec.ExternalClusterMaster) {
+		discoverySpec = &enterprisev1.IndexerDiscoverySpec{}
+	} else if cmRef := idxc.Spec.ClusterMasterRef; cmRef.Name != "" {
+		clusterMasterName = cmRef.Name
+		if cmRef.Namespace != "" {
+			clusterMasterNamespace = cmRef.Namespace
+		}
+		var clusterMaster enterprisev1.ClusterMaster
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: clusterMasterNamespace, Name: clusterMasterName}, &clusterMaster)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Unable to get ClusterMaster %s: %v", clusterMasterName, err)
+		}
+		discoverySpec = &clusterMaster.Spec.IndexerDiscovery
+	}
+
+	var discovery *corev1.Secret
+	if enterprise.IsIndexerDiscoveryEnabled(discoverySpec) {
+		discovery = &corev1.Secret{}
+		namespacedName := types.NamespacedName{Namespace: clusterMasterNamespace, Name: enterprise.GetSplunkIndexerDiscoveryName(clusterMasterName, enterprise.SplunkClusterMaster)}
+		if err = c.Get(context.TODO(), namespacedName, discovery); err != nil {
+			return nil, nil, nil, fmt.Errorf("Unable to get indexer discovery configuration %s: %v", namespacedName.Name, err)
+		}
+	}
+
+	var tls *corev1.Secret
+	if enterprise.IsTLSConfigured(&idxc.Spec.TLS) {
+		tls = &corev1.Secret{}
+		namespacedName := types.NamespacedName{Namespace: idxc.GetNamespace(), Name: enterprise.GetSplunkTLSName(idxc.GetIdentifier(), enterprise.SplunkIndexer)}
+		if err = c.Get(context.TODO(), namespacedName, tls); err != nil {
+			return nil, nil, nil, fmt.Errorf("Unable to get TLS configuration %s: %v", namespacedName.Name, err)
+		}
+	}
+
+	return &idxc, discovery, tls, nil
+}
+
+// ApplyUniversalForwarderOutputs creates or updates a Kubernetes Secret containing the outputs configuration used by universal
+// forwarders to send data to the peers of the referenced IndexerCluster, and reports whether indexer discovery and TLS are
+// used in status.
+func ApplyUniversalForwarderOutputs(client ControllerClient, cr *enterprisev1.UniversalForwarder) (*corev1.Secret, error) {
+	idxc, discovery, tls, err := getForwarderIndexerCluster(client, cr)
+	if err != nil {
+		return nil, err
+	}
+	cr.Status.IndexerDiscovery = discovery != nil
+	cr.Status.TLS = tls != nil
+
+	revised := enterprise.GetUniversalForwarderOutputsSecret(cr, idxc, discovery, tls)
+	revised.SetOwnerReferences(append(revised.GetOwnerReferences(), resources.AsOwner(cr)))
+
+	scopedLog := log.WithName("ApplyUniversalForwarderOutputs").WithValues(
+		"name", revised.GetName(),
+		"namespace", revised.GetNamespace())
+
+	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
+	var current corev1.Secret
+
+	err = client.Get(context.TODO(), namespacedName, &current)
+	if err != nil {
+		return revised, CreateResource(client, revised)
+	}
+
+	if !reflect.DeepEqual(revised.Data, current.Data) {
+		scopedLog.Info("Updating existing outputs Secret")
+		current.Data = revised.Data
+		err = UpdateResource(client, &current)
+	} else {
+		scopedLog.Info("No changes for outputs Secret")
+	}
+
+	return &current, err
+}
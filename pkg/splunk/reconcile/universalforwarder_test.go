@@ -0,0 +1,114 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+func TestApplyUniversalForwarder(t *testing.T) {
+	funcCalls := []mockFuncCall{
+		{metaName: "*v1.Secret-test-splunk-idxc-indexer-secrets"},
+		{metaName: "*v1.Secret-test-splunk-stack1-universal-forwarder-secrets"},
+		{metaName: "*v1beta1.IndexerCluster-test-idxc"},
+		{metaName: "*v1.Secret-test-splunk-stack1-universal-forwarder-outputs"},
+		{metaName: "*v1.Deployment-test-splunk-stack1-universal-forwarder"},
+		{metaName: "*v1.DaemonSet-test-splunk-stack1-universal-forwarder"},
+	}
+	createCalls := map[string][]mockFuncCall{"Get": funcCalls, "Create": {funcCalls[1], funcCalls[3], funcCalls[5]}}
+	updateCalls := map[string][]mockFuncCall{"Get": funcCalls, "Update": {funcCalls[5]}}
+	current := enterprisev1.UniversalForwarder{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "UniversalForwarder",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+		Spec: enterprisev1.UniversalForwarderSpec{
+			CommonSplunkSpec: enterprisev1.CommonSplunkSpec{
+				IndexerClusterRef: corev1.ObjectReference{Name: "idxc"},
+			},
+		},
+	}
+	indexerCluster := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "idxc",
+			Namespace: "test",
+		},
+	}
+	indexerSecrets := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-idxc-indexer-secrets",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"idxc_secret": []byte("abc123"),
+		},
+	}
+	revised := current.DeepCopy()
+	revised.Spec.Image = "splunk/test"
+	reconcile := func(c *mockClient, cr interface{}) error {
+		_, err := ApplyUniversalForwarder(c, cr.(*enterprisev1.UniversalForwarder))
+		return err
+	}
+	reconcileTester(t, "TestApplyUniversalForwarder", &current, revised, createCalls, updateCalls, reconcile, &indexerCluster, &indexerSecrets)
+
+	// outputs are sent directly to the indexer cluster peers
+	c := newMockClient()
+	c.state[getStateKey(&indexerCluster)] = &indexerCluster
+	c.state[getStateKey(&indexerSecrets)] = &indexerSecrets
+	outputs, err := ApplyUniversalForwarderOutputs(c, revised)
+	if err != nil {
+		t.Fatalf("ApplyUniversalForwarderOutputs() returned %v; want nil", err)
+	}
+	if got := string(outputs.Data["outputs.conf"]); !strings.Contains(got, "server = splunk-idxc-indexer-0.splunk-idxc-indexer-headless.test.svc.cluster.local:9997") {
+		t.Errorf("ApplyUniversalForwarderOutputs() outputs.conf = %s; want servers for indexer cluster peers", got)
+	}
+	if revised.Status.IndexerDiscovery || revised.Status.TLS {
+		t.Errorf("ApplyUniversalForwarderOutputs() status = %v; want indexerDiscovery and tls false", revised.Status)
+	}
+
+	// changing the workload type replaces the daemonset with a deployment
+	_, err = ApplyUniversalForwarder(c, revised)
+	if err != nil {
+		t.Errorf("ApplyUniversalForwarder() returned %v; want nil", err)
+	}
+	revised.Spec.WorkloadType = "Deployment"
+	c.resetCalls()
+	_, err = ApplyUniversalForwarder(c, revised)
+	if err != nil {
+		t.Errorf("ApplyUniversalForwarder() returned %v; want nil", err)
+	}
+	if len(c.calls["Delete"]) != 1 || c.state["*v1.DaemonSet-test-splunk-stack1-universal-forwarder"] != nil {
+		t.Errorf("ApplyUniversalForwarder() deleted %v; want daemonset", c.calls["Delete"])
+	}
+	if c.state["*v1.Deployment-test-splunk-stack1-universal-forwarder"] == nil {
+		t.Errorf("ApplyUniversalForwarder() did not create a deployment")
+	}
+
+	// missing indexer cluster
+	c = newMockClient()
+	_, err = ApplyUniversalForwarder(c, &current)
+	if err == nil {
+		t.Errorf("ApplyUniversalForwarder() returned nil; want error for missing indexer cluster")
+	}
+}
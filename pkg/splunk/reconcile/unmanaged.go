@@ -0,0 +1,81 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// keepUnmanagedFields copies the fields of a current StatefulSet that revised leaves to others into revised, so that
+// changes made to them outside of the operator are not reverted. Pod template labels and annotations added by others
+// are kept, but values set by the operator take precedence. Replicas are handled by UpdateStatefulSetPods.
+func keepUnmanagedFields(current, revised *appsv1.StatefulSet) {
+	if enterprise.IsUnmanagedField(revised, enterprise.UnmanagedPodAnnotations) {
+		revised.Spec.Template.Annotations = mergeUnmanagedMap(current.Spec.Template.Annotations, revised.Spec.Template.Annotations)
+	}
+	if enterprise.IsUnmanagedField(revised, enterprise.UnmanagedPodLabels) {
+		revised.Spec.Template.Labels = mergeUnmanagedMap(current.Spec.Template.Labels, revised.Spec.Template.Labels)
+	}
+	if enterprise.IsUnmanagedField(revised, enterprise.UnmanagedAffinity) {
+		revised.Spec.Template.Spec.Affinity = current.Spec.Template.Spec.Affinity
+	}
+	if enterprise.IsUnmanagedField(revised, enterprise.UnmanagedResources) {
+		for idx := range revised.Spec.Template.Spec.Containers {
+			for _, container := range current.Spec.Template.Spec.Containers {
+				if container.Name == revised.Spec.Template.Spec.Containers[idx].Name {
+					revised.Spec.Template.Spec.Containers[idx].Resources = container.Resources
+				}
+			}
+		}
+	}
+}
+
+// mergeUnmanagedMap returns the keys of revised together with any other keys of current
+func mergeUnmanagedMap(current, revised map[string]string) map[string]string {
+	if len(current) == 0 {
+		return revised
+	}
+	result := make(map[string]string)
+	for k, v := range current {
+		result[k] = v
+	}
+	for k, v := range revised {
+		result[k] = v
+	}
+	return result
+}
+
+// mergeUnmanagedFieldsAnnotation updates the list of fields left to others that is annotated on a current StatefulSet
+// to match revised. It returns true if it was changed.
+func mergeUnmanagedFieldsAnnotation(current, revised *appsv1.StatefulSet) bool {
+	want, wantFound := revised.GetAnnotations()[enterprise.UnmanagedFieldsAnnotation]
+	got, gotFound := current.GetAnnotations()[enterprise.UnmanagedFieldsAnnotation]
+	if want == got && wantFound == gotFound {
+		return false
+	}
+	annotations := current.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if wantFound {
+		annotations[enterprise.UnmanagedFieldsAnnotation] = want
+	} else {
+		delete(annotations, enterprise.UnmanagedFieldsAnnotation)
+	}
+	current.SetAnnotations(annotations)
+	return true
+}
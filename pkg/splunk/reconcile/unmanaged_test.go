@@ -0,0 +1,150 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyStatefulSetUnmanagedFields(t *testing.T) {
+	var replicas int32 = 1
+	desired := func(unmanagedFields string) *appsv1.StatefulSet {
+		ss := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "splunk-stack1-standalone",
+				Namespace:   "test",
+				Annotations: map[string]string{},
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"one": "two"}},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "splunk",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+							},
+						}},
+					},
+				},
+			},
+		}
+		if unmanagedFields != "" {
+			ss.ObjectMeta.Annotations[enterprise.UnmanagedFieldsAnnotation] = unmanagedFields
+		}
+		return ss
+	}
+	statefulSetCalls := []mockFuncCall{{metaName: "*v1.StatefulSet-test-splunk-stack1-standalone"}}
+	test := func(testname string, revised *appsv1.StatefulSet, wantPhase enterprisev1.ResourcePhase, wantCalls map[string][]mockFuncCall, c *mockClient) {
+		c.resetCalls()
+		phase, err := ApplyStatefulSet(c, revised)
+		if err != nil || phase != wantPhase {
+			t.Errorf("%s: ApplyStatefulSet() = %s, %v; want %s, nil", testname, phase, err, wantPhase)
+		}
+		c.checkCalls(t, testname, wantCalls)
+	}
+
+	unmanagedFields := "spec.replicas,spec.template.metadata.annotations,spec.template.spec.containers.resources"
+	c := newMockClient()
+	test("TestApplyStatefulSetUnmanagedFields(create)", desired(unmanagedFields), enterprisev1.PhasePending,
+		map[string][]mockFuncCall{"Get": statefulSetCalls, "Create": statefulSetCalls}, c)
+
+	// changes made by others to unmanaged fields are kept
+	current := c.state["*v1.StatefulSet-test-splunk-stack1-standalone"].(*appsv1.StatefulSet)
+	current.Spec.Template.ObjectMeta.Annotations = map[string]string{"one": "two", "three": "four"}
+	current.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("4")
+	revised := desired(unmanagedFields)
+	test("TestApplyStatefulSetUnmanagedFields(keep)", revised, enterprisev1.PhaseReady,
+		map[string][]mockFuncCall{"Get": statefulSetCalls}, c)
+	if got := revised.Spec.Template.ObjectMeta.Annotations; got["one"] != "two" || got["three"] != "four" {
+		t.Errorf("TestApplyStatefulSetUnmanagedFields(keep): ApplyStatefulSet() annotations = %v; want one=two, three=four", got)
+	}
+	if got := revised.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]; got.String() != "4" {
+		t.Errorf("TestApplyStatefulSetUnmanagedFields(keep): ApplyStatefulSet() cpu = %s; want 4", got.String())
+	}
+
+	// changes to the list of unmanaged fields are saved, and fields that are managed again are reverted
+	revised = desired("spec.replicas")
+	test("TestApplyStatefulSetUnmanagedFields(manage)", revised, enterprisev1.PhaseUpdating,
+		map[string][]mockFuncCall{"Get": statefulSetCalls, "Update": statefulSetCalls}, c)
+	if got := revised.GetAnnotations()[enterprise.UnmanagedFieldsAnnotation]; got != "spec.replicas" {
+		t.Errorf("TestApplyStatefulSetUnmanagedFields(manage): ApplyStatefulSet() %s = %s; want spec.replicas", enterprise.UnmanagedFieldsAnnotation, got)
+	}
+	if got := revised.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]; got.String() != "1" {
+		t.Errorf("TestApplyStatefulSetUnmanagedFields(manage): ApplyStatefulSet() cpu = %s; want 1", got.String())
+	}
+
+	// only the annotation is updated when unmanaged fields do not change the pod template
+	test("TestApplyStatefulSetUnmanagedFields(annotation)", desired(""), enterprisev1.PhaseReady,
+		map[string][]mockFuncCall{"Get": statefulSetCalls, "Update": statefulSetCalls}, c)
+	current = c.state["*v1.StatefulSet-test-splunk-stack1-standalone"].(*appsv1.StatefulSet)
+	if _, ok := current.GetAnnotations()[enterprise.UnmanagedFieldsAnnotation]; ok {
+		t.Errorf("TestApplyStatefulSetUnmanagedFields(annotation): ApplyStatefulSet() annotations = %v; want no %s", current.GetAnnotations(), enterprise.UnmanagedFieldsAnnotation)
+	}
+}
+
+func TestUpdateStatefulSetPodsUnmanagedReplicas(t *testing.T) {
+	// a StatefulSet scaled to 3 replicas by others is not scaled down to the desired number of replicas
+	var replicas int32 = 3
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "splunk-stack1",
+			Namespace:   "test",
+			Annotations: map[string]string{enterprise.UnmanagedFieldsAnnotation: enterprise.UnmanagedReplicas},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1.StatefulSetStatus{
+			Replicas:      replicas,
+			ReadyReplicas: replicas,
+		},
+	}
+	c := newMockClient()
+	podCalls := []mockFuncCall{}
+	for n := replicas - 1; n >= 0; n-- {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("splunk-stack1-%d", n),
+				Namespace: "test",
+			},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+		}
+		c.state[getStateKey(pod)] = pod
+		podCalls = append(podCalls, mockFuncCall{metaName: "*v1.Pod-test-" + pod.GetName()})
+	}
+
+	phase, err := UpdateStatefulSetPods(c, statefulSet, &DefaultStatefulSetPodManager{}, 1)
+	if err != nil || phase != enterprisev1.PhaseReady {
+		t.Errorf("UpdateStatefulSetPods() = %s, %v; want %s, nil", phase, err, enterprisev1.PhaseReady)
+	}
+	if *statefulSet.Spec.Replicas != 3 {
+		t.Errorf("UpdateStatefulSetPods() replicas = %d; want 3", *statefulSet.Spec.Replicas)
+	}
+	c.checkCalls(t, "TestUpdateStatefulSetPodsUnmanagedReplicas", map[string][]mockFuncCall{"Get": podCalls})
+}
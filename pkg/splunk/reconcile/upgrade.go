@@ -0,0 +1,169 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ApplyUpgradeImage updates the spec of a custom resource to use the image requested using its splunk-image annotation,
+// once all of the linked resources that come before it in the upgrade order have been upgraded to the same image. Linked
+// resources are upgraded in the order: cluster masters, indexer cluster peers, search heads (deployers before search head
+// cluster members) and license masters. Until then, the spec is updated to keep using the image of its current StatefulSet.
+// It returns true if the upgrade is waiting for other resources.
+func ApplyUpgradeImage(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, statefulSetName string) (bool, error) {
+	image := enterprise.GetRequestedImage(cr.GetObjectMeta())
+	if image == "" || image == spec.Image {
+		return false, nil
+	}
+	if err := enterprise.ValidateImage(image); err != nil {
+		return false, err
+	}
+
+	predecessors, err := getUpgradePredecessors(client, cr)
+	if err != nil {
+		return false, err
+	}
+	for _, predecessor := range predecessors {
+		if enterprise.GetRequestedImage(predecessor.GetObjectMeta()) == image && !isUpgraded(predecessor, image) {
+			log.WithName("ApplyUpgradeImage").Info("Waiting for linked resource to be upgraded", "name", cr.GetIdentifier(), "namespace", cr.GetNamespace(),
+				"linkedKind", predecessor.GetTypeMeta().Kind, "linkedName", predecessor.GetIdentifier(), "image", image)
+			return true, keepCurrentImage(client, cr.GetNamespace(), statefulSetName, spec)
+		}
+	}
+
+	spec.Image = image
+	return false, nil
+}
+
+// keepCurrentImage updates a spec to keep using the image of an existing StatefulSet; nothing is changed if it does not exist
+func keepCurrentImage(client ControllerClient, namespace, statefulSetName string, spec *enterprisev1.CommonSplunkSpec) error {
+	var statefulSet appsv1.StatefulSet
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: statefulSetName}, &statefulSet)
+	if err != nil {
+		return nil
+	}
+	for _, container := range statefulSet.Spec.Template.Spec.Containers {
+		if container.Name == "splunk" {
+			spec.Image = container.Image
+		}
+	}
+	return nil
+}
+
+// isUpgraded returns true if a linked resource is ready, and its health has been verified after upgrading to image
+func isUpgraded(cr enterprisev1.MetaObject, image string) bool {
+	switch obj := cr.(type) {
+	case *enterprisev1.ClusterMaster:
+		return obj.Status.Phase == enterprisev1.PhaseReady && obj.Status.VerifiedImage == image
+	case *enterprisev1.IndexerCluster:
+		return obj.Status.Phase == enterprisev1.PhaseReady && obj.Status.VerifiedImage == image
+	case *enterprisev1.SearchHeadCluster:
+		return obj.Status.Phase == enterprisev1.PhaseReady && obj.Status.VerifiedImage == image
+	case *enterprisev1.Standalone:
+		return obj.Status.Phase == enterprisev1.PhaseReady && obj.Status.VerifiedImage == image
+	}
+	return true
+}
+
+// getUpgradePredecessors returns the linked resources that must be upgraded before a custom resource
+func getUpgradePredecessors(c ControllerClient, cr enterprisev1.MetaObject) ([]enterprisev1.MetaObject, error) {
+	var predecessors []enterprisev1.MetaObject
+	switch obj := cr.(type) {
+	case *enterprisev1.IndexerCluster:
+		// indexer clusters without a clusterMasterRef upgrade their own cluster master first
+		if obj.Spec.ClusterMasterRef.Name != "" {
+			var clusterMaster enterprisev1.ClusterMaster
+			if getReferencedResource(c, cr, obj.Spec.ClusterMasterRef, &clusterMaster) {
+				predecessors = append(predecessors, &clusterMaster)
+			}
+		}
+	case *enterprisev1.SearchHeadCluster:
+		return getSearchHeadUpgradePredecessors(c, cr, &obj.Spec.CommonSplunkSpec)
+	case *enterprisev1.Standalone:
+		return getSearchHeadUpgradePredecessors(c, cr, &obj.Spec.CommonSplunkSpec)
+	case *enterprisev1.LicenseMaster:
+		// all resources using the license master are upgraded before it, including those that reference it from other namespaces
+		var clusterMasters enterprisev1.ClusterMasterList
+		var indexerClusters enterprisev1.IndexerClusterList
+		var searchHeadClusters enterprisev1.SearchHeadClusterList
+		var standalones enterprisev1.StandaloneList
+		for _, list := range []runtime.Object{&clusterMasters, &indexerClusters, &searchHeadClusters, &standalones} {
+			if err := c.List(context.TODO(), list); err != nil {
+				return nil, err
+			}
+		}
+		for idx := range clusterMasters.Items {
+			predecessors = appendIfReferenced(predecessors, &clusterMasters.Items[idx], clusterMasters.Items[idx].Spec.LicenseMasterRef, cr)
+		}
+		for idx := range indexerClusters.Items {
+			predecessors = appendIfReferenced(predecessors, &indexerClusters.Items[idx], indexerClusters.Items[idx].Spec.LicenseMasterRef, cr)
+		}
+		for idx := range searchHeadClusters.Items {
+			predecessors = appendIfReferenced(predecessors, &searchHeadClusters.Items[idx], searchHeadClusters.Items[idx].Spec.LicenseMasterRef, cr)
+		}
+		for idx := range standalones.Items {
+			predecessors = appendIfReferenced(predecessors, &standalones.Items[idx], standalones.Items[idx].Spec.LicenseMasterRef, cr)
+		}
+	}
+	return predecessors, nil
+}
+
+// getSearchHeadUpgradePredecessors returns the cluster master and indexer cluster peers that must be upgraded before search heads
+func getSearchHeadUpgradePredecessors(c ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec) ([]enterprisev1.MetaObject, error) {
+	var predecessors []enterprisev1.MetaObject
+	if spec.ClusterMasterRef.Name != "" {
+		var clusterMaster enterprisev1.ClusterMaster
+		if !getReferencedResource(c, cr, spec.ClusterMasterRef, &clusterMaster) {
+			return nil, nil
+		}
+		predecessors = append(predecessors, &clusterMaster)
+
+		// the peers of a ClusterMaster are every IndexerCluster that references it, in any namespace
+		var indexerClusters enterprisev1.IndexerClusterList
+		if err := c.List(context.TODO(), &indexerClusters); err != nil {
+			return nil, err
+		}
+		for idx := range indexerClusters.Items {
+			predecessors = appendIfReferenced(predecessors, &indexerClusters.Items[idx], indexerClusters.Items[idx].Spec.ClusterMasterRef, &clusterMaster)
+		}
+	} else if spec.IndexerClusterRef.Name != "" {
+		var indexerCluster enterprisev1.IndexerCluster
+		if getReferencedResource(c, cr, spec.IndexerClusterRef, &indexerCluster) {
+			predecessors = append(predecessors, &indexerCluster)
+		}
+	}
+	return predecessors, nil
+}
+
+// appendIfReferenced appends a linked resource to a list, if ref refers to target
+func appendIfReferenced(list []enterprisev1.MetaObject, linked enterprisev1.MetaObject, ref corev1.ObjectReference, target enterprisev1.MetaObject) []enterprisev1.MetaObject {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = linked.GetNamespace()
+	}
+	if ref.Name == target.GetIdentifier() && namespace == target.GetNamespace() {
+		list = append(list, linked)
+	}
+	return list
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestApplyUpgradeImage(t *testing.T) {
+	oldImage := "splunk/splunk:8.0.5"
+	newImage := "splunk/splunk:8.1.0@sha256:" + strings.Repeat("0a", 32)
+	annotations := map[string]string{enterprise.SplunkImageAnnotation: newImage}
+
+	clusterMaster := enterprisev1.ClusterMaster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test", Annotations: annotations},
+	}
+	clusterMaster.Spec.Image = oldImage
+	indexerCluster := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test", Annotations: annotations},
+	}
+	indexerCluster.Spec.Image = oldImage
+	indexerCluster.Spec.ClusterMasterRef.Name = "cm"
+	statefulSet := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-idxc-indexer", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "splunk", Image: "splunk/splunk:8.0.6"}},
+				},
+			},
+		},
+	}
+	c := newMockClient()
+	c.state[getStateKey(&clusterMaster)] = &clusterMaster
+	c.state[getStateKey(&statefulSet)] = &statefulSet
+
+	test := func(testMethod string, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, statefulSetName string, wantPending bool, wantImage string) {
+		spec.Image = oldImage
+		pending, err := ApplyUpgradeImage(c, cr, spec, statefulSetName)
+		if err != nil {
+			t.Errorf("%s returned %v; want nil", testMethod, err)
+		}
+		if pending != wantPending {
+			t.Errorf("%s pending = %t; want %t", testMethod, pending, wantPending)
+		}
+		if spec.Image != wantImage {
+			t.Errorf("%s image = %s; want %s", testMethod, spec.Image, wantImage)
+		}
+	}
+
+	// cluster masters are upgraded first
+	test("TestApplyUpgradeImage(cluster-master)", &clusterMaster, &clusterMaster.Spec.CommonSplunkSpec, "splunk-cm-cluster-master", false, newImage)
+
+	// peers keep using their current image until the cluster master has been upgraded and verified
+	test("TestApplyUpgradeImage(peers-waiting)", &indexerCluster, &indexerCluster.Spec.CommonSplunkSpec, "splunk-idxc-indexer", true, "splunk/splunk:8.0.6")
+	clusterMaster.Status.Phase = enterprisev1.PhaseReady
+	clusterMaster.Status.VerifiedImage = newImage
+	test("TestApplyUpgradeImage(peers)", &indexerCluster, &indexerCluster.Spec.CommonSplunkSpec, "splunk-idxc-indexer", false, newImage)
+
+	// linked resources that are not being upgraded to the same image are not waited for
+	clusterMaster.Status.Phase = enterprisev1.PhaseUpdating
+	clusterMaster.Annotations = nil
+	test("TestApplyUpgradeImage(not-linked)", &indexerCluster, &indexerCluster.Spec.CommonSplunkSpec, "splunk-idxc-indexer", false, newImage)
+	clusterMaster.Annotations = annotations
+
+	// search heads wait for indexer cluster peers
+	c.state[getStateKey(&indexerCluster)] = &indexerCluster
+	searchHeadCluster := enterprisev1.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shc", Namespace: "test", Annotations: annotations},
+	}
+	searchHeadCluster.Spec.IndexerClusterRef.Name = "idxc"
+	test("TestApplyUpgradeImage(search-heads-waiting)", &searchHeadCluster, &searchHeadCluster.Spec.CommonSplunkSpec, "splunk-shc-deployer", true, oldImage)
+	indexerCluster.Status.Phase = enterprisev1.PhaseReady
+	indexerCluster.Status.VerifiedImage = newImage
+	test("TestApplyUpgradeImage(search-heads)", &searchHeadCluster, &searchHeadCluster.Spec.CommonSplunkSpec, "splunk-shc-deployer", false, newImage)
+
+	// license masters are upgraded last
+	licenseMaster := enterprisev1.LicenseMaster{
+		ObjectMeta: metav1.ObjectMeta{Name: "lm", Namespace: "test", Annotations: annotations},
+	}
+	peers := indexerCluster.DeepCopy()
+	peers.Spec.LicenseMasterRef.Name = "lm"
+	peers.Status.Phase = enterprisev1.PhaseUpdating
+	c.listObj = &enterprisev1.IndexerClusterList{Items: []enterprisev1.IndexerCluster{*peers}}
+	test("TestApplyUpgradeImage(license-master-waiting)", &licenseMaster, &licenseMaster.Spec.CommonSplunkSpec, "splunk-lm-license-master", true, oldImage)
+	c.listObj.(*enterprisev1.IndexerClusterList).Items[0].Status.Phase = enterprisev1.PhaseReady
+	test("TestApplyUpgradeImage(license-master)", &licenseMaster, &licenseMaster.Spec.CommonSplunkSpec, "splunk-lm-license-master", false, newImage)
+
+	// resources in other namespaces are waited for only if they reference the license master's namespace
+	peers.Namespace = "other"
+	peers.Status.Phase = enterprisev1.PhaseUpdating
+	c.listObj = &enterprisev1.IndexerClusterList{Items: []enterprisev1.IndexerCluster{*peers}}
+	test("TestApplyUpgradeImage(license-master-other-namespace)", &licenseMaster, &licenseMaster.Spec.CommonSplunkSpec, "splunk-lm-license-master", false, newImage)
+	c.listObj.(*enterprisev1.IndexerClusterList).Items[0].Spec.LicenseMasterRef.Namespace = "test"
+	test("TestApplyUpgradeImage(license-master-cross-namespace)", &licenseMaster, &licenseMaster.Spec.CommonSplunkSpec, "splunk-lm-license-master", true, oldImage)
+
+	// images pinned using an invalid digest are rejected
+	licenseMaster.Annotations = map[string]string{enterprise.SplunkImageAnnotation: "splunk/splunk@sha256:0123"}
+	if _, err := ApplyUpgradeImage(c, &licenseMaster, &licenseMaster.Spec.CommonSplunkSpec, "splunk-lm-license-master"); err == nil {
+		t.Errorf("ApplyUpgradeImage() returned nil; want error for invalid digest")
+	}
+}
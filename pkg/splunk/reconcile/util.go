@@ -17,6 +17,7 @@ package reconcile
 import (
 	"context"
 	"reflect"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -28,6 +29,8 @@ import (
 	//stdlog "log"
 	//"github.com/go-logr/stdr"
 
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 )
 
@@ -48,6 +51,16 @@ type ControllerClient interface {
 	client.Client
 }
 
+// getRequeueInterval returns the interval between reconciles of a custom resource that is not ready yet, using the
+// default interval if the one configured is invalid
+func getRequeueInterval(cr enterprisev1.MetaObject) time.Duration {
+	interval, err := enterprise.GetRequeueInterval(cr)
+	if err != nil {
+		log.Error(err, "Invalid requeue interval", "name", cr.GetObjectMeta().GetName(), "namespace", cr.GetNamespace())
+	}
+	return interval
+}
+
 // CreateResource creates a new Kubernetes resource using the REST API.
 func CreateResource(client ControllerClient, obj ResourceObject) error {
 	scopedLog := log.WithName("CreateResource").WithValues(
@@ -137,6 +150,24 @@ func MergePodSpecUpdates(current *corev1.PodSpec, revised *corev1.PodSpec, name
 		result = true
 	}
 
+	// check for changes in TopologySpreadConstraints
+	if resources.CompareByMarshall(current.TopologySpreadConstraints, revised.TopologySpreadConstraints) {
+		scopedLog.Info("Pod TopologySpreadConstraints differ",
+			"current", current.TopologySpreadConstraints,
+			"revised", revised.TopologySpreadConstraints)
+		current.TopologySpreadConstraints = revised.TopologySpreadConstraints
+		result = true
+	}
+
+	// check for changes in TerminationGracePeriodSeconds (Kubernetes sets a default if it is not set)
+	if revised.TerminationGracePeriodSeconds != nil && resources.CompareByMarshall(current.TerminationGracePeriodSeconds, revised.TerminationGracePeriodSeconds) {
+		scopedLog.Info("Pod TerminationGracePeriodSeconds differs",
+			"current", current.TerminationGracePeriodSeconds,
+			"revised", revised.TerminationGracePeriodSeconds)
+		current.TerminationGracePeriodSeconds = revised.TerminationGracePeriodSeconds
+		result = true
+	}
+
 	// check for changes in SchedulerName
 	if current.SchedulerName != revised.SchedulerName {
 		scopedLog.Info("Pod SchedulerName differs",
@@ -146,6 +177,43 @@ func MergePodSpecUpdates(current *corev1.PodSpec, revised *corev1.PodSpec, name
 		result = true
 	}
 
+	// check for changes in SecurityContext
+	if resources.CompareByMarshall(current.SecurityContext, revised.SecurityContext) {
+		scopedLog.Info("Pod SecurityContext differs",
+			"current", current.SecurityContext,
+			"revised", revised.SecurityContext)
+		current.SecurityContext = revised.SecurityContext
+		result = true
+	}
+
+	// check for changes in ServiceAccountName (Kubernetes also sets the deprecated serviceAccount field)
+	if current.ServiceAccountName != revised.ServiceAccountName {
+		scopedLog.Info("Pod ServiceAccountName differs",
+			"current", current.ServiceAccountName,
+			"revised", revised.ServiceAccountName)
+		current.ServiceAccountName = revised.ServiceAccountName
+		current.DeprecatedServiceAccount = revised.ServiceAccountName
+		result = true
+	}
+
+	// check for changes in PriorityClassName
+	if current.PriorityClassName != revised.PriorityClassName {
+		scopedLog.Info("Pod PriorityClassName differs",
+			"current", current.PriorityClassName,
+			"revised", revised.PriorityClassName)
+		current.PriorityClassName = revised.PriorityClassName
+		result = true
+	}
+
+	// check for changes in ImagePullSecrets
+	if (len(current.ImagePullSecrets) > 0 || len(revised.ImagePullSecrets) > 0) && resources.CompareByMarshall(current.ImagePullSecrets, revised.ImagePullSecrets) {
+		scopedLog.Info("Pod ImagePullSecrets differ",
+			"current", current.ImagePullSecrets,
+			"revised", revised.ImagePullSecrets)
+		current.ImagePullSecrets = revised.ImagePullSecrets
+		result = true
+	}
+
 	// Check for changes in Volumes
 	if resources.CompareVolumes(current.Volumes, revised.Volumes) {
 		scopedLog.Info("Pod Volumes differ",
@@ -173,6 +241,26 @@ func MergePodSpecUpdates(current *corev1.PodSpec, revised *corev1.PodSpec, name
 				result = true
 			}
 
+			// check ImagePullPolicy (Kubernetes sets a default if it is not set)
+			if revised.Containers[idx].ImagePullPolicy != "" && current.Containers[idx].ImagePullPolicy != revised.Containers[idx].ImagePullPolicy {
+				scopedLog.Info("Pod Container ImagePullPolicies differ",
+					"current", current.Containers[idx].ImagePullPolicy,
+					"revised", revised.Containers[idx].ImagePullPolicy)
+				current.Containers[idx].ImagePullPolicy = revised.Containers[idx].ImagePullPolicy
+				result = true
+			}
+
+			// check Command and Args
+			if resources.CompareByMarshall(current.Containers[idx].Command, revised.Containers[idx].Command) ||
+				resources.CompareByMarshall(current.Containers[idx].Args, revised.Containers[idx].Args) {
+				scopedLog.Info("Pod Container Commands differ",
+					"current", current.Containers[idx].Command,
+					"revised", revised.Containers[idx].Command)
+				current.Containers[idx].Command = revised.Containers[idx].Command
+				current.Containers[idx].Args = revised.Containers[idx].Args
+				result = true
+			}
+
 			// check Ports
 			if resources.CompareContainerPorts(current.Containers[idx].Ports, revised.Containers[idx].Ports) {
 				scopedLog.Info("Pod Container Ports differ",
@@ -191,6 +279,15 @@ func MergePodSpecUpdates(current *corev1.PodSpec, revised *corev1.PodSpec, name
 				result = true
 			}
 
+			// check Env
+			if resources.CompareEnvs(current.Containers[idx].Env, revised.Containers[idx].Env) {
+				scopedLog.Info("Pod Container Envs differ",
+					"current", current.Containers[idx].Env,
+					"revised", revised.Containers[idx].Env)
+				current.Containers[idx].Env = revised.Containers[idx].Env
+				result = true
+			}
+
 			// check Resources
 			if resources.CompareByMarshall(&current.Containers[idx].Resources, &revised.Containers[idx].Resources) {
 				scopedLog.Info("Pod Container Resources differ",
@@ -199,6 +296,70 @@ func MergePodSpecUpdates(current *corev1.PodSpec, revised *corev1.PodSpec, name
 				current.Containers[idx].Resources = revised.Containers[idx].Resources
 				result = true
 			}
+
+			// check LivenessProbe
+			if resources.CompareProbes(current.Containers[idx].LivenessProbe, revised.Containers[idx].LivenessProbe) {
+				scopedLog.Info("Pod Container LivenessProbes differ",
+					"current", current.Containers[idx].LivenessProbe,
+					"revised", revised.Containers[idx].LivenessProbe)
+				current.Containers[idx].LivenessProbe = revised.Containers[idx].LivenessProbe
+				result = true
+			}
+
+			// check ReadinessProbe
+			if resources.CompareProbes(current.Containers[idx].ReadinessProbe, revised.Containers[idx].ReadinessProbe) {
+				scopedLog.Info("Pod Container ReadinessProbes differ",
+					"current", current.Containers[idx].ReadinessProbe,
+					"revised", revised.Containers[idx].ReadinessProbe)
+				current.Containers[idx].ReadinessProbe = revised.Containers[idx].ReadinessProbe
+				result = true
+			}
+
+			// check SecurityContext
+			if resources.CompareByMarshall(current.Containers[idx].SecurityContext, revised.Containers[idx].SecurityContext) {
+				scopedLog.Info("Pod Container SecurityContexts differ",
+					"current", current.Containers[idx].SecurityContext,
+					"revised", revised.Containers[idx].SecurityContext)
+				current.Containers[idx].SecurityContext = revised.Containers[idx].SecurityContext
+				result = true
+			}
+
+			// check Lifecycle
+			if resources.CompareByMarshall(current.Containers[idx].Lifecycle, revised.Containers[idx].Lifecycle) {
+				scopedLog.Info("Pod Container Lifecycles differ",
+					"current", current.Containers[idx].Lifecycle,
+					"revised", revised.Containers[idx].Lifecycle)
+				current.Containers[idx].Lifecycle = revised.Containers[idx].Lifecycle
+				result = true
+			}
+		}
+	}
+
+	// check for changes in init containers; only fields set by the operator are compared, since Kubernetes adds defaults to others
+	if len(current.InitContainers) != len(revised.InitContainers) {
+		scopedLog.Info("Pod InitContainer counts differ",
+			"current", len(current.InitContainers),
+			"revised", len(revised.InitContainers))
+		current.InitContainers = revised.InitContainers
+		result = true
+	} else {
+		for idx := range current.InitContainers {
+			currentContainer := &current.InitContainers[idx]
+			revisedContainer := &revised.InitContainers[idx]
+			if currentContainer.Name != revisedContainer.Name ||
+				currentContainer.Image != revisedContainer.Image ||
+				resources.CompareByMarshall(currentContainer.Command, revisedContainer.Command) ||
+				resources.CompareByMarshall(currentContainer.Args, revisedContainer.Args) ||
+				resources.CompareEnvs(currentContainer.Env, revisedContainer.Env) ||
+				resources.CompareVolumeMounts(currentContainer.VolumeMounts, revisedContainer.VolumeMounts) ||
+				resources.CompareByMarshall(&currentContainer.Resources, &revisedContainer.Resources) ||
+				resources.CompareByMarshall(currentContainer.SecurityContext, revisedContainer.SecurityContext) {
+				scopedLog.Info("Pod InitContainers differ",
+					"current", currentContainer,
+					"revised", revisedContainer)
+				current.InitContainers[idx] = *revisedContainer
+				result = true
+			}
 		}
 	}
 
@@ -243,6 +404,26 @@ func MergeServiceSpecUpdates(current *corev1.ServiceSpec, revised *corev1.Servic
 		result = true
 	}
 
+	// check for changes in Selector, which is used to switch search head clusters during blue/green upgrades
+	if (len(current.Selector) > 0 || len(revised.Selector) > 0) && !reflect.DeepEqual(current.Selector, revised.Selector) {
+		scopedLog.Info("Service Selector differs",
+			"current", current.Selector,
+			"revised", revised.Selector)
+		current.Selector = revised.Selector
+		result = true
+	}
+
+	// keep node ports allocated by Kubernetes for ports that do not request a specific one
+	if revised.Type == corev1.ServiceTypeNodePort || revised.Type == corev1.ServiceTypeLoadBalancer {
+		for idx := range revised.Ports {
+			for _, p := range current.Ports {
+				if revised.Ports[idx].NodePort == 0 && p.Name == revised.Ports[idx].Name {
+					revised.Ports[idx].NodePort = p.NodePort
+				}
+			}
+		}
+	}
+
 	// check for changes in Ports
 	if resources.CompareServicePorts(current.Ports, revised.Ports) {
 		scopedLog.Info("Service Ports differs",
@@ -254,3 +435,39 @@ func MergeServiceSpecUpdates(current *corev1.ServiceSpec, revised *corev1.Servic
 
 	return result
 }
+
+// MergeServiceMetaUpdates looks for labels and annotations of a service's
+// revised meta data that are missing or different in its current meta data,
+// and merges them into current. Other labels and annotations are kept, since
+// they may have been added by cloud providers or other controllers. It
+// returns true if current was changed, or false otherwise.
+func MergeServiceMetaUpdates(current *metav1.ObjectMeta, revised *metav1.ObjectMeta, name string) bool {
+	scopedLog := log.WithName("MergeServiceMetaUpdates").WithValues("name", name)
+	result := false
+
+	// check Labels
+	for k, v := range revised.Labels {
+		if value, ok := current.Labels[k]; !ok || value != v {
+			scopedLog.Info("Service Label differs", "label", k, "current", value, "revised", v)
+			if current.Labels == nil {
+				current.Labels = make(map[string]string)
+			}
+			current.Labels[k] = v
+			result = true
+		}
+	}
+
+	// check Annotations
+	for k, v := range revised.Annotations {
+		if value, ok := current.Annotations[k]; !ok || value != v {
+			scopedLog.Info("Service Annotation differs", "annotation", k, "current", value, "revised", v)
+			if current.Annotations == nil {
+				current.Annotations = make(map[string]string)
+			}
+			current.Annotations[k] = v
+			result = true
+		}
+	}
+
+	return result
+}
@@ -20,12 +20,19 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -45,20 +52,56 @@ func copyResource(dst runtime.Object, src runtime.Object) {
 		*dst.(*corev1.Service) = *src.(*corev1.Service)
 	case *corev1.Pod:
 		*dst.(*corev1.Pod) = *src.(*corev1.Pod)
+	case *corev1.PodList:
+		*dst.(*corev1.PodList) = *src.(*corev1.PodList)
+	case *appsv1.DaemonSet:
+		*dst.(*appsv1.DaemonSet) = *src.(*appsv1.DaemonSet)
 	case *appsv1.Deployment:
 		*dst.(*appsv1.Deployment) = *src.(*appsv1.Deployment)
 	case *appsv1.StatefulSet:
 		*dst.(*appsv1.StatefulSet) = *src.(*appsv1.StatefulSet)
+	case *appsv1.StatefulSetList:
+		*dst.(*appsv1.StatefulSetList) = *src.(*appsv1.StatefulSetList)
+	case *policyv1beta1.PodDisruptionBudget:
+		*dst.(*policyv1beta1.PodDisruptionBudget) = *src.(*policyv1beta1.PodDisruptionBudget)
+	case *networkingv1beta1.Ingress:
+		*dst.(*networkingv1beta1.Ingress) = *src.(*networkingv1beta1.Ingress)
+	case *storagev1.StorageClass:
+		*dst.(*storagev1.StorageClass) = *src.(*storagev1.StorageClass)
+	case *enterprisev1.ClusterMaster:
+		*dst.(*enterprisev1.ClusterMaster) = *src.(*enterprisev1.ClusterMaster)
+	case *enterprisev1.ClusterReplication:
+		*dst.(*enterprisev1.ClusterReplication) = *src.(*enterprisev1.ClusterReplication)
+	case *enterprisev1.DeploymentServer:
+		*dst.(*enterprisev1.DeploymentServer) = *src.(*enterprisev1.DeploymentServer)
+	case *enterprisev1.HecToken:
+		*dst.(*enterprisev1.HecToken) = *src.(*enterprisev1.HecToken)
 	case *enterprisev1.IndexerCluster:
 		*dst.(*enterprisev1.IndexerCluster) = *src.(*enterprisev1.IndexerCluster)
+	case *enterprisev1.IndexerClusterList:
+		*dst.(*enterprisev1.IndexerClusterList) = *src.(*enterprisev1.IndexerClusterList)
 	case *enterprisev1.LicenseMaster:
 		*dst.(*enterprisev1.LicenseMaster) = *src.(*enterprisev1.LicenseMaster)
+	case *enterprisev1.MonitoringConsole:
+		*dst.(*enterprisev1.MonitoringConsole) = *src.(*enterprisev1.MonitoringConsole)
 	case *enterprisev1.SearchHeadCluster:
 		*dst.(*enterprisev1.SearchHeadCluster) = *src.(*enterprisev1.SearchHeadCluster)
+	case *enterprisev1.SearchHeadClusterList:
+		*dst.(*enterprisev1.SearchHeadClusterList) = *src.(*enterprisev1.SearchHeadClusterList)
 	case *enterprisev1.Spark:
 		*dst.(*enterprisev1.Spark) = *src.(*enterprisev1.Spark)
+	case *enterprisev1.SplunkBackup:
+		*dst.(*enterprisev1.SplunkBackup) = *src.(*enterprisev1.SplunkBackup)
+	case *enterprisev1.SplunkRestore:
+		*dst.(*enterprisev1.SplunkRestore) = *src.(*enterprisev1.SplunkRestore)
 	case *enterprisev1.Standalone:
 		*dst.(*enterprisev1.Standalone) = *src.(*enterprisev1.Standalone)
+	case *enterprisev1.StandaloneList:
+		*dst.(*enterprisev1.StandaloneList) = *src.(*enterprisev1.StandaloneList)
+	case *enterprisev1.UniversalForwarder:
+		*dst.(*enterprisev1.UniversalForwarder) = *src.(*enterprisev1.UniversalForwarder)
+	case *unstructured.Unstructured:
+		*dst.(*unstructured.Unstructured) = *src.(*unstructured.Unstructured)
 	default:
 		dst = src
 	}
@@ -66,9 +109,10 @@ func copyResource(dst runtime.Object, src runtime.Object) {
 
 // getStateKeyFromObject returns a lookup key for the mockClient's state map
 func getStateKey(obj runtime.Object) string {
+	metaObj, _ := meta.Accessor(obj)
 	key := client.ObjectKey{
-		Name:      obj.(ResourceObject).GetObjectMeta().GetName(),
-		Namespace: obj.(ResourceObject).GetObjectMeta().GetNamespace(),
+		Name:      metaObj.GetName(),
+		Namespace: metaObj.GetNamespace(),
 	}
 	return getStateKeyWithKey(key, obj)
 }
@@ -121,7 +165,7 @@ type mockClient struct {
 	// status is a StatusWriter mock client returned by Status()
 	status mockStatusWriter
 
-	// listObj is used to assign obj parameter for List() calls
+	// listObj is used to assign obj parameter for List() calls; lists of other types are returned empty
 	listObj runtime.Object
 
 	// state is used to maintain a simple state of objects in the cluster, where key = <type>-<namespace>-<name>
@@ -158,7 +202,9 @@ func (c mockClient) List(ctx context.Context, obj runtime.Object, opts ...client
 	})
 	listObj := c.listObj
 	if listObj != nil {
-		copyResource(obj, listObj.(runtime.Object))
+		if reflect.TypeOf(obj) == reflect.TypeOf(listObj) {
+			copyResource(obj, listObj.(runtime.Object))
+		}
 		return nil
 	}
 	return c.notFoundError
@@ -324,6 +370,25 @@ func reconcileTester(t *testing.T, method string,
 	c.checkCalls(t, methodPlus, updateCalls)
 }
 
+func TestGetRequeueInterval(t *testing.T) {
+	cr := enterprisev1.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "stack1",
+			Namespace:   "test",
+			Annotations: map[string]string{enterprise.RequeueIntervalAnnotation: "2s"},
+		},
+	}
+	if got := getRequeueInterval(&cr); got != 2*time.Second {
+		t.Errorf("getRequeueInterval() = %s; want %s", got, 2*time.Second)
+	}
+
+	// invalid intervals are ignored
+	cr.ObjectMeta.Annotations[enterprise.RequeueIntervalAnnotation] = "never"
+	if got := getRequeueInterval(&cr); got != 5*time.Second {
+		t.Errorf("getRequeueInterval() = %s; want %s", got, 5*time.Second)
+	}
+}
+
 func TestCreateResource(t *testing.T) {
 	secret := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -399,11 +464,57 @@ func TestMergePodUpdates(t *testing.T) {
 	matcher = func() bool { return current.Spec.Affinity == revised.Spec.Affinity }
 	podUpdateTester("Affinity")
 
+	// check TopologySpreadConstraints
+	revised.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+	}
+	matcher = func() bool {
+		return reflect.DeepEqual(current.Spec.TopologySpreadConstraints, revised.Spec.TopologySpreadConstraints)
+	}
+	podUpdateTester("TopologySpreadConstraints")
+
 	// check SchedulerName
 	revised.Spec.SchedulerName = "gp2"
 	matcher = func() bool { return current.Spec.SchedulerName == revised.Spec.SchedulerName }
 	podUpdateTester("SchedulerName")
 
+	// check SecurityContext
+	runAsNonRoot := true
+	revised.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.SecurityContext, revised.Spec.SecurityContext) }
+	podUpdateTester("SecurityContext")
+
+	// check ServiceAccountName
+	revised.Spec.ServiceAccountName = "splunk-anyuid"
+	matcher = func() bool {
+		return current.Spec.ServiceAccountName == revised.Spec.ServiceAccountName && current.Spec.DeprecatedServiceAccount == revised.Spec.ServiceAccountName
+	}
+	podUpdateTester("ServiceAccountName")
+
+	// check PriorityClassName
+	revised.Spec.PriorityClassName = "splunk-indexers"
+	matcher = func() bool { return current.Spec.PriorityClassName == revised.Spec.PriorityClassName }
+	podUpdateTester("PriorityClassName")
+
+	// check ImagePullSecrets
+	revised.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry-credentials"}}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.ImagePullSecrets, revised.Spec.ImagePullSecrets) }
+	podUpdateTester("ImagePullSecrets")
+
+	// check TerminationGracePeriodSeconds
+	terminationGracePeriodSeconds := int64(900)
+	revised.Spec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+	matcher = func() bool {
+		return *current.Spec.TerminationGracePeriodSeconds == *revised.Spec.TerminationGracePeriodSeconds
+	}
+	podUpdateTester("TerminationGracePeriodSeconds")
+
+	// default TerminationGracePeriodSeconds is kept if not set
+	revised.Spec.TerminationGracePeriodSeconds = nil
+	if MergePodUpdates(&current, &revised, name) {
+		t.Errorf("MergePodUpdates() returned %t for unset terminationGracePeriodSeconds; want %t", true, false)
+	}
+
 	// check new Volume added
 	revised.Spec.Volumes = []corev1.Volume{{Name: "new-volume-added"}}
 	matcher = func() bool { return reflect.DeepEqual(current.Spec.Volumes, revised.Spec.Volumes) }
@@ -441,6 +552,13 @@ func TestMergePodUpdates(t *testing.T) {
 	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
 	podUpdateTester("Container Image")
 
+	// check container different ImagePullPolicy
+	revised.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
+	matcher = func() bool {
+		return current.Spec.Containers[0].ImagePullPolicy == revised.Spec.Containers[0].ImagePullPolicy
+	}
+	podUpdateTester("Container ImagePullPolicy")
+
 	// check container different Ports
 	revised.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 8000}}
 	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
@@ -465,10 +583,83 @@ func TestMergePodUpdates(t *testing.T) {
 	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
 	podUpdateTester("Container Resources")
 
+	// check container different LivenessProbe
+	revised.Spec.Containers[0].LivenessProbe = &corev1.Probe{
+		Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/sbin/checkstate.sh"}}},
+		InitialDelaySeconds: 900,
+	}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
+	podUpdateTester("Container LivenessProbe")
+
+	// check container different ReadinessProbe
+	revised.Spec.Containers[0].ReadinessProbe = &corev1.Probe{
+		Handler:          corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/bin/grep", "started", "/opt/container_artifact/splunk-container.state"}}},
+		FailureThreshold: 10,
+	}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
+	podUpdateTester("Container ReadinessProbe")
+
+	// probe fields set to Kubernetes defaults are not differences
+	current.Spec.Containers[0].ReadinessProbe = revised.Spec.Containers[0].ReadinessProbe.DeepCopy()
+	current.Spec.Containers[0].ReadinessProbe.TimeoutSeconds = 1
+	current.Spec.Containers[0].ReadinessProbe.PeriodSeconds = 10
+	current.Spec.Containers[0].ReadinessProbe.SuccessThreshold = 1
+	if MergePodUpdates(&current, &revised, name) {
+		t.Errorf("MergePodUpdates() returned %t for probe with default values; want %t", true, false)
+	}
+
+	// check container different Lifecycle
+	revised.Spec.Containers[0].Lifecycle = &corev1.Lifecycle{
+		PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", "/opt/splunk/bin/splunk stop"}}},
+	}
+	matcher = func() bool {
+		return reflect.DeepEqual(current.Spec.Containers[0].Lifecycle, revised.Spec.Containers[0].Lifecycle)
+	}
+	podUpdateTester("Container Lifecycle")
+
+	// check container different SecurityContext
+	readOnlyRootFilesystem := true
+	revised.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnlyRootFilesystem}
+	matcher = func() bool {
+		return reflect.DeepEqual(current.Spec.Containers[0].SecurityContext, revised.Spec.Containers[0].SecurityContext)
+	}
+	podUpdateTester("Container SecurityContext")
+
+	// check container different Command and Args
+	revised.Spec.Containers[0].Command = []string{"/fluent-bit/bin/fluent-bit"}
+	revised.Spec.Containers[0].Args = []string{"-c", "/fluent-bit/etc/fluent-bit.conf"}
+	matcher = func() bool {
+		return reflect.DeepEqual(current.Spec.Containers[0].Command, revised.Spec.Containers[0].Command) &&
+			reflect.DeepEqual(current.Spec.Containers[0].Args, revised.Spec.Containers[0].Args)
+	}
+	podUpdateTester("Container Command")
+
+	// check container different Env
+	revised.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"}}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers[0].Env, revised.Spec.Containers[0].Env) }
+	podUpdateTester("Container Env")
+
 	// check container removed
 	revised.Spec.Containers = []corev1.Container{}
 	matcher = func() bool { return reflect.DeepEqual(current.Spec.Containers, revised.Spec.Containers) }
 	podUpdateTester("Container removed")
+
+	// check new init container added
+	revised.Spec.InitContainers = []corev1.Container{{Name: "init-script", Image: "splunk/splunk", Command: []string{"/bin/sh", "/mnt/splunk-init-script/init.sh"}}}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.InitContainers, revised.Spec.InitContainers) }
+	podUpdateTester("InitContainer added")
+
+	// check init container different Command
+	revised.Spec.InitContainers = []corev1.Container{{Name: "init-script", Image: "splunk/splunk", Command: []string{"/bin/sh", "/mnt/splunk-init-script/seed.sh"}}}
+	matcher = func() bool { return reflect.DeepEqual(current.Spec.InitContainers, revised.Spec.InitContainers) }
+	podUpdateTester("InitContainer Command")
+
+	// init container fields set to Kubernetes defaults are not differences
+	current.Spec.InitContainers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+	current.Spec.InitContainers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	if MergePodUpdates(&current, &revised, name) {
+		t.Errorf("MergePodUpdates() returned %t for init container with default values; want %t", true, false)
+	}
 }
 
 func TestMergeServiceSpecUpdates(t *testing.T) {
@@ -530,4 +721,56 @@ func TestMergeServiceSpecUpdates(t *testing.T) {
 	revised.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
 	matcher = func() bool { return current.ExternalTrafficPolicy == revised.ExternalTrafficPolicy }
 	svcUpdateTester("Service ExternalTrafficPolicy changed")
+
+	current.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-stack1-search-head"}
+	revised.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-stack1-green-search-head"}
+	matcher = func() bool { return reflect.DeepEqual(current.Selector, revised.Selector) }
+	svcUpdateTester("Service Selector changed")
+
+	// node ports allocated by Kubernetes are kept, unless a specific one is requested
+	current.Ports = []corev1.ServicePort{{Name: "s2s", Port: 9997, NodePort: 31234}}
+	revised.Ports = []corev1.ServicePort{{Name: "s2s", Port: 9997}}
+	if MergeServiceSpecUpdates(&current, &revised, name) {
+		t.Errorf("MergeServiceSpecUpdates() with allocated node port returned %t; want %t", true, false)
+	}
+	revised.Ports = []corev1.ServicePort{{Name: "s2s", Port: 9997, NodePort: 30997}}
+	matcher = func() bool { return reflect.DeepEqual(current.Ports, revised.Ports) }
+	svcUpdateTester("Service NodePort changed")
+}
+
+func TestMergeServiceMetaUpdates(t *testing.T) {
+	current := metav1.ObjectMeta{
+		Annotations: map[string]string{"cloud.google.com/neg-status": "{}"},
+	}
+	revised := metav1.ObjectMeta{
+		Labels:      map[string]string{"team": "security"},
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+	}
+	name := "test-svc"
+
+	if !MergeServiceMetaUpdates(&current, &revised, name) {
+		t.Errorf("MergeServiceMetaUpdates() returned %t; want %t", false, true)
+	}
+	want := metav1.ObjectMeta{
+		Labels: map[string]string{"team": "security"},
+		Annotations: map[string]string{
+			"cloud.google.com/neg-status":                       "{}",
+			"service.beta.kubernetes.io/aws-load-balancer-type": "nlb",
+		},
+	}
+	if !reflect.DeepEqual(current, want) {
+		t.Errorf("MergeServiceMetaUpdates() current = %v; want %v", current, want)
+	}
+	if MergeServiceMetaUpdates(&current, &revised, name) {
+		t.Errorf("MergeServiceMetaUpdates() re-run returned %t; want %t", true, false)
+	}
+
+	// check Annotation changed
+	revised.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] = "external"
+	if !MergeServiceMetaUpdates(&current, &revised, name) {
+		t.Errorf("MergeServiceMetaUpdates() returned %t; want %t", false, true)
+	}
+	if current.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] != "external" {
+		t.Errorf("MergeServiceMetaUpdates() annotations = %v; want aws-load-balancer-type=external", current.Annotations)
+	}
 }
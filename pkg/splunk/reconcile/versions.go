@@ -0,0 +1,96 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+// ValidateReferencedVersions returns an error if the version of Splunk Enterprise used by a custom resource is not compatible
+// with the versions used by the license master, cluster master or indexer cluster that it references. Referenced resources
+// that cannot be retrieved are not checked, since errors retrieving them are reported when their secrets are used, and
+// nor are those being upgraded to the same image, since they are upgraded in a supported order (see ApplyUpgradeImage).
+func ValidateReferencedVersions(client ControllerClient, cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType enterprise.InstanceType) error {
+	image := enterprise.GetSplunkImage(spec.Image)
+
+	if instanceType != enterprise.SplunkLicenseMaster && spec.LicenseMasterRef.Name != "" {
+		var licenseMaster enterprisev1.LicenseMaster
+		if getReferencedResource(client, cr, spec.LicenseMasterRef, &licenseMaster) && !isUpgradingTogether(cr, &licenseMaster) {
+			err := enterprise.ValidateVersionSkew(instanceType, image, enterprise.SplunkLicenseMaster, getLinkedImage(&licenseMaster, licenseMaster.Spec.Image))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// references to a cluster master take precedence over references to an indexer cluster
+	var err error
+	if instanceType != enterprise.SplunkClusterMaster && spec.ClusterMasterRef.Name != "" {
+		var clusterMaster enterprisev1.ClusterMaster
+		if getReferencedResource(client, cr, spec.ClusterMasterRef, &clusterMaster) && !isUpgradingTogether(cr, &clusterMaster) {
+			err = enterprise.ValidateVersionSkew(instanceType, image, enterprise.SplunkClusterMaster, getLinkedImage(&clusterMaster, clusterMaster.Spec.Image))
+		}
+	} else if instanceType != enterprise.SplunkIndexer && instanceType != enterprise.SplunkLicenseMaster && spec.IndexerClusterRef.Name != "" {
+		var indexerCluster enterprisev1.IndexerCluster
+		if getReferencedResource(client, cr, spec.IndexerClusterRef, &indexerCluster) && !isUpgradingTogether(cr, &indexerCluster) {
+			indexerImage := getLinkedImage(&indexerCluster, indexerCluster.Spec.Image)
+			err = enterprise.ValidateVersionSkew(instanceType, image, enterprise.SplunkIndexer, indexerImage)
+
+			// indexer clusters run their own cluster master using the same image, unless they reference a ClusterMaster
+			if err == nil && indexerCluster.Spec.ClusterMasterRef.Name == "" {
+				err = enterprise.ValidateVersionSkew(instanceType, image, enterprise.SplunkClusterMaster, indexerImage)
+			}
+		}
+	}
+
+	return err
+}
+
+// isUpgradingTogether returns true if a custom resource and a linked resource are being upgraded to the same image using
+// their splunk-image annotations
+func isUpgradingTogether(cr enterprisev1.MetaObject, linked enterprisev1.MetaObject) bool {
+	image := enterprise.GetRequestedImage(cr.GetObjectMeta())
+	return image != "" && image == enterprise.GetRequestedImage(linked.GetObjectMeta())
+}
+
+// getLinkedImage returns the image used by a linked resource, which is the one requested using its splunk-image annotation
+// once it has been upgraded, or the one in its spec
+func getLinkedImage(linked enterprisev1.MetaObject, specImage string) string {
+	if image := enterprise.GetRequestedImage(linked.GetObjectMeta()); image != "" && isUpgraded(linked, image) {
+		return image
+	}
+	return enterprise.GetSplunkImage(specImage)
+}
+
+// getReferencedResource retrieves a custom resource referenced by another one, and returns false if it cannot be retrieved
+func getReferencedResource(client ControllerClient, cr enterprisev1.MetaObject, ref corev1.ObjectReference, obj runtime.Object) bool {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+	err := client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: ref.Name}, obj)
+	if err != nil {
+		log.WithName("getReferencedResource").Info("Unable to get referenced resource", "name", ref.Name, "namespace", namespace, "error", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconcile
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+)
+
+func TestValidateReferencedVersions(t *testing.T) {
+	licenseMaster := enterprisev1.LicenseMaster{
+		ObjectMeta: metav1.ObjectMeta{Name: "lm", Namespace: "test"},
+	}
+	licenseMaster.Spec.Image = "splunk/splunk:8.0.5"
+	indexerCluster := enterprisev1.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+	}
+	indexerCluster.Spec.Image = "splunk/splunk:8.0.5"
+	c := newMockClient()
+	c.state[getStateKey(&licenseMaster)] = &licenseMaster
+	c.state[getStateKey(&indexerCluster)] = &indexerCluster
+
+	test := func(cr enterprisev1.MetaObject, spec *enterprisev1.CommonSplunkSpec, instanceType enterprise.InstanceType, wantErr bool) {
+		err := ValidateReferencedVersions(c, cr, spec, instanceType)
+		if wantErr && err == nil {
+			t.Errorf("ValidateReferencedVersions(%s, %s) returned nil; want error", instanceType, spec.Image)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateReferencedVersions(%s, %s) returned %v; want nil", instanceType, spec.Image, err)
+		}
+	}
+
+	// indexers may not run a later version than their license master
+	idxc := enterprisev1.IndexerCluster{ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"}}
+	idxc.Spec.LicenseMasterRef.Name = "lm"
+	idxc.Spec.Image = "splunk/splunk:8.0.5"
+	test(&idxc, &idxc.Spec.CommonSplunkSpec, enterprise.SplunkIndexer, false)
+	idxc.Spec.Image = "splunk/splunk:8.1.0"
+	test(&idxc, &idxc.Spec.CommonSplunkSpec, enterprise.SplunkIndexer, true)
+
+	// search heads must run the same version as indexer clusters with their own cluster master
+	shc := enterprisev1.SearchHeadCluster{ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"}}
+	shc.Spec.IndexerClusterRef.Name = "idxc"
+	shc.Spec.Image = "splunk/splunk:8.0.5"
+	test(&shc, &shc.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead, false)
+	shc.Spec.Image = "splunk/splunk:7.3.6"
+	test(&shc, &shc.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead, true)
+	shc.Spec.Image = "splunk/splunk:8.1.0"
+	test(&shc, &shc.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead, true)
+
+	// search heads may run a later version than indexer cluster peers that reference a ClusterMaster
+	indexerCluster.Spec.ClusterMasterRef.Name = "cm"
+	test(&shc, &shc.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead, false)
+
+	// referenced resources that do not exist are not checked
+	shc.Spec.IndexerClusterRef.Name = "missing"
+	shc.Spec.LicenseMasterRef.Name = "missing"
+	test(&shc, &shc.Spec.CommonSplunkSpec, enterprise.SplunkSearchHead, false)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// MaxConcurrentReconcilesEnv is the operator environment variable used to set the maximum number of reconciles that
+	// may run at the same time for each controller. It may be overridden for a controller using a suffix with its name,
+	// such as MAX_CONCURRENT_RECONCILES_INDEXERCLUSTER.
+	MaxConcurrentReconcilesEnv = "MAX_CONCURRENT_RECONCILES"
+
+	// ClientQPSEnv is the operator environment variable used to set the maximum number of Kubernetes API requests
+	// per second made by each controller. It may be overridden for a controller in the same way as MaxConcurrentReconcilesEnv.
+	ClientQPSEnv = "CLIENT_QPS"
+
+	// ClientBurstEnv is the operator environment variable used to set the maximum burst of Kubernetes API requests
+	// made by each controller. It may be overridden for a controller in the same way as MaxConcurrentReconcilesEnv.
+	ClientBurstEnv = "CLIENT_BURST"
+
+	// RequeueIntervalEnv is the operator environment variable used to set the interval between reconciles of custom
+	// resources that are not ready yet, such as "10s". It may be overridden for a controller in the same way as
+	// MaxConcurrentReconcilesEnv.
+	RequeueIntervalEnv = "REQUEUE_INTERVAL"
+
+	// DefaultRequeueInterval is the interval between reconciles of custom resources that are not ready yet, if not configured
+	DefaultRequeueInterval = 5 * time.Second
+
+	// OrphanPolicyEnv is the operator environment variable used to select what is done with resources created by the
+	// operator whose custom resource no longer exists, such as "Report"
+	OrphanPolicyEnv = "ORPHAN_POLICY"
+
+	// OrphanCheckIntervalEnv is the operator environment variable used to set the interval between checks for orphaned
+	// resources, such as "30m"
+	OrphanCheckIntervalEnv = "ORPHAN_CHECK_INTERVAL"
+
+	// DefaultOrphanCheckInterval is the interval between checks for orphaned resources, if not configured
+	DefaultOrphanCheckInterval = time.Hour
+)
+
+// getControllerEnv returns the name and value of an operator environment variable for a controller, using the variable
+// with a suffix for the controller name if it is set, or the variable without a suffix otherwise
+func getControllerEnv(name, controller string) (string, string) {
+	if controller != "" {
+		if value, ok := os.LookupEnv(name + "_" + strings.ToUpper(controller)); ok {
+			return name + "_" + strings.ToUpper(controller), value
+		}
+	}
+	return name, os.Getenv(name)
+}
+
+// GetMaxConcurrentReconciles returns the maximum number of reconciles that may run at the same time for a controller,
+// such as "indexercluster" (defaults to 1)
+func GetMaxConcurrentReconciles(controller string) (int, error) {
+	name, value := getControllerEnv(MaxConcurrentReconcilesEnv, controller)
+	if value == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("Invalid %s; value=\"%s\"", name, value)
+	}
+	return n, nil
+}
+
+// GetRequeueInterval returns the interval between reconciles of custom resources that are not ready yet for a
+// controller, such as "indexercluster" (defaults to DefaultRequeueInterval)
+func GetRequeueInterval(controller string) (time.Duration, error) {
+	name, value := getControllerEnv(RequeueIntervalEnv, controller)
+	if value == "" {
+		return DefaultRequeueInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return DefaultRequeueInterval, fmt.Errorf("Invalid %s; value=\"%s\"", name, value)
+	}
+	return interval, nil
+}
+
+// GetOrphanCheckInterval returns the interval between checks for orphaned resources (defaults to DefaultOrphanCheckInterval)
+func GetOrphanCheckInterval() (time.Duration, error) {
+	value := os.Getenv(OrphanCheckIntervalEnv)
+	if value == "" {
+		return DefaultOrphanCheckInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return DefaultOrphanCheckInterval, fmt.Errorf("Invalid %s; value=\"%s\"", OrphanCheckIntervalEnv, value)
+	}
+	return interval, nil
+}
+
+// GetControllerConfig returns a copy of cfg with the client QPS and burst configured for a controller, such as
+// "indexercluster", or for all controllers if controller is empty. Values from cfg are kept if none are configured.
+func GetControllerConfig(cfg *rest.Config, controller string) (*rest.Config, error) {
+	config := rest.CopyConfig(cfg)
+	if name, value := getControllerEnv(ClientQPSEnv, controller); value != "" {
+		qps, err := strconv.ParseFloat(value, 32)
+		if err != nil || qps <= 0 {
+			return nil, fmt.Errorf("Invalid %s; value=\"%s\"", name, value)
+		}
+		config.QPS = float32(qps)
+	}
+	if name, value := getControllerEnv(ClientBurstEnv, controller); value != "" {
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("Invalid %s; value=\"%s\"", name, value)
+		}
+		config.Burst = burst
+	}
+	return config, nil
+}
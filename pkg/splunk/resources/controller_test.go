@@ -0,0 +1,145 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestGetMaxConcurrentReconciles(t *testing.T) {
+	defer os.Unsetenv(MaxConcurrentReconcilesEnv)
+	defer os.Unsetenv(MaxConcurrentReconcilesEnv + "_INDEXERCLUSTER")
+
+	test := func(controller string, want int, wantErr bool) {
+		got, err := GetMaxConcurrentReconciles(controller)
+		if wantErr && err == nil {
+			t.Errorf("GetMaxConcurrentReconciles(%s) returned nil; want error", controller)
+		} else if !wantErr && err != nil {
+			t.Errorf("GetMaxConcurrentReconciles(%s) returned %v; want nil", controller, err)
+		}
+		if got != want {
+			t.Errorf("GetMaxConcurrentReconciles(%s) = %d; want %d", controller, got, want)
+		}
+	}
+
+	test("indexercluster", 1, false)
+	os.Setenv(MaxConcurrentReconcilesEnv, "4")
+	test("indexercluster", 4, false)
+	test("standalone", 4, false)
+	os.Setenv(MaxConcurrentReconcilesEnv+"_INDEXERCLUSTER", "8")
+	test("indexercluster", 8, false)
+	test("standalone", 4, false)
+	os.Setenv(MaxConcurrentReconcilesEnv+"_INDEXERCLUSTER", "0")
+	test("indexercluster", 0, true)
+	os.Setenv(MaxConcurrentReconcilesEnv, "many")
+	test("standalone", 0, true)
+}
+
+func TestGetRequeueInterval(t *testing.T) {
+	defer os.Unsetenv(RequeueIntervalEnv)
+	defer os.Unsetenv(RequeueIntervalEnv + "_STANDALONE")
+
+	test := func(controller string, want time.Duration, wantErr bool) {
+		got, err := GetRequeueInterval(controller)
+		if wantErr && err == nil {
+			t.Errorf("GetRequeueInterval(%s) returned nil; want error", controller)
+		} else if !wantErr && err != nil {
+			t.Errorf("GetRequeueInterval(%s) returned %v; want nil", controller, err)
+		}
+		if got != want {
+			t.Errorf("GetRequeueInterval(%s) = %s; want %s", controller, got, want)
+		}
+	}
+
+	test("standalone", DefaultRequeueInterval, false)
+	os.Setenv(RequeueIntervalEnv, "30s")
+	test("standalone", 30*time.Second, false)
+	os.Setenv(RequeueIntervalEnv+"_STANDALONE", "1s")
+	test("standalone", time.Second, false)
+	test("indexercluster", 30*time.Second, false)
+	os.Setenv(RequeueIntervalEnv+"_STANDALONE", "0s")
+	test("standalone", DefaultRequeueInterval, true)
+	os.Setenv(RequeueIntervalEnv, "soon")
+	test("indexercluster", DefaultRequeueInterval, true)
+}
+
+func TestGetOrphanCheckInterval(t *testing.T) {
+	defer os.Unsetenv(OrphanCheckIntervalEnv)
+
+	test := func(want time.Duration, wantErr bool) {
+		got, err := GetOrphanCheckInterval()
+		if wantErr && err == nil {
+			t.Errorf("GetOrphanCheckInterval() returned nil; want error")
+		} else if !wantErr && err != nil {
+			t.Errorf("GetOrphanCheckInterval() returned %v; want nil", err)
+		}
+		if got != want {
+			t.Errorf("GetOrphanCheckInterval() = %s; want %s", got, want)
+		}
+	}
+
+	test(DefaultOrphanCheckInterval, false)
+	os.Setenv(OrphanCheckIntervalEnv, "30m")
+	test(30*time.Minute, false)
+	os.Setenv(OrphanCheckIntervalEnv, "-1m")
+	test(DefaultOrphanCheckInterval, true)
+	os.Setenv(OrphanCheckIntervalEnv, "hourly")
+	test(DefaultOrphanCheckInterval, true)
+}
+
+func TestGetControllerConfig(t *testing.T) {
+	defer os.Unsetenv(ClientQPSEnv)
+	defer os.Unsetenv(ClientBurstEnv)
+	defer os.Unsetenv(ClientBurstEnv + "_SEARCHHEADCLUSTER")
+
+	cfg := &rest.Config{Host: "https://kubernetes.default.svc", QPS: 5, Burst: 10}
+	test := func(controller string, wantQPS float32, wantBurst int, wantErr bool) {
+		got, err := GetControllerConfig(cfg, controller)
+		if wantErr {
+			if err == nil {
+				t.Errorf("GetControllerConfig(%s) returned nil; want error", controller)
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("GetControllerConfig(%s) returned %v; want nil", controller, err)
+			return
+		}
+		if got.QPS != wantQPS || got.Burst != wantBurst {
+			t.Errorf("GetControllerConfig(%s) QPS, Burst = %f, %d; want %f, %d", controller, got.QPS, got.Burst, wantQPS, wantBurst)
+		}
+		if got.Host != cfg.Host {
+			t.Errorf("GetControllerConfig(%s) host = %s; want %s", controller, got.Host, cfg.Host)
+		}
+	}
+
+	test("searchheadcluster", 5, 10, false)
+	os.Setenv(ClientQPSEnv, "50")
+	os.Setenv(ClientBurstEnv, "100")
+	test("", 50, 100, false)
+	test("searchheadcluster", 50, 100, false)
+	os.Setenv(ClientBurstEnv+"_SEARCHHEADCLUSTER", "200")
+	test("searchheadcluster", 50, 200, false)
+	test("standalone", 50, 100, false)
+	if cfg.QPS != 5 || cfg.Burst != 10 {
+		t.Errorf("GetControllerConfig() modified cfg QPS, Burst = %f, %d; want %f, %d", cfg.QPS, cfg.Burst, 5.0, 10)
+	}
+	os.Setenv(ClientQPSEnv, "-1")
+	test("standalone", 0, 0, true)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// OpenShiftCompatibilityEnv is the operator environment variable used to enable OpenShift compatibility ("true" or "false").
+	// If not set, it is enabled when the cluster supports SecurityContextConstraints.
+	OpenShiftCompatibilityEnv = "OPENSHIFT_COMPATIBILITY"
+
+	// OpenShiftServiceAccountName is the name of the ServiceAccount used by pods when OpenShift compatibility is enabled
+	OpenShiftServiceAccountName = "splunk-anyuid"
+
+	// ClusterRole that allows use of the anyuid SecurityContextConstraints, which is needed to run pods as user 41812
+	openShiftAnyUIDClusterRole = "system:openshift:scc:anyuid"
+)
+
+// IsOpenShiftCompatibilityEnabled returns true if pods should be configured to run on OpenShift
+func IsOpenShiftCompatibilityEnabled() bool {
+	return os.Getenv(OpenShiftCompatibilityEnv) == "true"
+}
+
+// GetOpenShiftServiceAccount returns a Kubernetes ServiceAccount used by pods when OpenShift compatibility is enabled.
+func GetOpenShiftServiceAccount(namespace string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ServiceAccount",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OpenShiftServiceAccountName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "splunk-operator"},
+		},
+	}
+}
+
+// GetOpenShiftRoleBinding returns a Kubernetes RoleBinding that allows pods using the OpenShift ServiceAccount to run with
+// the anyuid SecurityContextConstraints, so that they may use the user and group IDs given in their security context.
+func GetOpenShiftRoleBinding(namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: "rbac.authorization.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OpenShiftServiceAccountName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "splunk-operator"},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     openShiftAnyUIDClusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      OpenShiftServiceAccountName,
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+// SetOpenShiftServiceAccount configures a pod template to use the OpenShift ServiceAccount, if OpenShift compatibility is enabled
+// and the pod template does not already use another ServiceAccount.
+func SetOpenShiftServiceAccount(podTemplateSpec *corev1.PodTemplateSpec) {
+	if IsOpenShiftCompatibilityEnabled() && podTemplateSpec.Spec.ServiceAccountName == "" {
+		podTemplateSpec.Spec.ServiceAccountName = OpenShiftServiceAccountName
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetOpenShiftRoleBinding(t *testing.T) {
+	got, _ := json.Marshal(GetOpenShiftRoleBinding("test"))
+	want := `{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"splunk-anyuid","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/managed-by":"splunk-operator"}},"subjects":[{"kind":"ServiceAccount","name":"splunk-anyuid","namespace":"test"}],"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"ClusterRole","name":"system:openshift:scc:anyuid"}}`
+	if string(got) != want {
+		t.Errorf("GetOpenShiftRoleBinding() = %s; want %s", got, want)
+	}
+}
+
+func TestSetOpenShiftServiceAccount(t *testing.T) {
+	defer os.Unsetenv(OpenShiftCompatibilityEnv)
+
+	podTemplateSpec := corev1.PodTemplateSpec{}
+	SetOpenShiftServiceAccount(&podTemplateSpec)
+	if podTemplateSpec.Spec.ServiceAccountName != "" {
+		t.Errorf("SetOpenShiftServiceAccount() serviceAccountName = %s; want empty when not enabled", podTemplateSpec.Spec.ServiceAccountName)
+	}
+
+	os.Setenv(OpenShiftCompatibilityEnv, "true")
+	SetOpenShiftServiceAccount(&podTemplateSpec)
+	if podTemplateSpec.Spec.ServiceAccountName != OpenShiftServiceAccountName {
+		t.Errorf("SetOpenShiftServiceAccount() serviceAccountName = %s; want %s", podTemplateSpec.Spec.ServiceAccountName, OpenShiftServiceAccountName)
+	}
+
+	// other ServiceAccounts are kept
+	podTemplateSpec.Spec.ServiceAccountName = "splunk-irsa"
+	SetOpenShiftServiceAccount(&podTemplateSpec)
+	if podTemplateSpec.Spec.ServiceAccountName != "splunk-irsa" {
+		t.Errorf("SetOpenShiftServiceAccount() serviceAccountName = %s; want %s", podTemplateSpec.Spec.ServiceAccountName, "splunk-irsa")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ResourceChangedPredicate returns a predicate that ignores update events sent when watches are resynced, for which
+// the resource has not changed
+func ResourceChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaOld.GetResourceVersion() != e.MetaNew.GetResourceVersion()
+		},
+	}
+}
+
+// SpecChangedPredicate returns a predicate that ignores update events for custom resources unless their spec
+// (generation), labels, annotations, finalizers or deletion timestamp change, so that updates to their status do not
+// cause them to be reconciled again
+func SpecChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() || isMetaChanged(e.MetaOld, e.MetaNew)
+		},
+	}
+}
+
+// WorkloadChangedPredicate returns a predicate that ignores update events for StatefulSets, Deployments and DaemonSets
+// unless their spec (generation), deletion timestamp or the number of replicas reported in their status change
+func WorkloadChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() || !isDeletionTimestampEqual(e.MetaOld, e.MetaNew) {
+				return true
+			}
+			return !reflect.DeepEqual(getWorkloadStatus(e.ObjectOld), getWorkloadStatus(e.ObjectNew))
+		},
+	}
+}
+
+// DataChangedPredicate returns a predicate that ignores update events for Secrets and ConfigMaps unless their data
+// or deletion timestamp change
+func DataChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if !isDeletionTimestampEqual(e.MetaOld, e.MetaNew) {
+				return true
+			}
+			switch old := e.ObjectOld.(type) {
+			case *corev1.Secret:
+				new, ok := e.ObjectNew.(*corev1.Secret)
+				return !ok || !reflect.DeepEqual(old.Data, new.Data) || !reflect.DeepEqual(old.StringData, new.StringData)
+			case *corev1.ConfigMap:
+				new, ok := e.ObjectNew.(*corev1.ConfigMap)
+				return !ok || !reflect.DeepEqual(old.Data, new.Data) || !reflect.DeepEqual(old.BinaryData, new.BinaryData)
+			}
+			return e.MetaOld.GetResourceVersion() != e.MetaNew.GetResourceVersion()
+		},
+	}
+}
+
+// isMetaChanged returns true if the labels, annotations, finalizers or deletion timestamp of an object have changed
+func isMetaChanged(old, new metav1.Object) bool {
+	return !reflect.DeepEqual(old.GetLabels(), new.GetLabels()) ||
+		!reflect.DeepEqual(old.GetAnnotations(), new.GetAnnotations()) ||
+		!reflect.DeepEqual(old.GetFinalizers(), new.GetFinalizers()) ||
+		!isDeletionTimestampEqual(old, new)
+}
+
+// isDeletionTimestampEqual returns true if two objects have the same deletion timestamp, or neither has one
+func isDeletionTimestampEqual(old, new metav1.Object) bool {
+	a, b := old.GetDeletionTimestamp(), new.GetDeletionTimestamp()
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b)
+}
+
+// getWorkloadStatus returns the status of a StatefulSet, Deployment or DaemonSet without its conditions, which are
+// not used by the operator, or the object itself for other types
+func getWorkloadStatus(obj runtime.Object) interface{} {
+	switch workload := obj.(type) {
+	case *appsv1.StatefulSet:
+		status := workload.Status
+		status.Conditions = nil
+		return status
+	case *appsv1.Deployment:
+		status := workload.Status
+		status.Conditions = nil
+		return status
+	case *appsv1.DaemonSet:
+		status := workload.Status
+		status.Conditions = nil
+		return status
+	}
+	return obj
+}
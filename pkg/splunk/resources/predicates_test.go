@@ -0,0 +1,113 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func testPredicateUpdate(t *testing.T, name string, p predicate.Predicate, old, new runtime.Object, want bool) {
+	e := event.UpdateEvent{
+		MetaOld:   old.(metav1.Object),
+		ObjectOld: old,
+		MetaNew:   new.(metav1.Object),
+		ObjectNew: new,
+	}
+	if got := p.Update(e); got != want {
+		t.Errorf("%s Update() = %t; want %t", name, got, want)
+	}
+}
+
+func TestResourceChangedPredicate(t *testing.T) {
+	old := corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1"}}
+	new := old
+	testPredicateUpdate(t, "ResourceChangedPredicate(resync)", ResourceChangedPredicate(), &old, &new, false)
+	new.ResourceVersion = "2"
+	testPredicateUpdate(t, "ResourceChangedPredicate(changed)", ResourceChangedPredicate(), &old, &new, true)
+}
+
+func TestSpecChangedPredicate(t *testing.T) {
+	old := corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1", Generation: 1}}
+	test := func(name string, update func(*corev1.Service), want bool) {
+		new := *old.DeepCopy()
+		new.ResourceVersion = "2"
+		update(&new)
+		testPredicateUpdate(t, "SpecChangedPredicate("+name+")", SpecChangedPredicate(), &old, &new, want)
+	}
+
+	test("status", func(s *corev1.Service) {
+		s.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	}, false)
+	test("generation", func(s *corev1.Service) { s.Generation = 2 }, true)
+	test("labels", func(s *corev1.Service) { s.Labels = map[string]string{"app": "splunk"} }, true)
+	test("annotations", func(s *corev1.Service) { s.Annotations = map[string]string{"splunk-image": "splunk/splunk:8.0"} }, true)
+	test("finalizers", func(s *corev1.Service) { s.Finalizers = []string{"enterprise.splunk.com/delete-pvc"} }, true)
+	test("deletion", func(s *corev1.Service) { now := metav1.Now(); s.DeletionTimestamp = &now }, true)
+}
+
+func TestWorkloadChangedPredicate(t *testing.T) {
+	old := appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1", Generation: 1}}
+	test := func(name string, update func(*appsv1.StatefulSet), want bool) {
+		new := *old.DeepCopy()
+		new.ResourceVersion = "2"
+		update(&new)
+		testPredicateUpdate(t, "WorkloadChangedPredicate("+name+")", WorkloadChangedPredicate(), &old, &new, want)
+	}
+
+	test("resync", func(s *appsv1.StatefulSet) {}, false)
+	test("conditions", func(s *appsv1.StatefulSet) {
+		s.Status.Conditions = []appsv1.StatefulSetCondition{{Type: "Test", Status: corev1.ConditionTrue}}
+	}, false)
+	test("labels", func(s *appsv1.StatefulSet) { s.Labels = map[string]string{"app": "splunk"} }, false)
+	test("generation", func(s *appsv1.StatefulSet) { s.Generation = 2 }, true)
+	test("readyReplicas", func(s *appsv1.StatefulSet) { s.Status.ReadyReplicas = 1 }, true)
+	test("updateRevision", func(s *appsv1.StatefulSet) { s.Status.UpdateRevision = "test-1234" }, true)
+	test("deletion", func(s *appsv1.StatefulSet) { now := metav1.Now(); s.DeletionTimestamp = &now }, true)
+
+	oldDeployment := appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1"}}
+	newDeployment := *oldDeployment.DeepCopy()
+	newDeployment.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}}
+	testPredicateUpdate(t, "WorkloadChangedPredicate(deployment-conditions)", WorkloadChangedPredicate(), &oldDeployment, &newDeployment, false)
+	newDeployment.Status.AvailableReplicas = 1
+	testPredicateUpdate(t, "WorkloadChangedPredicate(deployment-replicas)", WorkloadChangedPredicate(), &oldDeployment, &newDeployment, true)
+}
+
+func TestDataChangedPredicate(t *testing.T) {
+	oldSecret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1"}, Data: map[string][]byte{"hec_token": []byte("1234")}}
+	newSecret := *oldSecret.DeepCopy()
+	newSecret.ResourceVersion = "2"
+	newSecret.Labels = map[string]string{"app": "splunk"}
+	testPredicateUpdate(t, "DataChangedPredicate(secret-labels)", DataChangedPredicate(), &oldSecret, &newSecret, false)
+	newSecret.Data["hec_token"] = []byte("5678")
+	testPredicateUpdate(t, "DataChangedPredicate(secret-data)", DataChangedPredicate(), &oldSecret, &newSecret, true)
+
+	oldConfigMap := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "1"}, Data: map[string]string{"default.yml": "test"}}
+	newConfigMap := *oldConfigMap.DeepCopy()
+	newConfigMap.ResourceVersion = "2"
+	testPredicateUpdate(t, "DataChangedPredicate(configmap-resync)", DataChangedPredicate(), &oldConfigMap, &newConfigMap, false)
+	newConfigMap.BinaryData = map[string][]byte{"license.lic": []byte("test")}
+	testPredicateUpdate(t, "DataChangedPredicate(configmap-binarydata)", DataChangedPredicate(), &oldConfigMap, &newConfigMap, true)
+	newConfigMap = *oldConfigMap.DeepCopy()
+	now := metav1.Now()
+	newConfigMap.DeletionTimestamp = &now
+	testPredicateUpdate(t, "DataChangedPredicate(configmap-deletion)", DataChangedPredicate(), &oldConfigMap, &newConfigMap, true)
+}
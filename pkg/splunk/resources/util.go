@@ -29,7 +29,39 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+const (
+	// user and group used to run Splunk Enterprise and Spark containers
+	defaultPodUserID = int64(41812)
+
+	// pod annotation used to select a seccomp profile
+	seccompPodAnnotation = "seccomp.security.alpha.kubernetes.io/pod"
+
+	// BundlePushAnnotation is used to request a configuration bundle push for a custom resource. It is not copied to the
+	// resources it owns, so that changing it does not recycle pods.
+	BundlePushAnnotation = "enterprise.splunk.com/bundle-push"
+
+	// DryRunAnnotation is used to request dry runs of reconciles for a custom resource. It is not copied to the resources
+	// it owns, so that starting or stopping dry runs does not recycle pods.
+	DryRunAnnotation = "enterprise.splunk.com/dry-run"
+
+	// PausedAnnotation is used to suspend reconciles of a custom resource. It is not copied to the resources it owns,
+	// so that pausing or resuming reconciles does not recycle pods.
+	PausedAnnotation = "enterprise.splunk.com/paused"
+
+	// DeletionProtectionAnnotation is used to block the deletion of a custom resource. It is not copied to the resources
+	// it owns, so that enabling or disabling protection does not recycle pods.
+	DeletionProtectionAnnotation = "enterprise.splunk.com/deletion-protection"
+
+	// DriftPolicyAnnotation is used to choose how the operator handles changes made by others to the resources that a
+	// custom resource owns. It is not copied to the resources it owns, so that changing the policy does not recycle pods.
+	DriftPolicyAnnotation = "enterprise.splunk.com/drift-policy"
+
+	// AdoptAnnotation is used to allow a custom resource to take ownership of existing resources that it did not create.
+	// It is not copied to the resources it owns, so that adding or removing it does not recycle pods.
+	AdoptAnnotation = "enterprise.splunk.com/adopt"
 )
 
 func init() {
@@ -59,13 +91,23 @@ func AppendParentMeta(child, parent metav1.Object) {
 
 	// append annotations from parent
 	for k, v := range parent.GetAnnotations() {
-		// ignore Annotations set by kubectl
-		if !strings.HasPrefix(k, "kubectl.kubernetes.io/") {
+		// ignore Annotations set by kubectl, and requests for bundle pushes, dry runs, pauses, deletion protection, drift policies or adoption
+		if !strings.HasPrefix(k, "kubectl.kubernetes.io/") && !isOperatorRequestAnnotation(k) {
 			child.GetAnnotations()[k] = v
 		}
 	}
 }
 
+// isOperatorRequestAnnotation returns true if an annotation is only used to request actions from the operator for the
+// custom resource it is set on
+func isOperatorRequestAnnotation(key string) bool {
+	switch key {
+	case BundlePushAnnotation, DryRunAnnotation, PausedAnnotation, DeletionProtectionAnnotation, DriftPolicyAnnotation, AdoptAnnotation:
+		return true
+	}
+	return false
+}
+
 // ParseResourceQuantity parses and returns a resource quantity from a string.
 func ParseResourceQuantity(str string, useIfEmpty string) (resource.Quantity, error) {
 	var result resource.Quantity
@@ -190,7 +232,10 @@ func CompareEnvs(a []corev1.EnvVar, b []corev1.EnvVar) bool {
 
 	// iterate elements, checking for differences
 	for n := range aSorted {
-		if aSorted[n] != bSorted[n] {
+		if aSorted[n].Name != bSorted[n].Name || aSorted[n].Value != bSorted[n].Value {
+			return true
+		}
+		if CompareByMarshall(aSorted[n].ValueFrom, bSorted[n].ValueFrom) {
 			return true
 		}
 	}
@@ -259,6 +304,36 @@ func CompareVolumeMounts(a []corev1.VolumeMount, b []corev1.VolumeMount) bool {
 	return false
 }
 
+// getProbeWithDefaults returns a copy of a Kubernetes Probe, with the default values that Kubernetes uses for any fields that are not set
+func getProbeWithDefaults(probe *corev1.Probe) *corev1.Probe {
+	result := probe.DeepCopy()
+	if result.TimeoutSeconds == 0 {
+		result.TimeoutSeconds = 1
+	}
+	if result.PeriodSeconds == 0 {
+		result.PeriodSeconds = 10
+	}
+	if result.SuccessThreshold == 0 {
+		result.SuccessThreshold = 1
+	}
+	if result.FailureThreshold == 0 {
+		result.FailureThreshold = 3
+	}
+	if result.HTTPGet != nil && result.HTTPGet.Scheme == "" {
+		result.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+	return result
+}
+
+// CompareProbes is a generic comparer of two Kubernetes Probes, which treats fields that are not set as having their default values.
+// It returns true if there are material differences between them, or false otherwise.
+func CompareProbes(a *corev1.Probe, b *corev1.Probe) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+	return CompareByMarshall(getProbeWithDefaults(a), getProbeWithDefaults(b))
+}
+
 // CompareByMarshall compares two Kubernetes objects by marshalling them to JSON.
 // It returns true if there are differences between the two marshalled values, or false otherwise.
 func CompareByMarshall(a interface{}, b interface{}) bool {
@@ -342,6 +417,15 @@ func GetLabels(component, name, identifier string) map[string]string {
 	}
 }
 
+// CopyLabels returns a copy of a map of labels, which is never nil.
+func CopyLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
 // AppendPodAntiAffinity appends a Kubernetes Affinity object to include anti-affinity for pods of the same type, and returns the result.
 func AppendPodAntiAffinity(affinity *corev1.Affinity, identifier string, typeLabel string) *corev1.Affinity {
 	if affinity == nil {
@@ -354,24 +438,13 @@ func AppendPodAntiAffinity(affinity *corev1.Affinity, identifier string, typeLab
 		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
 	}
 
-	// prepare match expressions to match select labels
-	matchExpressions := []metav1.LabelSelectorRequirement{
-		{
-			Key:      "app.kubernetes.io/instance",
-			Operator: metav1.LabelSelectorOpIn,
-			Values:   []string{fmt.Sprintf("splunk-%s-%s", identifier, typeLabel)},
-		},
-	}
-
 	affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
 		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
 		corev1.WeightedPodAffinityTerm{
 			Weight: 100,
 			PodAffinityTerm: corev1.PodAffinityTerm{
-				LabelSelector: &metav1.LabelSelector{
-					MatchExpressions: matchExpressions,
-				},
-				TopologyKey: "kubernetes.io/hostname",
+				LabelSelector: getPodTypeLabelSelector(identifier, typeLabel),
+				TopologyKey:   "kubernetes.io/hostname",
 			},
 		},
 	)
@@ -379,6 +452,73 @@ func AppendPodAntiAffinity(affinity *corev1.Affinity, identifier string, typeLab
 	return affinity
 }
 
+// AppendRequiredPodAntiAffinity appends a Kubernetes Affinity object to require anti-affinity for pods of the same type, and returns the result.
+func AppendRequiredPodAntiAffinity(affinity *corev1.Affinity, identifier string, typeLabel string) *corev1.Affinity {
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	} else {
+		affinity = affinity.DeepCopy()
+	}
+
+	if affinity.PodAntiAffinity == nil {
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+	}
+
+	affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+		affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+		corev1.PodAffinityTerm{
+			LabelSelector: getPodTypeLabelSelector(identifier, typeLabel),
+			TopologyKey:   "kubernetes.io/hostname",
+		},
+	)
+
+	return affinity
+}
+
+// GetPodAffinity returns the Kubernetes Affinity to use for pods of a given type. This adds anti-affinity for pods of the same
+// type to the affinity rules of a CommonSpec, as selected by its PodAntiAffinity preset.
+func GetPodAffinity(spec *enterprisev1.CommonSpec, identifier string, typeLabel string) *corev1.Affinity {
+	switch spec.PodAntiAffinity {
+	case "none":
+		return spec.Affinity.DeepCopy()
+	case "hard":
+		return AppendRequiredPodAntiAffinity(&spec.Affinity, identifier, typeLabel)
+	default:
+		return AppendPodAntiAffinity(&spec.Affinity, identifier, typeLabel)
+	}
+}
+
+// GetTopologySpreadConstraints returns the Kubernetes TopologySpreadConstraints to use for pods of a given type. This adds a
+// constraint to spread pods of the same type across zones to those of a CommonSpec, if SpreadAcrossZones is true.
+func GetTopologySpreadConstraints(spec *enterprisev1.CommonSpec, identifier string, typeLabel string) []corev1.TopologySpreadConstraint {
+	var constraints []corev1.TopologySpreadConstraint
+	for idx := range spec.TopologySpreadConstraints {
+		constraints = append(constraints, *spec.TopologySpreadConstraints[idx].DeepCopy())
+	}
+	if spec.SpreadAcrossZones {
+		constraints = append(constraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     getPodTypeLabelSelector(identifier, typeLabel),
+		})
+	}
+	return constraints
+}
+
+// getPodTypeLabelSelector returns a Kubernetes LabelSelector that matches pods of the same type
+func getPodTypeLabelSelector(identifier string, typeLabel string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      "app.kubernetes.io/instance",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{fmt.Sprintf("splunk-%s-%s", identifier, typeLabel)},
+			},
+		},
+	}
+}
+
 // ValidateImagePullPolicy checks validity of the ImagePullPolicy spec parameter, and returns error if it is invalid.
 func ValidateImagePullPolicy(imagePullPolicy *string) error {
 	// ImagePullPolicy
@@ -399,7 +539,20 @@ func ValidateImagePullPolicy(imagePullPolicy *string) error {
 	return nil
 }
 
-// ValidateResources checks resource requests and limits and sets defaults if not provided
+// ValidatePodAntiAffinity checks validity of the PodAntiAffinity spec parameter, and returns error if it is invalid.
+func ValidatePodAntiAffinity(podAntiAffinity *string) error {
+	switch *podAntiAffinity {
+	case "":
+		*podAntiAffinity = "soft"
+	case "soft", "hard", "none":
+	default:
+		return fmt.Errorf("PodAntiAffinity must be one of \"soft\", \"hard\" or \"none\"; value=\"%s\"", *podAntiAffinity)
+	}
+	return nil
+}
+
+// ValidateResources checks resource requests and limits and sets defaults if not provided. Defaults are adjusted when
+// needed so that requests are never greater than limits, since Kubernetes would reject them.
 func ValidateResources(resources *corev1.ResourceRequirements, defaults corev1.ResourceRequirements) {
 	// check for nil maps
 	if resources.Requests == nil {
@@ -409,29 +562,81 @@ func ValidateResources(resources *corev1.ResourceRequirements, defaults corev1.R
 		resources.Limits = make(corev1.ResourceList)
 	}
 
-	// if not given, use default cpu requests
-	_, ok := resources.Requests[corev1.ResourceCPU]
-	if !ok {
-		resources.Requests[corev1.ResourceCPU] = defaults.Requests[corev1.ResourceCPU]
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		request, hasRequest := resources.Requests[name]
+		limit, hasLimit := resources.Limits[name]
+
+		// if not given, use default requests (but not more than the limits given)
+		if !hasRequest {
+			request = defaults.Requests[name]
+			if hasLimit && request.Cmp(limit) > 0 {
+				request = limit
+			}
+			resources.Requests[name] = request
+		}
+
+		// if not given, use default limits (but not less than the requests given)
+		if !hasLimit {
+			limit = defaults.Limits[name]
+			if request.Cmp(limit) > 0 {
+				limit = request
+			}
+			resources.Limits[name] = limit
+		}
 	}
+}
 
-	// if not given, use default memory requests
-	_, ok = resources.Requests[corev1.ResourceMemory]
-	if !ok {
-		resources.Requests[corev1.ResourceMemory] = defaults.Requests[corev1.ResourceMemory]
+// ValidateSecurityContext checks validity of the pod and container security context spec parameters, and returns error if they are invalid.
+func ValidateSecurityContext(spec *enterprisev1.CommonSpec) error {
+	switch {
+	case spec.SeccompProfile == "", spec.SeccompProfile == "runtime/default", spec.SeccompProfile == "docker/default", spec.SeccompProfile == "unconfined":
+	case strings.HasPrefix(spec.SeccompProfile, "localhost/"):
+	default:
+		return fmt.Errorf("SeccompProfile must be one of \"runtime/default\", \"docker/default\", \"unconfined\" or \"localhost/<path>\"; value=\"%s\"", spec.SeccompProfile)
+	}
+
+	// containers that must run as non-root cannot be started as root
+	runAsNonRoot := spec.PodSecurityContext.RunAsNonRoot
+	runAsUser := spec.PodSecurityContext.RunAsUser
+	if spec.ContainerSecurityContext != nil {
+		if spec.ContainerSecurityContext.RunAsNonRoot != nil {
+			runAsNonRoot = spec.ContainerSecurityContext.RunAsNonRoot
+		}
+		if spec.ContainerSecurityContext.RunAsUser != nil {
+			runAsUser = spec.ContainerSecurityContext.RunAsUser
+		}
 	}
+	if runAsNonRoot != nil && *runAsNonRoot && runAsUser != nil && *runAsUser == 0 {
+		return fmt.Errorf("SecurityContext runAsNonRoot cannot be used with runAsUser 0")
+	}
+
+	return nil
+}
 
-	// if not given, use default cpu limits
-	_, ok = resources.Limits[corev1.ResourceCPU]
-	if !ok {
-		resources.Limits[corev1.ResourceCPU] = defaults.Limits[corev1.ResourceCPU]
+// GetPodSecurityContext returns the security context used for pods, based on the podSecurityContext spec parameter.
+// If not given, runAsUser and fsGroup default to the user that runs Splunk Enterprise and Spark containers.
+func GetPodSecurityContext(spec *enterprisev1.CommonSpec) *corev1.PodSecurityContext {
+	securityContext := spec.PodSecurityContext.DeepCopy()
+	if securityContext.RunAsUser == nil {
+		runAsUser := defaultPodUserID
+		securityContext.RunAsUser = &runAsUser
+	}
+	if securityContext.FSGroup == nil {
+		fsGroup := defaultPodUserID
+		securityContext.FSGroup = &fsGroup
 	}
+	return securityContext
+}
 
-	// if not given, use default memory limits
-	_, ok = resources.Limits[corev1.ResourceMemory]
-	if !ok {
-		resources.Limits[corev1.ResourceMemory] = defaults.Limits[corev1.ResourceMemory]
+// SetSeccompProfile annotates a pod template with the seccomp profile used by its pods, if one is given.
+func SetSeccompProfile(podTemplateSpec *corev1.PodTemplateSpec, profile string) {
+	if profile == "" {
+		return
 	}
+	if podTemplateSpec.ObjectMeta.Annotations == nil {
+		podTemplateSpec.ObjectMeta.Annotations = make(map[string]string)
+	}
+	podTemplateSpec.ObjectMeta.Annotations[seccompPodAnnotation] = profile
 }
 
 // ValidateCommonSpec checks validity and makes default updates to a CommonSpec, and returns error if something is wrong.
@@ -444,5 +649,13 @@ func ValidateCommonSpec(spec *enterprisev1.CommonSpec, defaultResources corev1.R
 	// if not provided, set default resource requests and limits
 	ValidateResources(&spec.Resources, defaultResources)
 
+	if err := ValidatePodAntiAffinity(&spec.PodAntiAffinity); err != nil {
+		return err
+	}
+
+	if err := ValidateSecurityContext(spec); err != nil {
+		return err
+	}
+
 	return ValidateImagePullPolicy(&spec.ImagePullPolicy)
 }
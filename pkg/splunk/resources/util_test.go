@@ -25,8 +25,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 )
 
 func TestAsOwner(t *testing.T) {
@@ -68,6 +69,12 @@ func TestAppendParentMeta(t *testing.T) {
 			Annotations: map[string]string{
 				"one": "two",
 				"kubectl.kubernetes.io/last-applied-configuration": "foobar",
+				BundlePushAnnotation:         "2020-06-01T10:00:00Z",
+				DryRunAnnotation:             "true",
+				PausedAnnotation:             "true",
+				DeletionProtectionAnnotation: "true",
+				DriftPolicyAnnotation:        "warn",
+				AdoptAnnotation:              "true",
 			},
 		},
 	}
@@ -344,6 +351,25 @@ func TestCompareEnvs(t *testing.T) {
 	a = []corev1.EnvVar{aEnv, cEnv}
 	b = []corev1.EnvVar{cEnv}
 	test(true)
+
+	// variables using valueFrom are compared by value, not by pointer
+	proxyEnv := func(key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: "HTTPS_PROXY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "proxy"},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	a = []corev1.EnvVar{aEnv, proxyEnv("url")}
+	b = []corev1.EnvVar{proxyEnv("url"), aEnv}
+	test(false)
+
+	b = []corev1.EnvVar{proxyEnv("https_url"), aEnv}
+	test(true)
 }
 
 func TestCompareVolumeMounts(t *testing.T) {
@@ -429,6 +455,47 @@ func TestCompareByMarshall(t *testing.T) {
 	test(true)
 }
 
+func TestCompareProbes(t *testing.T) {
+	var a *corev1.Probe
+	var b *corev1.Probe
+
+	test := func(want bool) {
+		f := func() bool {
+			return CompareProbes(a, b)
+		}
+		compareTester(t, "CompareProbes", f, a, b, want)
+	}
+
+	test(false)
+
+	a = &corev1.Probe{
+		Handler:             corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(8000)}},
+		InitialDelaySeconds: 30,
+	}
+	test(true)
+
+	// fields that are not set are treated as having the Kubernetes defaults
+	b = a.DeepCopy()
+	b.TimeoutSeconds = 1
+	b.PeriodSeconds = 10
+	b.SuccessThreshold = 1
+	b.FailureThreshold = 3
+	b.HTTPGet.Scheme = corev1.URISchemeHTTP
+	test(false)
+
+	b.FailureThreshold = 10
+	test(true)
+
+	b = a.DeepCopy()
+	b.InitialDelaySeconds = 300
+	test(true)
+
+	b = a.DeepCopy()
+	b.HTTPGet = nil
+	b.Exec = &corev1.ExecAction{Command: []string{"/sbin/checkstate.sh"}}
+	test(true)
+}
+
 func TestGetIstioAnnotations(t *testing.T) {
 	var ports []corev1.ContainerPort
 	var want map[string]string
@@ -476,6 +543,18 @@ func TestGetLabels(t *testing.T) {
 	})
 }
 
+func TestCopyLabels(t *testing.T) {
+	if got := CopyLabels(nil); got == nil || len(got) != 0 {
+		t.Errorf("CopyLabels(nil) = %v; want empty map", got)
+	}
+	labels := map[string]string{"one": "two"}
+	got := CopyLabels(labels)
+	got["three"] = "four"
+	if !reflect.DeepEqual(labels, map[string]string{"one": "two"}) || len(got) != 2 {
+		t.Errorf("CopyLabels() = %v; want independent copy of %v", got, labels)
+	}
+}
+
 func TestAppendPodAffinity(t *testing.T) {
 	var affinity corev1.Affinity
 	identifier := "test1"
@@ -582,6 +661,174 @@ func TestValidateCommonSpec(t *testing.T) {
 	if err == nil {
 		t.Error("ValidateCommonSpec() returned nil; want ERROR")
 	}
+
+	spec.ImagePullPolicy = "IfNotPresent"
+	if spec.PodAntiAffinity != "soft" {
+		t.Errorf("ValidateCommonSpec() PodAntiAffinity = %s; want %s", spec.PodAntiAffinity, "soft")
+	}
+	spec.PodAntiAffinity = "Invalid"
+	err = ValidateCommonSpec(&spec, defaultResources)
+	if err == nil {
+		t.Error("ValidateCommonSpec() returned nil for invalid PodAntiAffinity; want ERROR")
+	}
+}
+
+func TestValidateSecurityContext(t *testing.T) {
+	root := int64(0)
+	user := int64(1000)
+	nonRoot := true
+	test := func(spec enterprisev1.CommonSpec, wantErr bool) {
+		err := ValidateSecurityContext(&spec)
+		if wantErr && err == nil {
+			t.Errorf("ValidateSecurityContext(%v) returned nil; want error", spec)
+		} else if !wantErr && err != nil {
+			t.Errorf("ValidateSecurityContext(%v) returned %v; want nil", spec, err)
+		}
+	}
+
+	test(enterprisev1.CommonSpec{}, false)
+	test(enterprisev1.CommonSpec{SeccompProfile: "runtime/default"}, false)
+	test(enterprisev1.CommonSpec{SeccompProfile: "localhost/profiles/splunk.json"}, false)
+	test(enterprisev1.CommonSpec{SeccompProfile: "RuntimeDefault"}, true)
+	test(enterprisev1.CommonSpec{PodSecurityContext: corev1.PodSecurityContext{RunAsNonRoot: &nonRoot}}, false)
+	test(enterprisev1.CommonSpec{PodSecurityContext: corev1.PodSecurityContext{RunAsNonRoot: &nonRoot, RunAsUser: &root}}, true)
+	test(enterprisev1.CommonSpec{
+		PodSecurityContext:       corev1.PodSecurityContext{RunAsNonRoot: &nonRoot, RunAsUser: &root},
+		ContainerSecurityContext: &corev1.SecurityContext{RunAsUser: &user},
+	}, false)
+	test(enterprisev1.CommonSpec{
+		PodSecurityContext:       corev1.PodSecurityContext{RunAsUser: &root},
+		ContainerSecurityContext: &corev1.SecurityContext{RunAsNonRoot: &nonRoot},
+	}, true)
+}
+
+func TestGetPodSecurityContext(t *testing.T) {
+	test := func(spec enterprisev1.CommonSpec, want string) {
+		got, _ := json.Marshal(GetPodSecurityContext(&spec))
+		if string(got) != want {
+			t.Errorf("GetPodSecurityContext() = %s; want %s", got, want)
+		}
+	}
+
+	test(enterprisev1.CommonSpec{}, `{"runAsUser":41812,"fsGroup":41812}`)
+
+	user := int64(1000)
+	nonRoot := true
+	spec := enterprisev1.CommonSpec{PodSecurityContext: corev1.PodSecurityContext{RunAsUser: &user, RunAsNonRoot: &nonRoot}}
+	test(spec, `{"runAsUser":1000,"runAsNonRoot":true,"fsGroup":41812}`)
+
+	// defaults are not set in the spec
+	GetPodSecurityContext(&spec)
+	if spec.PodSecurityContext.FSGroup != nil {
+		t.Errorf("GetPodSecurityContext() modified spec: %v", spec.PodSecurityContext)
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	defaults := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("0.1"),
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		},
+	}
+
+	test := func(resources corev1.ResourceRequirements, want string) {
+		ValidateResources(&resources, defaults)
+		got, _ := json.Marshal(resources)
+		if string(got) != want {
+			t.Errorf("ValidateResources() = %s; want %s", got, want)
+		}
+	}
+
+	test(corev1.ResourceRequirements{}, `{"limits":{"cpu":"4","memory":"8Gi"},"requests":{"cpu":"100m","memory":"512Mi"}}`)
+
+	// values given are kept
+	test(corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")},
+	}, `{"limits":{"cpu":"4","memory":"16Gi"},"requests":{"cpu":"2","memory":"512Mi"}}`)
+
+	// default limits are not less than the requests given
+	test(corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8"), corev1.ResourceMemory: resource.MustParse("32Gi")},
+	}, `{"limits":{"cpu":"8","memory":"32Gi"},"requests":{"cpu":"8","memory":"32Gi"}}`)
+
+	// default requests are not more than the limits given
+	test(corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+	}, `{"limits":{"cpu":"50m","memory":"256Mi"},"requests":{"cpu":"50m","memory":"256Mi"}}`)
+}
+
+func TestGetPodAffinity(t *testing.T) {
+	spec := enterprisev1.CommonSpec{
+		Affinity: corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{},
+		},
+	}
+	selector := getPodTypeLabelSelector("stack1", "indexer")
+
+	test := func(podAntiAffinity string, want corev1.Affinity) {
+		spec.PodAntiAffinity = podAntiAffinity
+		got := GetPodAffinity(&spec, "stack1", "indexer")
+		f := func() bool {
+			return CompareByMarshall(got, &want)
+		}
+		compareTester(t, "GetPodAffinity()", f, got, &want, false)
+	}
+
+	test("none", corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}})
+	test("soft", corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{},
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: corev1.PodAffinityTerm{LabelSelector: selector, TopologyKey: "kubernetes.io/hostname"}},
+			},
+		},
+	})
+	test("hard", corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{},
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{LabelSelector: selector, TopologyKey: "kubernetes.io/hostname"},
+			},
+		},
+	})
+	if spec.Affinity.PodAntiAffinity != nil {
+		t.Errorf("GetPodAffinity() modified spec affinity: %v", spec.Affinity)
+	}
+}
+
+func TestGetTopologySpreadConstraints(t *testing.T) {
+	spec := enterprisev1.CommonSpec{}
+	if got := GetTopologySpreadConstraints(&spec, "stack1", "indexer"); got != nil {
+		t.Errorf("GetTopologySpreadConstraints() = %v; want nil", got)
+	}
+
+	custom := corev1.TopologySpreadConstraint{
+		MaxSkew:           2,
+		TopologyKey:       "kubernetes.io/hostname",
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+	}
+	spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{custom}
+	spec.SpreadAcrossZones = true
+	got := GetTopologySpreadConstraints(&spec, "stack1", "indexer")
+	want := []corev1.TopologySpreadConstraint{
+		custom,
+		{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     getPodTypeLabelSelector("stack1", "indexer"),
+		},
+	}
+	f := func() bool {
+		return CompareByMarshall(got, want)
+	}
+	compareTester(t, "GetTopologySpreadConstraints()", f, got, want, false)
 }
 
 func TestCompareVolumes(t *testing.T) {
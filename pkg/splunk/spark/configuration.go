@@ -15,13 +15,15 @@
 package spark
 
 import (
+	"fmt"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 	"github.com/splunk/splunk-operator/pkg/splunk/resources"
 )
 
@@ -102,6 +104,17 @@ func ValidateSparkSpec(spec *enterprisev1.SparkSpec) error {
 	if spec.Replicas == 0 {
 		spec.Replicas = 1
 	}
+	if IsSparkAutoscalingEnabled(spec) {
+		if spec.MinReplicas == 0 {
+			spec.MinReplicas = 1
+		}
+		if spec.MinReplicas > spec.MaxReplicas {
+			return fmt.Errorf("MinReplicas must be less than or equal to MaxReplicas; minReplicas=%d, maxReplicas=%d", spec.MinReplicas, spec.MaxReplicas)
+		}
+		if spec.SearchesPerWorker == 0 {
+			spec.SearchesPerWorker = 1
+		}
+	}
 	defaultResources := corev1.ResourceRequirements{
 		Requests: corev1.ResourceList{
 			corev1.ResourceCPU:    resource.MustParse("0.1"),
@@ -112,7 +125,32 @@ func ValidateSparkSpec(spec *enterprisev1.SparkSpec) error {
 			corev1.ResourceMemory: resource.MustParse("8Gi"),
 		},
 	}
-	return resources.ValidateCommonSpec(&spec.CommonSpec, defaultResources)
+	if err := resources.ValidateCommonSpec(&spec.CommonSpec, defaultResources); err != nil {
+		return err
+	}
+
+	// resources for each component default to the common resources
+	resources.ValidateResources(&spec.MasterResources, spec.Resources)
+	resources.ValidateResources(&spec.WorkerResources, spec.Resources)
+	return nil
+}
+
+// IsSparkAutoscalingEnabled returns true if spark workers are scaled based on the number of active DFS searches.
+func IsSparkAutoscalingEnabled(spec *enterprisev1.SparkSpec) bool {
+	return spec.MaxReplicas > 0
+}
+
+// GetSparkWorkerReplicas returns the number of spark workers needed to handle a number of active DFS searches,
+// within the minimum and maximum number of workers allowed by a Spark resource.
+func GetSparkWorkerReplicas(spec *enterprisev1.SparkSpec, activeSearches int32) int32 {
+	replicas := (activeSearches + spec.SearchesPerWorker - 1) / spec.SearchesPerWorker
+	if replicas < spec.MinReplicas {
+		return spec.MinReplicas
+	}
+	if replicas > spec.MaxReplicas {
+		return spec.MaxReplicas
+	}
+	return replicas
 }
 
 // GetSparkDeployment returns a Kubernetes Deployment object for the Spark master configured for a Spark resource.
@@ -121,6 +159,7 @@ func GetSparkDeployment(cr *enterprisev1.Spark, instanceType InstanceType) (*app
 	var ports []corev1.ContainerPort
 	var envVariables []corev1.EnvVar
 	var replicas int32
+	var javaOpts string
 	switch instanceType {
 	case SparkMaster:
 		ports = resources.SortContainerPorts(getSparkMasterContainerPorts())
@@ -131,6 +170,7 @@ func GetSparkDeployment(cr *enterprisev1.Spark, instanceType InstanceType) (*app
 			},
 		}
 		replicas = 1
+		javaOpts = cr.Spec.MasterJavaOpts
 	case SparkWorker:
 		ports = resources.SortContainerPorts(getSparkWorkerContainerPorts())
 		envVariables = []corev1.EnvVar{
@@ -146,11 +186,19 @@ func GetSparkDeployment(cr *enterprisev1.Spark, instanceType InstanceType) (*app
 			},
 		}
 		replicas = int32(cr.Spec.Replicas)
+		javaOpts = cr.Spec.WorkerJavaOpts
+	}
+	if javaOpts != "" {
+		envVariables = append(envVariables, corev1.EnvVar{
+			Name:  "SPARK_DAEMON_JAVA_OPTS",
+			Value: javaOpts,
+		})
 	}
 
-	// prepare labels, annotations and affinity
+	// prepare labels, annotations, affinity and topology spread constraints
 	annotations := resources.GetIstioAnnotations(ports)
-	affinity := resources.AppendPodAntiAffinity(&cr.Spec.Affinity, cr.GetIdentifier(), instanceType.ToString())
+	affinity := resources.GetPodAffinity(&cr.Spec.CommonSpec, cr.GetIdentifier(), instanceType.ToString())
+	topologySpreadConstraints := resources.GetTopologySpreadConstraints(&cr.Spec.CommonSpec, cr.GetIdentifier(), instanceType.ToString())
 	selectLabels := getSparkLabels(cr.GetIdentifier(), instanceType)
 	labels := make(map[string]string)
 	for k, v := range selectLabels {
@@ -178,9 +226,13 @@ func GetSparkDeployment(cr *enterprisev1.Spark, instanceType InstanceType) (*app
 					Annotations: annotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:      affinity,
-					SchedulerName: cr.Spec.SchedulerName,
-					Hostname:      GetSparkServiceName(instanceType, cr.GetIdentifier(), false),
+					Affinity:                  affinity,
+					TopologySpreadConstraints: topologySpreadConstraints,
+					SchedulerName:             cr.Spec.SchedulerName,
+					PriorityClassName:         cr.Spec.PriorityClassName,
+					ServiceAccountName:        cr.Spec.ServiceAccountName,
+					ImagePullSecrets:          cr.Spec.ImagePullSecrets,
+					Hostname:                  GetSparkServiceName(instanceType, cr.GetIdentifier(), false),
 					Containers: []corev1.Container{
 						{
 							Image:           cr.Spec.Image,
@@ -218,6 +270,9 @@ func GetSparkService(cr *enterprisev1.Spark, instanceType InstanceType, isHeadle
 	if isHeadless {
 		service = &corev1.Service{}
 		service.Spec.ClusterIP = corev1.ClusterIPNone
+
+		// use labels from template, but not annotations, which are usually specific to load balancers
+		service.ObjectMeta.Labels = resources.CopyLabels(cr.Spec.ServiceTemplate.ObjectMeta.Labels)
 	} else {
 		service = cr.Spec.ServiceTemplate.DeepCopy()
 	}
@@ -262,12 +317,9 @@ func GetSparkService(cr *enterprisev1.Spark, instanceType InstanceType, isHeadle
 func updateSparkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec, cr *enterprisev1.Spark, instanceType InstanceType) error {
 
 	// update security context
-	runAsUser := int64(41812)
-	fsGroup := int64(41812)
-	podTemplateSpec.Spec.SecurityContext = &corev1.PodSecurityContext{
-		RunAsUser: &runAsUser,
-		FSGroup:   &fsGroup,
-	}
+	podTemplateSpec.Spec.SecurityContext = resources.GetPodSecurityContext(&cr.Spec.CommonSpec)
+	resources.SetSeccompProfile(podTemplateSpec, cr.Spec.SeccompProfile)
+	resources.SetOpenShiftServiceAccount(podTemplateSpec)
 
 	// master listens for HTTP requests on a different interface from worker
 	var httpPort intstr.IntOrString
@@ -303,11 +355,18 @@ func updateSparkPodTemplateWithConfig(podTemplateSpec *corev1.PodTemplateSpec, c
 		PeriodSeconds:       10,
 	}
 
+	// master and worker pods may use different resources
+	containerResources := cr.Spec.WorkerResources
+	if instanceType == SparkMaster {
+		containerResources = cr.Spec.MasterResources
+	}
+
 	// update each container in pod
 	for idx := range podTemplateSpec.Spec.Containers {
-		podTemplateSpec.Spec.Containers[idx].Resources = cr.Spec.Resources
+		podTemplateSpec.Spec.Containers[idx].Resources = containerResources
 		podTemplateSpec.Spec.Containers[idx].LivenessProbe = livenessProbe
 		podTemplateSpec.Spec.Containers[idx].ReadinessProbe = readinessProbe
+		podTemplateSpec.Spec.Containers[idx].SecurityContext = cr.Spec.ContainerSecurityContext.DeepCopy()
 	}
 
 	return nil
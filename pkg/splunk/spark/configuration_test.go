@@ -18,9 +18,11 @@ import (
 	"encoding/json"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1alpha2"
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
 )
 
 func TestGetSparkDeployment(t *testing.T) {
@@ -56,13 +58,22 @@ func TestGetSparkDeployment(t *testing.T) {
 
 	test(SparkMaster, `{"kind":"Deployment","apiVersion":"apps/v1","metadata":{"name":"splunk-stack1-spark-master","namespace":"test","creationTimestamp":null,"ownerReferences":[{"apiVersion":"","kind":"","name":"stack1","uid":"","controller":true}]},"spec":{"replicas":1,"selector":{"matchLabels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"}},"template":{"metadata":{"creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"annotations":{"traffic.sidecar.istio.io/excludeOutboundPorts":"8089,8191,9997,7777,9000,17000,17500,19000","traffic.sidecar.istio.io/includeInboundPorts":"8009"}},"spec":{"containers":[{"name":"spark","image":"splunk/spark","ports":[{"name":"sparkmaster","containerPort":7777,"protocol":"TCP"},{"name":"sparkwebui","containerPort":8009,"protocol":"TCP"}],"env":[{"name":"SPLUNK_ROLE","value":"splunk_spark_master"}],"resources":{"limits":{"cpu":"4","memory":"8Gi"},"requests":{"cpu":"100m","memory":"512Mi"}},"livenessProbe":{"httpGet":{"path":"/","port":8009},"initialDelaySeconds":30,"timeoutSeconds":10,"periodSeconds":10},"readinessProbe":{"httpGet":{"path":"/","port":8009},"initialDelaySeconds":5,"timeoutSeconds":10,"periodSeconds":10},"imagePullPolicy":"IfNotPresent"}],"securityContext":{"runAsUser":41812,"fsGroup":41812},"hostname":"splunk-stack1-spark-master-service","affinity":{"podAntiAffinity":{"preferredDuringSchedulingIgnoredDuringExecution":[{"weight":100,"podAffinityTerm":{"labelSelector":{"matchExpressions":[{"key":"app.kubernetes.io/instance","operator":"In","values":["splunk-stack1-spark-master"]}]},"topologyKey":"kubernetes.io/hostname"}}]}},"schedulerName":"default-scheduler"}},"strategy":{}},"status":{}}`)
 	test(SparkWorker, `{"kind":"Deployment","apiVersion":"apps/v1","metadata":{"name":"splunk-stack1-spark-worker","namespace":"test","creationTimestamp":null,"ownerReferences":[{"apiVersion":"","kind":"","name":"stack1","uid":"","controller":true}]},"spec":{"replicas":3,"selector":{"matchLabels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"}},"template":{"metadata":{"creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"},"annotations":{"traffic.sidecar.istio.io/excludeOutboundPorts":"8089,8191,9997,7777,9000,17000,17500,19000","traffic.sidecar.istio.io/includeInboundPorts":"7000"}},"spec":{"containers":[{"name":"spark","image":"splunk/spark","ports":[{"name":"workerwebui","containerPort":7000,"protocol":"TCP"},{"name":"dfwreceivedata","containerPort":17500,"protocol":"TCP"}],"env":[{"name":"SPLUNK_ROLE","value":"splunk_spark_worker"},{"name":"SPARK_MASTER_HOSTNAME","value":"splunk-stack1-spark-master-service"},{"name":"SPARK_WORKER_PORT","value":"7777"}],"resources":{"limits":{"cpu":"4","memory":"8Gi"},"requests":{"cpu":"100m","memory":"512Mi"}},"livenessProbe":{"httpGet":{"path":"/","port":7000},"initialDelaySeconds":30,"timeoutSeconds":10,"periodSeconds":10},"readinessProbe":{"httpGet":{"path":"/","port":7000},"initialDelaySeconds":5,"timeoutSeconds":10,"periodSeconds":10},"imagePullPolicy":"IfNotPresent"}],"securityContext":{"runAsUser":41812,"fsGroup":41812},"hostname":"splunk-stack1-spark-worker-service","affinity":{"podAntiAffinity":{"preferredDuringSchedulingIgnoredDuringExecution":[{"weight":100,"podAffinityTerm":{"labelSelector":{"matchExpressions":[{"key":"app.kubernetes.io/instance","operator":"In","values":["splunk-stack1-spark-worker"]}]},"topologyKey":"kubernetes.io/hostname"}}]}},"schedulerName":"default-scheduler"}},"strategy":{}},"status":{}}`)
+
+	// secrets used to pull images from private registries are added to pods
+	cr.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry-credentials"}}
+	deployment, err := GetSparkDeployment(&cr, SparkWorker)
+	if err != nil {
+		t.Errorf("GetSparkDeployment() returned error: %v", err)
+	} else if got := deployment.Spec.Template.Spec.ImagePullSecrets; len(got) != 1 || got[0].Name != "registry-credentials" {
+		t.Errorf("GetSparkDeployment() imagePullSecrets = %v; want registry-credentials", got)
+	}
 }
 
 func TestGetSparkService(t *testing.T) {
 	cr := enterprisev1.Spark{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Spark",
-			APIVersion: "enterprise.splunk.com/v1alpha2",
+			APIVersion: "enterprise.splunk.com/v1beta1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "stack1",
@@ -82,14 +93,116 @@ func TestGetSparkService(t *testing.T) {
 		}
 	}
 
-	test(SparkMaster, false, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-master-service","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"sparkmaster","port":7777,"targetPort":0},{"name":"sparkwebui","port":8009,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"}},"status":{"loadBalancer":{}}}`)
-	test(SparkMaster, true, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-master-headless","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"sparkmaster","port":7777,"targetPort":0},{"name":"sparkwebui","port":8009,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"clusterIP":"None"},"status":{"loadBalancer":{}}}`)
+	test(SparkMaster, false, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-master-service","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1beta1","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"sparkmaster","port":7777,"targetPort":0},{"name":"sparkwebui","port":8009,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"}},"status":{"loadBalancer":{}}}`)
+	test(SparkMaster, true, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-master-headless","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1beta1","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"sparkmaster","port":7777,"targetPort":0},{"name":"sparkwebui","port":8009,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-master","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-master","app.kubernetes.io/part-of":"splunk-stack1-spark"},"clusterIP":"None"},"status":{"loadBalancer":{}}}`)
 
 	cr.Spec.ServiceTemplate.Spec.Type = "LoadBalancer"
 	cr.Spec.ServiceTemplate.ObjectMeta.Labels = map[string]string{"1": "2"}
 	cr.ObjectMeta.Labels = map[string]string{"one": "two"}
 	cr.ObjectMeta.Annotations = map[string]string{"a": "b"}
 
-	test(SparkWorker, false, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-worker-service","namespace":"test","creationTimestamp":null,"labels":{"1":"2","app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark","one":"two"},"annotations":{"a":"b"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"workerwebui","port":7000,"targetPort":0},{"name":"dfwreceivedata","port":17500,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"},"type":"LoadBalancer"},"status":{"loadBalancer":{}}}`)
-	test(SparkWorker, true, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-worker-headless","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark","one":"two"},"annotations":{"a":"b"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"workerwebui","port":7000,"targetPort":0},{"name":"dfwreceivedata","port":17500,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"},"clusterIP":"None"},"status":{"loadBalancer":{}}}`)
+	test(SparkWorker, false, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-worker-service","namespace":"test","creationTimestamp":null,"labels":{"1":"2","app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark","one":"two"},"annotations":{"a":"b"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1beta1","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"workerwebui","port":7000,"targetPort":0},{"name":"dfwreceivedata","port":17500,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"},"type":"LoadBalancer"},"status":{"loadBalancer":{}}}`)
+	test(SparkWorker, true, `{"kind":"Service","apiVersion":"v1","metadata":{"name":"splunk-stack1-spark-worker-headless","namespace":"test","creationTimestamp":null,"labels":{"1":"2","app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark","one":"two"},"annotations":{"a":"b"},"ownerReferences":[{"apiVersion":"enterprise.splunk.com/v1beta1","kind":"Spark","name":"stack1","uid":"05db21b4-7244-4022-a844-c131a8747f30","controller":true}]},"spec":{"ports":[{"name":"workerwebui","port":7000,"targetPort":0},{"name":"dfwreceivedata","port":17500,"targetPort":0}],"selector":{"app.kubernetes.io/component":"spark","app.kubernetes.io/instance":"splunk-stack1-spark-worker","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"spark-worker","app.kubernetes.io/part-of":"splunk-stack1-spark"},"clusterIP":"None"},"status":{"loadBalancer":{}}}`)
+}
+
+func TestValidateSparkSpecAutoscaling(t *testing.T) {
+	spec := enterprisev1.SparkSpec{MaxReplicas: 4}
+	if err := ValidateSparkSpec(&spec); err != nil {
+		t.Errorf("ValidateSparkSpec() returned error: %v", err)
+	}
+	if spec.MinReplicas != 1 || spec.SearchesPerWorker != 1 {
+		t.Errorf("ValidateSparkSpec() minReplicas=%d searchesPerWorker=%d; want 1 1", spec.MinReplicas, spec.SearchesPerWorker)
+	}
+
+	// defaults are only set when autoscaling is enabled
+	spec = enterprisev1.SparkSpec{}
+	if err := ValidateSparkSpec(&spec); err != nil {
+		t.Errorf("ValidateSparkSpec() returned error: %v", err)
+	}
+	if spec.MinReplicas != 0 || spec.SearchesPerWorker != 0 {
+		t.Errorf("ValidateSparkSpec() set autoscaling defaults without maxReplicas: %v", spec)
+	}
+
+	spec = enterprisev1.SparkSpec{MinReplicas: 5, MaxReplicas: 4}
+	if err := ValidateSparkSpec(&spec); err == nil {
+		t.Errorf("ValidateSparkSpec() returned nil; want error when minReplicas > maxReplicas")
+	}
+}
+
+func TestSparkComponentResources(t *testing.T) {
+	cr := enterprisev1.Spark{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")}
+	cr.Spec.WorkerResources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8"), corev1.ResourceMemory: resource.MustParse("16Gi")}
+	if err := ValidateSparkSpec(&cr.Spec); err != nil {
+		t.Errorf("ValidateSparkSpec() returned error: %v", err)
+	}
+
+	test := func(instanceType InstanceType, want string) {
+		deployment, err := GetSparkDeployment(&cr, instanceType)
+		if err != nil {
+			t.Errorf("GetSparkDeployment() returned error: %v", err)
+			return
+		}
+		got, _ := json.Marshal(deployment.Spec.Template.Spec.Containers[0].Resources)
+		if string(got) != want {
+			t.Errorf("GetSparkDeployment(\"%s\") resources = %s; want %s", instanceType, got, want)
+		}
+	}
+
+	// resources not given for a component are taken from the common resources
+	test(SparkMaster, `{"limits":{"cpu":"4","memory":"4Gi"},"requests":{"cpu":"100m","memory":"512Mi"}}`)
+	test(SparkWorker, `{"limits":{"cpu":"8","memory":"16Gi"},"requests":{"cpu":"8","memory":"16Gi"}}`)
+}
+
+func TestSparkComponentJavaOpts(t *testing.T) {
+	cr := enterprisev1.Spark{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.MasterJavaOpts = "-Xms1g -Xmx1g"
+
+	test := func(instanceType InstanceType, want string) {
+		deployment, err := GetSparkDeployment(&cr, instanceType)
+		if err != nil {
+			t.Errorf("GetSparkDeployment() returned error: %v", err)
+			return
+		}
+		got := ""
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "SPARK_DAEMON_JAVA_OPTS" {
+				got = env.Value
+			}
+		}
+		if got != want {
+			t.Errorf("GetSparkDeployment(\"%s\") SPARK_DAEMON_JAVA_OPTS = %s; want %s", instanceType, got, want)
+		}
+	}
+
+	// options are only set for the components that use them
+	test(SparkMaster, "-Xms1g -Xmx1g")
+	test(SparkWorker, "")
+	cr.Spec.WorkerJavaOpts = "-XX:+UseG1GC"
+	test(SparkWorker, "-XX:+UseG1GC")
+}
+
+func TestGetSparkWorkerReplicas(t *testing.T) {
+	spec := enterprisev1.SparkSpec{MinReplicas: 2, MaxReplicas: 6, SearchesPerWorker: 2}
+	test := func(activeSearches, want int32) {
+		if got := GetSparkWorkerReplicas(&spec, activeSearches); got != want {
+			t.Errorf("GetSparkWorkerReplicas(%d) = %d; want %d", activeSearches, got, want)
+		}
+	}
+
+	test(0, 2)
+	test(4, 2)
+	test(5, 3)
+	test(10, 5)
+	test(20, 6)
 }
@@ -0,0 +1,263 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("tracing")
+
+const (
+	// maximum number of spans that are queued between exports; spans are dropped when the queue is full
+	maxQueuedSpans = 4096
+
+	// interval between exports of queued spans
+	exportInterval = 5 * time.Second
+
+	// maximum time allowed for each export request
+	exportTimeout = 10 * time.Second
+
+	// OpenTelemetry status code used for spans that failed
+	otlpStatusCodeError = 2
+)
+
+// OTLPExporter queues spans and periodically exports them to an OpenTelemetry collector using OTLP over HTTP, with
+// JSON encoding.
+type OTLPExporter struct {
+	// URL of the collector's traces endpoint (for example, "http://otel-collector:4318/v1/traces")
+	endpoint string
+
+	// resource attributes that identify the operator in all exported spans
+	resource []otlpKeyValue
+
+	// client used to send export requests
+	client *http.Client
+
+	// mutex is used to protect spans and dropped
+	mutex sync.Mutex
+
+	// spans that have ended and not been exported yet
+	spans []*Span
+
+	// number of spans dropped since the last export, because the queue was full
+	dropped int
+}
+
+// NewOTLPExporter returns a new OTLPExporter that sends spans to the traces endpoint of an OpenTelemetry collector,
+// identified using a service name and version
+func NewOTLPExporter(endpoint, serviceName, serviceVersion string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint: endpoint,
+		resource: []otlpKeyValue{
+			newOTLPKeyValue("service.name", serviceName),
+			newOTLPKeyValue("service.version", serviceVersion),
+		},
+		client: &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// Export queues a span that has ended, until it is exported
+func (e *OTLPExporter) Export(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, span)
+}
+
+// Flush sends all queued spans to the collector. Spans that could not be sent are dropped, so that a collector that is
+// unavailable does not use an increasing amount of memory.
+func (e *OTLPExporter) Flush() error {
+	e.mutex.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Info("Dropped spans because the export queue was full", "dropped", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.getRequest(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Failed to export %d spans: %v", len(spans), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Failed to export %d spans: status=%d", len(spans), response.StatusCode)
+	}
+	return nil
+}
+
+// Run exports queued spans periodically until stop is closed, and then exports any that remain
+func (e *OTLPExporter) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Flush(); err != nil {
+				log.Error(err, "Unable to export spans", "endpoint", e.endpoint)
+			}
+		case <-stop:
+			if err := e.Flush(); err != nil {
+				log.Error(err, "Unable to export spans", "endpoint", e.endpoint)
+			}
+			return
+		}
+	}
+}
+
+// getRequest returns the OTLP export request used to send spans to the collector
+func (e *OTLPExporter) getRequest(spans []*Span) *otlpExportRequest {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: "github.com/splunk/splunk-operator"}}
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, newOTLPSpan(span))
+	}
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: e.resource},
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+// otlpExportRequest is the JSON encoding of an OTLP ExportTraceServiceRequest
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// otlpResourceSpans is the JSON encoding of OTLP ResourceSpans
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+// otlpResource is the JSON encoding of an OTLP Resource
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+// otlpScopeSpans is the JSON encoding of OTLP ScopeSpans
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+// otlpScope is the JSON encoding of an OTLP InstrumentationScope
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// otlpSpan is the JSON encoding of an OTLP Span
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+// otlpStatus is the JSON encoding of an OTLP Status
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpKeyValue is the JSON encoding of an OTLP KeyValue
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is the JSON encoding of an OTLP AnyValue; integers are encoded as strings
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// newOTLPKeyValue returns the OTLP encoding of an attribute, using its string representation for unsupported types
+func newOTLPKeyValue(key string, value interface{}) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		kv.Value.IntValue = &s
+	case int32:
+		s := strconv.FormatInt(int64(v), 10)
+		kv.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+// newOTLPSpan returns the OTLP encoding of a span
+func newOTLPSpan(span *Span) otlpSpan {
+	result := otlpSpan{
+		TraceID:           span.TraceID(),
+		SpanID:            span.SpanID(),
+		ParentSpanID:      span.ParentSpanID(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+	for _, a := range span.attributes {
+		result.Attributes = append(result.Attributes, newOTLPKeyValue(a.key, a.value))
+	}
+	if span.err != "" {
+		result.Status = otlpStatus{Code: otlpStatusCodeError, Message: span.err}
+	}
+	return result
+}
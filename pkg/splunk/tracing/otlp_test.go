@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var bodies []map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("export request = %s %s (%s); want POST /v1/traces (application/json)", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("export request body is not valid JSON: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL+"/v1/traces", "splunk-operator", "0.1.0")
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	// nothing is sent if there are no spans
+	if err := exporter.Flush(); err != nil || len(bodies) != 0 {
+		t.Errorf("Flush() = %v, %d requests; want nil, 0 requests", err, len(bodies))
+	}
+
+	root := StartSpan(nil, "Reconcile Standalone", SpanKindInternal, "k8s.name", "stack1")
+	child := StartSpan(root, "GET /services/server/info", SpanKindClient, "http.status_code", 503, "retry", true)
+	child.End(errors.New("503 Service Unavailable"))
+	root.End(nil)
+	if err := exporter.Flush(); err != nil {
+		t.Fatalf("Flush() returned %v; want nil", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("Flush() sent %d requests; want 1", len(bodies))
+	}
+
+	resourceSpans := bodies[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := resourceSpans["resource"].(map[string]interface{})
+	wantResource := []interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "splunk-operator"}},
+		map[string]interface{}{"key": "service.version", "value": map[string]interface{}{"stringValue": "0.1.0"}},
+	}
+	if !reflect.DeepEqual(resource["attributes"], wantResource) {
+		t.Errorf("resource attributes = %v; want %v", resource["attributes"], wantResource)
+	}
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans; want 2", len(spans))
+	}
+	got := spans[0].(map[string]interface{})
+	if got["traceId"] != root.TraceID() || got["spanId"] != child.SpanID() || got["parentSpanId"] != root.SpanID() {
+		t.Errorf("child span ids = %v, %v, %v; want %s, %s, %s", got["traceId"], got["spanId"], got["parentSpanId"], root.TraceID(), child.SpanID(), root.SpanID())
+	}
+	if got["name"] != "GET /services/server/info" || got["kind"] != float64(SpanKindClient) {
+		t.Errorf("child span = %v, %v; want GET /services/server/info, %d", got["name"], got["kind"], SpanKindClient)
+	}
+	wantAttributes := []interface{}{
+		map[string]interface{}{"key": "http.status_code", "value": map[string]interface{}{"intValue": "503"}},
+		map[string]interface{}{"key": "retry", "value": map[string]interface{}{"boolValue": true}},
+	}
+	if !reflect.DeepEqual(got["attributes"], wantAttributes) {
+		t.Errorf("child span attributes = %v; want %v", got["attributes"], wantAttributes)
+	}
+	wantStatus := map[string]interface{}{"code": float64(2), "message": "503 Service Unavailable"}
+	if !reflect.DeepEqual(got["status"], wantStatus) {
+		t.Errorf("child span status = %v; want %v", got["status"], wantStatus)
+	}
+	got = spans[1].(map[string]interface{})
+	if _, ok := got["parentSpanId"]; ok {
+		t.Errorf("root span has parentSpanId %v; want none", got["parentSpanId"])
+	}
+	if !reflect.DeepEqual(got["status"], map[string]interface{}{}) {
+		t.Errorf("root span status = %v; want unset", got["status"])
+	}
+
+	// spans are dropped if they cannot be exported
+	status = http.StatusServiceUnavailable
+	StartSpan(nil, "Reconcile Standalone", SpanKindInternal).End(nil)
+	err := exporter.Flush()
+	if err == nil || !strings.Contains(err.Error(), "status=503") {
+		t.Errorf("Flush() returned %v; want status=503 error", err)
+	}
+	if len(exporter.spans) != 0 {
+		t.Errorf("Flush() kept %d spans; want 0", len(exporter.spans))
+	}
+
+	// spans are dropped while the queue is full
+	for i := 0; i < maxQueuedSpans+1; i++ {
+		exporter.Export(root)
+	}
+	if len(exporter.spans) != maxQueuedSpans || exporter.dropped != 1 {
+		t.Errorf("Export() queued %d and dropped %d spans; want %d and 1", len(exporter.spans), exporter.dropped, maxQueuedSpans)
+	}
+}
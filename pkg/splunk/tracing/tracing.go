@@ -0,0 +1,186 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records spans for reconciles, and the requests made to the Kubernetes API and Splunk REST API while
+// reconciling, so that they can be exported to an OpenTelemetry collector.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship between a span and the operation that it records, using OpenTelemetry values
+type SpanKind int
+
+const (
+	// SpanKindInternal is used for operations that are done within the operator, such as reconciles
+	SpanKindInternal SpanKind = 1
+
+	// SpanKindClient is used for requests sent to remote services, such as the Kubernetes API and Splunk REST API
+	SpanKindClient SpanKind = 3
+)
+
+// Exporter sends spans to a tracing backend once they have ended
+type Exporter interface {
+	Export(span *Span)
+}
+
+var (
+	// exporterMutex is used to protect exporter
+	exporterMutex sync.RWMutex
+
+	// exporter receives all spans that have ended, or nil if tracing is disabled
+	exporter Exporter
+)
+
+// SetExporter sets the Exporter that receives all spans once they have ended. Tracing is disabled if it is nil.
+func SetExporter(e Exporter) {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	exporter = e
+}
+
+// getExporter returns the current Exporter, or nil if tracing is disabled
+func getExporter() Exporter {
+	exporterMutex.RLock()
+	defer exporterMutex.RUnlock()
+	return exporter
+}
+
+// attribute is a key and value that describes a span
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span records an operation and how long it took. All methods of a nil Span do nothing, so that callers do not need
+// to check whether tracing is enabled.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	attributes []attribute
+	err        string
+}
+
+// StartSpan starts a new span, which is a child of parent or the root of a new trace if parent is nil. Key and value
+// pairs are added to the span as attributes. It returns nil if tracing is disabled.
+func StartSpan(parent *Span, name string, kind SpanKind, keysAndValues ...interface{}) *Span {
+	if getExporter() == nil {
+		return nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok {
+			span.SetAttribute(key, keysAndValues[i+1])
+		}
+	}
+	return span
+}
+
+// SetAttribute adds an attribute to a span, replacing any previous value for the same key
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// End ends a span, records err (if not nil) as the reason it failed, and sends it to the current Exporter
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	if e := getExporter(); e != nil {
+		e.Export(s)
+	}
+}
+
+// Name returns the name of a span
+func (s *Span) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+// TraceID returns the hex-encoded identifier of the trace that a span belongs to
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SpanID returns the hex-encoded identifier of a span
+func (s *Span) SpanID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.spanID[:])
+}
+
+// ParentSpanID returns the hex-encoded identifier of the parent of a span, or an empty string if it has none
+func (s *Span) ParentSpanID() string {
+	if s == nil || s.parentID == [8]byte{} {
+		return ""
+	}
+	return hex.EncodeToString(s.parentID[:])
+}
+
+// Attribute returns the value of an attribute of a span, or nil if it has not been set
+func (s *Span) Attribute(key string) interface{} {
+	if s == nil {
+		return nil
+	}
+	for _, a := range s.attributes {
+		if a.key == key {
+			return a.value
+		}
+	}
+	return nil
+}
+
+// Err returns the error recorded when a span ended, or an empty string if it did not fail
+func (s *Span) Err() string {
+	if s == nil {
+		return ""
+	}
+	return s.err
+}
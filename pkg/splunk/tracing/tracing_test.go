@@ -0,0 +1,76 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"errors"
+	"testing"
+)
+
+// testExporter records all spans that have ended
+type testExporter struct {
+	spans []*Span
+}
+
+func (e *testExporter) Export(span *Span) {
+	e.spans = append(e.spans, span)
+}
+
+func TestStartSpan(t *testing.T) {
+	// nothing is recorded while tracing is disabled
+	SetExporter(nil)
+	span := StartSpan(nil, "disabled", SpanKindInternal, "key", "value")
+	if span != nil {
+		t.Errorf("StartSpan() = %v; want nil", span)
+	}
+	span.SetAttribute("key", "value")
+	span.End(errors.New("failed"))
+	if span.TraceID() != "" || span.Attribute("key") != nil {
+		t.Errorf("nil Span returned values; want none")
+	}
+
+	exporter := &testExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	root := StartSpan(nil, "Reconcile Standalone", SpanKindInternal, "k8s.namespace", "test", "k8s.name", "stack1")
+	child := StartSpan(root, "Get Secret", SpanKindClient)
+	child.SetAttribute("k8s.name", "splunk-test-secret")
+	child.SetAttribute("k8s.name", "splunk-stack1-secret")
+	child.End(errors.New("not found"))
+	root.End(nil)
+
+	if len(exporter.spans) != 2 || exporter.spans[0] != child || exporter.spans[1] != root {
+		t.Fatalf("exported spans = %v; want child, root", exporter.spans)
+	}
+	if len(root.TraceID()) != 32 || len(root.SpanID()) != 16 || root.ParentSpanID() != "" {
+		t.Errorf("root span ids = %s, %s, %s; want trace, span and no parent", root.TraceID(), root.SpanID(), root.ParentSpanID())
+	}
+	if child.TraceID() != root.TraceID() || child.ParentSpanID() != root.SpanID() || child.SpanID() == root.SpanID() {
+		t.Errorf("child span ids = %s, %s, %s; want trace %s and parent %s", child.TraceID(), child.SpanID(), child.ParentSpanID(), root.TraceID(), root.SpanID())
+	}
+	if got := root.Attribute("k8s.namespace"); got != "test" {
+		t.Errorf("root.Attribute(k8s.namespace) = %v; want test", got)
+	}
+	if got := child.Attribute("k8s.name"); got != "splunk-stack1-secret" || len(child.attributes) != 1 {
+		t.Errorf("child.Attribute(k8s.name) = %v; want splunk-stack1-secret", got)
+	}
+	if child.Err() != "not found" || root.Err() != "" {
+		t.Errorf("span errors = %s, %s; want \"not found\", \"\"", child.Err(), root.Err())
+	}
+	if root.end.Before(root.start) {
+		t.Errorf("root span ended before it started")
+	}
+}
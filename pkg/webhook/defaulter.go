@@ -0,0 +1,197 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+	"github.com/splunk/splunk-operator/pkg/splunk/enterprise"
+	"github.com/splunk/splunk-operator/pkg/splunk/spark"
+)
+
+// Defaulter is an admission handler that fills in default values for the spec of Splunk Enterprise custom resources.
+type Defaulter struct{}
+
+// Handle applies defaults to the custom resource in an admission request, and returns a patch response.
+func (d *Defaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	scopedLog := log.WithName("Defaulter").WithValues("kind", req.Kind.Kind, "name", req.Name, "namespace", req.Namespace)
+
+	// defaults for existing custom resources may depend on their previous spec
+	var oldRaw []byte
+	if req.Operation != admissionv1beta1.Create {
+		oldRaw = req.OldObject.Raw
+	}
+	spec, err := getDefaultedSpec(req.Kind.Kind, req.Object.Raw, oldRaw)
+	if err != nil {
+		scopedLog.Info("Denied admission request", "reason", err.Error())
+		return admission.Denied(err.Error())
+	}
+	if spec == nil {
+		return admission.Allowed("")
+	}
+
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	obj["spec"] = spec
+	current, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, current)
+}
+
+// getDefaultedSpec decodes a custom resource of the given kind, applies defaults to its spec, and returns it as generic JSON.
+// oldRaw is the existing custom resource when it is being updated, or nil when it is being created; defaults that would
+// change existing custom resources are only applied when it is created. It returns nil if the kind is not handled by this
+// webhook.
+func getDefaultedSpec(kind string, raw, oldRaw []byte) (interface{}, error) {
+	var spec interface{}
+	var err error
+
+	switch kind {
+	case "IndexerCluster":
+		cr := enterprisev1.IndexerCluster{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			if oldRaw == nil {
+				enterprise.SetIndexerClusterCreateDefaults(&cr.Spec)
+			} else {
+				old := enterprisev1.IndexerCluster{}
+				if err = json.Unmarshal(oldRaw, &old); err == nil {
+					enterprise.SetIndexerClusterUpdateDefaults(&cr.Spec, &old.Spec)
+				}
+			}
+			if err == nil {
+				err = enterprise.ValidateIndexerClusterSpec(&cr.Spec)
+			}
+		}
+		spec = cr.Spec
+	case "SearchHeadCluster":
+		cr := enterprisev1.SearchHeadCluster{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateSearchHeadClusterSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "Standalone":
+		cr := enterprisev1.Standalone{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateStandaloneSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "ClusterMaster":
+		cr := enterprisev1.ClusterMaster{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateClusterMasterSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "ClusterReplication":
+		cr := enterprisev1.ClusterReplication{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateClusterReplicationSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "LicenseMaster":
+		cr := enterprisev1.LicenseMaster{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateLicenseMasterSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "MonitoringConsole":
+		cr := enterprisev1.MonitoringConsole{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateMonitoringConsoleSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "DeploymentServer":
+		cr := enterprisev1.DeploymentServer{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateDeploymentServerSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "UniversalForwarder":
+		cr := enterprisev1.UniversalForwarder{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateUniversalForwarderSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "Spark":
+		cr := enterprisev1.Spark{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = spark.ValidateSparkSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "SplunkBackup":
+		cr := enterprisev1.SplunkBackup{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateSplunkBackupSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "SplunkRestore":
+		cr := enterprisev1.SplunkRestore{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateSplunkRestoreSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	case "HecToken":
+		cr := enterprisev1.HecToken{}
+		if err = json.Unmarshal(raw, &cr); err == nil {
+			err = enterprise.ValidateHecTokenSpec(&cr.Spec)
+		}
+		spec = cr.Spec
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// round trip through generic JSON so that fields left empty by defaulting are not added as nulls
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return pruneNulls(result), nil
+}
+
+// pruneNulls recursively removes null values from maps decoded from JSON.
+func pruneNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if child == nil {
+				delete(v, key)
+			} else {
+				v[key] = pruneNulls(child)
+			}
+		}
+	case []interface{}:
+		for idx := range v {
+			v[idx] = pruneNulls(v[idx])
+		}
+	}
+	return value
+}
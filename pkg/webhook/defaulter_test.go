@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func defaulterTester(t *testing.T, kind string, raw string) admission.Response {
+	req := admission.Request{
+		AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "enterprise.splunk.com", Version: "v1alpha2", Kind: kind},
+			Name:      "stack1",
+			Namespace: "test",
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(raw)},
+		},
+	}
+	d := Defaulter{}
+	return d.Handle(context.TODO(), req)
+}
+
+func getPatchValues(resp admission.Response) map[string]string {
+	values := make(map[string]string)
+	for _, patch := range resp.Patches {
+		values[patch.Path] = fmt.Sprintf("%v", patch.Value)
+	}
+	return values
+}
+
+func TestDefaulterIndexerCluster(t *testing.T) {
+	resp := defaulterTester(t, "IndexerCluster", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"IndexerCluster","metadata":{"name":"stack1","namespace":"test"},"spec":{"etcStorage":"20Gi"}}`)
+	if !resp.Allowed {
+		t.Fatalf("Defaulter.Handle() denied request: %v", resp.Result)
+	}
+
+	values := getPatchValues(resp)
+	want := map[string]string{
+		"/spec/replicas":   "3",
+		"/spec/image":      "splunk/splunk",
+		"/spec/varStorage": "100Gi",
+	}
+	for path, value := range want {
+		if values[path] != value {
+			t.Errorf("Defaulter.Handle() patch %s = \"%s\"; want \"%s\"", path, values[path], value)
+		}
+	}
+	if _, ok := values["/spec/etcStorage"]; ok {
+		t.Errorf("Defaulter.Handle() patched user defined etcStorage")
+	}
+	for path, value := range values {
+		if value == "<nil>" {
+			t.Errorf("Defaulter.Handle() patch %s has null value", path)
+		}
+	}
+}
+
+func TestDefaulterIndexerClusterUpdate(t *testing.T) {
+	test := func(spec, oldSpec string, want string) {
+		req := admission.Request{
+			AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: "enterprise.splunk.com", Version: "v1alpha2", Kind: "IndexerCluster"},
+				Name:      "stack1",
+				Namespace: "test",
+				Operation: admissionv1beta1.Update,
+				Object:    runtime.RawExtension{Raw: []byte(`{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"IndexerCluster","metadata":{"name":"stack1","namespace":"test"},"spec":` + spec + `}`)},
+				OldObject: runtime.RawExtension{Raw: []byte(`{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"IndexerCluster","metadata":{"name":"stack1","namespace":"test"},"spec":` + oldSpec + `}`)},
+			},
+		}
+		d := Defaulter{}
+		resp := d.Handle(context.TODO(), req)
+		if !resp.Allowed {
+			t.Fatalf("Defaulter.Handle() denied request: %v", resp.Result)
+		}
+		if values := getPatchValues(resp); values["/spec/replicas"] != want {
+			t.Errorf("Defaulter.Handle() spec=%s oldSpec=%s patch /spec/replicas = \"%s\"; want \"%s\"", spec, oldSpec, values["/spec/replicas"], want)
+		}
+	}
+
+	// updates that omit replicas keep the previous number of peers, rather than scaling down the indexer cluster
+	test(`{}`, `{"replicas":3}`, "3")
+	test(`{}`, `{"replicas":5}`, "5")
+
+	// replicas in the update are not changed
+	test(`{"replicas":2}`, `{"replicas":3}`, "")
+
+	// existing indexer clusters without replicas keep the previous default number of peers
+	test(`{}`, `{}`, "1")
+}
+
+func TestDefaulterUniversalForwarder(t *testing.T) {
+	resp := defaulterTester(t, "UniversalForwarder", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"UniversalForwarder","metadata":{"name":"stack1","namespace":"test"},"spec":{"indexerClusterRef":{"name":"idxc"}}}`)
+	if !resp.Allowed {
+		t.Fatalf("Defaulter.Handle() denied request: %v", resp.Result)
+	}
+
+	values := getPatchValues(resp)
+	want := map[string]string{
+		"/spec/workloadType": "DaemonSet",
+		"/spec/image":        "splunk/universalforwarder",
+	}
+	for path, value := range want {
+		if values[path] != value {
+			t.Errorf("Defaulter.Handle() patch %s = \"%s\"; want \"%s\"", path, values[path], value)
+		}
+	}
+
+	resp = defaulterTester(t, "UniversalForwarder", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"UniversalForwarder","metadata":{"name":"stack1","namespace":"test"},"spec":{}}`)
+	if resp.Allowed {
+		t.Errorf("Defaulter.Handle() allowed UniversalForwarder without indexerClusterRef")
+	}
+}
+
+func TestDefaulterDeploymentServer(t *testing.T) {
+	resp := defaulterTester(t, "DeploymentServer", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"DeploymentServer","metadata":{"name":"stack1","namespace":"test"},"spec":{"externalService":{"enabled":true}}}`)
+	if !resp.Allowed {
+		t.Fatalf("Defaulter.Handle() denied request: %v", resp.Result)
+	}
+
+	values := getPatchValues(resp)
+	if values["/spec/externalService/serviceType"] != "LoadBalancer" {
+		t.Errorf("Defaulter.Handle() patch %s = \"%s\"; want \"%s\"", "/spec/externalService/serviceType", values["/spec/externalService/serviceType"], "LoadBalancer")
+	}
+
+	resp = defaulterTester(t, "DeploymentServer", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"DeploymentServer","metadata":{"name":"stack1","namespace":"test"},"spec":{"deploymentApps":[{"name":"../system","configMapRef":"outputs"}]}}`)
+	if resp.Allowed {
+		t.Errorf("Defaulter.Handle() allowed DeploymentServer with invalid deployment app name")
+	}
+}
+
+func TestDefaulterErrors(t *testing.T) {
+	resp := defaulterTester(t, "IndexerCluster", `{"apiVersion":"enterprise.splunk.com/v1alpha2","kind":"IndexerCluster","metadata":{"name":"stack1","namespace":"test"},"spec":{"sites":[{"name":"bad"}]}}`)
+	if resp.Allowed {
+		t.Errorf("Defaulter.Handle() allowed IndexerCluster with invalid site name")
+	}
+
+	resp = defaulterTester(t, "Standalone", `{"spec":`)
+	if resp.Allowed {
+		t.Errorf("Defaulter.Handle() allowed malformed Standalone")
+	}
+
+	resp = defaulterTester(t, "ConfigMap", `{"apiVersion":"v1","kind":"ConfigMap"}`)
+	if !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("Defaulter.Handle() ConfigMap allowed=%t patches=%d; want allowed=true patches=0", resp.Allowed, len(resp.Patches))
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	enterprisev1 "github.com/splunk/splunk-operator/pkg/apis/enterprise/v1beta1"
+)
+
+// ValidationResult is the result of validating a custom resource in a manifest
+type ValidationResult struct {
+	// Kind of the custom resource
+	Kind string
+
+	// Name of the custom resource
+	Name string
+
+	// Namespace of the custom resource, which may be empty
+	Namespace string
+
+	// Object is the custom resource with defaults applied to its spec, or nil if it is not valid
+	Object map[string]interface{}
+
+	// Err is the reason that the custom resource is not valid, or nil if it is
+	Err error
+}
+
+// ValidateManifest decodes all objects in a YAML or JSON manifest, and validates and defaults the spec of each Splunk
+// custom resource using the same code as the mutating webhook. Objects that are not Splunk custom resources are skipped.
+// An error is returned if the manifest cannot be decoded.
+func ValidateManifest(r io.Reader) ([]ValidationResult, error) {
+	var results []ValidationResult
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := make(map[string]interface{})
+		err := decoder.Decode(&obj)
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return results, err
+		}
+		if len(obj) == 0 {
+			// empty documents are ignored by kubectl
+			continue
+		}
+
+		apiVersion, _ := obj["apiVersion"].(string)
+		if !strings.HasPrefix(apiVersion, enterprisev1.SchemeGroupVersion.Group+"/") {
+			continue
+		}
+		result := validateObject(obj)
+		if result.Err == nil && !isServedVersion(apiVersion) {
+			result.Object = nil
+			result.Err = fmt.Errorf("unsupported apiVersion \"%s\"; must be one of %v", apiVersion, enterprisev1.ServedVersions)
+		}
+		results = append(results, result)
+	}
+}
+
+// isServedVersion returns true if apiVersion is one of the versions of the enterprise API group served by the CRDs
+func isServedVersion(apiVersion string) bool {
+	for _, version := range enterprisev1.ServedVersions {
+		if apiVersion == enterprisev1.SchemeGroupVersion.Group+"/"+version {
+			return true
+		}
+	}
+	return false
+}
+
+// validateObject validates and defaults a Splunk custom resource decoded from a manifest, as it would be when created
+func validateObject(obj map[string]interface{}) ValidationResult {
+	var result ValidationResult
+	result.Kind, _ = obj["kind"].(string)
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		result.Name, _ = metadata["name"].(string)
+		result.Namespace, _ = metadata["namespace"].(string)
+	}
+	if result.Name == "" {
+		result.Err = fmt.Errorf("metadata.name is required")
+		return result
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	spec, err := getDefaultedSpec(result.Kind, raw, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if spec == nil {
+		result.Err = fmt.Errorf("unknown kind \"%s\"", result.Kind)
+		return result
+	}
+
+	obj["spec"] = spec
+	result.Object = obj
+	return result
+}
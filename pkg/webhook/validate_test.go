@@ -0,0 +1,135 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateManifest(t *testing.T) {
+	manifest := `apiVersion: enterprise.splunk.com/v1alpha2
+kind: IndexerCluster
+metadata:
+  name: idxc
+  namespace: splunk
+spec:
+  etcStorage: 20Gi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: apps
+---
+---
+apiVersion: enterprise.splunk.com/v1alpha2
+kind: UniversalForwarder
+metadata:
+  name: uf
+spec: {}
+---
+apiVersion: enterprise.splunk.com/v1alpha2
+kind: Widget
+metadata:
+  name: w1
+---
+apiVersion: enterprise.splunk.com/v1alpha1
+kind: Standalone
+metadata:
+  name: s1
+---
+apiVersion: enterprise.splunk.com/v1alpha2
+kind: Standalone
+spec: {}
+---
+apiVersion: enterprise.splunk.com/v1alpha2
+kind: Standalone
+metadata:
+  name: s2
+spec:
+  replicas: "two"
+---
+apiVersion: enterprise.splunk.com/v1beta1
+kind: IndexerCluster
+metadata:
+  name: idxc-beta
+spec:
+  etcStorage: 20Gi
+---
+apiVersion: enterprise.splunk.com/v1beta1
+kind: Standalone
+metadata:
+  name: s3
+spec:
+  replicas: "two"
+`
+	results, err := ValidateManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("ValidateManifest() returned %v; want nil", err)
+	}
+
+	want := []struct {
+		kind, name, err string
+	}{
+		{"IndexerCluster", "idxc", ""},
+		{"UniversalForwarder", "uf", "IndexerClusterRef is required"},
+		{"Widget", "w1", "unknown kind"},
+		{"Standalone", "s1", "unsupported apiVersion"},
+		{"Standalone", "", "metadata.name is required"},
+		{"Standalone", "s2", "cannot unmarshal"},
+		{"IndexerCluster", "idxc-beta", ""},
+		{"Standalone", "s3", "cannot unmarshal"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("ValidateManifest() returned %d results; want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Kind != w.kind || got.Name != w.name {
+			t.Errorf("ValidateManifest() result %d = %s/%s; want %s/%s", i, got.Kind, got.Name, w.kind, w.name)
+		}
+		if w.err == "" {
+			if got.Err != nil || got.Object == nil {
+				t.Errorf("ValidateManifest() result %d = %v, %v; want object, nil", i, got.Object, got.Err)
+			}
+		} else if got.Err == nil || !strings.Contains(got.Err.Error(), w.err) || got.Object != nil {
+			t.Errorf("ValidateManifest() result %d error = %v; want %s", i, got.Err, w.err)
+		}
+	}
+
+	// defaults are applied to the spec of valid custom resources
+	spec := results[0].Object["spec"].(map[string]interface{})
+	if spec["varStorage"] != "100Gi" || spec["etcStorage"] != "20Gi" {
+		t.Errorf("ValidateManifest() spec = %v; want varStorage=100Gi etcStorage=20Gi", spec)
+	}
+	if results[0].Namespace != "splunk" {
+		t.Errorf("ValidateManifest() namespace = %s; want splunk", results[0].Namespace)
+	}
+
+	// all served API versions share the same schema, and keep their own version
+	spec = results[6].Object["spec"].(map[string]interface{})
+	if spec["varStorage"] != "100Gi" || spec["etcStorage"] != "20Gi" {
+		t.Errorf("ValidateManifest() v1beta1 spec = %v; want varStorage=100Gi etcStorage=20Gi", spec)
+	}
+	if got := results[6].Object["apiVersion"]; got != "enterprise.splunk.com/v1beta1" {
+		t.Errorf("ValidateManifest() apiVersion = %s; want enterprise.splunk.com/v1beta1", got)
+	}
+
+	// manifests that cannot be decoded are errors
+	_, err = ValidateManifest(strings.NewReader("kind: [Standalone"))
+	if err == nil {
+		t.Errorf("ValidateManifest() returned nil for malformed manifest; want error")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018-2020 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package webhook is used to serve admission webhooks for Splunk Enterprise custom resources.
+The mutating webhook fills in default values for optional fields, so that users get predictable objects.
+This package has dependencies on enterprise and spark.
+*/
+package webhook
+
+import (
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// DefaulterPath is the URL path used to serve the mutating webhook that defaults custom resources
+const DefaulterPath = "/mutate-enterprise-splunk-com-v1alpha2"
+
+var log = logf.Log.WithName("webhook")
+
+// AddToManager registers all webhooks with a webhook server listening on port, which is added to the Manager.
+// The webhook server is run by every replica of the operator, not just the leader, since the webhook service
+// may route admission requests to any of them.
+func AddToManager(mgr manager.Manager, port int) error {
+	server := &crwebhook.Server{Port: port}
+	log.Info("Registering mutating webhook", "path", DefaulterPath)
+	server.Register(DefaulterPath, &crwebhook.Admission{Handler: &Defaulter{}})
+	return mgr.Add(&nonLeaderElectionServer{server: server})
+}
+
+// nonLeaderElectionServer wraps a webhook server so that it does not wait to be elected leader before starting
+type nonLeaderElectionServer struct {
+	server *crwebhook.Server
+}
+
+// Start runs the webhook server until stop is closed
+func (s *nonLeaderElectionServer) Start(stop <-chan struct{}) error {
+	return s.server.Start(stop)
+}
+
+// InjectFunc passes the Manager's injection function to the webhook server, which uses it to set fields of its webhooks
+func (s *nonLeaderElectionServer) InjectFunc(f inject.Func) error {
+	return s.server.InjectFunc(f)
+}
+
+// NeedLeaderElection returns false, since webhooks are served by all replicas
+func (s *nonLeaderElectionServer) NeedLeaderElection() bool {
+	return false
+}